@@ -9,6 +9,7 @@ import (
 	io "io"
 	reflect "reflect"
 
+	controller "github.com/cubefs/blobstore/access/controller"
 	access0 "github.com/cubefs/blobstore/api/access"
 	codemode "github.com/cubefs/blobstore/common/codemode"
 	proto "github.com/cubefs/blobstore/common/proto"
@@ -53,6 +54,20 @@ func (mr *MockStreamHandlerMockRecorder) Alloc(arg0, arg1, arg2, arg3, arg4 inte
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Alloc", reflect.TypeOf((*MockStreamHandler)(nil).Alloc), arg0, arg1, arg2, arg3, arg4)
 }
 
+// ClusterController mocks base method.
+func (m *MockStreamHandler) ClusterController() controller.ClusterController {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClusterController")
+	ret0, _ := ret[0].(controller.ClusterController)
+	return ret0
+}
+
+// ClusterController indicates an expected call of ClusterController.
+func (mr *MockStreamHandlerMockRecorder) ClusterController() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClusterController", reflect.TypeOf((*MockStreamHandler)(nil).ClusterController))
+}
+
 // Delete mocks base method.
 func (m *MockStreamHandler) Delete(arg0 context.Context, arg1 *access0.Location) error {
 	m.ctrl.T.Helper()
@@ -111,6 +126,20 @@ func (mr *MockStreamHandlerMockRecorder) PutAt(arg0, arg1, arg2, arg3, arg4, arg
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutAt", reflect.TypeOf((*MockStreamHandler)(nil).PutAt), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
 }
 
+// SetExpiry mocks base method.
+func (m *MockStreamHandler) SetExpiry(arg0 context.Context, arg1 *access0.Location, arg2 int64, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetExpiry", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetExpiry indicates an expected call of SetExpiry.
+func (mr *MockStreamHandlerMockRecorder) SetExpiry(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetExpiry", reflect.TypeOf((*MockStreamHandler)(nil).SetExpiry), arg0, arg1, arg2, arg3)
+}
+
 // MockLimiter is a mock of Limiter interface.
 type MockLimiter struct {
 	ctrl     *gomock.Controller
@@ -81,10 +81,25 @@ type ClusterController interface {
 	GetVolumeGetter(clusterID proto.ClusterID) (VolumeGetter, error)
 	// GetConfig get specified config of key from cluster manager
 	GetConfig(ctx context.Context, key string) (string, error)
+	// SetBlobExpiry record expiry of blobs to cluster manager of specified cluster
+	SetBlobExpiry(ctx context.Context, clusterID proto.ClusterID, args *cmapi.BlobExpiryArgs) error
+	// DeleteBlobExpiry delete expiry of blobs from cluster manager of specified cluster
+	DeleteBlobExpiry(ctx context.Context, clusterID proto.ClusterID, args *cmapi.BlobExpiryArgs) error
+	// GetKvClient return client of the shared key values in specified cluster
+	GetKvClient(clusterID proto.ClusterID) (KvClient, error)
 	// ChangeChooseAlg change alloc algorithm
 	ChangeChooseAlg(alg AlgChoose) error
 }
 
+// KvClient key values in cluster manager, shared by all access nodes
+type KvClient interface {
+	GetKV(ctx context.Context, key string) (cmapi.GetKvRet, error)
+	SetKV(ctx context.Context, args *cmapi.SetKvArgs) error
+	DeleteKV(ctx context.Context, args *cmapi.DeleteKvArgs) error
+	ListKV(ctx context.Context, args *cmapi.ListKvArgs) (cmapi.ListKvRet, error)
+	IncrKV(ctx context.Context, args *cmapi.IncrKvArgs) (cmapi.IncrKvRet, error)
+}
+
 // IsValidAlg choose algorithm is valid or not
 func IsValidAlg(alg AlgChoose) bool {
 	return alg > minAlg && alg < maxAlg
@@ -349,6 +364,35 @@ func (c *clusterControllerImpl) GetVolumeGetter(clusterID proto.ClusterID) (Volu
 	return nil, fmt.Errorf("no volume getter for %d", clusterID)
 }
 
+func (c *clusterControllerImpl) SetBlobExpiry(ctx context.Context, clusterID proto.ClusterID,
+	args *cmapi.BlobExpiryArgs) error {
+	allClusters := c.clusters.Load().(clusterMap)
+	cluster, ok := allClusters[clusterID]
+	if !ok {
+		return ErrNoSuchCluster
+	}
+	return cluster.client.SetBlobExpiry(ctx, args)
+}
+
+func (c *clusterControllerImpl) DeleteBlobExpiry(ctx context.Context, clusterID proto.ClusterID,
+	args *cmapi.BlobExpiryArgs) error {
+	allClusters := c.clusters.Load().(clusterMap)
+	cluster, ok := allClusters[clusterID]
+	if !ok {
+		return ErrNoSuchCluster
+	}
+	return cluster.client.DeleteBlobExpiry(ctx, args)
+}
+
+func (c *clusterControllerImpl) GetKvClient(clusterID proto.ClusterID) (KvClient, error) {
+	allClusters := c.clusters.Load().(clusterMap)
+	cluster, ok := allClusters[clusterID]
+	if !ok {
+		return nil, ErrNoSuchCluster
+	}
+	return cluster.client, nil
+}
+
 func (c *clusterControllerImpl) GetConfig(ctx context.Context, key string) (ret string, err error) {
 	span := trace.SpanFromContextSafe(ctx)
 
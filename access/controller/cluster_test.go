@@ -44,6 +44,8 @@ func initCluster() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", consul)
 	mux.HandleFunc("/service/get", serviceGet)
+	mux.HandleFunc("/blob/expiry/set", blobExpirySet)
+	mux.HandleFunc("/blob/expiry/delete", blobExpirySet)
 
 	testServer := httptest.NewServer(mux)
 	hostAddr = testServer.URL
@@ -104,6 +106,13 @@ func serviceGet(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func blobExpirySet(w http.ResponseWriter, req *http.Request) {
+	args := clustermgr.BlobExpiryArgs{}
+	if err := json.NewDecoder(req.Body).Decode(&args); err != nil || len(args.Blobs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
 func initCC() {
 	count := 0
 	for cc == nil || cc0 == nil || cc1 == nil || cc2 == nil || cc3 == nil {
@@ -241,6 +250,27 @@ func TestAccessClusterGetHandler(t *testing.T) {
 	}
 }
 
+func TestAccessClusterSetBlobExpiry(t *testing.T) {
+	args := &clustermgr.BlobExpiryArgs{Blobs: []clustermgr.BlobExpiry{
+		{Vid: 1, MinBid: 1, Count: 1, ExpireAt: time.Now().Unix()},
+	}}
+	require.ErrorIs(t, cc1.SetBlobExpiry(context.TODO(), 2, args), controller.ErrNoSuchCluster)
+	require.NoError(t, cc1.SetBlobExpiry(context.TODO(), 1, args))
+	require.Error(t, cc1.SetBlobExpiry(context.TODO(), 1, &clustermgr.BlobExpiryArgs{}))
+
+	require.ErrorIs(t, cc1.DeleteBlobExpiry(context.TODO(), 2, args), controller.ErrNoSuchCluster)
+	require.NoError(t, cc1.DeleteBlobExpiry(context.TODO(), 1, args))
+	require.Error(t, cc1.DeleteBlobExpiry(context.TODO(), 1, &clustermgr.BlobExpiryArgs{}))
+}
+
+func TestAccessClusterGetKvClient(t *testing.T) {
+	_, err := cc1.GetKvClient(2)
+	require.ErrorIs(t, err, controller.ErrNoSuchCluster)
+	kv, err := cc1.GetKvClient(1)
+	require.NoError(t, err)
+	require.NotNil(t, kv)
+}
+
 func TestAccessClusterChangeChooseAlg(t *testing.T) {
 	cases := []struct {
 		alg controller.AlgChoose
@@ -48,10 +48,11 @@ var (
 	rediscli *redis.ClusterClient
 	cmcli    cmapi.APIAccess
 
-	dataCalled  map[proto.Vid]int
-	dataNodes   map[string]cmapi.ServiceInfo
-	dataVolumes map[proto.Vid]cmapi.VolumeInfo
-	dataDisks   map[proto.DiskID]bnapi.DiskInfo
+	dataCalled    map[proto.Vid]int
+	dataNodes     map[string]cmapi.ServiceInfo
+	dataVolumes   map[proto.Vid]cmapi.VolumeInfo
+	dataRedirects map[proto.Vid]cmapi.VolumeRedirect
+	dataDisks     map[proto.DiskID]bnapi.DiskInfo
 )
 
 func init() {
@@ -83,6 +84,14 @@ func init() {
 	}
 	dataVolumes[vid404] = cmapi.VolumeInfo{VolumeInfoBase: cmapi.VolumeInfoBase{Vid: vid404}}
 
+	dataRedirects = make(map[proto.Vid]cmapi.VolumeRedirect, 1)
+	dataRedirects[proto.Vid(8)] = cmapi.VolumeRedirect{
+		Vid:         8,
+		CodeMode:    codemode.EC6P6,
+		NewVid:      9,
+		NewCodeMode: codemode.EC16P20L2,
+	}
+
 	dataNodes = make(map[string]cmapi.ServiceInfo)
 	dataNodes[controller.AllocatorServiceName] = cmapi.ServiceInfo{
 		Nodes: []cmapi.ServiceNode{
@@ -155,6 +164,13 @@ func init() {
 			}
 			return nil, errNotFound
 		})
+	cli.EXPECT().GetVolumeRedirect(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, vid proto.Vid) (*cmapi.VolumeRedirect, error) {
+			if val, ok := dataRedirects[vid]; ok {
+				return &val, nil
+			}
+			return nil, errcode.ErrKvNotFound
+		})
 	cli.EXPECT().DiskInfo(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
 		func(ctx context.Context, id proto.DiskID) (*bnapi.DiskInfo, error) {
 			if val, ok := dataDisks[id]; ok {
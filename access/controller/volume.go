@@ -46,12 +46,15 @@ type Unit = clustermgr.Unit
 //     Vid, CodeMode and Units are from cluster mgr
 //     IsPunish is cached in memory
 //     Timestamp is cached in redis to clear outdate info
+//     Redirect is not nil if the volume was transcoded,
+//         then Vid, CodeMode and Units are of the new volume
 type VolumePhy struct {
-	Vid       proto.Vid         `json:"vid"`
-	CodeMode  codemode.CodeMode `json:"codemode"`
-	IsPunish  bool              `json:"-"`
-	Timestamp int64             `json:"timestamp"`
-	Units     []Unit            `json:"units"`
+	Vid       proto.Vid                  `json:"vid"`
+	CodeMode  codemode.CodeMode          `json:"codemode"`
+	IsPunish  bool                       `json:"-"`
+	Timestamp int64                      `json:"timestamp"`
+	Units     []Unit                     `json:"units"`
+	Redirect  *clustermgr.VolumeRedirect `json:"redirect,omitempty"`
 }
 
 // VolumeGetter getter of volume physical location
@@ -285,13 +288,29 @@ func (v *volumeGetterImpl) getFromRedis(ctx context.Context, key string) *Volume
 func (v *volumeGetterImpl) getFromClusterAndUpdate(ctx context.Context, vid proto.Vid) (*VolumePhy, error) {
 	span := trace.SpanFromContextSafe(ctx)
 	var (
-		vInfo *clustermgr.VolumeInfo
-		err   error
+		vInfo    *clustermgr.VolumeInfo
+		redirect *clustermgr.VolumeRedirect
+		err      error
 	)
 
+	// reads of transcoded volume are redirected to the new volume
+	if err = retry.ExponentialBackoff(3, 100).On(func() error {
+		redirect, err = v.cmClient.GetVolumeRedirect(ctx, vid)
+		if rpc.DetectStatusCode(err) == errcode.CodeKvNotFound {
+			redirect, err = nil, nil
+		}
+		return err
+	}); err != nil {
+		return nil, errors.Base(err, "get volume redirect from clustermgr", v.cid, vid)
+	}
+	physicalVid := vid
+	if redirect != nil {
+		physicalVid = redirect.NewVid
+	}
+
 	id := addCVid(v.cid, vid)
 	if err = retry.ExponentialBackoff(3, 100).On(func() error {
-		if vInfo, err = v.cmClient.GetVolumeInfo(ctx, &clustermgr.GetVolumeArgs{Vid: vid}); err != nil {
+		if vInfo, err = v.cmClient.GetVolumeInfo(ctx, &clustermgr.GetVolumeArgs{Vid: physicalVid}); err != nil {
 			return err
 		}
 		return nil
@@ -313,6 +332,7 @@ func (v *volumeGetterImpl) getFromClusterAndUpdate(ctx context.Context, vid prot
 		CodeMode:  vInfo.CodeMode,
 		Timestamp: time.Now().UnixNano(),
 		Units:     make([]Unit, len(vInfo.Units)),
+		Redirect:  redirect,
 	}
 	copy(phy.Units, vInfo.Units[:])
 
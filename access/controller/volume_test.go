@@ -24,6 +24,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/cubefs/blobstore/access/controller"
+	"github.com/cubefs/blobstore/common/codemode"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/redis"
 	"github.com/cubefs/blobstore/common/trace"
@@ -79,6 +80,30 @@ func TestAccessVolumeGetterNew(t *testing.T) {
 	require.Nil(t, info)
 }
 
+func TestAccessVolumeGetterRedirect(t *testing.T) {
+	_, ctx := trace.StartSpanFromContext(context.Background(), "TestAccessVolumeGetterRedirect")
+
+	getter, err := controller.NewVolumeGetter(0xfd, cmcli, rediscli, time.Millisecond*200)
+	require.Nil(t, err)
+
+	info := getter.Get(ctx, proto.Vid(8), true)
+	require.NotNil(t, info)
+	require.Equal(t, proto.Vid(9), info.Vid)
+	require.Equal(t, codemode.EC16P20L2, info.CodeMode)
+	require.Equal(t, proto.Vuid(9011), info.Units[0].Vuid)
+	require.NotNil(t, info.Redirect)
+	require.Equal(t, proto.Vid(8), info.Redirect.Vid)
+	require.Equal(t, codemode.EC6P6, info.Redirect.CodeMode)
+
+	// cached in redis with redirect
+	info = getter.Get(ctx, proto.Vid(8), true)
+	require.NotNil(t, info.Redirect)
+
+	info = getter.Get(ctx, proto.Vid(9), false)
+	require.NotNil(t, info)
+	require.Nil(t, info.Redirect)
+}
+
 func TestAccessVolumeGetterNotExistVolume(t *testing.T) {
 	_, ctx := trace.StartSpanFromContext(context.Background(), "TestAccessVolumeGetterNotExistVolume")
 
@@ -95,6 +95,49 @@ func (mr *MockClusterControllerMockRecorder) GetConfig(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConfig", reflect.TypeOf((*MockClusterController)(nil).GetConfig), arg0, arg1)
 }
 
+// SetBlobExpiry mocks base method.
+func (m *MockClusterController) SetBlobExpiry(arg0 context.Context, arg1 proto.ClusterID, arg2 *clustermgr.BlobExpiryArgs) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBlobExpiry", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBlobExpiry indicates an expected call of SetBlobExpiry.
+func (mr *MockClusterControllerMockRecorder) SetBlobExpiry(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBlobExpiry", reflect.TypeOf((*MockClusterController)(nil).SetBlobExpiry), arg0, arg1, arg2)
+}
+
+// DeleteBlobExpiry mocks base method.
+func (m *MockClusterController) DeleteBlobExpiry(arg0 context.Context, arg1 proto.ClusterID, arg2 *clustermgr.BlobExpiryArgs) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBlobExpiry", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBlobExpiry indicates an expected call of DeleteBlobExpiry.
+func (mr *MockClusterControllerMockRecorder) DeleteBlobExpiry(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBlobExpiry", reflect.TypeOf((*MockClusterController)(nil).DeleteBlobExpiry), arg0, arg1, arg2)
+}
+
+// GetKvClient mocks base method.
+func (m *MockClusterController) GetKvClient(arg0 proto.ClusterID) (controller.KvClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKvClient", arg0)
+	ret0, _ := ret[0].(controller.KvClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKvClient indicates an expected call of GetKvClient.
+func (mr *MockClusterControllerMockRecorder) GetKvClient(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKvClient", reflect.TypeOf((*MockClusterController)(nil).GetKvClient), arg0)
+}
+
 // GetServiceController mocks base method.
 func (m *MockClusterController) GetServiceController(arg0 proto.ClusterID) (controller.ServiceController, error) {
 	m.ctrl.T.Helper()
@@ -30,10 +30,30 @@ var unhealthMetric = prometheus.NewCounterVec(
 	[]string{"cluster", "action", "module", "host", "reason"},
 )
 
+var hedgeMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "blobstore",
+		Subsystem: "access",
+		Name:      "hedge_read",
+		Help:      "hedged shard reads on access",
+	},
+	[]string{"cluster", "status"},
+)
+
+const (
+	hedgeStatusFired = "fired"
+	hedgeStatusWon   = "won"
+)
+
 func init() {
 	prometheus.MustRegister(unhealthMetric)
+	prometheus.MustRegister(hedgeMetric)
 }
 
 func reportUnhealth(cid proto.ClusterID, action, module, host, reason string) {
 	unhealthMetric.WithLabelValues(cid.ToString(), action, module, host, reason).Inc()
 }
+
+func reportHedge(cid proto.ClusterID, status string) {
+	hedgeMetric.WithLabelValues(cid.ToString(), status).Inc()
+}
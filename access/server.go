@@ -15,11 +15,14 @@
 package access
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -45,6 +48,8 @@ const (
 	limitNameGet    = "get"
 	limitNameDelete = "delete"
 	limitNameSign   = "sign"
+
+	limitNameMultipart = "multipart"
 )
 
 const (
@@ -87,10 +92,13 @@ func initWithRegionMagic(regionMagic string) {
 // Config service configs
 type Config struct {
 	cmd.Config
-	ConsulAgentAddr string        `json:"consul_agent_addr"`
-	ServiceRegister consul.Config `json:"service_register"`
-	Stream          StreamConfig  `json:"stream"`
-	Limit           LimitConfig   `json:"limit"`
+	ConsulAgentAddr string          `json:"consul_agent_addr"`
+	ServiceRegister consul.Config   `json:"service_register"`
+	Stream          StreamConfig    `json:"stream"`
+	Limit           LimitConfig     `json:"limit"`
+	Multipart       MultipartConfig `json:"multipart"`
+	Dedup           DedupConfig     `json:"dedup"`
+	Tenant          TenantConfig    `json:"tenant"`
 }
 
 // Service rpc service
@@ -98,6 +106,9 @@ type Service struct {
 	config        Config
 	streamHandler StreamHandler
 	limiter       Limiter
+	multipart     *multipartManager
+	dedup         DedupIndex
+	tenants       *tenantManager
 	stopCh        chan struct{}
 }
 
@@ -115,10 +126,29 @@ func New(cfg Config) *Service {
 	initWithRegionMagic(cfg.Stream.ClusterConfig.RegionMagic)
 
 	stopCh := make(chan struct{})
+	streamHandler := NewStreamHandler(&cfg.Stream, client, stopCh)
+	var dedup DedupIndex
+	if cfg.Dedup.Enable {
+		if dedup, err = NewDedupIndex(cfg.Dedup, streamHandler.ClusterController()); err != nil {
+			log.Fatalf("new dedup index failed, err: %v", err)
+		}
+	}
+	var tenants *tenantManager
+	if cfg.Tenant.Enable {
+		if tenants, err = newTenantManager(cfg.Tenant, streamHandler.ClusterController()); err != nil {
+			log.Fatalf("new tenant manager failed, err: %v", err)
+		}
+	}
+
+	multipart := newMultipartManager(cfg.Multipart, streamHandler, tenants)
+	multipart.loopCleanExpired(stopCh)
 	return &Service{
 		config:        cfg,
-		streamHandler: NewStreamHandler(&cfg.Stream, client, stopCh),
+		streamHandler: streamHandler,
 		limiter:       NewLimiter(cfg.Limit),
+		multipart:     multipart,
+		dedup:         dedup,
+		tenants:       tenants,
 		stopCh:        stopCh,
 	}
 }
@@ -128,6 +158,11 @@ func (s *Service) Close() {
 	if s.stopCh != nil {
 		close(s.stopCh)
 	}
+	if s.dedup != nil {
+		if err := s.dedup.Close(); err != nil {
+			log.Warn("close dedup index", err)
+		}
+	}
 }
 
 // RegisterService register service to rpc
@@ -169,6 +204,12 @@ func (s *Service) Limit(c *rpc.Context) {
 		name = limitNameDelete
 	case "/sign":
 		name = limitNameSign
+	case "/multipart/init", "/multipart/put", "/multipart/complete", "/multipart/abort":
+		name = limitNameMultipart
+	default:
+		if strings.HasPrefix(c.Request.URL.Path, "/object/") {
+			name = limitNameGet
+		}
 	}
 	if name == "" {
 		return
@@ -196,25 +237,84 @@ func (s *Service) Put(c *rpc.Context) {
 	span := trace.SpanFromContextSafe(ctx)
 
 	span.Debugf("accept /put request args:%+v", args)
-	if !args.IsValid() {
+	if !args.IsValid() || isExpired(args.Expiry) {
 		span.Debugf("invalid args:%+v", args)
 		c.RespondError(errcode.ErrIllegalArguments)
 		return
 	}
 
-	hashSumMap := args.Hashes.ToHashSumMap()
-	hasherMap := make(access.HasherMap, len(hashSumMap))
-	// make hashser
-	for alg := range hashSumMap {
-		hasherMap[alg] = alg.ToHasher()
+	tenant := s.tenants.Tenant(c.Request)
+	token := newTenantToken()
+	if err := s.tenants.Acquire(ctx, tenant, token, args.Size, 1); err != nil {
+		c.RespondError(err)
+		return
 	}
+	accounted := false
+	defer func() {
+		if !accounted {
+			s.tenants.Release(ctx, tenant, token, args.Size, 1)
+		}
+	}()
 
-	rc := s.limiter.Reader(ctx, c.Request.Body)
-	loc, err := s.streamHandler.Put(ctx, rc, args.Size, hasherMap)
-	if err != nil {
-		span.Error("stream put failed", errors.Detail(err))
-		c.RespondError(httpError(err))
-		return
+	// object with expiry is not deduplicated, it will be deleted after expired
+	dedup := s.dedup
+	if args.Expiry > 0 {
+		dedup = nil
+	}
+
+	expected, _ := args.ExpectedHashSumMap()
+	hashSumMap, hasherMap := newHasherMap(args.Hashes, expected)
+	if dedup != nil {
+		if _, ok := hasherMap[access.HashAlgSHA256]; !ok {
+			hasherMap[access.HashAlgSHA256] = access.HashAlgSHA256.ToHasher()
+		}
+	}
+
+	rc := s.tenants.Reader(ctx, tenant, s.limiter.Reader(ctx, c.Request.Body))
+
+	// reference the existing object if the content was put,
+	// the body still need to be read to verify the checksums.
+	// every put holds its own reference of the deduplicated content.
+	var (
+		loc          *access.Location
+		err          error
+		deduplicated bool
+		holder       uint64
+	)
+	if dedup != nil {
+		holder = newDedupHolder()
+	}
+	if sum, ok := expected[access.HashAlgSHA256]; ok && dedup != nil {
+		if loc, err = dedup.Acquire(ctx, sum, holder); err != nil {
+			span.Warn("dedup acquire failed", err)
+		}
+		if loc != nil {
+			// crc of the content location was filled, never failed with ref
+			loc, _ = referenceLocation(loc, holder)
+		}
+		if loc != nil && loc.Size != uint64(args.Size) {
+			span.Warnf("dedup size mismatch %d != %d", loc.Size, args.Size)
+			s.deleteLocation(ctx, loc)
+			loc = nil
+		}
+		if loc != nil {
+			deduplicated = true
+			if _, err = io.CopyN(hasherMap.ToWriter(), rc, args.Size); err != nil {
+				span.Info("read deduplicated body failed", err)
+				s.deleteLocation(ctx, loc)
+				c.RespondError(errcode.ErrAccessReadRequestBody)
+				return
+			}
+		}
+	}
+
+	if loc == nil {
+		loc, err = s.streamHandler.Put(ctx, rc, args.Size, hasherMap)
+		if err != nil {
+			span.Error("stream put failed", errors.Detail(err))
+			c.RespondError(httpError(err))
+			return
+		}
 	}
 
 	// hasher sum
@@ -222,12 +322,51 @@ func (s *Service) Put(c *rpc.Context) {
 		hashSumMap[alg] = hasher.Sum(nil)
 	}
 
-	if err := fillCrc(loc); err != nil {
-		span.Error("stream put fill location crc", err)
-		c.RespondError(httpError(err))
+	if !hashSumMap.Verify(expected) {
+		span.Warnf("checksum mismatch expected:%+v actual:%+v", expected.All(), hashSumMap.All())
+		if err := s.deleteLocation(ctx, loc); err != nil {
+			span.Error("delete mismatched location failed", errors.Detail(err))
+		}
+		c.RespondError(errcode.ErrAccessChecksumMismatch)
 		return
 	}
 
+	if !deduplicated {
+		if err := fillCrc(loc); err != nil {
+			span.Error("stream put fill location crc", err)
+			c.RespondError(httpError(err))
+			return
+		}
+		if dedup != nil {
+			loc, deduplicated = s.addDeduplicated(ctx, hashSumMap[access.HashAlgSHA256], loc, holder)
+		}
+	}
+	if args.Expiry > 0 {
+		if err := s.streamHandler.SetExpiry(ctx, loc, args.Expiry, s.tenants.OwnerKey(tenant, loc)); err != nil {
+			span.Error("stream put set expiry failed", errors.Detail(err))
+			if err := s.streamHandler.Delete(ctx, loc); err != nil {
+				span.Error("delete location without expiry failed", errors.Detail(err))
+			}
+			c.RespondError(httpError(err))
+			return
+		}
+	}
+	if dedup != nil && (args.Hashes|expected.ToHashAlgorithm())&access.HashAlgSHA256 == 0 {
+		delete(hashSumMap, access.HashAlgSHA256)
+	}
+
+	// the owner of deduplicated location was charged
+	if !deduplicated {
+		if err := s.tenants.Own(ctx, tenant, token, loc); err != nil {
+			span.Error("stream put own location failed", errors.Detail(err))
+			if err := s.deleteLocation(ctx, loc); err != nil {
+				span.Error("delete location without owner failed", errors.Detail(err))
+			}
+			c.RespondError(httpError(err))
+			return
+		}
+		accounted = true
+	}
 	c.RespondJSON(access.PutResp{
 		Location:   *loc,
 		HashSumMap: hashSumMap,
@@ -267,14 +406,10 @@ func (s *Service) PutAt(c *rpc.Context) {
 		return
 	}
 
-	hashSumMap := args.Hashes.ToHashSumMap()
-	hasherMap := make(access.HasherMap, len(hashSumMap))
-	// make hashser
-	for alg := range hashSumMap {
-		hasherMap[alg] = alg.ToHasher()
-	}
+	expected, _ := args.ExpectedHashSumMap()
+	hashSumMap, hasherMap := newHasherMap(args.Hashes, expected)
 
-	rc := s.limiter.Reader(ctx, c.Request.Body)
+	rc := s.tenants.Reader(ctx, s.tenants.Tenant(c.Request), s.limiter.Reader(ctx, c.Request.Body))
 	err := s.streamHandler.PutAt(ctx, rc, args.ClusterID, args.Vid, args.Blobid, args.Size, hasherMap)
 	if err != nil {
 		span.Error("stream putat failed", errors.Detail(err))
@@ -287,6 +422,12 @@ func (s *Service) PutAt(c *rpc.Context) {
 		hashSumMap[alg] = hasher.Sum(nil)
 	}
 
+	if !hashSumMap.Verify(expected) {
+		span.Warnf("checksum mismatch expected:%+v actual:%+v", expected.All(), hashSumMap.All())
+		c.RespondError(errcode.ErrAccessChecksumMismatch)
+		return
+	}
+
 	c.RespondJSON(access.PutAtResp{HashSumMap: hashSumMap})
 	span.Infof("done /putat request hash:%+v", hashSumMap.All())
 }
@@ -303,12 +444,25 @@ func (s *Service) Alloc(c *rpc.Context) {
 	span := trace.SpanFromContextSafe(ctx)
 
 	span.Debugf("accept /alloc request args:%+v", args)
-	if !args.IsValid() {
+	if !args.IsValid() || isExpired(args.Expiry) {
 		span.Debugf("invalid args:%+v", args)
 		c.RespondError(errcode.ErrIllegalArguments)
 		return
 	}
 
+	tenant := s.tenants.Tenant(c.Request)
+	token := newTenantToken()
+	if err := s.tenants.Acquire(ctx, tenant, token, int64(args.Size), 1); err != nil {
+		c.RespondError(err)
+		return
+	}
+	accounted := false
+	defer func() {
+		if !accounted {
+			s.tenants.Release(ctx, tenant, token, int64(args.Size), 1)
+		}
+	}()
+
 	location, err := s.streamHandler.Alloc(ctx, args.Size, args.BlobSize, args.AssignClusterID, args.CodeMode)
 	if err != nil {
 		span.Error("stream alloc failed", errors.Detail(err))
@@ -321,11 +475,31 @@ func (s *Service) Alloc(c *rpc.Context) {
 		c.RespondError(httpError(err))
 		return
 	}
+	if args.Expiry > 0 {
+		if err := s.streamHandler.SetExpiry(ctx, location, args.Expiry, s.tenants.OwnerKey(tenant, location)); err != nil {
+			span.Error("stream alloc set expiry failed", errors.Detail(err))
+			if err := s.streamHandler.Delete(ctx, location); err != nil {
+				span.Error("delete location without expiry failed", errors.Detail(err))
+			}
+			c.RespondError(httpError(err))
+			return
+		}
+	}
+
+	if err := s.tenants.Own(ctx, tenant, token, location); err != nil {
+		span.Error("stream alloc own location failed", errors.Detail(err))
+		if err := s.streamHandler.Delete(ctx, location); err != nil {
+			span.Error("delete location without owner failed", errors.Detail(err))
+		}
+		c.RespondError(httpError(err))
+		return
+	}
 
 	resp := access.AllocResp{
 		Location: *location,
 		Tokens:   genTokens(location),
 	}
+	accounted = true
 	c.RespondJSON(resp)
 	span.Infof("done /alloc request resp:%+v", resp)
 }
@@ -349,7 +523,7 @@ func (s *Service) Get(c *rpc.Context) {
 	}
 
 	w := c.Writer
-	writer := s.limiter.Writer(ctx, w)
+	writer := s.tenants.Writer(ctx, s.tenants.Tenant(c.Request), s.limiter.Writer(ctx, w))
 	transfer, err := s.streamHandler.Get(ctx, writer, args.Location, args.ReadSize, args.Offset)
 	if err != nil {
 		span.Error("stream get prepare failed", errors.Detail(err))
@@ -391,12 +565,20 @@ func (s *Service) Delete(c *rpc.Context) {
 
 	var err error
 	var resp access.DeleteResp
+	// locations without references to be deleted
+	var locations []access.Location
 	defer func() {
 		if err != nil {
 			c.RespondError(httpError(err))
 			return
 		}
 
+		for idx := range locations {
+			if loc := &locations[idx]; !locationsContain(resp.FailedLocations, loc) {
+				s.tenants.ReleaseLocation(ctx, loc)
+			}
+		}
+
 		if len(resp.FailedLocations) > 0 {
 			span.Errorf("failed locations N %d of %d", len(resp.FailedLocations), len(args.Locations))
 			// must return 2xx even if has failed locations,
@@ -416,21 +598,44 @@ func (s *Service) Delete(c *rpc.Context) {
 	span.Debugf("accept /delete request args: locations %d", len(args.Locations))
 	defer span.Info("done /delete request")
 
-	clusterBlobsN := make(map[proto.ClusterID]int, 4)
 	for _, loc := range args.Locations {
 		if !verifyCrc(&loc) {
 			span.Infof("invalid crc %+v", loc)
 			err = errcode.ErrIllegalArguments
 			return
 		}
+	}
+
+	// deduplicated location is deleted only if no references left
+	locations = args.Locations
+	if s.dedup != nil {
+		locations = make([]access.Location, 0, len(args.Locations))
+		for _, loc := range args.Locations {
+			toDelete, e := s.dedup.Release(ctx, loc)
+			if e != nil {
+				span.Error("dedup release failed", loc, e)
+				resp.FailedLocations = append(resp.FailedLocations, loc)
+				continue
+			}
+			if toDelete {
+				locations = append(locations, loc)
+			}
+		}
+	}
+	if len(locations) == 0 {
+		return
+	}
+
+	clusterBlobsN := make(map[proto.ClusterID]int, 4)
+	for _, loc := range locations {
 		clusterBlobsN[loc.ClusterID] += len(loc.Blobs)
 	}
 
-	if len(args.Locations) == 1 {
-		loc := args.Locations[0]
+	if len(locations) == 1 {
+		loc := locations[0]
 		if err := s.streamHandler.Delete(ctx, &loc); err != nil {
 			span.Error("stream delete failed", errors.Detail(err))
-			resp.FailedLocations = []access.Location{loc}
+			resp.FailedLocations = append(resp.FailedLocations, loc)
 		}
 		return
 	}
@@ -442,11 +647,17 @@ func (s *Service) Delete(c *rpc.Context) {
 	// max delete locations is 1024, one location is max to 5G,
 	// merged message max size about 40MB.
 
+	// packed location marks tombstone on the shared blob, cannot be merged.
 	merged := make(map[proto.ClusterID][]access.SliceInfo, len(clusterBlobsN))
+	packed := make(map[proto.ClusterID][]access.Location)
 	for id, n := range clusterBlobsN {
 		merged[id] = make([]access.SliceInfo, 0, n)
 	}
-	for _, loc := range args.Locations {
+	for _, loc := range locations {
+		if loc.Pack != nil {
+			packed[loc.ClusterID] = append(packed[loc.ClusterID], loc)
+			continue
+		}
 		merged[loc.ClusterID] = append(merged[loc.ClusterID], loc.Blobs...)
 	}
 
@@ -456,9 +667,9 @@ func (s *Service) Delete(c *rpc.Context) {
 	go func() {
 		for id := range failedCh {
 			if resp.FailedLocations == nil {
-				resp.FailedLocations = make([]access.Location, 0, len(args.Locations))
+				resp.FailedLocations = make([]access.Location, 0, len(locations))
 			}
-			for _, loc := range args.Locations {
+			for _, loc := range locations {
 				if loc.ClusterID == id {
 					resp.FailedLocations = append(resp.FailedLocations, loc)
 				}
@@ -470,15 +681,25 @@ func (s *Service) Delete(c *rpc.Context) {
 	wg.Add(len(merged))
 	for id := range merged {
 		go func(id proto.ClusterID) {
-			if err := s.streamHandler.Delete(ctx, &access.Location{
-				ClusterID: id,
-				BlobSize:  1,
-				Blobs:     merged[id],
-			}); err != nil {
-				span.Error("stream delete failed", id, errors.Detail(err))
-				failedCh <- id
+			defer wg.Done()
+			if len(merged[id]) > 0 {
+				if err := s.streamHandler.Delete(ctx, &access.Location{
+					ClusterID: id,
+					BlobSize:  1,
+					Blobs:     merged[id],
+				}); err != nil {
+					span.Error("stream delete failed", id, errors.Detail(err))
+					failedCh <- id
+					return
+				}
+			}
+			for idx := range packed[id] {
+				if err := s.streamHandler.Delete(ctx, &packed[id][idx]); err != nil {
+					span.Error("stream delete packed failed", id, errors.Detail(err))
+					failedCh <- id
+					return
+				}
 			}
-			wg.Done()
 		}(id)
 	}
 
@@ -566,6 +787,61 @@ func (s *Service) Sign(c *rpc.Context) {
 	span.Infof("done /sign request crc %d -> %d, resp:%+v", crcOld, loc.Crc, loc)
 }
 
+// deleteLocation deletes the location if it is not referenced by others
+func (s *Service) deleteLocation(ctx context.Context, loc *access.Location) error {
+	if s.dedup != nil {
+		toDelete, err := s.dedup.Release(ctx, *loc)
+		if err != nil || !toDelete {
+			return err
+		}
+	}
+	if err := s.streamHandler.Delete(ctx, loc); err != nil {
+		return err
+	}
+	s.tenants.ReleaseLocation(ctx, loc)
+	return nil
+}
+
+// addDeduplicated adds the new location into dedup index, returns reference
+// of the holder to the location, or to the existing location and deletes
+// the new one if the content was put, returns true if it is the existing.
+// The new location is not deduplicated if failed to add.
+func (s *Service) addDeduplicated(ctx context.Context, sum []byte, loc *access.Location, holder uint64) (*access.Location, bool) {
+	span := trace.SpanFromContextSafe(ctx)
+	existing, added, err := s.dedup.Add(ctx, sum, *loc, holder)
+	if err != nil {
+		span.Warn("dedup add failed", err)
+		return loc, false
+	}
+	// crc of the content location was filled, never failed with ref
+	ref, _ := referenceLocation(&existing, holder)
+	if added {
+		return ref, false
+	}
+
+	span.Infof("deduplicated location:%+v existing:%+v", loc, existing)
+	if err := s.streamHandler.Delete(ctx, loc); err != nil {
+		span.Warn("delete deduplicated location failed", errors.Detail(err))
+	}
+	return ref, true
+}
+
+// newHasherMap returns hashers of the algorithms and the expected checksums
+func newHasherMap(algs access.HashAlgorithm, expected access.HashSumMap) (access.HashSumMap, access.HasherMap) {
+	hashSumMap := (algs | expected.ToHashAlgorithm()).ToHashSumMap()
+	hasherMap := make(access.HasherMap, len(hashSumMap))
+	// make hashser
+	for alg := range hashSumMap {
+		hasherMap[alg] = alg.ToHasher()
+	}
+	return hashSumMap, hasherMap
+}
+
+// isExpired returns true if the expiry is set and not in the future
+func isExpired(expiry int64) bool {
+	return expiry > 0 && expiry <= time.Now().Unix()
+}
+
 func httpError(err error) error {
 	if e, ok := err.(rpc.HTTPError); ok {
 		return e
@@ -0,0 +1,477 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cubefs/blobstore/access/controller"
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/api/clustermgr"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+const (
+	// DedupIndexCluster index in key values of cluster manager, shared by all access nodes
+	DedupIndexCluster = "cluster"
+	// DedupIndexMemory index in memory of this access node, lost after restart
+	DedupIndexMemory = "memory"
+	// DedupIndexKVStore index in local kvstore of this access node
+	DedupIndexKVStore = "kvstore"
+)
+
+const (
+	dedupSumPrefix      = "access/dedup/sum/"
+	dedupRefPrefix      = "access/dedup/ref/"
+	dedupLocationPrefix = "access/dedup/loc/"
+)
+
+var errDedupNotFound = errors.New("dedup key not found")
+
+// DedupConfig content-addressed deduplication config
+// Objects are addressed by SHA-256 of the content, an existing object
+// is referenced again instead of writing new shards.
+// Index is one of "cluster", "memory" and "kvstore", default is "cluster",
+// references of a location are kept in cluster manager of the location.
+// "memory" and "kvstore" index only the objects put in this access node,
+// they are for single access node, Path is the kvstore directory.
+type DedupConfig struct {
+	Enable bool   `json:"enable"`
+	Index  string `json:"index"`
+	Path   string `json:"path"`
+}
+
+// DedupIndex index of deduplicated objects, sum is SHA-256 of the content.
+// Every put holds its own reference of the content with a unique holder,
+// locations in the index are content locations without Ref.
+type DedupIndex interface {
+	// Acquire adds reference of the holder to the content, returns nil if not exists.
+	Acquire(ctx context.Context, sum []byte, holder uint64) (*access.Location, error)
+	// Add adds the location with reference of the holder if the sum not exists,
+	// otherwise acquires the existing one and returns it.
+	// Returns true if the location was added.
+	Add(ctx context.Context, sum []byte, loc access.Location, holder uint64) (access.Location, bool, error)
+	// Release removes reference of the holder in Ref of the location, it is idempotent.
+	// Returns true if the location need to be deleted really, no holders left or not deduplicated.
+	Release(ctx context.Context, loc access.Location) (bool, error)
+	Close() error
+}
+
+// dedupStore key-value storage of the index
+type dedupStore interface {
+	get(ctx context.Context, key string) ([]byte, error)
+	set(ctx context.Context, kvs ...clustermgr.KeyValue) error
+	delete(ctx context.Context, keys ...string) error
+	// exist returns true if any key with the prefix exists
+	exist(ctx context.Context, prefix string) (bool, error)
+	close() error
+}
+
+type memoryStore struct {
+	lock sync.RWMutex
+	kvs  map[string][]byte
+}
+
+func (s *memoryStore) get(ctx context.Context, key string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	val, ok := s.kvs[key]
+	if !ok {
+		return nil, errDedupNotFound
+	}
+	return val, nil
+}
+
+func (s *memoryStore) set(ctx context.Context, kvs ...clustermgr.KeyValue) error {
+	s.lock.Lock()
+	for _, kv := range kvs {
+		s.kvs[kv.Key] = kv.Value
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *memoryStore) delete(ctx context.Context, keys ...string) error {
+	s.lock.Lock()
+	for _, key := range keys {
+		delete(s.kvs, key)
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *memoryStore) exist(ctx context.Context, prefix string) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for key := range s.kvs {
+		if strings.HasPrefix(key, prefix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryStore) close() error { return nil }
+
+type kvStore struct {
+	db kvstore.KVStore
+}
+
+func (s *kvStore) get(ctx context.Context, key string) ([]byte, error) {
+	val, err := s.db.Get([]byte(key))
+	if err == kvstore.ErrNotFound {
+		return nil, errDedupNotFound
+	}
+	return val, err
+}
+
+func (s *kvStore) set(ctx context.Context, kvs ...clustermgr.KeyValue) error {
+	batch := make([]kvstore.KV, 0, len(kvs))
+	for _, kv := range kvs {
+		batch = append(batch, kvstore.KV{Key: []byte(kv.Key), Value: kv.Value})
+	}
+	return s.db.WriteBatch(batch, false)
+}
+
+func (s *kvStore) delete(ctx context.Context, keys ...string) error {
+	batch := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		batch = append(batch, []byte(key))
+	}
+	return s.db.DeleteBatch(batch, false)
+}
+
+func (s *kvStore) exist(ctx context.Context, prefix string) (bool, error) {
+	iter := s.db.NewIterator(nil)
+	defer iter.Close()
+	iter.Seek([]byte(prefix))
+	if iter.Err() != nil {
+		return false, iter.Err()
+	}
+	return iter.ValidForPrefix([]byte(prefix)), nil
+}
+
+func (s *kvStore) close() error { return s.db.Close() }
+
+// clusterStore key values in cluster manager, operations are linearizable by raft
+type clusterStore struct {
+	kv controller.KvClient
+}
+
+func (s *clusterStore) get(ctx context.Context, key string) ([]byte, error) {
+	ret, err := s.kv.GetKV(ctx, key)
+	if err != nil {
+		if isKvNotFound(err) {
+			return nil, errDedupNotFound
+		}
+		return nil, err
+	}
+	return ret.Value, nil
+}
+
+func (s *clusterStore) set(ctx context.Context, kvs ...clustermgr.KeyValue) error {
+	return s.kv.SetKV(ctx, &clustermgr.SetKvArgs{Kvs: kvs})
+}
+
+func (s *clusterStore) delete(ctx context.Context, keys ...string) error {
+	return s.kv.DeleteKV(ctx, &clustermgr.DeleteKvArgs{Keys: keys})
+}
+
+func (s *clusterStore) exist(ctx context.Context, prefix string) (bool, error) {
+	ret, err := s.kv.ListKV(ctx, &clustermgr.ListKvArgs{Prefix: prefix, Count: 1})
+	if err != nil {
+		return false, err
+	}
+	return len(ret.Kvs) > 0, nil
+}
+
+func (s *clusterStore) close() error { return nil }
+
+// dedupIndex keeps every reference of location with key "ref/location/holder",
+// location of sum with key "sum/sum", and sum of location with key "loc/location".
+//
+// The content is deleted only after its sum removed and no holders left,
+// a holder is added before checking the sum again, so a new reference
+// is either seen by the releaser or given up by the acquirer.
+type dedupIndex struct {
+	local    dedupStore // nil if index in cluster manager
+	clusters controller.ClusterController
+}
+
+// NewDedupIndex returns a DedupIndex with config, the cluster index is shared
+// by all access nodes, the local index is for single access node.
+func NewDedupIndex(cfg DedupConfig, clusters controller.ClusterController) (DedupIndex, error) {
+	switch cfg.Index {
+	case "", DedupIndexCluster:
+		if clusters == nil {
+			return nil, errors.New("cluster dedup index without clusters")
+		}
+		return &dedupIndex{clusters: clusters}, nil
+	case DedupIndexMemory:
+		return &dedupIndex{local: &memoryStore{kvs: make(map[string][]byte)}}, nil
+	case DedupIndexKVStore:
+		if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+			return nil, err
+		}
+		db, err := kvstore.OpenDB(cfg.Path, false, &kvstore.RocksDBOption{CreateIfMissing: true})
+		if err != nil {
+			return nil, err
+		}
+		return &dedupIndex{local: &kvStore{db: db}}, nil
+	default:
+		return nil, fmt.Errorf("invalid dedup index %s", cfg.Index)
+	}
+}
+
+// store returns the store which indexes locations of the cluster
+func (d *dedupIndex) store(clusterID proto.ClusterID) (dedupStore, error) {
+	if d.local != nil {
+		return d.local, nil
+	}
+	kv, err := d.clusters.GetKvClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return &clusterStore{kv: kv}, nil
+}
+
+func (d *dedupIndex) stores() ([]dedupStore, error) {
+	if d.local != nil {
+		return []dedupStore{d.local}, nil
+	}
+	clusters := d.clusters.All()
+	stores := make([]dedupStore, 0, len(clusters))
+	for _, cluster := range clusters {
+		store, err := d.store(cluster.ClusterID)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, store)
+	}
+	return stores, nil
+}
+
+func dedupSumKey(sum []byte) string {
+	return dedupSumPrefix + hex.EncodeToString(sum)
+}
+
+func dedupHolderPrefix(hash string) string {
+	return dedupRefPrefix + hash + "/"
+}
+
+func dedupHolderKey(hash string, holder uint64) string {
+	return fmt.Sprintf("%s%016x", dedupHolderPrefix(hash), holder)
+}
+
+// locationHash the encoding with crc is unique for every location,
+// crc signs the pack if it is packed in a shared blob.
+func locationHash(loc *access.Location) string {
+	sum := sha256.Sum256(loc.Encode())
+	return hex.EncodeToString(sum[:])
+}
+
+func isKvNotFound(err error) bool {
+	return rpc.DetectStatusCode(err) == errcode.CodeKvNotFound
+}
+
+// newDedupHolder returns a random holder of reference
+func newDedupHolder() uint64 {
+	var buf [8]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			panic(err)
+		}
+		if holder := binary.BigEndian.Uint64(buf[:]); holder > 0 {
+			return holder
+		}
+	}
+}
+
+// referenceLocation returns the reference of the holder to the content location
+func referenceLocation(loc *access.Location, holder uint64) (*access.Location, error) {
+	ref := loc.Copy()
+	ref.Ref = &access.Ref{Holder: holder}
+	if err := fillCrc(&ref); err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+// contentLocation returns the content location of the reference
+func contentLocation(loc *access.Location) (*access.Location, error) {
+	content := loc.Copy()
+	if content.Ref == nil {
+		return &content, nil
+	}
+	content.Ref = nil
+	if err := fillCrc(&content); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+func (d *dedupIndex) acquire(ctx context.Context, store dedupStore, sum []byte, holder uint64) (*access.Location, error) {
+	key := dedupSumKey(sum)
+	val, err := store.get(ctx, key)
+	if err != nil {
+		if err == errDedupNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	loc, _, err := access.DecodeLocation(val)
+	if err != nil {
+		return nil, err
+	}
+
+	hkey := dedupHolderKey(locationHash(&loc), holder)
+	if err = store.set(ctx, clustermgr.KeyValue{Key: hkey, Value: sum}); err != nil {
+		return nil, err
+	}
+	// the location may be releasing if the sum has gone or changed
+	again, err := store.get(ctx, key)
+	if err == nil && bytes.Equal(again, val) {
+		return &loc, nil
+	}
+	if err != nil && err != errDedupNotFound {
+		return nil, err
+	}
+	if err = store.delete(ctx, hkey); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (d *dedupIndex) Acquire(ctx context.Context, sum []byte, holder uint64) (*access.Location, error) {
+	stores, err := d.stores()
+	if err != nil {
+		return nil, err
+	}
+	for _, store := range stores {
+		loc, err := d.acquire(ctx, store, sum, holder)
+		if err != nil {
+			return nil, err
+		}
+		if loc != nil {
+			trace.SpanFromContextSafe(ctx).Debugf("dedup acquire %x holder %x", sum, holder)
+			return loc, nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *dedupIndex) Add(ctx context.Context, sum []byte, loc access.Location, holder uint64) (access.Location, bool, error) {
+	existing, err := d.Acquire(ctx, sum, holder)
+	if err != nil {
+		return loc, false, err
+	}
+	if existing != nil {
+		trace.SpanFromContextSafe(ctx).Debugf("dedup add %x existing", sum)
+		return *existing, false, nil
+	}
+
+	store, err := d.store(loc.ClusterID)
+	if err != nil {
+		return loc, false, err
+	}
+	hash := locationHash(&loc)
+	if err = store.set(ctx,
+		clustermgr.KeyValue{Key: dedupHolderKey(hash, holder), Value: sum},
+		clustermgr.KeyValue{Key: dedupLocationPrefix + hash, Value: sum},
+		clustermgr.KeyValue{Key: dedupSumKey(sum), Value: loc.Encode()},
+	); err != nil {
+		return loc, false, err
+	}
+	trace.SpanFromContextSafe(ctx).Debugf("dedup add %x holder %x", sum, holder)
+	return loc, true, nil
+}
+
+func (d *dedupIndex) Release(ctx context.Context, loc access.Location) (bool, error) {
+	// location without reference is not deduplicated
+	if loc.Ref == nil {
+		return true, nil
+	}
+	span := trace.SpanFromContextSafe(ctx)
+	content, err := contentLocation(&loc)
+	if err != nil {
+		return false, err
+	}
+	store, err := d.store(loc.ClusterID)
+	if err != nil {
+		return false, err
+	}
+
+	hash := locationHash(content)
+	// released holder is deleted again, no more references are released
+	if err = store.delete(ctx, dedupHolderKey(hash, loc.Ref.Holder)); err != nil {
+		return false, err
+	}
+	prefix := dedupHolderPrefix(hash)
+	if exist, err := store.exist(ctx, prefix); err != nil || exist {
+		span.Debugf("dedup release %s holder %x, others exist %v", hash, loc.Ref.Holder, exist)
+		return false, err
+	}
+
+	// remove the sum if it still points to the location, then no more acquirers
+	lkey := dedupLocationPrefix + hash
+	var sumKey string
+	encoded := content.Encode()
+	if sum, err := store.get(ctx, lkey); err == nil {
+		if val, err := store.get(ctx, dedupSumKey(sum)); err == nil && bytes.Equal(val, encoded) {
+			sumKey = dedupSumKey(sum)
+			if err = store.delete(ctx, sumKey); err != nil {
+				return false, err
+			}
+		}
+	} else if err != errDedupNotFound {
+		return false, err
+	}
+
+	// acquired by others before the sum removed
+	exist, err := store.exist(ctx, prefix)
+	if err != nil || exist {
+		if exist && sumKey != "" {
+			err = store.set(ctx, clustermgr.KeyValue{Key: sumKey, Value: encoded})
+		}
+		span.Debugf("dedup release %s holder %x, acquired by others", hash, loc.Ref.Holder)
+		return false, err
+	}
+	if err = store.delete(ctx, lkey); err != nil {
+		span.Warnf("dedup release %s delete location failed %s", hash, err.Error())
+	}
+	span.Debugf("dedup release %s no references", hash)
+	return true, nil
+}
+
+func (d *dedupIndex) Close() error {
+	if d.local != nil {
+		return d.local.close()
+	}
+	return nil
+}
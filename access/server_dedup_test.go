@@ -0,0 +1,326 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
+)
+
+func newDedupLocation(bid proto.BlobID) access.Location {
+	loc := location.Copy()
+	loc.Size = 1024
+	loc.Blobs[0].MinBid = bid
+	fillCrc(&loc)
+	return loc
+}
+
+func refDedupLocation(t *testing.T, loc access.Location, holder uint64) access.Location {
+	ref, err := referenceLocation(&loc, holder)
+	require.NoError(t, err)
+	require.True(t, verifyCrc(ref))
+	return *ref
+}
+
+func testDedupIndex(t *testing.T, index DedupIndex) {
+	sumA := sha256.Sum256([]byte("a"))
+	sumB := sha256.Sum256([]byte("b"))
+	locA := newDedupLocation(100)
+	locB := newDedupLocation(200)
+
+	loc, err := index.Acquire(ctx, sumA[:], 1)
+	require.NoError(t, err)
+	require.Nil(t, loc)
+
+	// not deduplicated location
+	toDelete, err := index.Release(ctx, locA)
+	require.NoError(t, err)
+	require.True(t, toDelete)
+
+	existing, added, err := index.Add(ctx, sumA[:], locA, 1)
+	require.NoError(t, err)
+	require.True(t, added)
+	require.Equal(t, locA, existing)
+
+	// same content with another location
+	existing, added, err = index.Add(ctx, sumA[:], locB, 2)
+	require.NoError(t, err)
+	require.False(t, added)
+	require.Equal(t, locA, existing)
+
+	loc, err = index.Acquire(ctx, sumA[:], 3)
+	require.NoError(t, err)
+	require.Equal(t, locA, *loc)
+
+	existing, added, err = index.Add(ctx, sumB[:], locB, 4)
+	require.NoError(t, err)
+	require.True(t, added)
+	require.Equal(t, locB, existing)
+
+	// holders of A are 1, 2, 3, repeated release of a holder is idempotent
+	for ii := 0; ii < 3; ii++ {
+		toDelete, err = index.Release(ctx, refDedupLocation(t, locA, 2))
+		require.NoError(t, err)
+		require.False(t, toDelete)
+	}
+	toDelete, err = index.Release(ctx, refDedupLocation(t, locA, 3))
+	require.NoError(t, err)
+	require.False(t, toDelete)
+	toDelete, err = index.Release(ctx, refDedupLocation(t, locA, 1))
+	require.NoError(t, err)
+	require.True(t, toDelete)
+	loc, err = index.Acquire(ctx, sumA[:], 5)
+	require.NoError(t, err)
+	require.Nil(t, loc)
+
+	toDelete, err = index.Release(ctx, refDedupLocation(t, locB, 4))
+	require.NoError(t, err)
+	require.True(t, toDelete)
+
+	// packed location with envelope
+	packed := newDedupLocation(300)
+	packed.Pack = &access.Pack{Offset: 10, Index: 1, Count: 2}
+	fillCrc(&packed)
+	packed.Envelope = &access.Envelope{KeyID: "key", WrappedKey: []byte("wrapped"), ChunkSize: 1 << 16}
+	_, added, err = index.Add(ctx, sumB[:], packed, 6)
+	require.NoError(t, err)
+	require.True(t, added)
+	loc, err = index.Acquire(ctx, sumB[:], 7)
+	require.NoError(t, err)
+	require.Equal(t, packed, *loc)
+
+	other := newDedupLocation(300)
+	toDelete, err = index.Release(ctx, other)
+	require.NoError(t, err)
+	require.True(t, toDelete)
+	toDelete, err = index.Release(ctx, refDedupLocation(t, packed, 7))
+	require.NoError(t, err)
+	require.False(t, toDelete)
+
+	require.NoError(t, index.Close())
+}
+
+func TestAccessDedupIndexCluster(t *testing.T) {
+	ctr := gomock.NewController(t)
+	kv := newMemKv()
+	index, err := NewDedupIndex(DedupConfig{}, newMockClusters(ctr, kv))
+	require.NoError(t, err)
+	testDedupIndex(t, index)
+	// only the packed location is referenced by one holder
+	require.Equal(t, 3, len(kv.kvs))
+
+	_, err = NewDedupIndex(DedupConfig{Index: DedupIndexCluster}, nil)
+	require.Error(t, err)
+	_, err = NewDedupIndex(DedupConfig{Index: "redis"}, nil)
+	require.Error(t, err)
+}
+
+func TestAccessDedupIndexMemory(t *testing.T) {
+	index, err := NewDedupIndex(DedupConfig{Index: DedupIndexMemory}, nil)
+	require.NoError(t, err)
+	testDedupIndex(t, index)
+}
+
+func TestAccessDedupIndexKVStore(t *testing.T) {
+	path, err := os.MkdirTemp(os.TempDir(), "dedup")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+
+	index, err := NewDedupIndex(DedupConfig{Index: DedupIndexKVStore, Path: path}, nil)
+	require.NoError(t, err)
+	testDedupIndex(t, index)
+}
+
+func TestAccessDedupIndexReleasing(t *testing.T) {
+	index, err := NewDedupIndex(DedupConfig{Index: DedupIndexMemory}, nil)
+	require.NoError(t, err)
+	store := index.(*dedupIndex).local.(*memoryStore)
+
+	sum := sha256.Sum256([]byte("releasing"))
+	loc := newDedupLocation(100)
+	_, added, err := index.Add(ctx, sum[:], loc, 1)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	// the sum has been removed by the releaser, the acquirer gives up
+	val := store.kvs[dedupSumKey(sum[:])]
+	removing := &removingStore{memoryStore: store, key: dedupSumKey(sum[:])}
+	acquired, err := index.(*dedupIndex).acquire(ctx, removing, sum[:], 2)
+	require.NoError(t, err)
+	require.Nil(t, acquired)
+	exist, err := store.exist(ctx, dedupHolderKey(locationHash(&loc), 2))
+	require.NoError(t, err)
+	require.False(t, exist)
+
+	// the releaser sees the holder added before the sum removed
+	store.kvs[dedupSumKey(sum[:])] = val
+	acquired, err = index.Acquire(ctx, sum[:], 3)
+	require.NoError(t, err)
+	require.Equal(t, loc, *acquired)
+	toDelete, err := index.Release(ctx, refDedupLocation(t, loc, 1))
+	require.NoError(t, err)
+	require.False(t, toDelete)
+	acquired, err = index.Acquire(ctx, sum[:], 4)
+	require.NoError(t, err)
+	require.Equal(t, loc, *acquired)
+}
+
+// removingStore removes the key after the first get of it
+type removingStore struct {
+	*memoryStore
+	key string
+}
+
+func (s *removingStore) get(ctx context.Context, key string) ([]byte, error) {
+	val, err := s.memoryStore.get(ctx, key)
+	if key == s.key {
+		s.memoryStore.delete(ctx, key)
+	}
+	return val, err
+}
+
+func TestAccessServiceDedup(t *testing.T) {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	bid := proto.BlobID(1000)
+	putN := 0
+	s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, rc io.Reader, size int64, hasherMap access.HasherMap) (*access.Location, error) {
+			if _, err := io.CopyN(hasherMap.ToWriter(), rc, size); err != nil {
+				return nil, err
+			}
+			putN++
+			bid++
+			loc := location.Copy()
+			loc.Size = uint64(size)
+			loc.Blobs[0].MinBid = bid
+			return &loc, nil
+		})
+	var deleted []access.Location
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, location *access.Location) error {
+			deleted = append(deleted, *location)
+			return nil
+		})
+
+	index, err := NewDedupIndex(DedupConfig{Enable: true}, newMockClusters(ctr, newMemKv()))
+	require.NoError(t, err)
+	svc := &Service{
+		streamHandler: s,
+		limiter:       NewLimiter(LimitConfig{}),
+		dedup:         index,
+	}
+	defer svc.Close()
+	rpc.RegisterArgsParser(&access.PutArgs{}, "json")
+	rpc.RegisterArgsParser(&access.DeleteArgs{}, "json")
+	router := rpc.New()
+	router.Handle(http.MethodPut, "/put", svc.Put, rpc.OptArgsQuery())
+	router.Handle(http.MethodPost, "/delete", svc.Delete, rpc.OptArgsBody())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	cli := newClient()
+
+	buff := make([]byte, 1024)
+	for idx := range buff {
+		buff[idx] = byte(idx)
+	}
+	sum := sha256.Sum256(buff)
+	sumHex := hex.EncodeToString(sum[:])
+
+	put := func(query string) (access.PutResp, error) {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/put?size=1024"+query, bytes.NewReader(buff))
+		resp := access.PutResp{}
+		err := cli.DoWith(ctx, req, &resp, rpc.WithCrcEncode())
+		return resp, err
+	}
+
+	// put without sha256, computed in server
+	resp1, err := put("")
+	require.NoError(t, err)
+	require.Equal(t, 1, putN)
+	require.Equal(t, 0, len(resp1.HashSumMap))
+	require.True(t, verifyCrc(&resp1.Location))
+
+	// put again, the new location deleted
+	resp2, err := put("&hashes=16")
+	require.NoError(t, err)
+	require.Equal(t, 2, putN)
+	require.NotNil(t, resp2.Location.Ref)
+	require.NotEqual(t, resp1.Location.Ref.Holder, resp2.Location.Ref.Holder)
+	require.Equal(t, resp1.Location.Blobs, resp2.Location.Blobs)
+	require.True(t, verifyCrc(&resp2.Location))
+	require.Equal(t, sum[:], resp2.HashSumMap[access.HashAlgSHA256])
+	require.Equal(t, 1, len(deleted))
+	require.NotEqual(t, resp1.Location.Blobs, deleted[0].Blobs)
+
+	// put with expected sha256, no new object
+	resp3, err := put("&expected_sha256=" + sumHex)
+	require.NoError(t, err)
+	require.Equal(t, 2, putN)
+	require.Equal(t, resp1.Location.Blobs, resp3.Location.Blobs)
+	require.NotEqual(t, resp2.Location.Ref.Holder, resp3.Location.Ref.Holder)
+
+	// mismatched content with expected sha256
+	wrong := sha256.Sum256([]byte("wrong"))
+	_, _, err = index.Add(ctx, wrong[:], newDedupLocation(1), 1)
+	require.NoError(t, err)
+	_, err = put("&expected_sha256=" + hex.EncodeToString(wrong[:]))
+	assertErrorCode(t, 467, err)
+	require.Equal(t, 2, putN)
+	require.Equal(t, 1, len(deleted))
+	toDelete, err := index.Release(ctx, refDedupLocation(t, newDedupLocation(1), 1))
+	require.NoError(t, err)
+	require.True(t, toDelete)
+
+	// 3 references, retried deletes release the same reference
+	deleted = deleted[:0]
+	args := access.DeleteArgs{Locations: []access.Location{resp1.Location, resp1.Location}}
+	delResp := access.DeleteResp{}
+	for ii := 0; ii < 2; ii++ {
+		require.NoError(t, cli.PostWith(ctx, server.URL+"/delete", &delResp, args))
+		require.Equal(t, 0, len(delResp.FailedLocations))
+		require.Equal(t, 0, len(deleted))
+	}
+	args = access.DeleteArgs{Locations: []access.Location{resp2.Location}}
+	require.NoError(t, cli.PostWith(ctx, server.URL+"/delete", &delResp, args))
+	require.Equal(t, 0, len(delResp.FailedLocations))
+	require.Equal(t, 0, len(deleted))
+
+	args = access.DeleteArgs{Locations: []access.Location{resp3.Location}}
+	require.NoError(t, cli.PostWith(ctx, server.URL+"/delete", &delResp, args))
+	require.Equal(t, 0, len(delResp.FailedLocations))
+	require.Equal(t, 1, len(deleted))
+	require.Equal(t, resp1.Location.Blobs, deleted[0].Blobs)
+
+	// not deduplicated any more
+	resp4, err := put("&expected_sha256=" + sumHex)
+	require.NoError(t, err)
+	require.Equal(t, 3, putN)
+	require.NotEqual(t, resp1.Location.Blobs, resp4.Location.Blobs)
+}
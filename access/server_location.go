@@ -64,6 +64,24 @@ func calcCrc(loc *access.Location) (uint32, error) {
 	if _, err := crcWriter.Write(buf[4:n]); err != nil {
 		return 0, fmt.Errorf("fill crc %s", err.Error())
 	}
+	// sign pack of packed object, the crc of unpacked location is not changed
+	if loc.Pack != nil {
+		if _, err := crcWriter.Write(loc.Pack.Encode()); err != nil {
+			return 0, fmt.Errorf("fill crc %s", err.Error())
+		}
+	}
+	// sign stripe layout, the crc of location without stripe is not changed
+	if loc.Stripe != nil {
+		if _, err := crcWriter.Write(loc.Stripe.Encode()); err != nil {
+			return 0, fmt.Errorf("fill crc %s", err.Error())
+		}
+	}
+	// sign holder of deduplicated reference, the crc of the content location is not changed
+	if loc.Ref != nil {
+		if _, err := crcWriter.Write(loc.Ref.Encode()); err != nil {
+			return 0, fmt.Errorf("fill crc %s", err.Error())
+		}
+	}
 
 	return crcWriter.Sum32(), nil
 }
@@ -89,9 +107,15 @@ func signCrc(loc *access.Location, locs []access.Location) error {
 	first := locs[0]
 	bids := make(map[proto.BlobID]struct{}, 64)
 
+	// packed object and deduplicated reference share blobs with others, cannot be merged
+	if loc.Pack != nil || loc.Ref != nil {
+		return fmt.Errorf("shared location cannot be signed")
+	}
+
 	if loc.ClusterID != first.ClusterID ||
 		loc.CodeMode != first.CodeMode ||
-		loc.BlobSize != first.BlobSize {
+		loc.BlobSize != first.BlobSize ||
+		!stripeEqual(loc.Stripe, first.Stripe) {
 		return fmt.Errorf("not equal in constant field")
 	}
 
@@ -99,11 +123,15 @@ func signCrc(loc *access.Location, locs []access.Location) error {
 		if !verifyCrc(&l) {
 			return fmt.Errorf("not equal in crc %d", l.Crc)
 		}
+		if l.Pack != nil || l.Ref != nil {
+			return fmt.Errorf("shared location cannot be signed")
+		}
 
 		// assert
 		if l.ClusterID != first.ClusterID ||
 			l.CodeMode != first.CodeMode ||
-			l.BlobSize != first.BlobSize {
+			l.BlobSize != first.BlobSize ||
+			!stripeEqual(l.Stripe, first.Stripe) {
 			return fmt.Errorf("not equal in constant field")
 		}
 
@@ -125,3 +153,10 @@ func signCrc(loc *access.Location, locs []access.Location) error {
 
 	return fillCrc(loc)
 }
+
+func stripeEqual(a, b *access.Stripe) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Unit == b.Unit
+}
@@ -77,6 +77,28 @@ func TestAccessServiceLocationCrc(t *testing.T) {
 		loc.Crc = 0x9e17bc9e
 		require.True(t, verifyCrc(&loc))
 	}
+	{
+		loc := testMinLoc.Copy()
+		loc.Size = 1 << 30
+		loc.Crc = 0x9e17bc9e
+		loc.Pack = &access.Pack{Offset: 1024, Index: 1, Count: 2}
+		require.False(t, verifyCrc(&loc))
+
+		require.NoError(t, fillCrc(&loc))
+		require.True(t, verifyCrc(&loc))
+		loc.Pack.Offset++
+		require.False(t, verifyCrc(&loc))
+	}
+	{
+		loc := testMinLoc.Copy()
+		loc.Stripe = &access.Stripe{Unit: 1 << 16}
+		require.False(t, verifyCrc(&loc))
+
+		require.NoError(t, fillCrc(&loc))
+		require.True(t, verifyCrc(&loc))
+		loc.Stripe.Unit++
+		require.False(t, verifyCrc(&loc))
+	}
 }
 
 func TestAccessServiceLocationSecret(t *testing.T) {
@@ -152,6 +174,30 @@ func TestAccessServiceLocationSignCrc(t *testing.T) {
 		fillCrc(&loc2)
 		require.Error(t, signCrc(loc, []access.Location{loc1, loc2}))
 	}
+	{
+		loc1, loc2 := loc.Copy(), loc.Copy()
+		loc2.Pack = &access.Pack{Index: 0, Count: 1}
+		fillCrc(&loc2)
+		require.Error(t, signCrc(loc, []access.Location{loc1, loc2}))
+	}
+	{
+		packed := loc.Copy()
+		packed.Pack = &access.Pack{Index: 0, Count: 1}
+		require.Error(t, signCrc(&packed, []access.Location{loc.Copy()}))
+	}
+	{
+		loc1, loc2 := loc.Copy(), loc.Copy()
+		loc2.Stripe = &access.Stripe{Unit: 1 << 16}
+		fillCrc(&loc2)
+		require.Error(t, signCrc(loc, []access.Location{loc1, loc2}))
+
+		striped := loc.Copy()
+		striped.Stripe = &access.Stripe{Unit: 1 << 16}
+		loc1.Stripe = &access.Stripe{Unit: 1 << 16}
+		fillCrc(&loc1)
+		require.NoError(t, signCrc(&striped, []access.Location{loc1, loc2}))
+		require.True(t, verifyCrc(&striped))
+	}
 }
 
 func calcCrcWithoutMagic(loc *access.Location) (uint32, error) {
@@ -0,0 +1,623 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cubefs/blobstore/access/controller"
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/api/clustermgr"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+const (
+	defaultMultipartExpirationS = 24 * 60 * 60
+	defaultMultipartMaxUploads  = 1 << 16
+	defaultMultipartIntervalS   = 60
+
+	multipartUploadPrefix = "access/multipart/upload/"
+	multipartPartPrefix   = "access/multipart/part/"
+	multipartCountKey     = "access/multipart/count"
+	multipartListCount    = 1000
+)
+
+// MultipartConfig multipart upload config
+// Uploads are kept in the key values of cluster manager where the upload
+// allocated, so parts of one upload can be put into any access node.
+type MultipartConfig struct {
+	// ExpirationS upload will be aborted if not completed in seconds
+	ExpirationS int `json:"expiration_s"`
+	// MaxUploads max uploading number in one cluster
+	MaxUploads int `json:"max_uploads"`
+	// CheckIntervalS interval seconds to clean expired uploads
+	CheckIntervalS int `json:"check_interval_s"`
+}
+
+// multipartUpload one uploading with allocated location,
+// blob of part[i] is the i-th blob of location.
+type multipartUpload struct {
+	Location access.Location `json:"location"`
+	ExpireAt int64           `json:"expire_at"`
+}
+
+func (u *multipartUpload) expired() bool {
+	return time.Now().Unix() > u.ExpireAt
+}
+
+// uploadID is cluster id and uuid, like "1-d3b07384-...",
+// an upload is recorded by key of upload id, and an uploaded
+// part is recorded by key of upload id and part index.
+func multipartUploadKey(uploadID string) string {
+	return multipartUploadPrefix + uploadID
+}
+
+func multipartPartKey(uploadID string, index uint32) string {
+	return fmt.Sprintf("%s%s/%010d", multipartPartPrefix, uploadID, index)
+}
+
+func multipartClusterID(uploadID string) (proto.ClusterID, bool) {
+	idx := strings.IndexByte(uploadID, '-')
+	if idx <= 0 {
+		return 0, false
+	}
+	clusterID, err := strconv.ParseUint(uploadID[:idx], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return proto.ClusterID(clusterID), true
+}
+
+type multipartManager struct {
+	config        MultipartConfig
+	streamHandler StreamHandler
+	tenants       *tenantManager
+}
+
+func newMultipartManager(cfg MultipartConfig, streamHandler StreamHandler, tenants *tenantManager) *multipartManager {
+	cfg.ExpirationS = defaultInt(cfg.ExpirationS, defaultMultipartExpirationS)
+	cfg.MaxUploads = defaultInt(cfg.MaxUploads, defaultMultipartMaxUploads)
+	cfg.CheckIntervalS = defaultInt(cfg.CheckIntervalS, defaultMultipartIntervalS)
+	return &multipartManager{
+		config:        cfg,
+		streamHandler: streamHandler,
+		tenants:       tenants,
+	}
+}
+
+func (m *multipartManager) kvClient(clusterID proto.ClusterID) (controller.KvClient, error) {
+	return m.streamHandler.ClusterController().GetKvClient(clusterID)
+}
+
+func (m *multipartManager) add(ctx context.Context, location *access.Location) (string, int64, error) {
+	kv, err := m.kvClient(location.ClusterID)
+	if err != nil {
+		return "", 0, err
+	}
+	// the upload is counted once with token of upload id
+	uploadID := fmt.Sprintf("%d-%s", location.ClusterID, uuid.New().String())
+	_, err = kv.IncrKV(ctx, &clustermgr.IncrKvArgs{
+		Key:   multipartCountKey,
+		Delta: 1,
+		Max:   int64(m.config.MaxUploads),
+		Token: uploadID,
+	})
+	if err != nil {
+		if rpc.DetectStatusCode(err) == errcode.CodeKvCounterExceedLimit {
+			return "", 0, errcode.ErrAccessLimited
+		}
+		return "", 0, err
+	}
+
+	upload := multipartUpload{
+		Location: location.Copy(),
+		ExpireAt: time.Now().Add(time.Duration(m.config.ExpirationS) * time.Second).Unix(),
+	}
+	value, err := json.Marshal(upload)
+	if err == nil {
+		err = kv.SetKV(ctx, &clustermgr.SetKvArgs{Kvs: []clustermgr.KeyValue{
+			{Key: multipartUploadKey(uploadID), Value: value},
+		}})
+	}
+	if err != nil {
+		m.decrCount(ctx, kv, uploadID)
+		return "", 0, err
+	}
+	return uploadID, upload.ExpireAt, nil
+}
+
+func (m *multipartManager) load(ctx context.Context, uploadID string) (controller.KvClient, *multipartUpload, error) {
+	clusterID, ok := multipartClusterID(uploadID)
+	if !ok {
+		return nil, nil, errcode.ErrAccessNoSuchUpload
+	}
+	kv, err := m.kvClient(clusterID)
+	if err != nil {
+		return nil, nil, err
+	}
+	ret, err := kv.GetKV(ctx, multipartUploadKey(uploadID))
+	if err != nil {
+		if rpc.DetectStatusCode(err) == errcode.CodeKvNotFound {
+			return nil, nil, errcode.ErrAccessNoSuchUpload
+		}
+		return nil, nil, err
+	}
+	upload := &multipartUpload{}
+	if err = json.Unmarshal(ret.Value, upload); err != nil {
+		return nil, nil, err
+	}
+	return kv, upload, nil
+}
+
+// get returns blob of the part index
+func (m *multipartManager) get(ctx context.Context, uploadID string, index uint32) (access.Location, access.Blob, error) {
+	_, upload, err := m.load(ctx, uploadID)
+	if err != nil {
+		return access.Location{}, access.Blob{}, err
+	}
+	if upload.expired() {
+		return access.Location{}, access.Blob{}, errcode.ErrAccessNoSuchUpload
+	}
+	blobs := upload.Location.Spread()
+	if int(index) >= len(blobs) {
+		return access.Location{}, access.Blob{}, errcode.ErrIllegalArguments
+	}
+	return upload.Location, blobs[index], nil
+}
+
+// markUploaded records the part, and checks the upload again
+// in case of it was aborted or expired when putting the part.
+// Returns ErrAccessNoSuchUpload only if the upload had gone.
+func (m *multipartManager) markUploaded(ctx context.Context, uploadID string, index uint32) error {
+	kv, _, err := m.load(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	key := multipartPartKey(uploadID, index)
+	if err = kv.SetKV(ctx, &clustermgr.SetKvArgs{Kvs: []clustermgr.KeyValue{{Key: key}}}); err != nil {
+		return err
+	}
+	_, _, err = m.load(ctx, uploadID)
+	if err == errcode.ErrAccessNoSuchUpload {
+		if e := kv.DeleteKV(ctx, &clustermgr.DeleteKvArgs{Keys: []string{key}}); e != nil {
+			trace.SpanFromContextSafe(ctx).Warnf("delete part %s failed %s", key, errors.Detail(e))
+		}
+	}
+	return err
+}
+
+func (m *multipartManager) uploadedIndexes(ctx context.Context, kv controller.KvClient, uploadID string) ([]uint32, error) {
+	prefix := multipartPartPrefix + uploadID + "/"
+	args := &clustermgr.ListKvArgs{Prefix: prefix, Count: multipartListCount}
+	indexes := make([]uint32, 0, 16)
+	for {
+		ret, err := kv.ListKV(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range ret.Kvs {
+			index, err := strconv.ParseUint(strings.TrimPrefix(item.Key, prefix), 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, uint32(index))
+		}
+		if ret.Marker == "" {
+			return indexes, nil
+		}
+		args.Marker = ret.Marker
+	}
+}
+
+func (m *multipartManager) list(ctx context.Context, uploadID string) (access.MultipartListResp, error) {
+	kv, upload, err := m.load(ctx, uploadID)
+	if err != nil {
+		return access.MultipartListResp{}, err
+	}
+	if upload.expired() {
+		return access.MultipartListResp{}, errcode.ErrAccessNoSuchUpload
+	}
+	indexes, err := m.uploadedIndexes(ctx, kv, uploadID)
+	if err != nil {
+		return access.MultipartListResp{}, err
+	}
+	return access.MultipartListResp{
+		UploadID:  uploadID,
+		PartCount: uint32(len(upload.Location.Spread())),
+		Uploaded:  indexes,
+	}, nil
+}
+
+// complete removes the upload if all parts were uploaded
+func (m *multipartManager) complete(ctx context.Context, uploadID string) (access.Location, error) {
+	kv, upload, err := m.load(ctx, uploadID)
+	if err != nil {
+		return access.Location{}, err
+	}
+	if upload.expired() {
+		return access.Location{}, errcode.ErrAccessNoSuchUpload
+	}
+	indexes, err := m.uploadedIndexes(ctx, kv, uploadID)
+	if err != nil {
+		return access.Location{}, err
+	}
+	if len(indexes) != len(upload.Location.Spread()) {
+		return access.Location{}, errcode.ErrAccessPartsIncomplete
+	}
+	if err = m.remove(ctx, kv, uploadID, upload); err != nil {
+		return access.Location{}, err
+	}
+	return upload.Location, nil
+}
+
+// abort deletes blobs of the upload, the upload is removed
+// only after the blobs were sent to the delete queue.
+func (m *multipartManager) abort(ctx context.Context, uploadID string) error {
+	kv, upload, err := m.load(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	return m.deleteAndRemove(ctx, kv, uploadID, upload)
+}
+
+func (m *multipartManager) deleteAndRemove(ctx context.Context, kv controller.KvClient,
+	uploadID string, upload *multipartUpload) error {
+	loc := upload.Location.Copy()
+	if err := m.streamHandler.Delete(ctx, &loc); err != nil {
+		return err
+	}
+	m.tenants.ReleaseLocation(ctx, &loc)
+	return m.remove(ctx, kv, uploadID, upload)
+}
+
+// remove deletes keys of parts firstly, then the upload
+func (m *multipartManager) remove(ctx context.Context, kv controller.KvClient,
+	uploadID string, upload *multipartUpload) error {
+	partCount := uint32(len(upload.Location.Spread()))
+	keys := make([]string, 0, multipartListCount)
+	for index := uint32(0); index < partCount; index++ {
+		keys = append(keys, multipartPartKey(uploadID, index))
+		if len(keys) == multipartListCount || index == partCount-1 {
+			if err := kv.DeleteKV(ctx, &clustermgr.DeleteKvArgs{Keys: keys}); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := kv.DeleteKV(ctx, &clustermgr.DeleteKvArgs{Keys: []string{multipartUploadKey(uploadID)}}); err != nil {
+		return err
+	}
+	m.decrCount(ctx, kv, uploadID)
+	return nil
+}
+
+// decrCount the upload is uncounted once with token of upload id,
+// even if it is removed by concurrent access nodes.
+func (m *multipartManager) decrCount(ctx context.Context, kv controller.KvClient, uploadID string) {
+	_, err := kv.IncrKV(ctx, &clustermgr.IncrKvArgs{
+		Key:       multipartCountKey,
+		Delta:     -1,
+		MustExist: true,
+		Token:     uploadID,
+	})
+	if err != nil {
+		trace.SpanFromContextSafe(ctx).Warnf("decrease multipart count failed %s", errors.Detail(err))
+	}
+}
+
+// cleanExpired delete blobs of expired uploads in all clusters,
+// an upload will be checked next time if failed to delete.
+func (m *multipartManager) cleanExpired(ctx context.Context) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	for _, cluster := range m.streamHandler.ClusterController().All() {
+		kv, err := m.kvClient(cluster.ClusterID)
+		if err != nil {
+			span.Warnf("get kv client of cluster %d failed %s", cluster.ClusterID, errors.Detail(err))
+			continue
+		}
+
+		args := &clustermgr.ListKvArgs{Prefix: multipartUploadPrefix, Count: multipartListCount}
+		for {
+			ret, err := kv.ListKV(ctx, args)
+			if err != nil {
+				span.Warnf("list uploads of cluster %d failed %s", cluster.ClusterID, errors.Detail(err))
+				break
+			}
+			for _, item := range ret.Kvs {
+				uploadID := strings.TrimPrefix(item.Key, multipartUploadPrefix)
+				upload := &multipartUpload{}
+				if err = json.Unmarshal(item.Value, upload); err != nil {
+					span.Warnf("invalid upload %s %s", uploadID, err.Error())
+					continue
+				}
+				if !upload.expired() {
+					continue
+				}
+				if err = m.deleteAndRemove(ctx, kv, uploadID, upload); err != nil {
+					span.Warnf("delete expired upload %s failed %s", uploadID, errors.Detail(err))
+					continue
+				}
+				span.Infof("deleted expired upload %s location %+v", uploadID, upload.Location)
+			}
+			if ret.Marker == "" {
+				break
+			}
+			args.Marker = ret.Marker
+		}
+	}
+}
+
+func (m *multipartManager) loopCleanExpired(stopCh <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(m.config.CheckIntervalS) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				_, ctx := trace.StartSpanFromContext(context.Background(), "multipart-expired")
+				m.cleanExpired(ctx)
+			}
+		}
+	}()
+}
+
+// MultipartInit alloc location of the whole object and start a multipart upload
+func (s *Service) MultipartInit(c *rpc.Context) {
+	args := new(access.MultipartInitArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Debugf("accept /multipart/init request args:%+v", args)
+	if !args.IsValid() {
+		span.Debugf("invalid args:%+v", args)
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	// the whole object is charged, parts are put into the charged location,
+	// released if the upload is aborted or expired.
+	tenant := s.tenants.Tenant(c.Request)
+	token := newTenantToken()
+	if err := s.tenants.Acquire(ctx, tenant, token, int64(args.Size), 1); err != nil {
+		c.RespondError(err)
+		return
+	}
+	accounted := false
+	defer func() {
+		if !accounted {
+			s.tenants.Release(ctx, tenant, token, int64(args.Size), 1)
+		}
+	}()
+
+	location, err := s.streamHandler.Alloc(ctx, args.Size, args.PartSize, 0, args.CodeMode)
+	if err != nil {
+		span.Error("stream alloc failed", errors.Detail(err))
+		c.RespondError(httpError(err))
+		return
+	}
+	if err = s.tenants.Own(ctx, tenant, token, location); err != nil {
+		span.Warn("own multipart location failed", errors.Detail(err))
+		if e := s.streamHandler.Delete(ctx, location); e != nil {
+			span.Warn("delete allocated location failed", errors.Detail(e))
+		}
+		c.RespondError(httpError(err))
+		return
+	}
+	accounted = true
+
+	uploadID, expireAt, err := s.multipart.add(ctx, location)
+	if err != nil {
+		span.Warn("add multipart upload failed", err)
+		if e := s.streamHandler.Delete(ctx, location); e != nil {
+			span.Warn("delete allocated location failed", errors.Detail(e))
+		} else {
+			s.tenants.ReleaseLocation(ctx, location)
+		}
+		c.RespondError(httpError(err))
+		return
+	}
+
+	resp := access.MultipartInitResp{
+		UploadID:   uploadID,
+		Size:       location.Size,
+		PartSize:   location.BlobSize,
+		PartCount:  uint32(blobCount(location.Size, location.BlobSize)),
+		Expiration: expireAt,
+	}
+	c.RespondJSON(resp)
+	span.Infof("done /multipart/init request resp:%+v location:%+v", resp, location)
+}
+
+// MultipartPut put one part of the upload, the part can be put repeatedly
+func (s *Service) MultipartPut(c *rpc.Context) {
+	args := new(access.MultipartPutArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Debugf("accept /multipart/put request args:%+v", args)
+	if !args.IsValid() {
+		span.Debugf("invalid args:%+v", args)
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	location, blob, err := s.multipart.get(ctx, args.UploadID, args.Index)
+	if err != nil {
+		span.Info("get multipart upload", args.UploadID, err)
+		c.RespondError(err)
+		return
+	}
+	if args.Size != int64(blob.Size) {
+		span.Infof("part %d size %d not equal to blob size %d", args.Index, args.Size, blob.Size)
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	hashSumMap, hasherMap := newHasherMap(args.Hashes, nil)
+
+	rc := s.tenants.Reader(ctx, s.tenants.Tenant(c.Request), s.limiter.Reader(ctx, c.Request.Body))
+	err = s.streamHandler.PutAt(ctx, rc, location.ClusterID, blob.Vid, blob.Bid, args.Size, hasherMap)
+	if err != nil {
+		span.Error("stream multipart put failed", errors.Detail(err))
+		c.RespondError(httpError(err))
+		return
+	}
+
+	if err = s.multipart.markUploaded(ctx, args.UploadID, args.Index); err != nil {
+		// the part can be put again if failed to mark it
+		if err != errcode.ErrAccessNoSuchUpload {
+			span.Error("mark multipart part uploaded failed", errors.Detail(err))
+			c.RespondError(httpError(err))
+			return
+		}
+		// the upload was aborted or expired when putting the part,
+		// the blob of gone upload can not be put by client any more.
+		span.Warnf("upload %s had gone, delete blob %+v", args.UploadID, blob)
+		if e := s.streamHandler.Delete(ctx, &access.Location{
+			ClusterID: location.ClusterID,
+			BlobSize:  1,
+			Blobs: []access.SliceInfo{{
+				MinBid: blob.Bid,
+				Vid:    blob.Vid,
+				Count:  1,
+			}},
+		}); e != nil {
+			span.Error("delete blob of gone upload failed", errors.Detail(e))
+		}
+		c.RespondError(err)
+		return
+	}
+
+	// hasher sum
+	for alg, hasher := range hasherMap {
+		hashSumMap[alg] = hasher.Sum(nil)
+	}
+
+	c.RespondJSON(access.MultipartPutResp{HashSumMap: hashSumMap})
+	span.Infof("done /multipart/put request upload:%s index:%d hash:%+v",
+		args.UploadID, args.Index, hashSumMap.All())
+}
+
+// MultipartComplete complete the upload and returns signed location
+func (s *Service) MultipartComplete(c *rpc.Context) {
+	args := new(access.MultipartArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Debugf("accept /multipart/complete request args:%+v", args)
+	if !args.IsValid() {
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	location, err := s.multipart.complete(ctx, args.UploadID)
+	if err != nil {
+		span.Info("complete multipart upload", args.UploadID, err)
+		c.RespondError(err)
+		return
+	}
+
+	if err := fillCrc(&location); err != nil {
+		span.Error("multipart complete fill location crc", err)
+		c.RespondError(httpError(err))
+		return
+	}
+
+	c.RespondJSON(access.MultipartCompleteResp{Location: location})
+	span.Infof("done /multipart/complete request upload:%s location:%+v", args.UploadID, location)
+}
+
+// MultipartAbort abort the upload and delete all blobs
+func (s *Service) MultipartAbort(c *rpc.Context) {
+	args := new(access.MultipartArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Debugf("accept /multipart/abort request args:%+v", args)
+	if !args.IsValid() {
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	if err := s.multipart.abort(ctx, args.UploadID); err != nil {
+		span.Error("abort multipart upload", args.UploadID, errors.Detail(err))
+		c.RespondError(httpError(err))
+		return
+	}
+
+	c.Respond()
+	span.Infof("done /multipart/abort request upload:%s", args.UploadID)
+}
+
+// MultipartList list uploaded parts of the upload
+func (s *Service) MultipartList(c *rpc.Context) {
+	args := new(access.MultipartArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	if !args.IsValid() {
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	resp, err := s.multipart.list(ctx, args.UploadID)
+	if err != nil {
+		span.Info("list multipart upload", args.UploadID, err)
+		c.RespondError(err)
+		return
+	}
+
+	c.RespondJSON(resp)
+}
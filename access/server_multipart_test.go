@@ -0,0 +1,270 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/api/clustermgr"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/rpc"
+)
+
+func TestAccessServiceMultipart(t *testing.T) {
+	host := runMockService(newService())
+	cli := newClient()
+
+	putPart := func(uploadID string, index uint32, size int64) error {
+		url := fmt.Sprintf("%s/multipart/put?uploadid=%s&index=%d&size=%d&hashes=14",
+			host, uploadID, index, size)
+		req, _ := http.NewRequest(http.MethodPut, url, bytes.NewReader(make([]byte, size)))
+		resp := &access.MultipartPutResp{}
+		err := cli.DoWith(ctx, req, resp, rpc.WithCrcEncode())
+		if err == nil {
+			require.Equal(t, 3, len(resp.HashSumMap))
+		}
+		return err
+	}
+	list := func(uploadID string) (access.MultipartListResp, error) {
+		resp := access.MultipartListResp{}
+		err := cli.GetWith(ctx, fmt.Sprintf("%s/multipart/list?upload_id=%s", host, uploadID), &resp)
+		return resp, err
+	}
+
+	{
+		resp := &access.MultipartInitResp{}
+		err := cli.PostWith(ctx, host+"/multipart/init", resp, access.MultipartInitArgs{})
+		assertErrorCode(t, 400, err)
+		err = cli.PostWith(ctx, host+"/multipart/init", resp, access.MultipartInitArgs{Size: 1023})
+		assertErrorCode(t, 500, err)
+	}
+
+	size := uint64(_blobSize)*11 - 100
+	init := &access.MultipartInitResp{}
+	err := cli.PostWith(ctx, host+"/multipart/init", init, access.MultipartInitArgs{
+		Size:     size,
+		PartSize: _blobSize,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(11), init.PartCount)
+	require.Equal(t, _blobSize, init.PartSize)
+	require.Less(t, time.Now().Unix(), init.Expiration)
+
+	assertErrorCode(t, 554, putPart("not-exist-upload", 0, int64(_blobSize)))
+	assertErrorCode(t, 400, putPart(init.UploadID, 11, int64(_blobSize)))
+	assertErrorCode(t, 400, putPart(init.UploadID, 10, int64(_blobSize)))
+
+	// put in disorder and repeatedly
+	for _, idx := range []uint32{3, 1, 1, 0, 2, 4, 5, 6, 7, 8} {
+		require.NoError(t, putPart(init.UploadID, idx, int64(_blobSize)))
+	}
+	listResp, err := list(init.UploadID)
+	require.NoError(t, err)
+	require.Equal(t, uint32(11), listResp.PartCount)
+	require.Equal(t, []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8}, listResp.Uploaded)
+
+	complete := &access.MultipartCompleteResp{}
+	err = cli.PostWith(ctx, host+"/multipart/complete", complete, access.MultipartArgs{UploadID: init.UploadID})
+	assertErrorCode(t, 555, err)
+
+	require.NoError(t, putPart(init.UploadID, 9, int64(_blobSize)))
+	require.NoError(t, putPart(init.UploadID, 10, int64(_blobSize)-100))
+	err = cli.PostWith(ctx, host+"/multipart/complete", complete, access.MultipartArgs{UploadID: init.UploadID})
+	require.NoError(t, err)
+	require.Equal(t, size, complete.Location.Size)
+	require.True(t, verifyCrc(&complete.Location))
+
+	err = cli.PostWith(ctx, host+"/multipart/complete", complete, access.MultipartArgs{UploadID: init.UploadID})
+	assertErrorCode(t, 554, err)
+	_, err = list(init.UploadID)
+	assertErrorCode(t, 554, err)
+}
+
+func TestAccessServiceMultipartAbort(t *testing.T) {
+	host := runMockService(newService())
+	cli := newClient()
+
+	init := &access.MultipartInitResp{}
+	err := cli.PostWith(ctx, host+"/multipart/init", init, access.MultipartInitArgs{Size: uint64(_blobSize)})
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), init.PartCount)
+
+	err = cli.PostWith(ctx, host+"/multipart/abort", nil, access.MultipartArgs{})
+	assertErrorCode(t, 400, err)
+	err = cli.PostWith(ctx, host+"/multipart/abort", nil, access.MultipartArgs{UploadID: init.UploadID})
+	require.NoError(t, err)
+	err = cli.PostWith(ctx, host+"/multipart/abort", nil, access.MultipartArgs{UploadID: init.UploadID})
+	assertErrorCode(t, 554, err)
+
+	url := fmt.Sprintf("%s/multipart/put?uploadid=%s&index=0&size=%d", host, init.UploadID, _blobSize)
+	req, _ := http.NewRequest(http.MethodPut, url, bytes.NewReader(make([]byte, _blobSize)))
+	err = cli.DoWith(ctx, req, nil, rpc.WithCrcEncode())
+	assertErrorCode(t, 554, err)
+}
+
+func TestAccessServiceMultipartExpired(t *testing.T) {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	kv := newMemKv()
+	s.EXPECT().ClusterController().AnyTimes().Return(newMockClusters(ctr, kv))
+	mgr := newMultipartManager(MultipartConfig{MaxUploads: 2}, s, nil)
+
+	loc := location.Copy()
+	loc.Size = uint64(_blobSize) * 2
+	id1, _, err := mgr.add(ctx, &loc)
+	require.NoError(t, err)
+	id2, _, err := mgr.add(ctx, &loc)
+	require.NoError(t, err)
+	_, _, err = mgr.add(ctx, &loc)
+	require.ErrorIs(t, err, errcode.ErrAccessLimited)
+
+	// the upload is shared by another access node
+	other := newMultipartManager(MultipartConfig{}, s, nil)
+	require.NoError(t, other.markUploaded(ctx, id2, 1))
+	resp, err := mgr.list(ctx, id2)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1}, resp.Uploaded)
+
+	_, upload, err := mgr.load(ctx, id1)
+	require.NoError(t, err)
+	upload.ExpireAt = time.Now().Add(-time.Second).Unix()
+	value, _ := json.Marshal(upload)
+	require.NoError(t, kv.SetKV(ctx, &clustermgr.SetKvArgs{Kvs: []clustermgr.KeyValue{
+		{Key: multipartUploadKey(id1), Value: value},
+	}}))
+	require.NoError(t, mgr.markUploaded(ctx, id1, 0))
+	_, _, err = mgr.get(ctx, id1, 0)
+	require.Error(t, err)
+	_, _, err = mgr.get(ctx, id2, 0)
+	require.NoError(t, err)
+
+	// upload is kept if failed to delete blobs
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errAllocTimeout)
+	other.cleanExpired(ctx)
+	_, _, err = mgr.load(ctx, id1)
+	require.NoError(t, err)
+
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ interface{}, l *access.Location) error {
+			require.Equal(t, loc.Blobs, l.Blobs)
+			return nil
+		})
+	other.cleanExpired(ctx)
+	_, _, err = mgr.load(ctx, id1)
+	require.ErrorIs(t, err, errcode.ErrAccessNoSuchUpload)
+	_, err = kv.GetKV(ctx, multipartPartKey(id1, 0))
+	require.ErrorIs(t, err, errcode.ErrKvNotFound)
+	_, _, err = mgr.load(ctx, id2)
+	require.NoError(t, err)
+
+	// one more upload after the expired removed
+	_, _, err = mgr.add(ctx, &loc)
+	require.NoError(t, err)
+}
+
+// hookKv calls the hook before setting every key
+type hookKv struct {
+	*memKv
+	onSet func(key string) error
+}
+
+func (h *hookKv) SetKV(ctx context.Context, args *clustermgr.SetKvArgs) error {
+	for _, kv := range args.Kvs {
+		if h.onSet == nil {
+			break
+		}
+		if err := h.onSet(kv.Key); err != nil {
+			return err
+		}
+	}
+	return h.memKv.SetKV(ctx, args)
+}
+
+func TestAccessServiceMultipartPutMarkFailed(t *testing.T) {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	kv := &hookKv{memKv: newMemKv()}
+	s.EXPECT().ClusterController().AnyTimes().Return(newMockClusters(ctr, kv))
+	s.EXPECT().PutAt(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+		gomock.Any()).AnyTimes().Return(nil)
+	svc := &Service{
+		streamHandler: s,
+		limiter:       NewLimiter(LimitConfig{}),
+		multipart:     newMultipartManager(MultipartConfig{MaxUploads: 1}, s, nil),
+	}
+	rpc.RegisterArgsParser(&access.MultipartPutArgs{}, "json")
+	router := rpc.New()
+	router.Handle(http.MethodPut, "/multipart/put", svc.MultipartPut, rpc.OptArgsQuery())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	cli := newClient()
+
+	loc := location.Copy()
+	loc.Size = uint64(_blobSize)
+	uploadID, _, err := svc.multipart.add(ctx, &loc)
+	require.NoError(t, err)
+	putPart := func() error {
+		url := fmt.Sprintf("%s/multipart/put?uploadid=%s&index=0&size=%d", server.URL, uploadID, _blobSize)
+		req, _ := http.NewRequest(http.MethodPut, url, bytes.NewReader(make([]byte, _blobSize)))
+		return cli.DoWith(ctx, req, nil, rpc.WithCrcEncode())
+	}
+
+	// the blob is kept to be put again
+	kv.onSet = func(key string) error {
+		if strings.HasPrefix(key, multipartPartPrefix) {
+			return errcode.ErrRaftPropose
+		}
+		return nil
+	}
+	assertErrorCode(t, errcode.CodeRaftPropose, putPart())
+	kv.onSet = nil
+	require.NoError(t, putPart())
+
+	// the blob is deleted if the upload had gone
+	kv.onSet = func(key string) error {
+		return kv.DeleteKV(ctx, &clustermgr.DeleteKvArgs{Keys: []string{multipartUploadKey(uploadID)}})
+	}
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, l *access.Location) error {
+			require.Equal(t, loc.Blobs[0].MinBid, l.Blobs[0].MinBid)
+			require.Equal(t, uint32(1), l.Blobs[0].Count)
+			return nil
+		})
+	assertErrorCode(t, errcode.CodeAccessNoSuchUpload, putPart())
+	_, err = kv.GetKV(ctx, multipartPartKey(uploadID, 0))
+	require.ErrorIs(t, err, errcode.ErrKvNotFound)
+
+	// the upload is uncounted once
+	for ii := 0; ii < 2; ii++ {
+		svc.multipart.decrCount(ctx, kv, uploadID)
+	}
+	kv.onSet = nil
+	_, _, err = svc.multipart.add(ctx, &loc)
+	require.NoError(t, err)
+	_, _, err = svc.multipart.add(ctx, &loc)
+	require.ErrorIs(t, err, errcode.ErrAccessLimited)
+}
@@ -0,0 +1,302 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/cubefs/blobstore/api/access"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+const (
+	headerAcceptRanges = "Accept-Ranges"
+	headerETag         = "ETag"
+	headerIfNoneMatch  = "If-None-Match"
+	headerIfRange      = "If-Range"
+	headerRange        = "Range"
+)
+
+// httpRange specifies the byte range [start, start+length)
+type httpRange struct {
+	start, length uint64
+}
+
+func (r httpRange) contentRange(size uint64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+func (r httpRange) mimeHeader(contentType string, size uint64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		rpc.HeaderContentRange: {r.contentRange(size)},
+		rpc.HeaderContentType:  {contentType},
+	}
+}
+
+// errNoOverlap returned if none of the ranges overlap the object
+var errNoOverlap = errors.New("invalid range: failed to overlap")
+
+// parseRange parses a Range header string as per RFC 7233,
+// errNoOverlap is returned if none of the ranges overlap.
+func parseRange(s string, size uint64) ([]httpRange, error) {
+	if s == "" {
+		return nil, nil
+	}
+	const b = "bytes="
+	if !strings.HasPrefix(s, b) {
+		return nil, errors.New("invalid range")
+	}
+
+	var ranges []httpRange
+	noOverlap := false
+	for _, ra := range strings.Split(s[len(b):], ",") {
+		ra = strings.TrimSpace(ra)
+		if ra == "" {
+			continue
+		}
+		i := strings.Index(ra, "-")
+		if i < 0 {
+			return nil, errors.New("invalid range")
+		}
+		start, end := strings.TrimSpace(ra[:i]), strings.TrimSpace(ra[i+1:])
+
+		var r httpRange
+		if start == "" {
+			// suffix-length, the last n bytes
+			if end == "" || end[0] == '-' {
+				return nil, errors.New("invalid range")
+			}
+			n, err := strconv.ParseUint(end, 10, 64)
+			if err != nil {
+				return nil, errors.New("invalid range")
+			}
+			if n == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r.start = size - n
+			r.length = n
+		} else {
+			i, err := strconv.ParseUint(start, 10, 64)
+			if err != nil {
+				return nil, errors.New("invalid range")
+			}
+			if i >= size {
+				noOverlap = true
+				continue
+			}
+			r.start = i
+			if end == "" {
+				r.length = size - r.start
+			} else {
+				i, err := strconv.ParseUint(end, 10, 64)
+				if err != nil || r.start > i {
+					return nil, errors.New("invalid range")
+				}
+				if i >= size {
+					i = size - 1
+				}
+				r.length = i - r.start + 1
+			}
+		}
+		ranges = append(ranges, r)
+	}
+
+	if noOverlap && len(ranges) == 0 {
+		return nil, errNoOverlap
+	}
+	return ranges, nil
+}
+
+// locationETag returns strong etag of the location, data in a location
+// never be changed, the sha256 of encoded location identifies the data.
+func locationETag(loc *access.Location) string {
+	sum := sha256.Sum256(loc.Encode())
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// etagMatch reports whether the etag list matches the etag with weak comparison
+func etagMatch(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, e := range strings.Split(list, ",") {
+		e = strings.TrimSpace(e)
+		if e == "*" || strings.TrimPrefix(e, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeLocationString decode location from hex or base64 string
+func decodeLocationString(s string) (access.Location, error) {
+	if _, err := hex.DecodeString(s); err == nil {
+		if loc, err := access.DecodeLocationFromHex(s); err == nil {
+			return loc, nil
+		}
+	}
+	return access.DecodeLocationFromBase64(s)
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+// GetObject get object by location string in url, supports
+// http Range(multi-ranges), If-None-Match, If-Range and HEAD.
+func (s *Service) GetObject(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	locStr := strings.TrimPrefix(c.Param.ByName("location"), "/")
+	span.Debugf("accept %s /object request location:%s range:%s", c.Request.Method,
+		locStr, c.Request.Header.Get(headerRange))
+
+	loc, err := decodeLocationString(locStr)
+	if err != nil || !verifyCrc(&loc) || loc.Envelope != nil {
+		span.Debugf("invalid location:%s %v", locStr, err)
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	w := c.Writer
+	etag := locationETag(&loc)
+	w.Header().Set(headerETag, etag)
+	w.Header().Set(headerAcceptRanges, "bytes")
+
+	if inm := c.Request.Header.Get(headerIfNoneMatch); inm != "" && etagMatch(inm, etag) {
+		c.RespondStatus(http.StatusNotModified)
+		span.Info("done /object request not modified")
+		return
+	}
+
+	size := loc.Size
+	rangeHeader := c.Request.Header.Get(headerRange)
+	if ir := c.Request.Header.Get(headerIfRange); ir != "" && ir != etag {
+		rangeHeader = ""
+	}
+	if c.Request.Method == http.MethodHead || size == 0 {
+		rangeHeader = ""
+	}
+	ranges, err := parseRange(rangeHeader, size)
+	if err != nil {
+		span.Debugf("invalid range:%s %s", rangeHeader, err.Error())
+		if err == errNoOverlap {
+			w.Header().Set(rpc.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+		}
+		c.RespondError(rpc.NewError(http.StatusRequestedRangeNotSatisfiable, "InvalidRange", err))
+		return
+	}
+	// serve the whole object if the ranges is larger than it
+	rangesSize := uint64(0)
+	for _, ra := range ranges {
+		rangesSize += ra.length
+	}
+	if rangesSize > size {
+		ranges = nil
+	}
+
+	if c.Request.Method == http.MethodHead {
+		w.Header().Set(rpc.HeaderContentType, rpc.MIMEStream)
+		w.Header().Set(rpc.HeaderContentLength, strconv.FormatUint(size, 10))
+		c.RespondStatus(http.StatusOK)
+		span.Info("done HEAD /object request")
+		return
+	}
+
+	writer := s.tenants.Writer(ctx, s.tenants.Tenant(c.Request), s.limiter.Writer(ctx, w))
+	if len(ranges) <= 1 {
+		ra := httpRange{start: 0, length: size}
+		if len(ranges) == 1 {
+			ra = ranges[0]
+		}
+		transfer, err := s.streamHandler.Get(ctx, writer, loc, ra.length, ra.start)
+		if err != nil {
+			span.Error("stream get prepare failed", errors.Detail(err))
+			c.RespondError(httpError(err))
+			return
+		}
+
+		w.Header().Set(rpc.HeaderContentType, rpc.MIMEStream)
+		w.Header().Set(rpc.HeaderContentLength, strconv.FormatUint(ra.length, 10))
+		if len(ranges) == 1 {
+			w.Header().Set(rpc.HeaderContentRange, ra.contentRange(size))
+			c.RespondStatus(http.StatusPartialContent)
+		} else {
+			c.RespondStatus(http.StatusOK)
+		}
+		c.Flush()
+
+		if err = transfer(); err != nil {
+			span.Error("stream get transfer failed", errors.Detail(err))
+			return
+		}
+		span.Info("done /object request")
+		return
+	}
+
+	// multipart/byteranges
+	var contentLength countingWriter
+	counter := multipart.NewWriter(&contentLength)
+	for _, ra := range ranges {
+		counter.CreatePart(ra.mimeHeader(rpc.MIMEStream, size))
+		contentLength += countingWriter(ra.length)
+	}
+	counter.Close()
+
+	mw := multipart.NewWriter(writer)
+	mw.SetBoundary(counter.Boundary())
+	w.Header().Set(rpc.HeaderContentType, "multipart/byteranges; boundary="+mw.Boundary())
+	w.Header().Set(rpc.HeaderContentLength, strconv.FormatInt(int64(contentLength), 10))
+	c.RespondStatus(http.StatusPartialContent)
+	c.Flush()
+
+	for _, ra := range ranges {
+		part, err := mw.CreatePart(ra.mimeHeader(rpc.MIMEStream, size))
+		if err != nil {
+			span.Error("create multipart failed", err)
+			return
+		}
+		transfer, err := s.streamHandler.Get(ctx, part, loc, ra.length, ra.start)
+		if err != nil {
+			span.Error("stream get prepare failed", errors.Detail(err))
+			return
+		}
+		if err = transfer(); err != nil {
+			span.Error("stream get transfer failed", errors.Detail(err))
+			return
+		}
+	}
+	if err = mw.Close(); err != nil {
+		span.Error("close multipart failed", err)
+		return
+	}
+	span.Infof("done /object request ranges:%d", len(ranges))
+}
@@ -0,0 +1,289 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/common/rpc"
+)
+
+func TestAccessServiceObjectParseRange(t *testing.T) {
+	cases := []struct {
+		s      string
+		size   uint64
+		ranges []httpRange
+		err    bool
+	}{
+		{"", 100, nil, false},
+		{"bytes=0-9", 100, []httpRange{{0, 10}}, false},
+		{"bytes=90-", 100, []httpRange{{90, 10}}, false},
+		{"bytes=90-200", 100, []httpRange{{90, 10}}, false},
+		{"bytes=-10", 100, []httpRange{{90, 10}}, false},
+		{"bytes=-200", 100, []httpRange{{0, 100}}, false},
+		{"bytes=0-0, 10-19 ,-1", 100, []httpRange{{0, 1}, {10, 10}, {99, 1}}, false},
+		{"bytes=0-9,100-", 100, []httpRange{{0, 10}}, false},
+		{"bytes=100-", 100, nil, true},
+		{"bytes=-0", 100, nil, true},
+		{"bits=0-9", 100, nil, true},
+		{"bytes=9-0", 100, nil, true},
+		{"bytes=a-9", 100, nil, true},
+		{"bytes=0-b", 100, nil, true},
+		{"bytes=--1", 100, nil, true},
+		{"bytes=10", 100, nil, true},
+	}
+	for _, cs := range cases {
+		ranges, err := parseRange(cs.s, cs.size)
+		if cs.err {
+			require.Error(t, err, cs.s)
+			continue
+		}
+		require.NoError(t, err, cs.s)
+		require.Equal(t, cs.ranges, ranges, cs.s)
+	}
+
+	_, err := parseRange("bytes=100-", 100)
+	require.Equal(t, errNoOverlap, err)
+}
+
+func TestAccessServiceObjectETag(t *testing.T) {
+	loc := location.Copy()
+	etag := locationETag(&loc)
+	require.True(t, strings.HasPrefix(etag, `"`))
+	require.True(t, etagMatch(etag, etag))
+	require.True(t, etagMatch("*", etag))
+	require.True(t, etagMatch(`"xxx", W/`+etag, etag))
+	require.False(t, etagMatch(`"xxx"`, etag))
+
+	loc.Size++
+	require.NotEqual(t, etag, locationETag(&loc))
+
+	// same crc and size, different blobs
+	loc = location.Copy()
+	loc.Blobs[0].Vid++
+	require.NotEqual(t, etag, locationETag(&loc))
+}
+
+func newObjectServer(t *testing.T, data []byte) string {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	s.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, w io.Writer, loc access.Location, readSize, offset uint64) (func() error, error) {
+			if loc.ClusterID == 2 {
+				return nil, errors.New("fake get error")
+			}
+			return func() error {
+				_, err := w.Write(data[offset : offset+readSize])
+				return err
+			}, nil
+		})
+
+	svc := &Service{
+		streamHandler: s,
+		limiter:       NewLimiter(LimitConfig{}),
+	}
+	router := rpc.New()
+	router.Handle(http.MethodGet, "/object/*location", svc.GetObject)
+	router.Handle(http.MethodHead, "/object/*location", svc.GetObject)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestAccessServiceObjectGet(t *testing.T) {
+	data := make([]byte, 1000)
+	for idx := range data {
+		data[idx] = byte(idx)
+	}
+	host := newObjectServer(t, data)
+
+	loc := location.Copy()
+	loc.Size = uint64(len(data))
+	fillCrc(&loc)
+	etag := locationETag(&loc)
+
+	do := func(method, locStr string, headers map[string]string) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, fmt.Sprintf("%s/object/%s", host, locStr), nil)
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	// invalid location
+	for _, locStr := range []string{"", "xxx", "0a0b", loc.HexString()[2:]} {
+		resp, _ := do(http.MethodGet, locStr, nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode, locStr)
+	}
+	{
+		invalid := loc.Copy()
+		invalid.Size++
+		resp, _ := do(http.MethodGet, invalid.HexString(), nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		encrypted := loc.Copy()
+		encrypted.Envelope = &access.Envelope{KeyID: "key", WrappedKey: []byte("key"), ChunkSize: 1 << 10}
+		resp, _ = do(http.MethodGet, encrypted.HexString(), nil)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		broken := loc.Copy()
+		broken.ClusterID = 2
+		fillCrc(&broken)
+		resp, _ = do(http.MethodGet, broken.Base64String(), nil)
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	// whole object
+	for _, locStr := range []string{loc.HexString(), loc.Base64String()} {
+		resp, body := do(http.MethodGet, locStr, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, etag, resp.Header.Get("ETag"))
+		require.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+		require.Equal(t, int64(len(data)), resp.ContentLength)
+		require.Equal(t, data, body)
+	}
+	locStr := loc.HexString()
+
+	// HEAD
+	{
+		resp, body := do(http.MethodHead, locStr, map[string]string{"Range": "bytes=0-9"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, etag, resp.Header.Get("ETag"))
+		require.Equal(t, int64(len(data)), resp.ContentLength)
+		require.Equal(t, 0, len(body))
+	}
+
+	// conditional
+	for _, inm := range []string{etag, "*", `"xxx", ` + etag, "W/" + etag} {
+		resp, body := do(http.MethodGet, locStr, map[string]string{"If-None-Match": inm})
+		require.Equal(t, http.StatusNotModified, resp.StatusCode)
+		require.Equal(t, etag, resp.Header.Get("ETag"))
+		require.Equal(t, 0, len(body))
+
+		resp, _ = do(http.MethodHead, locStr, map[string]string{"If-None-Match": inm})
+		require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	}
+	{
+		resp, body := do(http.MethodGet, locStr, map[string]string{"If-None-Match": `"xxx"`})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, data, body)
+	}
+
+	// single range
+	for _, cs := range []struct {
+		r            string
+		start, end   int
+		contentRange string
+	}{
+		{"bytes=0-0", 0, 1, "bytes 0-0/1000"},
+		{"bytes=100-199", 100, 200, "bytes 100-199/1000"},
+		{"bytes=900-", 900, 1000, "bytes 900-999/1000"},
+		{"bytes=-10", 990, 1000, "bytes 990-999/1000"},
+		{"bytes=990-2000", 990, 1000, "bytes 990-999/1000"},
+	} {
+		resp, body := do(http.MethodGet, locStr, map[string]string{"Range": cs.r})
+		require.Equal(t, http.StatusPartialContent, resp.StatusCode, cs.r)
+		require.Equal(t, cs.contentRange, resp.Header.Get("Content-Range"))
+		require.Equal(t, data[cs.start:cs.end], body)
+
+		// If-Range
+		resp, body = do(http.MethodGet, locStr, map[string]string{"Range": cs.r, "If-Range": etag})
+		require.Equal(t, http.StatusPartialContent, resp.StatusCode, cs.r)
+		require.Equal(t, data[cs.start:cs.end], body)
+		resp, body = do(http.MethodGet, locStr, map[string]string{"Range": cs.r, "If-Range": `"xxx"`})
+		require.Equal(t, http.StatusOK, resp.StatusCode, cs.r)
+		require.Equal(t, data, body)
+	}
+
+	// unsatisfiable and invalid range
+	{
+		resp, _ := do(http.MethodGet, locStr, map[string]string{"Range": "bytes=1000-"})
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+		require.Equal(t, "bytes */1000", resp.Header.Get("Content-Range"))
+
+		resp, _ = do(http.MethodGet, locStr, map[string]string{"Range": "bytes=10-1"})
+		require.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	}
+
+	// ranges larger than object
+	{
+		resp, body := do(http.MethodGet, locStr, map[string]string{"Range": "bytes=0-999,0-999"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, data, body)
+	}
+
+	// multi ranges
+	{
+		resp, body := do(http.MethodGet, locStr, map[string]string{"Range": "bytes=0-9, 500-509,-10"})
+		require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		require.Equal(t, int64(len(body)), resp.ContentLength)
+
+		mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "multipart/byteranges", mediaType)
+
+		expected := []struct {
+			contentRange string
+			data         []byte
+		}{
+			{"bytes 0-9/1000", data[0:10]},
+			{"bytes 500-509/1000", data[500:510]},
+			{"bytes 990-999/1000", data[990:1000]},
+		}
+		reader := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+		for _, exp := range expected {
+			part, err := reader.NextPart()
+			require.NoError(t, err)
+			require.Equal(t, exp.contentRange, part.Header.Get("Content-Range"))
+			require.Equal(t, rpc.MIMEStream, part.Header.Get("Content-Type"))
+			b, err := ioutil.ReadAll(part)
+			require.NoError(t, err)
+			require.Equal(t, exp.data, b)
+		}
+		_, err = reader.NextPart()
+		require.Equal(t, io.EOF, err)
+	}
+
+	// empty object
+	{
+		empty := location.Copy()
+		empty.Size = 0
+		empty.Blobs = nil
+		fillCrc(&empty)
+		resp, body := do(http.MethodGet, empty.HexString(), map[string]string{"Range": "bytes=0-9"})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, 0, len(body))
+	}
+}
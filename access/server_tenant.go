@@ -0,0 +1,433 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+
+	"github.com/cubefs/blobstore/access/controller"
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/api/clustermgr"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+const (
+	// DefaultTenant tenant of requests without the tenant header
+	DefaultTenant = "default"
+
+	tenantListCount = 1000
+)
+
+// TenantQuota quota of one tenant, 0 means unlimited
+type TenantQuota struct {
+	MaxBytes   int64 `json:"max_bytes"`   // stored bytes
+	MaxObjects int64 `json:"max_objects"` // stored objects
+	ReaderMBps int   `json:"reader_mbps"` // read with MB/s
+	WriterMBps int   `json:"writer_mbps"` // write with MB/s
+}
+
+// TenantConfig per-tenant quota and bandwidth config
+// Tenant of request is the value of the header, default is X-Blobstore-Tenant,
+// requests without tenant are charged to DefaultTenant.
+// Usage is shared by all access nodes in kv of the cluster manager of ClusterID,
+// default is the minimum cluster id of the region.
+// Usage of expired objects is released by tinker of the cluster which stores
+// the objects, only if the usage is stored in the same cluster.
+type TenantConfig struct {
+	Enable        bool                   `json:"enable"`
+	Header        string                 `json:"header"`
+	DefaultTenant string                 `json:"default_tenant"`
+	ClusterID     proto.ClusterID        `json:"cluster_id"`
+	DefaultQuota  TenantQuota            `json:"default_quota"`
+	Quotas        map[string]TenantQuota `json:"quotas"`
+}
+
+type tenantRate struct {
+	reader *rate.Limiter
+	writer *rate.Limiter
+}
+
+// tenantManager accounts stored bytes and objects of tenants,
+// all methods are no-op on nil manager.
+//
+// A location is owned by the tenant who stored it, the owner is recorded
+// and charged once, deduplicated references are free of charge.
+// Usage of the owner is released only when the location is deleted.
+// Usage is changed with a unique token of each charge, so that a retried
+// or repeated change of the counters is applied only once.
+type tenantManager struct {
+	config   TenantConfig
+	clusters controller.ClusterController
+
+	lock  sync.Mutex
+	rates map[string]*tenantRate
+}
+
+func newTenantManager(cfg TenantConfig, clusters controller.ClusterController) (*tenantManager, error) {
+	if cfg.Header == "" {
+		cfg.Header = access.HeaderTenant
+	}
+	if cfg.DefaultTenant == "" {
+		cfg.DefaultTenant = DefaultTenant
+	}
+	if clusters == nil {
+		return nil, errors.New("tenant usage without clusters")
+	}
+	return &tenantManager{
+		config:   cfg,
+		clusters: clusters,
+		rates:    make(map[string]*tenantRate),
+	}, nil
+}
+
+// kvClient returns kv client of the cluster which stores the usage
+func (m *tenantManager) kvClient() (controller.KvClient, error) {
+	clusterID := m.config.ClusterID
+	if clusterID == 0 {
+		for _, cluster := range m.clusters.All() {
+			if clusterID == 0 || cluster.ClusterID < clusterID {
+				clusterID = cluster.ClusterID
+			}
+		}
+	}
+	if clusterID == 0 {
+		return nil, errcode.ErrInvalidClusterID
+	}
+	return m.clusters.GetKvClient(clusterID)
+}
+
+// Tenant returns tenant of the request
+func (m *tenantManager) Tenant(req *http.Request) string {
+	if m == nil {
+		return ""
+	}
+	if tenant := req.Header.Get(m.config.Header); tenant != "" {
+		return tenant
+	}
+	return m.config.DefaultTenant
+}
+
+func (m *tenantManager) quota(tenant string) TenantQuota {
+	if quota, ok := m.config.Quotas[tenant]; ok {
+		return quota
+	}
+	return m.config.DefaultQuota
+}
+
+// Acquire adds bytes and objects to usage of the tenant with the token,
+// returns ErrAccessQuotaExceeded if over quota.
+// A retried acquire with the same token is charged only once.
+func (m *tenantManager) Acquire(ctx context.Context, tenant, token string, bytes, objects int64) error {
+	if m == nil || tenant == "" {
+		return nil
+	}
+	span := trace.SpanFromContextSafe(ctx)
+	quota := m.quota(tenant)
+
+	kv, err := m.kvClient()
+	if err != nil {
+		return err
+	}
+	if err = incrUsage(ctx, kv, access.TenantBytesPrefix+tenant, token, bytes, quota.MaxBytes); err != nil {
+		span.Infof("tenant %s acquire bytes %d quota:%+v %s", tenant, bytes, quota, errors.Detail(err))
+		return quotaError(err)
+	}
+	if err = incrUsage(ctx, kv, access.TenantObjectsPrefix+tenant, token, objects, quota.MaxObjects); err != nil {
+		span.Infof("tenant %s acquire objects %d quota:%+v %s", tenant, objects, quota, errors.Detail(err))
+		if e := incrUsage(ctx, kv, access.TenantBytesPrefix+tenant, token, -bytes, 0); e != nil {
+			span.Warnf("tenant %s rollback bytes %d failed %s", tenant, bytes, errors.Detail(e))
+		}
+		return quotaError(err)
+	}
+	return nil
+}
+
+// Release subtracts bytes and objects acquired with the token from usage
+// of the tenant, the usage is released only once, so it is safe to retry.
+func (m *tenantManager) Release(ctx context.Context, tenant, token string, bytes, objects int64) error {
+	if m == nil || tenant == "" {
+		return nil
+	}
+	span := trace.SpanFromContextSafe(ctx)
+
+	kv, err := m.kvClient()
+	if err != nil {
+		span.Warnf("tenant %s release failed %s", tenant, errors.Detail(err))
+		return err
+	}
+	if err = incrUsage(ctx, kv, access.TenantBytesPrefix+tenant, token, -bytes, 0); err != nil {
+		span.Warnf("tenant %s release bytes %d failed %s", tenant, bytes, errors.Detail(err))
+		return err
+	}
+	if err = incrUsage(ctx, kv, access.TenantObjectsPrefix+tenant, token, -objects, 0); err != nil {
+		span.Warnf("tenant %s release objects %d failed %s", tenant, objects, errors.Detail(err))
+		return err
+	}
+	return nil
+}
+
+// Own records the tenant and the token as owner of the location which was charged
+func (m *tenantManager) Own(ctx context.Context, tenant, token string, loc *access.Location) error {
+	if m == nil || tenant == "" {
+		return nil
+	}
+	kv, err := m.kvClient()
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(access.TenantOwner{Tenant: tenant, Token: token, Bytes: int64(loc.Size)})
+	if err != nil {
+		return err
+	}
+	return kv.SetKV(ctx, &clustermgr.SetKvArgs{Kvs: []clustermgr.KeyValue{
+		{Key: tenantOwnerKey(loc), Value: value},
+	}})
+}
+
+// OwnerKey returns kv key of the owner if the location is charged to the tenant
+func (m *tenantManager) OwnerKey(tenant string, loc *access.Location) string {
+	if m == nil || tenant == "" {
+		return ""
+	}
+	return tenantOwnerKey(loc)
+}
+
+// ReleaseLocation releases the deleted location from usage of its owner,
+// nothing to release if the location has no owner.
+// The owner is removed after its usage released, so it is retried
+// by the next deletion of the location if failed.
+func (m *tenantManager) ReleaseLocation(ctx context.Context, loc *access.Location) {
+	if m == nil {
+		return
+	}
+	span := trace.SpanFromContextSafe(ctx)
+
+	kv, err := m.kvClient()
+	if err != nil {
+		span.Warn("release location failed", errors.Detail(err))
+		return
+	}
+	key := tenantOwnerKey(loc)
+	ret, err := kv.GetKV(ctx, key)
+	if err != nil {
+		if !isKvNotFound(err) {
+			span.Warnf("get owner of location %+v failed %s", loc, errors.Detail(err))
+		}
+		return
+	}
+	var owner access.TenantOwner
+	if err = json.Unmarshal(ret.Value, &owner); err != nil {
+		span.Warnf("decode owner of location %+v failed %s", loc, errors.Detail(err))
+		return
+	}
+	if err = m.Release(ctx, owner.Tenant, owner.Token, owner.Bytes, 1); err != nil {
+		return
+	}
+	if err = kv.DeleteKV(ctx, &clustermgr.DeleteKvArgs{Keys: []string{key}}); err != nil {
+		span.Warnf("delete owner of location %+v failed %s", loc, errors.Detail(err))
+	}
+}
+
+// Usage returns usage of the tenant, or all tenants if tenant is empty
+func (m *tenantManager) Usage(ctx context.Context, tenant string) ([]access.TenantUsage, error) {
+	kv, err := m.kvClient()
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]*access.TenantUsage)
+	if tenant != "" {
+		usages[tenant] = &access.TenantUsage{}
+	}
+	for _, prefix := range []string{access.TenantBytesPrefix, access.TenantObjectsPrefix} {
+		args := &clustermgr.ListKvArgs{Prefix: prefix + tenant, Count: tenantListCount}
+		for {
+			ret, err := kv.ListKV(ctx, args)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range ret.Kvs {
+				name := strings.TrimPrefix(item.Key, prefix)
+				if tenant != "" && name != tenant {
+					continue
+				}
+				usage := usages[name]
+				if usage == nil {
+					usage = &access.TenantUsage{}
+					usages[name] = usage
+				}
+				if len(item.Value) != 8 {
+					return nil, errcode.ErrIllegalArguments
+				}
+				value := int64(binary.BigEndian.Uint64(item.Value))
+				if prefix == access.TenantBytesPrefix {
+					usage.Bytes = value
+				} else {
+					usage.Objects = value
+				}
+			}
+			if ret.Marker == "" {
+				break
+			}
+			args.Marker = ret.Marker
+		}
+	}
+
+	tenants := make([]string, 0, len(usages))
+	for name := range usages {
+		tenants = append(tenants, name)
+	}
+	sort.Strings(tenants)
+
+	ret := make([]access.TenantUsage, 0, len(tenants))
+	for _, name := range tenants {
+		usage := usages[name]
+		quota := m.quota(name)
+		ret = append(ret, access.TenantUsage{
+			Tenant:     name,
+			Bytes:      usage.Bytes,
+			Objects:    usage.Objects,
+			MaxBytes:   quota.MaxBytes,
+			MaxObjects: quota.MaxObjects,
+			ReaderMBps: quota.ReaderMBps,
+			WriterMBps: quota.WriterMBps,
+		})
+	}
+	return ret, nil
+}
+
+// incrUsage the counter is limited in [0, max], max of 0 means no limit,
+// the delta is applied once with the token.
+func incrUsage(ctx context.Context, kv controller.KvClient, key, token string, delta, max int64) error {
+	if delta == 0 {
+		return nil
+	}
+	_, err := kv.IncrKV(ctx, &clustermgr.IncrKvArgs{
+		Key: key, Delta: delta, Max: max, MustExist: delta < 0, Token: token,
+	})
+	return err
+}
+
+// newTenantToken returns a unique token of the usage charged by one request
+func newTenantToken() string {
+	return uuid.New().String()
+}
+
+func quotaError(err error) error {
+	if rpc.DetectStatusCode(err) == errcode.CodeKvCounterExceedLimit {
+		return errcode.ErrAccessQuotaExceeded
+	}
+	return err
+}
+
+// tenantOwnerKey crc is excluded, cos location of multipart upload
+// is signed after completed, and ref is excluded, cos the content of
+// deduplicated location is owned once whichever holder deletes it last.
+func tenantOwnerKey(loc *access.Location) string {
+	owned := loc.Copy()
+	owned.Crc = 0
+	owned.Ref = nil
+	return access.TenantOwnerPrefix + locationHash(&owned)
+}
+
+func (m *tenantManager) rate(tenant string) *tenantRate {
+	if m == nil || tenant == "" {
+		return nil
+	}
+	quota := m.quota(tenant)
+	if quota.ReaderMBps <= 0 && quota.WriterMBps <= 0 {
+		return nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if r, ok := m.rates[tenant]; ok {
+		return r
+	}
+	mb := 1 << 20
+	r := &tenantRate{}
+	if quota.ReaderMBps > 0 {
+		r.reader = rate.NewLimiter(rate.Limit(quota.ReaderMBps*mb), 2*quota.ReaderMBps*mb)
+	}
+	if quota.WriterMBps > 0 {
+		r.writer = rate.NewLimiter(rate.Limit(quota.WriterMBps*mb), 2*quota.WriterMBps*mb)
+	}
+	m.rates[tenant] = r
+	return r
+}
+
+// Reader return io.Reader with bandwidth rate limit of the tenant
+func (m *tenantManager) Reader(ctx context.Context, tenant string, r io.Reader) io.Reader {
+	if tr := m.rate(tenant); tr != nil && tr.reader != nil {
+		return &Reader{ctx: ctx, rate: tr.reader, underlying: r}
+	}
+	return r
+}
+
+// Writer return io.Writer with bandwidth rate limit of the tenant
+func (m *tenantManager) Writer(ctx context.Context, tenant string, w io.Writer) io.Writer {
+	if tr := m.rate(tenant); tr != nil && tr.writer != nil {
+		return &Writer{ctx: ctx, rate: tr.writer, underlying: w}
+	}
+	return w
+}
+
+// TenantUsage returns usage and quota of tenants
+func (s *Service) TenantUsage(c *rpc.Context) {
+	args := new(access.TenantUsageArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	span := trace.SpanFromContextSafe(c.Request.Context())
+	span.Debugf("accept /admin/tenant/usage request args:%+v", args)
+	if s.tenants == nil {
+		c.RespondError(errcode.ErrIllegalArguments)
+		return
+	}
+
+	tenants, err := s.tenants.Usage(c.Request.Context(), args.Tenant)
+	if err != nil {
+		span.Error("get tenant usage failed", errors.Detail(err))
+		c.RespondError(httpError(err))
+		return
+	}
+	c.RespondJSON(access.TenantUsageResp{Tenants: tenants})
+}
+
+// locationsContain returns true if the location is in locations
+func locationsContain(locations []access.Location, loc *access.Location) bool {
+	for idx := range locations {
+		if locations[idx].Crc == loc.Crc && string(locations[idx].Encode()) == string(loc.Encode()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/common/codemode"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
+)
+
+func TestAccessTenantManager(t *testing.T) {
+	var nilManager *tenantManager
+	require.NoError(t, nilManager.Acquire(ctx, "a", "t", 1, 1))
+	require.NoError(t, nilManager.Release(ctx, "a", "t", 1, 1))
+	require.NoError(t, nilManager.Own(ctx, "a", "t", location))
+	nilManager.ReleaseLocation(ctx, location)
+	require.Equal(t, "", nilManager.Tenant(&http.Request{}))
+
+	_, err := newTenantManager(TenantConfig{}, nil)
+	require.Error(t, err)
+
+	ctr := gomock.NewController(t)
+	m, err := newTenantManager(TenantConfig{
+		DefaultQuota: TenantQuota{MaxBytes: 100, MaxObjects: 2},
+		Quotas: map[string]TenantQuota{
+			"big": {ReaderMBps: 1},
+		},
+	}, newMockClusters(ctr, newMemKv()))
+	require.NoError(t, err)
+
+	req := &http.Request{Header: http.Header{}}
+	require.Equal(t, DefaultTenant, m.Tenant(req))
+	req.Header.Set(access.HeaderTenant, "a")
+	require.Equal(t, "a", m.Tenant(req))
+
+	// requests without tenant are charged to the default tenant
+	require.ErrorIs(t, m.Acquire(ctx, DefaultTenant, "t0", 1000, 1), errcode.ErrAccessQuotaExceeded)
+
+	require.NoError(t, m.Acquire(ctx, "a", "t1", 60, 1))
+	// retried acquire is charged once
+	require.NoError(t, m.Acquire(ctx, "a", "t1", 60, 1))
+	require.ErrorIs(t, m.Acquire(ctx, "a", "t2", 41, 1), errcode.ErrAccessQuotaExceeded)
+	require.NoError(t, m.Acquire(ctx, "a", "t2", 40, 1))
+	require.ErrorIs(t, m.Acquire(ctx, "a", "t3", 0, 1), errcode.ErrAccessQuotaExceeded)
+	require.NoError(t, m.Acquire(ctx, "big", "t4", 1000, 1000))
+	// bytes are rolled back if objects exceeded
+	require.NoError(t, m.Acquire(ctx, "c", "t5", 10, 2))
+	require.ErrorIs(t, m.Acquire(ctx, "c", "t6", 10, 1), errcode.ErrAccessQuotaExceeded)
+
+	require.NoError(t, m.Release(ctx, "a", "t1", 60, 1))
+	// retried release and release without acquired are no-op
+	require.NoError(t, m.Release(ctx, "a", "t1", 60, 1))
+	require.NoError(t, m.Release(ctx, "b", "t1", 60, 1))
+	require.NoError(t, m.Release(ctx, "big", "t4", 1000, 1))
+	usages, err := m.Usage(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, []access.TenantUsage{
+		{Tenant: "a", Bytes: 40, Objects: 1, MaxBytes: 100, MaxObjects: 2},
+		{Tenant: "big", Bytes: 0, Objects: 999, ReaderMBps: 1},
+		{Tenant: "c", Bytes: 10, Objects: 2, MaxBytes: 100, MaxObjects: 2},
+	}, usages)
+	usages, err = m.Usage(ctx, "b")
+	require.NoError(t, err)
+	require.Equal(t, []access.TenantUsage{
+		{Tenant: "b", MaxBytes: 100, MaxObjects: 2},
+	}, usages)
+
+	r := bytes.NewReader(nil)
+	_, ok := m.Reader(ctx, "big", r).(*Reader)
+	require.True(t, ok)
+	require.Equal(t, io.Reader(r), m.Reader(ctx, "a", r))
+	w := bytes.NewBuffer(nil)
+	require.Equal(t, io.Writer(w), m.Writer(ctx, "big", w))
+}
+
+func TestAccessTenantManagerShared(t *testing.T) {
+	ctr := gomock.NewController(t)
+	kv := newMemKv()
+	cfg := TenantConfig{Header: "X-Tenant", DefaultQuota: TenantQuota{MaxBytes: 300}}
+	m1, err := newTenantManager(cfg, newMockClusters(ctr, kv))
+	require.NoError(t, err)
+	m2, err := newTenantManager(cfg, newMockClusters(ctr, kv))
+	require.NoError(t, err)
+
+	// usage is shared by access nodes
+	require.NoError(t, m1.Acquire(ctx, "a", "ta", 100, 1))
+	require.NoError(t, m2.Acquire(ctx, "b", "tb", 200, 2))
+	require.ErrorIs(t, m2.Acquire(ctx, "a", "tc", 201, 1), errcode.ErrAccessQuotaExceeded)
+	require.NoError(t, m1.Release(ctx, "b", "tb", 100, 1))
+	usages, err := m2.Usage(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, []access.TenantUsage{
+		{Tenant: "a", Bytes: 100, Objects: 1, MaxBytes: 300},
+		{Tenant: "b", Bytes: 100, Objects: 1, MaxBytes: 300},
+	}, usages)
+
+	// location is released from its owner once
+	loc := location.Copy()
+	loc.Size = 100
+	require.NoError(t, m1.Own(ctx, "a", "ta", &loc))
+	loc.Crc = 1
+	m2.ReleaseLocation(ctx, &loc)
+	m1.ReleaseLocation(ctx, &loc)
+	usages, err = m1.Usage(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, []access.TenantUsage{{Tenant: "a", MaxBytes: 300}}, usages)
+	require.Equal(t, 2, len(kv.kvs))
+}
+
+func TestAccessServiceTenant(t *testing.T) {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, rc io.Reader, size int64, hasherMap access.HasherMap) (*access.Location, error) {
+			if _, err := io.CopyN(hasherMap.ToWriter(), rc, size); err != nil {
+				return nil, err
+			}
+			if size == 1023 {
+				return nil, errcode.ErrAccessLimited
+			}
+			loc := location.Copy()
+			loc.Size = uint64(size)
+			return &loc, nil
+		})
+	s.EXPECT().Alloc(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, size uint64, blobSize uint32,
+			assignClusterID proto.ClusterID, codeMode codemode.CodeMode) (*access.Location, error) {
+			loc := location.Copy()
+			loc.Size = size
+			return &loc, nil
+		})
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, location *access.Location) error {
+			if location.Size == 512 {
+				return errcode.ErrAccessLimited
+			}
+			return nil
+		})
+
+	clusters := newMockClusters(ctr, newMemKv())
+	s.EXPECT().ClusterController().AnyTimes().Return(clusters)
+
+	tenants, err := newTenantManager(TenantConfig{
+		Quotas: map[string]TenantQuota{"a": {MaxBytes: 3000, MaxObjects: 3}},
+	}, clusters)
+	require.NoError(t, err)
+	dedup, err := NewDedupIndex(DedupConfig{}, clusters)
+	require.NoError(t, err)
+	svc := &Service{
+		streamHandler: s,
+		limiter:       NewLimiter(LimitConfig{}),
+		multipart:     newMultipartManager(MultipartConfig{}, s, tenants),
+		tenants:       tenants,
+	}
+	rpc.RegisterArgsParser(&access.PutArgs{}, "json")
+	rpc.RegisterArgsParser(&access.TenantUsageArgs{}, "json")
+	router := rpc.New()
+	router.Handle(http.MethodPut, "/put", svc.Put, rpc.OptArgsQuery())
+	router.Handle(http.MethodPost, "/alloc", svc.Alloc, rpc.OptArgsBody())
+	router.Handle(http.MethodPost, "/delete", svc.Delete, rpc.OptArgsBody())
+	router.Handle(http.MethodPost, "/multipart/init", svc.MultipartInit, rpc.OptArgsBody())
+	router.Handle(http.MethodPost, "/multipart/abort", svc.MultipartAbort, rpc.OptArgsBody())
+	router.Handle(http.MethodGet, "/admin/tenant/usage", svc.TenantUsage, rpc.OptArgsQuery())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	cli := newClient()
+
+	put := func(tenant string, size int) (access.Location, error) {
+		req, _ := http.NewRequest(http.MethodPut,
+			fmt.Sprintf("%s/put?size=%d", server.URL, size), bytes.NewReader(make([]byte, size)))
+		req.Header.Set(access.HeaderTenant, tenant)
+		resp := access.PutResp{}
+		err := cli.DoWith(ctx, req, &resp, rpc.WithCrcEncode())
+		return resp.Location, err
+	}
+	usage := func(tenant string) access.TenantUsage {
+		resp := access.TenantUsageResp{}
+		require.NoError(t, cli.GetWith(ctx, server.URL+"/admin/tenant/usage?tenant="+tenant, &resp))
+		require.Equal(t, 1, len(resp.Tenants))
+		return resp.Tenants[0]
+	}
+
+	loc1, err := put("a", 1024)
+	require.NoError(t, err)
+	// failed put is not accounted
+	_, err = put("a", 1023)
+	assertErrorCode(t, errcode.CodeAccessLimited, err)
+	require.Equal(t, access.TenantUsage{Tenant: "a", Bytes: 1024, Objects: 1, MaxBytes: 3000, MaxObjects: 3}, usage("a"))
+
+	_, err = put("a", 2000)
+	assertErrorCode(t, errcode.CodeAccessQuotaExceeded, err)
+	// not limited with default tenant or other tenant
+	_, err = put("", 2000)
+	require.NoError(t, err)
+	_, err = put("b", 2000)
+	require.NoError(t, err)
+
+	postJSON := func(path, tenant string, args, ret interface{}) error {
+		body, err := json.Marshal(args)
+		require.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, bytes.NewReader(body))
+		req.Header.Set(access.HeaderTenant, tenant)
+		req.Header.Set(rpc.HeaderContentType, rpc.MIMEJSON)
+		return cli.DoWith(ctx, req, ret)
+	}
+
+	allocResp := access.AllocResp{}
+	for ii := 0; ii < 2; ii++ {
+		require.NoError(t, postJSON("/alloc", "a", access.AllocArgs{Size: 512}, &allocResp))
+	}
+	require.Equal(t, uint64(512), allocResp.Location.Size)
+	err = postJSON("/alloc", "a", access.AllocArgs{Size: 512}, &allocResp)
+	assertErrorCode(t, errcode.CodeAccessQuotaExceeded, err)
+	require.Equal(t, access.TenantUsage{Tenant: "a", Bytes: 2048, Objects: 3, MaxBytes: 3000, MaxObjects: 3}, usage("a"))
+
+	fillCrc(&loc1)
+	deleteResp := access.DeleteResp{}
+	// released from the owner
+	require.NoError(t, postJSON("/delete", "b", access.DeleteArgs{Locations: []access.Location{loc1}}, &deleteResp))
+	require.Equal(t, 0, len(deleteResp.FailedLocations))
+	require.Equal(t, access.TenantUsage{Tenant: "a", Bytes: 1024, Objects: 2, MaxBytes: 3000, MaxObjects: 3}, usage("a"))
+	// failed location is not released
+	err = postJSON("/delete", "a", access.DeleteArgs{Locations: []access.Location{allocResp.Location}}, &deleteResp)
+	assertErrorCode(t, http.StatusIMUsed, err)
+	require.Equal(t, access.TenantUsage{Tenant: "a", Bytes: 1024, Objects: 2, MaxBytes: 3000, MaxObjects: 3}, usage("a"))
+
+	resp := access.TenantUsageResp{}
+	require.NoError(t, cli.GetWith(ctx, server.URL+"/admin/tenant/usage", &resp))
+	require.Equal(t, []access.TenantUsage{
+		{Tenant: "a", Bytes: 1024, Objects: 2, MaxBytes: 3000, MaxObjects: 3},
+		{Tenant: "b", Bytes: 2000, Objects: 1},
+		{Tenant: DefaultTenant, Bytes: 2000, Objects: 1},
+	}, resp.Tenants)
+
+	// released only if the last reference of deduplicated location is deleted
+	svc.dedup = dedup
+	loc2, err := put("b", 100)
+	require.NoError(t, err)
+	require.NotNil(t, loc2.Ref)
+	sum := sha256.Sum256(make([]byte, 100))
+	content, err := contentLocation(&loc2)
+	require.NoError(t, err)
+	var refs []access.Location
+	for ii := 0; ii < 2; ii++ {
+		holder := newDedupHolder()
+		_, _, err = dedup.Add(ctx, sum[:], *content, holder)
+		require.NoError(t, err)
+		ref, err := referenceLocation(content, holder)
+		require.NoError(t, err)
+		refs = append(refs, *ref)
+	}
+	// retried delete of the same reference
+	refs = append(refs, refs[0])
+	for _, ref := range refs {
+		require.NoError(t, postJSON("/delete", "a", access.DeleteArgs{Locations: []access.Location{ref}}, &deleteResp))
+		require.Equal(t, access.TenantUsage{Tenant: "b", Bytes: 2100, Objects: 2}, usage("b"))
+	}
+	require.NoError(t, postJSON("/delete", "a", access.DeleteArgs{Locations: []access.Location{loc2}}, &deleteResp))
+	require.Equal(t, access.TenantUsage{Tenant: "b", Bytes: 2000, Objects: 1}, usage("b"))
+	svc.dedup = nil
+
+	// multipart upload is charged, and released if aborted
+	initResp := access.MultipartInitResp{}
+	err = postJSON("/multipart/init", "a", access.MultipartInitArgs{Size: 2000}, &initResp)
+	assertErrorCode(t, errcode.CodeAccessQuotaExceeded, err)
+	require.NoError(t, postJSON("/multipart/init", "a", access.MultipartInitArgs{Size: 1000}, &initResp))
+	require.Equal(t, access.TenantUsage{Tenant: "a", Bytes: 2024, Objects: 3, MaxBytes: 3000, MaxObjects: 3}, usage("a"))
+	require.NoError(t, postJSON("/multipart/abort", "", access.MultipartArgs{UploadID: initResp.UploadID}, nil))
+	require.Equal(t, access.TenantUsage{Tenant: "a", Bytes: 1024, Objects: 2, MaxBytes: 3000, MaxObjects: 3}, usage("a"))
+
+	svc.tenants = nil
+	err = cli.GetWith(ctx, server.URL+"/admin/tenant/usage", &resp)
+	assertErrorCode(t, 400, err)
+}
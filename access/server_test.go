@@ -17,20 +17,28 @@ package access
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/cubefs/blobstore/access/controller"
 	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/common/codemode"
 	errcode "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
@@ -124,6 +132,8 @@ func newService() *Service {
 			return nil
 		})
 
+	s.EXPECT().ClusterController().AnyTimes().Return(newMockClusters(ctr, newMemKv()))
+
 	return &Service{
 		streamHandler: s,
 		limiter: NewLimiter(LimitConfig{
@@ -133,6 +143,7 @@ func newService() *Service {
 			ReaderMBps: 0,
 			WriterMBps: 0,
 		}),
+		multipart: newMultipartManager(MultipartConfig{}, s, nil),
 	}
 }
 
@@ -140,6 +151,113 @@ func newClient() rpc.Client {
 	return rpc.NewClient(&rpc.Config{})
 }
 
+// memKv key values of cluster manager in memory
+type memKv struct {
+	mu  sync.Mutex
+	kvs map[string][]byte
+}
+
+var _ controller.KvClient = (*memKv)(nil)
+
+func newMemKv() *memKv {
+	return &memKv{kvs: make(map[string][]byte)}
+}
+
+// newMockClusters returns cluster 1 with the key values
+func newMockClusters(ctr *gomock.Controller, kv controller.KvClient) *MockClusterController {
+	cc := NewMockClusterController(ctr)
+	cc.EXPECT().All().AnyTimes().Return([]*clustermgr.ClusterInfo{{ClusterID: 1}})
+	cc.EXPECT().GetKvClient(gomock.Any()).AnyTimes().Return(kv, nil)
+	return cc
+}
+
+func (m *memKv) GetKV(ctx context.Context, key string) (clustermgr.GetKvRet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.kvs[key]
+	if !ok {
+		return clustermgr.GetKvRet{}, errcode.ErrKvNotFound
+	}
+	return clustermgr.GetKvRet{Value: value}, nil
+}
+
+func (m *memKv) SetKV(ctx context.Context, args *clustermgr.SetKvArgs) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, kv := range args.Kvs {
+		m.kvs[kv.Key] = kv.Value
+	}
+	return nil
+}
+
+func (m *memKv) DeleteKV(ctx context.Context, args *clustermgr.DeleteKvArgs) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range args.Keys {
+		delete(m.kvs, key)
+	}
+	return nil
+}
+
+func (m *memKv) ListKV(ctx context.Context, args *clustermgr.ListKvArgs) (clustermgr.ListKvRet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.kvs))
+	for key := range m.kvs {
+		if strings.HasPrefix(key, args.Prefix) && key > args.Marker {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	ret := clustermgr.ListKvRet{}
+	for _, key := range keys {
+		if len(ret.Kvs) >= args.Count {
+			ret.Marker = ret.Kvs[len(ret.Kvs)-1].Key
+			break
+		}
+		ret.Kvs = append(ret.Kvs, clustermgr.KeyValue{Key: key, Value: m.kvs[key]})
+	}
+	return ret, nil
+}
+
+func (m *memKv) IncrKV(ctx context.Context, args *clustermgr.IncrKvArgs) (clustermgr.IncrKvRet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var value int64
+	data, exist := m.kvs[args.Key]
+	if exist {
+		value = int64(binary.BigEndian.Uint64(data))
+	}
+	tokenKey := clustermgr.IncrKvTokenPrefix + args.Key + "/" + args.Token
+	if args.Token != "" {
+		if _, recorded := m.kvs[tokenKey]; args.Delta == 0 || recorded == (args.Delta > 0) {
+			return clustermgr.IncrKvRet{Value: value}, nil
+		}
+	}
+	if !exist && args.MustExist {
+		return clustermgr.IncrKvRet{}, errcode.ErrKvNotFound
+	}
+	if value+args.Delta < 0 || (args.Max > 0 && value+args.Delta > args.Max) {
+		return clustermgr.IncrKvRet{Value: value}, errcode.ErrKvCounterExceedLimit
+	}
+	if args.Token != "" {
+		if args.Delta > 0 {
+			m.kvs[tokenKey] = []byte{1}
+		} else {
+			delete(m.kvs, tokenKey)
+		}
+	}
+	value += args.Delta
+	if value == 0 {
+		delete(m.kvs, args.Key)
+	} else {
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(value))
+		m.kvs[args.Key] = data
+	}
+	return clustermgr.IncrKvRet{Value: value}, nil
+}
+
 func TestAccessServiceNew(t *testing.T) {
 	runMockService(newService())
 }
@@ -286,6 +404,201 @@ func TestAccessServicePut(t *testing.T) {
 	}
 }
 
+func TestAccessServicePutChecksum(t *testing.T) {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, rc io.Reader, size int64, hasherMap access.HasherMap) (*access.Location, error) {
+			if _, err := io.CopyN(hasherMap.ToWriter(), rc, size); err != nil {
+				return nil, err
+			}
+			loc := location.Copy()
+			loc.Size = uint64(size)
+			return &loc, nil
+		})
+	s.EXPECT().PutAt(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+		gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, rc io.Reader,
+			clusterID proto.ClusterID, vid proto.Vid, bid proto.BlobID, size int64,
+			hasherMap access.HasherMap) error {
+			_, err := io.CopyN(hasherMap.ToWriter(), rc, size)
+			return err
+		})
+
+	var deleted []access.Location
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, location *access.Location) error {
+			deleted = append(deleted, *location)
+			return nil
+		})
+
+	svc := &Service{
+		streamHandler: s,
+		limiter:       NewLimiter(LimitConfig{}),
+	}
+	rpc.RegisterArgsParser(&access.PutArgs{}, "json")
+	rpc.RegisterArgsParser(&access.PutAtArgs{}, "json")
+	router := rpc.New()
+	router.Handle(http.MethodPut, "/put", svc.Put, rpc.OptArgsQuery())
+	router.Handle(http.MethodPut, "/putat", svc.PutAt, rpc.OptArgsQuery())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	host := server.URL
+	cli := newClient()
+
+	buff := make([]byte, 1024)
+	for idx := range buff {
+		buff[idx] = byte(idx)
+	}
+	sums := (access.HashAlgCRC32 | access.HashAlgMD5 | access.HashAlgSHA1).ToHashSumMap()
+	for alg := range sums {
+		hasher := alg.ToHasher()
+		hasher.Write(buff)
+		sums[alg] = hasher.Sum(nil)
+	}
+	crcHex := hex.EncodeToString(sums[access.HashAlgCRC32])
+	md5Hex := hex.EncodeToString(sums[access.HashAlgMD5])
+	sha1Hex := hex.EncodeToString(sums[access.HashAlgSHA1])
+
+	put := func(path, query string) (access.HashSumMap, error) {
+		req, _ := http.NewRequest(http.MethodPut, host+path+query, bytes.NewReader(buff))
+		resp := &access.PutResp{}
+		err := cli.DoWith(ctx, req, resp, rpc.WithCrcEncode())
+		return resp.HashSumMap, err
+	}
+
+	// the blob of putat is left to the caller to delete
+	for _, cs := range []struct {
+		path    string
+		deleted int
+	}{
+		{"/put?size=1024", 2},
+		{"/putat?clusterid=1&volumeid=1111&blobid=111&size=1024&token=8238436d05ecf2366f0b00", 0},
+	} {
+		path := cs.path
+		deleted = deleted[:0]
+
+		_, err := put(path, "&expected_md5=xxx")
+		assertErrorCode(t, 400, err)
+
+		hashSumMap, err := put(path, "&hashes=2&expected_md5="+md5Hex+"&expected_sha1="+sha1Hex)
+		require.NoError(t, err)
+		require.Equal(t, sums, hashSumMap)
+
+		_, err = put(path, "&expected_crc32="+crcHex+"&expected_md5="+md5Hex+"&expected_sha1="+sha1Hex)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(deleted))
+
+		_, err = put(path, "&expected_crc32=00000000")
+		assertErrorCode(t, 467, err)
+		_, err = put(path, "&expected_crc32="+crcHex+"&expected_sha1="+md5Hex+"0a0b0c0d")
+		assertErrorCode(t, 467, err)
+		require.Equal(t, cs.deleted, len(deleted))
+	}
+}
+
+func TestAccessServiceExpiry(t *testing.T) {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	s.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, rc io.Reader, size int64, hasherMap access.HasherMap) (*access.Location, error) {
+			if _, err := io.CopyN(hasherMap.ToWriter(), rc, size); err != nil {
+				return nil, err
+			}
+			loc := location.Copy()
+			loc.Size = uint64(size)
+			return &loc, nil
+		})
+	s.EXPECT().Alloc(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, size uint64, blobSize uint32,
+			assignClusterID proto.ClusterID, codeMode codemode.CodeMode) (*access.Location, error) {
+			loc := location.Copy()
+			loc.Size = size
+			return &loc, nil
+		})
+	var deleted []access.Location
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, location *access.Location) error {
+			deleted = append(deleted, *location)
+			return nil
+		})
+	var expiries []int64
+	s.EXPECT().SetExpiry(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, location *access.Location, expiry int64, owner string) error {
+			if location.Size == 1025 {
+				return errcode.ErrAccessLimited
+			}
+			expiries = append(expiries, expiry)
+			return nil
+		})
+
+	index, err := NewDedupIndex(DedupConfig{Enable: true}, newMockClusters(ctr, newMemKv()))
+	require.NoError(t, err)
+	svc := &Service{
+		streamHandler: s,
+		limiter:       NewLimiter(LimitConfig{}),
+		dedup:         index,
+	}
+	defer svc.Close()
+	rpc.RegisterArgsParser(&access.PutArgs{}, "json")
+	router := rpc.New()
+	router.Handle(http.MethodPut, "/put", svc.Put, rpc.OptArgsQuery())
+	router.Handle(http.MethodPost, "/alloc", svc.Alloc, rpc.OptArgsBody())
+	server := httptest.NewServer(router)
+	defer server.Close()
+	cli := newClient()
+
+	put := func(size int, query string) (access.PutResp, error) {
+		buff := make([]byte, size)
+		req, _ := http.NewRequest(http.MethodPut,
+			fmt.Sprintf("%s/put?size=%d%s", server.URL, size, query), bytes.NewReader(buff))
+		resp := access.PutResp{}
+		err := cli.DoWith(ctx, req, &resp, rpc.WithCrcEncode())
+		return resp, err
+	}
+	expiry := time.Now().Add(time.Hour).Unix()
+	expiryQuery := fmt.Sprintf("&expiry=%d", expiry)
+
+	_, err = put(1024, "&expiry=-1")
+	assertErrorCode(t, 400, err)
+	_, err = put(1024, "&expiry=1000")
+	assertErrorCode(t, 400, err)
+	require.Equal(t, 0, len(expiries))
+
+	_, err = put(1024, expiryQuery)
+	require.NoError(t, err)
+	require.Equal(t, []int64{expiry}, expiries)
+
+	// not deduplicated with expiry
+	sum := sha256.Sum256(make([]byte, 1024))
+	_, err = put(1024, "&expected_sha256="+hex.EncodeToString(sum[:])+expiryQuery)
+	require.NoError(t, err)
+	loc, err := index.Acquire(ctx, sum[:], newDedupHolder())
+	require.NoError(t, err)
+	require.Nil(t, loc)
+	require.Equal(t, 0, len(deleted))
+
+	// delete the location if failed to set expiry
+	_, err = put(1025, expiryQuery)
+	assertErrorCode(t, errcode.CodeAccessLimited, err)
+	require.Equal(t, 1, len(deleted))
+	require.Equal(t, uint64(1025), deleted[0].Size)
+
+	// alloc
+	expiries = expiries[:0]
+	deleted = deleted[:0]
+	allocResp := access.AllocResp{}
+	err = cli.PostWith(ctx, server.URL+"/alloc", &allocResp, access.AllocArgs{Size: 1024, Expiry: 1000})
+	assertErrorCode(t, 400, err)
+	err = cli.PostWith(ctx, server.URL+"/alloc", &allocResp, access.AllocArgs{Size: 1024, Expiry: expiry})
+	require.NoError(t, err)
+	require.True(t, verifyCrc(&allocResp.Location))
+	require.Equal(t, []int64{expiry}, expiries)
+	err = cli.PostWith(ctx, server.URL+"/alloc", &allocResp, access.AllocArgs{Size: 1025, Expiry: expiry})
+	assertErrorCode(t, errcode.CodeAccessLimited, err)
+	require.Equal(t, 1, len(deleted))
+}
+
 func TestAccessServiceGet(t *testing.T) {
 	host := runMockService(newService())
 	cli := newClient()
@@ -446,6 +759,45 @@ func TestAccessServiceDelete(t *testing.T) {
 	}
 }
 
+func TestAccessServiceDeletePacked(t *testing.T) {
+	ctr := gomock.NewController(t)
+	s := NewMockStreamHandler(ctr)
+	var mu sync.Mutex
+	var deleted []access.Location
+	s.EXPECT().Delete(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, location *access.Location) error {
+			mu.Lock()
+			deleted = append(deleted, location.Copy())
+			mu.Unlock()
+			return nil
+		})
+
+	svc := &Service{streamHandler: s}
+	rpc.RegisterArgsParser(&access.DeleteArgs{}, "json")
+	router := rpc.New()
+	router.Handle(http.MethodPost, "/delete", svc.Delete, rpc.OptArgsBody())
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	loc := location.Copy()
+	loc.Size = 1024
+	fillCrc(&loc)
+	packed := location.Copy()
+	packed.Size = 10
+	packed.Blobs = packed.Blobs[:1]
+	packed.Pack = &access.Pack{Offset: 10, Index: 1, Count: 2}
+	fillCrc(&packed)
+
+	args := access.DeleteArgs{Locations: []access.Location{loc, packed}}
+	resp := access.DeleteResp{}
+	require.NoError(t, newClient().PostWith(ctx, server.URL+"/delete", &resp, args))
+	require.Equal(t, 0, len(resp.FailedLocations))
+	require.Equal(t, 2, len(deleted))
+	require.Nil(t, deleted[0].Pack)
+	require.Equal(t, loc.Blobs, deleted[0].Blobs)
+	require.Equal(t, packed, deleted[1])
+}
+
 func TestAccessServiceDeleteBlob(t *testing.T) {
 	host := runMockService(newService())
 	cli := newClient()
@@ -61,10 +61,14 @@ func NewHandler(service *Service) *rpc.Router {
 	rpc.RegisterArgsParser(&access.PutArgs{}, "json")
 	rpc.RegisterArgsParser(&access.PutAtArgs{}, "json")
 	rpc.RegisterArgsParser(&access.DeleteBlobArgs{}, "json")
+	rpc.RegisterArgsParser(&access.MultipartPutArgs{}, "json")
+	rpc.RegisterArgsParser(&access.MultipartArgs{}, "json")
+	rpc.RegisterArgsParser(&access.TenantUsageArgs{}, "json")
 
 	rpc.Use(service.Limit)
 
-	// POST /put?size={size}&hashes={hashes}
+	// POST /put?size={size}&hashes={hashes}[&expected_crc32={hex}&expected_md5={hex}&expected_sha1={hex}&expected_sha256={hex}]
+	//           [&expiry={unix}]
 	// request  body:  DataStream
 	// response body:  json
 	rpc.POST("/put", service.Put, rpc.OptArgsQuery())
@@ -72,6 +76,7 @@ func NewHandler(service *Service) *rpc.Router {
 	rpc.PUT("/put", service.Put, rpc.OptArgsQuery())
 
 	// POST /putat?clusterid={clusterid}&volumeid={volumeid}&blobid={blobid}&size={size}&hashes={hashes}&token={token}
+	//             [&expected_crc32={hex}&expected_md5={hex}&expected_sha1={hex}&expected_sha256={hex}]
 	// request  body:  DataStream
 	// response body:  json
 	rpc.POST("/putat", service.PutAt, rpc.OptArgsQuery())
@@ -88,6 +93,14 @@ func NewHandler(service *Service) *rpc.Router {
 	// response body:  DataStream
 	rpc.POST("/get", service.Get, rpc.OptArgsBody())
 
+	// GET /object/{location}
+	// location is hex or base64 string of Location
+	// request  header: Range, If-None-Match, If-Range
+	// response body:   DataStream or multipart/byteranges
+	rpc.GET("/object/*location", service.GetObject)
+	// HEAD /object/{location}
+	rpc.HEAD("/object/*location", service.GetObject)
+
 	// POST /delete
 	// request  body:  json
 	// response body:  json
@@ -100,5 +113,28 @@ func NewHandler(service *Service) *rpc.Router {
 	// response body:  json
 	rpc.POST("/sign", service.Sign, rpc.OptArgsBody())
 
+	// POST /multipart/init
+	// request  body:  json
+	// response body:  json
+	rpc.POST("/multipart/init", service.MultipartInit, rpc.OptArgsBody())
+	// PUT /multipart/put?uploadid={uploadid}&index={index}&size={size}&hashes={hashes}
+	// request  body:  DataStream
+	// response body:  json
+	rpc.PUT("/multipart/put", service.MultipartPut, rpc.OptArgsQuery())
+	// POST /multipart/complete
+	// request  body:  json
+	// response body:  json
+	rpc.POST("/multipart/complete", service.MultipartComplete, rpc.OptArgsBody())
+	// POST /multipart/abort
+	// request  body:  json
+	rpc.POST("/multipart/abort", service.MultipartAbort, rpc.OptArgsBody())
+	// GET /multipart/list?upload_id={upload_id}
+	// response body:  json
+	rpc.GET("/multipart/list", service.MultipartList, rpc.OptArgsQuery())
+
+	// GET /admin/tenant/usage?tenant={tenant}
+	// response body:  json
+	rpc.GET("/admin/tenant/usage", service.TenantUsage, rpc.OptArgsQuery())
+
 	return rpc.DefaultRouter
 }
@@ -17,8 +17,10 @@ package access
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/afex/hystrix-go/hystrix"
 	"github.com/hashicorp/consul/api"
@@ -27,6 +29,7 @@ import (
 	"github.com/cubefs/blobstore/api/access"
 	"github.com/cubefs/blobstore/api/allocator"
 	"github.com/cubefs/blobstore/api/blobnode"
+	cmapi "github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/api/mqproxy"
 	"github.com/cubefs/blobstore/common/codemode"
 	"github.com/cubefs/blobstore/common/ec"
@@ -48,6 +51,9 @@ var (
 const (
 	defaultMaxObjectSize int64 = 5 * (1 << 30) // 5GB
 
+	// max blobs of one request to cluster manager
+	maxBlobExpiryBatch = 1000
+
 	// hystrix command define
 	allocCommand = "alloc"
 	rwCommand    = "rw"
@@ -101,8 +107,17 @@ type StreamHandler interface {
 	//failed
 	Get(ctx context.Context, w io.Writer, location access.Location, readSize, offset uint64) (func() error, error)
 
-	// Delete delete all blobs in this location
+	// Delete delete all blobs and expiry of the blobs in this location
 	Delete(ctx context.Context, location *access.Location) error
+
+	// SetExpiry record expiry of all blobs in this location,
+	// blobs will be deleted by the sweeper after expired.
+	//     required: expiry, unix timestamp in seconds
+	//     optional: owner, kv key of the tenant owner released after expired
+	SetExpiry(ctx context.Context, location *access.Location, expiry int64, owner string) error
+
+	// ClusterController returns controller of clusters in this region
+	ClusterController() controller.ClusterController
 }
 
 // StreamConfig access stream handler config
@@ -130,6 +145,13 @@ type StreamConfig struct {
 	AllocatorConfig allocator.Config         `json:"allocator_config"`
 	MQproxyConfig   mqproxy.Config           `json:"mqproxy_config"`
 
+	// small objects packing config
+	PackConfig PackConfig `json:"pack_config"`
+	// large objects streaming put config
+	StripeConfig StripeConfig `json:"stripe_config"`
+	// hedged shard reads config
+	HedgeConfig HedgeConfig `json:"hedge_config"`
+
 	// hystrix command config
 	AllocCommandConfig hystrix.CommandConfig `json:"alloc_command_config"`
 	RWCommandConfig    hystrix.CommandConfig `json:"rw_command_config"`
@@ -156,6 +178,9 @@ type Handler struct {
 	maxObjectSize int64
 
 	discardVidChan chan discardVid
+	packer         *packer
+	latency        *latencyTracker
+	stripeFlag     int32 // feature flag of striped put in cluster
 	stopCh         <-chan struct{}
 
 	StreamConfig
@@ -190,6 +215,15 @@ func confCheck(cfg *StreamConfig) {
 	}
 	cfg.EncoderConcurrency = defaultInt(cfg.EncoderConcurrency, defaultEncoderConcurrency)
 	cfg.MinReadShardsX = defaultInt(cfg.MinReadShardsX, defaultMinReadShardsX)
+	if cfg.PackConfig.Enable {
+		packConfCheck(&cfg.PackConfig, cfg.MaxBlobSize)
+	}
+	if cfg.StripeConfig.Enable {
+		stripeConfCheck(&cfg.StripeConfig)
+	}
+	if cfg.HedgeConfig.Enable {
+		hedgeConfCheck(&cfg.HedgeConfig)
+	}
 
 	cfg.ClusterConfig.CMClientConfig.Config.ClientTimeoutMs = defaultInt64(cfg.ClusterConfig.CMClientConfig.Config.ClientTimeoutMs, defaultTimeoutClusterMgr)
 	cfg.AllocatorConfig.ClientTimeoutMs = defaultInt64(cfg.AllocatorConfig.ClientTimeoutMs, defaultTimeoutAllocator)
@@ -234,6 +268,12 @@ func NewStreamHandler(cfg *StreamConfig, kvClient *api.Client, stopCh <-chan str
 		StreamConfig:  *cfg,
 	}
 
+	// runtime code modes must be registered before policies be used
+	if err = cmapi.SyncCodeModes(context.Background(), handler.clusterController); err != nil {
+		log.Fatal("sync code modes from cluster manager failed, err: ", err)
+	}
+	go cmapi.LoopSyncCodeModes(handler.clusterController, cmapi.CodeModeSyncInterval, stopCh)
+
 	rawCodeModePolicies, err := handler.clusterController.GetConfig(context.Background(), proto.CodeModeConfigKey)
 	if err != nil {
 		log.Fatal("get codemode policy from cluster manager failed, err: ", err)
@@ -282,16 +322,120 @@ func NewStreamHandler(cfg *StreamConfig, kvClient *api.Client, stopCh <-chan str
 	handler.discardVidChan = make(chan discardVid, 8)
 	handler.stopCh = stopCh
 	handler.loopDiscardVids()
+	if cfg.StripeConfig.Enable {
+		handler.loopStripeFlag()
+	}
+	if cfg.PackConfig.Enable {
+		handler.packer = newPacker(handler)
+	}
+	if cfg.HedgeConfig.Enable {
+		handler.latency = newLatencyTracker(cfg.HedgeConfig.WindowSize)
+	}
 	return handler
 }
 
-// Delete delete all blobs in this location
+// Delete delete all blobs in this location, the expiry of blobs is
+// deleted firstly, or the sweeper may delete the blobs once more.
 func (h *Handler) Delete(ctx context.Context, location *access.Location) error {
 	span := trace.SpanFromContextSafe(ctx)
 	span.Debugf("to delete %+v", location)
+
+	blobs := blobExpiryArgs(location, 0).Blobs
+	for len(blobs) > 0 {
+		n := len(blobs)
+		if n > maxBlobExpiryBatch {
+			n = maxBlobExpiryBatch
+		}
+		args := &cmapi.BlobExpiryArgs{Blobs: blobs[:n]}
+		if err := retry.Timed(3, 200).On(func() error {
+			err := h.clusterController.DeleteBlobExpiry(ctx, location.ClusterID, args)
+			if err != nil {
+				span.Warn("delete blob expiry", errors.Detail(err))
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+		blobs = blobs[n:]
+	}
 	return h.clearGarbage(ctx, location)
 }
 
+// lazyEncoders encoders of code modes not in policies
+var lazyEncoders sync.Map
+
+// getEncoder returns encoder of the code mode, encoders of code modes
+// not in policies are built lazily, such as new mode of transcoded volume.
+func (h *Handler) getEncoder(codeMode codemode.CodeMode) (ec.Encoder, error) {
+	if encoder, ok := h.encoder[codeMode]; ok {
+		return encoder, nil
+	}
+	if encoder, ok := lazyEncoders.Load(codeMode); ok {
+		return encoder.(ec.Encoder), nil
+	}
+	if !codeMode.IsValid() {
+		return nil, fmt.Errorf("invalid codemode %d", codeMode)
+	}
+	encoder, err := ec.NewEncoder(&ec.Config{
+		CodeMode:     codeMode.Tactic(),
+		EnableVerify: h.EncoderEnableVerify,
+		Concurrency:  h.EncoderConcurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := lazyEncoders.LoadOrStore(codeMode, encoder)
+	return actual.(ec.Encoder), nil
+}
+
+// ClusterController returns controller of clusters in this region
+func (h *Handler) ClusterController() controller.ClusterController {
+	return h.clusterController
+}
+
+// SetExpiry record expiry of all blobs in this location
+func (h *Handler) SetExpiry(ctx context.Context, location *access.Location, expiry int64, owner string) error {
+	span := trace.SpanFromContextSafe(ctx)
+	span.Debugf("to set expiry %d owner %s of %+v", expiry, owner, location)
+
+	args := blobExpiryArgs(location, expiry)
+	for idx := range args.Blobs {
+		args.Blobs[idx].Owner = owner
+	}
+	if len(args.Blobs) == 0 {
+		return nil
+	}
+
+	return retry.Timed(3, 200).On(func() error {
+		err := h.clusterController.SetBlobExpiry(ctx, location.ClusterID, args)
+		if err != nil {
+			span.Warn("set blob expiry", errors.Detail(err))
+		}
+		return err
+	})
+}
+
+// blobExpiryArgs returns expiry of all blobs in this location
+func blobExpiryArgs(location *access.Location, expiry int64) *cmapi.BlobExpiryArgs {
+	args := &cmapi.BlobExpiryArgs{Blobs: make([]cmapi.BlobExpiry, 0, len(location.Blobs))}
+	for _, blob := range location.Blobs {
+		if blob.Count == 0 {
+			continue
+		}
+		blobExpiry := cmapi.BlobExpiry{
+			Vid:      blob.Vid,
+			MinBid:   blob.MinBid,
+			Count:    blob.Count,
+			ExpireAt: expiry,
+		}
+		if location.Pack != nil {
+			blobExpiry.Packed = &proto.PackedBlob{Index: location.Pack.Index, Count: location.Pack.Count}
+		}
+		args.Blobs = append(args.Blobs, blobExpiry)
+	}
+	return args
+}
+
 func (h *Handler) sendRepairMsgBg(ctx context.Context,
 	clusterID proto.ClusterID, vid proto.Vid, bid proto.BlobID, badIdx []uint8) {
 	go func() {
@@ -356,10 +500,16 @@ func (h *Handler) clearGarbage(ctx context.Context, location *access.Location) e
 		Blobs:     make([]mqproxy.BlobDelete, 0, len(blobs)),
 	}
 
+	// packed object marks a tombstone on the shared blob
+	var packed *proto.PackedBlob
+	if location.Pack != nil {
+		packed = &proto.PackedBlob{Index: location.Pack.Index, Count: location.Pack.Count}
+	}
 	for _, blob := range blobs {
 		deleteArgs.Blobs = append(deleteArgs.Blobs, mqproxy.BlobDelete{
-			Bid: blob.Bid,
-			Vid: blob.Vid,
+			Bid:    blob.Bid,
+			Vid:    blob.Vid,
+			Packed: packed,
 		})
 	}
 
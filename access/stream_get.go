@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"sort"
 	"sync"
@@ -28,6 +29,7 @@ import (
 	"github.com/cubefs/blobstore/access/controller"
 	"github.com/cubefs/blobstore/api/access"
 	"github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/common/codemode"
 	"github.com/cubefs/blobstore/common/ec"
 	errcode "github.com/cubefs/blobstore/common/errors"
@@ -44,11 +46,33 @@ var (
 )
 
 type blobGetArgs struct {
-	Vid      proto.Vid
-	Bid      proto.BlobID
-	BlobSize uint64
-	Offset   uint64
-	ReadSize uint64
+	Vid        proto.Vid
+	Bid        proto.BlobID
+	BlobSize   uint64
+	Offset     uint64
+	ReadSize   uint64
+	StripeUnit int // stripe layout if > 0
+	// Ranges of data to read in normal layout instead of Offset if not empty,
+	// a redirected blob of stripe layout is read once with ranges.
+	Ranges []dataRange
+}
+
+type dataRange struct {
+	Offset int
+	Size   int
+}
+
+// segments returns segments of the data to read in data shards
+func (b *blobGetArgs) segments(sizes ec.BufferSizes, tactic codemode.Tactic) []ec.Segment {
+	if len(b.Ranges) == 0 {
+		from := int(b.Offset)
+		return ec.StripeSegments(sizes, tactic, b.StripeUnit, from, from+int(b.ReadSize))
+	}
+	segments := make([]ec.Segment, 0, len(b.Ranges))
+	for _, r := range b.Ranges {
+		segments = append(segments, ec.StripeSegments(sizes, tactic, 0, r.Offset, r.Offset+r.Size)...)
+	}
+	return segments
 }
 
 type shardData struct {
@@ -65,9 +89,10 @@ type sortedVuid struct {
 }
 
 type pipeBuffer struct {
-	err    error
-	blob   blobGetArgs
-	shards [][]byte
+	err      error
+	blob     blobGetArgs
+	shards   [][]byte
+	segments []ec.Segment
 }
 
 // Get read file
@@ -106,6 +131,11 @@ func (h *Handler) Get(ctx context.Context, w io.Writer, location access.Location
 	}
 
 	clusterID := location.ClusterID
+	if blobs, err = h.redirectBlobs(ctx, clusterID, blobs); err != nil {
+		span.Error("redirect blobs", errors.Detail(err))
+		return func() error { return nil }, err
+	}
+
 	var serviceController controller.ServiceController
 	if err = retry.Timed(3, 200).On(func() error {
 		sc, err := h.clusterController.GetServiceController(clusterID)
@@ -206,10 +236,11 @@ func (h *Handler) Get(ctx context.Context, w io.Writer, location access.Location
 						return
 					}
 
+					segments := blob.segments(sizes, tactic)
 					select {
 					case <-closeCh:
 						return
-					case ch <- pipeBuffer{blob: blob, shards: shards}:
+					case ch <- pipeBuffer{blob: blob, shards: shards, segments: segments}:
 					}
 				}
 			}()
@@ -225,26 +256,12 @@ func (h *Handler) Get(ctx context.Context, w io.Writer, location access.Location
 
 			startWrite := time.Now()
 
-			idx := 0
-			off := line.blob.Offset
-			toReadSize := line.blob.ReadSize
-			for toReadSize > 0 {
-				buf := line.shards[idx]
-				l := uint64(len(buf))
-				if off >= l {
-					idx++
-					off -= l
-					continue
-				}
-
-				toRead := minU64(toReadSize, l-off)
-				if _, e := w.Write(buf[off : off+toRead]); e != nil {
+			for _, seg := range line.segments {
+				buf := line.shards[seg.Index]
+				if _, e := w.Write(buf[seg.Offset : seg.Offset+seg.Size]); e != nil {
 					err = errors.Info(e, "write to response")
 					break
 				}
-				idx++
-				off = 0
-				toReadSize -= toRead
 			}
 
 			getTime.AddGetWrite(startWrite)
@@ -272,6 +289,10 @@ func (h *Handler) readOneBlob(ctx context.Context, getTime *times,
 	blob blobGetArgs, sortedVuids []sortedVuid, shards [][]byte) error {
 	span := trace.SpanFromContextSafe(ctx)
 
+	encoder, err := h.getEncoder(codeMode)
+	if err != nil {
+		return err
+	}
 	tactic := codeMode.Tactic()
 	sizes, err := ec.GetBufferSizes(int(blob.BlobSize), tactic)
 	if err != nil {
@@ -288,6 +309,24 @@ func (h *Handler) readOneBlob(ctx context.Context, getTime *times,
 
 	stopChan := make(chan struct{})
 	nextChan := make(chan struct{}, len(sortedVuids))
+	// index of shard which is slower than hedge delay
+	hedgeChan := make(chan int, len(sortedVuids))
+
+	readShard := func(vuid sortedVuid) shardData {
+		stopHedge := h.startHedgeTimer(vuid, hedgeChan)
+		shard := h.readOneShard(ctx, serviceController, clusterID, vid,
+			shardSize, blob, vuid, stopChan)
+		stopHedge()
+		return shard
+	}
+
+	// num of shards can be read after the first N+X shards
+	nextRemain := 0
+	for _, vuid := range sortedVuids[minShardsRead:] {
+		if _, ok := empties[vuid.index]; !ok {
+			nextRemain++
+		}
+	}
 
 	shardPipe := func() <-chan shardData {
 		ch := make(chan shardData)
@@ -302,8 +341,7 @@ func (h *Handler) readOneBlob(ctx context.Context, getTime *times,
 				if _, ok := empties[vuid.index]; !ok {
 					wg.Add(1)
 					go func(vuid sortedVuid) {
-						ch <- h.readOneShard(ctx, serviceController, clusterID, vid,
-							shardSize, blob, vuid, stopChan)
+						ch <- readShard(vuid)
 						wg.Done()
 					}(vuid)
 				}
@@ -322,8 +360,7 @@ func (h *Handler) readOneBlob(ctx context.Context, getTime *times,
 
 				wg.Add(1)
 				go func(vuid sortedVuid) {
-					ch <- h.readOneShard(ctx, serviceController, clusterID, vid,
-						shardSize, blob, vuid, stopChan)
+					ch <- readShard(vuid)
 					wg.Done()
 				}(vuid)
 			}
@@ -341,7 +378,30 @@ func (h *Handler) readOneBlob(ctx context.Context, getTime *times,
 	startRead := time.Now()
 	getTime.AddGetN(int(blob.ReadSize))
 	reconstructed := false
-	for shard := range shardPipe {
+	hedged := make(map[int]struct{})
+	for {
+		var (
+			shard shardData
+			ok    bool
+		)
+		select {
+		case idx := <-hedgeChan:
+			// fire an extra shard read for the slow shard
+			if _, ok = received[idx]; ok || len(hedged) >= h.HedgeConfig.MaxHedges || nextRemain <= 0 {
+				continue
+			}
+			hedged[idx] = struct{}{}
+			nextRemain--
+			nextChan <- struct{}{}
+			reportHedge(clusterID, hedgeStatusFired)
+			span.Debugf("bid(%d) hedged read for slow shard ecidx(%d)", blob.Bid, idx)
+			continue
+		case shard, ok = <-shardPipe:
+		}
+		if !ok {
+			break
+		}
+
 		// swap shard buffer
 		if shard.status {
 			buf := shards[shard.index]
@@ -390,7 +450,7 @@ func (h *Handler) readOneBlob(ctx context.Context, getTime *times,
 		// has bad shards, but have enough shards to reconstruct
 		if len(received) >= dataN+badShards {
 			span.Debugf("bid(%d) ready to ec reconstruct data", blob.Bid)
-			err := h.encoder[codeMode].ReconstructData(shards, badIdx)
+			err := encoder.ReconstructData(shards, badIdx)
 			if err == nil {
 				reconstructed = true
 				close(stopChan)
@@ -403,10 +463,21 @@ func (h *Handler) readOneBlob(ctx context.Context, getTime *times,
 			close(stopChan)
 			break
 		}
+		nextRemain--
 		nextChan <- struct{}{}
 	}
 	getTime.AddGetRead(startRead)
 
+	// hedging won if read done without the slow shard
+	if reconstructed {
+		for idx := range hedged {
+			if _, ok := received[idx]; !ok {
+				reportHedge(clusterID, hedgeStatusWon)
+				break
+			}
+		}
+	}
+
 	// release buffer of delayed shards
 	go func() {
 		for shard := range shardPipe {
@@ -445,6 +516,7 @@ func (h *Handler) readOneShard(ctx context.Context, serviceController controller
 		err  error
 		body io.ReadCloser
 	)
+	startRead := time.Now()
 	if err = hystrix.Do(rwCommand, func() error {
 		body, err = h.getOneShardFromHost(ctx, serviceController, vuid.host, vuid.diskID, args,
 			vuid.index, clusterID, vid, stopChan)
@@ -476,6 +548,7 @@ func (h *Handler) readOneShard(ctx context.Context, serviceController controller
 			vuid.vuid, vuid.diskID, vuid.host, vuid.index, err.Error())
 		return shardResult
 	}
+	h.recordLatency(vuid, time.Since(startRead))
 
 	shardResult.status = true
 	shardResult.buffer = buf
@@ -503,59 +576,68 @@ func (h *Handler) getDataShardOnly(ctx context.Context, getTime *times,
 	}
 	defer buffer.Release()
 
-	shardSize := buffer.ShardSize
-	firstShardIdx := int(blob.Offset) / shardSize
-	shardOffset := int(blob.Offset) % shardSize
+	segments := blob.segments(buffer.BufferSizes, tactic)
+	if len(segments) == 0 {
+		return fmt.Errorf("no enough data to read %d", blob.ReadSize)
+	}
+
+	// read all segments in one shard with one ranged request,
+	// there is only one segment per shard in normal layout.
+	bufOffsets := make([]int, len(segments))
+	shardSegments := make(map[int][]int, tactic.N)
+	shardIndexes := make([]int, 0, tactic.N)
+	bufOffset := 0
+	for idx, seg := range segments {
+		bufOffsets[idx] = bufOffset
+		bufOffset += seg.Size
+		if _, ok := shardSegments[seg.Index]; !ok {
+			shardIndexes = append(shardIndexes, seg.Index)
+		}
+		shardSegments[seg.Index] = append(shardSegments[seg.Index], idx)
+	}
+	// segments of redirected ranges may be out of order in one shard
+	for _, segIdxes := range shardSegments {
+		sort.Slice(segIdxes, func(i, j int) bool {
+			return segments[segIdxes[i]].Offset < segments[segIdxes[j]].Offset
+		})
+	}
 
 	startRead := time.Now()
 	getTime.AddGetN(int(blob.ReadSize))
 
-	remainSize := blob.ReadSize
-	bufOffset := 0
-	for i, shard := range blobVolume.Units[firstShardIdx:tactic.N] {
-		if remainSize <= 0 {
-			break
-		}
+	for _, index := range shardIndexes {
+		segIdxes := shardSegments[index]
+		first, last := segments[segIdxes[0]], segments[segIdxes[len(segIdxes)-1]]
 
-		toReadSize := minU64(remainSize, uint64(shardSize-shardOffset))
+		shard := blobVolume.Units[index]
 		args := blobnode.RangeGetShardArgs{
 			GetShardArgs: blobnode.GetShardArgs{
 				DiskID: shard.DiskID,
 				Vuid:   shard.Vuid,
 				Bid:    blob.Bid,
 			},
-			Offset: int64(shardOffset),
-			Size:   int64(toReadSize),
+			Offset: int64(first.Offset),
+			Size:   int64(last.Offset + last.Size - first.Offset),
 		}
 
 		body, err := h.getOneShardFromHost(ctx, serviceController, shard.Host, shard.DiskID, args,
-			firstShardIdx+i, clusterID, blob.Vid, nil)
+			index, clusterID, blob.Vid, nil)
 		if err != nil {
 			span.Warnf("read blob(%d %d %d) on blobnode(%d %d %s) ecidx(%d): %s",
 				clusterID, blob.Vid, blob.Bid,
-				shard.Vuid, shard.DiskID, shard.Host, firstShardIdx+i, errors.Detail(err))
+				shard.Vuid, shard.DiskID, shard.Host, index, errors.Detail(err))
 			return errNeedReconstructRead
 		}
-		defer body.Close()
 
-		buf := buffer.DataBuf[bufOffset : bufOffset+int(toReadSize)]
-		_, err = io.ReadFull(body, buf)
+		err = readSegments(body, first.Offset, segments, segIdxes, bufOffsets, buffer.DataBuf)
+		body.Close()
 		if err != nil {
 			span.Warn(err)
 			return errNeedReconstructRead
 		}
-
-		// reset next shard offset
-		shardOffset = 0
-		remainSize -= toReadSize
-		bufOffset += int(toReadSize)
 	}
 	getTime.AddGetRead(startRead)
 
-	if remainSize > 0 {
-		return fmt.Errorf("no enough data to read %d", remainSize)
-	}
-
 	startWrite := time.Now()
 	if _, err := w.Write(buffer.DataBuf[:int(blob.ReadSize)]); err != nil {
 		getTime.AddGetWrite(startWrite)
@@ -566,6 +648,24 @@ func (h *Handler) getDataShardOnly(ctx context.Context, getTime *times,
 	return nil
 }
 
+// readSegments reads segments of one shard from body which starts at offset of the shard
+func readSegments(body io.Reader, offset int, segments []ec.Segment, segIdxes []int,
+	bufOffsets []int, buf []byte) error {
+	for _, idx := range segIdxes {
+		seg := segments[idx]
+		if gap := seg.Offset - offset; gap > 0 {
+			if _, err := io.CopyN(ioutil.Discard, body, int64(gap)); err != nil {
+				return err
+			}
+		}
+		if _, err := io.ReadFull(body, buf[bufOffsets[idx]:bufOffsets[idx]+seg.Size]); err != nil {
+			return err
+		}
+		offset = seg.Offset + seg.Size
+	}
+	return nil
+}
+
 // getOneShardFromHost get body of one shard
 func (h *Handler) getOneShardFromHost(ctx context.Context, serviceController controller.ServiceController,
 	host string, diskID proto.DiskID, args blobnode.RangeGetShardArgs, // get shard param with host diskid
@@ -658,6 +758,31 @@ func genLocationBlobs(location *access.Location, readSize uint64, offset uint64)
 		return nil, fmt.Errorf("FileSize:%d ReadSize:%d Offset:%d", location.Size, readSize, offset)
 	}
 
+	stripeUnit := 0
+	if location.Stripe.IsValid() {
+		stripeUnit = int(location.Stripe.Unit)
+	}
+
+	// packed object, read the range in the shared blob
+	if pack := location.Pack; pack != nil {
+		if !pack.IsValid() || len(location.Blobs) != 1 || location.Blobs[0].Count != 1 ||
+			pack.Offset+location.Size > uint64(location.BlobSize) {
+			return nil, fmt.Errorf("Pack:%+v Blobs:%+v BlobSize:%d", *pack, location.Blobs, location.BlobSize)
+		}
+		if readSize == 0 {
+			return nil, nil
+		}
+		blob := location.Blobs[0]
+		return []blobGetArgs{{
+			Vid:        blob.Vid,
+			Bid:        blob.MinBid,
+			BlobSize:   uint64(location.BlobSize),
+			Offset:     pack.Offset + offset,
+			ReadSize:   readSize,
+			StripeUnit: stripeUnit,
+		}}, nil
+	}
+
 	blobSize := uint64(location.BlobSize)
 	if blobSize <= 0 {
 		return nil, fmt.Errorf("BlobSize:%d", blobSize)
@@ -681,11 +806,12 @@ func genLocationBlobs(location *access.Location, readSize uint64, offset uint64)
 				toReadSize := minU64(remainSize, blobSize-blobOffset)
 				if toReadSize > 0 {
 					blobs = append(blobs, blobGetArgs{
-						Vid:      blob.Vid,
-						Bid:      currBlobID,
-						BlobSize: minU64(location.Size-idx*blobSize, blobSize), // update the last blob size
-						Offset:   blobOffset,
-						ReadSize: toReadSize,
+						Vid:        blob.Vid,
+						Bid:        currBlobID,
+						BlobSize:   minU64(location.Size-idx*blobSize, blobSize), // update the last blob size
+						Offset:     blobOffset,
+						ReadSize:   toReadSize,
+						StripeUnit: stripeUnit,
 					})
 				}
 
@@ -706,6 +832,73 @@ func genLocationBlobs(location *access.Location, readSize uint64, offset uint64)
 	return blobs, nil
 }
 
+// redirectBlobs replaces blobs of transcoded volumes with blobs in the new volumes,
+// data of the new blob is the data shards of source blob, so that
+// the range in normal layout is the same, and is cut into ranges in stripe layout.
+func (h *Handler) redirectBlobs(ctx context.Context, clusterID proto.ClusterID,
+	blobs []blobGetArgs) ([]blobGetArgs, error) {
+	volumes := make(map[proto.Vid]*controller.VolumePhy)
+	for idx, blob := range blobs {
+		volume, ok := volumes[blob.Vid]
+		if !ok {
+			var err error
+			if volume, err = h.getVolume(ctx, clusterID, blob.Vid, true); err != nil {
+				return nil, err
+			}
+			volumes[blob.Vid] = volume
+		}
+		if volume.Redirect == nil {
+			continue
+		}
+
+		newBlob, err := redirectBlob(blob, volume.Redirect)
+		if err != nil {
+			return nil, err
+		}
+		blobs[idx] = newBlob
+	}
+	return blobs, nil
+}
+
+// redirectBlob returns the new blob which is read once,
+// segments of stripe layout are remapped into data ranges of the new blob.
+func redirectBlob(blob blobGetArgs, redirect *clustermgr.VolumeRedirect) (blobGetArgs, error) {
+	tactic := redirect.CodeMode.Tactic()
+	sizes, err := ec.GetBufferSizes(int(blob.BlobSize), tactic)
+	if err != nil {
+		return blobGetArgs{}, err
+	}
+
+	newBlob := blobGetArgs{
+		Vid:      redirect.NewVid,
+		Bid:      blob.Bid,
+		BlobSize: uint64(sizes.ECDataSize),
+		Offset:   blob.Offset,
+		ReadSize: blob.ReadSize,
+	}
+	if blob.StripeUnit <= 0 || blob.ReadSize == 0 {
+		return newBlob, nil
+	}
+
+	from := int(blob.Offset)
+	segments := ec.StripeSegments(sizes, tactic, blob.StripeUnit, from, from+int(blob.ReadSize))
+	if len(segments) == 0 {
+		return blobGetArgs{}, fmt.Errorf("no enough data to read %d", blob.ReadSize)
+	}
+	ranges := make([]dataRange, 0, len(segments))
+	for _, seg := range segments {
+		offset := seg.Index*sizes.ShardSize + seg.Offset
+		if n := len(ranges); n > 0 && ranges[n-1].Offset+ranges[n-1].Size == offset {
+			ranges[n-1].Size += seg.Size
+			continue
+		}
+		ranges = append(ranges, dataRange{Offset: offset, Size: seg.Size})
+	}
+	newBlob.Offset = 0
+	newBlob.Ranges = ranges
+	return newBlob, nil
+}
+
 func genSortedVuidByIDC(ctx context.Context, serviceController controller.ServiceController, idc string,
 	vuidPhys []controller.Unit) []sortedVuid {
 	span := trace.SpanFromContextSafe(ctx)
@@ -17,6 +17,7 @@ package access
 import (
 	"bytes"
 	"crypto/rand"
+	"io"
 	mrand "math/rand"
 	"testing"
 	"time"
@@ -24,7 +25,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/ec"
 	"github.com/cubefs/blobstore/common/proto"
 )
 
@@ -563,3 +566,94 @@ func BenchmarkAccessStreamGet(b *testing.B) {
 		})
 	}
 }
+
+func TestAccessStreamGetRedirectBlob(t *testing.T) {
+	redirect := &clustermgr.VolumeRedirect{
+		Vid:         1,
+		CodeMode:    codemode.EC6P6,
+		NewVid:      2,
+		NewCodeMode: codemode.EC3P3,
+	}
+	tactic := redirect.CodeMode.Tactic()
+	newTactic := redirect.NewCodeMode.Tactic()
+	encoder, err := ec.NewEncoder(&ec.Config{CodeMode: tactic, EnableVerify: true})
+	require.NoError(t, err)
+
+	for _, unit := range []int{0, 1 << 10, 3000} {
+		size := 1<<16 + mrand.Intn(1<<16)
+		data := make([]byte, size)
+		rand.Read(data)
+
+		// transcoded data is the concatenation of source data shards
+		sizes, err := ec.GetBufferSizes(size, tactic)
+		require.NoError(t, err)
+		writers := make([]io.Writer, tactic.N+tactic.M+tactic.L)
+		shards := make([]*bytes.Buffer, len(writers))
+		for idx := range writers {
+			shards[idx] = bytes.NewBuffer(nil)
+			writers[idx] = shards[idx]
+		}
+		if unit > 0 {
+			stripe, err := ec.NewStripeEncoder(encoder, tactic, unit, nil)
+			require.NoError(t, err)
+			require.NoError(t, stripe.Encode(bytes.NewReader(data), size, writers))
+		} else {
+			buf := make([]byte, sizes.ECSize)
+			copy(buf, data)
+			splits, err := encoder.Split(buf)
+			require.NoError(t, err)
+			require.NoError(t, encoder.Encode(splits))
+			for idx := range splits {
+				shards[idx].Write(splits[idx])
+			}
+		}
+		transcoded := make([]byte, 0, sizes.ECDataSize)
+		for idx := 0; idx < tactic.N; idx++ {
+			transcoded = append(transcoded, shards[idx].Bytes()...)
+		}
+
+		for ii := 0; ii < 20; ii++ {
+			offset := mrand.Intn(size)
+			readSize := mrand.Intn(size - offset)
+			blob := blobGetArgs{
+				Vid: 1, Bid: 10, BlobSize: uint64(size),
+				Offset: uint64(offset), ReadSize: uint64(readSize), StripeUnit: unit,
+			}
+			b, err := redirectBlob(blob, redirect)
+			require.NoError(t, err)
+			require.Equal(t, redirect.NewVid, b.Vid)
+			require.Equal(t, blob.Bid, b.Bid)
+			require.Equal(t, uint64(sizes.ECDataSize), b.BlobSize)
+			require.Equal(t, uint64(readSize), b.ReadSize)
+			require.Equal(t, 0, b.StripeUnit)
+
+			// read from data shards of the new blob once
+			newShards := make([][]byte, newTactic.N)
+			newSizes, err := ec.GetBufferSizes(int(b.BlobSize), newTactic)
+			require.NoError(t, err)
+			for idx := range newShards {
+				newShards[idx] = make([]byte, newSizes.ShardSize)
+				if from := idx * newSizes.ShardSize; from < len(transcoded) {
+					copy(newShards[idx], transcoded[from:])
+				}
+			}
+			buff := bytes.NewBuffer(nil)
+			for _, seg := range b.segments(newSizes, newTactic) {
+				buff.Write(newShards[seg.Index][seg.Offset : seg.Offset+seg.Size])
+			}
+			require.Equal(t, data[offset:offset+readSize], buff.Bytes())
+		}
+	}
+}
+
+func TestAccessStreamGetEncoder(t *testing.T) {
+	h := &Handler{encoder: map[codemode.CodeMode]ec.Encoder{}}
+	encoder, err := h.getEncoder(codemode.EC3P3)
+	require.NoError(t, err)
+	again, err := h.getEncoder(codemode.EC3P3)
+	require.NoError(t, err)
+	require.True(t, encoder == again)
+
+	_, err = h.getEncoder(codemode.CodeMode(0xff))
+	require.Error(t, err)
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+const (
+	defaultHedgePercentile = 0.95
+	defaultHedgeMinDelayMs = 10
+	defaultHedgeMaxDelayMs = 1000
+	defaultHedgeMaxHedges  = 2
+	defaultHedgeWindowSize = 128
+
+	// percentile is valid with min samples
+	hedgeMinSamples = 8
+)
+
+// HedgeConfig hedged shard reads config
+// An extra shard is read if a shard has not returned within the Percentile
+// latency of its disk (or its host if the disk has few samples),
+// the delay is limited in [MinDelayMs, MaxDelayMs], MaxDelayMs is used
+// if there is no enough samples. MaxHedges extra shards at most for one blob.
+// Latencies of last WindowSize reads are tracked per disk and host.
+type HedgeConfig struct {
+	Enable     bool    `json:"enable"`
+	Percentile float64 `json:"percentile"`
+	MinDelayMs int     `json:"min_delay_ms"`
+	MaxDelayMs int     `json:"max_delay_ms"`
+	MaxHedges  int     `json:"max_hedges"`
+	WindowSize int     `json:"window_size"`
+}
+
+func hedgeConfCheck(cfg *HedgeConfig) {
+	if cfg.Percentile <= 0 || cfg.Percentile > 1 {
+		cfg.Percentile = defaultHedgePercentile
+	}
+	cfg.MinDelayMs = defaultInt(cfg.MinDelayMs, defaultHedgeMinDelayMs)
+	cfg.MaxDelayMs = defaultInt(cfg.MaxDelayMs, defaultHedgeMaxDelayMs)
+	if cfg.MaxDelayMs < cfg.MinDelayMs {
+		cfg.MaxDelayMs = cfg.MinDelayMs
+	}
+	cfg.MaxHedges = defaultInt(cfg.MaxHedges, defaultHedgeMaxHedges)
+	cfg.WindowSize = defaultInt(cfg.WindowSize, defaultHedgeWindowSize)
+}
+
+// latencyWindow ring of recent latencies
+type latencyWindow struct {
+	lock    sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration, size int) {
+	w.lock.Lock()
+	if len(w.samples) < size {
+		w.samples = append(w.samples, d)
+	} else {
+		w.samples[w.next] = d
+		w.next = (w.next + 1) % size
+	}
+	w.lock.Unlock()
+}
+
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.lock.Lock()
+	if len(w.samples) < hedgeMinSamples {
+		w.lock.Unlock()
+		return 0, false
+	}
+	samples := make([]time.Duration, len(w.samples))
+	copy(samples, w.samples)
+	w.lock.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(float64(len(samples))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(samples) {
+		idx = len(samples) - 1
+	}
+	return samples[idx], true
+}
+
+// latencyTracker tracks read latencies of disks and hosts
+type latencyTracker struct {
+	size int
+
+	lock  sync.RWMutex
+	disks map[proto.DiskID]*latencyWindow
+	hosts map[string]*latencyWindow
+}
+
+func newLatencyTracker(size int) *latencyTracker {
+	return &latencyTracker{
+		size:  size,
+		disks: make(map[proto.DiskID]*latencyWindow),
+		hosts: make(map[string]*latencyWindow),
+	}
+}
+
+func (t *latencyTracker) add(diskID proto.DiskID, host string, d time.Duration) {
+	t.lock.RLock()
+	disk, hostW := t.disks[diskID], t.hosts[host]
+	t.lock.RUnlock()
+
+	if disk == nil || hostW == nil {
+		t.lock.Lock()
+		if disk = t.disks[diskID]; disk == nil {
+			disk = &latencyWindow{}
+			t.disks[diskID] = disk
+		}
+		if hostW = t.hosts[host]; hostW == nil {
+			hostW = &latencyWindow{}
+			t.hosts[host] = hostW
+		}
+		t.lock.Unlock()
+	}
+
+	disk.add(d, t.size)
+	hostW.add(d, t.size)
+}
+
+// percentile returns latency percentile of the disk, or the host if disk has few samples
+func (t *latencyTracker) percentile(diskID proto.DiskID, host string, p float64) (time.Duration, bool) {
+	t.lock.RLock()
+	disk, hostW := t.disks[diskID], t.hosts[host]
+	t.lock.RUnlock()
+
+	if disk != nil {
+		if d, ok := disk.percentile(p); ok {
+			return d, true
+		}
+	}
+	if hostW != nil {
+		return hostW.percentile(p)
+	}
+	return 0, false
+}
+
+// hedgeDelay returns delay to fire a hedged read if the shard has not returned,
+// returns 0 if hedging is disabled.
+func (h *Handler) hedgeDelay(vuid sortedVuid) time.Duration {
+	if h.latency == nil {
+		return 0
+	}
+	minDelay := time.Duration(h.HedgeConfig.MinDelayMs) * time.Millisecond
+	maxDelay := time.Duration(h.HedgeConfig.MaxDelayMs) * time.Millisecond
+
+	delay, ok := h.latency.percentile(vuid.diskID, vuid.host, h.HedgeConfig.Percentile)
+	if !ok || delay > maxDelay {
+		return maxDelay
+	}
+	if delay < minDelay {
+		return minDelay
+	}
+	return delay
+}
+
+// startHedgeTimer sends index of the shard to hedgeCh if it has not returned in hedge delay,
+// returns function to stop the timer.
+func (h *Handler) startHedgeTimer(vuid sortedVuid, hedgeCh chan<- int) func() {
+	delay := h.hedgeDelay(vuid)
+	if delay <= 0 {
+		return func() {}
+	}
+	timer := time.AfterFunc(delay, func() {
+		select {
+		case hedgeCh <- vuid.index:
+		default:
+		}
+	})
+	return func() { timer.Stop() }
+}
+
+func (h *Handler) recordLatency(vuid sortedVuid, d time.Duration) {
+	if h.latency != nil {
+		h.latency.add(vuid.diskID, vuid.host, d)
+	}
+}
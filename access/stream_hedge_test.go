@@ -0,0 +1,135 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func newHedgeStreamer(cfg HedgeConfig) *Handler {
+	h := *streamer
+	h.MinReadShardsX = 0
+	h.HedgeConfig = cfg
+	h.HedgeConfig.Enable = true
+	hedgeConfCheck(&h.HedgeConfig)
+	h.latency = newLatencyTracker(h.HedgeConfig.WindowSize)
+	return &h
+}
+
+func TestAccessStreamHedgeConfig(t *testing.T) {
+	cfg := HedgeConfig{}
+	hedgeConfCheck(&cfg)
+	require.Equal(t, defaultHedgePercentile, cfg.Percentile)
+	require.Equal(t, defaultHedgeMinDelayMs, cfg.MinDelayMs)
+	require.Equal(t, defaultHedgeMaxDelayMs, cfg.MaxDelayMs)
+	require.Equal(t, defaultHedgeMaxHedges, cfg.MaxHedges)
+	require.Equal(t, defaultHedgeWindowSize, cfg.WindowSize)
+
+	cfg = HedgeConfig{Percentile: 1.5, MinDelayMs: 100, MaxDelayMs: 10}
+	hedgeConfCheck(&cfg)
+	require.Equal(t, defaultHedgePercentile, cfg.Percentile)
+	require.Equal(t, 100, cfg.MaxDelayMs)
+}
+
+func TestAccessStreamHedgeLatencyTracker(t *testing.T) {
+	tracker := newLatencyTracker(10)
+	_, ok := tracker.percentile(1, "host", 0.9)
+	require.False(t, ok)
+
+	for ii := 1; ii <= 20; ii++ {
+		tracker.add(1, "host", time.Duration(ii)*time.Millisecond)
+	}
+	// window keeps the last 10 samples
+	d, ok := tracker.percentile(1, "host", 0.9)
+	require.True(t, ok)
+	require.Equal(t, 19*time.Millisecond, d)
+	d, ok = tracker.percentile(1, "host", 0)
+	require.True(t, ok)
+	require.Equal(t, 11*time.Millisecond, d)
+
+	// fallback to host
+	tracker.add(2, "host", time.Second)
+	d, ok = tracker.percentile(2, "host", 1)
+	require.True(t, ok)
+	require.Equal(t, time.Second, d)
+	_, ok = tracker.percentile(2, "other", 1)
+	require.False(t, ok)
+}
+
+func TestAccessStreamHedgeDelay(t *testing.T) {
+	h := *streamer
+	require.Equal(t, time.Duration(0), h.hedgeDelay(sortedVuid{}))
+	stop := h.startHedgeTimer(sortedVuid{}, make(chan int))
+	stop()
+
+	hh := newHedgeStreamer(HedgeConfig{MinDelayMs: 10, MaxDelayMs: 100})
+	vuid := sortedVuid{diskID: 1, host: "host"}
+	require.Equal(t, 100*time.Millisecond, hh.hedgeDelay(vuid))
+	for ii := 0; ii < hedgeMinSamples; ii++ {
+		hh.recordLatency(vuid, time.Millisecond)
+	}
+	require.Equal(t, 10*time.Millisecond, hh.hedgeDelay(vuid))
+	for ii := 0; ii < hh.HedgeConfig.WindowSize; ii++ {
+		hh.recordLatency(vuid, 50*time.Millisecond)
+	}
+	require.Equal(t, 50*time.Millisecond, hh.hedgeDelay(vuid))
+	for ii := 0; ii < hh.HedgeConfig.WindowSize; ii++ {
+		hh.recordLatency(vuid, time.Second)
+	}
+	require.Equal(t, 100*time.Millisecond, hh.hedgeDelay(vuid))
+
+	hedgeCh := make(chan int, 1)
+	vuid.index = 3
+	hh.startHedgeTimer(vuid, hedgeCh)
+	require.Equal(t, 3, <-hedgeCh)
+}
+
+func TestAccessStreamHedgeGet(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamHedgeGet")
+	dataShards.clean()
+	defer dataShards.clean()
+
+	h := newHedgeStreamer(HedgeConfig{MinDelayMs: 10, MaxDelayMs: 100})
+	size := 1 << 20
+	data := make([]byte, size)
+	rand.Read(data)
+	loc, err := h.Put(ctx(), bytes.NewReader(data), int64(size), nil)
+	require.NoError(t, err)
+
+	// slow shards are hedged without waiting blocking duration
+	ids := []proto.Vuid{1001, 1002}
+	for _, id := range ids {
+		vuidController.Block(id)
+	}
+	defer func() {
+		for _, id := range ids {
+			vuidController.Unblock(id)
+		}
+	}()
+	startTime := time.Now()
+	buff := bytes.NewBuffer(nil)
+	transfer, err := h.Get(ctx(), buff, *loc, uint64(size), 0)
+	require.NoError(t, err)
+	require.NoError(t, transfer())
+	require.True(t, dataEqual(data, buff.Bytes()))
+	require.Greater(t, vuidController.duration, time.Since(startTime))
+}
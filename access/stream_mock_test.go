@@ -353,6 +353,7 @@ func initMockData() {
 			return clustermgr.ServiceInfo{}, errNotFound
 		})
 	cli.EXPECT().GetVolumeInfo(gomock.Any(), gomock.Any()).AnyTimes().Return(dataVolume, nil)
+	cli.EXPECT().GetVolumeRedirect(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, errcode.ErrKvNotFound)
 	cli.EXPECT().DiskInfo(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
 		func(ctx context.Context, id proto.DiskID) (*blobnode.DiskInfo, error) {
 			if val, ok := dataDisks[id]; ok {
@@ -387,6 +388,7 @@ func initMockData() {
 	c.EXPECT().ChooseOne().AnyTimes().Return(clusterInfo, nil)
 	c.EXPECT().GetServiceController(gomock.Any()).AnyTimes().Return(serviceController, nil)
 	c.EXPECT().GetVolumeGetter(gomock.Any()).AnyTimes().Return(volumeGetter, nil)
+	c.EXPECT().DeleteBlobExpiry(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
 	cc = c
 
 	ctr = gomock.NewController(&testing.T{})
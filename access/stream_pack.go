@@ -0,0 +1,222 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/cubefs/blobstore/api/access"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+const (
+	defaultPackMaxObjectSize int64 = 1 << 16
+	defaultPackMaxPackSize   int64 = 1 << 20
+	defaultPackMaxCount            = 256
+	defaultPackWindowMs            = 10
+)
+
+// PackConfig small objects packing config
+// Objects not larger than MaxObjectSize are packed in a shared blob,
+// objects are collected in WindowMs since the first one arrived,
+// a shared blob has MaxCount objects and MaxPackSize bytes at most.
+type PackConfig struct {
+	Enable        bool  `json:"enable"`
+	MaxObjectSize int64 `json:"max_object_size"`
+	MaxPackSize   int64 `json:"max_pack_size"`
+	MaxCount      int   `json:"max_count"`
+	WindowMs      int   `json:"window_ms"`
+}
+
+func packConfCheck(cfg *PackConfig, maxBlobSize uint32) {
+	if cfg.MaxObjectSize <= 0 {
+		cfg.MaxObjectSize = defaultPackMaxObjectSize
+	}
+	if cfg.MaxPackSize <= 0 {
+		cfg.MaxPackSize = defaultPackMaxPackSize
+	}
+	if cfg.MaxPackSize > int64(maxBlobSize) {
+		cfg.MaxPackSize = int64(maxBlobSize)
+	}
+	if cfg.MaxObjectSize > cfg.MaxPackSize {
+		cfg.MaxObjectSize = cfg.MaxPackSize
+	}
+	cfg.MaxCount = defaultInt(cfg.MaxCount, defaultPackMaxCount)
+	cfg.WindowMs = defaultInt(cfg.WindowMs, defaultPackWindowMs)
+}
+
+type packItem struct {
+	ctx  context.Context
+	data []byte
+	loc  *access.Location
+	err  error
+	done chan struct{}
+}
+
+// packer collects small objects and puts them in one shared blob
+type packer struct {
+	handler *Handler
+	config  PackConfig
+	itemCh  chan *packItem
+}
+
+func newPacker(h *Handler) *packer {
+	p := &packer{
+		handler: h,
+		config:  h.PackConfig,
+		itemCh:  make(chan *packItem),
+	}
+	go p.loop()
+	return p
+}
+
+func (p *packer) canPack(size int64) bool {
+	return size > 0 && size <= p.config.MaxObjectSize
+}
+
+func (p *packer) put(ctx context.Context, rc io.Reader, size int64,
+	hasherMap access.HasherMap) (*access.Location, error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if len(hasherMap) > 0 {
+		rc = io.TeeReader(rc, hasherMap.ToWriter())
+	}
+	item := &packItem{
+		ctx:  ctx,
+		data: make([]byte, size),
+		done: make(chan struct{}),
+	}
+	if _, err := io.ReadFull(rc, item.data); err != nil {
+		span.Info("read pack data failed", err)
+		return nil, errcode.ErrAccessReadRequestBody
+	}
+
+	select {
+	case p.itemCh <- item:
+	case <-p.handler.stopCh:
+		return nil, errcode.ErrUnexpected
+	}
+	<-item.done
+	return item.loc, item.err
+}
+
+func (p *packer) loop() {
+	window := time.Duration(p.config.WindowMs) * time.Millisecond
+
+	var next *packItem
+	for {
+		if next == nil {
+			select {
+			case <-p.handler.stopCh:
+				return
+			case next = <-p.itemCh:
+			}
+		}
+
+		items := []*packItem{next}
+		size := int64(len(next.data))
+		next = nil
+
+		stopped := false
+		timer := time.NewTimer(window)
+	collect:
+		for len(items) < p.config.MaxCount && size < p.config.MaxPackSize {
+			select {
+			case <-p.handler.stopCh:
+				stopped = true
+				break collect
+			case <-timer.C:
+				break collect
+			case item := <-p.itemCh:
+				if size+int64(len(item.data)) > p.config.MaxPackSize {
+					next = item
+					break collect
+				}
+				items = append(items, item)
+				size += int64(len(item.data))
+			}
+		}
+		timer.Stop()
+
+		go p.flush(items, size)
+		if stopped {
+			if next != nil {
+				go p.flush([]*packItem{next}, int64(len(next.data)))
+			}
+			return
+		}
+	}
+}
+
+// flush puts packed objects in one blob, every object has
+// a location addressing its range in the shared blob.
+func (p *packer) flush(items []*packItem, size int64) {
+	defer func() {
+		for _, item := range items {
+			close(item.done)
+		}
+	}()
+
+	if len(items) == 1 {
+		item := items[0]
+		item.loc, item.err = p.handler.put(item.ctx, bytes.NewReader(item.data), size, nil)
+		return
+	}
+
+	span, ctx := trace.StartSpanFromContext(context.Background(), "pack")
+	buffer := make([]byte, 0, size)
+	for _, item := range items {
+		buffer = append(buffer, item.data...)
+	}
+
+	loc, err := p.handler.put(ctx, bytes.NewReader(buffer), size, nil)
+	if err == nil && (len(loc.Blobs) != 1 || loc.Blobs[0].Count != 1) {
+		err = errors.Newf("packed in more than one blob %+v", loc.Blobs)
+		if e := p.handler.clearGarbage(ctx, loc); e != nil {
+			span.Warn(errors.Detail(e))
+		}
+	}
+	if err != nil {
+		span.Errorf("put %d packed objects size:%d failed %s", len(items), size, errors.Detail(err))
+		for _, item := range items {
+			item.err = err
+		}
+		return
+	}
+
+	offset := uint64(0)
+	for idx, item := range items {
+		item.loc = &access.Location{
+			ClusterID: loc.ClusterID,
+			CodeMode:  loc.CodeMode,
+			Size:      uint64(len(item.data)),
+			BlobSize:  uint32(size),
+			Blobs:     []access.SliceInfo{loc.Blobs[0]},
+			Pack: &access.Pack{
+				Offset: offset,
+				Index:  uint32(idx),
+				Count:  uint32(len(items)),
+			},
+		}
+		offset += uint64(len(item.data))
+		trace.SpanFromContextSafe(item.ctx).Debugf("packed in %+v by %s", item.loc, span.TraceID())
+	}
+	span.Infof("put %d packed objects size:%d in %+v", len(items), size, loc.Blobs[0])
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/api/mqproxy"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/testing/mocks"
+)
+
+func newPackStreamer(t *testing.T, cfg PackConfig) (*Handler, func()) {
+	h := *streamer
+	h.PackConfig = cfg
+	h.PackConfig.Enable = true
+	packConfCheck(&h.PackConfig, h.MaxBlobSize)
+
+	stopCh := make(chan struct{})
+	h.stopCh = stopCh
+	h.packer = newPacker(&h)
+	return &h, func() { close(stopCh) }
+}
+
+func TestAccessStreamPackConfig(t *testing.T) {
+	cfg := PackConfig{}
+	packConfCheck(&cfg, 1<<22)
+	require.Equal(t, defaultPackMaxObjectSize, cfg.MaxObjectSize)
+	require.Equal(t, defaultPackMaxPackSize, cfg.MaxPackSize)
+	require.Equal(t, defaultPackMaxCount, cfg.MaxCount)
+	require.Equal(t, defaultPackWindowMs, cfg.WindowMs)
+
+	cfg = PackConfig{MaxObjectSize: 1 << 20, MaxPackSize: 1 << 30}
+	packConfCheck(&cfg, 1<<16)
+	require.Equal(t, int64(1<<16), cfg.MaxPackSize)
+	require.Equal(t, int64(1<<16), cfg.MaxObjectSize)
+}
+
+func TestAccessStreamPackPutGet(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamPackPutGet")
+	dataShards.clean()
+	defer dataShards.clean()
+
+	h, stop := newPackStreamer(t, PackConfig{MaxObjectSize: 1 << 10, MaxCount: 8, WindowMs: 1000})
+	defer stop()
+
+	datas := make([][]byte, 8)
+	locs := make([]*access.Location, len(datas))
+	var wg sync.WaitGroup
+	for idx := range datas {
+		datas[idx] = make([]byte, 100+idx)
+		rand.Read(datas[idx])
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			hasherMap := access.HasherMap{access.HashAlgCRC32: access.HashAlgCRC32.ToHasher()}
+			loc, err := h.Put(ctx(), bytes.NewReader(datas[idx]), int64(len(datas[idx])), hasherMap)
+			require.NoError(t, err)
+			locs[idx] = loc
+		}(idx)
+	}
+	wg.Wait()
+
+	packSize := uint32(0)
+	offsets := make(map[uint32]uint64)
+	for idx, loc := range locs {
+		require.NotNil(t, loc.Pack)
+		require.Equal(t, uint32(len(datas)), loc.Pack.Count)
+		require.Equal(t, uint64(len(datas[idx])), loc.Size)
+		require.Equal(t, 1, len(loc.Blobs))
+		require.Equal(t, locs[0].Blobs, loc.Blobs)
+		offsets[loc.Pack.Index] = loc.Pack.Offset
+		packSize += uint32(loc.Size)
+	}
+	require.Equal(t, len(datas), len(offsets))
+	require.Equal(t, packSize, locs[0].BlobSize)
+
+	for idx, loc := range locs {
+		require.NoError(t, fillCrc(loc))
+		decoded, err := access.DecodeLocationFromHex(loc.HexString())
+		require.NoError(t, err)
+		require.True(t, verifyCrc(&decoded))
+
+		buff := bytes.NewBuffer(nil)
+		transfer, err := h.Get(ctx(), buff, decoded, decoded.Size, 0)
+		require.NoError(t, err)
+		require.NoError(t, transfer())
+		require.Equal(t, datas[idx], buff.Bytes())
+
+		buff.Reset()
+		transfer, err = h.Get(ctx(), buff, decoded, 10, 50)
+		require.NoError(t, err)
+		require.NoError(t, transfer())
+		require.Equal(t, datas[idx][50:60], buff.Bytes())
+
+		_, err = h.Get(ctx(), buff, decoded, decoded.Size, 1)
+		require.Error(t, err)
+	}
+
+	// not packed
+	{
+		data := make([]byte, 1<<11)
+		loc, err := h.Put(ctx(), bytes.NewReader(data), int64(len(data)), nil)
+		require.NoError(t, err)
+		require.Nil(t, loc.Pack)
+	}
+}
+
+func TestAccessStreamPackDelete(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamPackDelete")
+
+	var deleteArgs []*mqproxy.DeleteArgs
+	sender := mocks.NewMockMsgSender(gomock.NewController(t))
+	sender.EXPECT().SendDeleteMsg(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, _ string, args *mqproxy.DeleteArgs) error {
+			deleteArgs = append(deleteArgs, args)
+			return nil
+		})
+	h := *streamer
+	h.mqproxyClient = sender
+
+	loc := &access.Location{
+		ClusterID: clusterID,
+		Size:      10,
+		BlobSize:  100,
+		Blobs:     []access.SliceInfo{{MinBid: 1000, Vid: 10, Count: 1}},
+		Pack:      &access.Pack{Offset: 20, Index: 2, Count: 5},
+	}
+	require.NoError(t, h.Delete(ctx(), loc))
+	require.Equal(t, 1, len(deleteArgs))
+	require.Equal(t, []mqproxy.BlobDelete{{
+		Bid:    1000,
+		Vid:    10,
+		Packed: &proto.PackedBlob{Index: 2, Count: 5},
+	}}, deleteArgs[0].Blobs)
+
+	loc.Pack = nil
+	require.NoError(t, h.Delete(ctx(), loc))
+	require.Equal(t, 2, len(deleteArgs))
+	require.Nil(t, deleteArgs[1].Blobs[0].Packed)
+}
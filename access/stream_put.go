@@ -43,7 +43,16 @@ import (
 // Put put one object
 //     required: size, file size
 //     optional: hasher map to calculate hash.Hash
+//     small object is packed with others in a shared blob if packing enabled
 func (h *Handler) Put(ctx context.Context, rc io.Reader, size int64,
+	hasherMap access.HasherMap) (*access.Location, error) {
+	if h.packer != nil && h.packer.canPack(size) {
+		return h.packer.put(ctx, rc, size, hasherMap)
+	}
+	return h.put(ctx, rc, size, hasherMap)
+}
+
+func (h *Handler) put(ctx context.Context, rc io.Reader, size int64,
 	hasherMap access.HasherMap) (*access.Location, error) {
 	span := trace.SpanFromContextSafe(ctx)
 	span.Debugf("put request size:%d hashes:b(%b)", size, hasherMap.ToHashAlgorithm())
@@ -90,6 +99,16 @@ func (h *Handler) Put(ctx context.Context, rc io.Reader, size int64,
 		}
 	}()
 
+	// encode and write stripe by stripe without buffering the whole blob
+	if h.stripeEnabled() && size >= h.StripeConfig.MinObjectSize {
+		location.Stripe = &access.Stripe{Unit: uint32(h.StripeConfig.Unit)}
+		if err = h.putStriped(ctx, location, limitReader); err != nil {
+			return nil, err
+		}
+		uploadSucc = true
+		return location, nil
+	}
+
 	readSize := int(blobSize)
 	if size < int64(readSize) {
 		readSize = int(size)
@@ -156,7 +175,7 @@ func (h *Handler) Put(ctx context.Context, rc io.Reader, size int64,
 		span.Debugf("to write blob(%d %d %d) ", clusterID, vid, bid)
 
 		startWrite := time.Now()
-		badIdx, err := h.writeToBlobnodesWithHystrix(ctx, clusterID, vid, bid, shards)
+		badIdx, err := h.writeToBlobnodesWithHystrix(ctx, clusterID, vid, bid, bufferedShards(shards))
 		putTime.AddPutWrite(startWrite)
 		if err != nil {
 			return nil, errors.Info(err, "write to blobnode failed")
@@ -170,9 +189,28 @@ func (h *Handler) Put(ctx context.Context, rc io.Reader, size int64,
 	return location, nil
 }
 
+// shardBodies bodies of shards to write to blobnodes
+type shardBodies interface {
+	// body returns reader of the shard, returns error if it cannot be read again
+	body(index int) (io.Reader, error)
+	size(index int) int64
+	// crc returns crc32 of the shard which has been read
+	crc(index int) uint32
+	// done is called after writing of the shard finished
+	done(index int)
+}
+
+// bufferedShards shards in memory, can be read repeatedly
+type bufferedShards [][]byte
+
+func (s bufferedShards) body(index int) (io.Reader, error) { return bytes.NewReader(s[index]), nil }
+func (s bufferedShards) size(index int) int64              { return int64(len(s[index])) }
+func (s bufferedShards) crc(index int) uint32              { return crc32.ChecksumIEEE(s[index]) }
+func (s bufferedShards) done(index int)                    {}
+
 func (h *Handler) writeToBlobnodesWithHystrix(ctx context.Context,
 	clusterID proto.ClusterID, vid proto.Vid, bid proto.BlobID,
-	shards [][]byte) (badIdx []uint8, err error) {
+	shards shardBodies) (badIdx []uint8, err error) {
 	err = hystrix.Do(rwCommand, func() error {
 		badIdx, err = h.writeToBlobnodes(ctx, clusterID, vid, bid, shards)
 		return err
@@ -183,7 +221,7 @@ func (h *Handler) writeToBlobnodesWithHystrix(ctx context.Context,
 // writeToBlobnodes write shards to blobnode
 func (h *Handler) writeToBlobnodes(ctx context.Context,
 	clusterID proto.ClusterID, vid proto.Vid, bid proto.BlobID,
-	shards [][]byte) (badIdx []uint8, err error) {
+	shards shardBodies) (badIdx []uint8, err error) {
 	span := trace.SpanFromContextSafe(ctx)
 
 	volume, err := h.getVolume(ctx, clusterID, vid, true)
@@ -215,16 +253,16 @@ func (h *Handler) writeToBlobnodes(ctx context.Context,
 
 		go func() {
 			defer func() {
+				shards.done(index)
 				wg.Done()
 			}()
 
 			diskID := unit.DiskID
-			crcOrigin := crc32.ChecksumIEEE(shards[index])
 			args := &blobnode.PutShardArgs{
 				DiskID: diskID,
 				Vuid:   unit.Vuid,
 				Bid:    bid,
-				Size:   int64(len(shards[index])),
+				Size:   shards.size(index),
 				Type:   blobnode.NormalIO,
 			}
 
@@ -253,11 +291,15 @@ func (h *Handler) writeToBlobnodes(ctx context.Context,
 				crc       uint32
 			)
 			writeErr = retry.ExponentialBackoff(3, 200).RuptOn(func() (bool, error) {
-				args.Body = bytes.NewReader(shards[index])
+				body, e := shards.body(index)
+				if e != nil {
+					return true, e
+				}
+				args.Body = body
 
 				crc, err = h.blobnodeClient.PutShard(ctxChild, host, args)
 				if err == nil {
-					if crc != crcOrigin {
+					if crcOrigin := shards.crc(index); crc != crcOrigin {
 						return false, fmt.Errorf("crc mismatch 0x%x != 0x%x", crc, crcOrigin)
 					}
 
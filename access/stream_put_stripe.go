@@ -0,0 +1,353 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"context"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/ec"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+const (
+	defaultStripeMinObjectSize  int64 = 1 << 22
+	defaultStripeDepth                = 4
+	defaultStripeCheckIntervalS       = 60
+)
+
+var (
+	errStripeShardRewrite = errors.New("stripe shard cannot be rewritten")
+	errStripeShardClosed  = errors.New("stripe shard closed")
+	errStripeAborted      = errors.New("stripe put aborted")
+)
+
+// StripeConfig streaming put config
+// Objects not smaller than MinObjectSize are encoded stripe by stripe
+// with Unit bytes per shard, and written to blobnodes while reading body.
+// Each shard queues Depth units at most, so memory of one put is about
+// Depth+2 stripes instead of the whole blob, but failed shards cannot be retried.
+//
+// Access nodes of old version read striped location as the normal layout,
+// so striped put is gated by the feature flag proto.StripeEnableConfigKey
+// of cluster manager, which is checked every CheckIntervalS seconds.
+// Turn the flag on after all access nodes of the region were upgraded.
+type StripeConfig struct {
+	Enable         bool  `json:"enable"`
+	MinObjectSize  int64 `json:"min_object_size"`
+	Unit           int   `json:"unit"`
+	Depth          int   `json:"depth"`
+	CheckIntervalS int   `json:"check_interval_s"`
+}
+
+func stripeConfCheck(cfg *StripeConfig) {
+	if cfg.MinObjectSize <= 0 {
+		cfg.MinObjectSize = defaultStripeMinObjectSize
+	}
+	cfg.Unit = defaultInt(cfg.Unit, ec.DefaultStripeUnit)
+	cfg.Depth = defaultInt(cfg.Depth, defaultStripeDepth)
+	cfg.CheckIntervalS = defaultInt(cfg.CheckIntervalS, defaultStripeCheckIntervalS)
+}
+
+// stripeEnabled returns true if striped put is enabled in config and in cluster
+func (h *Handler) stripeEnabled() bool {
+	return h.StripeConfig.Enable && atomic.LoadInt32(&h.stripeFlag) == 1
+}
+
+// loadStripeFlag keeps the last flag if failed to get config from cluster manager,
+// the flag is off if it has never been loaded.
+func (h *Handler) loadStripeFlag(ctx context.Context) {
+	span := trace.SpanFromContextSafe(ctx)
+	val, err := h.clusterController.GetConfig(ctx, proto.StripeEnableConfigKey)
+	if err != nil {
+		span.Debugf("get config %s failed %s", proto.StripeEnableConfigKey, errors.Detail(err))
+		return
+	}
+	enable, err := strconv.ParseBool(val)
+	if err != nil {
+		span.Warnf("invalid config %s=%s", proto.StripeEnableConfigKey, val)
+		return
+	}
+	var flag int32
+	if enable {
+		flag = 1
+	}
+	if atomic.SwapInt32(&h.stripeFlag, flag) != flag {
+		span.Infof("stripe put enable changed to %v", enable)
+	}
+}
+
+func (h *Handler) loopStripeFlag() {
+	_, ctx := trace.StartSpanFromContext(context.Background(), "stripe-flag")
+	h.loadStripeFlag(ctx)
+	go func() {
+		ticker := time.NewTicker(time.Duration(h.StripeConfig.CheckIntervalS) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stopCh:
+				return
+			case <-ticker.C:
+				_, ctx := trace.StartSpanFromContext(context.Background(), "stripe-flag")
+				h.loadStripeFlag(ctx)
+			}
+		}
+	}()
+}
+
+// stripeShard bounded pipe of one shard, units written by stripe encoder
+// are copied and queued until read by PutShard, Write blocks if queue is full.
+// Units written after the shard closed are discarded.
+type stripeShard struct {
+	size int64
+	unit int
+
+	queue   chan []byte
+	free    chan []byte
+	nAlloc  int
+	readErr error
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	aborted   <-chan struct{}
+
+	// reader side
+	taken  bool
+	crc    uint32
+	cur    []byte
+	curBuf []byte
+}
+
+func (s *stripeShard) Write(p []byte) (int, error) {
+	if err := s.checkClosed(); err != nil {
+		if err == errStripeShardClosed {
+			return len(p), nil
+		}
+		return 0, err
+	}
+
+	var buf []byte
+	select {
+	case <-s.aborted:
+		return 0, errStripeAborted
+	case <-s.closed:
+		return len(p), nil
+	case buf = <-s.free:
+	default:
+		if s.nAlloc < cap(s.free) {
+			s.nAlloc++
+			buf = make([]byte, 0, s.unit)
+			break
+		}
+		select {
+		case <-s.aborted:
+			return 0, errStripeAborted
+		case <-s.closed:
+			return len(p), nil
+		case buf = <-s.free:
+		}
+	}
+
+	buf = append(buf[:0], p...)
+	select {
+	case <-s.aborted:
+		return 0, errStripeAborted
+	case <-s.closed:
+	case s.queue <- buf:
+	}
+	return len(p), nil
+}
+
+func (s *stripeShard) Read(p []byte) (int, error) {
+	if len(s.cur) == 0 {
+		if s.curBuf != nil {
+			s.free <- s.curBuf
+			s.curBuf = nil
+		}
+		if err := s.checkClosed(); err != nil {
+			return 0, err
+		}
+		select {
+		case <-s.aborted:
+			return 0, errStripeAborted
+		case <-s.closed:
+			return 0, errStripeShardClosed
+		case buf, ok := <-s.queue:
+			if !ok {
+				if s.readErr != nil {
+					return 0, s.readErr
+				}
+				return 0, io.EOF
+			}
+			s.cur, s.curBuf = buf, buf
+		}
+	}
+
+	n := copy(p, s.cur)
+	s.cur = s.cur[n:]
+	atomic.StoreUint32(&s.crc, crc32.Update(atomic.LoadUint32(&s.crc), crc32.IEEETable, p[:n]))
+	return n, nil
+}
+
+func (s *stripeShard) checkClosed() error {
+	select {
+	case <-s.aborted:
+		return errStripeAborted
+	default:
+	}
+	select {
+	case <-s.closed:
+		return errStripeShardClosed
+	default:
+	}
+	return nil
+}
+
+func (s *stripeShard) close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// stripeShards shards of one blob written by stripe encoder
+type stripeShards struct {
+	shards    []*stripeShard
+	abortOnce sync.Once
+	aborted   chan struct{}
+}
+
+func newStripeShards(n, shardSize, unit, depth int) *stripeShards {
+	s := &stripeShards{
+		shards:  make([]*stripeShard, n),
+		aborted: make(chan struct{}),
+	}
+	for idx := range s.shards {
+		s.shards[idx] = &stripeShard{
+			size: int64(shardSize),
+			unit: unit,
+			// one being read, one being written
+			queue:   make(chan []byte, depth),
+			free:    make(chan []byte, depth+2),
+			closed:  make(chan struct{}),
+			aborted: s.aborted,
+		}
+	}
+	return s
+}
+
+func (s *stripeShards) body(index int) (io.Reader, error) {
+	shard := s.shards[index]
+	if shard.taken {
+		return nil, errStripeShardRewrite
+	}
+	shard.taken = true
+	return shard, nil
+}
+
+func (s *stripeShards) size(index int) int64 { return s.shards[index].size }
+func (s *stripeShards) crc(index int) uint32 { return atomic.LoadUint32(&s.shards[index].crc) }
+func (s *stripeShards) done(index int)       { s.shards[index].close() }
+
+func (s *stripeShards) writers() []io.Writer {
+	writers := make([]io.Writer, len(s.shards))
+	for idx := range s.shards {
+		writers[idx] = s.shards[idx]
+	}
+	return writers
+}
+
+// closeWrite is called by the writer after all units written or failed
+func (s *stripeShards) closeWrite(err error) {
+	for _, shard := range s.shards {
+		shard.readErr = err
+		close(shard.queue)
+	}
+}
+
+// abort stops both reading and writing of all shards
+func (s *stripeShards) abort() {
+	s.abortOnce.Do(func() { close(s.aborted) })
+}
+
+// putStriped encodes and writes all blobs of the location stripe by stripe
+func (h *Handler) putStriped(ctx context.Context, location *access.Location, rc io.Reader) error {
+	span := trace.SpanFromContextSafe(ctx)
+	putTime := new(times)
+	defer func() {
+		span.AppendRPCTrackLog(putTime.PutLogs())
+	}()
+
+	for _, blob := range location.Spread() {
+		if err := h.putStripedBlob(ctx, putTime, location.ClusterID, location.CodeMode, blob, rc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) putStripedBlob(ctx context.Context, putTime *times,
+	clusterID proto.ClusterID, codeMode codemode.CodeMode, blob access.Blob, rc io.Reader) error {
+	span := trace.SpanFromContextSafe(ctx)
+	tactic := codeMode.Tactic()
+	sizes, err := ec.GetBufferSizes(int(blob.Size), tactic)
+	if err != nil {
+		return err
+	}
+	encoder, err := ec.NewStripeEncoder(h.encoder[codeMode], tactic, h.StripeConfig.Unit, h.memPool)
+	if err != nil {
+		return err
+	}
+	defer encoder.Release()
+
+	shards := newStripeShards(codeMode.GetShardNum(), sizes.ShardSize, h.StripeConfig.Unit, h.StripeConfig.Depth)
+	readCh := make(chan error, 1)
+	go func() {
+		err := encoder.Encode(rc, int(blob.Size), shards.writers())
+		shards.closeWrite(err)
+		readCh <- err
+	}()
+	span.Debugf("to write striped blob(%d %d %d) ", clusterID, blob.Vid, blob.Bid)
+
+	// reading body, encoding and writing to blobnodes are pipelined
+	startWrite := time.Now()
+	badIdx, err := h.writeToBlobnodesWithHystrix(ctx, clusterID, blob.Vid, blob.Bid, shards)
+	if err != nil {
+		shards.abort()
+	}
+	readErr := <-readCh
+	putTime.AddPutN(int(blob.Size))
+	putTime.AddPutWrite(startWrite)
+
+	if readErr != nil && readErr != errStripeAborted {
+		span.Infof("read striped blob data failed size:%d %s", blob.Size, readErr.Error())
+		return errcode.ErrAccessReadRequestBody
+	}
+	if err != nil {
+		return errors.Info(err, "write to blobnode failed")
+	}
+	if len(badIdx) > 0 {
+		h.sendRepairMsgBg(ctx, clusterID, blob.Vid, blob.Bid, badIdx)
+	}
+	return nil
+}
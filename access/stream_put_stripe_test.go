@@ -0,0 +1,232 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"crypto/rand"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/common/ec"
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func newStripeStreamer(cfg StripeConfig) *Handler {
+	h := *streamer
+	h.StripeConfig = cfg
+	h.StripeConfig.Enable = true
+	h.stripeFlag = 1
+	stripeConfCheck(&h.StripeConfig)
+	return &h
+}
+
+func TestAccessStreamStripeConfig(t *testing.T) {
+	cfg := StripeConfig{}
+	stripeConfCheck(&cfg)
+	require.Equal(t, defaultStripeMinObjectSize, cfg.MinObjectSize)
+	require.Equal(t, ec.DefaultStripeUnit, cfg.Unit)
+	require.Equal(t, defaultStripeDepth, cfg.Depth)
+	require.Equal(t, defaultStripeCheckIntervalS, cfg.CheckIntervalS)
+}
+
+func TestAccessStreamStripeFlag(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamStripeFlag")
+	ctr := gomock.NewController(t)
+	cc := NewMockClusterController(ctr)
+	gomock.InOrder(
+		cc.EXPECT().GetConfig(gomock.Any(), proto.StripeEnableConfigKey).Return("", errcode.ErrNotFound),
+		cc.EXPECT().GetConfig(gomock.Any(), proto.StripeEnableConfigKey).Return("true", nil),
+		cc.EXPECT().GetConfig(gomock.Any(), proto.StripeEnableConfigKey).Return("", errcode.ErrNotFound),
+		cc.EXPECT().GetConfig(gomock.Any(), proto.StripeEnableConfigKey).Return("invalid", nil),
+		cc.EXPECT().GetConfig(gomock.Any(), proto.StripeEnableConfigKey).Return("false", nil),
+	)
+
+	h := newStripeStreamer(StripeConfig{MinObjectSize: 1 << 10, Unit: 1 << 12})
+	h.clusterController = cc
+	h.stripeFlag = 0
+	h.loadStripeFlag(ctx())
+	require.False(t, h.stripeEnabled())
+	h.loadStripeFlag(ctx())
+	require.True(t, h.stripeEnabled())
+	// keep the last flag if failed
+	h.loadStripeFlag(ctx())
+	require.True(t, h.stripeEnabled())
+	h.loadStripeFlag(ctx())
+	require.True(t, h.stripeEnabled())
+	h.loadStripeFlag(ctx())
+	require.False(t, h.stripeEnabled())
+
+	// not striped if disabled in cluster
+	h.clusterController = streamer.clusterController
+	loc, err := h.Put(ctx(), bytes.NewReader(make([]byte, 1<<12)), 1<<12, nil)
+	require.NoError(t, err)
+	require.Nil(t, loc.Stripe)
+	h.StripeConfig.Enable = false
+	h.stripeFlag = 1
+	require.False(t, h.stripeEnabled())
+}
+
+func TestAccessStreamStripeShards(t *testing.T) {
+	shards := newStripeShards(2, 10, 4, 1)
+	writers := shards.writers()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, p := range [][]byte{[]byte("abcd"), []byte("efgh"), []byte("ij")} {
+			for _, w := range writers {
+				n, err := w.Write(p)
+				require.NoError(t, err)
+				require.Equal(t, len(p), n)
+			}
+		}
+		shards.closeWrite(nil)
+	}()
+
+	// discard units of closed shard
+	shards.done(1)
+	body, err := shards.body(0)
+	require.NoError(t, err)
+	_, err = shards.body(0)
+	require.ErrorIs(t, err, errStripeShardRewrite)
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	require.Equal(t, []byte("abcdefghij"), data)
+	require.Equal(t, int64(10), shards.size(0))
+	require.Equal(t, crc32.ChecksumIEEE(data), shards.crc(0))
+	<-done
+
+	body, err = shards.body(1)
+	require.NoError(t, err)
+	_, err = body.Read(make([]byte, 1))
+	require.ErrorIs(t, err, errStripeShardClosed)
+
+	// aborted
+	shards = newStripeShards(1, 10, 4, 1)
+	shards.abort()
+	shards.abort()
+	_, err = shards.writers()[0].Write([]byte("a"))
+	require.ErrorIs(t, err, errStripeAborted)
+	body, _ = shards.body(0)
+	_, err = body.Read(make([]byte, 1))
+	require.ErrorIs(t, err, errStripeAborted)
+
+	// write error
+	shards = newStripeShards(1, 10, 4, 1)
+	shards.closeWrite(io.ErrUnexpectedEOF)
+	body, _ = shards.body(0)
+	_, err = body.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestAccessStreamStripePutGet(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamStripePutGet")
+	dataShards.clean()
+	defer dataShards.clean()
+
+	h := newStripeStreamer(StripeConfig{MinObjectSize: 1 << 10, Unit: 1 << 12, Depth: 1})
+	cases := []struct {
+		size     int
+		offset   int
+		readSize int
+	}{
+		{1 << 10, 0, 1 << 10},
+		{1 << 20, 0, 1 << 20},
+		{(1 << 22) + 1023, 0, (1 << 22) + 1023},
+		{(1 << 22) + 1023, 1 << 20, 1 << 20},
+		{(1 << 22) + 1023, (1 << 12) - 1, 1 << 14},
+		{(1 << 22) + 1023, 1 << 22, 1023},
+		{12192823, 6799138, 908019},
+	}
+	for _, cs := range cases {
+		data := make([]byte, cs.size)
+		rand.Read(data)
+		loc, err := h.Put(ctx(), bytes.NewReader(data), int64(cs.size), nil)
+		require.NoError(t, err)
+		require.True(t, loc.Stripe.IsValid())
+		require.Equal(t, uint32(1<<12), loc.Stripe.Unit)
+
+		buff := bytes.NewBuffer(nil)
+		transfer, err := h.Get(ctx(), buff, *loc, uint64(cs.readSize), uint64(cs.offset))
+		require.NoError(t, err)
+		require.NoError(t, transfer())
+		require.True(t, dataEqual(data[cs.offset:cs.offset+cs.readSize], buff.Bytes()))
+	}
+
+	// not striped if smaller than min object size
+	loc, err := h.Put(ctx(), bytes.NewReader(make([]byte, 1023)), 1023, nil)
+	require.NoError(t, err)
+	require.Nil(t, loc.Stripe)
+}
+
+func TestAccessStreamStripeGetBroken(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamStripeGetBroken")
+	dataShards.clean()
+	vuidController.Unbreak(1005)
+	defer func() {
+		dataShards.clean()
+		vuidController.Unbreak(1001)
+		vuidController.Break(1005)
+	}()
+
+	h := newStripeStreamer(StripeConfig{MinObjectSize: 1 << 10, Unit: 1 << 12})
+	size := 1 << 20
+	data := make([]byte, size)
+	rand.Read(data)
+	loc, err := h.Put(ctx(), bytes.NewReader(data), int64(size), nil)
+	require.NoError(t, err)
+
+	// reconstruct striped data
+	vuidController.Break(1001)
+	for _, rang := range [][2]int{{0, size}, {1 << 12, 1 << 12}, {size - 100, 100}} {
+		buff := bytes.NewBuffer(nil)
+		transfer, err := h.Get(ctx(), buff, *loc, uint64(rang[1]), uint64(rang[0]))
+		require.NoError(t, err)
+		require.NoError(t, transfer())
+		require.True(t, dataEqual(data[rang[0]:rang[0]+rang[1]], buff.Bytes()))
+	}
+}
+
+func TestAccessStreamStripePutFailed(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamStripePutFailed")
+	dataShards.clean()
+	defer dataShards.clean()
+
+	h := newStripeStreamer(StripeConfig{MinObjectSize: 1 << 10, Unit: 1 << 12})
+	size := 1 << 20
+
+	// short body
+	_, err := h.Put(ctx(), bytes.NewReader(make([]byte, size-1)), int64(size), nil)
+	require.ErrorIs(t, err, errcode.ErrAccessReadRequestBody)
+
+	// quorum failed
+	ids := []proto.Vuid{1001, 1011}
+	for _, id := range ids {
+		vuidController.Break(id)
+	}
+	defer func() {
+		for _, id := range ids {
+			vuidController.Unbreak(id)
+		}
+	}()
+	_, err = h.Put(ctx(), bytes.NewReader(make([]byte, size)), int64(size), nil)
+	require.Error(t, err)
+}
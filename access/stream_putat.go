@@ -59,7 +59,11 @@ func (h *Handler) PutAt(ctx context.Context, rc io.Reader,
 	}
 	defer buffer.Release()
 
-	shards, err := h.encoder[volume.CodeMode].Split(buffer.ECDataBuf)
+	encoder, err := h.getEncoder(volume.CodeMode)
+	if err != nil {
+		return err
+	}
+	shards, err := encoder.Split(buffer.ECDataBuf)
 	if err != nil {
 		return err
 	}
@@ -82,13 +86,13 @@ func (h *Handler) PutAt(ctx context.Context, rc io.Reader,
 		return errcode.ErrAccessReadRequestBody
 	}
 
-	if err = h.encoder[volume.CodeMode].Encode(shards); err != nil {
+	if err = encoder.Encode(shards); err != nil {
 		return err
 	}
 	span.Debugf("to write blob(%d %d %d) ", clusterID, vid, bid)
 
 	startWrite := time.Now()
-	badIdx, err := h.writeToBlobnodesWithHystrix(ctx, clusterID, vid, bid, shards)
+	badIdx, err := h.writeToBlobnodesWithHystrix(ctx, clusterID, vid, bid, bufferedShards(shards))
 	putTime.AddPutWrite(startWrite)
 	if err != nil {
 		return err
@@ -16,13 +16,18 @@ package access
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"io"
 	"testing"
 
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/cubefs/blobstore/api/access"
+	cmapi "github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/proto"
 )
 
 func newReader(size int) io.Reader {
@@ -62,3 +67,86 @@ func TestAccessStreamDelete(t *testing.T) {
 
 	dataShards.clean()
 }
+
+func TestAccessStreamSetExpiry(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamSetExpiry")
+	var (
+		called int
+		blobs  []cmapi.BlobExpiry
+	)
+	c := NewMockClusterController(gomock.NewController(t))
+	c.EXPECT().SetBlobExpiry(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, clusterID proto.ClusterID, args *cmapi.BlobExpiryArgs) error {
+			called++
+			if clusterID != 1 {
+				return errNotFound
+			}
+			blobs = args.Blobs
+			return nil
+		})
+	h := *streamer
+	h.clusterController = c
+
+	loc := &access.Location{
+		ClusterID: 1,
+		Blobs: []access.SliceInfo{
+			{Vid: 1, MinBid: 100, Count: 2},
+			{Vid: 2, MinBid: 200, Count: 0},
+			{Vid: 3, MinBid: 300, Count: 1},
+		},
+	}
+	require.NoError(t, h.SetExpiry(ctx(), loc, 1000, ""))
+	require.Equal(t, 1, called)
+	require.Equal(t, []cmapi.BlobExpiry{
+		{Vid: 1, MinBid: 100, Count: 2, ExpireAt: 1000},
+		{Vid: 3, MinBid: 300, Count: 1, ExpireAt: 1000},
+	}, blobs)
+
+	loc.Blobs = loc.Blobs[2:]
+	loc.Pack = &access.Pack{Offset: 10, Index: 1, Count: 3}
+	require.NoError(t, h.SetExpiry(ctx(), loc, 2000, "owner"))
+	require.Equal(t, []cmapi.BlobExpiry{
+		{Vid: 3, MinBid: 300, Count: 1, ExpireAt: 2000, Packed: &proto.PackedBlob{Index: 1, Count: 3}, Owner: "owner"},
+	}, blobs)
+
+	// empty location
+	called = 0
+	require.NoError(t, h.SetExpiry(ctx(), &access.Location{ClusterID: 1}, 1000, ""))
+	require.Equal(t, 0, called)
+
+	loc.ClusterID = 2
+	require.Error(t, h.SetExpiry(ctx(), loc, 1000, ""))
+	require.Equal(t, 3, called)
+}
+
+func TestAccessStreamDeleteExpiry(t *testing.T) {
+	ctx := ctxWithName("TestAccessStreamDeleteExpiry")
+	var blobs []cmapi.BlobExpiry
+	c := NewMockClusterController(gomock.NewController(t))
+	c.EXPECT().DeleteBlobExpiry(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, clusterID proto.ClusterID, args *cmapi.BlobExpiryArgs) error {
+			if clusterID != 1 {
+				return errNotFound
+			}
+			blobs = args.Blobs
+			return nil
+		})
+	h := *streamer
+	h.clusterController = c
+
+	// the blobs are not deleted if failed to delete expiry
+	loc := &access.Location{
+		ClusterID: 2,
+		BlobSize:  1,
+		Blobs:     []access.SliceInfo{{Vid: 3, MinBid: 300, Count: 1}},
+		Pack:      &access.Pack{Offset: 10, Index: 1, Count: 3},
+	}
+	require.ErrorIs(t, h.Delete(ctx(), loc), errNotFound)
+
+	c.EXPECT().GetServiceController(gomock.Any()).Return(nil, errNotFound)
+	loc.ClusterID = 1
+	require.Error(t, h.Delete(ctx(), loc))
+	require.Equal(t, []cmapi.BlobExpiry{
+		{Vid: 3, MinBid: 300, Count: 1, Packed: &proto.PackedBlob{Index: 1, Count: 3}},
+	}, blobs)
+}
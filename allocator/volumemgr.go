@@ -130,6 +130,7 @@ func NewVolumeMgr(ctx context.Context, blobCfg BlobConfig, volCfg VolConfig, clu
 
 	go v.retainTask()
 	go v.metricReportTask()
+	go clustermgr.LoopSyncCodeModes(v.clusterMgr, clustermgr.CodeModeSyncInterval, v.closed)
 
 	return v, err
 }
@@ -152,6 +153,9 @@ func (v *volumeMgr) initModeInfo(ctx context.Context) (err error) {
 	if err != nil {
 		return errors.Info(err, "strconv.Atoi volumeChunkSize err").Detail(err)
 	}
+	if err = clustermgr.SyncCodeModes(ctx, v.clusterMgr); err != nil {
+		return errors.Info(err, "Sync code modes from clusterMgr err").Detail(err)
+	}
 	codeModeInfos, err := v.clusterMgr.GetConfig(ctx, proto.CodeModeConfigKey)
 	if err != nil {
 		return errors.Info(err, "Get code_mode config from clusterMgr err").Detail(err)
@@ -172,6 +172,19 @@ type Config struct {
 	// It is an io.WriteCloser that writes to the specified filename.
 	// YOU should CLOSE it after you do not use the client anymore.
 	Logger *Logger
+
+	// Encryption encrypt objects in client if setting.
+	// Encrypted objects can be got only by client with the same KeyProvider.
+	Encryption *EncryptionConfig
+}
+
+// EncryptionConfig envelope encryption config
+type EncryptionConfig struct {
+	// KeyProvider provides data key for every object
+	KeyProvider KeyProvider
+	// ChunkSize plaintext size of every sealed chunk,
+	// ranged get will read the aligned chunks.
+	ChunkSize uint32
 }
 
 // ConsulConfig alias of consul api.Config
@@ -201,6 +214,19 @@ type API interface {
 	// Delete all blobs in these locations.
 	// return failed locations which have yet been deleted if error is not nil.
 	Delete(ctx context.Context, args *DeleteArgs) (failedLocations []Location, err error)
+
+	// MultipartInit start a multipart upload, parts of the upload could be
+	// put in parallel and retried independently on the returned host.
+	MultipartInit(ctx context.Context, args *MultipartInitArgs) (upload MultipartUpload, err error)
+	// MultipartPut put one part of the upload, the part can be put repeatedly.
+	MultipartPut(ctx context.Context, upload *MultipartUpload, args *MultipartPutArgs) (hashSumMap HashSumMap, err error)
+	// MultipartComplete complete the upload if all parts have been put.
+	// return the location of the whole object.
+	MultipartComplete(ctx context.Context, upload *MultipartUpload) (location Location, err error)
+	// MultipartAbort abort the upload, all put parts will be deleted.
+	MultipartAbort(ctx context.Context, upload *MultipartUpload) error
+	// MultipartList returns indexes of put parts to resume the upload.
+	MultipartList(ctx context.Context, upload *MultipartUpload) (uploaded []uint32, err error)
 }
 
 var _ API = (*client)(nil)
@@ -254,6 +280,16 @@ func New(cfg Config) (API, error) {
 	if cfg.ServiceIntervalMs < 500 {
 		cfg.ServiceIntervalMs = defaultServiceIntervalMs
 	}
+	if cfg.Encryption != nil {
+		if cfg.Encryption.KeyProvider == nil {
+			return nil, errcode.ErrIllegalArguments
+		}
+		encryption := *cfg.Encryption
+		if encryption.ChunkSize == 0 {
+			encryption.ChunkSize = DefaultEnvelopeChunkSize
+		}
+		cfg.Encryption = &encryption
+	}
 
 	var rpcClient rpc.Client
 	if cfg.RPCConfig != nil {
@@ -319,15 +355,25 @@ func New(cfg Config) (API, error) {
 }
 
 func (c *client) Put(ctx context.Context, args *PutArgs) (location Location, hashSumMap HashSumMap, err error) {
+	expected, ok := args.ExpectedHashSumMap()
+	if !ok || args.Expiry < 0 {
+		return location, nil, errcode.ErrIllegalArguments
+	}
 	if args.Size == 0 {
-		hashSumMap := args.Hashes.ToHashSumMap()
+		hashSumMap := (args.Hashes | expected.ToHashAlgorithm()).ToHashSumMap()
 		for alg := range hashSumMap {
 			hashSumMap[alg] = alg.ToHasher().Sum(nil)
 		}
+		if !hashSumMap.Verify(expected) {
+			return location, nil, errcode.ErrAccessChecksumMismatch
+		}
 		return Location{Blobs: make([]SliceInfo, 0)}, hashSumMap, nil
 	}
 
 	ctx = withReqidContext(ctx)
+	if c.config.Encryption != nil {
+		return c.putEncrypted(ctx, args)
+	}
 	if args.Size <= c.config.MaxSizePutOnce {
 		return c.putObject(ctx, args)
 	}
@@ -372,7 +418,7 @@ func (c *client) putObject(ctx context.Context, args *PutArgs) (location Locatio
 			body = reader
 		}
 
-		urlStr := fmt.Sprintf("%s/put?size=%d&hashes=%d", host, args.Size, args.Hashes)
+		urlStr := fmt.Sprintf("%s/put?size=%d&hashes=%d%s", host, args.Size, args.Hashes, args.expectedQuery())
 		req, e := http.NewRequest(http.MethodPut, urlStr, body)
 		if e != nil {
 			return e
@@ -441,7 +487,9 @@ func (c *client) putPartsBatch(ctx context.Context, parts []blobPart) error {
 func (c *client) putParts(ctx context.Context, args *PutArgs) (Location, HashSumMap, error) {
 	span := trace.SpanFromContextSafe(ctx)
 
-	hashSumMap := args.Hashes.ToHashSumMap()
+	// verify the expected checksums locally, access cannot see the whole body
+	expected, _ := args.ExpectedHashSumMap()
+	hashSumMap := (args.Hashes | expected.ToHashAlgorithm()).ToHashSumMap()
 	hasherMap := make(HasherMap, len(hashSumMap))
 	for alg := range hashSumMap {
 		hasherMap[alg] = alg.ToHasher()
@@ -485,7 +533,8 @@ func (c *client) putParts(ctx context.Context, args *PutArgs) (Location, HashSum
 	err := c.tryN(ctx, c.config.MaxHostRetry, func(host string) error {
 		allocResp := &AllocResp{}
 		if err := c.rpcClient.PostWith(ctx, fmt.Sprintf("%s/alloc", host), allocResp, AllocArgs{
-			Size: uint64(args.Size),
+			Size:   uint64(args.Size),
+			Expiry: args.Expiry,
 		}); err != nil {
 			return err
 		}
@@ -649,6 +698,7 @@ func (c *client) putParts(ctx context.Context, args *PutArgs) (Location, HashSum
 					BlobSize:        loc.BlobSize,
 					CodeMode:        loc.CodeMode,
 					AssignClusterID: loc.ClusterID,
+					Expiry:          args.Expiry,
 				}); err != nil {
 					return err
 				}
@@ -703,6 +753,10 @@ func (c *client) putParts(ctx context.Context, args *PutArgs) (Location, HashSum
 	for alg, hasher := range hasherMap {
 		hashSumMap[alg] = hasher.Sum(nil)
 	}
+	if !hashSumMap.Verify(expected) {
+		span.Errorf("checksum mismatch expected:%+v actual:%+v", expected.All(), hashSumMap.All())
+		return Location{}, nil, errcode.ErrAccessChecksumMismatch
+	}
 	success = true
 	return loc, hashSumMap, nil
 }
@@ -713,6 +767,13 @@ func (c *client) Get(ctx context.Context, args *GetArgs) (body io.ReadCloser, er
 	}
 
 	ctx = withReqidContext(ctx)
+	if args.Location.Envelope != nil {
+		return c.getEncrypted(ctx, args)
+	}
+	return c.get(ctx, args)
+}
+
+func (c *client) get(ctx context.Context, args *GetArgs) (body io.ReadCloser, err error) {
 	if args.Location.Size == 0 || args.ReadSize == 0 {
 		return noopBody{}, nil
 	}
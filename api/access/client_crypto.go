@@ -0,0 +1,262 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"context"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// chunkNonce returns nonce of the index-th chunk,
+// the data key is unique for every object, so the nonce never be reused.
+func chunkNonce(nonce []byte, index uint64) []byte {
+	for i := range nonce[:len(nonce)-8] {
+		nonce[i] = 0
+	}
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], index)
+	return nonce
+}
+
+// encryptReader seals plaintext chunk by chunk
+type encryptReader struct {
+	aead      cipher.AEAD
+	reader    io.Reader
+	remain    uint64
+	index     uint64
+	nonce     []byte
+	plain     []byte
+	sealedBuf []byte
+	sealed    []byte
+}
+
+func newEncryptReader(aead cipher.AEAD, reader io.Reader, size uint64, chunkSize uint32) *encryptReader {
+	return &encryptReader{
+		aead:      aead,
+		reader:    reader,
+		remain:    size,
+		nonce:     make([]byte, aead.NonceSize()),
+		plain:     make([]byte, chunkSize),
+		sealedBuf: make([]byte, 0, int(chunkSize)+aead.Overhead()),
+	}
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	if len(r.sealed) == 0 {
+		if r.remain == 0 {
+			return 0, io.EOF
+		}
+
+		plain := r.plain
+		if uint64(len(plain)) > r.remain {
+			plain = plain[:r.remain]
+		}
+		if _, err := io.ReadFull(r.reader, plain); err != nil {
+			return 0, err
+		}
+		r.sealed = r.aead.Seal(r.sealedBuf[:0], chunkNonce(r.nonce, r.index), plain, nil)
+		r.remain -= uint64(len(plain))
+		r.index++
+	}
+
+	n := copy(p, r.sealed)
+	r.sealed = r.sealed[n:]
+	return n, nil
+}
+
+// decryptReader opens sealed chunks, and returns plaintext in range
+type decryptReader struct {
+	aead   cipher.AEAD
+	body   io.ReadCloser
+	index  uint64
+	skip   uint64
+	remain uint64
+	err    error
+	nonce  []byte
+	sealed []byte
+	plain  []byte
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.remain == 0 {
+			return 0, io.EOF
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *decryptReader) next() error {
+	n, err := io.ReadFull(r.body, r.sealed)
+	if err == io.ErrUnexpectedEOF && n > r.aead.Overhead() {
+		err = nil // the last chunk
+	} else if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	plain, err := r.aead.Open(r.sealed[:0], chunkNonce(r.nonce, r.index), r.sealed[:n], nil)
+	if err != nil {
+		return fmt.Errorf("decrypt %dth chunk %s", r.index, err.Error())
+	}
+	r.index++
+
+	if r.skip > 0 {
+		if r.skip >= uint64(len(plain)) {
+			return fmt.Errorf("decrypt %dth chunk short %d", r.index-1, len(plain))
+		}
+		plain = plain[r.skip:]
+		r.skip = 0
+	}
+	if uint64(len(plain)) > r.remain {
+		plain = plain[:r.remain]
+	}
+	r.remain -= uint64(len(plain))
+	r.plain = plain
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.body.Close()
+}
+
+func (c *client) putEncrypted(ctx context.Context, args *PutArgs) (Location, HashSumMap, error) {
+	span := trace.SpanFromContextSafe(ctx)
+	encryption := c.config.Encryption
+
+	keyID, dataKey, wrappedKey, err := encryption.KeyProvider.GenerateDataKey(ctx)
+	if err != nil {
+		span.Error("generate data key", err)
+		return Location{}, nil, errcode.ErrUnexpected
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		span.Error("new data key cipher", err)
+		return Location{}, nil, errcode.ErrUnexpected
+	}
+	envelope := Envelope{
+		KeyID:      keyID,
+		WrappedKey: wrappedKey,
+		ChunkSize:  encryption.ChunkSize,
+	}
+
+	// access can see only ciphertext, calculate checksums of plaintext in client
+	expected, _ := args.ExpectedHashSumMap()
+	hashSumMap := (args.Hashes | expected.ToHashAlgorithm()).ToHashSumMap()
+	hasherMap := make(HasherMap, len(hashSumMap))
+	for alg := range hashSumMap {
+		hasherMap[alg] = alg.ToHasher()
+	}
+	reqBody := args.Body
+	if len(hasherMap) > 0 {
+		reqBody = io.TeeReader(args.Body, hasherMap.ToWriter())
+	}
+
+	sealedArgs := &PutArgs{
+		Size:   int64(envelope.CipherSize(uint64(args.Size))),
+		Expiry: args.Expiry,
+		Body:   newEncryptReader(aead, reqBody, uint64(args.Size), envelope.ChunkSize),
+	}
+
+	var loc Location
+	if sealedArgs.Size <= c.config.MaxSizePutOnce {
+		loc, _, err = c.putObject(ctx, sealedArgs)
+	} else {
+		loc, _, err = c.putParts(ctx, sealedArgs)
+	}
+	if err != nil {
+		return Location{}, nil, err
+	}
+
+	for alg, hasher := range hasherMap {
+		hashSumMap[alg] = hasher.Sum(nil)
+	}
+	if !hashSumMap.Verify(expected) {
+		span.Errorf("checksum mismatch expected:%+v actual:%+v", expected.All(), hashSumMap.All())
+		if _, err := c.Delete(ctx, &DeleteArgs{Locations: []Location{loc}}); err != nil {
+			span.Warnf("clean location '%+v' failed %s", loc, err.Error())
+		}
+		return Location{}, nil, errcode.ErrAccessChecksumMismatch
+	}
+
+	loc.Envelope = &envelope
+	return loc, hashSumMap, nil
+}
+
+func (c *client) getEncrypted(ctx context.Context, args *GetArgs) (io.ReadCloser, error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	loc := args.Location.Copy()
+	envelope := loc.Envelope
+	if !envelope.IsValid() || c.config.Encryption == nil ||
+		args.Offset+args.ReadSize > loc.DataSize() {
+		return nil, errcode.ErrIllegalArguments
+	}
+	if args.ReadSize == 0 {
+		return noopBody{}, nil
+	}
+
+	dataKey, err := c.config.Encryption.KeyProvider.DecryptDataKey(ctx, envelope.KeyID, envelope.WrappedKey)
+	if err != nil {
+		span.Error("decrypt data key", err)
+		return nil, errcode.ErrUnexpected
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		span.Error("new data key cipher", err)
+		return nil, errcode.ErrUnexpected
+	}
+
+	// read the aligned sealed chunks
+	chunkSize := uint64(envelope.ChunkSize)
+	sealedChunkSize := chunkSize + uint64(aead.Overhead())
+	firstChunk := args.Offset / chunkSize
+	lastChunk := (args.Offset + args.ReadSize - 1) / chunkSize
+	offset := firstChunk * sealedChunkSize
+	end := (lastChunk + 1) * sealedChunkSize
+	if end > loc.Size {
+		end = loc.Size
+	}
+
+	loc.Envelope = nil
+	body, err := c.get(ctx, &GetArgs{Location: loc, Offset: offset, ReadSize: end - offset})
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		aead:   aead,
+		body:   body,
+		index:  firstChunk,
+		skip:   args.Offset - firstChunk*chunkSize,
+		remain: args.ReadSize,
+		nonce:  make([]byte, aead.NonceSize()),
+		sealed: make([]byte, sealedChunkSize),
+	}, nil
+}
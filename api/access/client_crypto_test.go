@@ -0,0 +1,188 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"hash/crc32"
+	"io/ioutil"
+	mrand "math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	errcode "github.com/cubefs/blobstore/common/errors"
+)
+
+func newEncryptionClient(t *testing.T, chunkSize uint32) access.API {
+	provider, err := access.NewFileKeyProvider(newKeyFile(t, "key-1 "+testMasterKey1))
+	require.NoError(t, err)
+
+	cfg := access.Config{}
+	cfg.Consul.Address = mockServer.URL[7:]
+	cfg.PriorityAddrs = []string{mockServer.URL}
+	cfg.MaxSizePutOnce = 1 << 20
+	cfg.PartConcurrence = 2
+	cfg.Encryption = &access.EncryptionConfig{
+		KeyProvider: provider,
+		ChunkSize:   chunkSize,
+	}
+	cli, err := access.New(cfg)
+	require.NoError(t, err)
+	return cli
+}
+
+func TestAccessClientEncryptionConfig(t *testing.T) {
+	cfg := access.Config{Encryption: &access.EncryptionConfig{}}
+	cfg.Consul.Address = mockServer.URL[7:]
+	_, err := access.New(cfg)
+	require.ErrorIs(t, errcode.ErrIllegalArguments, err)
+}
+
+func TestAccessClientEncryptionPutGet(t *testing.T) {
+	for _, chunkSize := range []uint32{0, 1 << 10, 1000} {
+		cli := newEncryptionClient(t, chunkSize)
+
+		for _, size := range []int{0, 1, 1 << 10, 1<<10 + 1, 1<<20 - 1, 1<<21 + 1023} {
+			dataCache.clean()
+
+			buff := make([]byte, size)
+			rand.Read(buff)
+			loc, hashSumMap, err := cli.Put(randCtx(), &access.PutArgs{
+				Size:   int64(size),
+				Hashes: access.HashAlgCRC32,
+				Body:   bytes.NewReader(buff),
+			})
+			require.NoError(t, err)
+			require.Equal(t, crc32.ChecksumIEEE(buff), hashSumMap.GetSumVal(access.HashAlgCRC32))
+			require.Equal(t, uint64(size), loc.DataSize())
+			if size == 0 {
+				continue
+			}
+
+			require.NotNil(t, loc.Envelope)
+			require.Less(t, uint64(size), loc.Size)
+			if chunkSize == 0 {
+				require.Equal(t, access.DefaultEnvelopeChunkSize, loc.Envelope.ChunkSize)
+			}
+
+			// encoded location
+			decoded, err := access.DecodeLocationFromHex(loc.HexString())
+			require.NoError(t, err)
+			require.Equal(t, loc, decoded)
+
+			// stored ciphertext
+			sealedLoc := decoded.Copy()
+			sealedLoc.Envelope = nil
+			stored, err := client.Get(randCtx(), &access.GetArgs{Location: sealedLoc, ReadSize: sealedLoc.Size})
+			require.NoError(t, err)
+			sealed, err := ioutil.ReadAll(stored)
+			require.NoError(t, err)
+			stored.Close()
+			require.Equal(t, int(loc.Size), len(sealed))
+			if size >= 16 {
+				require.False(t, bytes.Contains(sealed, buff[:16]))
+			}
+
+			// whole object and ranges
+			ranges := [][2]int{{0, size}, {0, 1}, {size - 1, 1}, {size, 0}}
+			for ii := 0; ii < 10; ii++ {
+				offset := mrand.Intn(size)
+				ranges = append(ranges, [2]int{offset, mrand.Intn(size - offset + 1)})
+			}
+			for _, r := range ranges {
+				body, err := cli.Get(randCtx(), &access.GetArgs{
+					Location: decoded,
+					Offset:   uint64(r[0]),
+					ReadSize: uint64(r[1]),
+				})
+				require.NoError(t, err)
+				data, err := ioutil.ReadAll(body)
+				require.NoError(t, err)
+				body.Close()
+				require.Equal(t, buff[r[0]:r[0]+r[1]], data, "range %v", r)
+			}
+
+			_, err = cli.Get(randCtx(), &access.GetArgs{Location: decoded, Offset: uint64(size), ReadSize: 1})
+			require.ErrorIs(t, errcode.ErrIllegalArguments, err)
+			// cannot be read without key provider
+			_, err = client.Get(randCtx(), &access.GetArgs{Location: decoded, ReadSize: 1})
+			require.ErrorIs(t, errcode.ErrIllegalArguments, err)
+		}
+	}
+}
+
+func TestAccessClientEncryptionBroken(t *testing.T) {
+	cli := newEncryptionClient(t, 1<<10)
+	dataCache.clean()
+
+	size := 1 << 12
+	buff := make([]byte, size)
+	rand.Read(buff)
+	loc, _, err := cli.Put(randCtx(), &access.PutArgs{Size: int64(size), Body: bytes.NewReader(buff)})
+	require.NoError(t, err)
+
+	// broken ciphertext
+	stored := dataCache.get(0)
+	stored[len(stored)/2]++
+	body, err := cli.Get(randCtx(), &access.GetArgs{Location: loc, ReadSize: uint64(size)})
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(body)
+	require.Error(t, err)
+	body.Close()
+	stored[len(stored)/2]--
+
+	// first chunk is ok
+	body, err = cli.Get(randCtx(), &access.GetArgs{Location: loc, ReadSize: 1 << 10})
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	require.Equal(t, buff[:1<<10], data)
+
+	// broken wrapped key
+	broken := loc.Copy()
+	broken.Envelope.WrappedKey[0]++
+	_, err = cli.Get(randCtx(), &access.GetArgs{Location: broken, ReadSize: 1})
+	require.ErrorIs(t, errcode.ErrUnexpected, err)
+}
+
+func TestAccessClientEncryptionChecksum(t *testing.T) {
+	cli := newEncryptionClient(t, 0)
+
+	for _, size := range []int{1 << 10, 1<<21 + 1023} {
+		dataCache.clean()
+
+		buff := make([]byte, size)
+		rand.Read(buff)
+		md5Sum := md5.Sum(buff)
+		args := access.PutArgs{
+			Size:        int64(size),
+			ExpectedMD5: hex.EncodeToString(md5Sum[:]),
+			Body:        bytes.NewReader(buff),
+		}
+		_, hashSumMap, err := cli.Put(randCtx(), &args)
+		require.NoError(t, err)
+		require.Equal(t, md5Sum[:], hashSumMap[access.HashAlgMD5])
+
+		buff[0]++
+		args.Body = bytes.NewReader(buff)
+		_, _, err = cli.Put(randCtx(), &args)
+		require.ErrorIs(t, errcode.ErrAccessChecksumMismatch, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	errcode "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// MultipartUpload handle of a multipart upload
+// Host is the access host which the upload was initiated on,
+// the upload is kept in that host, all parts MUST be put into it.
+// The handle can be saved by yourself to resume the upload.
+type MultipartUpload struct {
+	Host       string `json:"host"`
+	UploadID   string `json:"upload_id"`
+	Size       uint64 `json:"size"`
+	PartSize   uint32 `json:"part_size"`
+	PartCount  uint32 `json:"part_count"`
+	Expiration int64  `json:"expiration"`
+}
+
+// PartSizeOf returns size of the part index
+func (u *MultipartUpload) PartSizeOf(index uint32) int64 {
+	if index >= u.PartCount {
+		return 0
+	}
+	if index == u.PartCount-1 {
+		if lastSize := u.Size % uint64(u.PartSize); lastSize > 0 {
+			return int64(lastSize)
+		}
+	}
+	return int64(u.PartSize)
+}
+
+func (u *MultipartUpload) isValid() bool {
+	return u != nil && u.Host != "" && u.UploadID != ""
+}
+
+// tryOnDelayMs delay of the first retry, increases with the times of try
+const tryOnDelayMs = 100
+
+// tryOn retry on the host of the upload only if the access node is reachable,
+// n is the max try times, default if not positive, returns once ctx is done
+func (c *client) tryOn(ctx context.Context, host string, n int, connector func(string) error) error {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if n <= 0 {
+		n = defaultMaxPartRetry
+	}
+	for ii := 1; ; ii++ {
+		err := connector(host)
+		if err == nil {
+			return nil
+		}
+		// has connected access node, only 500 need to retry
+		if httpErr, ok := err.(rpc.HTTPError); ok &&
+			httpErr.StatusCode() != http.StatusInternalServerError {
+			return err
+		}
+		if ii >= n {
+			span.Error("exceed the max retry limit", n, "failed on", host, err)
+			return err
+		}
+		span.Warnf("the %dth try on %s failed %s", ii, host, err.Error())
+
+		timer := time.NewTimer(time.Duration(ii*tryOnDelayMs) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			span.Warnf("context done on %s, last error %s", host, err.Error())
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *client) MultipartInit(ctx context.Context, args *MultipartInitArgs) (upload MultipartUpload, err error) {
+	if !args.IsValid() {
+		return upload, errcode.ErrIllegalArguments
+	}
+
+	ctx = withReqidContext(ctx)
+	err = c.tryN(ctx, c.config.MaxHostRetry, func(host string) error {
+		resp := &MultipartInitResp{}
+		if e := c.rpcClient.PostWith(ctx, fmt.Sprintf("%s/multipart/init", host), resp, args); e != nil {
+			return e
+		}
+		upload = MultipartUpload{
+			Host:       host,
+			UploadID:   resp.UploadID,
+			Size:       resp.Size,
+			PartSize:   resp.PartSize,
+			PartCount:  resp.PartCount,
+			Expiration: resp.Expiration,
+		}
+		return nil
+	})
+	return
+}
+
+func (c *client) MultipartPut(ctx context.Context, upload *MultipartUpload, args *MultipartPutArgs) (HashSumMap, error) {
+	if !upload.isValid() || args == nil {
+		return nil, errcode.ErrIllegalArguments
+	}
+	args.UploadID = upload.UploadID
+	if !args.IsValid() {
+		return nil, errcode.ErrIllegalArguments
+	}
+	if args.Index >= upload.PartCount || args.Size != upload.PartSizeOf(args.Index) {
+		return nil, errcode.ErrIllegalArguments
+	}
+
+	ctx = withReqidContext(ctx)
+	span := trace.SpanFromContextSafe(ctx)
+
+	// cache the part to retry putting
+	buffer, _ := memPool.Alloc(int(args.Size))
+	buffer = buffer[:args.Size]
+	defer memPool.Put(buffer)
+	if _, err := io.ReadFull(args.Body, buffer); err != nil {
+		span.Error("read buffer from request", err)
+		return nil, errcode.ErrAccessReadRequestBody
+	}
+
+	var hashSumMap HashSumMap
+	err := c.tryOn(ctx, upload.Host, c.config.MaxPartRetry, func(host string) error {
+		urlStr := fmt.Sprintf("%s/multipart/put?uploadid=%s&index=%d&size=%d&hashes=%d",
+			host, upload.UploadID, args.Index, args.Size, args.Hashes)
+		req, e := http.NewRequest(http.MethodPut, urlStr, bytes.NewReader(buffer))
+		if e != nil {
+			return e
+		}
+
+		resp := &MultipartPutResp{}
+		if e = c.rpcClient.DoWith(ctx, req, resp, rpc.WithCrcEncode()); e != nil {
+			return e
+		}
+		hashSumMap = resp.HashSumMap
+		return nil
+	})
+	return hashSumMap, err
+}
+
+func (c *client) MultipartComplete(ctx context.Context, upload *MultipartUpload) (location Location, err error) {
+	if !upload.isValid() {
+		return location, errcode.ErrIllegalArguments
+	}
+
+	ctx = withReqidContext(ctx)
+	err = c.tryOn(ctx, upload.Host, c.config.MaxHostRetry, func(host string) error {
+		resp := &MultipartCompleteResp{}
+		if e := c.rpcClient.PostWith(ctx, fmt.Sprintf("%s/multipart/complete", host), resp,
+			MultipartArgs{UploadID: upload.UploadID}); e != nil {
+			return e
+		}
+		location = resp.Location
+		return nil
+	})
+	return
+}
+
+func (c *client) MultipartAbort(ctx context.Context, upload *MultipartUpload) error {
+	if !upload.isValid() {
+		return errcode.ErrIllegalArguments
+	}
+
+	ctx = withReqidContext(ctx)
+	return c.tryOn(ctx, upload.Host, c.config.MaxHostRetry, func(host string) error {
+		return c.rpcClient.PostWith(ctx, fmt.Sprintf("%s/multipart/abort", host), nil,
+			MultipartArgs{UploadID: upload.UploadID})
+	})
+}
+
+func (c *client) MultipartList(ctx context.Context, upload *MultipartUpload) (uploaded []uint32, err error) {
+	if !upload.isValid() {
+		return nil, errcode.ErrIllegalArguments
+	}
+
+	ctx = withReqidContext(ctx)
+	err = c.tryOn(ctx, upload.Host, c.config.MaxHostRetry, func(host string) error {
+		resp := &MultipartListResp{}
+		if e := c.rpcClient.GetWith(ctx, fmt.Sprintf("%s/multipart/list?upload_id=%s",
+			host, upload.UploadID), resp); e != nil {
+			return e
+		}
+		uploaded = resp.Uploaded
+		return nil
+	})
+	return
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/common/crc32block"
+	"github.com/cubefs/blobstore/common/rpc"
+)
+
+const (
+	multipartUploadID = "upload-id"
+	multipartPartSize = 1 << 10
+)
+
+func newMultipartServer() (*httptest.Server, *int) {
+	var (
+		mu       sync.Mutex
+		size     uint64
+		count    uint32
+		uploaded map[uint32]bool
+		brokenN  = 1
+	)
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		b, _ := json.Marshal(v)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch req.URL.Path {
+		case "/multipart/init":
+			args := access.MultipartInitArgs{}
+			requestBody(req, &args)
+			size = args.Size
+			count = uint32((size + multipartPartSize - 1) / multipartPartSize)
+			uploaded = make(map[uint32]bool)
+			writeJSON(w, access.MultipartInitResp{
+				UploadID:  multipartUploadID,
+				Size:      size,
+				PartSize:  multipartPartSize,
+				PartCount: count,
+			})
+
+		case "/multipart/put":
+			w.Header().Set(rpc.HeaderAckCrcEncoded, "1")
+			query := req.URL.Query()
+			if query.Get("uploadid") != multipartUploadID {
+				w.WriteHeader(554)
+				return
+			}
+			index, _ := strconv.Atoi(query.Get("index"))
+			if index == 1 && brokenN > 0 {
+				brokenN--
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			l, _ := strconv.Atoi(req.Header.Get("Content-Length"))
+			decoder := crc32block.NewBodyDecoder(req.Body)
+			defer decoder.Close()
+			buf := make([]byte, decoder.CodeSize(int64(l)))
+			if _, err := io.ReadFull(decoder, buf); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			uploaded[uint32(index)] = true
+
+			algsInt, _ := strconv.Atoi(query.Get("hashes"))
+			hashSumMap := access.HashAlgorithm(algsInt).ToHashSumMap()
+			for alg := range hashSumMap {
+				hasher := alg.ToHasher()
+				hasher.Write(buf)
+				hashSumMap[alg] = hasher.Sum(nil)
+			}
+			writeJSON(w, access.MultipartPutResp{HashSumMap: hashSumMap})
+
+		case "/multipart/list":
+			if req.URL.Query().Get("upload_id") != multipartUploadID {
+				w.WriteHeader(554)
+				return
+			}
+			resp := access.MultipartListResp{UploadID: multipartUploadID, PartCount: count}
+			for index := range uploaded {
+				resp.Uploaded = append(resp.Uploaded, index)
+			}
+			sort.Slice(resp.Uploaded, func(i, j int) bool { return resp.Uploaded[i] < resp.Uploaded[j] })
+			writeJSON(w, resp)
+
+		case "/multipart/complete":
+			if uint32(len(uploaded)) != count {
+				w.WriteHeader(555)
+				return
+			}
+			loc := access.Location{Size: size}
+			fillCrc(&loc)
+			writeJSON(w, access.MultipartCompleteResp{Location: loc})
+
+		case "/multipart/abort":
+			uploaded = make(map[uint32]bool)
+			w.WriteHeader(http.StatusOK)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &brokenN
+}
+
+func TestAccessClientMultipart(t *testing.T) {
+	server, brokenN := newMultipartServer()
+	defer server.Close()
+
+	cfg := access.Config{}
+	cfg.PriorityAddrs = []string{server.URL}
+	cfg.Consul.Address = mockServer.URL[7:]
+	cli, err := access.New(cfg)
+	require.NoError(t, err)
+
+	_, err = cli.MultipartInit(randCtx(), &access.MultipartInitArgs{})
+	require.Error(t, err)
+
+	size := uint64(multipartPartSize*2 + 100)
+	upload, err := cli.MultipartInit(randCtx(), &access.MultipartInitArgs{Size: size, PartSize: multipartPartSize})
+	require.NoError(t, err)
+	require.Equal(t, server.URL, upload.Host)
+	require.Equal(t, uint32(3), upload.PartCount)
+	require.Equal(t, int64(multipartPartSize), upload.PartSizeOf(0))
+	require.Equal(t, int64(100), upload.PartSizeOf(2))
+	require.Equal(t, int64(0), upload.PartSizeOf(3))
+
+	putPart := func(index uint32, size int64) error {
+		_, err := cli.MultipartPut(randCtx(), &upload, &access.MultipartPutArgs{
+			Index:  index,
+			Size:   size,
+			Hashes: access.HashAlgMD5,
+			Body:   bytes.NewReader(make([]byte, size)),
+		})
+		return err
+	}
+	require.Error(t, putPart(3, 100))
+	require.Error(t, putPart(2, multipartPartSize))
+	require.NoError(t, putPart(0, multipartPartSize))
+
+	_, err = cli.MultipartComplete(randCtx(), &upload)
+	require.Equal(t, 555, rpc.DetectStatusCode(err))
+
+	// retry on the pinned host
+	require.NoError(t, putPart(1, multipartPartSize))
+	require.Equal(t, 0, *brokenN)
+
+	uploaded, err := cli.MultipartList(randCtx(), &upload)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{0, 1}, uploaded)
+
+	require.NoError(t, putPart(2, 100))
+	loc, err := cli.MultipartComplete(randCtx(), &upload)
+	require.NoError(t, err)
+	require.Equal(t, size, loc.Size)
+
+	require.NoError(t, cli.MultipartAbort(randCtx(), &upload))
+	require.Error(t, cli.MultipartAbort(randCtx(), &access.MultipartUpload{}))
+
+	invalid := upload
+	invalid.UploadID = "not-exist"
+	_, err = cli.MultipartList(randCtx(), &invalid)
+	require.Equal(t, 554, rpc.DetectStatusCode(err))
+
+	// unreachable host is retried in limited times
+	unreachable := upload
+	unreachable.Host = "http://127.0.0.1:1"
+	_, err = cli.MultipartList(randCtx(), &unreachable)
+	require.Error(t, err)
+
+	// returns once context canceled
+	ctx, cancel := context.WithCancel(randCtx())
+	cancel()
+	start := time.Now()
+	_, err = cli.MultipartList(ctx, &unreachable)
+	require.Error(t, err)
+	require.True(t, time.Since(start) < time.Second)
+}
@@ -17,7 +17,10 @@ package access_test
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"hash/crc32"
@@ -126,13 +129,10 @@ func init() {
 				size := req.Header.Get("Content-Length")
 				l, _ := strconv.Atoi(size)
 
-				w.WriteHeader(http.StatusOK)
-
 				decoder := crc32block.NewBodyDecoder(req.Body)
 				defer decoder.Close()
 				buf := make([]byte, decoder.CodeSize(int64(l)))
 				io.ReadFull(decoder, buf)
-				dataCache.put(0, buf)
 
 				hashesStr := req.URL.Query().Get("hashes")
 				algsInt, _ := strconv.Atoi(hashesStr)
@@ -144,8 +144,24 @@ func init() {
 					hasher.Write(buf)
 					hashSumMap[alg] = hasher.Sum(nil)
 				}
+				if expected := req.URL.Query().Get("expected_crc32"); expected != "" {
+					b, _ := hex.DecodeString(expected)
+					if crc32.ChecksumIEEE(buf) != binary.BigEndian.Uint32(b) {
+						w.WriteHeader(errcode.CodeAccessChecksumMismatch)
+						return
+					}
+				}
+				if expiry := req.URL.Query().Get("expiry"); expiry != "" {
+					unix, _ := strconv.ParseInt(expiry, 10, 64)
+					if unix <= time.Now().Unix() {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+				}
+				dataCache.put(0, buf)
+				w.WriteHeader(http.StatusOK)
 
-				loc := access.Location{Size: uint64(l)}
+				loc := access.Location{Size: uint64(len(buf))}
 				fillCrc(&loc)
 				resp := access.PutResp{
 					Location:   loc,
@@ -162,15 +178,20 @@ func init() {
 					return
 				}
 
-				w.Header().Set("Content-Length", strconv.Itoa(int(args.Location.Size)))
-				w.WriteHeader(http.StatusOK)
-				if buf := dataCache.get(0); len(buf) > 0 {
-					w.Write(buf)
-				} else {
+				buf := dataCache.get(0)
+				if len(buf) == 0 {
 					for _, blob := range args.Location.Spread() {
-						w.Write(dataCache.get(blob.Bid))
+						buf = append(buf, dataCache.get(blob.Bid)...)
 					}
 				}
+				if args.Offset+args.ReadSize > uint64(len(buf)) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				w.Header().Set("Content-Length", strconv.Itoa(int(args.ReadSize)))
+				w.WriteHeader(http.StatusOK)
+				w.Write(buf[args.Offset : args.Offset+args.ReadSize])
 
 			} else if req.URL.Path == "/alloc" {
 				args := access.AllocArgs{}
@@ -605,6 +626,68 @@ func TestAccessClientPutAtBase(t *testing.T) {
 	}
 }
 
+func TestAccessClientPutExpectedChecksum(t *testing.T) {
+	args := access.PutArgs{Size: 1, ExpectedMD5: "xxx"}
+	_, _, err := client.Put(randCtx(), &args)
+	require.ErrorIs(t, errcode.ErrIllegalArguments, err)
+
+	emptyCrc := make([]byte, 4)
+	binary.BigEndian.PutUint32(emptyCrc, crc32.ChecksumIEEE(nil))
+	args = access.PutArgs{Size: 0, ExpectedCRC32: hex.EncodeToString(emptyCrc)}
+	_, _, err = client.Put(randCtx(), &args)
+	require.NoError(t, err)
+	args = access.PutArgs{Size: 0, ExpectedCRC32: "0a0b0c0d"}
+	_, _, err = client.Put(randCtx(), &args)
+	require.ErrorIs(t, errcode.ErrAccessChecksumMismatch, err)
+
+	for _, size := range []int{1 << 10, 1 << 20, 1<<21 + 1023} {
+		dataCache.clean()
+
+		buff := make([]byte, size)
+		rand.Read(buff)
+		crcSum := make([]byte, 4)
+		binary.BigEndian.PutUint32(crcSum, crc32.ChecksumIEEE(buff))
+		md5Sum := md5.Sum(buff)
+
+		args := access.PutArgs{
+			Size:          int64(size),
+			Hashes:        access.HashAlgCRC32,
+			ExpectedCRC32: hex.EncodeToString(crcSum),
+			ExpectedMD5:   hex.EncodeToString(md5Sum[:]),
+			Body:          bytes.NewBuffer(buff),
+		}
+		_, hashSumMap, err := client.Put(randCtx(), &args)
+		require.NoError(t, err)
+		require.Equal(t, crc32.ChecksumIEEE(buff), hashSumMap.GetSumVal(access.HashAlgCRC32))
+
+		buff[0]++
+		args.Body = bytes.NewBuffer(buff)
+		_, _, err = client.Put(randCtx(), &args)
+		require.Equal(t, errcode.CodeAccessChecksumMismatch, rpc.DetectStatusCode(err))
+	}
+}
+
+func TestAccessClientPutExpiry(t *testing.T) {
+	args := access.PutArgs{Size: 1, Expiry: -1}
+	_, _, err := client.Put(randCtx(), &args)
+	require.ErrorIs(t, errcode.ErrIllegalArguments, err)
+
+	buff := make([]byte, 1<<10)
+	rand.Read(buff)
+	args = access.PutArgs{
+		Size:   int64(len(buff)),
+		Expiry: time.Now().Add(time.Hour).Unix(),
+		Body:   bytes.NewBuffer(buff),
+	}
+	_, _, err = client.Put(randCtx(), &args)
+	require.NoError(t, err)
+
+	args.Expiry = time.Now().Add(-time.Hour).Unix()
+	args.Body = bytes.NewBuffer(buff)
+	_, _, err = client.Put(randCtx(), &args)
+	require.Equal(t, http.StatusBadRequest, rpc.DetectStatusCode(err))
+}
+
 func TestAccessClientPutAtMerge(t *testing.T) {
 	cfg := access.Config{}
 	cfg.Consul.Address = mockServer.URL[7:]
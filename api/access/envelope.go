@@ -0,0 +1,188 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	// EnvelopeOverhead overhead bytes of every sealed chunk, the AES-GCM tag
+	EnvelopeOverhead = 16
+	// DefaultEnvelopeChunkSize default plaintext size of sealed chunk
+	DefaultEnvelopeChunkSize uint32 = 1 << 16
+
+	envelopeVersion byte = 1
+)
+
+// DO NOT CHANGE IT.
+var envelopeMagic = [2]byte{0xe5, 0x7e}
+
+// Envelope encryption envelope of location
+// The data was sealed chunk by chunk with AES-GCM by a data key,
+// the data key is wrapped by the master key of a KeyProvider.
+//
+// KeyID is id of the master key which wrapped the data key
+// WrappedKey is the wrapped data key
+// ChunkSize is plaintext size of every sealed chunk but the last one,
+// every sealed chunk is EnvelopeOverhead bytes larger than its plaintext.
+type Envelope struct {
+	_          [0]byte
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	ChunkSize  uint32 `json:"chunk_size"`
+}
+
+// Copy returns a new same Envelope
+func (e *Envelope) Copy() Envelope {
+	dst := Envelope{
+		KeyID:      e.KeyID,
+		WrappedKey: make([]byte, len(e.WrappedKey)),
+		ChunkSize:  e.ChunkSize,
+	}
+	copy(dst.WrappedKey, e.WrappedKey)
+	return dst
+}
+
+// IsValid is valid envelope
+func (e *Envelope) IsValid() bool {
+	return e != nil && e.ChunkSize > 0 && len(e.WrappedKey) > 0
+}
+
+// CipherSize returns sealed size of the plaintext size
+func (e *Envelope) CipherSize(plainSize uint64) uint64 {
+	chunks := (plainSize + uint64(e.ChunkSize) - 1) / uint64(e.ChunkSize)
+	return plainSize + chunks*EnvelopeOverhead
+}
+
+// PlainSize returns plaintext size of the sealed size
+func (e *Envelope) PlainSize(cipherSize uint64) uint64 {
+	sealedChunkSize := uint64(e.ChunkSize) + EnvelopeOverhead
+	plainSize := cipherSize / sealedChunkSize * uint64(e.ChunkSize)
+	if rest := cipherSize % sealedChunkSize; rest > EnvelopeOverhead {
+		plainSize += rest - EnvelopeOverhead
+	}
+	return plainSize
+}
+
+// encodedSize returns max size of encoded envelope
+func (e *Envelope) encodedSize() int {
+	return 2 + 1 + 5 + 5 + len(e.KeyID) + 5 + len(e.WrappedKey) + 4
+}
+
+// encode transfer Envelope to the buf, the location is encoded in prefix
+// Returns the number of bytes written
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+//  | magic | version | chunksize  |  len(keyid)  | keyid |  len(key)  | key | crc |
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+//  |   2   |    1    | uvarint(5) |  uvarint(5)  |   n   | uvarint(5) |  n  |  4  |
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// crc is crc32 IEEE of the encoded location and the envelope before crc,
+// so the wrapped key cannot be changed or bound to another location.
+func (e *Envelope) encode(prefix, buf []byte) int {
+	n := copy(buf, envelopeMagic[:])
+	buf[n] = envelopeVersion
+	n++
+	n += binary.PutUvarint(buf[n:], uint64(e.ChunkSize))
+	n += binary.PutUvarint(buf[n:], uint64(len(e.KeyID)))
+	n += copy(buf[n:], e.KeyID)
+	n += binary.PutUvarint(buf[n:], uint64(len(e.WrappedKey)))
+	n += copy(buf[n:], e.WrappedKey)
+
+	crc := crc32.NewIEEE()
+	crc.Write(prefix)
+	crc.Write(buf[:n])
+	binary.BigEndian.PutUint32(buf[n:], crc.Sum32())
+	return n + 4
+}
+
+func hasEnvelope(buf []byte) bool {
+	return len(buf) >= len(envelopeMagic) &&
+		buf[0] == envelopeMagic[0] && buf[1] == envelopeMagic[1]
+}
+
+// decodeEnvelope parse envelope from buf, the location is encoded in prefix
+// Returns Envelope and the number of bytes read
+func decodeEnvelope(prefix, buf []byte) (Envelope, int, error) {
+	var (
+		e   Envelope
+		n   int
+		val uint64
+		nn  int
+	)
+	next := func() (uint64, int) {
+		val, nn := binary.Uvarint(buf[n:])
+		if nn <= 0 {
+			return 0, nn
+		}
+		n += nn
+		return val, nn
+	}
+	nextBytes := func(length uint64) ([]byte, bool) {
+		if uint64(len(buf)-n) < length {
+			return nil, false
+		}
+		b := buf[n : n+int(length)]
+		n += int(length)
+		return b, true
+	}
+
+	if len(buf) < len(envelopeMagic)+1 || !hasEnvelope(buf) {
+		return e, n, fmt.Errorf("bytes envelope magic %d", len(buf))
+	}
+	n += len(envelopeMagic)
+	if buf[n] != envelopeVersion {
+		return e, n, fmt.Errorf("envelope version %d", buf[n])
+	}
+	n++
+
+	if val, nn = next(); nn <= 0 {
+		return e, n, fmt.Errorf("bytes envelope chunk_size %d", nn)
+	}
+	e.ChunkSize = uint32(val)
+
+	if val, nn = next(); nn <= 0 {
+		return e, n, fmt.Errorf("bytes envelope length key_id %d", nn)
+	}
+	keyID, ok := nextBytes(val)
+	if !ok {
+		return e, n, fmt.Errorf("bytes envelope key_id %d", val)
+	}
+	e.KeyID = string(keyID)
+
+	if val, nn = next(); nn <= 0 {
+		return e, n, fmt.Errorf("bytes envelope length wrapped_key %d", nn)
+	}
+	wrappedKey, ok := nextBytes(val)
+	if !ok {
+		return e, n, fmt.Errorf("bytes envelope wrapped_key %d", val)
+	}
+	e.WrappedKey = append([]byte{}, wrappedKey...)
+
+	if len(buf)-n < 4 {
+		return e, n, fmt.Errorf("bytes envelope crc %d", len(buf)-n)
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(prefix)
+	crc.Write(buf[:n])
+	if actual := binary.BigEndian.Uint32(buf[n:]); actual != crc.Sum32() {
+		return e, n, fmt.Errorf("envelope crc mismatch %d != %d", actual, crc.Sum32())
+	}
+	n += 4
+
+	return e, n, nil
+}
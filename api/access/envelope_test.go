@@ -0,0 +1,148 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func newEnvelopeLocation() access.Location {
+	return access.Location{
+		ClusterID: 1,
+		CodeMode:  1,
+		Size:      1 << 20,
+		BlobSize:  1 << 22,
+		Crc:       0xabcdef,
+		Blobs: []access.SliceInfo{
+			{MinBid: 100, Vid: 10, Count: 1},
+		},
+		Envelope: &access.Envelope{
+			KeyID:      "key-1",
+			WrappedKey: []byte("wrapped-data-key-wrapped-data-key"),
+			ChunkSize:  access.DefaultEnvelopeChunkSize,
+		},
+	}
+}
+
+func TestEnvelopeSize(t *testing.T) {
+	e := access.Envelope{ChunkSize: 100}
+	cases := []struct {
+		plain, cipher uint64
+	}{
+		{0, 0},
+		{1, 1 + 16},
+		{99, 99 + 16},
+		{100, 100 + 16},
+		{101, 101 + 32},
+		{1000, 1000 + 160},
+		{1001, 1001 + 176},
+	}
+	for _, cs := range cases {
+		require.Equal(t, cs.cipher, e.CipherSize(cs.plain))
+		require.Equal(t, cs.plain, e.PlainSize(cs.cipher))
+	}
+
+	loc := newEnvelopeLocation()
+	loc.Size = loc.Envelope.CipherSize(12345)
+	require.Equal(t, uint64(12345), loc.DataSize())
+	loc.Envelope = nil
+	require.Equal(t, loc.Size, loc.DataSize())
+}
+
+func TestEnvelopeLocationEncodeDecode(t *testing.T) {
+	loc := newEnvelopeLocation()
+
+	base := loc.Copy()
+	base.Envelope = nil
+	baseBuf := base.Encode()
+
+	buf := loc.Encode()
+	require.Equal(t, baseBuf, buf[:len(baseBuf)])
+	require.Equal(t, len(baseBuf), loc.Encode2(make([]byte, 1024)))
+
+	dloc, n, err := access.DecodeLocation(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, loc, dloc)
+
+	copied := loc.Copy()
+	require.Equal(t, loc, copied)
+	copied.Envelope.WrappedKey[0]++
+	require.NotEqual(t, loc.Envelope.WrappedKey, copied.Envelope.WrappedKey)
+
+	hexLoc, err := access.DecodeLocationFromHex(loc.HexString())
+	require.NoError(t, err)
+	require.Equal(t, loc, hexLoc)
+	b64Loc, err := access.DecodeLocationFromBase64(loc.Base64String())
+	require.NoError(t, err)
+	require.Equal(t, loc, b64Loc)
+
+	b, err := json.Marshal(loc)
+	require.NoError(t, err)
+	var jsonLoc access.Location
+	require.NoError(t, json.Unmarshal(b, &jsonLoc))
+	require.Equal(t, loc, jsonLoc)
+
+	// without envelope
+	dloc, n, err = access.DecodeLocation(baseBuf)
+	require.NoError(t, err)
+	require.Equal(t, len(baseBuf), n)
+	require.Nil(t, dloc.Envelope)
+	b, _ = json.Marshal(base)
+	require.NotContains(t, string(b), "envelope")
+}
+
+func TestEnvelopeLocationDecodeError(t *testing.T) {
+	loc := newEnvelopeLocation()
+	buf := loc.Encode()
+	baseLen := len(buf) - (2 + 1 + 3 + 1 + len(loc.Envelope.KeyID) + 1 + len(loc.Envelope.WrappedKey) + 4)
+
+	// truncated
+	for n := baseLen + 2; n < len(buf); n++ {
+		_, _, err := access.DecodeLocation(buf[:n])
+		require.Error(t, err)
+	}
+
+	// any byte changed
+	for idx := range buf {
+		changed := append([]byte{}, buf...)
+		changed[idx] ^= 0x5a
+		dloc, _, err := access.DecodeLocation(changed)
+		if err == nil {
+			require.NotEqual(t, loc, dloc)
+			require.Nil(t, dloc.Envelope, "changed at %d", idx)
+		}
+	}
+
+	// bound to another location
+	other := loc.Copy()
+	other.Blobs[0].MinBid = proto.BlobID(101)
+	otherBuf := other.Encode()
+	forged := append(otherBuf[:baseLen:baseLen], buf[baseLen:]...)
+	_, _, err := access.DecodeLocation(forged)
+	require.Error(t, err)
+
+	// version
+	changed := append([]byte{}, buf...)
+	changed[baseLen+2] = 0xff
+	_, _, err = access.DecodeLocation(changed)
+	require.Error(t, err)
+}
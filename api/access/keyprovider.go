@@ -0,0 +1,141 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// DataKeySize size of data key, AES-256
+const DataKeySize = 32
+
+// KeyProvider provides data keys for envelope encryption,
+// a data key is wrapped by the master key of the provider.
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key and the wrapped one,
+	// keyID is id of the master key which wrapped the data key.
+	GenerateDataKey(ctx context.Context) (keyID string, dataKey, wrappedKey []byte, err error)
+	// DecryptDataKey unwrap the data key by master key of keyID.
+	DecryptDataKey(ctx context.Context, keyID string, wrappedKey []byte) (dataKey []byte, err error)
+}
+
+type fileKeyProvider struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewFileKeyProvider returns a KeyProvider with master keys in local file
+// One master key per line, formatted as "{key_id} {hex_key}",
+// the hex key is 16, 24 or 32 bytes to select AES-128, AES-192, or AES-256.
+// The first key is primary to wrap new data keys, the others are used
+// to unwrap the data keys which had been wrapped by them.
+// Empty line and line starting with '#' are ignored.
+func NewFileKeyProvider(filename string) (KeyProvider, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return newFileKeyProvider(data)
+}
+
+func newFileKeyProvider(data []byte) (KeyProvider, error) {
+	p := &fileKeyProvider{keys: make(map[string]cipher.AEAD)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid key at line %d", lineno)
+		}
+		keyID := fields[0]
+		if _, ok := p.keys[keyID]; ok {
+			return nil, fmt.Errorf("duplicated key id %s at line %d", keyID, lineno)
+		}
+
+		key, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex key at line %d %s", lineno, err.Error())
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key at line %d %s", lineno, err.Error())
+		}
+
+		if p.primary == "" {
+			p.primary = keyID
+		}
+		p.keys[keyID] = aead
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if p.primary == "" {
+		return nil, fmt.Errorf("no master key")
+	}
+	return p, nil
+}
+
+func (p *fileKeyProvider) GenerateDataKey(ctx context.Context) (string, []byte, []byte, error) {
+	aead := p.keys[p.primary]
+
+	dataKey := make([]byte, DataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+DataKeySize+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", nil, nil, err
+	}
+	wrappedKey := aead.Seal(nonce, nonce, dataKey, []byte(p.primary))
+	return p.primary, dataKey, wrappedKey, nil
+}
+
+func (p *fileKeyProvider) DecryptDataKey(ctx context.Context, keyID string, wrappedKey []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("no such master key %s", keyID)
+	}
+	if len(wrappedKey) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid wrapped key length %d", len(wrappedKey))
+	}
+
+	nonce := wrappedKey[:aead.NonceSize()]
+	return aead.Open(nil, nonce, wrappedKey[aead.NonceSize():], []byte(keyID))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+)
+
+const (
+	testMasterKey1 = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	testMasterKey2 = "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"
+)
+
+func newKeyFile(t *testing.T, lines ...string) string {
+	dir, err := ioutil.TempDir(os.TempDir(), "TestKeyProvider")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, "keys")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(strings.Join(lines, "\n")), 0o600))
+	return filename
+}
+
+func TestFileKeyProviderLoad(t *testing.T) {
+	_, err := access.NewFileKeyProvider(filepath.Join(os.TempDir(), "not-exist-key-file"))
+	require.Error(t, err)
+
+	for _, lines := range [][]string{
+		{},
+		{"# comment only", ""},
+		{"key-1"},
+		{"key-1 " + testMasterKey1 + " more"},
+		{"key-1 xyz"},
+		{"key-1 0011"},
+		{"key-1 " + testMasterKey1, "key-1 " + testMasterKey2},
+	} {
+		_, err := access.NewFileKeyProvider(newKeyFile(t, lines...))
+		require.Error(t, err, lines)
+	}
+
+	_, err = access.NewFileKeyProvider(newKeyFile(t,
+		"# master keys", "", "key-1 "+testMasterKey1, "  key-2   "+testMasterKey2+"  "))
+	require.NoError(t, err)
+}
+
+func TestFileKeyProviderDataKey(t *testing.T) {
+	ctx := context.Background()
+	p1, err := access.NewFileKeyProvider(newKeyFile(t, "key-1 "+testMasterKey1))
+	require.NoError(t, err)
+
+	keyID, dataKey, wrappedKey, err := p1.GenerateDataKey(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "key-1", keyID)
+	require.Equal(t, access.DataKeySize, len(dataKey))
+	require.NotContains(t, string(wrappedKey), string(dataKey))
+
+	_, dataKey2, wrappedKey2, err := p1.GenerateDataKey(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, dataKey, dataKey2)
+	require.NotEqual(t, wrappedKey, wrappedKey2)
+
+	key, err := p1.DecryptDataKey(ctx, keyID, wrappedKey)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, key)
+
+	_, err = p1.DecryptDataKey(ctx, "key-2", wrappedKey)
+	require.Error(t, err)
+	_, err = p1.DecryptDataKey(ctx, keyID, wrappedKey[:5])
+	require.Error(t, err)
+	broken := append([]byte{}, wrappedKey...)
+	broken[len(broken)-1]++
+	_, err = p1.DecryptDataKey(ctx, keyID, broken)
+	require.Error(t, err)
+
+	// rotate the primary key
+	p2, err := access.NewFileKeyProvider(newKeyFile(t, "key-2 "+testMasterKey2, "key-1 "+testMasterKey1))
+	require.NoError(t, err)
+	key, err = p2.DecryptDataKey(ctx, keyID, wrappedKey)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, key)
+
+	keyID, dataKey, wrappedKey, err = p2.GenerateDataKey(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "key-2", keyID)
+	key, err = p2.DecryptDataKey(ctx, keyID, wrappedKey)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, key)
+	_, err = p1.DecryptDataKey(ctx, keyID, wrappedKey)
+	require.Error(t, err)
+}
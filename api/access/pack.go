@@ -0,0 +1,124 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const packVersion byte = 1
+
+// DO NOT CHANGE IT.
+var packMagic = [2]byte{0x9a, 0xc4}
+
+// Pack the object is packed with other small objects in a shared blob
+// The location of packed object has only one blob, BlobSize is real size
+// of the shared blob, Size is size of the object.
+//
+// Offset is where the object starts in the shared blob
+// Index is the index of the object in the shared blob
+// Count is num of objects packed in the shared blob
+type Pack struct {
+	_      [0]byte
+	Offset uint64 `json:"offset"`
+	Index  uint32 `json:"index"`
+	Count  uint32 `json:"count"`
+}
+
+// IsValid is valid pack
+func (p *Pack) IsValid() bool {
+	return p != nil && p.Count > 0 && p.Index < p.Count
+}
+
+// encodedSize returns max size of encoded pack
+func (p *Pack) encodedSize() int {
+	return 2 + 1 + 10 + 5 + 5
+}
+
+// encode transfer Pack to the buf
+// Returns the number of bytes written
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+//  | magic | version |   offset    |   index    |   count    |
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+//  |   2   |    1    | uvarint(10) | uvarint(5) | uvarint(5) |
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// Pack is signed in Crc of location.
+func (p *Pack) encode(buf []byte) int {
+	n := copy(buf, packMagic[:])
+	buf[n] = packVersion
+	n++
+	n += binary.PutUvarint(buf[n:], p.Offset)
+	n += binary.PutUvarint(buf[n:], uint64(p.Index))
+	n += binary.PutUvarint(buf[n:], uint64(p.Count))
+	return n
+}
+
+func hasPack(buf []byte) bool {
+	return len(buf) >= len(packMagic) &&
+		buf[0] == packMagic[0] && buf[1] == packMagic[1]
+}
+
+// decodePack parse pack from buf
+// Returns Pack and the number of bytes read
+func decodePack(buf []byte) (Pack, int, error) {
+	var (
+		p   Pack
+		n   int
+		val uint64
+		nn  int
+	)
+	next := func() (uint64, int) {
+		val, nn := binary.Uvarint(buf[n:])
+		if nn <= 0 {
+			return 0, nn
+		}
+		n += nn
+		return val, nn
+	}
+
+	if len(buf) < len(packMagic)+1 || !hasPack(buf) {
+		return p, n, fmt.Errorf("bytes pack magic %d", len(buf))
+	}
+	n += len(packMagic)
+	if buf[n] != packVersion {
+		return p, n, fmt.Errorf("pack version %d", buf[n])
+	}
+	n++
+
+	if val, nn = next(); nn <= 0 {
+		return p, n, fmt.Errorf("bytes pack offset %d", nn)
+	}
+	p.Offset = val
+	if val, nn = next(); nn <= 0 {
+		return p, n, fmt.Errorf("bytes pack index %d", nn)
+	}
+	p.Index = uint32(val)
+	if val, nn = next(); nn <= 0 {
+		return p, n, fmt.Errorf("bytes pack count %d", nn)
+	}
+	p.Count = uint32(val)
+
+	if !p.IsValid() {
+		return p, n, fmt.Errorf("invalid pack %+v", p)
+	}
+	return p, n, nil
+}
+
+// Encode returns encoding of the pack, used to sign pack in crc
+func (p *Pack) Encode() []byte {
+	buf := make([]byte, p.encodedSize())
+	return buf[:p.encode(buf)]
+}
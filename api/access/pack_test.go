@@ -0,0 +1,112 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+)
+
+func newPackLocation() access.Location {
+	return access.Location{
+		ClusterID: 1,
+		CodeMode:  1,
+		Size:      1 << 10,
+		BlobSize:  1 << 16,
+		Crc:       0xabcdef,
+		Blobs: []access.SliceInfo{
+			{MinBid: 100, Vid: 10, Count: 1},
+		},
+		Pack: &access.Pack{Offset: 1 << 12, Index: 4, Count: 64},
+	}
+}
+
+func TestPackIsValid(t *testing.T) {
+	var pack *access.Pack
+	require.False(t, pack.IsValid())
+	require.False(t, (&access.Pack{}).IsValid())
+	require.False(t, (&access.Pack{Index: 1, Count: 1}).IsValid())
+	require.True(t, (&access.Pack{Index: 0, Count: 1}).IsValid())
+}
+
+func TestPackLocationEncodeDecode(t *testing.T) {
+	loc := newPackLocation()
+
+	base := loc.Copy()
+	base.Pack = nil
+	baseBuf := base.Encode()
+
+	buf := loc.Encode()
+	require.Equal(t, baseBuf, buf[:len(baseBuf)])
+	require.Equal(t, loc.Pack.Encode(), buf[len(baseBuf):])
+
+	dloc, n, err := access.DecodeLocation(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, loc, dloc)
+
+	copied := loc.Copy()
+	require.Equal(t, loc, copied)
+	copied.Pack.Index++
+	require.NotEqual(t, loc.Pack.Index, copied.Pack.Index)
+
+	hexLoc, err := access.DecodeLocationFromHex(loc.HexString())
+	require.NoError(t, err)
+	require.Equal(t, loc, hexLoc)
+
+	b, err := json.Marshal(loc)
+	require.NoError(t, err)
+	var jsonLoc access.Location
+	require.NoError(t, json.Unmarshal(b, &jsonLoc))
+	require.Equal(t, loc, jsonLoc)
+	b, _ = json.Marshal(base)
+	require.NotContains(t, string(b), "pack")
+
+	// with envelope
+	loc.Envelope = newEnvelopeLocation().Envelope
+	buf = loc.Encode()
+	dloc, n, err = access.DecodeLocation(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, loc, dloc)
+}
+
+func TestPackLocationDecodeError(t *testing.T) {
+	loc := newPackLocation()
+	buf := loc.Encode()
+	baseLen := len(buf) - len(loc.Pack.Encode())
+
+	// truncated
+	for n := baseLen + 2; n < len(buf); n++ {
+		_, _, err := access.DecodeLocation(buf[:n])
+		require.Error(t, err)
+	}
+
+	// version
+	changed := append([]byte{}, buf...)
+	changed[baseLen+2] = 0xff
+	_, _, err := access.DecodeLocation(changed)
+	require.Error(t, err)
+
+	// invalid index
+	pack := access.Pack{Index: 2, Count: 2}
+	changed = append(buf[:baseLen:baseLen], pack.Encode()...)
+	_, _, err = access.DecodeLocation(changed)
+	require.Error(t, err)
+}
@@ -15,6 +15,7 @@
 package access
 
 import (
+	"bytes"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -179,6 +180,60 @@ func (h HashSumMap) ToHashAlgorithm() HashAlgorithm {
 	return alg
 }
 
+// Verify returns true if all the expected checksums are equal
+func (h HashSumMap) Verify(expected HashSumMap) bool {
+	for alg, sum := range expected {
+		if val, ok := h[alg]; !ok || !bytes.Equal(val, sum) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeExpectedHashSumMap decode expected checksums from hex string,
+// returns false if any of them is invalid
+func decodeExpectedHashSumMap(crc32Hex, md5Hex, sha1Hex, sha256Hex string) (HashSumMap, bool) {
+	h := make(HashSumMap)
+	for _, a := range []struct {
+		alg  HashAlgorithm
+		val  string
+		size int
+	}{
+		{HashAlgCRC32, crc32Hex, crc32.Size},
+		{HashAlgMD5, md5Hex, md5.Size},
+		{HashAlgSHA1, sha1Hex, sha1.Size},
+		{HashAlgSHA256, sha256Hex, sha256.Size},
+	} {
+		if a.val == "" {
+			continue
+		}
+		b, err := hex.DecodeString(a.val)
+		if err != nil || len(b) != a.size {
+			return nil, false
+		}
+		h[a.alg] = b
+	}
+	return h, true
+}
+
+// encodeExpectedQuery encode expected checksums to rpc url arguments
+func encodeExpectedQuery(crc32Hex, md5Hex, sha1Hex, sha256Hex string) string {
+	query := ""
+	if crc32Hex != "" {
+		query += "&expected_crc32=" + crc32Hex
+	}
+	if md5Hex != "" {
+		query += "&expected_md5=" + md5Hex
+	}
+	if sha1Hex != "" {
+		query += "&expected_sha1=" + sha1Hex
+	}
+	if sha256Hex != "" {
+		query += "&expected_sha256=" + sha256Hex
+	}
+	return query
+}
+
 // All returns readable checksum
 func (h HashSumMap) All() map[string]interface{} {
 	m := make(map[string]interface{})
@@ -208,6 +263,10 @@ func (h HashSumMap) All() map[string]interface{} {
 // BlobSize is every blob's size but the last one which's size=(Size mod BlobSize)
 // Crc is the checksum, change anything of the location, crc will mismatch
 // Blobs all blob information
+// Envelope is the encryption envelope if the data was encrypted by client,
+// it is not signed in Crc, but protected by its own crc in encoding.
+// Pack is not nil if the object was packed in a shared blob, it is signed in Crc.
+// Ref is not nil if the object is a reference of deduplicated content, it is signed in Crc.
 type Location struct {
 	_         [0]byte
 	ClusterID proto.ClusterID   `json:"cluster_id"`
@@ -216,6 +275,10 @@ type Location struct {
 	BlobSize  uint32            `json:"blob_size"`
 	Crc       uint32            `json:"crc"`
 	Blobs     []SliceInfo       `json:"blobs"`
+	Envelope  *Envelope         `json:"envelope,omitempty"`
+	Pack      *Pack             `json:"pack,omitempty"`
+	Stripe    *Stripe           `json:"stripe,omitempty"`
+	Ref       *Ref              `json:"ref,omitempty"`
 }
 
 // SliceInfo blobs info, 8 + 4 + 4 bytes
@@ -254,9 +317,33 @@ func (loc *Location) Copy() Location {
 		Blobs:     make([]SliceInfo, len(loc.Blobs)),
 	}
 	copy(dst.Blobs, loc.Blobs)
+	if loc.Envelope != nil {
+		envelope := loc.Envelope.Copy()
+		dst.Envelope = &envelope
+	}
+	if loc.Pack != nil {
+		pack := *loc.Pack
+		dst.Pack = &pack
+	}
+	if loc.Stripe != nil {
+		stripe := *loc.Stripe
+		dst.Stripe = &stripe
+	}
+	if loc.Ref != nil {
+		ref := *loc.Ref
+		dst.Ref = &ref
+	}
 	return dst
 }
 
+// DataSize returns size of the user data, it is less than Size if encrypted
+func (loc *Location) DataSize() uint64 {
+	if loc.Envelope == nil {
+		return loc.Size
+	}
+	return loc.Envelope.PlainSize(loc.Size)
+}
+
 // Encode transfer Location to slice byte
 // Returns the buf created by me
 //  (n) means max-n bytes
@@ -271,19 +358,46 @@ func (loc *Location) Copy() Location {
 //  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 //  | n-bytes |  (10)  | (5) |  (5)  | (20) | (20) |       ...         |
 //  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// The encoding of Pack, Stripe, Ref and Envelope are appended in order if they are not nil,
+// see Pack, Stripe, Ref and Envelope.
 func (loc *Location) Encode() []byte {
 	if loc == nil {
 		return nil
 	}
 	n := 25 + 5 + len(loc.Blobs)*20
+	if loc.Pack != nil {
+		n += loc.Pack.encodedSize()
+	}
+	if loc.Stripe != nil {
+		n += loc.Stripe.encodedSize()
+	}
+	if loc.Ref != nil {
+		n += loc.Ref.encodedSize()
+	}
+	if loc.Envelope != nil {
+		n += loc.Envelope.encodedSize()
+	}
 	buf := make([]byte, n)
 	n = loc.Encode2(buf)
+	if loc.Pack != nil {
+		n += loc.Pack.encode(buf[n:])
+	}
+	if loc.Stripe != nil {
+		n += loc.Stripe.encode(buf[n:])
+	}
+	if loc.Ref != nil {
+		n += loc.Ref.encode(buf[n:])
+	}
+	if loc.Envelope != nil {
+		n += loc.Envelope.encode(buf[:n], buf[n:])
+	}
 	return buf[:n]
 }
 
 // Encode2 transfer Location to the buf, the buf reuse by yourself
 // Returns the number of bytes read
 // If the buffer is too small, Encode2 will panic
+// Pack, Stripe, Ref and Envelope are not encoded in Encode2.
 func (loc *Location) Encode2(buf []byte) int {
 	if loc == nil {
 		return 0
@@ -369,6 +483,52 @@ func (loc *Location) Spread() []Blob {
 // Returns Location and the number of bytes read
 // Error is not nil when parsing failed
 func DecodeLocation(buf []byte) (Location, int, error) {
+	loc, n, err := decodeLocation(buf)
+	if err != nil {
+		return loc, n, err
+	}
+
+	if hasPack(buf[n:]) {
+		pack, nn, err := decodePack(buf[n:])
+		n += nn
+		if err != nil {
+			return loc, n, err
+		}
+		loc.Pack = &pack
+	}
+
+	if hasStripe(buf[n:]) {
+		stripe, nn, err := decodeStripe(buf[n:])
+		n += nn
+		if err != nil {
+			return loc, n, err
+		}
+		loc.Stripe = &stripe
+	}
+
+	if hasRef(buf[n:]) {
+		ref, nn, err := decodeRef(buf[n:])
+		n += nn
+		if err != nil {
+			return loc, n, err
+		}
+		loc.Ref = &ref
+	}
+
+	if !hasEnvelope(buf[n:]) {
+		return loc, n, nil
+	}
+
+	envelope, nn, err := decodeEnvelope(buf[:n], buf[n:])
+	n += nn
+	if err != nil {
+		return loc, n, err
+	}
+	loc.Envelope = &envelope
+	return loc, n, nil
+}
+
+func decodeLocation(buf []byte) (Location, int, error) {
 	var (
 		loc Location
 		n   int
@@ -482,10 +642,20 @@ func DecodeLocationFromBase64(s string) (Location, error) {
 // PutArgs for service /put
 // Hashes means how to calculate check sum,
 // HashAlgCRC32 | HashAlgMD5 equal 2 + 4 = 6
+// Expected* are optional checksums of the body in hex string,
+// access rejects the put and deletes the written data if mismatched.
+// the hex string of crc32 is in big-endian, same as HashSumMap.
 type PutArgs struct {
-	Size   int64         `json:"size"`
-	Hashes HashAlgorithm `json:"hashes,omitempty"`
-	Body   io.Reader     `json:"-"`
+	Size           int64         `json:"size"`
+	Hashes         HashAlgorithm `json:"hashes,omitempty"`
+	ExpectedCRC32  string        `json:"expected_crc32,omitempty"`
+	ExpectedMD5    string        `json:"expected_md5,omitempty"`
+	ExpectedSHA1   string        `json:"expected_sha1,omitempty"`
+	ExpectedSHA256 string        `json:"expected_sha256,omitempty"`
+	// Expiry unix timestamp in seconds, the object will be deleted
+	// automatically after expired, zero means never expire.
+	Expiry int64     `json:"expiry,omitempty"`
+	Body   io.Reader `json:"-"`
 }
 
 // IsValid is valid put args
@@ -493,7 +663,21 @@ func (args *PutArgs) IsValid() bool {
 	if args == nil {
 		return false
 	}
-	return args.Size > 0
+	_, ok := args.ExpectedHashSumMap()
+	return args.Size > 0 && args.Expiry >= 0 && ok
+}
+
+// ExpectedHashSumMap returns the expected checksums of put args
+func (args *PutArgs) ExpectedHashSumMap() (HashSumMap, bool) {
+	return decodeExpectedHashSumMap(args.ExpectedCRC32, args.ExpectedMD5, args.ExpectedSHA1, args.ExpectedSHA256)
+}
+
+func (args *PutArgs) expectedQuery() string {
+	query := encodeExpectedQuery(args.ExpectedCRC32, args.ExpectedMD5, args.ExpectedSHA1, args.ExpectedSHA256)
+	if args.Expiry > 0 {
+		query += fmt.Sprintf("&expiry=%d", args.Expiry)
+	}
+	return query
 }
 
 // PutResp put response result
@@ -503,14 +687,20 @@ type PutResp struct {
 }
 
 // PutAtArgs for service /putat
+// Expected* are optional checksums of the body, same as PutArgs,
+// but the blob is not deleted if mismatched, the caller should clean it up.
 type PutAtArgs struct {
-	ClusterID proto.ClusterID `json:"clusterid"`
-	Vid       proto.Vid       `json:"volumeid"`
-	Blobid    proto.BlobID    `json:"blobid"`
-	Size      int64           `json:"size"`
-	Hashes    HashAlgorithm   `json:"hashes,omitempty"`
-	Token     string          `json:"token"`
-	Body      io.Reader       `json:"-"`
+	ClusterID      proto.ClusterID `json:"clusterid"`
+	Vid            proto.Vid       `json:"volumeid"`
+	Blobid         proto.BlobID    `json:"blobid"`
+	Size           int64           `json:"size"`
+	Hashes         HashAlgorithm   `json:"hashes,omitempty"`
+	ExpectedCRC32  string          `json:"expected_crc32,omitempty"`
+	ExpectedMD5    string          `json:"expected_md5,omitempty"`
+	ExpectedSHA1   string          `json:"expected_sha1,omitempty"`
+	ExpectedSHA256 string          `json:"expected_sha256,omitempty"`
+	Token          string          `json:"token"`
+	Body           io.Reader       `json:"-"`
 }
 
 // IsValid is valid putat args
@@ -518,10 +708,16 @@ func (args *PutAtArgs) IsValid() bool {
 	if args == nil {
 		return false
 	}
+	_, ok := args.ExpectedHashSumMap()
 	return args.ClusterID > proto.ClusterID(0) &&
 		args.Vid > proto.Vid(0) &&
 		args.Blobid > proto.BlobID(0) &&
-		args.Size > 0
+		args.Size > 0 && ok
+}
+
+// ExpectedHashSumMap returns the expected checksums of putat args
+func (args *PutAtArgs) ExpectedHashSumMap() (HashSumMap, bool) {
+	return decodeExpectedHashSumMap(args.ExpectedCRC32, args.ExpectedMD5, args.ExpectedSHA1, args.ExpectedSHA256)
 }
 
 // PutAtResp putat response result
@@ -535,11 +731,12 @@ type AllocArgs struct {
 	BlobSize        uint32            `json:"blob_size"`
 	AssignClusterID proto.ClusterID   `json:"assign_cluster_id"`
 	CodeMode        codemode.CodeMode `json:"code_mode"`
+	Expiry          int64             `json:"expiry,omitempty"`
 }
 
 // IsValid is valid alloc args
 func (args *AllocArgs) IsValid() bool {
-	if args == nil {
+	if args == nil || args.Expiry < 0 {
 		return false
 	}
 	if args.AssignClusterID > 0 {
@@ -630,3 +827,77 @@ func (args *SignArgs) IsValid() bool {
 type SignResp struct {
 	Location Location `json:"location"`
 }
+
+// MultipartInitArgs for service /multipart/init
+// PartSize is size of every part but the last one, equal to blob size
+type MultipartInitArgs struct {
+	Size     uint64            `json:"size"`
+	PartSize uint32            `json:"part_size"`
+	CodeMode codemode.CodeMode `json:"code_mode"`
+}
+
+// IsValid is valid multipart init args
+func (args *MultipartInitArgs) IsValid() bool {
+	if args == nil {
+		return false
+	}
+	return args.Size > 0 && args.PartSize <= MaxBlobSize
+}
+
+// MultipartInitResp multipart init response
+// Expiration is unix seconds, the upload will be aborted if not completed before it
+type MultipartInitResp struct {
+	UploadID   string `json:"upload_id"`
+	Size       uint64 `json:"size"`
+	PartSize   uint32 `json:"part_size"`
+	PartCount  uint32 `json:"part_count"`
+	Expiration int64  `json:"expiration"`
+}
+
+// MultipartPutArgs for service /multipart/put
+// Index is index of the part in [0, PartCount)
+type MultipartPutArgs struct {
+	UploadID string        `json:"uploadid"`
+	Index    uint32        `json:"index"`
+	Size     int64         `json:"size"`
+	Hashes   HashAlgorithm `json:"hashes,omitempty"`
+	Body     io.Reader     `json:"-"`
+}
+
+// IsValid is valid multipart put args
+func (args *MultipartPutArgs) IsValid() bool {
+	if args == nil {
+		return false
+	}
+	return args.UploadID != "" && args.Size > 0
+}
+
+// MultipartPutResp multipart put response result
+type MultipartPutResp struct {
+	HashSumMap HashSumMap `json:"hashsum"`
+}
+
+// MultipartArgs for service /multipart/complete, /multipart/abort and /multipart/list
+type MultipartArgs struct {
+	UploadID string `json:"upload_id"`
+}
+
+// IsValid is valid multipart args
+func (args *MultipartArgs) IsValid() bool {
+	if args == nil {
+		return false
+	}
+	return args.UploadID != ""
+}
+
+// MultipartCompleteResp multipart complete response with signed location
+type MultipartCompleteResp struct {
+	Location Location `json:"location"`
+}
+
+// MultipartListResp indexes of uploaded parts
+type MultipartListResp struct {
+	UploadID  string   `json:"upload_id"`
+	PartCount uint32   `json:"part_count"`
+	Uploaded  []uint32 `json:"uploaded"`
+}
@@ -259,6 +259,26 @@ func TestHashAlgorithm2HashSumMap(t *testing.T) {
 	}
 }
 
+func TestHashSumMapVerify(t *testing.T) {
+	buff := make([]byte, 1024)
+	rand.Read(buff)
+
+	actual := (access.HashAlgCRC32 | access.HashAlgMD5 | access.HashAlgSHA1).ToHashSumMap()
+	for alg := range actual {
+		hasher := alg.ToHasher()
+		hasher.Write(buff)
+		actual[alg] = hasher.Sum(nil)
+	}
+
+	require.True(t, actual.Verify(nil))
+	require.True(t, actual.Verify(access.HashSumMap{}))
+	require.True(t, actual.Verify(access.HashSumMap{access.HashAlgMD5: actual[access.HashAlgMD5]}))
+	require.True(t, actual.Verify(actual))
+	require.False(t, actual.Verify(access.HashSumMap{access.HashAlgMD5: actual[access.HashAlgSHA1]}))
+	require.False(t, actual.Verify(access.HashSumMap{access.HashAlgSHA256: make([]byte, sha256.Size)}))
+	require.False(t, access.HashSumMap{}.Verify(access.HashSumMap{access.HashAlgCRC32: nil}))
+}
+
 func TestLocationEncodeDecodeNil(t *testing.T) {
 	var loc *access.Location
 	require.Nil(t, loc.Encode())
@@ -461,6 +481,53 @@ func TestPutArgs(t *testing.T) {
 		args := access.PutArgs{Size: cs.size}
 		require.Equal(t, cs.valid, args.IsValid())
 	}
+
+	expectedCases := []struct {
+		crc32, md5, sha1 string
+		valid            bool
+		algs             access.HashAlgorithm
+	}{
+		{"", "", "", true, 0},
+		{"0a0b0c0d", "", "", true, access.HashAlgCRC32},
+		{"0a0b0c0", "", "", false, 0},
+		{"0a0b0c0d0e", "", "", false, 0},
+		{"", "d41d8cd98f00b204e9800998ecf8427e", "", true, access.HashAlgMD5},
+		{"", "x41d8cd98f00b204e9800998ecf8427e", "", false, 0},
+		{"", "", "da39a3ee5e6b4b0d3255bfef95601890afd80709", true, access.HashAlgSHA1},
+		{"", "", "d41d8cd98f00b204e9800998ecf8427e", false, 0},
+		{
+			"0a0b0c0d", "d41d8cd98f00b204e9800998ecf8427e", "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+			true, access.HashAlgCRC32 | access.HashAlgMD5 | access.HashAlgSHA1,
+		},
+	}
+	for _, cs := range expectedCases {
+		args := access.PutArgs{Size: 1, ExpectedCRC32: cs.crc32, ExpectedMD5: cs.md5, ExpectedSHA1: cs.sha1}
+		require.Equal(t, cs.valid, args.IsValid())
+		expected, ok := args.ExpectedHashSumMap()
+		require.Equal(t, cs.valid, ok)
+		require.Equal(t, cs.algs, expected.ToHashAlgorithm())
+
+		atArgs := access.PutAtArgs{
+			ClusterID: 1, Vid: 1, Blobid: 1, Size: 1,
+			ExpectedCRC32: cs.crc32, ExpectedMD5: cs.md5, ExpectedSHA1: cs.sha1,
+		}
+		require.Equal(t, cs.valid, atArgs.IsValid())
+	}
+
+	args := access.PutArgs{Size: 1, ExpectedCRC32: "0a0b0c0d"}
+	expected, _ := args.ExpectedHashSumMap()
+	require.Equal(t, uint32(0x0a0b0c0d), expected.GetSumVal(access.HashAlgCRC32))
+
+	sha256Hex := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	args = access.PutArgs{Size: 1, ExpectedSHA256: sha256Hex}
+	require.True(t, args.IsValid())
+	expected, _ = args.ExpectedHashSumMap()
+	require.Equal(t, access.HashAlgSHA256, expected.ToHashAlgorithm())
+	require.Equal(t, sha256Hex, expected.GetSumVal(access.HashAlgSHA256))
+	args.ExpectedSHA256 = sha256Hex[:62]
+	require.False(t, args.IsValid())
+	atArgs := access.PutAtArgs{ClusterID: 1, Vid: 1, Blobid: 1, Size: 1, ExpectedSHA256: sha256Hex[2:] + "00"}
+	require.True(t, atArgs.IsValid())
 }
 
 func TestPutAtArgs(t *testing.T) {
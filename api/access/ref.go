@@ -0,0 +1,99 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const refVersion byte = 1
+
+// DO NOT CHANGE IT.
+var refMagic = [2]byte{0x9c, 0x7f}
+
+// Ref the location is one reference of deduplicated content,
+// Holder is unique for every put which holds the reference.
+// The blobs are deleted after all holders released.
+type Ref struct {
+	_      [0]byte
+	Holder uint64 `json:"holder"`
+}
+
+// IsValid is valid ref
+func (r *Ref) IsValid() bool {
+	return r != nil && r.Holder > 0
+}
+
+// encodedSize returns max size of encoded ref
+func (r *Ref) encodedSize() int {
+	return 2 + 1 + 10
+}
+
+// encode transfer Ref to the buf
+// Returns the number of bytes written
+//  - - - - - - - - - - - - - - - - -
+//  | magic | version |    holder   |
+//  - - - - - - - - - - - - - - - - -
+//  |   2   |    1    | uvarint(10) |
+//  - - - - - - - - - - - - - - - - -
+// Ref is signed in Crc of location.
+func (r *Ref) encode(buf []byte) int {
+	n := copy(buf, refMagic[:])
+	buf[n] = refVersion
+	n++
+	n += binary.PutUvarint(buf[n:], r.Holder)
+	return n
+}
+
+func hasRef(buf []byte) bool {
+	return len(buf) >= len(refMagic) &&
+		buf[0] == refMagic[0] && buf[1] == refMagic[1]
+}
+
+// decodeRef parse ref from buf
+// Returns Ref and the number of bytes read
+func decodeRef(buf []byte) (Ref, int, error) {
+	var (
+		r Ref
+		n int
+	)
+	if len(buf) < len(refMagic)+1 || !hasRef(buf) {
+		return r, n, fmt.Errorf("bytes ref magic %d", len(buf))
+	}
+	n += len(refMagic)
+	if buf[n] != refVersion {
+		return r, n, fmt.Errorf("ref version %d", buf[n])
+	}
+	n++
+
+	val, nn := binary.Uvarint(buf[n:])
+	if nn <= 0 {
+		return r, n, fmt.Errorf("bytes ref holder %d", nn)
+	}
+	n += nn
+	r.Holder = val
+
+	if !r.IsValid() {
+		return r, n, fmt.Errorf("invalid ref %+v", r)
+	}
+	return r, n, nil
+}
+
+// Encode returns encoding of the ref, used to sign ref in crc
+func (r *Ref) Encode() []byte {
+	buf := make([]byte, r.encodedSize())
+	return buf[:r.encode(buf)]
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+)
+
+func TestRefLocationEncodeDecode(t *testing.T) {
+	var ref *access.Ref
+	require.False(t, ref.IsValid())
+	require.False(t, (&access.Ref{}).IsValid())
+
+	loc := newStripeLocation()
+	base := loc.Copy()
+	baseBuf := base.Encode()
+	loc.Ref = &access.Ref{Holder: 1 << 63}
+
+	buf := loc.Encode()
+	require.Equal(t, baseBuf, buf[:len(baseBuf)])
+	require.Equal(t, loc.Ref.Encode(), buf[len(baseBuf):])
+
+	dloc, n, err := access.DecodeLocation(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, loc, dloc)
+
+	copied := loc.Copy()
+	require.Equal(t, loc, copied)
+	copied.Ref.Holder++
+	require.NotEqual(t, loc.Ref.Holder, copied.Ref.Holder)
+
+	b, err := json.Marshal(loc)
+	require.NoError(t, err)
+	var jsonLoc access.Location
+	require.NoError(t, json.Unmarshal(b, &jsonLoc))
+	require.Equal(t, loc, jsonLoc)
+	b, _ = json.Marshal(base)
+	require.NotContains(t, string(b), "ref")
+
+	// with pack and envelope
+	loc.Pack = &access.Pack{Offset: 1, Index: 0, Count: 1}
+	loc.Envelope = newEnvelopeLocation().Envelope
+	buf = loc.Encode()
+	dloc, n, err = access.DecodeLocation(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, loc, dloc)
+}
+
+func TestRefLocationDecodeError(t *testing.T) {
+	loc := newStripeLocation()
+	loc.Ref = &access.Ref{Holder: 100}
+	buf := loc.Encode()
+	baseLen := len(buf) - len(loc.Ref.Encode())
+
+	// truncated
+	for n := baseLen + 2; n < len(buf); n++ {
+		_, _, err := access.DecodeLocation(buf[:n])
+		require.Error(t, err)
+	}
+
+	// version
+	changed := append([]byte{}, buf...)
+	changed[baseLen+2] = 0xff
+	_, _, err := access.DecodeLocation(changed)
+	require.Error(t, err)
+
+	// invalid holder
+	ref := access.Ref{}
+	changed = append(buf[:baseLen:baseLen], ref.Encode()...)
+	_, _, err = access.DecodeLocation(changed)
+	require.Error(t, err)
+}
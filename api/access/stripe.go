@@ -0,0 +1,98 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const stripeVersion byte = 1
+
+// DO NOT CHANGE IT.
+var stripeMagic = [2]byte{0x9b, 0x5e}
+
+// Stripe blobs of the location are encoded with stripe layout,
+// shards are cut into units of Unit bytes, see ec.StripeSegments.
+type Stripe struct {
+	_    [0]byte
+	Unit uint32 `json:"unit"`
+}
+
+// IsValid is valid stripe
+func (s *Stripe) IsValid() bool {
+	return s != nil && s.Unit > 0
+}
+
+// encodedSize returns max size of encoded stripe
+func (s *Stripe) encodedSize() int {
+	return 2 + 1 + 5
+}
+
+// encode transfer Stripe to the buf
+// Returns the number of bytes written
+//  - - - - - - - - - - - - - - - -
+//  | magic | version |    unit    |
+//  - - - - - - - - - - - - - - - -
+//  |   2   |    1    | uvarint(5) |
+//  - - - - - - - - - - - - - - - -
+// Stripe is signed in Crc of location.
+func (s *Stripe) encode(buf []byte) int {
+	n := copy(buf, stripeMagic[:])
+	buf[n] = stripeVersion
+	n++
+	n += binary.PutUvarint(buf[n:], uint64(s.Unit))
+	return n
+}
+
+func hasStripe(buf []byte) bool {
+	return len(buf) >= len(stripeMagic) &&
+		buf[0] == stripeMagic[0] && buf[1] == stripeMagic[1]
+}
+
+// decodeStripe parse stripe from buf
+// Returns Stripe and the number of bytes read
+func decodeStripe(buf []byte) (Stripe, int, error) {
+	var (
+		s Stripe
+		n int
+	)
+	if len(buf) < len(stripeMagic)+1 || !hasStripe(buf) {
+		return s, n, fmt.Errorf("bytes stripe magic %d", len(buf))
+	}
+	n += len(stripeMagic)
+	if buf[n] != stripeVersion {
+		return s, n, fmt.Errorf("stripe version %d", buf[n])
+	}
+	n++
+
+	val, nn := binary.Uvarint(buf[n:])
+	if nn <= 0 {
+		return s, n, fmt.Errorf("bytes stripe unit %d", nn)
+	}
+	n += nn
+	s.Unit = uint32(val)
+
+	if !s.IsValid() {
+		return s, n, fmt.Errorf("invalid stripe %+v", s)
+	}
+	return s, n, nil
+}
+
+// Encode returns encoding of the stripe, used to sign stripe in crc
+func (s *Stripe) Encode() []byte {
+	buf := make([]byte, s.encodedSize())
+	return buf[:s.encode(buf)]
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/access"
+)
+
+func newStripeLocation() access.Location {
+	return access.Location{
+		ClusterID: 1,
+		CodeMode:  1,
+		Size:      1 << 24,
+		BlobSize:  1 << 22,
+		Crc:       0xabcdef,
+		Blobs: []access.SliceInfo{
+			{MinBid: 100, Vid: 10, Count: 4},
+		},
+		Stripe: &access.Stripe{Unit: 1 << 16},
+	}
+}
+
+func TestStripeLocationEncodeDecode(t *testing.T) {
+	var stripe *access.Stripe
+	require.False(t, stripe.IsValid())
+	require.False(t, (&access.Stripe{}).IsValid())
+
+	loc := newStripeLocation()
+	base := loc.Copy()
+	base.Stripe = nil
+	baseBuf := base.Encode()
+
+	buf := loc.Encode()
+	require.Equal(t, baseBuf, buf[:len(baseBuf)])
+	require.Equal(t, loc.Stripe.Encode(), buf[len(baseBuf):])
+
+	dloc, n, err := access.DecodeLocation(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, loc, dloc)
+
+	copied := loc.Copy()
+	require.Equal(t, loc, copied)
+	copied.Stripe.Unit++
+	require.NotEqual(t, loc.Stripe.Unit, copied.Stripe.Unit)
+
+	b, err := json.Marshal(loc)
+	require.NoError(t, err)
+	var jsonLoc access.Location
+	require.NoError(t, json.Unmarshal(b, &jsonLoc))
+	require.Equal(t, loc, jsonLoc)
+	b, _ = json.Marshal(base)
+	require.NotContains(t, string(b), "stripe")
+
+	// with pack and envelope
+	loc.Pack = &access.Pack{Offset: 1, Index: 0, Count: 1}
+	loc.Envelope = newEnvelopeLocation().Envelope
+	buf = loc.Encode()
+	dloc, n, err = access.DecodeLocation(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, loc, dloc)
+}
+
+func TestStripeLocationDecodeError(t *testing.T) {
+	loc := newStripeLocation()
+	buf := loc.Encode()
+	baseLen := len(buf) - len(loc.Stripe.Encode())
+
+	// truncated
+	for n := baseLen + 2; n < len(buf); n++ {
+		_, _, err := access.DecodeLocation(buf[:n])
+		require.Error(t, err)
+	}
+
+	// version
+	changed := append([]byte{}, buf...)
+	changed[baseLen+2] = 0xff
+	_, _, err := access.DecodeLocation(changed)
+	require.Error(t, err)
+
+	// invalid unit
+	stripe := access.Stripe{}
+	changed = append(buf[:baseLen:baseLen], stripe.Encode()...)
+	_, _, err = access.DecodeLocation(changed)
+	require.Error(t, err)
+}
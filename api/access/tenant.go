@@ -0,0 +1,55 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package access
+
+// HeaderTenant default request header of tenant name
+const HeaderTenant = "X-Blobstore-Tenant"
+
+// TenantUsageArgs tenant usage args, returns all tenants if tenant is empty
+type TenantUsageArgs struct {
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// TenantUsage usage and quota of one tenant, quota 0 means unlimited
+type TenantUsage struct {
+	Tenant     string `json:"tenant"`
+	Bytes      int64  `json:"bytes"`
+	Objects    int64  `json:"objects"`
+	MaxBytes   int64  `json:"max_bytes"`
+	MaxObjects int64  `json:"max_objects"`
+	ReaderMBps int    `json:"reader_mbps"`
+	WriterMBps int    `json:"writer_mbps"`
+}
+
+// TenantUsageResp tenant usage response
+type TenantUsageResp struct {
+	Tenants []TenantUsage `json:"tenants"`
+}
+
+// tenant usage is stored in kv of clustermgr, the counters are changed
+// with the token of the owner, so that they are changed only once.
+const (
+	TenantBytesPrefix   = "access/tenant/bytes/"
+	TenantObjectsPrefix = "access/tenant/objects/"
+	TenantOwnerPrefix   = "access/tenant/owner/"
+)
+
+// TenantOwner owner of the location charged to the tenant,
+// Bytes and one object were acquired with Token.
+type TenantOwner struct {
+	Tenant string `json:"tenant"`
+	Token  string `json:"token"`
+	Bytes  int64  `json:"bytes"`
+}
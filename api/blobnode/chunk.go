@@ -20,10 +20,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"time"
 
 	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
 )
 
 const (
@@ -269,6 +272,56 @@ func (c *client) ListChunks(ctx context.Context, host string, args *ListChunkArg
 	return listRet.ChunkInfos, nil
 }
 
+type ExportChunkArgs struct {
+	DiskID proto.DiskID `json:"diskid"`
+	Vuid   proto.Vuid   `json:"vuid"`
+}
+
+// ExportChunk returns archive stream of a readonly chunk
+func (c *client) ExportChunk(ctx context.Context, host string, args *ExportChunkArgs) (body io.ReadCloser, err error) {
+	if !IsValidDiskID(args.DiskID) {
+		err = bloberr.ErrInvalidDiskId
+		return
+	}
+
+	urlStr := fmt.Sprintf("%v/chunk/export/diskid/%v/vuid/%v", host, args.DiskID, args.Vuid)
+	resp, err := c.Get(ctx, urlStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		err = rpc.ParseResponseErr(resp)
+		return
+	}
+
+	return resp.Body, nil
+}
+
+type ImportChunkArgs struct {
+	DiskID proto.DiskID `json:"diskid"`
+	Vuid   proto.Vuid   `json:"vuid"`
+	Body   io.Reader    `json:"-"`
+}
+
+// ImportChunk creates chunk of vuid from archive, which is exported by ExportChunk
+func (c *client) ImportChunk(ctx context.Context, host string, args *ImportChunkArgs) (ci *ChunkInfo, err error) {
+	if !IsValidDiskID(args.DiskID) {
+		err = bloberr.ErrInvalidDiskId
+		return
+	}
+
+	urlStr := fmt.Sprintf("%v/chunk/import/diskid/%v/vuid/%v", host, args.DiskID, args.Vuid)
+	req, err := http.NewRequest(http.MethodPost, urlStr, args.Body)
+	if err != nil {
+		return
+	}
+	ci = new(ChunkInfo)
+	err = c.DoWith(ctx, req, ci)
+	return
+}
+
 type CompactChunkArgs struct {
 	DiskID proto.DiskID `json:"diskid"`
 	Vuid   proto.Vuid   `json:"vuid"`
@@ -277,3 +330,14 @@ type CompactChunkArgs struct {
 type DiskProbeArgs struct {
 	Path string `json:"path"`
 }
+
+// DiskAttachArgs attaches an empty path which may be absent in config,
+// base config of the first configured disk is used as template.
+type DiskAttachArgs struct {
+	Path      string `json:"path"`
+	MaxChunks int32  `json:"max_chunks"`
+}
+
+type DiskDetachArgs struct {
+	DiskID proto.DiskID `json:"disk_id"`
+}
@@ -84,6 +84,8 @@ type StorageAPI interface {
 	SetChunkReadonly(ctx context.Context, host string, args *ChangeChunkStatusArgs) (err error)
 	SetChunkReadwrite(ctx context.Context, host string, args *ChangeChunkStatusArgs) (err error)
 	ListChunks(ctx context.Context, host string, args *ListChunkArgs) (cis []*ChunkInfo, err error)
+	ExportChunk(ctx context.Context, host string, args *ExportChunkArgs) (body io.ReadCloser, err error)
+	ImportChunk(ctx context.Context, host string, args *ImportChunkArgs) (ci *ChunkInfo, err error)
 
 	// shard
 	GetShard(ctx context.Context, host string, args *GetShardArgs) (body io.ReadCloser, shardCrc uint32, err error)
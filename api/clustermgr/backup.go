@@ -0,0 +1,49 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+
+	"github.com/cubefs/blobstore/common/raftserver"
+)
+
+type BackupArgs struct {
+	// Dir is a local directory on the clustermgr leader, it must not contain backup already
+	Dir string `json:"dir"`
+}
+
+// BackupFile is a data file of backup and it's checksum
+type BackupFile struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Crc32 uint32 `json:"crc32"`
+}
+
+// BackupManifest describes a consistent backup of clustermgr at ApplyIndex
+type BackupManifest struct {
+	ApplyIndex uint64             `json:"apply_index"`
+	Term       uint64             `json:"term"`
+	Members    raftserver.Members `json:"members"`
+	Files      []BackupFile       `json:"files"`
+	CreateTime int64              `json:"create_time"`
+}
+
+// Backup take a consistent backup of all clustermgr data into local directory of leader
+func (c *Client) Backup(ctx context.Context, args *BackupArgs) (ret *BackupManifest, err error) {
+	ret = &BackupManifest{}
+	err = c.PostWith(ctx, "/admin/backup", ret, args)
+	return
+}
@@ -30,6 +30,13 @@ const (
 	MemberTypeMax
 )
 
+const (
+	// HeaderStaleRead opts read request into bounded-staleness read served by follower or learner
+	HeaderStaleRead = "X-Stale-Read"
+	// HeaderAppliedIndex is the raft applied index of the member who served the stale read
+	HeaderAppliedIndex = "X-Applied-Index"
+)
+
 var retryCodes = []int{
 	http.StatusInternalServerError,
 	http.StatusBadGateway,
@@ -41,10 +48,14 @@ type MemberType uint8
 
 type Config struct {
 	lbClient.LbConfig
+	// StaleRead allows volume, disk and service info to be read from follower or learner
+	StaleRead bool `json:"stale_read"`
 }
 
 type Client struct {
 	lbClient.Client
+	staleRead bool
+	timeoutMs int64
 }
 
 var _ ClientAPI = (*Client)(nil)
@@ -63,7 +74,24 @@ func New(cfg *Config) *Client {
 	if cfg.ShouldRetry == nil {
 		cfg.ShouldRetry = defaultShouldRetry
 	}
-	return &Client{lbClient.NewLbClient(&cfg.LbConfig, nil)}
+	return &Client{
+		Client:    lbClient.NewLbClient(&cfg.LbConfig, nil),
+		staleRead: cfg.StaleRead,
+		timeoutMs: cfg.ClientTimeoutMs,
+	}
+}
+
+// readWith get info from clustermgr, set stale read header if client opts into it
+func (c *Client) readWith(ctx context.Context, url string, ret interface{}) error {
+	if !c.staleRead {
+		return c.GetWith(ctx, url, ret)
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderStaleRead, "1")
+	return c.DoWith(ctx, req, ret)
 }
 
 type BidScopeArgs struct {
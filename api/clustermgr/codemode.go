@@ -0,0 +1,88 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// CodeModeSyncInterval interval of services syncing runtime code modes from cluster
+const CodeModeSyncInterval = time.Minute
+
+type ListCodeModeRet struct {
+	CodeModes []codemode.RegisteredMode `json:"code_modes"`
+}
+
+// RegisterCodeMode register a runtime code mode into cluster
+func (c *Client) RegisterCodeMode(ctx context.Context, args *codemode.RegisteredMode) (err error) {
+	err = c.PostWith(ctx, "/codemode/register", nil, args)
+	return
+}
+
+// ListCodeModes list all runtime registered code modes of cluster
+func (c *Client) ListCodeModes(ctx context.Context) (ret ListCodeModeRet, err error) {
+	err = c.GetWith(ctx, "/codemode/list", &ret)
+	return
+}
+
+// ConfigGetter get config from cluster manager
+type ConfigGetter interface {
+	GetConfig(ctx context.Context, key string) (string, error)
+}
+
+// SyncCodeModes register runtime code modes of cluster into process,
+// it is ok if cluster has no registered code mode
+func SyncCodeModes(ctx context.Context, getter ConfigGetter) error {
+	val, err := getter.GetConfig(ctx, proto.CodeModeTacticsConfigKey)
+	if err != nil {
+		if rpc.DetectStatusCode(err) == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	if val == "" {
+		return nil
+	}
+	var modes []codemode.RegisteredMode
+	if err = json.Unmarshal([]byte(val), &modes); err != nil {
+		return err
+	}
+	return codemode.Register(modes...)
+}
+
+// LoopSyncCodeModes sync runtime code modes every interval until stopped
+func LoopSyncCodeModes(getter ConfigGetter, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			span, ctx := trace.StartSpanFromContext(context.Background(), "")
+			if err := SyncCodeModes(ctx, getter); err != nil {
+				span.Warnf("sync code modes failed, err: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -109,7 +109,7 @@ func (c *Client) AllocDiskID(ctx context.Context) (proto.DiskID, error) {
 // DiskInfo get disk info from cluster manager
 func (c *Client) DiskInfo(ctx context.Context, id proto.DiskID) (ret *blobnode.DiskInfo, err error) {
 	ret = &blobnode.DiskInfo{}
-	err = c.GetWith(ctx, "/disk/info?disk_id="+id.ToString(), ret)
+	err = c.readWith(ctx, "/disk/info?disk_id="+id.ToString(), ret)
 	return
 }
 
@@ -144,7 +144,7 @@ func (c *Client) ListHostDisk(ctx context.Context, host string) (ret []*blobnode
 // ListDisk list disk info from cluster manager
 // when ListOptionArgs is default value, defalut return 10 diskInfos
 func (c *Client) ListDisk(ctx context.Context, options *ListOptionArgs) (ret ListDiskRet, err error) {
-	err = c.GetWith(ctx, fmt.Sprintf(
+	err = c.readWith(ctx, fmt.Sprintf(
 		"/disk/list?idc=%s&rack=%s&host=%s&status=%d&marker=%d&count=%d",
 		options.Idc,
 		options.Rack,
@@ -0,0 +1,90 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+// BlobExpiry expiry of blobs [MinBid, MinBid+Count) in volume,
+// ExpireAt is unix timestamp in seconds.
+// Packed is the packed object in shared blob, Count is 1 if packed.
+// Owner is the kv key of the tenant owner whose usage is released after expired.
+type BlobExpiry struct {
+	Vid      proto.Vid         `json:"vid"`
+	MinBid   proto.BlobID      `json:"min_bid"`
+	Count    uint32            `json:"count"`
+	ExpireAt int64             `json:"expire_at"`
+	Packed   *proto.PackedBlob `json:"packed,omitempty"`
+	Owner    string            `json:"owner,omitempty"`
+}
+
+// IsValid returns true if blob expiry is valid
+func (b *BlobExpiry) IsValid() bool {
+	if b.Vid == proto.InvalidVid || b.MinBid == proto.InValidBlobID || b.Count == 0 {
+		return false
+	}
+	if b.ExpireAt <= 0 {
+		return false
+	}
+	if b.Packed != nil && (b.Count != 1 || !b.Packed.IsValid()) {
+		return false
+	}
+	return true
+}
+
+// IsValidToDelete returns true if blob expiry is valid to delete,
+// ExpireAt may be 0 to delete the record whatever the expiry is.
+func (b *BlobExpiry) IsValidToDelete() bool {
+	blob := *b
+	if blob.ExpireAt == 0 {
+		blob.ExpireAt = 1
+	}
+	return blob.IsValid()
+}
+
+type BlobExpiryArgs struct {
+	Blobs []BlobExpiry `json:"blobs"`
+}
+
+type ListExpiredBlobArgs struct {
+	Before int64 `json:"before"`
+	Count  int   `json:"count"`
+}
+
+type ListExpiredBlobRet struct {
+	Blobs []BlobExpiry `json:"blobs"`
+}
+
+// SetBlobExpiry records expiry of blobs
+func (c *Client) SetBlobExpiry(ctx context.Context, args *BlobExpiryArgs) (err error) {
+	err = c.PostWith(ctx, "/blob/expiry/set", nil, args)
+	return
+}
+
+// DeleteBlobExpiry deletes expiry records of blobs, ExpireAt may be 0
+func (c *Client) DeleteBlobExpiry(ctx context.Context, args *BlobExpiryArgs) (err error) {
+	err = c.PostWith(ctx, "/blob/expiry/delete", nil, args)
+	return
+}
+
+// ListExpiredBlob lists blobs expired before the unix timestamp in order of expiry
+func (c *Client) ListExpiredBlob(ctx context.Context, args *ListExpiredBlobArgs) (ret ListExpiredBlobRet, err error) {
+	err = c.GetWith(ctx, fmt.Sprintf("/blob/expiry/list?before=%d&count=%d", args.Before, args.Count), &ret)
+	return
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func TestBlobExpiryIsValid(t *testing.T) {
+	for _, cs := range []struct {
+		blob  BlobExpiry
+		valid bool
+	}{
+		{BlobExpiry{}, false},
+		{BlobExpiry{Vid: 1, MinBid: 1, Count: 1}, false},
+		{BlobExpiry{Vid: 1, MinBid: 1, ExpireAt: 1}, false},
+		{BlobExpiry{Vid: 1, Count: 1, ExpireAt: 1}, false},
+		{BlobExpiry{MinBid: 1, Count: 1, ExpireAt: 1}, false},
+		{BlobExpiry{Vid: 1, MinBid: 1, Count: 10, ExpireAt: 1}, true},
+		{BlobExpiry{Vid: 1, MinBid: 1, Count: 2, ExpireAt: 1, Packed: &proto.PackedBlob{Index: 0, Count: 2}}, false},
+		{BlobExpiry{Vid: 1, MinBid: 1, Count: 1, ExpireAt: 1, Packed: &proto.PackedBlob{Index: 2, Count: 2}}, false},
+		{BlobExpiry{Vid: 1, MinBid: 1, Count: 1, ExpireAt: 1, Packed: &proto.PackedBlob{Index: 1, Count: 2}}, true},
+	} {
+		require.Equal(t, cs.valid, cs.blob.IsValid())
+	}
+
+	blob := BlobExpiry{Vid: 1, MinBid: 1, Count: 1}
+	require.False(t, blob.IsValid())
+	require.True(t, blob.IsValidToDelete())
+	blob.Count = 0
+	require.False(t, blob.IsValidToDelete())
+}
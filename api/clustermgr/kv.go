@@ -0,0 +1,108 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// KeyValue the value is opaque to clustermgr, except the counter
+// which is 8 bytes big endian int64 changed by IncrKV.
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type GetKvArgs struct {
+	Key string `json:"key"`
+}
+
+type GetKvRet struct {
+	Value []byte `json:"value"`
+}
+
+type SetKvArgs struct {
+	Kvs []KeyValue `json:"kvs"`
+}
+
+type DeleteKvArgs struct {
+	Keys []string `json:"keys"`
+}
+
+// ListKvArgs list keys with prefix after marker
+type ListKvArgs struct {
+	Prefix string `json:"prefix,omitempty"`
+	Marker string `json:"marker,omitempty"`
+	Count  int    `json:"count,omitempty"`
+}
+
+// ListKvRet Marker is the next marker, empty if no more
+type ListKvRet struct {
+	Kvs    []KeyValue `json:"kvs"`
+	Marker string     `json:"marker"`
+}
+
+// IncrKvTokenPrefix prefix of keys recording the applied tokens of counters
+const IncrKvTokenPrefix = "_incr_token/"
+
+// IncrKvArgs adds Delta to the counter atomically, the new value
+// must be in [0, Max], Max of 0 means no limit. the counter is created
+// if not exists unless MustExist, and is deleted when it becomes 0.
+//
+// Token makes the incr idempotent if not empty, a positive Delta is
+// applied only if the token has not been recorded, and records it;
+// a negative Delta is applied only if the token has been recorded,
+// and removes it. A retried incr returns the current value.
+type IncrKvArgs struct {
+	Key       string `json:"key"`
+	Delta     int64  `json:"delta"`
+	Max       int64  `json:"max"`
+	MustExist bool   `json:"must_exist"`
+	Token     string `json:"token,omitempty"`
+}
+
+type IncrKvRet struct {
+	Value int64 `json:"value"`
+}
+
+// GetKV returns ErrKvNotFound if key not exists
+func (c *Client) GetKV(ctx context.Context, key string) (ret GetKvRet, err error) {
+	err = c.GetWith(ctx, "/kv/get?key="+url.QueryEscape(key), &ret)
+	return
+}
+
+func (c *Client) SetKV(ctx context.Context, args *SetKvArgs) (err error) {
+	err = c.PostWith(ctx, "/kv/set", nil, args)
+	return
+}
+
+func (c *Client) DeleteKV(ctx context.Context, args *DeleteKvArgs) (err error) {
+	err = c.PostWith(ctx, "/kv/delete", nil, args)
+	return
+}
+
+func (c *Client) ListKV(ctx context.Context, args *ListKvArgs) (ret ListKvRet, err error) {
+	err = c.GetWith(ctx, fmt.Sprintf("/kv/list?prefix=%s&marker=%s&count=%d",
+		url.QueryEscape(args.Prefix), url.QueryEscape(args.Marker), args.Count), &ret)
+	return
+}
+
+// IncrKV returns ErrKvNotFound or ErrKvCounterExceedLimit if not applied
+func (c *Client) IncrKV(ctx context.Context, args *IncrKvArgs) (ret IncrKvRet, err error) {
+	err = c.PostWith(ctx, "/kv/incr", &ret, args)
+	return
+}
@@ -52,6 +52,7 @@ type APIAccess interface {
 	GetConfig(ctx context.Context, key string) (string, error)
 	GetService(ctx context.Context, args GetServiceArgs) (ServiceInfo, error)
 	GetVolumeInfo(ctx context.Context, args *GetVolumeArgs) (*VolumeInfo, error)
+	GetVolumeRedirect(ctx context.Context, vid proto.Vid) (*VolumeRedirect, error)
 	DiskInfo(ctx context.Context, id proto.DiskID) (*blobnode.DiskInfo, error)
 }
 
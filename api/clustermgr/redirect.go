@@ -0,0 +1,62 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+// VolumeRedirect blobs of Vid were transcoded into NewVid with the same bids,
+// data of the new blob is the data shards of source blob encoded by CodeMode.
+type VolumeRedirect struct {
+	Vid         proto.Vid         `json:"vid"`
+	CodeMode    codemode.CodeMode `json:"code_mode"`
+	NewVid      proto.Vid         `json:"new_vid"`
+	NewCodeMode codemode.CodeMode `json:"new_code_mode"`
+}
+
+// VolumeRedirectPrefix prefix of volume redirect keys in kv
+const VolumeRedirectPrefix = "volume/redirect/"
+
+// VolumeRedirectKey returns key of volume redirect in kv
+func VolumeRedirectKey(vid proto.Vid) string {
+	return fmt.Sprintf("%s%d", VolumeRedirectPrefix, vid)
+}
+
+// GetVolumeRedirect returns ErrKvNotFound if the volume is not redirected
+func (c *Client) GetVolumeRedirect(ctx context.Context, vid proto.Vid) (ret *VolumeRedirect, err error) {
+	val, err := c.GetKV(ctx, VolumeRedirectKey(vid))
+	if err != nil {
+		return nil, err
+	}
+	ret = &VolumeRedirect{}
+	err = json.Unmarshal(val.Value, ret)
+	return
+}
+
+// SetVolumeRedirect redirects reads of args.Vid to args.NewVid
+func (c *Client) SetVolumeRedirect(ctx context.Context, args *VolumeRedirect) (err error) {
+	val, err := json.Marshal(args)
+	if err != nil {
+		return
+	}
+	err = c.SetKV(ctx, &SetKvArgs{Kvs: []KeyValue{{Key: VolumeRedirectKey(args.Vid), Value: val}}})
+	return
+}
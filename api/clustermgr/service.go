@@ -95,7 +95,7 @@ func (c *Client) UnregisterService(ctx context.Context, args UnregisterArgs) (er
 }
 
 func (c *Client) GetService(ctx context.Context, args GetServiceArgs) (info ServiceInfo, err error) {
-	err = c.GetWith(ctx, getserviceUrl+"?name="+args.Name, &info)
+	err = c.readWith(ctx, getserviceUrl+"?name="+args.Name, &info)
 	return
 }
 
@@ -64,7 +64,7 @@ type AllocVolumeInfo struct {
 
 func (c *Client) GetVolumeInfo(ctx context.Context, args *GetVolumeArgs) (ret *VolumeInfo, err error) {
 	ret = &VolumeInfo{}
-	err = c.GetWith(ctx, "/volume/get?vid="+args.Vid.ToString(), ret)
+	err = c.readWith(ctx, "/volume/get?vid="+args.Vid.ToString(), ret)
 	return
 }
 
@@ -85,7 +85,7 @@ type ListVolumes struct {
 }
 
 func (c *Client) ListVolume(ctx context.Context, args *ListVolumeArgs) (ret ListVolumes, err error) {
-	err = c.GetWith(ctx, fmt.Sprintf("/volume/list?marker=%d&count=%d", args.Marker, args.Count), &ret)
+	err = c.readWith(ctx, fmt.Sprintf("/volume/list?marker=%d&count=%d", args.Marker, args.Count), &ret)
 	return
 }
 
@@ -141,6 +141,8 @@ func (c *Client) RetainVolume(ctx context.Context, args *RetainVolumeArgs) (ret
 
 type LockVolumeArgs struct {
 	Vid proto.Vid `json:"vid"`
+	// Token allows locking an active volume allocated with this token
+	Token string `json:"token,omitempty"`
 }
 
 func (c *Client) LockVolume(ctx context.Context, args *LockVolumeArgs) (err error) {
@@ -0,0 +1,73 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+type WatchEventType uint8
+
+const (
+	WatchEventMin = WatchEventType(iota)
+	// WatchEventVolumeUnit volume unit was migrated or its epoch was changed
+	WatchEventVolumeUnit
+	// WatchEventDiskStatus disk status was changed
+	WatchEventDiskStatus
+	WatchEventMax
+)
+
+// WatchEvent change of volume unit or disk status, Index is the raft apply index of the change
+type WatchEvent struct {
+	Index  uint64           `json:"index"`
+	Type   WatchEventType   `json:"type"`
+	Vid    proto.Vid        `json:"vid,omitempty"`
+	Vuid   proto.Vuid       `json:"vuid,omitempty"`
+	DiskID proto.DiskID     `json:"disk_id,omitempty"`
+	Status proto.DiskStatus `json:"status,omitempty"`
+}
+
+type WatchArgs struct {
+	// Index watch the events whose index is larger than Index,
+	// 0 means returns the latest index of clustermgr without events
+	Index uint64 `json:"index"`
+	Count int    `json:"count"`
+	// TimeoutMs max time to wait when there is no event, 0 means no waiting
+	TimeoutMs int64 `json:"timeout_ms"`
+}
+
+type WatchRet struct {
+	// Index next watch should start from
+	Index  uint64       `json:"index"`
+	Events []WatchEvent `json:"events"`
+}
+
+// Watch long polls volume unit and disk status changes ordered by raft apply index,
+// returns ErrWatchIndexCompacted if events after args.Index have been discarded,
+// then caller should drop all cached volumes and disks, and watch from the latest index.
+// The wait is limited to half of the client timeout, so the long poll returns before
+// the request timed out.
+func (c *Client) Watch(ctx context.Context, args *WatchArgs) (ret *WatchRet, err error) {
+	timeoutMs := args.TimeoutMs
+	if c.timeoutMs > 0 && timeoutMs > c.timeoutMs/2 {
+		timeoutMs = c.timeoutMs / 2
+	}
+	ret = &WatchRet{}
+	err = c.GetWith(ctx, fmt.Sprintf("/watch?index=%d&count=%d&timeout_ms=%d", args.Index, args.Count, timeoutMs), ret)
+	return
+}
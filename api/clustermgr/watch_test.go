@@ -0,0 +1,49 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/common/rpc"
+)
+
+func TestWatchTimeout(t *testing.T) {
+	var timeouts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeouts = append(timeouts, r.URL.Query().Get("timeout_ms"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"index":1}`))
+	}))
+	defer server.Close()
+
+	for _, clientTimeoutMs := range []int64{0, 3000} {
+		cli := New(&Config{LbConfig: rpc.LbConfig{
+			Hosts: []string{server.URL}, Config: rpc.Config{ClientTimeoutMs: clientTimeoutMs},
+		}})
+		for _, timeoutMs := range []int64{0, 1000, 60000} {
+			ret, err := cli.Watch(context.Background(), &WatchArgs{Index: 1, TimeoutMs: timeoutMs})
+			require.NoError(t, err)
+			require.Equal(t, uint64(1), ret.Index)
+		}
+	}
+	// the server waits less than the client timeout
+	require.Equal(t, []string{"0", "1000", "60000", "0", "1000", "1500"}, timeouts)
+}
@@ -37,8 +37,9 @@ type DeleteArgs struct {
 }
 
 type BlobDelete struct {
-	Bid proto.BlobID `json:"bid"`
-	Vid proto.Vid    `json:"vid"`
+	Bid    proto.BlobID      `json:"bid"`
+	Vid    proto.Vid         `json:"vid"`
+	Packed *proto.PackedBlob `json:"packed,omitempty"`
 }
 
 type ShardRepairArgs struct {
@@ -41,10 +41,13 @@ type IScheduler interface {
 	BalanceTaskDetail(ctx context.Context, args *TaskStatArgs) (ret MigrateTaskDetail, err error)
 	DropTaskDetail(ctx context.Context, args *TaskStatArgs) (ret MigrateTaskDetail, err error)
 	ManualMigrateTaskDetail(ctx context.Context, args *TaskStatArgs) (ret MigrateTaskDetail, err error)
+	TranscodeTaskDetail(ctx context.Context, args *TaskStatArgs) (ret TranscodeTaskDetail, err error)
 	Stats(ctx context.Context) (ret TasksStat, err error)
 
 	// add manual migrate task
 	AddManualMigrateTask(ctx context.Context, args *AddManualMigrateArgs) (err error)
+	// add transcode task
+	AddTranscodeTask(ctx context.Context, args *AddTranscodeArgs) (err error)
 }
 
 type Config struct {
@@ -31,6 +31,7 @@ type WorkerTask struct {
 	Balance       *proto.MigrateTask   `json:"balance"`        // balance task
 	DiskDrop      *proto.MigrateTask   `json:"disk_drop"`      // disk drop task
 	ManualMigrate *proto.MigrateTask   `json:"manual_migrate"` // manual migrate task
+	Transcode     *proto.TranscodeTask `json:"transcode"`      // transcode task
 }
 
 func (task *WorkerTask) IsValid() bool {
@@ -56,6 +57,16 @@ func (task *WorkerTask) IsValid() bool {
 		mode = task.ManualMigrate.CodeMode
 		destination = task.ManualMigrate.Destination
 		srcs = task.ManualMigrate.Sources
+	case proto.TranscodeTaskType:
+		if !task.Transcode.DestCodeMode.IsValid() {
+			return false
+		}
+		if !proto.CheckVunitLocations(task.Transcode.Destinations) {
+			return false
+		}
+		mode = task.Transcode.CodeMode
+		destination = task.Transcode.GetDest()
+		srcs = task.Transcode.Sources
 	default:
 		return false
 	}
@@ -110,6 +121,7 @@ type TaskRenewalArgs struct {
 	Balance       map[string]struct{} `json:"balance"`
 	DiskDrop      map[string]struct{} `json:"disk_drop"`
 	ManualMigrate map[string]struct{} `json:"manual_migrate"`
+	Transcode     map[string]struct{} `json:"transcode"`
 }
 
 type TaskRenewalRet struct {
@@ -117,6 +129,7 @@ type TaskRenewalRet struct {
 	Balance       map[string]string `json:"balance"`
 	DiskDrop      map[string]string `json:"disk_drop"`
 	ManualMigrate map[string]string `json:"manual_migrate"`
+	Transcode     map[string]string `json:"transcode"`
 }
 
 func (c *client) RenewalTask(ctx context.Context, args *TaskRenewalArgs) (ret *TaskRenewalRet, err error) {
@@ -196,6 +209,20 @@ func (c *client) AddManualMigrateTask(ctx context.Context, args *AddManualMigrat
 	return c.PostWith(ctx, c.Host+"/manual/migrate/task/add", nil, args)
 }
 
+type AddTranscodeArgs struct {
+	Vid      proto.Vid         `json:"vid"`
+	CodeMode codemode.CodeMode `json:"code_mode"`
+}
+
+func (args *AddTranscodeArgs) Valid() bool {
+	return args.Vid > 0 && args.CodeMode.IsValid()
+}
+
+// AddTranscodeTask transcode blobs of a sealed volume into a new volume of CodeMode
+func (c *client) AddTranscodeTask(ctx context.Context, args *AddTranscodeArgs) (err error) {
+	return c.PostWith(ctx, c.Host+"/transcode/task/add", nil, args)
+}
+
 // for task stat
 type TaskStatArgs struct {
 	TaskId string `json:"task_id"`
@@ -211,6 +238,11 @@ type MigrateTaskDetail struct {
 	RunStats proto.TaskStatistics `json:"run_stats"`
 }
 
+type TranscodeTaskDetail struct {
+	TaskInfo proto.TranscodeTask  `json:"task_info"`
+	RunStats proto.TaskStatistics `json:"run_stats"`
+}
+
 type PerMinStats struct {
 	FinishedCnt    string `json:"finished_cnt"`
 	ShardCnt       string `json:"shard_cnt"`
@@ -252,6 +284,10 @@ type ManualMigrateTasksStat struct {
 	MigrateTasksStat
 }
 
+type TranscodeTasksStat struct {
+	MigrateTasksStat
+}
+
 type InspectTasksStats struct {
 	Switch         string `json:"switch"`
 	FinishedPerMin string `json:"finished_per_min"`
@@ -263,6 +299,7 @@ type TasksStat struct {
 	Drop          DiskDropTasksStat      `json:"drop"`
 	Balance       BalanceTasksStat       `json:"balance"`
 	ManualMigrate ManualMigrateTasksStat `json:"manual_migrate"`
+	Transcode     TranscodeTasksStat     `json:"transcode"`
 	Inspect       InspectTasksStats      `json:"inspect"`
 }
 
@@ -286,6 +323,11 @@ func (c *client) ManualMigrateTaskDetail(ctx context.Context, args *TaskStatArgs
 	return
 }
 
+func (c *client) TranscodeTaskDetail(ctx context.Context, args *TaskStatArgs) (ret TranscodeTaskDetail, err error) {
+	err = c.PostWith(ctx, c.Host+"/transcode/task/detail", &ret, args)
+	return
+}
+
 func (c *client) Stats(ctx context.Context) (ret TasksStat, err error) {
 	err = c.GetWith(ctx, c.Host+"/stats", &ret)
 	return
@@ -64,6 +64,7 @@ type Stat struct {
 type Stats struct {
 	ShardRepair Stat `json:"shard_repair"`
 	BlobDelete  Stat `json:"blob_delete"`
+	BlobExpiry  Stat `json:"blob_expiry"`
 }
 
 func (c *Client) Stats(ctx context.Context, host string) (ret Stats, err error) {
@@ -18,6 +18,7 @@ import (
 	"github.com/cubefs/blobstore/blobnode/base/flow"
 	"github.com/cubefs/blobstore/blobnode/base/priority"
 	"github.com/cubefs/blobstore/common/iostat"
+	"github.com/cubefs/blobstore/common/proto"
 )
 
 const (
@@ -28,8 +29,22 @@ type Config struct {
 	DiskBandwidthMBPS int64           `json:"disk_bandwidth_MBPS"`
 	DiskIOPS          int64           `json:"disk_iops"`
 	LevelConfigs      LevelConfig     `json:"flow_conf"`
+	LatencySLO        SLOConfig       `json:"latency_slo"`
 	DiskViewer        iostat.IOViewer `json:"-"`
 	StatGetter        flow.StatGetter `json:"-"`
+	// latency of normal io used by latency slo, closed with qos
+	LatencyViewer iostat.IOViewer `json:"-"`
+	DiskID        proto.DiskID    `json:"-"`
+}
+
+// SLOConfig scales budgets of background levels by read latency of normal io.
+// ReadLatencyTargetMs is the p99 target, which is measured against await.
+// Zero target disables the controller.
+type SLOConfig struct {
+	ReadLatencyTargetMs int64   `json:"read_latency_target_ms"`
+	IntervalMs          int64   `json:"interval_ms"`
+	MinFactor           float64 `json:"min_factor"`
+	Step                float64 `json:"step"`
 }
 
 type ParaConfig struct {
@@ -97,5 +112,26 @@ func initConfig(conf *Config) (err error) {
 		conf.DiskIOPS = thresholdNotSet
 	}
 
+	return initSLOConfig(&conf.LatencySLO)
+}
+
+func initSLOConfig(conf *SLOConfig) error {
+	if conf.ReadLatencyTargetMs < 0 || conf.IntervalMs < 0 || conf.MinFactor < 0 || conf.MinFactor > 1 ||
+		conf.Step < 0 || conf.Step >= 1 {
+		return ErrWrongConfig
+	}
+
+	if conf.IntervalMs == 0 {
+		conf.IntervalMs = defaultSLOIntervalMs
+	}
+
+	if conf.MinFactor == 0 {
+		conf.MinFactor = defaultSLOMinFactor
+	}
+
+	if conf.Step == 0 {
+		conf.Step = defaultSLOStep
+	}
+
 	return nil
 }
@@ -21,6 +21,7 @@ import (
 
 	"github.com/dustin/go-humanize"
 
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
 	"github.com/cubefs/blobstore/blobnode/base/limitio"
 	"github.com/cubefs/blobstore/blobnode/base/priority"
 	"github.com/cubefs/blobstore/common/iostat"
@@ -47,6 +48,7 @@ type LevelGetter interface {
 // Note: controllers that are not configured, nil
 type LevelManager struct {
 	levels []LevelQos
+	slo    *sloController
 }
 
 // Implementation of LevelQos interface
@@ -57,6 +59,7 @@ type levelQos struct {
 	iops      limitio.Controller
 	diskStat  iostat.IOViewer
 	threshold *Threshold
+	slo       *sloController // scales budgets of background level, may be nil
 }
 
 type rateReader struct {
@@ -172,20 +175,25 @@ func (h *levelQos) adjustCapacity() {
 	bps := rstat.Bps + wstat.Bps
 	iops := rstat.Iops + wstat.Iops
 
+	sloFactor := float64(1)
+	if h.slo != nil {
+		sloFactor = h.slo.getFactor()
+	}
+
 	if h.iops != nil {
-		capacity := int(h.threshold.Iops)
+		factor := sloFactor
 		if iops > uint64(h.threshold.DiskIOPS) {
-			capacity = int(float64(h.threshold.Iops) * h.threshold.Factor)
+			factor *= h.threshold.Factor
 		}
-		h.iops.UpdateCapacity(capacity)
+		h.iops.UpdateCapacity(int(float64(h.threshold.Iops) * factor))
 	}
 
 	if h.bps != nil {
-		capacity := int(h.threshold.Bandwidth)
+		factor := sloFactor
 		if bps > uint64(h.threshold.DiskBandwidth) {
-			capacity = int(float64(h.threshold.Bandwidth) * h.threshold.Factor)
+			factor *= h.threshold.Factor
 		}
-		h.bps.UpdateCapacity(capacity)
+		h.bps.UpdateCapacity(int(float64(h.threshold.Bandwidth) * factor))
 	}
 }
 
@@ -199,6 +207,12 @@ func (mgr *LevelManager) GetLevel(pri priority.Priority) LevelQos {
 	return mgr.levels[pri]
 }
 
+func (mgr *LevelManager) Close() {
+	if mgr.slo != nil {
+		mgr.slo.Close()
+	}
+}
+
 func NewLevelQos(threshold *Threshold, diskStat iostat.IOViewer) LevelQos {
 	qos := &levelQos{
 		diskStat:  diskStat,
@@ -252,5 +266,18 @@ func NewLevelQosMgr(conf Config, diskStat iostat.IOViewer) (*LevelManager, error
 		mgr.levels[prio] = levelController
 	}
 
+	if conf.LatencySLO.ReadLatencyTargetMs > 0 && conf.LatencyViewer != nil {
+		// all levels below normal io are background
+		normal := priority.GetPriority(bnapi.NormalIO)
+		background := make(map[priority.Priority]*levelQos)
+		for prio, level := range mgr.levels {
+			if level != nil && priority.Priority(prio) != normal {
+				background[priority.Priority(prio)] = level.(*levelQos)
+			}
+		}
+		mgr.slo = newSLOController(conf.LatencySLO, conf.DiskID.ToString(), conf.LatencyViewer, background)
+		go mgr.slo.run()
+	}
+
 	return mgr, nil
 }
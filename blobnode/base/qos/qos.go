@@ -34,6 +34,7 @@ type Qos interface {
 	WriterAt(context.Context, bnapi.IOType, io.WriterAt) io.WriterAt
 	Writer(context.Context, bnapi.IOType, io.Writer) io.Writer
 	Reader(context.Context, bnapi.IOType, io.Reader) io.Reader
+	Close()
 }
 
 func (qos *IOQos) getiostat(iot bnapi.IOType) (ios iostat.StatMgrAPI) {
@@ -103,6 +104,12 @@ func (qos *IOQos) Reader(ctx context.Context, ioType bnapi.IOType, reader io.Rea
 	return r
 }
 
+func (qos *IOQos) Close() {
+	if closer, ok := qos.LevelMgr.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
 func NewQosManager(conf Config) (Qos, error) {
 	// disk multi-level flow control
 	levelMgr, err := NewLevelQosMgr(conf, conf.DiskViewer)
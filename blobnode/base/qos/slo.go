@@ -0,0 +1,174 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package qos
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cubefs/blobstore/blobnode/base/priority"
+	"github.com/cubefs/blobstore/common/iostat"
+)
+
+const (
+	defaultSLOIntervalMs = 1000
+	defaultSLOMinFactor  = 0.1
+	defaultSLOStep       = 0.1
+	// grow background budgets only when latency falls well below the target
+	sloGrowWatermark = 0.8
+)
+
+const (
+	sloDecisionShrink = "shrink"
+	sloDecisionGrow   = "grow"
+	sloDecisionHold   = "hold"
+)
+
+var (
+	sloBudgetMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "blobstore",
+			Subsystem: "blobnode",
+			Name:      "qos_slo_budget",
+			Help:      "blobnode background io budgets of latency slo",
+		},
+		[]string{"disk_id", "level", "item"},
+	)
+	sloDecisionMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "blobstore",
+			Subsystem: "blobnode",
+			Name:      "qos_slo_decision",
+			Help:      "blobnode decisions of latency slo",
+		},
+		[]string{"disk_id", "decision"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(sloBudgetMetric, sloDecisionMetric)
+}
+
+// sloController shrinks the budgets of background levels multiplicatively
+// while await of normal reads exceeds the target, and grows them back
+// additively once latency recovers.
+type sloController struct {
+	conf    SLOConfig
+	diskID  string
+	latency iostat.IOViewer
+	levels  map[priority.Priority]*levelQos
+
+	factor int64 // math.Float64bits of budget factor, in [MinFactor, 1]
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newSLOController(conf SLOConfig, diskID string, latency iostat.IOViewer,
+	levels map[priority.Priority]*levelQos) *sloController {
+	c := &sloController{
+		conf:    conf,
+		diskID:  diskID,
+		latency: latency,
+		levels:  levels,
+		factor:  int64(math.Float64bits(1)),
+		done:    make(chan struct{}),
+	}
+	for _, level := range levels {
+		level.slo = c
+	}
+	c.report()
+	return c
+}
+
+func (c *sloController) getFactor() float64 {
+	return math.Float64frombits(uint64(atomic.LoadInt64(&c.factor)))
+}
+
+func (c *sloController) setFactor(f float64) {
+	atomic.StoreInt64(&c.factor, int64(math.Float64bits(f)))
+}
+
+func (c *sloController) run() {
+	ticker := time.NewTicker(time.Duration(c.conf.IntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.adjust()
+		}
+	}
+}
+
+// adjust makes one decision with the latest await of normal reads
+func (c *sloController) adjust() string {
+	await := c.latency.ReadStat().Await
+	target := c.conf.ReadLatencyTargetMs * int64(time.Millisecond)
+
+	factor := c.getFactor()
+	decision := sloDecisionHold
+	switch {
+	case await > target:
+		factor = math.Max(factor*(1-c.conf.Step), c.conf.MinFactor)
+		decision = sloDecisionShrink
+	case float64(await) < float64(target)*sloGrowWatermark && factor < 1:
+		factor = math.Min(factor+c.conf.Step, 1)
+		decision = sloDecisionGrow
+	}
+	c.setFactor(factor)
+
+	// apply new budgets at once, not until the next background io
+	for _, level := range c.levels {
+		level.adjustCapacity()
+	}
+
+	sloDecisionMetric.With(prometheus.Labels{"disk_id": c.diskID, "decision": decision}).Inc()
+	c.report()
+	return decision
+}
+
+func (c *sloController) report() {
+	factor := c.getFactor()
+	for pri, level := range c.levels {
+		budgets := map[string]float64{"factor": factor}
+		if level.iops != nil {
+			budgets["iops"] = float64(level.threshold.Iops) * factor
+		}
+		if level.bps != nil {
+			budgets["bps"] = float64(level.threshold.Bandwidth) * factor
+		}
+		for item, value := range budgets {
+			sloBudgetMetric.With(prometheus.Labels{
+				"disk_id": c.diskID,
+				"level":   pri.String(),
+				"item":    item,
+			}).Set(value)
+		}
+	}
+}
+
+func (c *sloController) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.latency.Close()
+	})
+}
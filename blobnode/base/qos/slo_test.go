@@ -0,0 +1,107 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package qos
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/blobnode/base/priority"
+	"github.com/cubefs/blobstore/common/iostat"
+)
+
+type mockLatencyViewer struct {
+	await  int64
+	closed int32
+}
+
+func (v *mockLatencyViewer) ReadStat() *iostat.StatData {
+	return &iostat.StatData{Await: atomic.LoadInt64(&v.await)}
+}
+
+func (v *mockLatencyViewer) WriteStat() *iostat.StatData { return &iostat.StatData{} }
+func (v *mockLatencyViewer) Update()                     {}
+func (v *mockLatencyViewer) Close()                      { atomic.AddInt32(&v.closed, 1) }
+
+func TestLatencySLO(t *testing.T) {
+	viewer := &mockLatencyViewer{}
+	conf := Config{
+		LevelConfigs: LevelConfig{
+			"level0": {Iops: 1000},
+			"level1": {Iops: 400, Bandwidth: 100},
+		},
+		LatencySLO: SLOConfig{
+			ReadLatencyTargetMs: 10,
+			IntervalMs:          3600 * 1000,
+			MinFactor:           0.25,
+			Step:                0.5,
+		},
+		LatencyViewer: viewer,
+	}
+	mgr, err := NewLevelQosMgr(conf, &mockLatencyViewer{})
+	require.NoError(t, err)
+	require.NotNil(t, mgr.slo)
+
+	// only background levels are controlled
+	require.Equal(t, 1, len(mgr.slo.levels))
+	require.Nil(t, mgr.levels[0].(*levelQos).slo)
+	level1 := mgr.levels[1].(*levelQos)
+	require.Equal(t, mgr.slo, level1.slo)
+	require.Equal(t, level1, mgr.slo.levels[priority.Priority(1)])
+
+	// latency exceeds target: shrink to min factor
+	atomic.StoreInt64(&viewer.await, int64(20*time.Millisecond))
+	require.Equal(t, sloDecisionShrink, mgr.slo.adjust())
+	require.Equal(t, 0.5, mgr.slo.getFactor())
+	require.Equal(t, sloDecisionShrink, mgr.slo.adjust())
+	require.Equal(t, 0.25, mgr.slo.getFactor())
+	require.Equal(t, sloDecisionShrink, mgr.slo.adjust())
+	require.Equal(t, 0.25, mgr.slo.getFactor())
+
+	// latency near target: hold
+	atomic.StoreInt64(&viewer.await, int64(9*time.Millisecond))
+	require.Equal(t, sloDecisionHold, mgr.slo.adjust())
+	require.Equal(t, 0.25, mgr.slo.getFactor())
+
+	// latency recovers: grow back to full budgets
+	atomic.StoreInt64(&viewer.await, int64(time.Millisecond))
+	require.Equal(t, sloDecisionGrow, mgr.slo.adjust())
+	require.Equal(t, 0.75, mgr.slo.getFactor())
+	require.Equal(t, sloDecisionGrow, mgr.slo.adjust())
+	require.Equal(t, float64(1), mgr.slo.getFactor())
+	require.Equal(t, sloDecisionHold, mgr.slo.adjust())
+
+	mgr.Close()
+	mgr.Close()
+	require.Equal(t, int32(1), atomic.LoadInt32(&viewer.closed))
+
+	// disabled
+	conf.LatencySLO.ReadLatencyTargetMs = 0
+	mgr, err = NewLevelQosMgr(conf, &mockLatencyViewer{})
+	require.NoError(t, err)
+	require.Nil(t, mgr.slo)
+	mgr.Close()
+
+	// wrong config
+	conf.LatencySLO = SLOConfig{ReadLatencyTargetMs: 10, Step: 1}
+	_, err = NewLevelQosMgr(conf, nil)
+	require.ErrorIs(t, err, ErrWrongConfig)
+	conf.LatencySLO = SLOConfig{ReadLatencyTargetMs: 10, MinFactor: 2}
+	_, err = NewLevelQosMgr(conf, nil)
+	require.ErrorIs(t, err, ErrWrongConfig)
+}
@@ -17,6 +17,7 @@ package blobnode
 import (
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
 	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/chunk"
 	"github.com/cubefs/blobstore/blobnode/core/disk"
 	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/rpc"
@@ -381,3 +382,140 @@ func (s *Service) ChunkStat_(c *rpc.Context) {
 	chunk := cs.ChunkInfo(ctx)
 	c.RespondJSON(&chunk)
 }
+
+/*
+ *  method:         GET
+ *  url:            /chunk/export/diskid/{diskid}/vuid/{vuid}
+ *  response body:  tar archive of chunk
+ */
+func (s *Service) ChunkExport_(c *rpc.Context) {
+	args := new(bnapi.ExportChunkArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Infof("chunk export args:%v", args)
+
+	if !bnapi.IsValidDiskID(args.DiskID) {
+		c.RespondError(bloberr.ErrInvalidDiskId)
+		return
+	}
+
+	s.lock.RLock()
+	ds, exist := s.Disks[args.DiskID]
+	s.lock.RUnlock()
+	if !exist {
+		span.Errorf("diskId:%d not exist", args.DiskID)
+		c.RespondError(bloberr.ErrNoSuchDisk)
+		return
+	}
+
+	cs, exist := ds.GetChunkStorage(args.Vuid)
+	if !exist {
+		span.Errorf("vuid:%d not exist", args.Vuid)
+		c.RespondError(bloberr.ErrNoSuchVuid)
+		return
+	}
+
+	// shards of readonly chunk are stable during export
+	if cs.Status() != bnapi.ChunkStatusReadOnly {
+		span.Errorf("chunk:%s status:%v is not readonly", cs.ID(), cs.Status())
+		c.RespondError(bloberr.ErrChunkNotReadonly)
+		return
+	}
+
+	ctx = bnapi.Setiotype(ctx, bnapi.BackgroundIO)
+
+	c.Writer.Header().Set(rpc.HeaderContentType, "application/x-tar")
+	if err := chunk.ExportChunk(ctx, cs, c.Writer); err != nil {
+		// the archive is truncated, which will be rejected by import
+		span.Errorf("Failed export chunk:%s, err:%v", cs.ID(), err)
+		return
+	}
+}
+
+/*
+ *  method:         POST
+ *  url:            /chunk/import/diskid/{diskid}/vuid/{vuid}
+ *  request body:   tar archive of chunk
+ *  response body:  json.Marshal(ChunkInfo)
+ */
+func (s *Service) ChunkImport_(c *rpc.Context) {
+	args := new(bnapi.ImportChunkArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Infof("chunk import args:%v", args)
+
+	if !bnapi.IsValidDiskID(args.DiskID) {
+		c.RespondError(bloberr.ErrInvalidDiskId)
+		return
+	}
+
+	limitKey := args.Vuid
+	err := s.ChunkLimitPerVuid.Acquire(limitKey)
+	if err != nil {
+		span.Errorf("can not import chunk with same vuid(%v) at the same time", args.Vuid)
+		c.RespondError(bloberr.ErrOutOfLimit)
+		return
+	}
+	defer s.ChunkLimitPerVuid.Release(limitKey)
+
+	s.lock.RLock()
+	ds, exist := s.Disks[args.DiskID]
+	s.lock.RUnlock()
+	if !exist {
+		span.Errorf("diskId:%d not exist", args.DiskID)
+		c.RespondError(bloberr.ErrNoSuchDisk)
+		return
+	}
+
+	ar, err := chunk.NewArchiveReader(c.Request.Body)
+	if err != nil {
+		span.Errorf("Failed read archive header, err:%v", err)
+		c.RespondError(bloberr.ErrInvalidParam)
+		return
+	}
+	if err = ar.CheckVuid(args.Vuid); err != nil {
+		span.Errorf("vuid:%d not match archive vuid:%d", args.Vuid, ar.Header.Chunk.Vuid)
+		c.RespondError(bloberr.ErrInvalidParam)
+		return
+	}
+
+	chunkSize := ar.Header.Chunk.ChunkSize
+	if chunkSize <= 0 || chunkSize > disk.MaxChunkSize {
+		chunkSize = core.DefaultChunkSize
+	}
+
+	cs, err := ds.CreateChunk(ctx, args.Vuid, chunkSize)
+	if err != nil {
+		span.Errorf("Failed register vuid:%v, err:%v", args.Vuid, err)
+		c.RespondError(err)
+		return
+	}
+
+	ctx = bnapi.Setiotype(ctx, bnapi.BackgroundIO)
+
+	if err = ar.ImportTo(ctx, cs); err != nil {
+		span.Errorf("Failed import chunk:%s, err:%v", cs.ID(), err)
+		if rerr := ds.ReleaseChunk(ctx, args.Vuid, true); rerr != nil {
+			span.Errorf("Failed release chunk:%s, err:%v", cs.ID(), rerr)
+		}
+		c.RespondError(err)
+		return
+	}
+
+	span.Infof("import vuid:%d success, chunk:%s from chunk:%s", args.Vuid, cs.ID(), ar.Header.Chunk.ChunkId)
+
+	info := cs.ChunkInfo(ctx)
+	c.RespondJSON(&info)
+}
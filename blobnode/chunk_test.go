@@ -15,14 +15,18 @@
 package blobnode
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
 	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
 	"github.com/cubefs/blobstore/common/trace"
 	"github.com/cubefs/blobstore/util/log"
 )
@@ -265,3 +269,73 @@ func TestListChunk(t *testing.T) {
 		}
 	}
 }
+
+func TestExportImportChunk(t *testing.T) {
+	service, _ := newTestBlobNodeService(t, "ExportImportChunk")
+	defer cleanTestBlobNodeService(service)
+
+	host := runTestServer(service)
+	client := bnapi.New(&bnapi.Config{})
+
+	ctx := context.TODO()
+
+	diskID, vuid, newVuid := proto.DiskID(101), proto.Vuid(2001), proto.Vuid(3001)
+	err := client.CreateChunk(ctx, host, &bnapi.CreateChunkArgs{DiskID: diskID, Vuid: vuid})
+	require.NoError(t, err)
+
+	data := []byte("chunk export and import")
+	for bid := proto.BlobID(1); bid <= 3; bid++ {
+		_, err = client.PutShard(ctx, host, &bnapi.PutShardArgs{
+			DiskID: diskID, Vuid: vuid, Bid: bid, Size: int64(len(data)), Body: bytes.NewReader(data),
+		})
+		require.NoError(t, err)
+	}
+
+	// err: chunk is not readonly
+	exportArgs := &bnapi.ExportChunkArgs{DiskID: diskID, Vuid: vuid}
+	_, err = client.ExportChunk(ctx, host, exportArgs)
+	require.Equal(t, bloberr.CodeChunkNotReadonly, rpc.DetectStatusCode(err))
+
+	err = client.SetChunkReadonly(ctx, host, &bnapi.ChangeChunkStatusArgs{DiskID: diskID, Vuid: vuid})
+	require.NoError(t, err)
+
+	body, err := client.ExportChunk(ctx, host, exportArgs)
+	require.NoError(t, err)
+	archive, err := ioutil.ReadAll(body)
+	body.Close()
+	require.NoError(t, err)
+
+	// err: broken archive
+	_, err = client.ImportChunk(ctx, host, &bnapi.ImportChunkArgs{
+		DiskID: diskID, Vuid: newVuid, Body: bytes.NewReader(archive[:100]),
+	})
+	require.Error(t, err)
+
+	// err: archive of another volume unit
+	_, err = client.ImportChunk(ctx, host, &bnapi.ImportChunkArgs{
+		DiskID: diskID, Vuid: proto.EncodeVuid(proto.EncodeVuidPrefix(1, 0), 1), Body: bytes.NewReader(archive),
+	})
+	require.Equal(t, bloberr.CodeInvalidParam, rpc.DetectStatusCode(err))
+
+	ci, err := client.ImportChunk(ctx, host, &bnapi.ImportChunkArgs{
+		DiskID: diskID, Vuid: newVuid, Body: bytes.NewReader(archive),
+	})
+	require.NoError(t, err)
+	require.Equal(t, newVuid, ci.Vuid)
+	require.Equal(t, bnapi.ChunkStatusNormal, ci.Status)
+
+	for bid := proto.BlobID(1); bid <= 3; bid++ {
+		body, _, err := client.GetShard(ctx, host, &bnapi.GetShardArgs{DiskID: diskID, Vuid: newVuid, Bid: bid})
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(body)
+		body.Close()
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+	}
+
+	// err: vuid already exists
+	_, err = client.ImportChunk(ctx, host, &bnapi.ImportChunkArgs{
+		DiskID: diskID, Vuid: newVuid, Body: bytes.NewReader(archive),
+	})
+	require.Error(t, err)
+}
@@ -18,7 +18,9 @@ import (
 	"os"
 
 	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/api/mqproxy"
 	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/cache"
 	"github.com/cubefs/blobstore/blobnode/db"
 	"github.com/cubefs/blobstore/cmd"
 	"github.com/cubefs/blobstore/util/log"
@@ -45,8 +47,12 @@ type Config struct {
 	DiskConfig    core.RuntimeConfig `json:"disk_config"`
 	MetaConfig    db.MetaConfig      `json:"meta_config"`
 	FlockFilename string             `json:"flock_filename"`
+	// ShardCache caches hot shards of hdd chunks on ssd paths, disabled if no paths
+	ShardCache cache.Config `json:"shard_cache"`
 
 	Clustermgr *cmapi.Config `json:"clustermgr"`
+	// MQProxy receives repair messages of bad shards found by scrubbing
+	MQProxy mqproxy.LbConfig `json:"mqproxy"`
 
 	HeartbeatIntervalSec        int `json:"heartbeat_interval_S"`
 	ChunkReportIntervalSec      int `json:"chunk_report_interval_S"`
@@ -39,6 +39,7 @@ type BlobFile interface {
 	Allocate(off int64, size int64) (err error)
 	Discard(off int64, size int64) (err error)
 	SysStat() (sysstat syscall.Stat_t, err error)
+	DataSync() error
 }
 
 type blobFile struct {
@@ -102,6 +103,12 @@ func (ef *blobFile) Sync() error {
 	return err
 }
 
+func (ef *blobFile) DataSync() error {
+	err := sys.Fdatasync(ef.file.Fd())
+	ef.handleError(err)
+	return err
+}
+
 func (ef *blobFile) Close() error {
 	return ef.file.Close()
 }
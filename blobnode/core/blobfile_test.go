@@ -70,6 +70,9 @@ func TestBlobFile_Op(t *testing.T) {
 
 	require.Equal(t, data, buf)
 
+	// data sync
+	require.NoError(t, ef.DataSync())
+
 	// stat
 	stat, err := ef.SysStat()
 	require.NoError(t, err)
@@ -0,0 +1,390 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+/*
+ * shard cache keeps hot shards of hdd chunks on ssd paths:
+ *   ${path}/${vuid}-${bid} => shard body
+ * shards are promoted when they are read frequently, evicted in lru order,
+ * and validated with the shard meta of chunk before being served.
+ * the index is rebuilt from the files when blobnode restarts.
+ */
+
+const (
+	DefaultCapacityB       = 64 * (1 << 30) // 64 GiB
+	DefaultMaxShardSizeB   = 1 << 20        // 1 MiB
+	DefaultPromoteHits     = 3
+	DefaultHitWindowS      = 60
+	DefaultFillConcurrency = 4
+
+	tmpSuffix  = ".tmp"
+	maxTracked = 1 << 20 // max tracked shards in one hit window
+)
+
+var ErrShardTooLarge = errors.New("shard too large to cache")
+
+type Config struct {
+	Paths           []string `json:"paths"`            // ssd paths, empty means disabled
+	CapacityB       int64    `json:"capacity_B"`       // capacity of each path
+	MaxShardSizeB   int64    `json:"max_shard_size_B"` // larger shards are not cached
+	PromoteHits     int      `json:"promote_hits"`     // reads in one window to promote
+	HitWindowS      int64    `json:"hit_window_S"`     // window of counting reads
+	FillConcurrency int      `json:"fill_concurrency"` // concurrent promotions, dropped if busy
+}
+
+type Key struct {
+	Vuid proto.Vuid
+	Bid  proto.BlobID
+}
+
+func (k Key) name() string {
+	return fmt.Sprintf("%016x-%016x", uint64(k.Vuid), uint64(k.Bid))
+}
+
+func parseKey(name string) (k Key, ok bool) {
+	var vuid, bid uint64
+	if n, err := fmt.Sscanf(name, "%016x-%016x", &vuid, &bid); err != nil || n != 2 {
+		return k, false
+	}
+	k = Key{Vuid: proto.Vuid(vuid), Bid: proto.BlobID(bid)}
+	return k, k.name() == name
+}
+
+type entry struct {
+	key  Key
+	size int64
+}
+
+// tier is the lru cache of one ssd path
+type tier struct {
+	dir      string
+	capacity int64
+
+	lock    sync.Mutex
+	used    int64
+	lru     *list.List // front is the most recently used
+	entries map[Key]*list.Element
+}
+
+func newTier(ctx context.Context, dir string, capacity int64) (t *tier, err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	t = &tier{
+		dir:      dir,
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[Key]*list.Element),
+	}
+
+	// rebuild the index, files are promoted in order of modify time
+	sort.Slice(fis, func(i, j int) bool {
+		return fis[i].ModTime().Before(fis[j].ModTime())
+	})
+	for _, fi := range fis {
+		key, ok := parseKey(fi.Name())
+		if !ok || !fi.Mode().IsRegular() {
+			span.Warnf("remove unknown cache file:%s", fi.Name())
+			os.RemoveAll(filepath.Join(dir, fi.Name()))
+			continue
+		}
+		t.entries[key] = t.lru.PushFront(&entry{key: key, size: fi.Size()})
+		t.used += fi.Size()
+	}
+	t.evict()
+
+	span.Infof("shard cache:%s loaded, shards:%d, used:%d", dir, len(t.entries), t.used)
+	return t, nil
+}
+
+func (t *tier) file(key Key) string {
+	return filepath.Join(t.dir, key.name())
+}
+
+func (t *tier) contains(key Key) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	_, ok := t.entries[key]
+	return ok
+}
+
+func (t *tier) get(key Key) ([]byte, error) {
+	t.lock.Lock()
+	elem, ok := t.entries[key]
+	if !ok {
+		t.lock.Unlock()
+		return nil, os.ErrNotExist
+	}
+	t.lru.MoveToFront(elem)
+	t.lock.Unlock()
+
+	return ioutil.ReadFile(t.file(key))
+}
+
+func (t *tier) put(key Key, data []byte) error {
+	file := t.file(key)
+	if err := ioutil.WriteFile(file+tmpSuffix, data, 0o644); err != nil {
+		os.Remove(file + tmpSuffix)
+		return err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// rename under lock, the file is removed with the entry
+	if err := os.Rename(file+tmpSuffix, file); err != nil {
+		os.Remove(file + tmpSuffix)
+		return err
+	}
+	if elem, ok := t.entries[key]; ok {
+		t.removeElement(elem)
+	}
+	t.entries[key] = t.lru.PushFront(&entry{key: key, size: int64(len(data))})
+	t.used += int64(len(data))
+	t.evict()
+	return nil
+}
+
+func (t *tier) delete(key Key) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if elem, ok := t.entries[key]; ok {
+		t.removeElement(elem)
+		os.Remove(t.file(key))
+	}
+}
+
+// evict removes the least recently used shards until under capacity
+func (t *tier) evict() {
+	for t.used > t.capacity {
+		elem := t.lru.Back()
+		if elem == nil {
+			return
+		}
+		t.removeElement(elem)
+		os.Remove(t.file(elem.Value.(*entry).key))
+	}
+}
+
+func (t *tier) removeElement(elem *list.Element) {
+	e := t.lru.Remove(elem).(*entry)
+	delete(t.entries, e.key)
+	t.used -= e.size
+}
+
+// ShardCache caches hot shards on ssd paths
+type ShardCache struct {
+	conf  Config
+	tiers []*tier
+
+	hitLock  sync.Mutex
+	hits     map[Key]int
+	hitReset time.Time
+	filling  map[Key]struct{}
+
+	fillSem chan struct{}
+	wg      sync.WaitGroup
+}
+
+func initConfig(conf *Config) {
+	if conf.CapacityB <= 0 {
+		conf.CapacityB = DefaultCapacityB
+	}
+	if conf.MaxShardSizeB <= 0 {
+		conf.MaxShardSizeB = DefaultMaxShardSizeB
+	}
+	if conf.PromoteHits <= 0 {
+		conf.PromoteHits = DefaultPromoteHits
+	}
+	if conf.HitWindowS <= 0 {
+		conf.HitWindowS = DefaultHitWindowS
+	}
+	if conf.FillConcurrency <= 0 {
+		conf.FillConcurrency = DefaultFillConcurrency
+	}
+}
+
+func NewShardCache(ctx context.Context, conf Config) (c *ShardCache, err error) {
+	if len(conf.Paths) == 0 {
+		return nil, errors.New("paths of shard cache are not specified")
+	}
+	initConfig(&conf)
+
+	c = &ShardCache{
+		conf:     conf,
+		hits:     make(map[Key]int),
+		hitReset: time.Now().Add(time.Duration(conf.HitWindowS) * time.Second),
+		filling:  make(map[Key]struct{}),
+		fillSem:  make(chan struct{}, conf.FillConcurrency),
+	}
+	for _, path := range conf.Paths {
+		t, err := newTier(ctx, path, conf.CapacityB)
+		if err != nil {
+			return nil, err
+		}
+		c.tiers = append(c.tiers, t)
+	}
+
+	return c, nil
+}
+
+func (c *ShardCache) tier(key Key) *tier {
+	return c.tiers[(uint64(key.Vuid)+uint64(key.Bid))%uint64(len(c.tiers))]
+}
+
+// Get returns the cached shard body, which matches size and crc of the meta.
+// broken or stale shard is removed.
+func (c *ShardCache) Get(ctx context.Context, key Key, meta *core.ShardMeta) (data []byte, ok bool) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	t := c.tier(key)
+	data, err := t.get(key)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			span.Warnf("read cached shard:%+v failed, err:%v", key, err)
+			t.delete(key)
+		}
+		return nil, false
+	}
+	if len(data) != int(meta.Size) || crc32.ChecksumIEEE(data) != meta.Crc {
+		span.Warnf("cached shard:%+v not match meta:%+v, size:%d", key, meta, len(data))
+		t.delete(key)
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Access counts the read of shard, returns true if it should be promoted
+func (c *ShardCache) Access(key Key, size uint32) bool {
+	if int64(size) > c.conf.MaxShardSizeB {
+		return false
+	}
+
+	c.hitLock.Lock()
+	defer c.hitLock.Unlock()
+
+	if now := time.Now(); now.After(c.hitReset) || len(c.hits) >= maxTracked {
+		c.hits = make(map[Key]int)
+		c.hitReset = now.Add(time.Duration(c.conf.HitWindowS) * time.Second)
+	}
+	c.hits[key]++
+	if c.hits[key] < c.conf.PromoteHits {
+		return false
+	}
+	if _, ok := c.filling[key]; ok {
+		return false
+	}
+	return !c.tier(key).contains(key)
+}
+
+// Fill promotes the shard in background, the data read is checked with crc.
+// It is dropped if there are too many promotions.
+func (c *ShardCache) Fill(ctx context.Context, key Key, crc uint32, read func(ctx context.Context) ([]byte, error)) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	c.hitLock.Lock()
+	if _, ok := c.filling[key]; ok {
+		c.hitLock.Unlock()
+		return
+	}
+	select {
+	case c.fillSem <- struct{}{}:
+	default:
+		c.hitLock.Unlock()
+		span.Debugf("too many shards filling, drop:%+v", key)
+		return
+	}
+	c.filling[key] = struct{}{}
+	delete(c.hits, key)
+	c.hitLock.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer func() {
+			c.hitLock.Lock()
+			delete(c.filling, key)
+			c.hitLock.Unlock()
+			<-c.fillSem
+			c.wg.Done()
+		}()
+
+		data, err := read(ctx)
+		if err == nil && crc32.ChecksumIEEE(data) != crc {
+			err = fmt.Errorf("crc not match, expected:%d", crc)
+		}
+		if err == nil {
+			err = c.Put(ctx, key, data)
+		}
+		if err != nil {
+			span.Warnf("fill shard:%+v failed, err:%v", key, err)
+		}
+	}()
+}
+
+// Put caches the shard body
+func (c *ShardCache) Put(ctx context.Context, key Key, data []byte) error {
+	if int64(len(data)) > c.conf.MaxShardSizeB {
+		return ErrShardTooLarge
+	}
+	return c.tier(key).put(key, data)
+}
+
+// Delete removes the shard, it's called when the shard is deleted in chunk
+func (c *ShardCache) Delete(ctx context.Context, key Key) {
+	c.tier(key).delete(key)
+}
+
+// Close waits the running promotions
+func (c *ShardCache) Close() {
+	c.wg.Wait()
+}
+
+// Stat returns the cached shard count and used bytes
+func (c *ShardCache) Stat() (count int, used int64) {
+	for _, t := range c.tiers {
+		t.lock.Lock()
+		count += len(t.entries)
+		used += t.used
+		t.lock.Unlock()
+	}
+	return count, used
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func shardMeta(data []byte) *core.ShardMeta {
+	return &core.ShardMeta{Size: uint32(len(data)), Crc: crc32.ChecksumIEEE(data)}
+}
+
+func TestShardCacheGetPut(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "ShardCacheGetPut")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	_, err = NewShardCache(ctx, Config{})
+	require.Error(t, err)
+
+	c, err := NewShardCache(ctx, Config{Paths: []string{testDir}, MaxShardSizeB: 8})
+	require.NoError(t, err)
+	defer c.Close()
+
+	key := Key{Vuid: 1, Bid: 2}
+	data := []byte("data")
+	_, ok := c.Get(ctx, key, shardMeta(data))
+	require.False(t, ok)
+
+	require.NoError(t, c.Put(ctx, key, data))
+	got, ok := c.Get(ctx, key, shardMeta(data))
+	require.True(t, ok)
+	require.Equal(t, data, got)
+	require.ErrorIs(t, c.Put(ctx, Key{Vuid: 1, Bid: 3}, make([]byte, 9)), ErrShardTooLarge)
+
+	// stale shard is removed
+	_, ok = c.Get(ctx, key, shardMeta([]byte("new data")))
+	require.False(t, ok)
+	count, used := c.Stat()
+	require.Equal(t, 0, count)
+	require.Equal(t, int64(0), used)
+	_, err = os.Stat(filepath.Join(testDir, key.name()))
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, c.Put(ctx, key, data))
+	c.Delete(ctx, key)
+	_, ok = c.Get(ctx, key, shardMeta(data))
+	require.False(t, ok)
+}
+
+func TestShardCacheEvictAndRebuild(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "ShardCacheEvict")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	conf := Config{Paths: []string{testDir}, CapacityB: 30, MaxShardSizeB: 10}
+	c, err := NewShardCache(ctx, conf)
+	require.NoError(t, err)
+
+	datas := make(map[Key][]byte)
+	for bid := proto.BlobID(1); bid <= 3; bid++ {
+		key := Key{Vuid: 1, Bid: bid}
+		datas[key] = bytes.Repeat([]byte{byte(bid)}, 10)
+		require.NoError(t, c.Put(ctx, key, datas[key]))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 1 is recently used, 2 is evicted
+	_, ok := c.Get(ctx, Key{Vuid: 1, Bid: 1}, shardMeta(datas[Key{Vuid: 1, Bid: 1}]))
+	require.True(t, ok)
+	key := Key{Vuid: 1, Bid: 4}
+	datas[key] = bytes.Repeat([]byte{4}, 10)
+	require.NoError(t, c.Put(ctx, key, datas[key]))
+	_, ok = c.Get(ctx, Key{Vuid: 1, Bid: 2}, shardMeta(datas[Key{Vuid: 1, Bid: 2}]))
+	require.False(t, ok)
+	count, used := c.Stat()
+	require.Equal(t, 3, count)
+	require.Equal(t, int64(30), used)
+	c.Close()
+
+	// unknown files are removed
+	require.NoError(t, ioutil.WriteFile(filepath.Join(testDir, key.name()+tmpSuffix), []byte("tmp"), 0o644))
+
+	c, err = NewShardCache(ctx, conf)
+	require.NoError(t, err)
+	defer c.Close()
+	count, used = c.Stat()
+	require.Equal(t, 3, count)
+	require.Equal(t, int64(30), used)
+	for _, bid := range []proto.BlobID{1, 3, 4} {
+		key := Key{Vuid: 1, Bid: bid}
+		got, ok := c.Get(ctx, key, shardMeta(datas[key]))
+		require.True(t, ok)
+		require.Equal(t, datas[key], got)
+	}
+	_, err = os.Stat(filepath.Join(testDir, key.name()+tmpSuffix))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestShardCachePromote(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "ShardCachePromote")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	c, err := NewShardCache(ctx, Config{Paths: []string{testDir}, MaxShardSizeB: 8, PromoteHits: 2})
+	require.NoError(t, err)
+	defer c.Close()
+
+	key := Key{Vuid: 1, Bid: 1}
+	data := []byte("data")
+	require.False(t, c.Access(key, 9))
+	require.False(t, c.Access(key, 4))
+	require.True(t, c.Access(key, 4))
+
+	// broken data is not cached
+	c.Fill(ctx, key, crc32.ChecksumIEEE(data), func(ctx context.Context) ([]byte, error) {
+		return []byte("broken"), nil
+	})
+	c.Close()
+	_, ok := c.Get(ctx, key, shardMeta(data))
+	require.False(t, ok)
+
+	c.Fill(ctx, key, crc32.ChecksumIEEE(data), func(ctx context.Context) ([]byte, error) {
+		return nil, errors.New("read failed")
+	})
+	c.Close()
+	_, ok = c.Get(ctx, key, shardMeta(data))
+	require.False(t, ok)
+
+	require.False(t, c.Access(key, 4))
+	require.True(t, c.Access(key, 4))
+	c.Fill(ctx, key, crc32.ChecksumIEEE(data), func(ctx context.Context) ([]byte, error) {
+		return data, nil
+	})
+	c.Close()
+	got, ok := c.Get(ctx, key, shardMeta(data))
+	require.True(t, ok)
+	require.Equal(t, data, got)
+	require.False(t, c.Access(key, 4))
+}
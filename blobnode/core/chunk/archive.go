@@ -0,0 +1,256 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package chunk
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+/*
+ * chunk archive is a tar stream for offline transfer of chunks:
+ *	chunk.json       : ArchiveHeader, vuid meta and metas of all shards
+ *	shards/${bid}    : data of shard, one entry per shard in header
+ * shards are verified by crc in header when imported.
+ */
+
+const (
+	archiveVersion      = 1
+	archiveHeaderName   = "chunk.json"
+	archiveShardPrefix  = "shards/"
+	archiveListPageSize = 1024
+)
+
+var (
+	ErrArchiveVersion    = errors.New("chunk archive version not supported")
+	ErrArchiveHeader     = errors.New("chunk archive header not found")
+	ErrArchiveShard      = errors.New("chunk archive shard not match header")
+	ErrArchiveCrc        = errors.New("chunk archive shard crc not match")
+	ErrArchiveIncomplete = errors.New("chunk archive shards incomplete")
+	ErrArchiveNotEmpty   = errors.New("chunk to import is not empty")
+	ErrArchiveVuid       = errors.New("chunk archive vuid not match")
+)
+
+type ArchiveHeader struct {
+	Version int                `json:"version"`
+	Chunk   core.VuidMeta      `json:"chunk"`
+	Shards  []*bnapi.ShardInfo `json:"shards"`
+}
+
+func listAllShards(ctx context.Context, cs core.ChunkAPI) (shards []*bnapi.ShardInfo, err error) {
+	startBid := proto.InValidBlobID
+	for {
+		infos, next, err := cs.ListShards(ctx, startBid, archiveListPageSize, bnapi.ShardStatusDefault)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, infos...)
+		if next == proto.InValidBlobID || len(infos) == 0 {
+			return shards, nil
+		}
+		startBid = next
+	}
+}
+
+// ExportChunk writes archive of chunk into w, the chunk should be readonly
+func ExportChunk(ctx context.Context, cs core.ChunkAPI, w io.Writer) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	shards, err := listAllShards(ctx, cs)
+	if err != nil {
+		return err
+	}
+
+	hdr, err := json.Marshal(&ArchiveHeader{
+		Version: archiveVersion,
+		Chunk:   *cs.VuidMeta(),
+		Shards:  shards,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tw := tar.NewWriter(w)
+	if err = tw.WriteHeader(&tar.Header{
+		Name: archiveHeaderName, Mode: 0o644, Size: int64(len(hdr)), ModTime: now,
+	}); err != nil {
+		return err
+	}
+	if _, err = tw.Write(hdr); err != nil {
+		return err
+	}
+
+	for _, si := range shards {
+		if err = tw.WriteHeader(&tar.Header{
+			Name: archiveShardPrefix + strconv.FormatUint(uint64(si.Bid), 10), Mode: 0o644, Size: si.Size, ModTime: now,
+		}); err != nil {
+			return err
+		}
+		if _, err = cs.Read(ctx, core.NewShardReader(si.Bid, cs.Vuid(), 0, 0, tw)); err != nil {
+			span.Errorf("Failed read shard, vuid:%v bid:%v err:%v", cs.Vuid(), si.Bid, err)
+			return err
+		}
+	}
+
+	span.Infof("export chunk:%s shards:%d", cs.ID(), len(shards))
+	return tw.Close()
+}
+
+type ArchiveReader struct {
+	Header ArchiveHeader
+	tr     *tar.Reader
+}
+
+// NewArchiveReader reads header of chunk archive, shards are left in r for import
+func NewArchiveReader(r io.Reader) (ar *ArchiveReader, err error) {
+	ar = &ArchiveReader{tr: tar.NewReader(r)}
+
+	th, err := ar.tr.Next()
+	if err != nil {
+		if err == io.EOF {
+			err = ErrArchiveHeader
+		}
+		return nil, err
+	}
+	if th.Name != archiveHeaderName {
+		return nil, ErrArchiveHeader
+	}
+	if err = json.NewDecoder(ar.tr).Decode(&ar.Header); err != nil {
+		return nil, err
+	}
+	if ar.Header.Version != archiveVersion {
+		return nil, ErrArchiveVersion
+	}
+	return ar, nil
+}
+
+// CheckVuid returns error if vuid is not of the same volume unit as the archive,
+// the epoch may be different
+func (ar *ArchiveReader) CheckVuid(vuid proto.Vuid) error {
+	src := ar.Header.Chunk.Vuid
+	if vuid.Vid() != src.Vid() || vuid.Index() != src.Index() {
+		return ErrArchiveVuid
+	}
+	return nil
+}
+
+// ImportTo writes shards of archive into an empty chunk,
+// then reads them back to verify crc
+func (ar *ArchiveReader) ImportTo(ctx context.Context, cs core.ChunkAPI) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if err = ar.CheckVuid(cs.Vuid()); err != nil {
+		return err
+	}
+
+	existed, _, err := cs.ListShards(ctx, proto.InValidBlobID, 1, bnapi.ShardStatusDefault)
+	if err != nil {
+		return err
+	}
+	if len(existed) > 0 {
+		return ErrArchiveNotEmpty
+	}
+
+	shards := make(map[proto.BlobID]*bnapi.ShardInfo, len(ar.Header.Shards))
+	for _, si := range ar.Header.Shards {
+		shards[si.Bid] = si
+	}
+
+	written := make(map[proto.BlobID]struct{}, len(shards))
+	for {
+		th, err := ar.tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		bid, err := parseArchiveShardName(th.Name)
+		if err != nil {
+			span.Errorf("unexpected entry:%s", th.Name)
+			return err
+		}
+		si, ok := shards[bid]
+		if _, dup := written[bid]; !ok || dup || si.Size != th.Size {
+			span.Errorf("shard entry:%s size:%d not match header", th.Name, th.Size)
+			return ErrArchiveShard
+		}
+
+		shard := core.NewShardWriter(bid, cs.Vuid(), uint32(si.Size), ar.tr)
+		if err = cs.Write(ctx, shard); err != nil {
+			return err
+		}
+		if shard.Crc != si.Crc {
+			span.Errorf("shard bid:%v crc:%v not match header crc:%v", bid, shard.Crc, si.Crc)
+			return ErrArchiveCrc
+		}
+		written[bid] = struct{}{}
+	}
+
+	if len(written) != len(shards) {
+		return ErrArchiveIncomplete
+	}
+	if err = cs.SyncData(ctx); err != nil {
+		return err
+	}
+
+	for _, si := range ar.Header.Shards {
+		if si.Flag == bnapi.ShardStatusMarkDelete {
+			if err = cs.MarkDelete(ctx, si.Bid); err != nil {
+				return err
+			}
+		}
+	}
+
+	// verification pass
+	for _, si := range ar.Header.Shards {
+		crc := crc32.NewIEEE()
+		if _, err = cs.Read(ctx, core.NewShardReader(si.Bid, cs.Vuid(), 0, 0, crc)); err != nil {
+			return err
+		}
+		if crc.Sum32() != si.Crc {
+			span.Errorf("verify shard bid:%v crc:%v not match header crc:%v", si.Bid, crc.Sum32(), si.Crc)
+			return ErrArchiveCrc
+		}
+	}
+
+	span.Infof("import chunk:%s from chunk:%s shards:%d", cs.ID(), ar.Header.Chunk.ChunkId, len(written))
+	return nil
+}
+
+func parseArchiveShardName(name string) (proto.BlobID, error) {
+	if !strings.HasPrefix(name, archiveShardPrefix) {
+		return proto.InValidBlobID, ErrArchiveShard
+	}
+	bid, err := strconv.ParseUint(strings.TrimPrefix(name, archiveShardPrefix), 10, 64)
+	if err != nil {
+		return proto.InValidBlobID, ErrArchiveShard
+	}
+	return proto.BlobID(bid), nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+func TestChunkArchive(t *testing.T) {
+	_, ctx := trace.StartSpanFromContextWithTraceID(context.Background(), "", "ChunkArchive")
+
+	testDir, err := ioutil.TempDir(os.TempDir(), "ChunkArchive")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+	for _, dir := range []string{"src", "dst", "corrupted", "truncated"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(testDir, dir), 0o755))
+	}
+
+	src := createTestChunk(t, ctx, filepath.Join(testDir, "src"), proto.Vuid(1024))
+	datas := make(map[proto.BlobID][]byte)
+	for bid := proto.BlobID(1); bid <= 10; bid++ {
+		data := bytes.Repeat([]byte{byte(bid)}, int(bid)*100)
+		datas[bid] = data
+		shard := core.NewShardWriter(bid, src.Vuid(), uint32(len(data)), bytes.NewReader(data))
+		require.NoError(t, src.Write(ctx, shard))
+	}
+	require.NoError(t, src.MarkDelete(ctx, 5))
+	require.NoError(t, src.SetStatus(bnapi.ChunkStatusReadOnly))
+
+	archive := bytes.NewBuffer(nil)
+	require.NoError(t, ExportChunk(ctx, src, archive))
+
+	dst := createTestChunk(t, ctx, filepath.Join(testDir, "dst"), proto.Vuid(2048))
+	ar, err := NewArchiveReader(bytes.NewReader(archive.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, src.ID(), ar.Header.Chunk.ChunkId)
+	require.Equal(t, 10, len(ar.Header.Shards))
+	require.NoError(t, ar.ImportTo(ctx, dst))
+
+	for bid, data := range datas {
+		buf := bytes.NewBuffer(nil)
+		_, err := dst.Read(ctx, core.NewShardReader(bid, dst.Vuid(), 0, 0, buf))
+		require.NoError(t, err)
+		require.Equal(t, data, buf.Bytes())
+
+		meta, err := dst.ReadShardMeta(ctx, bid)
+		require.NoError(t, err)
+		flag := bnapi.ShardStatusNormal
+		if bid == 5 {
+			flag = bnapi.ShardStatusMarkDelete
+		}
+		require.Equal(t, flag, meta.Flag)
+	}
+
+	// not empty
+	ar, err = NewArchiveReader(bytes.NewReader(archive.Bytes()))
+	require.NoError(t, err)
+	require.ErrorIs(t, ar.ImportTo(ctx, dst), ErrArchiveNotEmpty)
+
+	// vuid of another volume unit
+	require.ErrorIs(t, ar.CheckVuid(proto.EncodeVuid(proto.EncodeVuidPrefix(1, 0), 1)), ErrArchiveVuid)
+	require.ErrorIs(t, ar.CheckVuid(proto.EncodeVuid(proto.EncodeVuidPrefix(0, 1), 1)), ErrArchiveVuid)
+
+	// corrupted data of last shard
+	corrupted := append([]byte{}, archive.Bytes()...)
+	idx := bytes.LastIndex(corrupted, datas[10])
+	require.True(t, idx > 0)
+	corrupted[idx] ^= 0xff
+	dst = createTestChunk(t, ctx, filepath.Join(testDir, "corrupted"), proto.Vuid(4096))
+	ar, err = NewArchiveReader(bytes.NewReader(corrupted))
+	require.NoError(t, err)
+	require.ErrorIs(t, ar.ImportTo(ctx, dst), ErrArchiveCrc)
+
+	// truncated
+	dst = createTestChunk(t, ctx, filepath.Join(testDir, "truncated"), proto.Vuid(8192))
+	ar, err = NewArchiveReader(bytes.NewReader(archive.Bytes()[:archive.Len()/2]))
+	require.NoError(t, err)
+	require.Error(t, ar.ImportTo(ctx, dst))
+
+	// not archive
+	_, err = NewArchiveReader(bytes.NewReader([]byte("not archive")))
+	require.Error(t, err)
+}
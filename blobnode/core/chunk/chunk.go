@@ -17,8 +17,8 @@ package chunk
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
-	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -27,7 +27,7 @@ import (
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
 	"github.com/cubefs/blobstore/blobnode/base"
 	"github.com/cubefs/blobstore/blobnode/core"
-	"github.com/cubefs/blobstore/blobnode/core/storage"
+	"github.com/cubefs/blobstore/blobnode/db"
 	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/trace"
@@ -56,8 +56,10 @@ type chunk struct {
 	lock    sync.RWMutex
 
 	// storageWrapper ( meta & data )
-	stg  atomic.Value
-	disk core.DiskAPI
+	stg    atomic.Value
+	engine string
+	db     db.MetaHandler // kv db of the disk
+	disk   core.DiskAPI
 
 	// hook fn
 	onClosed func()
@@ -94,25 +96,22 @@ func newChunkStorage(ctx context.Context, dataPath string, vm core.VuidMeta, opt
 ) {
 	span := trace.SpanFromContextSafe(ctx)
 
-	// chunk data
-	chunkFile := filepath.Join(dataPath, vm.ChunkId.String())
-
 	opt := core.Option{}
 	for _, fn := range opts {
 		fn(&opt)
 	}
 
-	// new chunkData fd
-	cd, err := storage.NewChunkData(ctx, vm, chunkFile, opt.Conf, opt.CreateDataIfMiss, opt.IoQos)
+	// open chunk with the engine it was created
+	engine, err := core.GetStorageEngine(vm.StorageEngine)
 	if err != nil {
-		span.Errorf("Failed new chunk data. dp:%s, err:%v", dataPath, err)
+		span.Errorf("Failed get storage engine. vm:%v, err:%v", vm, err)
 		return nil, err
 	}
 
-	// create meta fd
-	cm, err := storage.NewChunkMeta(ctx, vm, opt.DB)
+	// new chunk storage ( meta & data )
+	stg, err := engine(ctx, dataPath, vm, &opt)
 	if err != nil {
-		span.Errorf("Failed new chunk meta. vm:%v, err:%v", vm, err)
+		span.Errorf("Failed new chunk storage. dp:%s, vm:%v, err:%v", dataPath, vm, err)
 		return nil, err
 	}
 
@@ -120,6 +119,8 @@ func newChunkStorage(ctx context.Context, dataPath string, vm core.VuidMeta, opt
 		version:        vm.Version,
 		vuid:           vm.Vuid,
 		diskID:         vm.DiskID,
+		engine:         vm.StorageEngine,
+		db:             opt.DB,
 		disk:           opt.Disk,
 		conf:           opt.Conf,
 		status:         vm.Status,
@@ -133,7 +134,6 @@ func newChunkStorage(ctx context.Context, dataPath string, vm core.VuidMeta, opt
 	cs.resetCompactTask()
 
 	// init stg
-	stg := storage.NewStorage(cm, cd)
 	cs.setStg(stg)
 
 	cs.fileInfo.Total = uint64(vm.ChunkSize)
@@ -201,7 +201,8 @@ func (cs *chunk) refreshFstat(ctx context.Context) (err error) {
 		return err
 	}
 
-	fsize, physize := stat.FileSize, stat.PhySize
+	// reusable holes are not counted in logic size
+	fsize, physize := stat.FileSize-stat.FreeSize, stat.PhySize
 
 	info := &cs.fileInfo
 
@@ -342,6 +343,8 @@ func (cs *chunk) vuidMeta() (vm *core.VuidMeta) {
 		Mtime:       cs.lastModifyTime,
 		Status:      cs.status,
 		Compacting:  cs.compacting,
+
+		StorageEngine: cs.engine,
 	}
 	return vm
 }
@@ -674,6 +677,62 @@ func (cs *chunk) ListShards(ctx context.Context, startBid proto.BlobID, cnt int,
 	return
 }
 
+// ScrubShards verifies data of at most cnt normal shards after startBid,
+// fn is called with every corrupted shard. n is the number of verified shards,
+// next is InValidBlobID if reach the end of chunk.
+func (cs *chunk) ScrubShards(ctx context.Context, startBid proto.BlobID, cnt int, fn func(bid proto.BlobID, err error)) (
+	next proto.BlobID, n int, err error,
+) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	stg := cs.GetStg()
+	defer cs.PutStg(stg)
+
+	metas := make(map[proto.BlobID]core.ShardMeta, cnt)
+	bids := make([]proto.BlobID, 0, cnt)
+	err = stg.ScanMeta(ctx, startBid, cnt, func(bid proto.BlobID, sm *core.ShardMeta) error {
+		next = bid
+		if sm.Flag != bnapi.ShardStatusNormal {
+			return nil
+		}
+		metas[bid] = *sm
+		bids = append(bids, bid)
+		return nil
+	})
+	if err != nil && err != core.ErrChunkScanEOF {
+		span.Errorf("scan vuid:%v shard occur error: %v", cs.vuid, err)
+		return proto.InValidBlobID, 0, err
+	}
+	if err == core.ErrChunkScanEOF {
+		next = proto.InValidBlobID
+	}
+
+	for _, bid := range bids {
+		sm := metas[bid]
+		shard := core.NewShardReader(bid, cs.vuid, 0, int64(sm.Size), nil)
+		shard.FillMeta(sm)
+
+		verr := stg.DataHandler().Verify(ctx, shard)
+		n++
+		if verr == nil {
+			continue
+		}
+		if !errors.Is(verr, core.ErrShardCorrupted) {
+			span.Errorf("Failed verify vuid:%v bid:%v, err:%v", cs.vuid, bid, verr)
+			return proto.InValidBlobID, n, verr
+		}
+		// shard may be deleted while verifying
+		if cur, merr := stg.ReadShardMeta(ctx, bid); merr != nil || cur.Flag != bnapi.ShardStatusNormal {
+			span.Debugf("shard vuid:%v bid:%v changed, err:%v", cs.vuid, bid, merr)
+			continue
+		}
+		span.Warnf("shard vuid:%v bid:%v corrupted, err:%v", cs.vuid, bid, verr)
+		fn(bid, verr)
+	}
+
+	return next, n, nil
+}
+
 func (cs *chunk) close(ctx context.Context) {
 	span := trace.SpanFromContextSafe(ctx)
 
@@ -391,3 +391,64 @@ func TestChunkStorage_Finalizer(t *testing.T) {
 
 	require.Equal(t, int32(1), atomic.LoadInt32(&cnt))
 }
+
+func TestChunkStorage_Engines(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"ChunkStorageEngines")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	err = core.EnsureDiskArea(testDir)
+	require.NoError(t, err)
+	datapath := core.GetDataPath(testDir)
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+
+	vuid := proto.Vuid(1)
+	newChunk := func(engine string) (*Chunk, error) {
+		// engine of the chunk is not changed by config
+		conf := &core.Config{
+			BaseConfig: core.BaseConfig{StorageEngine: "not-exist"},
+			RuntimeConfig: core.RuntimeConfig{
+				MetricReportIntervalS: 30,
+			},
+		}
+		vm := core.VuidMeta{
+			Vuid:          vuid,
+			DiskID:        12,
+			ChunkId:       bnapi.NewChunkId(vuid),
+			Mtime:         time.Now().UnixNano(),
+			Status:        bnapi.ChunkStatusNormal,
+			StorageEngine: engine,
+		}
+		return NewChunkStorage(ctx, datapath, vm, func(option *core.Option) {
+			option.Conf = conf
+			option.CreateDataIfMiss = true
+			option.IoQos = ioQos
+		})
+	}
+
+	_, err = newChunk("not-exist")
+	require.ErrorIs(t, err, core.ErrStorageEngineNotFound)
+
+	// engines without kv db
+	for _, engine := range []string{"memory", "logfile"} {
+		cs, err := newChunk(engine)
+		require.NoError(t, err)
+		require.Equal(t, engine, cs.VuidMeta().StorageEngine)
+
+		shardData := []byte("test data")
+		shard := core.NewShardWriter(1024, vuid, uint32(len(shardData)), bytes.NewReader(shardData))
+		require.NoError(t, cs.Write(ctx, shard))
+
+		rs, err := cs.NewReader(ctx, shard.Bid)
+		require.NoError(t, err)
+		rd, err := ioutil.ReadAll(rs.Body)
+		require.NoError(t, err)
+		require.Equal(t, shardData, rd)
+		require.Equal(t, crc32.ChecksumIEEE(shardData), rs.Crc)
+
+		require.NoError(t, cs.MarkDelete(ctx, shard.Bid))
+		require.NoError(t, cs.Delete(ctx, shard.Bid))
+		require.NoError(t, cs.Sync(ctx))
+	}
+}
@@ -59,6 +59,8 @@ func (cs *chunk) StartCompact(ctx context.Context) (newcs core.ChunkAPI, err err
 		Ctime:       now,
 		Mtime:       now,
 		Status:      bnapi.ChunkStatusDefault,
+
+		StorageEngine: cs.engine,
 	}
 
 	stg := cs.getStg()
@@ -66,7 +68,8 @@ func (cs *chunk) StartCompact(ctx context.Context) (newcs core.ChunkAPI, err err
 	// new dstChunkStorage
 	ncs, err := newChunkStorage(ctx, cs.Disk().GetDataPath(), vm, func(o *core.Option) {
 		o.Conf = cs.Disk().GetConfig()
-		o.DB = stg.MetaHandler().InnerDB()
+		// meta of some engines are not saved in kv db of the disk
+		o.DB = cs.db
 		o.IoQos = cs.Disk().GetIoQos()
 		o.Disk = cs.Disk()
 		o.CreateDataIfMiss = true
@@ -318,7 +321,8 @@ func (cs *chunk) NeedCompact(ctx context.Context) bool {
 		return false
 	}
 
-	size, phySize := stat.FileSize, stat.PhySize
+	// reusable holes are not counted as void space
+	size, phySize := stat.FileSize-stat.FreeSize, stat.PhySize
 
 	// file size is too large
 	if size >= cs.conf.CompactTriggerThreshold {
@@ -135,6 +135,10 @@ func (mock *diskMock) WalkChunksWithLock(ctx context.Context, fn func(cs core.Ch
 	return
 }
 
+func (mock *diskMock) ScrubStats() (stats []core.ScrubStat) {
+	return
+}
+
 func (mock *diskMock) ResetChunks(ctx context.Context) {
 }
 
@@ -151,7 +155,8 @@ func ensureTestDir(t *testing.T, diskRoot string) (root, meta, data string) {
 	return
 }
 
-func createTestChunk(t *testing.T, ctx context.Context, diskRoot string, vuid proto.Vuid) (cs *Chunk) {
+func createTestChunk(t *testing.T, ctx context.Context, diskRoot string, vuid proto.Vuid,
+	opts ...core.OptionFunc) (cs *Chunk) {
 	_, metaPath, dataPath := ensureTestDir(t, diskRoot)
 
 	dbHandler, err := db.NewMetaHandler(metaPath, db.MetaConfig{})
@@ -181,6 +186,9 @@ func createTestChunk(t *testing.T, ctx context.Context, diskRoot string, vuid pr
 		option.CreateDataIfMiss = true
 		option.Disk = &diskMock{dataPath: dataPath, conf: conf, ioQos: ioQos}
 		option.IoQos = ioQos
+		for _, opt := range opts {
+			opt(option)
+		}
 	})
 	require.NoError(t, err)
 	require.NotNil(t, chunk)
@@ -651,3 +659,41 @@ func TestChunkStorage_CompactCheck(t *testing.T) {
 	runtime.GC()
 	runtime.GC()
 }
+
+func TestChunk_NeedCompactWithFreeExtents(t *testing.T) {
+	ctx := context.Background()
+
+	testDir, err := ioutil.TempDir(os.TempDir(), "NeedCompactWithFreeExtents")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	vuid := proto.Vuid(1024)
+	cs := createTestChunk(t, ctx, testDir, vuid, func(option *core.Option) {
+		option.Conf.ReuseExtents = true
+	})
+	cs.conf.CompactTriggerThreshold = core.DefaultCompactTriggerThreshold
+	cs.conf.CompactMinSizeThreshold = 1 << 20
+	cs.conf.CompactEmptyRateThreshold = core.DefaultCompactEmptyRateThreshold
+
+	shardData := bytes.Repeat([]byte("TestData"), 8<<10)
+	shardCnt := 64
+	for i := 1; i <= shardCnt; i++ {
+		shard := core.NewShardWriter(proto.BlobID(i), vuid, uint32(len(shardData)), bytes.NewReader(shardData))
+		require.NoError(t, cs.Write(ctx, shard))
+	}
+	require.False(t, cs.NeedCompact(ctx))
+	size := cs.ChunkInfo(ctx).Size
+
+	// delete most of shards, holes are reusable
+	for i := 1; i < shardCnt; i++ {
+		require.NoError(t, cs.MarkDelete(ctx, proto.BlobID(i)))
+		require.NoError(t, cs.Delete(ctx, proto.BlobID(i)))
+	}
+	require.False(t, cs.NeedCompact(ctx))
+	require.True(t, cs.ChunkInfo(ctx).Size < size)
+
+	// new shard placed in the hole
+	shard := core.NewShardWriter(proto.BlobID(shardCnt+1), vuid, uint32(len(shardData)), bytes.NewReader(shardData))
+	require.NoError(t, cs.Write(ctx, shard))
+	require.True(t, uint64(shard.Offset) < size)
+}
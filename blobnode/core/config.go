@@ -19,8 +19,10 @@ import (
 	"errors"
 
 	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/api/mqproxy"
 	"github.com/cubefs/blobstore/blobnode/base/qos"
 	"github.com/cubefs/blobstore/blobnode/db"
+	"github.com/cubefs/blobstore/blobnode/sys"
 	"github.com/cubefs/blobstore/common/proto"
 )
 
@@ -41,6 +43,11 @@ const (
 	DefaultCompactTriggerThreshold      = 1 * (1 << 40)   // 1 TiB
 	DefaultMetricReportIntervalS        = 30              // 30 Sec
 	DefaultCompactEmptyRateThreshold    = float64(0.8)    // 80% rate
+	DefaultScrubIntervalSec             = 60              // 1 min
+	DefaultScrubBatchSize               = 128             // 128 counts
+	DefaultGroupCommitShardSize         = 128 * (1 << 10) // 128 KiB
+	DefaultGroupCommitBatchSize         = 4 * (1 << 20)   // 4 MiB
+	DefaultIOUringEntries               = 256             // 256 counts
 )
 
 // Config for disk
@@ -49,6 +56,15 @@ type BaseConfig struct {
 	AutoFormat  bool   `json:"auto_format"`
 	MaxChunks   int32  `json:"max_chunks"`
 	DisableSync bool   `json:"disable_sync"`
+	// StorageEngine name of registered storage engine of chunks
+	StorageEngine string `json:"storage_engine"`
+	// ReuseExtents reuses punched holes of datafile chunks, which are recorded in meta db
+	ReuseExtents bool `json:"reuse_extents"`
+	// DirectIO bypasses page cache for chunk data, falls back if filesystem rejects
+	DirectIO bool `json:"direct_io"`
+	// IOUring submits chunk data io with io_uring of linux, falls back if not supported
+	IOUring        bool   `json:"io_uring"`
+	IOUringEntries uint32 `json:"io_uring_entries"`
 }
 
 type RuntimeConfig struct {
@@ -73,6 +89,12 @@ type RuntimeConfig struct {
 	IOStatFileDryRun             bool       `json:"iostat_file_dryrun"`
 	MetricReportIntervalS        int64      `json:"metric_report_interval_S"`
 	DiskQos                      qos.Config `json:"data_qos"`
+	AllowScrub                   bool       `json:"allow_scrub"`
+	ScrubIntervalSec             int64      `json:"scrub_interval_S"` // loop
+	ScrubBatchSize               int        `json:"scrub_batch_size"`
+	GroupCommit                  bool       `json:"group_commit"`            // coalesce small shard writes
+	GroupCommitShardSize         int64      `json:"group_commit_shard_size"` // larger shards written alone
+	GroupCommitBatchSize         int64      `json:"group_commit_batch_size"` // max bytes of one commit
 }
 
 type HostInfo struct {
@@ -91,6 +113,10 @@ type Config struct {
 	AllocDiskID      func(ctx context.Context) (proto.DiskID, error)
 	HandleIOError    func(ctx context.Context, diskID proto.DiskID, diskErr error)
 	NotifyCompacting func(ctx context.Context, args *cmapi.SetCompactChunkArgs) (err error)
+	NotifyRepair     func(ctx context.Context, args *mqproxy.ShardRepairArgs) (err error)
+
+	// IORing shared io_uring of disk, nil if disabled
+	IORing *sys.Ring
 }
 
 func InitConfig(conf *Config) error {
@@ -103,9 +129,16 @@ func InitConfig(conf *Config) error {
 	if conf.AllocDiskID == nil {
 		return errors.New("allocDiskID is not specified")
 	}
+	// group commit acknowledges shards after fdatasync
+	if conf.GroupCommit && conf.DisableSync {
+		return errors.New("group commit conflicts with disable sync")
+	}
 	if conf.DiskReservedSpaceB <= 0 {
 		conf.DiskReservedSpaceB = DefaultDiskReservedSpaceB
 	}
+	if conf.StorageEngine == "" {
+		conf.StorageEngine = DefaultStorageEngine
+	}
 	if conf.MaxChunks <= 0 {
 		conf.MaxChunks = DefaultMaxChunks
 	}
@@ -150,6 +183,21 @@ func InitConfig(conf *Config) error {
 	if conf.MetricReportIntervalS <= 0 {
 		conf.MetricReportIntervalS = DefaultMetricReportIntervalS
 	}
+	if conf.ScrubIntervalSec <= 0 {
+		conf.ScrubIntervalSec = DefaultScrubIntervalSec
+	}
+	if conf.ScrubBatchSize <= 0 {
+		conf.ScrubBatchSize = DefaultScrubBatchSize
+	}
+	if conf.IOUringEntries == 0 {
+		conf.IOUringEntries = DefaultIOUringEntries
+	}
+	if conf.GroupCommitShardSize <= 0 {
+		conf.GroupCommitShardSize = int64(DefaultGroupCommitShardSize)
+	}
+	if conf.GroupCommitBatchSize <= 0 {
+		conf.GroupCommitBatchSize = int64(DefaultGroupCommitBatchSize)
+	}
 
 	return nil
 }
@@ -40,4 +40,20 @@ func TestInitConfig(t *testing.T) {
 	conf.HandleIOError = func(ctx context.Context, diskID proto.DiskID, diskErr error) {}
 	err = InitConfig(conf)
 	require.Error(t, err)
+
+	conf.AllocDiskID = func(ctx context.Context) (proto.DiskID, error) { return 1, nil }
+	conf.GroupCommit = true
+	conf.DisableSync = true
+	err = InitConfig(conf)
+	require.Error(t, err)
+
+	conf.GroupCommit = false
+	err = InitConfig(conf)
+	require.NoError(t, err)
+	require.Equal(t, DefaultStorageEngine, conf.StorageEngine)
+	require.Equal(t, int64(DefaultScrubIntervalSec), conf.ScrubIntervalSec)
+	require.Equal(t, DefaultScrubBatchSize, conf.ScrubBatchSize)
+	require.Equal(t, int64(DefaultGroupCommitShardSize), conf.GroupCommitShardSize)
+	require.Equal(t, int64(DefaultGroupCommitBatchSize), conf.GroupCommitBatchSize)
+	require.Equal(t, uint32(DefaultIOUringEntries), conf.IOUringEntries)
 }
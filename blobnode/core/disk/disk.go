@@ -33,6 +33,7 @@ import (
 	"github.com/cubefs/blobstore/blobnode/core/chunk"
 	myos "github.com/cubefs/blobstore/blobnode/sys"
 	bloberr "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/iostat"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/trace"
 	"github.com/cubefs/blobstore/util/errors"
@@ -81,6 +82,10 @@ type DiskStorage struct {
 	// DiskQos (include io visualization function)
 	dataQos qos.Qos
 
+	// scrub progress of chunks
+	scrubLock  sync.Mutex
+	scrubStats map[proto.Vuid]*core.ScrubStat
+
 	// status
 	status       proto.DiskStatus
 	isMountPoint bool
@@ -157,6 +162,14 @@ func (ds *DiskStorage) Close(ctx context.Context) {
 	// clean chunk map
 	ds.Chunks = make(map[proto.Vuid]core.ChunkAPI)
 
+	// chunks opened still work with pread/pwrite
+	ds.closeIORing(ctx)
+
+	// stop latency slo of background io
+	if ds.dataQos != nil {
+		ds.dataQos.Close()
+	}
+
 	// clean superblock
 	sb := ds.SuperBlock
 	if sb != nil {
@@ -166,6 +179,29 @@ func (ds *DiskStorage) Close(ctx context.Context) {
 	ds.closed = true
 }
 
+// Destroy closes the disk storage and releases the meta db at once rather than gc,
+// so that the disk path can be cleaned and reopened.
+func (dsw *DiskStorageWrapper) Destroy(ctx context.Context) {
+	db := dsw.SuperBlock.db
+
+	runtime.SetFinalizer(dsw, nil)
+	dsw.Close(ctx)
+
+	runtime.SetFinalizer(db, nil)
+	db.Close(ctx)
+}
+
+func (ds *DiskStorage) closeIORing(ctx context.Context) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if ds.Conf.IORing == nil {
+		return
+	}
+	if err := ds.Conf.IORing.Close(); err != nil {
+		span.Errorf("Failed close io_uring, diskID:%v, err:%v", ds.DiskID, err)
+	}
+}
+
 func (ds *DiskStorage) DiskInfo() (info bnapi.DiskInfo) {
 	ds.Lock.RLock()
 	defer ds.Lock.RUnlock()
@@ -323,6 +359,8 @@ func (dsw *DiskStorageWrapper) CreateChunk(ctx context.Context, vuid proto.Vuid,
 		Ctime:     nowtime,
 		Mtime:     nowtime,
 		Status:    bnapi.ChunkStatusNormal,
+		// engine of the chunk never changes even if config changed
+		StorageEngine: ds.Conf.StorageEngine,
 	}
 
 	// create chunk storage
@@ -471,6 +509,10 @@ func newDiskStorage(ctx context.Context, conf core.Config) (ds *DiskStorage, err
 	// init Qos Manager
 	conf.DiskQos.DiskViewer = diskView
 	conf.DiskQos.StatGetter = dataios
+	conf.DiskQos.DiskID = dm.DiskID
+	if conf.DiskQos.LatencySLO.ReadLatencyTargetMs > 0 {
+		conf.DiskQos.LatencyViewer = iostat.NewIOViewer(dataios[bnapi.NormalIO].Stat, 0)
+	}
 
 	dataQos, err := qos.NewQosManager(conf.DiskQos)
 	if err != nil {
@@ -478,6 +520,22 @@ func newDiskStorage(ctx context.Context, conf core.Config) (ds *DiskStorage, err
 		return nil, err
 	}
 
+	if conf.IOUring {
+		ring, err := myos.NewRing(conf.IOUringEntries)
+		if err != nil {
+			span.Warnf("fallback to pread/pwrite, io_uring not supported, err:%v", err)
+		} else {
+			conf.IORing = ring
+		}
+	}
+
+	// resume scrub progress of chunks
+	scrubStats, err := sb.ListScrubStats(ctx)
+	if err != nil {
+		span.Errorf("Failed list scrub stats, err:%v", err)
+		return nil, err
+	}
+
 	ds = &DiskStorage{
 		DiskID:           dm.DiskID,
 		SuperBlock:       sb,
@@ -492,12 +550,19 @@ func newDiskStorage(ctx context.Context, conf core.Config) (ds *DiskStorage, err
 		Readonly:         dm.Readonly,
 		isMountPoint:     myos.IsMountPoint(conf.Path),
 		dataQos:          dataQos,
+		scrubStats:       make(map[proto.Vuid]*core.ScrubStat, len(scrubStats)),
 		CreateAt:         dm.Ctime,
 		LastUpdateAt:     dm.Mtime,
 	}
 
+	for vuid := range scrubStats {
+		stat := scrubStats[vuid]
+		ds.scrubStats[vuid] = &stat
+	}
+
 	if err = ds.fillDiskUsage(ctx); err != nil {
 		span.Errorf("Failed fill disk usage, err:%v", err)
+		ds.closeIORing(ctx)
 		return nil, err
 	}
 
@@ -507,6 +572,7 @@ func newDiskStorage(ctx context.Context, conf core.Config) (ds *DiskStorage, err
 	ds.loopAttach(ds.loopDiskUsage)
 	ds.loopAttach(ds.loopCleanTrash)
 	ds.loopAttach(ds.loopMetricReport)
+	ds.loopAttach(ds.loopScrubChunk)
 
 	return ds, nil
 }
@@ -109,12 +109,16 @@ func TestNewDiskStorage(t *testing.T) {
 		t.Fail()
 	}
 
-	// second time. reload
+	// second time. reload, chunks are opened with the engine they were created
+	diskConfig.StorageEngine = "logfile"
 	ds, err = NewDiskStorage(ctx, diskConfig)
 	require.NoError(t, err)
 	require.NotNil(t, ds)
 	require.Equal(t, 2, len(ds.Chunks))
 	defer ds.ResetChunks(ctx)
+	for _, cs := range ds.Chunks {
+		require.Equal(t, core.DefaultStorageEngine, cs.VuidMeta().StorageEngine)
+	}
 
 	ds.runCompactFiles()
 
@@ -0,0 +1,208 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package disk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/storage"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+/*
+ * offline tools of the meta db of disk, blobnode must not run on the disk:
+ *  - rebuild a lost meta db from format info and chunk data files,
+ *    the newest chunk of vuid is bound, older ones (compaction leftovers)
+ *    are rebuilt as released, and cleaned after protection period.
+ *  - export or import the metas of one chunk as a portable file.
+ */
+
+var ErrMetaNotEmpty = errors.New("disk: meta path is not empty")
+
+// RebuildStat is the result of rebuilding meta db
+type RebuildStat struct {
+	Chunks   int `json:"chunks"`
+	Released int `json:"released"`
+	Shards   int `json:"shards"`
+}
+
+func openOfflineSuperBlock(conf core.Config) (sb *SuperBlock, close func(), err error) {
+	sb, err = NewSuperBlock(core.GetMetaPath(conf.Path), &conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	close = func() {
+		// offline tools release the db before exit instead of waiting for gc
+		db := sb.db
+		sb.Close(context.Background())
+		runtime.SetFinalizer(db, nil)
+		db.Close(context.Background())
+	}
+	return sb, close, nil
+}
+
+// RebuildMeta rebuilds the meta db of disk offline,
+// the broken meta db must be moved away from meta path first.
+func RebuildMeta(ctx context.Context, conf core.Config, verify bool) (stat RebuildStat, err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	metaPath := core.GetMetaPath(conf.Path)
+	if fis, err := ioutil.ReadDir(metaPath); err == nil && len(fis) > 0 {
+		return stat, ErrMetaNotEmpty
+	}
+
+	format, err := core.ReadFormatInfo(ctx, conf.Path)
+	if err != nil {
+		return stat, err
+	}
+
+	// group chunk files by vuid
+	dataPath := core.GetDataPath(conf.Path)
+	fis, err := ioutil.ReadDir(dataPath)
+	if err != nil {
+		return stat, err
+	}
+	chunks := make(map[proto.Vuid][]core.VuidMeta)
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		id, err := bnapi.DecodeChunk(fi.Name())
+		if err != nil {
+			span.Warnf("skip unknown file:%s", fi.Name())
+			continue
+		}
+		vm, err := storage.ReadChunkFileMeta(filepath.Join(dataPath, fi.Name()))
+		if err != nil {
+			span.Warnf("skip chunk file:%s, err:%v", fi.Name(), err)
+			continue
+		}
+		vm.Vuid = id.VolumeUnitId()
+		vm.DiskID = format.DiskID
+		vm.ChunkId = id
+		vm.ChunkSize = core.DefaultChunkSize
+		vm.Mtime = fi.ModTime().UnixNano()
+		vm.Status = bnapi.ChunkStatusNormal
+		chunks[vm.Vuid] = append(chunks[vm.Vuid], vm)
+	}
+
+	if err = os.MkdirAll(metaPath, 0o755); err != nil {
+		return stat, err
+	}
+	sb, closeSb, err := openOfflineSuperBlock(conf)
+	if err != nil {
+		return stat, err
+	}
+	defer closeSb()
+
+	for vuid, vms := range chunks {
+		sort.Slice(vms, func(i, j int) bool {
+			return vms[i].ChunkId.UnixTime() > vms[j].ChunkId.UnixTime()
+		})
+		for i := range vms {
+			vm := vms[i]
+			if i > 0 {
+				vm.Status = bnapi.ChunkStatusRelease
+				vm.Reason = bnapi.ReleaseForCompact
+				stat.Released++
+			}
+
+			n, err := storage.RebuildChunkMeta(ctx, sb.db, vm, filepath.Join(dataPath, vm.ChunkId.String()), verify)
+			if err != nil {
+				span.Errorf("Failed rebuild chunk:%s, err:%v", vm.ChunkId, err)
+				return stat, err
+			}
+			if err = sb.UpsertChunk(ctx, vm.ChunkId, vm); err != nil {
+				return stat, err
+			}
+			stat.Shards += n
+			stat.Chunks++
+			span.Infof("rebuild chunk:%s, status:%d, shards:%d", vm.ChunkId, vm.Status, n)
+		}
+		if err = sb.BindVuidChunk(ctx, vuid, vms[0].ChunkId); err != nil {
+			return stat, err
+		}
+	}
+
+	// disk info at last, the meta db is usable after it
+	dm := core.DiskMeta{
+		FormatInfo: *format,
+		Mtime:      time.Now().UnixNano(),
+		Registered: true,
+		Status:     proto.DiskStatusNormal,
+		Path:       conf.Path,
+	}
+	if err = sb.UpsertDisk(ctx, dm.DiskID, dm); err != nil {
+		return stat, err
+	}
+
+	return stat, nil
+}
+
+func readOfflineChunk(ctx context.Context, sb *SuperBlock, vuid proto.Vuid) (vm core.VuidMeta, err error) {
+	id, err := sb.ReadVuidBind(ctx, vuid)
+	if err != nil {
+		return vm, err
+	}
+	return sb.ReadChunk(ctx, id)
+}
+
+// ExportChunkMeta exports the metas of chunk bound to vuid offline
+func ExportChunkMeta(ctx context.Context, conf core.Config, vuid proto.Vuid, w io.Writer) (n int, err error) {
+	sb, closeSb, err := openOfflineSuperBlock(conf)
+	if err != nil {
+		return 0, err
+	}
+	defer closeSb()
+
+	vm, err := readOfflineChunk(ctx, sb, vuid)
+	if err != nil {
+		return 0, err
+	}
+	return storage.ExportChunkMeta(ctx, sb.db, vm, w)
+}
+
+// ImportChunkMeta imports the exported metas into chunk bound to vuid offline,
+// the status of chunk is restored too.
+func ImportChunkMeta(ctx context.Context, conf core.Config, vuid proto.Vuid, r io.Reader) (n int, err error) {
+	sb, closeSb, err := openOfflineSuperBlock(conf)
+	if err != nil {
+		return 0, err
+	}
+	defer closeSb()
+
+	vm, err := readOfflineChunk(ctx, sb, vuid)
+	if err != nil {
+		return 0, err
+	}
+	exported, n, err := storage.ImportChunkMeta(ctx, sb.db, vm, r)
+	if err != nil {
+		return n, err
+	}
+
+	vm.Status, vm.Reason = exported.Status, exported.Reason
+	return n, sb.UpsertChunk(ctx, vm.ChunkId, vm)
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package disk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+// closeDiskStorage waits the meta db closed by gc
+func closeDiskStorage(t *testing.T, ds *DiskStorageWrapper) {
+	done := make(chan struct{})
+	ds.OnClosed = func() {
+		close(done)
+	}
+	ds.ResetChunks(context.Background())
+	ds = nil
+
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		time.Sleep(time.Second)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("disk storage not closed")
+	}
+}
+
+func TestRebuildMeta(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "RebuildMeta")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	diskpath := filepath.Join(testDir, "DiskPath")
+	require.NoError(t, os.MkdirAll(diskpath, 0o755))
+	diskConfig := core.Config{
+		BaseConfig: core.BaseConfig{
+			Path:       diskpath,
+			AutoFormat: true,
+		},
+		AllocDiskID:      getDiskIDFn,
+		NotifyCompacting: setChunkCompactFn,
+		HandleIOError:    handleIOErrorFn,
+	}
+	ds, err := NewDiskStorage(ctx, diskConfig)
+	require.NoError(t, err)
+	diskID := ds.DiskID
+
+	datas := make(map[proto.Vuid]map[proto.BlobID][]byte)
+	for vuid := proto.Vuid(1); vuid <= 2; vuid++ {
+		cs, err := ds.CreateChunk(ctx, vuid, core.DefaultChunkSize)
+		require.NoError(t, err)
+		datas[vuid] = make(map[proto.BlobID][]byte)
+		for bid := proto.BlobID(1); bid <= 3; bid++ {
+			data := bytes.Repeat([]byte{byte(bid)}, int(bid)*1024)
+			require.NoError(t, cs.Write(ctx, core.NewShardWriter(bid, vuid, uint32(len(data)), bytes.NewReader(data))))
+			datas[vuid][bid] = data
+		}
+		require.NoError(t, cs.MarkDelete(ctx, 3))
+	}
+	chunk2 := ds.Chunks[2].ID()
+	closeDiskStorage(t, ds)
+
+	// export
+	exportFile := filepath.Join(testDir, "vuid1.meta")
+	f, err := os.Create(exportFile)
+	require.NoError(t, err)
+	n, err := ExportChunkMeta(ctx, diskConfig, 1, f)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	f.Close()
+
+	// leftover of compaction
+	time.Sleep(time.Millisecond)
+	leftover := bnapi.NewChunkId(2)
+	data, err := ioutil.ReadFile(filepath.Join(core.GetDataPath(diskpath), chunk2.String()))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(core.GetDataPath(diskpath), leftover.String()), data, 0o644))
+
+	// meta db lost
+	_, err = RebuildMeta(ctx, diskConfig, true)
+	require.ErrorIs(t, err, ErrMetaNotEmpty)
+	metaPath := core.GetMetaPath(diskpath)
+	require.NoError(t, os.Rename(metaPath, metaPath+".broken"))
+
+	stat, err := RebuildMeta(ctx, diskConfig, true)
+	require.NoError(t, err)
+	require.Equal(t, RebuildStat{Chunks: 3, Released: 1, Shards: 9}, stat)
+
+	f, err = os.Open(exportFile)
+	require.NoError(t, err)
+	n, err = ImportChunkMeta(ctx, diskConfig, 1, f)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	f.Close()
+
+	ds, err = NewDiskStorage(ctx, diskConfig)
+	require.NoError(t, err)
+	defer ds.ResetChunks(ctx)
+	require.Equal(t, diskID, ds.DiskID)
+	require.Equal(t, 2, len(ds.Chunks))
+	require.Equal(t, leftover, ds.Chunks[2].ID())
+	released, err := ds.SuperBlock.ReadChunk(ctx, chunk2)
+	require.NoError(t, err)
+	require.Equal(t, bnapi.ChunkStatusRelease, released.Status)
+
+	for vuid, shards := range datas {
+		cs := ds.Chunks[vuid]
+		for bid, data := range shards {
+			sm, err := cs.ReadShardMeta(ctx, bid)
+			require.NoError(t, err)
+			// mark-deleted flag is restored by import
+			if bid == 3 && vuid == 1 {
+				require.Equal(t, bnapi.ShardStatusMarkDelete, sm.Flag)
+			} else {
+				require.Equal(t, bnapi.ShardStatusNormal, sm.Flag)
+			}
+
+			w := bytes.NewBuffer(nil)
+			_, err = cs.Read(ctx, core.NewShardReader(bid, vuid, 0, 0, w))
+			require.NoError(t, err)
+			require.Equal(t, data, w.Bytes())
+		}
+	}
+}
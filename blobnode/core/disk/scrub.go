@@ -0,0 +1,196 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package disk
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/api/mqproxy"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+const scrubRepairReason = "blobnode-scrub"
+
+func (ds *DiskStorage) loopScrubChunk() {
+	span, ctx := trace.StartSpanFromContextWithTraceID(context.Background(), "", "Scrub "+ds.Conf.Path)
+
+	span.Infof("loop scrub chunk.")
+
+	timer := initTimer(ds.Conf.ScrubIntervalSec)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ds.closeCh:
+			span.Infof("loop scrub chunk done")
+			return
+		case <-timer.C:
+			if err := ds.scrubChunks(ctx); err != nil {
+				span.Errorf("Failed exec scrub chunk. err:%v", err)
+			}
+			resetTimer(ds.Conf.ScrubIntervalSec, timer)
+		}
+	}
+}
+
+// scrubChunks verifies one batch of shards of every chunk,
+// and continues from the cursor of the chunk in the next time.
+func (ds *DiskStorage) scrubChunks(ctx context.Context) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if !ds.Conf.AllowScrub {
+		span.Debugf("skip scrub chunks")
+		return nil
+	}
+
+	// lowest priority, throttled by the io qos of disk
+	ctx = bnapi.Setiotype(ctx, bnapi.InternalIO)
+
+	ds.Lock.RLock()
+	chunks := make([]core.ChunkAPI, 0, len(ds.Chunks))
+	for _, cs := range ds.Chunks {
+		chunks = append(chunks, cs)
+	}
+	ds.Lock.RUnlock()
+
+	ds.cleanScrubStats(ctx, chunks)
+
+	for _, cs := range chunks {
+		select {
+		case <-ds.closeCh:
+			return nil
+		default:
+		}
+
+		if cs.Status() == bnapi.ChunkStatusRelease || cs.IsClosed() || cs.VuidMeta().Compacting {
+			span.Debugf("skip scrub chunk vuid:%v", cs.Vuid())
+			continue
+		}
+		if err = ds.scrubChunk(ctx, cs); err != nil {
+			span.Errorf("Failed scrub chunk vuid:%v, err:%v", cs.Vuid(), err)
+		}
+	}
+
+	return nil
+}
+
+func (ds *DiskStorage) scrubChunk(ctx context.Context, cs core.ChunkAPI) (err error) {
+	vuid := cs.Vuid()
+
+	ds.scrubLock.Lock()
+	stat, ok := ds.scrubStats[vuid]
+	if !ok {
+		stat = &core.ScrubStat{Vuid: vuid}
+		ds.scrubStats[vuid] = stat
+	}
+	cursor := stat.Cursor
+	ds.scrubLock.Unlock()
+
+	bad := int64(0)
+	next, n, err := cs.ScrubShards(ctx, cursor, ds.Conf.ScrubBatchSize, func(bid proto.BlobID, err error) {
+		bad++
+		ds.notifyRepair(ctx, vuid, bid, err)
+	})
+	if err != nil {
+		return err
+	}
+
+	ds.scrubLock.Lock()
+	stat.BadShards += bad
+	if next == proto.InValidBlobID {
+		// finish a round, start from the beginning next time
+		stat.Cursor = proto.InValidBlobID
+		stat.Scrubbed = 0
+		stat.Rounds++
+		stat.LastRoundTime = time.Now().UnixNano()
+	} else {
+		stat.Cursor = next
+		stat.Scrubbed += int64(n)
+	}
+	saved := *stat
+	ds.scrubLock.Unlock()
+
+	// resume from the cursor after restart
+	return ds.SuperBlock.UpsertScrubStat(ctx, saved)
+}
+
+func (ds *DiskStorage) notifyRepair(ctx context.Context, vuid proto.Vuid, bid proto.BlobID, reason error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	args := &mqproxy.ShardRepairArgs{
+		ClusterID: ds.Conf.ClusterID,
+		Bid:       bid,
+		Vid:       vuid.Vid(),
+		BadIdxes:  []uint8{vuid.Index()},
+		Reason:    scrubRepairReason,
+	}
+
+	if ds.Conf.NotifyRepair == nil {
+		span.Warnf("no repair notifier, bad shard:%+v, err:%v", args, reason)
+		return
+	}
+
+	if err := ds.Conf.NotifyRepair(ctx, args); err != nil {
+		span.Errorf("Failed send repair message:%+v, err:%v", args, err)
+		return
+	}
+
+	span.Warnf("send repair message:%+v, bad shard err:%v", args, reason)
+}
+
+// cleanScrubStats removes progress of chunks not on the disk any more
+func (ds *DiskStorage) cleanScrubStats(ctx context.Context, chunks []core.ChunkAPI) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	exist := make(map[proto.Vuid]struct{}, len(chunks))
+	for _, cs := range chunks {
+		exist[cs.Vuid()] = struct{}{}
+	}
+
+	ds.scrubLock.Lock()
+	removed := make([]proto.Vuid, 0)
+	for vuid := range ds.scrubStats {
+		if _, ok := exist[vuid]; !ok {
+			delete(ds.scrubStats, vuid)
+			removed = append(removed, vuid)
+		}
+	}
+	ds.scrubLock.Unlock()
+
+	for _, vuid := range removed {
+		if err := ds.SuperBlock.DeleteScrubStat(ctx, vuid); err != nil {
+			span.Warnf("Failed delete scrub stat vuid:%v, err:%v", vuid, err)
+		}
+	}
+}
+
+func (ds *DiskStorage) ScrubStats() (stats []core.ScrubStat) {
+	ds.scrubLock.Lock()
+	stats = make([]core.ScrubStat, 0, len(ds.scrubStats))
+	for _, stat := range ds.scrubStats {
+		stats = append(stats, *stat)
+	}
+	ds.scrubLock.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Vuid < stats[j].Vuid
+	})
+	return stats
+}
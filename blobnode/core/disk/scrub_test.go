@@ -0,0 +1,138 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package disk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/mqproxy"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func TestScrubChunk(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "TestScrubChunk")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+
+	diskpath := filepath.Join(testDir, "DiskPath")
+	err = os.MkdirAll(diskpath, 0o755)
+	require.NoError(t, err)
+
+	var repairs []*mqproxy.ShardRepairArgs
+	diskConfig := core.Config{
+		BaseConfig: core.BaseConfig{
+			Path:       diskpath,
+			AutoFormat: true,
+		},
+		RuntimeConfig: core.RuntimeConfig{
+			ScrubBatchSize: 2,
+		},
+		HostInfo:         core.HostInfo{ClusterID: 1},
+		AllocDiskID:      getDiskIDFn,
+		NotifyCompacting: setChunkCompactFn,
+		HandleIOError:    handleIOErrorFn,
+		NotifyRepair: func(ctx context.Context, args *mqproxy.ShardRepairArgs) error {
+			repairs = append(repairs, args)
+			return nil
+		},
+	}
+	ds, err := NewDiskStorage(ctx, diskConfig)
+	require.NoError(t, err)
+
+	vuid := proto.EncodeVuid(proto.EncodeVuidPrefix(100, 3), 1)
+	cs, err := ds.CreateChunk(ctx, vuid, core.DefaultChunkSize)
+	require.NoError(t, err)
+
+	shards := make(map[proto.BlobID]*core.Shard)
+	for bid := proto.BlobID(1); bid <= 5; bid++ {
+		data := bytes.Repeat([]byte{byte(bid)}, 8<<10)
+		shard := core.NewShardWriter(bid, vuid, uint32(len(data)), bytes.NewReader(data))
+		require.NoError(t, cs.Write(ctx, shard))
+		shards[bid] = shard
+	}
+	require.NoError(t, cs.MarkDelete(ctx, 5))
+
+	// not allowed
+	require.NoError(t, ds.scrubChunks(ctx))
+	require.Equal(t, 0, len(ds.ScrubStats()))
+
+	ds.Conf.AllowScrub = true
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ds.scrubChunks(ctx))
+	}
+	stats := ds.ScrubStats()
+	require.Equal(t, 1, len(stats))
+	require.Equal(t, int64(1), stats[0].Rounds)
+	require.Equal(t, int64(0), stats[0].BadShards)
+	require.Equal(t, proto.InValidBlobID, stats[0].Cursor)
+	require.Equal(t, 0, len(repairs))
+
+	// corrupt body of bid 3
+	f, err := os.OpenFile(filepath.Join(ds.DataPath, cs.ID().String()), os.O_RDWR, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte("corrupted"), shards[3].Offset+core.GetShardHeaderSize()+100)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// resume from cursor
+	require.NoError(t, ds.scrubChunks(ctx))
+	stats = ds.ScrubStats()
+	require.Equal(t, proto.BlobID(2), stats[0].Cursor)
+	require.Equal(t, int64(2), stats[0].Scrubbed)
+	require.Equal(t, 0, len(repairs))
+
+	require.NoError(t, ds.scrubChunks(ctx))
+	stats = ds.ScrubStats()
+	require.Equal(t, proto.BlobID(4), stats[0].Cursor)
+	require.Equal(t, int64(1), stats[0].BadShards)
+	// progress is saved in meta db of the disk
+	saved, err := ds.SuperBlock.ListScrubStats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[proto.Vuid]core.ScrubStat{vuid: stats[0]}, saved)
+	require.Equal(t, 1, len(repairs))
+	require.Equal(t, &mqproxy.ShardRepairArgs{
+		ClusterID: 1,
+		Bid:       3,
+		Vid:       100,
+		BadIdxes:  []uint8{vuid.Index()},
+		Reason:    scrubRepairReason,
+	}, repairs[0])
+
+	// mark deleted shard is skipped
+	require.NoError(t, ds.scrubChunks(ctx))
+	stats = ds.ScrubStats()
+	require.Equal(t, int64(2), stats[0].Rounds)
+	require.Equal(t, 1, len(repairs))
+
+	// progress of released chunk is cleaned
+	ds.Lock.Lock()
+	delete(ds.Chunks, vuid)
+	ds.Lock.Unlock()
+	require.NoError(t, ds.scrubChunks(ctx))
+	require.Equal(t, 0, len(ds.ScrubStats()))
+	saved, err = ds.SuperBlock.ListScrubStats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(saved))
+}
@@ -67,6 +67,7 @@ const (
 	_diskSpacePrefix  = "disk"
 	_chunkSpacePrefix = "chunks"
 	_vuidSpacePrefix  = "vuids"
+	_scrubSpacePrefix = "scrub"
 
 	_diskmetaKey = "diskinfo"
 )
@@ -92,6 +93,10 @@ func GenVuidSpaceKey(vuid proto.Vuid) string {
 	return fmt.Sprintf("%s%s%d", _vuidSpacePrefix, slashSeparator, vuid)
 }
 
+func GenScrubKey(vuid proto.Vuid) string {
+	return fmt.Sprintf("%s%s%d", _scrubSpacePrefix, slashSeparator, vuid)
+}
+
 func parseVuidSpacePrefix(key string) (vuid proto.Vuid, err error) {
 	strs := strings.Split(key, slashSeparator)
 	prefix, vuidstr := strs[0], strs[1]
@@ -366,6 +371,50 @@ func (s *SuperBlock) DeleteChunk(ctx context.Context, id bnapi.ChunkId) (err err
 	return nil
 }
 
+// UpsertScrubStat saves scrub progress of the vuid
+func (s *SuperBlock) UpsertScrubStat(ctx context.Context, stat core.ScrubStat) (err error) {
+	data, err := json.Marshal(stat)
+	if err != nil {
+		return err
+	}
+	key := []byte(GenScrubKey(stat.Vuid))
+
+	return s.writeData(ctx, key, data)
+}
+
+func (s *SuperBlock) DeleteScrubStat(ctx context.Context, vuid proto.Vuid) (err error) {
+	key := []byte(GenScrubKey(vuid))
+	return s.db.Delete(ctx, key)
+}
+
+func (s *SuperBlock) ListScrubStats(ctx context.Context) (stats map[proto.Vuid]core.ScrubStat, err error) {
+	iter := s.db.NewIterator(ctx)
+	defer iter.Close()
+
+	prefix := []byte(_scrubSpacePrefix + slashSeparator)
+
+	stats = make(map[proto.Vuid]core.ScrubStat)
+	for iter.Seek(prefix); iter.ValidForPrefix(prefix); iter.Next() {
+		v := iter.Value()
+		value := v.Data()
+
+		stat := core.ScrubStat{}
+		err = json.Unmarshal(value, &stat)
+		v.Free()
+		if err != nil {
+			return nil, err
+		}
+
+		stats[stat.Vuid] = stat
+	}
+
+	if err = iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
 func (s *SuperBlock) SetHandlerIOError(handleIOError func(err error)) {
 	s.db.SetHandleIOError(handleIOError)
 }
@@ -210,6 +210,37 @@ func TestSuperBlock_ListVuids(t *testing.T) {
 	require.Equal(t, 10, len(vuids))
 }
 
+func TestSuperBlock_ScrubStats(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "SBScrubStats")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+
+	diskmetapath := filepath.Join(testDir, "DiskPath")
+	err = os.MkdirAll(diskmetapath, 0o755)
+	require.NoError(t, err)
+
+	s, err := NewSuperBlock(diskmetapath, &core.Config{})
+	require.NoError(t, err)
+	require.NotNil(t, s)
+
+	for i := 0; i < 3; i++ {
+		vuid := proto.Vuid(1024 + i)
+		require.NoError(t, s.UpsertChunk(ctx, bnapi.NewChunkId(vuid), core.VuidMeta{Vuid: vuid}))
+		require.NoError(t, s.UpsertScrubStat(ctx, core.ScrubStat{Vuid: vuid, Cursor: proto.BlobID(i)}))
+	}
+	require.NoError(t, s.UpsertScrubStat(ctx, core.ScrubStat{Vuid: 1024, Cursor: 10, Rounds: 1}))
+	require.NoError(t, s.DeleteScrubStat(ctx, 1025))
+
+	stats, err := s.ListScrubStats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[proto.Vuid]core.ScrubStat{
+		1024: {Vuid: 1024, Cursor: 10, Rounds: 1},
+		1026: {Vuid: 1026, Cursor: 2},
+	}, stats)
+}
+
 func TestSuperBlock_genVuidSpaceKey(t *testing.T) {
 	vuid := proto.Vuid(1001)
 	key := GenVuidSpaceKey(vuid)
@@ -0,0 +1,79 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const DefaultStorageEngine = "datafile"
+
+var ErrStorageEngineNotFound = errors.New("storage engine not found")
+
+// StorageEngine creates chunk storage of the vuid meta,
+// data of the chunk is saved in dataPath.
+type StorageEngine func(ctx context.Context, dataPath string, vm VuidMeta, opt *Option) (Storage, error)
+
+var (
+	enginesLock sync.RWMutex
+	engines     = make(map[string]StorageEngine)
+)
+
+// RegisterStorageEngine makes a storage engine available by the name,
+// panic if registered twice.
+func RegisterStorageEngine(name string, engine StorageEngine) {
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+
+	if engine == nil {
+		panic("storage engine is nil: " + name)
+	}
+	if _, ok := engines[name]; ok {
+		panic("storage engine registered twice: " + name)
+	}
+	engines[name] = engine
+}
+
+// GetStorageEngine returns the storage engine, empty name means default engine
+func GetStorageEngine(name string) (StorageEngine, error) {
+	if name == "" {
+		name = DefaultStorageEngine
+	}
+
+	enginesLock.RLock()
+	engine, ok := engines[name]
+	enginesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStorageEngineNotFound, name)
+	}
+	return engine, nil
+}
+
+// StorageEngines returns sorted names of registered storage engines
+func StorageEngines() []string {
+	enginesLock.RLock()
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	enginesLock.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageEngineRegistry(t *testing.T) {
+	engine := func(ctx context.Context, dataPath string, vm VuidMeta, opt *Option) (Storage, error) {
+		return nil, nil
+	}
+
+	_, err := GetStorageEngine("")
+	require.ErrorIs(t, err, ErrStorageEngineNotFound)
+
+	RegisterStorageEngine(DefaultStorageEngine, engine)
+	RegisterStorageEngine("test-engine", engine)
+	defer func() {
+		enginesLock.Lock()
+		delete(engines, DefaultStorageEngine)
+		delete(engines, "test-engine")
+		enginesLock.Unlock()
+	}()
+
+	_, err = GetStorageEngine("")
+	require.NoError(t, err)
+	_, err = GetStorageEngine("test-engine")
+	require.NoError(t, err)
+	_, err = GetStorageEngine("not-exist")
+	require.ErrorIs(t, err, ErrStorageEngineNotFound)
+	require.Equal(t, []string{DefaultStorageEngine, "test-engine"}, StorageEngines())
+
+	require.Panics(t, func() { RegisterStorageEngine("test-engine", engine) })
+	require.Panics(t, func() { RegisterStorageEngine("nil-engine", nil) })
+}
@@ -21,4 +21,5 @@ import (
 var (
 	ErrChunkScanEOF      = errors.New("chunk scan occur eof")
 	ErrEnoughShardNumber = errors.New("chunk scan enough shard number")
+	ErrShardCorrupted    = errors.New("shard data corrupted")
 )
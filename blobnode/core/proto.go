@@ -41,6 +41,8 @@ type VuidMeta struct {
 	Compacting  bool              `json:"compacting"`
 	Status      bnapi.ChunkStatus `json:"status"` // normal、release
 	Reason      string            `json:"reason"`
+	// StorageEngine engine of the chunk, chunks created before engines are datafile
+	StorageEngine string `json:"storage_engine,omitempty"`
 }
 
 // disk meta data for rocksdb
@@ -64,10 +66,21 @@ type DiskStats struct {
 type StorageStat struct {
 	FileSize   int64         `json:"file_size"`
 	PhySize    int64         `json:"phy_size"`
+	FreeSize   int64         `json:"free_size"` // reusable space of punched holes
 	ParentID   bnapi.ChunkId `json:"parent_id"`
 	CreateTime int64         `json:"create_time"`
 }
 
+// scrub progress of chunk
+type ScrubStat struct {
+	Vuid          proto.Vuid   `json:"vuid"`
+	Cursor        proto.BlobID `json:"cursor"`          // last scrubbed bid of current round
+	Scrubbed      int64        `json:"scrubbed"`        // scrubbed shards of current round
+	BadShards     int64        `json:"bad_shards"`      // total bad shards found
+	Rounds        int64        `json:"rounds"`          // finished rounds
+	LastRoundTime int64        `json:"last_round_time"` // finished time of last round, nsec
+}
+
 type MetaHandler interface {
 	ID() bnapi.ChunkId
 	InnerDB() db.MetaHandler
@@ -85,6 +98,7 @@ type DataHandler interface {
 	Read(ctx context.Context, shard *Shard, from, to uint32) (r io.Reader, err error)
 	Stat() (stat *StorageStat, err error)
 	Flush() (err error)
+	Verify(ctx context.Context, shard *Shard) (err error)
 	Delete(ctx context.Context, shard *Shard) (err error)
 	Destroy(ctx context.Context) (err error)
 	Close()
@@ -131,6 +145,7 @@ type ChunkAPI interface {
 	Delete(ctx context.Context, bid proto.BlobID) (err error)
 	ReadShardMeta(ctx context.Context, bid proto.BlobID) (sm *ShardMeta, err error)
 	ListShards(ctx context.Context, startBid proto.BlobID, cnt int, status bnapi.ShardStatus) (infos []*bnapi.ShardInfo, next proto.BlobID, err error)
+	ScrubShards(ctx context.Context, startBid proto.BlobID, cnt int, fn func(bid proto.BlobID, err error)) (next proto.BlobID, n int, err error)
 	Sync(ctx context.Context) (err error)
 	SyncData(ctx context.Context) (err error)
 	Close(ctx context.Context)
@@ -173,6 +188,7 @@ type DiskAPI interface {
 	EnqueueCompact(ctx context.Context, vuid proto.Vuid)
 	GcRubbishChunk(ctx context.Context) (mayBeLost []bnapi.ChunkId, err error)
 	WalkChunksWithLock(ctx context.Context, fn func(cs ChunkAPI) error) (err error)
+	ScrubStats() (stats []ScrubStat)
 	ResetChunks(ctx context.Context)
 	Close(ctx context.Context)
 }
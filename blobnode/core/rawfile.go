@@ -0,0 +1,220 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package core
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/cubefs/blobstore/blobnode/sys"
+	rp "github.com/cubefs/blobstore/common/resourcepool"
+	"github.com/cubefs/blobstore/util/log"
+)
+
+// DirectIOAlignment alignment of offset, length and buffer address of direct io
+const DirectIOAlignment = 4096
+
+var directBufPool = rp.NewAlignedMemPool(map[int]int{
+	DirectIOAlignment: 1024,
+	64 * 1024:         256,
+	256 * 1024:        64,
+	1024 * 1024:       16,
+	4 * 1024 * 1024:   4,
+}, DirectIOAlignment)
+
+// openDirectFile can be replaced in tests
+var openDirectFile = func(filename string) (*os.File, error) {
+	if sys.O_DIRECT == 0 {
+		return nil, syscall.ENOTSUP
+	}
+	return os.OpenFile(filename, os.O_RDWR|sys.O_DIRECT, 0o644)
+}
+
+// OpenRawFile opens file of chunk data with the io options of disk.
+// falls back to buffered io if the filesystem rejects O_DIRECT,
+// and to pread/pwrite if ring is nil or closed.
+func OpenRawFile(filename string, createIfMiss bool, directIO bool, ring *sys.Ring) (RawFile, error) {
+	file, err := OpenFile(filename, createIfMiss)
+	if err != nil {
+		return nil, err
+	}
+
+	direct := false
+	if directIO {
+		df, err := openDirectFile(filename)
+		if err == nil {
+			err = probeDirectIO(df)
+		}
+		if err != nil {
+			log.Warnf("fallback to buffered io, file:%s, err:%v", filename, err)
+			if df != nil {
+				df.Close()
+			}
+		} else {
+			file.Close()
+			file, direct = df, true
+		}
+	}
+
+	var raw RawFile = file
+	if ring != nil {
+		raw = &ringFile{File: file, ring: ring}
+	}
+	if direct {
+		raw = &directFile{RawFile: raw}
+	}
+	return raw, nil
+}
+
+// probeDirectIO checks the filesystem accepts aligned direct read
+func probeDirectIO(file *os.File) error {
+	buf, _ := directBufPool.Get(DirectIOAlignment)
+	defer directBufPool.Put(buf) // nolint: errcheck
+
+	_, err := file.ReadAt(buf, 0)
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+func isDirectAligned(b []byte, off int64) bool {
+	return off%DirectIOAlignment == 0 && len(b)%DirectIOAlignment == 0 &&
+		(len(b) == 0 || uintptr(unsafe.Pointer(&b[0]))%DirectIOAlignment == 0)
+}
+
+// ringFile submits ReadAt and WriteAt with io_uring
+type ringFile struct {
+	*os.File
+	ring *sys.Ring
+}
+
+func (rf *ringFile) ReadAt(b []byte, off int64) (n int, err error) {
+	for n < len(b) {
+		m, err := rf.ring.ReadAt(rf.Fd(), b[n:], off+int64(n))
+		if err == sys.ErrRingClosed {
+			m, err = rf.File.ReadAt(b[n:], off+int64(n))
+			return n + m, err
+		}
+		if err != nil {
+			return n, &os.PathError{Op: "read", Path: rf.Name(), Err: err}
+		}
+		if m == 0 {
+			return n, io.EOF
+		}
+		n += m
+	}
+	return n, nil
+}
+
+func (rf *ringFile) WriteAt(b []byte, off int64) (n int, err error) {
+	for n < len(b) {
+		m, err := rf.ring.WriteAt(rf.Fd(), b[n:], off+int64(n))
+		if err == sys.ErrRingClosed {
+			m, err = rf.File.WriteAt(b[n:], off+int64(n))
+			return n + m, err
+		}
+		if err != nil {
+			return n, &os.PathError{Op: "write", Path: rf.Name(), Err: err}
+		}
+		if m == 0 {
+			return n, io.ErrShortWrite
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// directFile reads and writes the O_DIRECT file, which bypasses page cache.
+// unaligned io is done with aligned buffer, write is read-modify-write of
+// the head and tail blocks, which is exclusive with other writes of the file,
+// and the file size is extended to block alignment.
+type directFile struct {
+	RawFile
+	// aligned writes share, read-modify-write is exclusive
+	wLock sync.RWMutex
+}
+
+func (df *directFile) ReadAt(b []byte, off int64) (n int, err error) {
+	if isDirectAligned(b, off) {
+		return df.RawFile.ReadAt(b, off)
+	}
+
+	start := off - off%DirectIOAlignment
+	end := AlignSize(off+int64(len(b)), DirectIOAlignment)
+
+	buf, _ := directBufPool.Alloc(int(end - start))
+	defer directBufPool.Put(buf) // nolint: errcheck
+
+	m, err := df.RawFile.ReadAt(buf, start)
+	if skip := int(off - start); m > skip {
+		n = copy(b, buf[skip:m])
+	}
+	if n == len(b) {
+		return n, nil
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (df *directFile) WriteAt(b []byte, off int64) (n int, err error) {
+	if isDirectAligned(b, off) {
+		df.wLock.RLock()
+		defer df.wLock.RUnlock()
+		return df.RawFile.WriteAt(b, off)
+	}
+
+	df.wLock.Lock()
+	defer df.wLock.Unlock()
+
+	start := off - off%DirectIOAlignment
+	end := AlignSize(off+int64(len(b)), DirectIOAlignment)
+
+	buf, _ := directBufPool.Alloc(int(end - start))
+	defer directBufPool.Put(buf) // nolint: errcheck
+
+	// read the partial written blocks
+	if off != start {
+		if err = df.readBlock(buf[:DirectIOAlignment], start); err != nil {
+			return 0, err
+		}
+	}
+	if tail := off + int64(len(b)); tail != end && (off == start || end-start > DirectIOAlignment) {
+		if err = df.readBlock(buf[len(buf)-DirectIOAlignment:], end-DirectIOAlignment); err != nil {
+			return 0, err
+		}
+	}
+
+	copy(buf[off-start:], b)
+	if _, err = df.RawFile.WriteAt(buf, start); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// readBlock reads one block, zero filled beyond the end of file
+func (df *directFile) readBlock(block []byte, off int64) error {
+	m, err := df.RawFile.ReadAt(block, off)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	rp.Zero(block[m:])
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/blobnode/sys"
+)
+
+// checkRawFile compares random io of raw file with the data in memory
+func checkRawFile(t *testing.T, raw RawFile) {
+	const fileSize = 64 * 1024
+
+	expect := make([]byte, 0, fileSize)
+	data := make([]byte, fileSize)
+	rand.Read(data)
+
+	// aligned, unaligned, inside one block, across blocks
+	writes := [][2]int{{0, 4096}, {4096, 100}, {5000, 3000}, {100, 50}, {8100, 20000}, {30000, 4096}}
+	for _, w := range writes {
+		off, size := w[0], w[1]
+		n, err := raw.WriteAt(data[off:off+size], int64(off))
+		require.NoError(t, err)
+		require.Equal(t, size, n)
+		if len(expect) < off+size {
+			expect = expect[:off+size]
+		}
+		copy(expect[off:], data[off:off+size])
+	}
+
+	for _, r := range [][2]int{{0, 4096}, {1, 10}, {4000, 200}, {100, 30000}, {8192, 8192}, {0, len(expect)}} {
+		off, size := r[0], r[1]
+		buf := make([]byte, size)
+		n, err := raw.ReadAt(buf, int64(off))
+		require.NoError(t, err)
+		require.Equal(t, size, n)
+		require.Equal(t, expect[off:off+size], buf)
+	}
+
+	// read beyond the end
+	buf := make([]byte, 8192)
+	info, err := raw.Stat()
+	require.NoError(t, err)
+	n, err := raw.ReadAt(buf, info.Size()-100)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 100, n)
+}
+
+func TestOpenRawFile_Buffered(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "OpenRawFileBuffered")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	raw, err := OpenRawFile(filepath.Join(testDir, "file"), true, false, nil)
+	require.NoError(t, err)
+	defer raw.Close()
+	require.IsType(t, &os.File{}, raw)
+	checkRawFile(t, raw)
+
+	_, err = OpenRawFile(filepath.Join(testDir, "missing"), false, false, nil)
+	require.Error(t, err)
+}
+
+func TestOpenRawFile_Direct(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "OpenRawFileDirect")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	raw, err := OpenRawFile(filepath.Join(testDir, "file"), true, true, nil)
+	require.NoError(t, err)
+	defer raw.Close()
+
+	if _, ok := raw.(*directFile); !ok {
+		// filesystem rejects O_DIRECT
+		require.IsType(t, &os.File{}, raw)
+	}
+	checkRawFile(t, raw)
+
+	// alignment is done by direct file itself
+	file, err := OpenFile(filepath.Join(testDir, "buffered"), true)
+	require.NoError(t, err)
+	df := &directFile{RawFile: file}
+	defer df.Close()
+	checkRawFile(t, df)
+}
+
+func TestOpenRawFile_DirectFallback(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "OpenRawFileFallback")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	origin := openDirectFile
+	defer func() { openDirectFile = origin }()
+	openDirectFile = func(filename string) (*os.File, error) {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: syscall.EINVAL}
+	}
+
+	raw, err := OpenRawFile(filepath.Join(testDir, "file"), true, true, nil)
+	require.NoError(t, err)
+	defer raw.Close()
+	require.IsType(t, &os.File{}, raw)
+	checkRawFile(t, raw)
+}
+
+func TestOpenRawFile_IOUring(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "OpenRawFileIOUring")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ring, err := sys.NewRing(DefaultIOUringEntries)
+	if err != nil {
+		t.Skipf("io_uring not supported: %v", err)
+	}
+
+	raw, err := OpenRawFile(filepath.Join(testDir, "file"), true, false, ring)
+	require.NoError(t, err)
+	defer raw.Close()
+	require.IsType(t, &ringFile{}, raw)
+	checkRawFile(t, raw)
+
+	direct, err := OpenRawFile(filepath.Join(testDir, "direct"), true, true, ring)
+	require.NoError(t, err)
+	defer direct.Close()
+	checkRawFile(t, direct)
+
+	// pread/pwrite after ring closed
+	require.NoError(t, ring.Close())
+	data := []byte("after ring closed")
+	_, err = raw.WriteAt(data, 0)
+	require.NoError(t, err)
+	buf := make([]byte, len(data))
+	_, err = raw.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(data, buf))
+}
+
+func TestDirectFile_ConcurrentUnalignedWrite(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "DirectFileConcurrent")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	file, err := OpenFile(filepath.Join(testDir, "file"), true)
+	require.NoError(t, err)
+	df := &directFile{RawFile: file}
+	defer df.Close()
+
+	// pieces of the same block written concurrently
+	const pieces = 64
+	const pieceSize = DirectIOAlignment / pieces
+	done := make(chan struct{}, pieces)
+	for i := 0; i < pieces; i++ {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			data := bytes.Repeat([]byte{byte(i + 1)}, pieceSize)
+			_, err := df.WriteAt(data, int64(i*pieceSize))
+			require.NoError(t, err)
+		}(i)
+	}
+	for i := 0; i < pieces; i++ {
+		<-done
+	}
+
+	buf := make([]byte, DirectIOAlignment)
+	_, err = df.ReadAt(buf, 0)
+	require.NoError(t, err)
+	for i := 0; i < pieces; i++ {
+		require.Equal(t, bytes.Repeat([]byte{byte(i + 1)}, pieceSize), buf[i*pieceSize:(i+1)*pieceSize])
+	}
+}
@@ -112,6 +112,7 @@ var (
 	ErrShardFooterMagic = errors.New("shard footer magic")
 	ErrShardFooterSize  = errors.New("shard footer size")
 	ErrShardBufferSize  = errors.New("shard buffer size not match")
+	ErrShardDataCrc     = errors.New("shard data crc not match")
 )
 
 const (
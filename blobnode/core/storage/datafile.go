@@ -24,6 +24,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	bncomm "github.com/cubefs/blobstore/blobnode/base"
 	"github.com/cubefs/blobstore/blobnode/base/qos"
 	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
 	"github.com/cubefs/blobstore/common/crc32block"
 	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/trace"
@@ -107,6 +109,17 @@ type datafile struct {
 
 	ioQos  qos.Qos
 	closed bool
+
+	// punched holes can be reused, nil if disabled
+	extents *freeExtents
+	// changes of extents are persisted in order without wLock
+	extentLock sync.Mutex
+
+	// coalesced small shard writes, already synced
+	committer groupCommitter
+	// sequence of unsynced writes, only tracked in group commit mode
+	writeSeq  int64
+	syncedSeq int64
 }
 
 func (hdr *ChunkHeader) Marshal() ([]byte, error) {
@@ -125,15 +138,19 @@ func (hdr *ChunkHeader) Marshal() ([]byte, error) {
 }
 
 func (hdr *ChunkHeader) Unmarshal(data []byte) error {
+	return hdr.unmarshal(data, chunkHeaderMagic)
+}
+
+func (hdr *ChunkHeader) unmarshal(data []byte, expectMagic [_chunkMagicSize]byte) error {
 	if len(data) != _chunkHeaderSize {
 		panic(ErrChunkHeaderBufSize)
 	}
 
 	magic := data[_chunkMagicOffset : _chunkMagicOffset+_chunkMagicSize]
-	if !bytes.Equal(magic, chunkHeaderMagic[:]) {
+	if !bytes.Equal(magic, expectMagic[:]) {
 		return ErrChunkDataMagic
 	}
-	hdr.magic = chunkHeaderMagic
+	hdr.magic = expectMagic
 	hdr.version = data[_chunkVerOffset : _chunkVerOffset+_chunkVerSize][0]
 	copy(hdr.parentChunk[:], data[_chunkParentChunkOffset:_chunkParentChunkOffset+_chunkParentChunkSize])
 	hdr.createTime = int64(binary.BigEndian.Uint64(data[_chunkCreateTimeOffset : _chunkCreateTimeOffset+_chunkCreateTimeSize]))
@@ -149,6 +166,22 @@ func (hdr *ChunkHeader) String() string {
 }
 
 func NewChunkData(ctx context.Context, vm core.VuidMeta, file string, conf *core.Config, createIfMiss bool, ioQos qos.Qos) (
+	cd *datafile, err error) {
+	cd, err = openDataFile(ctx, vm, file, conf, createIfMiss, ioQos)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cd.init(&vm); err != nil {
+		err = fmt.Errorf("block: %s init() error(%v)", file, err)
+		cd.Close()
+		return nil, err
+	}
+
+	return cd, nil
+}
+
+func openDataFile(ctx context.Context, vm core.VuidMeta, file string, conf *core.Config, createIfMiss bool, ioQos qos.Qos) (
 	cd *datafile, err error) {
 	span := trace.SpanFromContextSafe(ctx)
 
@@ -157,7 +190,7 @@ func NewChunkData(ctx context.Context, vm core.VuidMeta, file string, conf *core
 		return nil, bloberr.ErrInvalidParam
 	}
 
-	fd, err := core.OpenFile(file, createIfMiss)
+	fd, err := core.OpenRawFile(file, createIfMiss, conf.DirectIO, conf.IORing)
 	if err != nil {
 		err = fmt.Errorf("os.OpenFile(\"%s\") error(%v)", file, err)
 		return nil, err
@@ -182,12 +215,6 @@ func NewChunkData(ctx context.Context, vm core.VuidMeta, file string, conf *core
 		},
 	}
 
-	if err = cd.init(&vm); err != nil {
-		err = fmt.Errorf("block: %s init() error(%v)", file, err)
-		cd.Close()
-		return nil, err
-	}
-
 	return cd, nil
 }
 
@@ -230,7 +257,24 @@ func (cd *datafile) Flush() (err error) {
 		return
 	}
 
-	return cd.ef.Sync()
+	if !cd.conf.GroupCommit {
+		return cd.ef.Sync()
+	}
+
+	// group committed shards are synced already
+	seq := atomic.LoadInt64(&cd.writeSeq)
+	if atomic.LoadInt64(&cd.syncedSeq) >= seq {
+		return nil
+	}
+	if err = cd.ef.Sync(); err != nil {
+		return err
+	}
+	for {
+		synced := atomic.LoadInt64(&cd.syncedSeq)
+		if synced >= seq || atomic.CompareAndSwapInt64(&cd.syncedSeq, synced, seq) {
+			return nil
+		}
+	}
 }
 
 func (cd *datafile) Close() {
@@ -287,19 +331,87 @@ func (cd *datafile) parseMeta() (err error) {
 	return
 }
 
-func (cd *datafile) allocSpace(fsize int64) (pos int64, err error) {
+// loadExtents enables reusing of punched holes, which are persisted in db
+func (cd *datafile) loadExtents(ctx context.Context, db db.MetaHandler, id bnapi.ChunkId) (err error) {
+	cd.wLock.RLock()
+	wOff := cd.wOff
+	cd.wLock.RUnlock()
+
+	extents, err := loadFreeExtents(ctx, db, id, _chunkHeaderSize, wOff)
+	if err != nil {
+		return err
+	}
+
 	cd.wLock.Lock()
-	defer cd.wLock.Unlock()
+	cd.extents = extents
+	cd.wLock.Unlock()
+	return nil
+}
+
+// unlockPersistExtents persists the changes of extents made under wLock,
+// wLock is released after the extent lock is taken to keep the order.
+func (cd *datafile) unlockPersistExtents(ctx context.Context, changes []extent) error {
+	cd.extentLock.Lock()
+	defer cd.extentLock.Unlock()
+
+	cd.wLock.Unlock()
+	return cd.extents.persist(ctx, changes)
+}
+
+func (cd *datafile) allocSpace(ctx context.Context, fsize int64) (pos int64, err error) {
+	cd.wLock.Lock()
+
+	if cd.extents != nil {
+		pos, ok, changes := cd.extents.alloc(core.AlignSize(fsize, _pagesize))
+		if ok {
+			// the space is used after removed from db, crash only leaks it
+			return pos, cd.unlockPersistExtents(ctx, changes)
+		}
+	}
 
 	pos = cd.wOff
 
 	cd.wOff += fsize
 	cd.wOff = core.AlignSize(cd.wOff, _pagesize)
 
+	cd.wLock.Unlock()
 	return pos, nil
 }
 
 func (cd *datafile) Write(ctx context.Context, shard *core.Shard) error {
+	if cd.conf.GroupCommit && int64(shard.Size) <= cd.conf.GroupCommitShardSize {
+		return cd.groupWrite(ctx, shard)
+	}
+
+	if cd.conf.DirectIO {
+		if err := cd.writeEncodedShard(ctx, shard); err != nil {
+			return err
+		}
+	} else {
+		phySize := core.Alignphysize(int64(shard.Size))
+
+		// allocate space
+		pos, err := cd.allocSpace(ctx, phySize)
+		if err != nil {
+			return err
+		}
+
+		if err = cd.writeShard(ctx, shard, pos); err != nil {
+			return err
+		}
+	}
+	cd.written()
+
+	return nil
+}
+
+// written marks the unsynced write, which is synced by the next Flush
+func (cd *datafile) written() {
+	atomic.AddInt64(&cd.writeSeq, 1)
+}
+
+// writeShard writes header, body and footer of the shard at pos
+func (cd *datafile) writeShard(ctx context.Context, shard *core.Shard, pos int64) (err error) {
 	span := trace.SpanFromContextSafe(ctx)
 
 	var (
@@ -308,14 +420,6 @@ func (cd *datafile) Write(ctx context.Context, shard *core.Shard) error {
 		start  time.Time
 	)
 
-	phySize := core.Alignphysize(int64(shard.Size))
-
-	// allocate space
-	pos, err := cd.allocSpace(phySize)
-	if err != nil {
-		return err
-	}
-
 	shard.Offset = pos
 
 	headerbuf := make([]byte, core.GetShardHeaderSize())
@@ -391,6 +495,28 @@ func (cd *datafile) Write(ctx context.Context, shard *core.Shard) error {
 	return nil
 }
 
+// writeEncodedShard writes the shard encoded in memory at once,
+// avoids read-modify-write of the unaligned pieces in direct io
+func (cd *datafile) writeEncodedShard(ctx context.Context, shard *core.Shard) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	data, err := cd.encodeShard(ctx, shard)
+	if err != nil {
+		return err
+	}
+
+	pos, err := cd.allocSpace(ctx, int64(len(data)))
+	if err != nil {
+		return err
+	}
+	shard.Offset = pos
+
+	start := time.Now()
+	_, err = cd.qosWriterAt(ctx, cd.ef).WriteAt(data, pos)
+	span.AppendTrackLog("dat.w", start, err)
+	return err
+}
+
 func (cd *datafile) Read(ctx context.Context, shard *core.Shard, from, to uint32) (r io.Reader, err error) {
 	if shard == nil {
 		return nil, bloberr.ErrInvalidParam
@@ -410,6 +536,21 @@ func (cd *datafile) Read(ctx context.Context, shard *core.Shard, from, to uint32
 	// new reader
 	iosr := cd.qosReaderAt(ctx, cd.ef)
 
+	// space of deleted shard may be reused by others
+	if cd.extents != nil {
+		var ns core.Shard
+		buf := make([]byte, core.GetShardHeaderSize())
+		if _, err = iosr.ReadAt(buf, shard.Offset); err != nil {
+			return nil, err
+		}
+		if err = ns.ParseHeader(buf); err != nil {
+			return nil, err
+		}
+		if shard.Bid != ns.Bid || shard.Vuid != ns.Vuid || shard.Size != ns.Size {
+			return nil, ErrShardHeaderNotMatch
+		}
+	}
+
 	// new buffer
 	block := make([]byte, core.CrcBlockUnitSize)
 
@@ -427,6 +568,64 @@ func (cd *datafile) Read(ctx context.Context, shard *core.Shard, from, to uint32
 	return r, nil
 }
 
+// Verify checks header, crc blocks and footer of shard,
+// return core.ErrShardCorrupted if the data on disk is broken
+func (cd *datafile) Verify(ctx context.Context, shard *core.Shard) (err error) {
+	var ns core.Shard
+
+	if shard.Offset < _chunkHeaderSize {
+		return bloberr.ErrShardInvalidOffset
+	}
+
+	iosr := cd.qosReaderAt(ctx, cd.ef)
+
+	// header
+	buf := make([]byte, core.GetShardHeaderSize())
+	if _, err = iosr.ReadAt(buf, shard.Offset); err != nil {
+		return err
+	}
+	if err = ns.ParseHeader(buf); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrShardCorrupted, err)
+	}
+	if shard.Bid != ns.Bid || shard.Vuid != ns.Vuid || shard.Size != ns.Size {
+		return fmt.Errorf("%w: %v", core.ErrShardCorrupted, ErrShardHeaderNotMatch)
+	}
+
+	// body
+	pos := shard.Offset + core.GetShardHeaderSize()
+	block := make([]byte, core.CrcBlockUnitSize)
+	decoder, err := crc32block.NewDecoderWithBlock(iosr, pos, int64(shard.Size), block, bufsize)
+	if err != nil {
+		return err
+	}
+	r, err := decoder.Reader(0, int64(shard.Size))
+	if err != nil {
+		return err
+	}
+	crc := crc32.NewIEEE()
+	if _, err = io.Copy(crc, r); err != nil {
+		if err == crc32block.ErrMismatchedCrc {
+			return fmt.Errorf("%w: %v", core.ErrShardCorrupted, err)
+		}
+		return err
+	}
+
+	// footer
+	pos += crc32block.EncodeSize(int64(shard.Size), core.CrcBlockUnitSize)
+	buf = make([]byte, core.GetShardFooterSize())
+	if _, err = iosr.ReadAt(buf, pos); err != nil {
+		return err
+	}
+	if err = ns.ParseFooter(buf); err != nil {
+		return fmt.Errorf("%w: %v", core.ErrShardCorrupted, err)
+	}
+	if actual := crc.Sum32(); actual != ns.Crc || actual != shard.Crc {
+		return fmt.Errorf("%w: %v", core.ErrShardCorrupted, core.ErrShardDataCrc)
+	}
+
+	return nil
+}
+
 func (cd *datafile) Delete(ctx context.Context, shard *core.Shard) (err error) {
 	var ns core.Shard
 	var discardSize int64
@@ -458,13 +657,29 @@ func (cd *datafile) Delete(ctx context.Context, shard *core.Shard) (err error) {
 	// punch hole
 	discardSize = core.Alignphysize(int64(shard.Size))
 	discardSize = core.AlignSize(discardSize, _pagesize)
-	err = cd.ef.Discard(shard.Offset, discardSize)
+	if err = cd.ef.Discard(shard.Offset, discardSize); err != nil {
+		return err
+	}
+	cd.written()
+
+	if cd.extents != nil {
+		cd.wLock.Lock()
+		err = cd.unlockPersistExtents(ctx, cd.extents.free(shard.Offset, discardSize))
+	}
 
 	return err
 }
 
 func (cd *datafile) Destroy(ctx context.Context) (err error) {
 	log.Warnf("destroy chunk data: %s", cd.ef.Name())
+
+	if cd.extents != nil {
+		cd.wLock.Lock()
+		if err = cd.unlockPersistExtents(ctx, cd.extents.destroy()); err != nil {
+			return err
+		}
+	}
+
 	return os.Remove(cd.File)
 }
 
@@ -491,6 +706,12 @@ func (cd *datafile) Stat() (stat *core.StorageStat, err error) {
 		CreateTime: cd.header.createTime,
 	}
 
+	if cd.extents != nil {
+		cd.wLock.RLock()
+		stat.FreeSize = cd.extents.total
+		cd.wLock.RUnlock()
+	}
+
 	return stat, nil
 }
 
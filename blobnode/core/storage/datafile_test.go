@@ -17,6 +17,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -30,6 +31,7 @@ import (
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
 	"github.com/cubefs/blobstore/blobnode/base/qos"
 	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/sys"
 	"github.com/cubefs/blobstore/common/crc32block"
 	"github.com/cubefs/blobstore/common/proto"
 )
@@ -157,6 +159,53 @@ func TestChunkData_Write(t *testing.T) {
 	require.Equal(t, expectedOff, cd.wOff)
 }
 
+func TestChunkData_Verify(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"ChunkDataVerify")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	chunkname := filepath.Join(testDir, bnapi.NewChunkId(0).String())
+
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+	cd, err := NewChunkData(ctx, core.VuidMeta{}, chunkname, &core.Config{}, true, ioQos)
+	require.NoError(t, err)
+	defer cd.Close()
+
+	sharddata := bytes.Repeat([]byte("test data"), 1<<10)
+	shard := core.NewShardWriter(1024, 10, uint32(len(sharddata)), bytes.NewReader(sharddata))
+	require.NoError(t, cd.Write(ctx, shard))
+	require.NoError(t, cd.Verify(ctx, shard))
+
+	err = cd.Verify(ctx, &core.Shard{Offset: _chunkHeaderSize - 1})
+	require.Error(t, err)
+	require.False(t, errors.Is(err, core.ErrShardCorrupted))
+
+	// meta not match
+	ns := core.ShardCopy(shard)
+	ns.Crc++
+	require.ErrorIs(t, cd.Verify(ctx, ns), core.ErrShardCorrupted)
+	ns = core.ShardCopy(shard)
+	ns.Bid++
+	require.ErrorIs(t, cd.Verify(ctx, ns), core.ErrShardCorrupted)
+
+	bodyOff := shard.Offset + core.GetShardHeaderSize()
+	footerOff := bodyOff + crc32block.EncodeSize(int64(shard.Size), core.CrcBlockUnitSize)
+	for _, off := range []int64{shard.Offset + 4, bodyOff + 100, footerOff} {
+		orig := make([]byte, 1)
+		_, err = cd.ef.ReadAt(orig, off)
+		require.NoError(t, err)
+
+		_, err = cd.ef.WriteAt([]byte{orig[0] + 1}, off)
+		require.NoError(t, err)
+		require.ErrorIs(t, cd.Verify(ctx, shard), core.ErrShardCorrupted, off)
+
+		_, err = cd.ef.WriteAt(orig, off)
+		require.NoError(t, err)
+		require.NoError(t, cd.Verify(ctx, shard))
+	}
+}
+
 func TestChunkData_ConcurrencyWrite(t *testing.T) {
 	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"ChunkDataWriteCon")
 	require.NoError(t, err)
@@ -504,3 +553,33 @@ func TestChunkHeader(t *testing.T) {
 	s := chunkHeader.String()
 	require.NotNil(t, s)
 }
+
+func TestChunkData_DirectIO(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"DirectIO")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ring, err := sys.NewRing(core.DefaultIOUringEntries)
+	if err != nil {
+		ring = nil
+	}
+	diskConfig := &core.Config{
+		BaseConfig: core.BaseConfig{Path: testDir, DirectIO: true},
+		IORing:     ring,
+	}
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+	chunkname := filepath.Join(testDir, bnapi.NewChunkId(0).String())
+	cd, err := NewChunkData(context.Background(), core.VuidMeta{}, chunkname, diskConfig, true, ioQos)
+	require.NoError(t, err)
+
+	shards, datas := genGroupShards(8, 10000)
+	for _, shard := range shards {
+		require.NoError(t, cd.Write(context.Background(), shard))
+	}
+	checkGroupShards(t, cd, shards, datas)
+	cd.Close()
+
+	if ring != nil {
+		require.NoError(t, ring.Close())
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// storage engines
+const (
+	// EngineDataFile shard data in datafile, shard meta in kv db
+	EngineDataFile = core.DefaultStorageEngine
+	// EngineMemory shard data and meta in memory, only for testing
+	EngineMemory = "memory"
+	// EngineLogFile shard data and meta are appended in one log file
+	EngineLogFile = "logfile"
+)
+
+func init() {
+	core.RegisterStorageEngine(EngineDataFile, newDataFileStorage)
+	core.RegisterStorageEngine(EngineMemory, newMemoryStorage)
+	core.RegisterStorageEngine(EngineLogFile, newLogFileStorage)
+}
+
+func newDataFileStorage(ctx context.Context, dataPath string, vm core.VuidMeta, opt *core.Option) (
+	core.Storage, error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	// chunk data
+	chunkFile := filepath.Join(dataPath, vm.ChunkId.String())
+
+	// new chunkData fd
+	cd, err := NewChunkData(ctx, vm, chunkFile, opt.Conf, opt.CreateDataIfMiss, opt.IoQos)
+	if err != nil {
+		span.Errorf("Failed new chunk data. dp:%s, err:%v", dataPath, err)
+		return nil, err
+	}
+
+	// create meta fd
+	cm, err := NewChunkMeta(ctx, vm, opt.DB)
+	if err != nil {
+		span.Errorf("Failed new chunk meta. vm:%v, err:%v", vm, err)
+		cd.Close()
+		return nil, err
+	}
+
+	// reuse punched holes
+	if opt.Conf != nil && opt.Conf.ReuseExtents {
+		if err = cd.loadExtents(ctx, opt.DB, vm.ChunkId); err != nil {
+			span.Errorf("Failed load free extents. vm:%v, err:%v", vm, err)
+			cd.Close()
+			return nil, err
+		}
+	}
+
+	return NewStorage(cm, cd), nil
+}
+
+func newMemoryStorage(ctx context.Context, dataPath string, vm core.VuidMeta, opt *core.Option) (
+	core.Storage, error) {
+	return NewStorage(newMemMeta(vm), newMemData(vm)), nil
+}
+
+func newLogFileStorage(ctx context.Context, dataPath string, vm core.VuidMeta, opt *core.Option) (
+	core.Storage, error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	chunkFile := filepath.Join(dataPath, vm.ChunkId.String())
+	lf, err := NewLogFile(ctx, vm, chunkFile, opt.Conf, opt.CreateDataIfMiss, opt.IoQos)
+	if err != nil {
+		span.Errorf("Failed new chunk log file. dp:%s, err:%v", dataPath, err)
+		return nil, err
+	}
+
+	return NewStorage(lf.MetaHandler(), lf), nil
+}
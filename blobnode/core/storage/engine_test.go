@@ -0,0 +1,298 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base/qos"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
+	bloberr "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+// engineSuite conformance tests of storage engine,
+// all registered engines must pass it.
+type engineSuite struct {
+	t        *testing.T
+	dataPath string
+	opt      *core.Option
+	vuid     proto.Vuid
+	datas    map[proto.BlobID][]byte
+}
+
+func newEngineSuite(t *testing.T, name string) (*engineSuite, func()) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"Engine")
+	require.NoError(t, err)
+
+	require.NoError(t, core.EnsureDiskArea(testDir))
+	kvdb, err := db.NewMetaHandler(core.GetMetaPath(testDir), db.MetaConfig{})
+	require.NoError(t, err)
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+
+	s := &engineSuite{
+		t:        t,
+		dataPath: core.GetDataPath(testDir),
+		opt: &core.Option{
+			DB: kvdb,
+			Conf: &core.Config{
+				BaseConfig: core.BaseConfig{StorageEngine: name},
+				HandleIOError: func(ctx context.Context, diskID proto.DiskID, diskErr error) {
+				},
+			},
+			IoQos:            ioQos,
+			CreateDataIfMiss: true,
+		},
+		vuid:  proto.Vuid(1024),
+		datas: make(map[proto.BlobID][]byte),
+	}
+	return s, func() {
+		os.RemoveAll(testDir)
+	}
+}
+
+func (s *engineSuite) newStorage(parent bnapi.ChunkId) core.Storage {
+	return s.openStorage(bnapi.NewChunkId(s.vuid), parent)
+}
+
+func (s *engineSuite) openStorage(id bnapi.ChunkId, parent bnapi.ChunkId) core.Storage {
+	engine, err := core.GetStorageEngine(s.opt.Conf.StorageEngine)
+	require.NoError(s.t, err)
+	vm := core.VuidMeta{
+		Vuid:        s.vuid,
+		DiskID:      1,
+		ChunkId:     id,
+		ParentChunk: parent,
+		Ctime:       1024,
+	}
+	stg, err := engine(context.Background(), s.dataPath, vm, s.opt)
+	require.NoError(s.t, err)
+	require.Equal(s.t, vm.ChunkId, stg.ID())
+	return stg
+}
+
+func (s *engineSuite) write(stg core.Storage, bid proto.BlobID, size int) {
+	data := make([]byte, size)
+	rand.Read(data)
+	shard := core.NewShardWriter(bid, s.vuid, uint32(size), bytes.NewReader(data))
+	require.NoError(s.t, stg.Write(context.Background(), shard))
+	require.Equal(s.t, crc32.ChecksumIEEE(data), shard.Crc)
+	s.datas[bid] = data
+}
+
+func (s *engineSuite) read(stg core.Storage, bid proto.BlobID, from, to int64) []byte {
+	ctx := context.Background()
+	sm, err := stg.ReadShardMeta(ctx, bid)
+	require.NoError(s.t, err)
+
+	shard := core.NewShardReader(bid, s.vuid, from, to, nil)
+	shard.FillMeta(*sm)
+	rc, err := stg.NewRangeReader(ctx, shard, from, to)
+	require.NoError(s.t, err)
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(s.t, err)
+	return data
+}
+
+// checkShards checks all shards of the storage are same with datas
+func (s *engineSuite) checkShards(stg core.Storage, datas map[proto.BlobID][]byte) {
+	ctx := context.Background()
+	bids := make([]proto.BlobID, 0, len(datas))
+	startBid := proto.InValidBlobID
+	for {
+		err := stg.ScanMeta(ctx, startBid, 2, func(bid proto.BlobID, sm *core.ShardMeta) error {
+			require.Less(s.t, startBid, bid)
+			startBid = bid
+			bids = append(bids, bid)
+
+			data, ok := datas[bid]
+			require.True(s.t, ok, bid)
+			require.Equal(s.t, uint32(len(data)), sm.Size)
+			require.Equal(s.t, crc32.ChecksumIEEE(data), sm.Crc)
+			require.Equal(s.t, data, s.read(stg, bid, 0, int64(sm.Size)))
+			return nil
+		})
+		if err == core.ErrChunkScanEOF {
+			break
+		}
+		require.NoError(s.t, err)
+	}
+	require.Equal(s.t, len(datas), len(bids))
+}
+
+func (s *engineSuite) testReadWrite(stg core.Storage) {
+	ctx := context.Background()
+	sizes := []int{1, 4 << 10, core.CrcBlockUnitSize + 3, 200 << 10}
+	for idx, size := range sizes {
+		s.write(stg, proto.BlobID(idx+1), size)
+	}
+	s.checkShards(stg, s.datas)
+
+	// range read
+	data := s.datas[4]
+	for _, rang := range [][2]int64{{0, 0}, {0, 1}, {1 << 10, 100 << 10}, {100 << 10, 200 << 10}} {
+		require.Equal(s.t, data[rang[0]:rang[1]], s.read(stg, 4, rang[0], rang[1]))
+	}
+
+	// overwrite
+	s.write(stg, 2, 10<<10)
+	s.checkShards(stg, s.datas)
+
+	// not found
+	_, err := stg.ReadShardMeta(ctx, 100)
+	require.True(s.t, os.IsNotExist(err))
+
+	// invalid scan
+	err = stg.ScanMeta(ctx, proto.InValidBlobID, 0, func(bid proto.BlobID, sm *core.ShardMeta) error {
+		return nil
+	})
+	require.Error(s.t, err)
+
+	stat, err := stg.Stat(ctx)
+	require.NoError(s.t, err)
+	require.True(s.t, stat.FileSize > 0)
+	require.Equal(s.t, int64(1024), stat.CreateTime)
+
+	require.NoError(s.t, stg.SyncData(ctx))
+	require.NoError(s.t, stg.Sync(ctx))
+}
+
+func (s *engineSuite) testVerify(stg core.Storage) {
+	ctx := context.Background()
+	for bid := range s.datas {
+		sm, err := stg.ReadShardMeta(ctx, bid)
+		require.NoError(s.t, err)
+		shard := core.NewShardReader(bid, s.vuid, 0, int64(sm.Size), nil)
+		shard.FillMeta(*sm)
+		require.NoError(s.t, stg.DataHandler().Verify(ctx, shard))
+
+		shard.Crc++
+		require.ErrorIs(s.t, stg.DataHandler().Verify(ctx, shard), core.ErrShardCorrupted)
+	}
+}
+
+func (s *engineSuite) testDelete(stg core.Storage) {
+	ctx := context.Background()
+
+	// delete before mark delete
+	_, err := stg.Delete(ctx, 1)
+	require.ErrorIs(s.t, err, bloberr.ErrShardNotMarkDelete)
+
+	require.NoError(s.t, stg.MarkDelete(ctx, 1))
+	require.ErrorIs(s.t, stg.MarkDelete(ctx, 1), bloberr.ErrShardMarkDeleted)
+	sm, err := stg.ReadShardMeta(ctx, 1)
+	require.NoError(s.t, err)
+	require.Equal(s.t, bnapi.ShardStatusMarkDelete, sm.Flag)
+
+	n, err := stg.Delete(ctx, 1)
+	require.NoError(s.t, err)
+	require.Equal(s.t, int64(len(s.datas[1])), n)
+	delete(s.datas, 1)
+
+	require.NoError(s.t, stg.MarkDelete(ctx, 3))
+	_, err = stg.Delete(ctx, 3)
+	require.NoError(s.t, err)
+	delete(s.datas, 3)
+
+	_, err = stg.ReadShardMeta(ctx, 1)
+	require.True(s.t, os.IsNotExist(err))
+	require.Error(s.t, stg.MarkDelete(ctx, 1))
+	s.checkShards(stg, s.datas)
+}
+
+// testCompact compacts stg to a new storage as chunk compacting
+func (s *engineSuite) testCompact(stg core.Storage) core.Storage {
+	ctx := context.Background()
+	dst := s.newStorage(stg.ID())
+	replStg := NewReplicateStg(stg, dst, nil)
+
+	copied := make(map[proto.BlobID]bool)
+	startBid := proto.InValidBlobID
+	for {
+		err := replStg.ScanMeta(ctx, startBid, 1, func(bid proto.BlobID, sm *core.ShardMeta) error {
+			startBid = bid
+			shard := core.NewShardReader(bid, s.vuid, 0, int64(sm.Size), nil)
+			shard.FillMeta(*sm)
+			rc, err := stg.NewRangeReader(ctx, shard, 0, int64(sm.Size))
+			if err != nil {
+				return err
+			}
+			shard.Body = rc
+			copied[bid] = true
+			return dst.Write(ctx, shard)
+		})
+		if err == core.ErrChunkScanEOF {
+			break
+		}
+		require.NoError(s.t, err)
+
+		// written while compacting
+		if len(copied) == 1 {
+			data := make([]byte, 1<<10)
+			rand.Read(data)
+			shard := core.NewShardWriter(100, s.vuid, uint32(len(data)), bytes.NewReader(data))
+			require.NoError(s.t, replStg.Write(ctx, shard))
+			s.datas[100] = data
+		}
+	}
+	require.NoError(s.t, replStg.PendingError())
+	require.NoError(s.t, dst.Sync(ctx))
+
+	s.checkShards(stg, s.datas)
+	s.checkShards(dst, s.datas)
+
+	stat, err := dst.Stat(ctx)
+	require.NoError(s.t, err)
+	require.Equal(s.t, stg.ID(), stat.ParentID)
+	return dst
+}
+
+func TestStorageEngines(t *testing.T) {
+	require.Subset(t, core.StorageEngines(), []string{EngineDataFile, EngineMemory, EngineLogFile})
+
+	for _, name := range core.StorageEngines() {
+		t.Run(name, func(t *testing.T) {
+			s, clean := newEngineSuite(t, name)
+			defer clean()
+
+			stg := s.newStorage(bnapi.ChunkId{})
+			s.testReadWrite(stg)
+			s.testVerify(stg)
+			s.testDelete(stg)
+			dst := s.testCompact(stg)
+
+			stg.Destroy(context.Background())
+			stg.Close(context.Background())
+			s.checkShards(dst, s.datas)
+			dst.Destroy(context.Background())
+			dst.Close(context.Background())
+		})
+	}
+}
+
+func TestStorageEngineNotFound(t *testing.T) {
+	_, err := core.GetStorageEngine("not-exist")
+	require.ErrorIs(t, err, core.ErrStorageEngineNotFound)
+}
@@ -0,0 +1,273 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/db"
+	rdb "github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+/*
+ * free extents are the punched holes of chunk data file, which can be
+ * reused by new shards. they are persisted in the meta kv db of the chunk:
+ *   extents${chunk_name}${offset} => ${size}
+ * extents are changed in memory, the changes are persisted later in order,
+ * so that the db is not written under the write lock of data file.
+ */
+
+const (
+	_chunkExtentSpacePrefix = "extents"
+)
+
+var (
+	extentSpacePrefixLen = len(_chunkExtentSpacePrefix)
+	extentChunkCommonLen = extentSpacePrefixLen + bnapi.ChunkIdLength
+	extentKeyLen         = extentChunkCommonLen + 8
+	extentValueLen       = 8
+)
+
+var ErrExtentKeyPrefix = errors.New("extent key error prefix")
+
+// extent size 0 means the extent is deleted in changes
+type extent struct {
+	off  int64
+	size int64
+}
+
+type freeExtents struct {
+	db     db.MetaHandler
+	prefix []byte // extents${chunk_name}
+
+	starts map[int64]int64              // offset -> size
+	ends   map[int64]int64              // offset+size -> offset
+	bySize map[int64]map[int64]struct{} // size -> offsets
+	sizes  []int64                      // sorted sizes of extents
+	total  int64                        // total size of extents
+}
+
+func genExtentCommonKey(id bnapi.ChunkId) []byte {
+	buf := make([]byte, extentChunkCommonLen)
+
+	copy(buf[0:extentSpacePrefixLen], []byte(_chunkExtentSpacePrefix))
+	copy(buf[extentSpacePrefixLen:extentChunkCommonLen], id[:])
+
+	return buf
+}
+
+func newFreeExtents(db db.MetaHandler, id bnapi.ChunkId) *freeExtents {
+	return &freeExtents{
+		db:     db,
+		prefix: genExtentCommonKey(id),
+		starts: make(map[int64]int64),
+		ends:   make(map[int64]int64),
+		bySize: make(map[int64]map[int64]struct{}),
+	}
+}
+
+// loadFreeExtents loads persisted extents in range [minOff, maxOff)
+func loadFreeExtents(ctx context.Context, db db.MetaHandler, id bnapi.ChunkId, minOff, maxOff int64) (
+	fe *freeExtents, err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	fe = newFreeExtents(db, id)
+
+	iter := db.NewIterator(ctx, func(op *rdb.Op) {
+		op.Ro = rdb.NewReadOptions()
+		// set fill cache false
+		op.Ro.SetFillCache(false)
+	})
+	defer iter.Close()
+
+	exts := make([]extent, 0)
+	for iter.Seek(fe.prefix); iter.ValidForPrefix(fe.prefix); iter.Next() {
+		k, v := iter.Key(), iter.Value()
+		key, value := k.Data(), v.Data()
+		if len(key) != extentKeyLen || len(value) != extentValueLen {
+			k.Free()
+			v.Free()
+			span.Errorf("invalid extent key:%v value:%v", key, value)
+			return nil, ErrExtentKeyPrefix
+		}
+		ext := extent{
+			off:  int64(binary.BigEndian.Uint64(key[extentChunkCommonLen:])),
+			size: int64(binary.BigEndian.Uint64(value)),
+		}
+		k.Free()
+		v.Free()
+		exts = append(exts, ext)
+	}
+
+	sort.Slice(exts, func(i, j int) bool {
+		return exts[i].off < exts[j].off
+	})
+
+	end := minOff
+	for _, ext := range exts {
+		// drop broken or overlapped extent, the space is leaked until compaction
+		if ext.size <= 0 || ext.size%_pagesize != 0 || ext.off < end || ext.off+ext.size > maxOff {
+			span.Warnf("drop invalid extent:%+v, min:%d, max:%d", ext, end, maxOff)
+			if err = fe.deleteKey(ctx, ext.off); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		fe.insert(ext)
+		end = ext.off + ext.size
+	}
+
+	return fe, nil
+}
+
+func (fe *freeExtents) genKey(off int64) []byte {
+	key := make([]byte, extentKeyLen)
+	copy(key, fe.prefix)
+	binary.BigEndian.PutUint64(key[extentChunkCommonLen:], uint64(off))
+	return key
+}
+
+func (fe *freeExtents) putKey(ctx context.Context, ext extent) error {
+	value := make([]byte, extentValueLen)
+	binary.BigEndian.PutUint64(value, uint64(ext.size))
+	return fe.db.Put(ctx, rdb.KV{Key: fe.genKey(ext.off), Value: value})
+}
+
+func (fe *freeExtents) deleteKey(ctx context.Context, off int64) error {
+	return fe.db.Delete(ctx, fe.genKey(off))
+}
+
+func (fe *freeExtents) insert(ext extent) {
+	fe.starts[ext.off] = ext.size
+	fe.ends[ext.off+ext.size] = ext.off
+	fe.total += ext.size
+
+	offs, ok := fe.bySize[ext.size]
+	if !ok {
+		offs = make(map[int64]struct{})
+		fe.bySize[ext.size] = offs
+
+		idx := sort.Search(len(fe.sizes), func(i int) bool { return fe.sizes[i] >= ext.size })
+		fe.sizes = append(fe.sizes, 0)
+		copy(fe.sizes[idx+1:], fe.sizes[idx:])
+		fe.sizes[idx] = ext.size
+	}
+	offs[ext.off] = struct{}{}
+}
+
+func (fe *freeExtents) remove(ext extent) {
+	delete(fe.starts, ext.off)
+	delete(fe.ends, ext.off+ext.size)
+	fe.total -= ext.size
+
+	offs := fe.bySize[ext.size]
+	delete(offs, ext.off)
+	if len(offs) == 0 {
+		delete(fe.bySize, ext.size)
+
+		idx := sort.Search(len(fe.sizes), func(i int) bool { return fe.sizes[i] >= ext.size })
+		fe.sizes = append(fe.sizes[:idx], fe.sizes[idx+1:]...)
+	}
+}
+
+// alloc takes size bytes from the smallest extent that fits,
+// the rest of the extent is still free.
+// The allocated space must not be used until the changes persisted.
+func (fe *freeExtents) alloc(size int64) (pos int64, ok bool, changes []extent) {
+	idx := sort.Search(len(fe.sizes), func(i int) bool { return fe.sizes[i] >= size })
+	if idx == len(fe.sizes) {
+		return 0, false, nil
+	}
+
+	ext := extent{off: -1, size: fe.sizes[idx]}
+	for off := range fe.bySize[ext.size] {
+		if ext.off < 0 || off < ext.off {
+			ext.off = off
+		}
+	}
+
+	// delete first, crash only leaks the space
+	changes = append(changes, extent{off: ext.off})
+	fe.remove(ext)
+
+	if ext.size > size {
+		rest := extent{off: ext.off + size, size: ext.size - size}
+		changes = append(changes, rest)
+		fe.insert(rest)
+	}
+
+	return ext.off, true, changes
+}
+
+// free adds the extent and merges it with the adjacent extents
+func (fe *freeExtents) free(off, size int64) (changes []extent) {
+	ext := extent{off: off, size: size}
+
+	if prevOff, ok := fe.ends[off]; ok {
+		prev := extent{off: prevOff, size: fe.starts[prevOff]}
+		changes = append(changes, extent{off: prev.off})
+		fe.remove(prev)
+		ext.off, ext.size = prev.off, prev.size+ext.size
+	}
+	if nextSize, ok := fe.starts[off+size]; ok {
+		next := extent{off: off + size, size: nextSize}
+		changes = append(changes, extent{off: next.off})
+		fe.remove(next)
+		ext.size += next.size
+	}
+
+	changes = append(changes, ext)
+	fe.insert(ext)
+
+	return changes
+}
+
+// destroy removes all extents
+func (fe *freeExtents) destroy() (changes []extent) {
+	for off := range fe.starts {
+		changes = append(changes, extent{off: off})
+	}
+
+	fe.starts = make(map[int64]int64)
+	fe.ends = make(map[int64]int64)
+	fe.bySize = make(map[int64]map[int64]struct{})
+	fe.sizes = nil
+	fe.total = 0
+
+	return changes
+}
+
+// persist writes the changes into db in order
+func (fe *freeExtents) persist(ctx context.Context, changes []extent) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	for _, ext := range changes {
+		if ext.size == 0 {
+			err = fe.deleteKey(ctx, ext.off)
+		} else {
+			err = fe.putKey(ctx, ext)
+		}
+		if err != nil {
+			span.Errorf("Failed persist extent:%+v, err:%v", ext, err)
+			return err
+		}
+	}
+	return nil
+}
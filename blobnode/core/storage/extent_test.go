@@ -0,0 +1,221 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base/qos"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func TestFreeExtents(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"FreeExtents")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	kvdb, err := db.NewMetaHandler(testDir, db.MetaConfig{})
+	require.NoError(t, err)
+	id := bnapi.NewChunkId(1024)
+
+	const page = int64(_pagesize)
+	maxOff := 100 * page
+
+	fe, err := loadFreeExtents(ctx, kvdb, id, page, maxOff)
+	require.NoError(t, err)
+	alloc := func(size int64) (int64, bool) {
+		pos, ok, changes := fe.alloc(size)
+		require.NoError(t, fe.persist(ctx, changes))
+		return pos, ok
+	}
+	free := func(off, size int64) {
+		require.NoError(t, fe.persist(ctx, fe.free(off, size)))
+	}
+
+	_, ok := alloc(page)
+	require.False(t, ok)
+
+	// [10, 12) [20, 21) [30, 34)
+	free(10*page, 2*page)
+	free(20*page, page)
+	free(30*page, 4*page)
+	require.Equal(t, 7*page, fe.total)
+
+	// best fit
+	pos, ok := alloc(page)
+	require.True(t, ok)
+	require.Equal(t, 20*page, pos)
+	pos, ok = alloc(3 * page)
+	require.True(t, ok)
+	require.Equal(t, 30*page, pos)
+	_, ok = alloc(3 * page)
+	require.False(t, ok)
+	require.Equal(t, 3*page, fe.total)
+
+	// changes are persisted
+	reloaded, err := loadFreeExtents(ctx, kvdb, id, page, maxOff)
+	require.NoError(t, err)
+	require.Equal(t, fe.starts, reloaded.starts)
+
+	// merge: [10, 12) + [12, 13) + [13, 33) + [33, 34)
+	free(12*page, page)
+	free(13*page, 20*page)
+	require.Equal(t, map[int64]int64{10 * page: 24 * page}, fe.starts)
+	require.Equal(t, []int64{24 * page}, fe.sizes)
+
+	// reload
+	fe, err = loadFreeExtents(ctx, kvdb, id, page, maxOff)
+	require.NoError(t, err)
+	require.Equal(t, map[int64]int64{10 * page: 24 * page}, fe.starts)
+	require.Equal(t, 24*page, fe.total)
+
+	// out of range extents are dropped
+	require.NoError(t, fe.putKey(ctx, extent{off: 90 * page, size: 20 * page}))
+	require.NoError(t, fe.putKey(ctx, extent{off: 11 * page, size: page}))
+	fe, err = loadFreeExtents(ctx, kvdb, id, page, maxOff)
+	require.NoError(t, err)
+	require.Equal(t, map[int64]int64{10 * page: 24 * page}, fe.starts)
+
+	require.NoError(t, fe.persist(ctx, fe.destroy()))
+	fe, err = loadFreeExtents(ctx, kvdb, id, page, maxOff)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(fe.starts))
+	require.Equal(t, int64(0), fe.total)
+}
+
+func TestDataFileReuseExtents(t *testing.T) {
+	s, clean := newEngineSuite(t, EngineDataFile)
+	defer clean()
+	s.opt.Conf.ReuseExtents = true
+
+	ctx := context.Background()
+	stg := s.newStorage(bnapi.ChunkId{})
+	for bid := proto.BlobID(1); bid <= 4; bid++ {
+		s.write(stg, bid, 16<<10)
+	}
+	sm, err := stg.ReadShardMeta(ctx, 2)
+	require.NoError(t, err)
+	stat, err := stg.Stat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), stat.FreeSize)
+
+	require.NoError(t, stg.MarkDelete(ctx, 2))
+	_, err = stg.Delete(ctx, 2)
+	require.NoError(t, err)
+	delete(s.datas, 2)
+
+	freeStat, err := stg.Stat(ctx)
+	require.NoError(t, err)
+	freeSize := core.AlignSize(core.Alignphysize(16<<10), _pagesize)
+	require.Equal(t, freeSize, freeStat.FreeSize)
+
+	// read deleted shard after its space reused
+	s.write(stg, 5, 8<<10)
+	newSm, err := stg.ReadShardMeta(ctx, 5)
+	require.NoError(t, err)
+	require.Equal(t, sm.Offset, newSm.Offset)
+	shard := core.NewShardReader(2, s.vuid, 0, int64(sm.Size), nil)
+	shard.FillMeta(*sm)
+	_, err = stg.NewRangeReader(ctx, shard, 0, int64(sm.Size))
+	require.ErrorIs(t, err, ErrShardHeaderNotMatch)
+
+	// too large to reuse
+	s.write(stg, 6, 16<<10)
+	newSm, err = stg.ReadShardMeta(ctx, 6)
+	require.NoError(t, err)
+	require.Equal(t, core.AlignSize(stat.FileSize, _pagesize), newSm.Offset)
+
+	newStat, err := stg.Stat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, freeSize-core.AlignSize(core.Alignphysize(8<<10), _pagesize), newStat.FreeSize)
+	s.checkShards(stg, s.datas)
+
+	// reopen
+	id := stg.ID()
+	stg.Close(ctx)
+	stg = s.openStorage(id, bnapi.ChunkId{})
+	reopenStat, err := stg.Stat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, newStat.FreeSize, reopenStat.FreeSize)
+	s.checkShards(stg, s.datas)
+
+	stg.Destroy(ctx)
+	stg.Close(ctx)
+}
+
+func TestDataFileWithoutExtents(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"WithoutExtents")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+	cd, err := NewChunkData(ctx, core.VuidMeta{}, testDir+"/chunk", &core.Config{}, true, ioQos)
+	require.NoError(t, err)
+	defer cd.Close()
+
+	data := []byte("test data")
+	shard := core.NewShardWriter(1, 10, uint32(len(data)), bytes.NewReader(data))
+	require.NoError(t, cd.Write(ctx, shard))
+	require.NoError(t, cd.Delete(ctx, shard))
+
+	stat, err := cd.Stat()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), stat.FreeSize)
+
+	// append only
+	wOff := cd.wOff
+	shard = core.NewShardWriter(2, 10, uint32(len(data)), bytes.NewReader(data))
+	require.NoError(t, cd.Write(ctx, shard))
+	require.Equal(t, wOff, shard.Offset)
+}
+
+func TestDataFileReuseExtentsDisabled(t *testing.T) {
+	s, clean := newEngineSuite(t, EngineDataFile)
+	defer clean()
+
+	ctx := context.Background()
+	stg := s.newStorage(bnapi.ChunkId{})
+	for bid := proto.BlobID(1); bid <= 2; bid++ {
+		s.write(stg, bid, 16<<10)
+	}
+	require.NoError(t, stg.MarkDelete(ctx, 1))
+	_, err := stg.Delete(ctx, 1)
+	require.NoError(t, err)
+	delete(s.datas, 1)
+
+	stat, err := stg.Stat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), stat.FreeSize)
+
+	s.write(stg, 3, 8<<10)
+	sm, err := stg.ReadShardMeta(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, core.AlignSize(stat.FileSize, _pagesize), sm.Offset)
+	s.checkShards(stg, s.datas)
+
+	stg.Destroy(ctx)
+	stg.Close(ctx)
+}
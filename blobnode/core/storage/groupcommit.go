@@ -0,0 +1,235 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"hash/crc32"
+	"io"
+	"sync"
+	"time"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base/priority"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/crc32block"
+	bloberr "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+/*
+ * group commit coalesces concurrent small shard writes of one chunk:
+ * every writer encodes its shard into a page aligned buffer, the first
+ * one becomes the leader, which writes the pending buffers contiguously
+ * with one WriteAt, then fdatasync once and acknowledges all of them.
+ * the followers queued during the commit are taken by the next leader.
+ */
+
+type commitRecord struct {
+	shard  *core.Shard
+	data   []byte // header + body + footer, page aligned
+	ioType bnapi.IOType
+
+	wake chan struct{} // committed, or become the leader
+	done bool
+	err  error
+}
+
+type groupCommitter struct {
+	lock    sync.Mutex
+	pending []*commitRecord
+	leading bool
+}
+
+// takeBatch pops the pending records up to maxSize bytes, at least one
+func (gc *groupCommitter) takeBatch(maxSize int64) (batch []*commitRecord, size int64) {
+	n := 0
+	for ; n < len(gc.pending); n++ {
+		rsize := int64(len(gc.pending[n].data))
+		if n > 0 && size+rsize > maxSize {
+			break
+		}
+		size += rsize
+	}
+	batch = gc.pending[:n:n]
+	gc.pending = gc.pending[n:]
+	return batch, size
+}
+
+func (cd *datafile) groupWrite(ctx context.Context, shard *core.Shard) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	rec := &commitRecord{shard: shard, ioType: bnapi.Getiotype(ctx), wake: make(chan struct{}, 1)}
+	if rec.data, err = cd.encodeShard(ctx, shard); err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	gc := &cd.committer
+	gc.lock.Lock()
+	gc.pending = append(gc.pending, rec)
+	leader := !gc.leading
+	gc.leading = true
+	gc.lock.Unlock()
+
+	if !leader {
+		<-rec.wake
+	}
+	if !rec.done {
+		cd.commitPending(ctx)
+	}
+	span.AppendTrackLog("gc.w", start, rec.err)
+
+	return rec.err
+}
+
+// encodeShard encodes the shard in memory with the same layout of writeShard
+func (cd *datafile) encodeShard(ctx context.Context, shard *core.Shard) (data []byte, err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	phySize := core.Alignphysize(int64(shard.Size))
+	buf := bytes.NewBuffer(make([]byte, 0, core.AlignSize(phySize, _pagesize)))
+
+	headerbuf := make([]byte, core.GetShardHeaderSize())
+	if err = shard.WriterHeader(headerbuf); err != nil {
+		return nil, err
+	}
+	buf.Write(headerbuf)
+
+	crc := crc32.NewIEEE()
+	body := io.LimitReader(shard.Body, int64(shard.Size))
+	body = io.TeeReader(body, crc)
+
+	buffer := cd.pool.Get().([]byte)
+	defer cd.pool.Put(buffer) // nolint: staticcheck
+
+	encoder, err := crc32block.NewEncoder(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	_, err = encoder.Encode(body, int64(shard.Size), buf)
+	span.AppendTrackLog("net.r", start, err)
+	if err != nil {
+		if _, ok := err.(crc32block.ReaderError); ok {
+			err = bloberr.ErrReaderError
+		}
+		return nil, err
+	}
+
+	shard.Crc = crc.Sum32()
+
+	footerbuf := make([]byte, core.GetShardFooterSize())
+	if err = shard.WriterFooter(footerbuf); err != nil {
+		return nil, err
+	}
+	buf.Write(footerbuf)
+
+	// padding is zero filled
+	data = buf.Bytes()
+	return data[:cap(data)], nil
+}
+
+// commitPending commits one batch, then hands over the leader to the next pending
+func (cd *datafile) commitPending(ctx context.Context) {
+	gc := &cd.committer
+
+	gc.lock.Lock()
+	batch, size := gc.takeBatch(cd.conf.GroupCommitBatchSize)
+	gc.lock.Unlock()
+
+	err := cd.commitBatch(ctx, batch, size)
+
+	var next *commitRecord
+	gc.lock.Lock()
+	for _, rec := range batch {
+		rec.done = true
+		rec.err = err
+	}
+	if len(gc.pending) > 0 {
+		next = gc.pending[0]
+	} else {
+		gc.leading = false
+	}
+	gc.lock.Unlock()
+
+	for _, rec := range batch {
+		rec.wake <- struct{}{}
+	}
+	if next != nil {
+		next.wake <- struct{}{}
+	}
+}
+
+func (cd *datafile) commitBatch(ctx context.Context, batch []*commitRecord, size int64) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	// contiguous space of the whole batch
+	pos, err := cd.allocSpace(ctx, size)
+	if err != nil {
+		return err
+	}
+
+	data := batch[0].data
+	if len(batch) > 1 {
+		data = make([]byte, 0, size)
+		for _, rec := range batch {
+			data = append(data, rec.data...)
+		}
+	}
+
+	// the batch is written for all writers, so it is not canceled with the leader,
+	// and is limited as the writer with the highest priority.
+	qosCtx := bnapi.Setiotype(trace.ContextWithSpan(context.Background(), span), batchIOType(batch))
+	qoswAt := cd.qosWriterAt(qosCtx, cd.ef)
+
+	start := time.Now()
+	_, err = qoswAt.WriteAt(data, pos)
+	span.AppendTrackLog("gc.dat.w", start, err)
+	if err != nil {
+		return err
+	}
+
+	if !cd.conf.DisableSync {
+		start = time.Now()
+		err = cd.ef.DataSync()
+		span.AppendTrackLog("gc.sync", start, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, rec := range batch {
+		rec.shard.Offset = pos
+		pos += int64(len(rec.data))
+	}
+	span.Debugf("group commit %d shards, %d bytes", len(batch), size)
+
+	return nil
+}
+
+// batchIOType returns io type with the highest priority of the batch
+func batchIOType(batch []*commitRecord) bnapi.IOType {
+	ioType := batch[0].ioType
+	for _, rec := range batch[1:] {
+		if priority.GetPriority(rec.ioType) < priority.GetPriority(ioType) {
+			ioType = rec.ioType
+		}
+	}
+	return ioType
+}
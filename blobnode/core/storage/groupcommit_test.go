@@ -0,0 +1,257 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base/qos"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func newGroupCommitData(t *testing.T, testDir string, batchSize int64) *datafile {
+	diskConfig := &core.Config{
+		BaseConfig: core.BaseConfig{Path: testDir},
+		RuntimeConfig: core.RuntimeConfig{
+			GroupCommit:          true,
+			GroupCommitShardSize: 16 << 10,
+			GroupCommitBatchSize: batchSize,
+		},
+	}
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+	chunkname := filepath.Join(testDir, bnapi.NewChunkId(0).String())
+	cd, err := NewChunkData(context.Background(), core.VuidMeta{}, chunkname, diskConfig, true, ioQos)
+	require.NoError(t, err)
+	return cd
+}
+
+// queueWrites blocks the committer, and waits all shards pending
+func queueWrites(t *testing.T, cd *datafile, shards []*core.Shard) *sync.WaitGroup {
+	cd.committer.lock.Lock()
+	cd.committer.leading = true
+	cd.committer.lock.Unlock()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(shards))
+	for i := range shards {
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, cd.Write(context.Background(), shards[i]))
+		}(i)
+	}
+
+	for {
+		cd.committer.lock.Lock()
+		n := len(cd.committer.pending)
+		cd.committer.lock.Unlock()
+		if n == len(shards) {
+			return wg
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func genGroupShards(cnt int, size int) ([]*core.Shard, [][]byte) {
+	shards := make([]*core.Shard, cnt)
+	datas := make([][]byte, cnt)
+	for i := 0; i < cnt; i++ {
+		datas[i] = bytes.Repeat([]byte{byte(i)}, size)
+		shards[i] = core.NewShardWriter(proto.BlobID(i+1), 10, uint32(size), bytes.NewReader(datas[i]))
+	}
+	return shards, datas
+}
+
+func checkGroupShards(t *testing.T, cd *datafile, shards []*core.Shard, datas [][]byte) {
+	ctx := context.Background()
+	for i, shard := range shards {
+		require.True(t, shard.Offset%_pagesize == 0)
+		r, err := cd.Read(ctx, shard, 0, shard.Size)
+		require.NoError(t, err)
+		rd, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, datas[i], rd)
+		require.NoError(t, cd.Verify(ctx, shard))
+	}
+}
+
+func TestGroupCommit_Batch(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"GroupCommitBatch")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	cd := newGroupCommitData(t, testDir, core.DefaultGroupCommitBatchSize)
+	defer cd.Close()
+
+	concurrency := 16
+	shards, datas := genGroupShards(concurrency, 1024)
+	wg := queueWrites(t, cd, shards)
+
+	// all pending shards in one commit
+	cd.commitPending(context.Background())
+	wg.Wait()
+	require.False(t, cd.committer.leading)
+	require.Equal(t, 0, len(cd.committer.pending))
+
+	offsets := make([]int64, 0, concurrency)
+	for _, shard := range shards {
+		offsets = append(offsets, shard.Offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	for i, off := range offsets {
+		require.Equal(t, int64(_chunkHeaderSize+i*_pagesize), off)
+	}
+	require.Equal(t, int64(_chunkHeaderSize+concurrency*_pagesize), cd.wOff)
+	checkGroupShards(t, cd, shards, datas)
+}
+
+func TestGroupCommit_IOType(t *testing.T) {
+	batch := []*commitRecord{{ioType: bnapi.InternalIO}}
+	require.Equal(t, bnapi.InternalIO, batchIOType(batch))
+	batch = append(batch, &commitRecord{ioType: bnapi.BackgroundIO}, &commitRecord{ioType: bnapi.CompactIO})
+	require.Equal(t, bnapi.BackgroundIO, batchIOType(batch))
+	batch = append(batch, &commitRecord{ioType: bnapi.NormalIO})
+	require.Equal(t, bnapi.NormalIO, batchIOType(batch))
+
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"GroupCommitIOType")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	cd := newGroupCommitData(t, testDir, core.DefaultGroupCommitBatchSize)
+	defer cd.Close()
+
+	// the batch is committed even if the leader was canceled
+	shards, datas := genGroupShards(4, 1024)
+	wg := queueWrites(t, cd, shards)
+	ctx, cancel := context.WithCancel(bnapi.Setiotype(context.Background(), bnapi.CompactIO))
+	cancel()
+	cd.commitPending(ctx)
+	wg.Wait()
+	checkGroupShards(t, cd, shards, datas)
+}
+
+func TestGroupCommit_HandOver(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"GroupCommitHandOver")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	// 2 shards at most in one commit
+	cd := newGroupCommitData(t, testDir, 2*_pagesize)
+	defer cd.Close()
+
+	shards, datas := genGroupShards(5, 1024)
+	wg := queueWrites(t, cd, shards)
+
+	cd.commitPending(context.Background())
+	wg.Wait()
+	require.False(t, cd.committer.leading)
+	require.Equal(t, int64(_chunkHeaderSize+5*_pagesize), cd.wOff)
+	checkGroupShards(t, cd, shards, datas)
+
+	// concurrent writes without blocking
+	shards, datas = genGroupShards(32, 4096)
+	wg = &sync.WaitGroup{}
+	wg.Add(len(shards))
+	for i := range shards {
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, cd.Write(context.Background(), shards[i]))
+		}(i)
+	}
+	wg.Wait()
+	require.False(t, cd.committer.leading)
+	checkGroupShards(t, cd, shards, datas)
+}
+
+func TestGroupCommit_LargeShard(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"GroupCommitLarge")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	cd := newGroupCommitData(t, testDir, core.DefaultGroupCommitBatchSize)
+	defer cd.Close()
+
+	ctx := context.Background()
+
+	// small shard is synced when written
+	shards, datas := genGroupShards(1, 1024)
+	require.NoError(t, cd.Write(ctx, shards[0]))
+	require.Equal(t, int64(0), cd.writeSeq)
+	require.NoError(t, cd.Flush())
+	require.Equal(t, int64(0), cd.syncedSeq)
+
+	// large shard is written alone, and synced by flush
+	data := bytes.Repeat([]byte("large"), 8<<10)
+	shard := core.NewShardWriter(2, 10, uint32(len(data)), bytes.NewReader(data))
+	require.NoError(t, cd.Write(ctx, shard))
+	require.Equal(t, int64(1), cd.writeSeq)
+	require.NoError(t, cd.Flush())
+	require.Equal(t, int64(1), cd.syncedSeq)
+
+	checkGroupShards(t, cd, append(shards, shard), append(datas, data))
+
+	// broken body
+	shard = core.NewShardWriter(3, 10, 1024, bytes.NewReader(data[:100]))
+	require.Error(t, cd.Write(ctx, shard))
+	require.False(t, cd.committer.leading)
+}
+
+func TestGroupCommit_ReuseExtents(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"GroupCommitExtents")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	kvdb, err := db.NewMetaHandler(testDir, db.MetaConfig{})
+	require.NoError(t, err)
+
+	cd := newGroupCommitData(t, testDir, core.DefaultGroupCommitBatchSize)
+	defer cd.Close()
+	require.NoError(t, cd.loadExtents(ctx, kvdb, bnapi.NewChunkId(0)))
+
+	shards, datas := genGroupShards(4, 1024)
+	for _, shard := range shards {
+		require.NoError(t, cd.Write(ctx, shard))
+	}
+	require.NoError(t, cd.Delete(ctx, shards[1]))
+	require.NoError(t, cd.Delete(ctx, shards[2]))
+	wOff := cd.wOff
+
+	// the batch is allocated from the merged hole
+	newShards, newDatas := genGroupShards(2, 1024)
+	wg := queueWrites(t, cd, newShards)
+	cd.commitPending(ctx)
+	wg.Wait()
+
+	require.Equal(t, wOff, cd.wOff)
+	require.Equal(t, int64(0), cd.extents.total)
+	offsets := []int64{newShards[0].Offset, newShards[1].Offset}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	require.Equal(t, []int64{shards[1].Offset, shards[2].Offset}, offsets)
+	checkGroupShards(t, cd, append([]*core.Shard{shards[0], shards[3]}, newShards...),
+		append([][]byte{datas[0], datas[3]}, newDatas...))
+}
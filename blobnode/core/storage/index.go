@@ -0,0 +1,128 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/cubefs/blobstore/blobnode/core"
+	bloberr "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+// shardIndex shard metas of a chunk in memory,
+// used by storage engines which do not save meta in kv db.
+type shardIndex struct {
+	lock   sync.RWMutex
+	shards map[proto.BlobID]core.ShardMeta
+	// sorted bids for scanning, rebuilt if dirty
+	bids  []proto.BlobID
+	dirty bool
+}
+
+func newShardIndex() *shardIndex {
+	return &shardIndex{shards: make(map[proto.BlobID]core.ShardMeta)}
+}
+
+func (idx *shardIndex) get(bid proto.BlobID) (sm core.ShardMeta, ok bool) {
+	idx.lock.RLock()
+	sm, ok = idx.shards[bid]
+	idx.lock.RUnlock()
+	return
+}
+
+func (idx *shardIndex) set(bid proto.BlobID, sm core.ShardMeta) {
+	idx.lock.Lock()
+	if _, ok := idx.shards[bid]; !ok {
+		// bids are increasing mostly
+		if n := len(idx.bids); n > 0 && idx.bids[n-1] > bid {
+			idx.dirty = true
+		}
+		idx.bids = append(idx.bids, bid)
+	}
+	idx.shards[bid] = sm
+	idx.lock.Unlock()
+}
+
+func (idx *shardIndex) del(bid proto.BlobID) {
+	idx.lock.Lock()
+	if _, ok := idx.shards[bid]; ok {
+		delete(idx.shards, bid)
+		idx.dirty = true
+	}
+	idx.lock.Unlock()
+}
+
+func (idx *shardIndex) len() int {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return len(idx.shards)
+}
+
+func (idx *shardIndex) reset() {
+	idx.lock.Lock()
+	idx.shards = make(map[proto.BlobID]core.ShardMeta)
+	idx.bids = nil
+	idx.dirty = false
+	idx.lock.Unlock()
+}
+
+func (idx *shardIndex) sortedBids() []proto.BlobID {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+
+	if idx.dirty {
+		bids := make([]proto.BlobID, 0, len(idx.shards))
+		for bid := range idx.shards {
+			bids = append(bids, bid)
+		}
+		sort.Slice(bids, func(i, j int) bool { return bids[i] < bids[j] })
+		idx.bids = bids
+		idx.dirty = false
+	}
+	return idx.bids
+}
+
+// scan shard metas same as metafile
+// from - to ( startBid, ... ]
+func (idx *shardIndex) scan(ctx context.Context, startBid proto.BlobID, limit int,
+	fn func(bid proto.BlobID, sm *core.ShardMeta) error) (err error,
+) {
+	if limit == 0 {
+		return bloberr.ErrInvalidParam
+	}
+
+	bids := idx.sortedBids()
+	i := sort.Search(len(bids), func(i int) bool { return bids[i] > startBid })
+	for ; i < len(bids) && limit > 0; i++ {
+		// may be deleted after sorting
+		sm, ok := idx.get(bids[i])
+		if !ok {
+			continue
+		}
+		if err = fn(bids[i], &sm); err != nil {
+			return err
+		}
+		limit--
+	}
+
+	if limit > 0 {
+		return core.ErrChunkScanEOF
+	}
+
+	return nil
+}
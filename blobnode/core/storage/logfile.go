@@ -0,0 +1,415 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"syscall"
+	"time"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base/qos"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
+	bloberr "github.com/cubefs/blobstore/common/errors"
+	rdb "github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// Log file has the same header (4k) with chunk data but a different magic,
+// shards and metas are appended as records, there is no kv db.
+// Log file format:
+//  --------------
+// |    header    |
+//  --------------
+// |  put record  |   ---- aligned with 4k, followed by shard
+// |    shard     |
+// | meta record  |   ---- shard meta is updated
+// | meta record  |
+// |  del record  |   ---- shard meta is deleted
+// |  put record  |
+// |    shard     |
+// |    ....      |
+//
+// record format (40 Bytes):
+// ----------------------
+// | crc(record)(uint32)|
+// |  magic   (uint32)  |
+// |  type    (uint8)   |
+// |  flag    (uint8)   |
+// | padding (2 bytes)  |
+// |  bid     (uint64)  |
+// |  offset  (int64)   |
+// |  size    (uint32)  |
+// | crc(shard)(uint32) |
+// | padding (4 bytes)  |
+// ----------------------
+//
+// Shard metas are rebuilt by replaying records when opened,
+// shards without meta record are ignored.
+
+const (
+	_logRecordSize = 40
+
+	_logRecordCrcOffset   = 0
+	_logRecordMagicOffset = 4
+	_logRecordTypeOffset  = 8
+	_logRecordFlagOffset  = 9
+	_logRecordBidOffset   = 12
+	_logRecordOffOffset   = 20
+	_logRecordSizeOffset  = 28
+	_logRecordCrcOfShard  = 32
+)
+
+type logRecordType uint8
+
+const (
+	logRecordPut logRecordType = iota + 1
+	logRecordMeta
+	logRecordDelete
+)
+
+var (
+	logChunkHeaderMagic = [_chunkMagicSize]byte{0x20, 0x22, 0x10, 0x16}
+	logRecordMagic      = [4]byte{0x4c, 0x4f, 0x47, 0x52}
+)
+
+var (
+	ErrLogRecordMagic = errors.New("logfile: record magic not match")
+	ErrLogRecordCrc   = errors.New("logfile: record crc not match")
+	ErrLogRecordType  = errors.New("logfile: record type invalid")
+)
+
+type logRecord struct {
+	typ    logRecordType
+	flag   bnapi.ShardStatus
+	bid    proto.BlobID
+	offset int64
+	size   uint32
+	crc    uint32
+}
+
+func (rec *logRecord) marshal(buf []byte) {
+	copy(buf[_logRecordMagicOffset:], logRecordMagic[:])
+	buf[_logRecordTypeOffset] = uint8(rec.typ)
+	buf[_logRecordFlagOffset] = uint8(rec.flag)
+	binary.BigEndian.PutUint64(buf[_logRecordBidOffset:], uint64(rec.bid))
+	binary.BigEndian.PutUint64(buf[_logRecordOffOffset:], uint64(rec.offset))
+	binary.BigEndian.PutUint32(buf[_logRecordSizeOffset:], rec.size)
+	binary.BigEndian.PutUint32(buf[_logRecordCrcOfShard:], rec.crc)
+	binary.BigEndian.PutUint32(buf[_logRecordCrcOffset:], crc32.ChecksumIEEE(buf[_logRecordMagicOffset:]))
+}
+
+func (rec *logRecord) unmarshal(buf []byte) error {
+	if !bytes.Equal(buf[_logRecordMagicOffset:_logRecordTypeOffset], logRecordMagic[:]) {
+		return ErrLogRecordMagic
+	}
+	if crc32.ChecksumIEEE(buf[_logRecordMagicOffset:]) != binary.BigEndian.Uint32(buf[_logRecordCrcOffset:]) {
+		return ErrLogRecordCrc
+	}
+	rec.typ = logRecordType(buf[_logRecordTypeOffset])
+	if rec.typ < logRecordPut || rec.typ > logRecordDelete {
+		return ErrLogRecordType
+	}
+	rec.flag = bnapi.ShardStatus(buf[_logRecordFlagOffset])
+	rec.bid = proto.BlobID(binary.BigEndian.Uint64(buf[_logRecordBidOffset:]))
+	rec.offset = int64(binary.BigEndian.Uint64(buf[_logRecordOffOffset:]))
+	rec.size = binary.BigEndian.Uint32(buf[_logRecordSizeOffset:])
+	rec.crc = binary.BigEndian.Uint32(buf[_logRecordCrcOfShard:])
+	return nil
+}
+
+// next returns offset of the next record
+func (rec *logRecord) next(pos int64) int64 {
+	if rec.typ == logRecordPut {
+		return core.AlignSize(pos+_logRecordSize+core.Alignphysize(int64(rec.size)), _pagesize)
+	}
+	return pos + _logRecordSize
+}
+
+type logfile struct {
+	*datafile
+	id    bnapi.ChunkId
+	index *shardIndex
+}
+
+type logmeta struct {
+	lf *logfile
+}
+
+func NewLogFile(ctx context.Context, vm core.VuidMeta, file string, conf *core.Config, createIfMiss bool, ioQos qos.Qos) (
+	lf *logfile, err error) {
+	cd, err := openDataFile(ctx, vm, file, conf, createIfMiss, ioQos)
+	if err != nil {
+		return nil, err
+	}
+
+	lf = &logfile{datafile: cd, id: vm.ChunkId, index: newShardIndex()}
+	if err = lf.init(ctx, &vm); err != nil {
+		err = fmt.Errorf("log: %s init() error(%v)", file, err)
+		lf.Close()
+		return nil, err
+	}
+
+	return lf, nil
+}
+
+func (lf *logfile) init(ctx context.Context, meta *core.VuidMeta) (err error) {
+	var sysstat syscall.Stat_t
+
+	if sysstat, err = lf.ef.SysStat(); err != nil {
+		return
+	}
+
+	fileSize := sysstat.Size
+	if fileSize == 0 {
+		// first time. auto format
+		lf.initHeader(meta)
+		lf.header.magic = logChunkHeaderMagic
+		if err = lf.writeMeta(); err != nil {
+			return
+		}
+		lf.wOff = _chunkHeaderSize
+		return
+	}
+
+	buf := make([]byte, _chunkHeaderSize)
+	if _, err = lf.ef.ReadAt(buf, 0); err != nil {
+		return
+	}
+	hdr := ChunkHeader{}
+	if err = hdr.unmarshal(buf, logChunkHeaderMagic); err != nil {
+		return
+	}
+	lf.header = hdr
+
+	if err = lf.replay(ctx, fileSize); err != nil {
+		return
+	}
+	lf.wOff = core.AlignSize(fileSize, _pagesize)
+	return
+}
+
+// replay rebuilds shard metas from records
+func (lf *logfile) replay(ctx context.Context, fileSize int64) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	skipped := 0
+	buf := make([]byte, _logRecordSize)
+	for pos := int64(_chunkHeaderSize); pos+_logRecordSize <= fileSize; {
+		if _, err = lf.ef.ReadAt(buf, pos); err != nil {
+			return
+		}
+
+		var rec logRecord
+		if err = rec.unmarshal(buf); err != nil {
+			// torn or discarded record, the next record starts at page boundary
+			skipped++
+			pos = core.AlignSize(pos+1, _pagesize)
+			continue
+		}
+
+		switch rec.typ {
+		case logRecordMeta:
+			lf.index.set(rec.bid, core.ShardMeta{
+				Version: _shardVer[0],
+				Flag:    rec.flag,
+				Offset:  rec.offset,
+				Size:    rec.size,
+				Crc:     rec.crc,
+			})
+		case logRecordDelete:
+			lf.index.del(rec.bid)
+		}
+		pos = rec.next(pos)
+	}
+
+	span.Infof("replay log file:%s shards:%d skipped:%d", lf.File, lf.index.len(), skipped)
+	return nil
+}
+
+// appendRecord appends a meta record, then applies it to shard metas
+func (lf *logfile) appendRecord(ctx context.Context, rec logRecord) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	buf := make([]byte, _logRecordSize)
+	rec.marshal(buf)
+
+	// records are appended and applied in order
+	lf.wLock.Lock()
+	defer lf.wLock.Unlock()
+
+	start := time.Now()
+	_, err = lf.qosWriterAt(ctx, lf.ef).WriteAt(buf, lf.wOff)
+	span.AppendTrackLog("rec.w", start, err)
+	if err != nil {
+		return err
+	}
+	lf.wOff += _logRecordSize
+	lf.written()
+
+	if rec.typ == logRecordDelete {
+		lf.index.del(rec.bid)
+		return nil
+	}
+	lf.index.set(rec.bid, core.ShardMeta{
+		Version: _shardVer[0],
+		Flag:    rec.flag,
+		Offset:  rec.offset,
+		Size:    rec.size,
+		Crc:     rec.crc,
+	})
+	return nil
+}
+
+func (lf *logfile) allocSpace(fsize int64) (pos int64, err error) {
+	lf.wLock.Lock()
+	defer lf.wLock.Unlock()
+
+	// meta records may be not aligned
+	pos = core.AlignSize(lf.wOff, _pagesize)
+	lf.wOff = core.AlignSize(pos+fsize, _pagesize)
+
+	return pos, nil
+}
+
+func (lf *logfile) Write(ctx context.Context, shard *core.Shard) error {
+	span := trace.SpanFromContextSafe(ctx)
+
+	phySize := _logRecordSize + core.Alignphysize(int64(shard.Size))
+	pos, err := lf.allocSpace(phySize)
+	if err != nil {
+		return err
+	}
+
+	rec := logRecord{
+		typ:    logRecordPut,
+		flag:   shard.Flag,
+		bid:    shard.Bid,
+		offset: pos + _logRecordSize,
+		size:   shard.Size,
+	}
+	buf := make([]byte, _logRecordSize)
+	rec.marshal(buf)
+
+	start := time.Now()
+	_, err = lf.qosWriterAt(ctx, lf.ef).WriteAt(buf, pos)
+	span.AppendTrackLog("rec.w", start, err)
+	if err != nil {
+		return err
+	}
+
+	if err = lf.writeShard(ctx, shard, rec.offset); err != nil {
+		return err
+	}
+	lf.written()
+
+	return nil
+}
+
+func (lf *logfile) Delete(ctx context.Context, shard *core.Shard) (err error) {
+	var ns core.Shard
+
+	pos := shard.Offset - _logRecordSize
+	if pos < _chunkHeaderSize {
+		return bloberr.ErrShardInvalidOffset
+	}
+	if pos%_pagesize != 0 {
+		return ErrShardOffNotAlignment
+	}
+
+	// read shard header
+	buf := make([]byte, core.GetShardHeaderSize())
+	_, err = lf.ef.ReadAt(buf, shard.Offset)
+	if err != nil {
+		return err
+	}
+
+	// verify
+	err = ns.ParseHeader(buf)
+	if err != nil {
+		return err
+	}
+	if shard.Bid != ns.Bid || shard.Vuid != ns.Vuid || shard.Size != ns.Size {
+		return ErrShardHeaderNotMatch
+	}
+
+	// punch hole, keep the first page with put record for replaying
+	end := core.AlignSize(shard.Offset+core.Alignphysize(int64(shard.Size)), _pagesize)
+	if discardSize := end - pos - _pagesize; discardSize > 0 {
+		err = lf.ef.Discard(pos+_pagesize, discardSize)
+	}
+
+	return err
+}
+
+func (lf *logfile) MetaHandler() core.MetaHandler {
+	return &logmeta{lf: lf}
+}
+
+func (lm *logmeta) ID() bnapi.ChunkId {
+	return lm.lf.id
+}
+
+func (lm *logmeta) InnerDB() db.MetaHandler {
+	return nil
+}
+
+func (lm *logmeta) Write(ctx context.Context, bid proto.BlobID, value core.ShardMeta) (err error) {
+	return lm.lf.appendRecord(ctx, logRecord{
+		typ:    logRecordMeta,
+		flag:   value.Flag,
+		bid:    bid,
+		offset: value.Offset,
+		size:   value.Size,
+		crc:    value.Crc,
+	})
+}
+
+func (lm *logmeta) Read(ctx context.Context, bid proto.BlobID) (value core.ShardMeta, err error) {
+	value, ok := lm.lf.index.get(bid)
+	if !ok {
+		return value, rdb.ErrNotFound
+	}
+	return value, nil
+}
+
+func (lm *logmeta) Delete(ctx context.Context, bid proto.BlobID) (err error) {
+	if _, ok := lm.lf.index.get(bid); !ok {
+		return nil
+	}
+	return lm.lf.appendRecord(ctx, logRecord{typ: logRecordDelete, bid: bid})
+}
+
+func (lm *logmeta) Scan(ctx context.Context, startBid proto.BlobID, limit int,
+	fn func(bid proto.BlobID, sm *core.ShardMeta) error) (err error) {
+	return lm.lf.index.scan(ctx, startBid, limit, fn)
+}
+
+// Destroy shard metas are removed with log file
+func (lm *logmeta) Destroy(ctx context.Context) (err error) {
+	lm.lf.index.reset()
+	return nil
+}
+
+// Close log file is closed as data handler
+func (lm *logmeta) Close() {
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base/qos"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func TestLogRecord(t *testing.T) {
+	rec := logRecord{
+		typ:    logRecordMeta,
+		flag:   bnapi.ShardStatusMarkDelete,
+		bid:    1024,
+		offset: 8192,
+		size:   100,
+		crc:    0xabcd,
+	}
+	buf := make([]byte, _logRecordSize)
+	rec.marshal(buf)
+
+	var dec logRecord
+	require.NoError(t, dec.unmarshal(buf))
+	require.Equal(t, rec, dec)
+	require.Equal(t, int64(100+_logRecordSize), dec.next(100))
+
+	rec.typ = logRecordPut
+	require.Equal(t, int64(2*_pagesize), rec.next(_pagesize))
+
+	buf[_logRecordBidOffset]++
+	require.ErrorIs(t, dec.unmarshal(buf), ErrLogRecordCrc)
+	buf[_logRecordMagicOffset]++
+	require.ErrorIs(t, dec.unmarshal(buf), ErrLogRecordMagic)
+
+	rec.typ = 0
+	rec.marshal(buf)
+	require.ErrorIs(t, dec.unmarshal(buf), ErrLogRecordType)
+}
+
+func TestLogFileReplay(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"LogFileReplay")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	conf := &core.Config{}
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+	vuid := proto.Vuid(1024)
+	vm := core.VuidMeta{Vuid: vuid, ChunkId: bnapi.NewChunkId(vuid), Ctime: 1024}
+	file := filepath.Join(testDir, vm.ChunkId.String())
+
+	// not a log file
+	cd, err := NewChunkData(ctx, vm, file+"data", conf, true, ioQos)
+	require.NoError(t, err)
+	cd.Close()
+	_, err = NewLogFile(ctx, vm, file+"data", conf, false, ioQos)
+	require.Error(t, err)
+
+	lf, err := NewLogFile(ctx, vm, file, conf, true, ioQos)
+	require.NoError(t, err)
+	stg := NewStorage(lf.MetaHandler(), lf)
+
+	datas := make(map[proto.BlobID][]byte)
+	for bid := proto.BlobID(1); bid <= 5; bid++ {
+		data := make([]byte, int(bid)*(core.CrcBlockUnitSize/2))
+		rand.Read(data)
+		require.NoError(t, stg.Write(ctx, core.NewShardWriter(bid, vuid, uint32(len(data)), bytes.NewReader(data))))
+		datas[bid] = data
+	}
+	require.NoError(t, stg.MarkDelete(ctx, 2))
+	require.NoError(t, stg.MarkDelete(ctx, 3))
+	_, err = stg.Delete(ctx, 3)
+	require.NoError(t, err)
+	delete(datas, 3)
+
+	// shard without meta record is ignored
+	data := make([]byte, 10)
+	require.NoError(t, lf.Write(ctx, core.NewShardWriter(6, vuid, uint32(len(data)), bytes.NewReader(data))))
+	// torn record at the end
+	lf.wLock.Lock()
+	_, err = lf.ef.WriteAt(logRecordMagic[:], lf.wOff+_logRecordMagicOffset)
+	lf.wLock.Unlock()
+	require.NoError(t, err)
+	stat, err := lf.Stat()
+	require.NoError(t, err)
+	metas := make(map[proto.BlobID]core.ShardMeta)
+	require.Equal(t, core.ErrChunkScanEOF, stg.ScanMeta(ctx, proto.InValidBlobID, 10,
+		func(bid proto.BlobID, sm *core.ShardMeta) error {
+			metas[bid] = *sm
+			return nil
+		}))
+	require.Equal(t, len(datas), len(metas))
+	stg.Close(ctx)
+
+	// replay
+	lf, err = NewLogFile(ctx, vm, file, conf, false, ioQos)
+	require.NoError(t, err)
+	stg = NewStorage(lf.MetaHandler(), lf)
+	defer stg.Close(ctx)
+	require.Equal(t, len(metas), lf.index.len())
+	for bid, meta := range metas {
+		sm, err := stg.ReadShardMeta(ctx, bid)
+		require.NoError(t, err)
+		require.Equal(t, meta, *sm)
+	}
+	replayStat, err := lf.Stat()
+	require.NoError(t, err)
+	require.Equal(t, stat.CreateTime, replayStat.CreateTime)
+
+	// write after replaying
+	data = make([]byte, 1<<10)
+	rand.Read(data)
+	shard := core.NewShardWriter(7, vuid, uint32(len(data)), bytes.NewReader(data))
+	require.NoError(t, stg.Write(ctx, shard))
+	require.True(t, shard.Offset > stat.FileSize)
+	rc, err := stg.NewRangeReader(ctx, shard, 0, int64(len(data)))
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+}
+
+func TestShardIndex(t *testing.T) {
+	ctx := context.Background()
+	idx := newShardIndex()
+	for _, bid := range []proto.BlobID{5, 1, 3, 2, 4} {
+		idx.set(bid, core.ShardMeta{Size: uint32(bid)})
+	}
+	idx.set(3, core.ShardMeta{Size: 30})
+	idx.del(4)
+	idx.del(4)
+
+	var bids []proto.BlobID
+	err := idx.scan(ctx, 1, 10, func(bid proto.BlobID, sm *core.ShardMeta) error {
+		bids = append(bids, bid)
+		return nil
+	})
+	require.Equal(t, core.ErrChunkScanEOF, err)
+	require.Equal(t, []proto.BlobID{2, 3, 5}, bids)
+
+	sm, ok := idx.get(3)
+	require.True(t, ok)
+	require.Equal(t, uint32(30), sm.Size)
+	require.Equal(t, 4, idx.len())
+
+	idx.reset()
+	require.Equal(t, 0, idx.len())
+}
+
+func TestLogFileFlush(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"LogFileFlush")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	ctx := context.Background()
+	conf := &core.Config{RuntimeConfig: core.RuntimeConfig{GroupCommit: true}}
+	ioQos, _ := qos.NewQosManager(qos.Config{})
+	vuid := proto.Vuid(1024)
+	vm := core.VuidMeta{Vuid: vuid, ChunkId: bnapi.NewChunkId(vuid), Ctime: 1024}
+	lf, err := NewLogFile(ctx, vm, filepath.Join(testDir, vm.ChunkId.String()), conf, true, ioQos)
+	require.NoError(t, err)
+	stg := NewStorage(lf.MetaHandler(), lf)
+	defer stg.Close(ctx)
+
+	// shards and meta records are synced by flush
+	data := []byte("test data")
+	require.NoError(t, stg.Write(ctx, core.NewShardWriter(1, vuid, uint32(len(data)), bytes.NewReader(data))))
+	seq := lf.writeSeq
+	require.True(t, seq > lf.syncedSeq)
+	require.NoError(t, lf.Flush())
+	require.Equal(t, seq, lf.syncedSeq)
+
+	require.NoError(t, stg.MarkDelete(ctx, 1))
+	require.True(t, lf.writeSeq > lf.syncedSeq)
+	require.NoError(t, lf.Flush())
+	require.Equal(t, lf.writeSeq, lf.syncedSeq)
+}
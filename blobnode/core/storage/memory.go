@@ -0,0 +1,198 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
+	bloberr "github.com/cubefs/blobstore/common/errors"
+	rdb "github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+/*
+ * memory storage engine, shards are lost after closed
+ */
+
+type memmeta struct {
+	id    bnapi.ChunkId
+	index *shardIndex
+}
+
+func newMemMeta(vm core.VuidMeta) *memmeta {
+	return &memmeta{id: vm.ChunkId, index: newShardIndex()}
+}
+
+func (mm *memmeta) ID() bnapi.ChunkId {
+	return mm.id
+}
+
+func (mm *memmeta) InnerDB() db.MetaHandler {
+	return nil
+}
+
+func (mm *memmeta) Write(ctx context.Context, bid proto.BlobID, value core.ShardMeta) (err error) {
+	mm.index.set(bid, value)
+	return nil
+}
+
+func (mm *memmeta) Read(ctx context.Context, bid proto.BlobID) (value core.ShardMeta, err error) {
+	value, ok := mm.index.get(bid)
+	if !ok {
+		return value, rdb.ErrNotFound
+	}
+	return value, nil
+}
+
+func (mm *memmeta) Delete(ctx context.Context, bid proto.BlobID) (err error) {
+	mm.index.del(bid)
+	return nil
+}
+
+func (mm *memmeta) Scan(ctx context.Context, startBid proto.BlobID, limit int,
+	fn func(bid proto.BlobID, sm *core.ShardMeta) error) (err error) {
+	return mm.index.scan(ctx, startBid, limit, fn)
+}
+
+func (mm *memmeta) Destroy(ctx context.Context) (err error) {
+	mm.index.reset()
+	return nil
+}
+
+func (mm *memmeta) Close() {
+}
+
+type memdata struct {
+	lock   sync.RWMutex
+	wOff   int64
+	used   int64
+	shards map[int64][]byte // offset -> shard data
+
+	parent bnapi.ChunkId
+	ctime  int64
+}
+
+func newMemData(vm core.VuidMeta) *memdata {
+	return &memdata{
+		wOff:   _chunkHeaderSize,
+		shards: make(map[int64][]byte),
+		parent: vm.ParentChunk,
+		ctime:  vm.Ctime,
+	}
+}
+
+func (md *memdata) Write(ctx context.Context, shard *core.Shard) error {
+	buf := make([]byte, shard.Size)
+	if _, err := io.ReadFull(shard.Body, buf); err != nil {
+		return bloberr.ErrReaderError
+	}
+	phySize := core.AlignSize(core.Alignphysize(int64(shard.Size)), _pagesize)
+
+	md.lock.Lock()
+	shard.Offset = md.wOff
+	md.wOff += phySize
+	md.used += phySize
+	md.shards[shard.Offset] = buf
+	md.lock.Unlock()
+
+	shard.Crc = crc32.ChecksumIEEE(buf)
+	return nil
+}
+
+func (md *memdata) Read(ctx context.Context, shard *core.Shard, from, to uint32) (r io.Reader, err error) {
+	if shard == nil {
+		return nil, bloberr.ErrInvalidParam
+	}
+	if to > shard.Size || from > to {
+		return nil, bloberr.ErrInvalidParam
+	}
+
+	md.lock.RLock()
+	buf, ok := md.shards[shard.Offset]
+	md.lock.RUnlock()
+	if !ok || len(buf) != int(shard.Size) {
+		return nil, bloberr.ErrShardInvalidOffset
+	}
+
+	return bytes.NewReader(buf[from:to]), nil
+}
+
+func (md *memdata) Stat() (stat *core.StorageStat, err error) {
+	md.lock.RLock()
+	defer md.lock.RUnlock()
+
+	return &core.StorageStat{
+		FileSize:   md.wOff,
+		PhySize:    md.used,
+		ParentID:   md.parent,
+		CreateTime: md.ctime,
+	}, nil
+}
+
+func (md *memdata) Flush() (err error) {
+	return nil
+}
+
+func (md *memdata) Verify(ctx context.Context, shard *core.Shard) (err error) {
+	md.lock.RLock()
+	buf, ok := md.shards[shard.Offset]
+	md.lock.RUnlock()
+	if !ok {
+		return bloberr.ErrShardInvalidOffset
+	}
+	if len(buf) != int(shard.Size) {
+		return fmt.Errorf("%w: %v", core.ErrShardCorrupted, ErrShardHeaderNotMatch)
+	}
+	if crc32.ChecksumIEEE(buf) != shard.Crc {
+		return fmt.Errorf("%w: %v", core.ErrShardCorrupted, core.ErrShardDataCrc)
+	}
+	return nil
+}
+
+func (md *memdata) Delete(ctx context.Context, shard *core.Shard) (err error) {
+	md.lock.Lock()
+	defer md.lock.Unlock()
+
+	buf, ok := md.shards[shard.Offset]
+	if !ok {
+		return bloberr.ErrShardInvalidOffset
+	}
+	if len(buf) != int(shard.Size) {
+		return ErrShardHeaderNotMatch
+	}
+
+	delete(md.shards, shard.Offset)
+	md.used -= core.AlignSize(core.Alignphysize(int64(shard.Size)), _pagesize)
+	return nil
+}
+
+func (md *memdata) Destroy(ctx context.Context) (err error) {
+	md.lock.Lock()
+	md.shards = make(map[int64][]byte)
+	md.used = 0
+	md.lock.Unlock()
+	return nil
+}
+
+func (md *memdata) Close() {
+}
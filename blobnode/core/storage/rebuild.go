@@ -0,0 +1,282 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
+	"github.com/cubefs/blobstore/common/crc32block"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+/*
+ * shards of datafile are self-describing, the shard metas can be rebuilt
+ * by scanning the data file sequentially:
+ *   valid header + footer at page aligned offset is a shard,
+ *   otherwise the page is skipped (punched hole or broken data).
+ * mark-deleted flags are not in data file, rebuilt shards are normal.
+ *
+ * chunk metas can be exported and imported as a portable file of json lines:
+ *   {"version":1,"chunk":${vuid_meta}}
+ *   {"bid":${bid},"meta":${shard_meta}}
+ *   ...
+ */
+
+const chunkMetaExportVersion = 1
+
+var (
+	ErrChunkMetaExportVersion = errors.New("chunk meta export version not supported")
+	ErrChunkMetaExportChunk   = errors.New("chunk meta export not match chunk")
+)
+
+type chunkMetaExportHeader struct {
+	Version int           `json:"version"`
+	Chunk   core.VuidMeta `json:"chunk"`
+}
+
+type chunkMetaExportRecord struct {
+	Bid  proto.BlobID   `json:"bid"`
+	Meta core.ShardMeta `json:"meta"`
+}
+
+// ReadChunkFileMeta reads the header of chunk file,
+// returns the meta of chunk with version, parent, ctime and storage engine.
+func ReadChunkFileMeta(file string) (vm core.VuidMeta, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return vm, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, _chunkHeaderSize)
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		return vm, err
+	}
+
+	hdr := ChunkHeader{}
+	vm.StorageEngine = EngineDataFile
+	if err = hdr.unmarshal(buf, chunkHeaderMagic); err == ErrChunkDataMagic {
+		vm.StorageEngine = EngineLogFile
+		err = hdr.unmarshal(buf, logChunkHeaderMagic)
+	}
+	if err != nil {
+		return vm, err
+	}
+
+	vm.Version = hdr.version
+	vm.ParentChunk = hdr.parentChunk
+	vm.Ctime = hdr.createTime
+	return vm, nil
+}
+
+// ScanDataFile scans the shards of datafile in order of offset,
+// Offset, Size and Crc of shard are filled from header and footer.
+// The body is checked with crc blocks if verify, broken shard is skipped.
+func ScanDataFile(ctx context.Context, file string, verify bool, fn func(shard *core.Shard) error) (err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+
+	hdr := ChunkHeader{}
+	buf := make([]byte, _chunkHeaderSize)
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	if err = hdr.Unmarshal(buf); err != nil {
+		return err
+	}
+
+	headerBuf := make([]byte, core.GetShardHeaderSize())
+	footerBuf := make([]byte, core.GetShardFooterSize())
+	block := make([]byte, core.CrcBlockUnitSize)
+
+	for pos := int64(_chunkHeaderSize); pos+core.GetShardHeaderSize() <= size; {
+		shard := &core.Shard{Offset: pos}
+
+		if _, err = f.ReadAt(headerBuf, pos); err != nil {
+			return err
+		}
+		if shard.ParseHeader(headerBuf) != nil {
+			pos += _pagesize
+			continue
+		}
+
+		phySize := core.Alignphysize(int64(shard.Size))
+		if pos+phySize > size {
+			span.Warnf("shard:%d at %d is truncated, file size:%d", shard.Bid, pos, size)
+			pos += _pagesize
+			continue
+		}
+		if _, err = f.ReadAt(footerBuf, pos+phySize-core.GetShardFooterSize()); err != nil {
+			return err
+		}
+		if err = shard.ParseFooter(footerBuf); err != nil {
+			span.Warnf("shard:%d at %d has broken footer, err:%v", shard.Bid, pos, err)
+			pos += _pagesize
+			continue
+		}
+
+		if verify {
+			if err = verifyShardBody(f, shard, block); err != nil {
+				span.Warnf("shard:%d at %d is broken, err:%v", shard.Bid, pos, err)
+				pos += _pagesize
+				continue
+			}
+		}
+
+		if err = fn(shard); err != nil {
+			return err
+		}
+		pos += core.AlignSize(phySize, _pagesize)
+	}
+
+	return nil
+}
+
+func verifyShardBody(f io.ReaderAt, shard *core.Shard, block []byte) error {
+	pos := shard.Offset + core.GetShardHeaderSize()
+	decoder, err := crc32block.NewDecoderWithBlock(f, pos, int64(shard.Size), block, bufsize)
+	if err != nil {
+		return err
+	}
+	r, err := decoder.Reader(0, int64(shard.Size))
+	if err != nil {
+		return err
+	}
+	crc := crc32.NewIEEE()
+	if _, err = io.Copy(crc, r); err != nil {
+		return err
+	}
+	if crc.Sum32() != shard.Crc {
+		return core.ErrShardDataCrc
+	}
+	return nil
+}
+
+// RebuildChunkMeta writes the shard metas scanned from data file into db,
+// shards of other vuids are ignored, the later one wins if bid is duplicated.
+// Only datafile chunks need it, logfile chunks keep metas in the file.
+func RebuildChunkMeta(ctx context.Context, db db.MetaHandler, vm core.VuidMeta, file string, verify bool) (
+	n int, err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if vm.StorageEngine != "" && vm.StorageEngine != EngineDataFile {
+		span.Infof("chunk:%s of engine:%s has no meta in db", vm.ChunkId, vm.StorageEngine)
+		return 0, nil
+	}
+
+	cm, err := NewChunkMeta(ctx, vm, db)
+	if err != nil {
+		return 0, err
+	}
+
+	err = ScanDataFile(ctx, file, verify, func(shard *core.Shard) error {
+		if shard.Vuid != vm.Vuid {
+			span.Warnf("shard:%d at %d of vuid:%d not belongs to chunk:%s", shard.Bid, shard.Offset, shard.Vuid, vm.ChunkId)
+			return nil
+		}
+		n++
+		return cm.Write(ctx, shard.Bid, core.ShardMeta{
+			Version: _shardVer[0],
+			Flag:    bnapi.ShardStatusNormal,
+			Offset:  shard.Offset,
+			Size:    shard.Size,
+			Crc:     shard.Crc,
+		})
+	})
+	return n, err
+}
+
+// ExportChunkMeta writes the chunk meta and its shard metas into w
+func ExportChunkMeta(ctx context.Context, db db.MetaHandler, vm core.VuidMeta, w io.Writer) (n int, err error) {
+	cm, err := NewChunkMeta(ctx, vm, db)
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	if err = enc.Encode(chunkMetaExportHeader{Version: chunkMetaExportVersion, Chunk: vm}); err != nil {
+		return 0, err
+	}
+
+	err = cm.Scan(ctx, proto.InValidBlobID, math.MaxInt32, func(bid proto.BlobID, sm *core.ShardMeta) error {
+		n++
+		return enc.Encode(chunkMetaExportRecord{Bid: bid, Meta: *sm})
+	})
+	if err != nil && err != core.ErrChunkScanEOF {
+		return n, err
+	}
+
+	return n, bw.Flush()
+}
+
+// ImportChunkMeta writes the shard metas exported of the same chunk into db,
+// returns the chunk meta in file.
+func ImportChunkMeta(ctx context.Context, db db.MetaHandler, vm core.VuidMeta, r io.Reader) (
+	exported core.VuidMeta, n int, err error) {
+	cm, err := NewChunkMeta(ctx, vm, db)
+	if err != nil {
+		return exported, 0, err
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+	hdr := chunkMetaExportHeader{}
+	if err = dec.Decode(&hdr); err != nil {
+		return exported, 0, err
+	}
+	if hdr.Version != chunkMetaExportVersion {
+		return exported, 0, ErrChunkMetaExportVersion
+	}
+	if hdr.Chunk.ChunkId != vm.ChunkId {
+		return exported, 0, fmt.Errorf("%w: %s", ErrChunkMetaExportChunk, hdr.Chunk.ChunkId)
+	}
+
+	for {
+		rec := chunkMetaExportRecord{}
+		if err = dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				return hdr.Chunk, n, nil
+			}
+			return hdr.Chunk, n, err
+		}
+		if err = cm.Write(ctx, rec.Bid, rec.Meta); err != nil {
+			return hdr.Chunk, n, err
+		}
+		n++
+	}
+}
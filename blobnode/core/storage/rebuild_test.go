@@ -0,0 +1,127 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/db"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func readChunkMetas(t *testing.T, kvdb db.MetaHandler, vm core.VuidMeta) map[proto.BlobID]core.ShardMeta {
+	cm, err := NewChunkMeta(context.Background(), vm, kvdb)
+	require.NoError(t, err)
+	metas := make(map[proto.BlobID]core.ShardMeta)
+	err = cm.Scan(context.Background(), proto.InValidBlobID, 100, func(bid proto.BlobID, sm *core.ShardMeta) error {
+		metas[bid] = *sm
+		return nil
+	})
+	require.ErrorIs(t, err, core.ErrChunkScanEOF)
+	return metas
+}
+
+func TestRebuildChunkMeta(t *testing.T) {
+	s, clean := newEngineSuite(t, EngineDataFile)
+	defer clean()
+
+	ctx := context.Background()
+	stg := s.newStorage(bnapi.ChunkId{})
+	defer stg.Close(ctx)
+	sizes := []int{1, 4 << 10, 100 << 10, 10, 1 << 10}
+	for i, size := range sizes {
+		s.write(stg, proto.BlobID(i+1), size)
+	}
+	require.NoError(t, stg.MarkDelete(ctx, 2))
+	_, err := stg.Delete(ctx, 2)
+	require.NoError(t, err)
+	require.NoError(t, stg.MarkDelete(ctx, 3))
+
+	vm := core.VuidMeta{Vuid: s.vuid, DiskID: 1, ChunkId: stg.ID()}
+	file := filepath.Join(s.dataPath, vm.ChunkId.String())
+	fileMeta, err := ReadChunkFileMeta(file)
+	require.NoError(t, err)
+	require.Equal(t, EngineDataFile, fileMeta.StorageEngine)
+	require.Equal(t, int64(1024), fileMeta.Ctime)
+
+	origin := readChunkMetas(t, s.opt.DB, vm)
+	require.Equal(t, 4, len(origin))
+	exported := bytes.NewBuffer(nil)
+	n, err := ExportChunkMeta(ctx, s.opt.DB, vm, exported)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	newDB := func() db.MetaHandler {
+		dir, err := ioutil.TempDir(os.TempDir(), defaultDiskTestDir+"RebuildMeta")
+		require.NoError(t, err)
+		kvdb, err := db.NewMetaHandler(dir, db.MetaConfig{})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			kvdb.Close(ctx)
+			os.RemoveAll(dir)
+		})
+		return kvdb
+	}
+
+	// rebuild from data file, mark-deleted flag is lost
+	kvdb := newDB()
+	n, err = RebuildChunkMeta(ctx, kvdb, vm, file, true)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	rebuilt := readChunkMetas(t, kvdb, vm)
+	require.Equal(t, bnapi.ShardStatusNormal, rebuilt[3].Flag)
+	markDeleted := origin[3]
+	markDeleted.Flag = bnapi.ShardStatusNormal
+	origin[3] = markDeleted
+	require.Equal(t, origin, rebuilt)
+
+	// broken body is skipped if verify
+	f, err := os.OpenFile(file, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, rebuilt[4].Offset+core.GetShardHeaderSize()+5)
+	require.NoError(t, err)
+	f.Close()
+	n, err = RebuildChunkMeta(ctx, newDB(), vm, file, false)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	n, err = RebuildChunkMeta(ctx, newDB(), vm, file, true)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	// import exported metas
+	_, _, err = ImportChunkMeta(ctx, kvdb, core.VuidMeta{Vuid: s.vuid, DiskID: 1, ChunkId: bnapi.NewChunkId(s.vuid)},
+		bytes.NewReader(exported.Bytes()))
+	require.ErrorIs(t, err, ErrChunkMetaExportChunk)
+	chunk, n, err := ImportChunkMeta(ctx, kvdb, vm, bytes.NewReader(exported.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	require.Equal(t, vm, chunk)
+	imported := readChunkMetas(t, kvdb, vm)
+	require.Equal(t, bnapi.ShardStatusMarkDelete, imported[3].Flag)
+
+	// logfile chunk has no meta in db
+	n, err = RebuildChunkMeta(ctx, kvdb, core.VuidMeta{StorageEngine: EngineLogFile}, file, true)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+}
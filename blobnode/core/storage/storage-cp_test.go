@@ -91,6 +91,12 @@ func (mm *mockBrokenData) Flush() (err error) {
 	return
 }
 
+func (mm *mockBrokenData) Verify(ctx context.Context, shard *core.Shard) (err error) {
+	err = bloberr.ErrUnexpected
+
+	return
+}
+
 func (mm *mockBrokenData) Delete(ctx context.Context, shard *core.Shard) (err error) {
 	err = bloberr.ErrUnexpected
 
@@ -194,8 +194,11 @@ func (stg *storage) Sync(ctx context.Context) (err error) {
 
 	meta, data := stg.meta, stg.data
 
-	if err = meta.InnerDB().Flush(ctx); err != nil {
-		span.Errorf("Failed meta flush, err:%v", err)
+	// meta of some engines are not saved in kv db
+	if db := meta.InnerDB(); db != nil {
+		if err = db.Flush(ctx); err != nil {
+			span.Errorf("Failed meta flush, err:%v", err)
+		}
 	}
 
 	if err = data.Flush(); err != nil {
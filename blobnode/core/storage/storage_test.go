@@ -131,6 +131,10 @@ func (mm *mockdata) Flush() (err error) {
 	return
 }
 
+func (mm *mockdata) Verify(ctx context.Context, shard *core.Shard) (err error) {
+	return
+}
+
 func (mm *mockdata) Delete(ctx context.Context, shard *core.Shard) (err error) {
 	return
 }
@@ -15,12 +15,17 @@
 package blobnode
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
 	"github.com/cubefs/blobstore/blobnode/base"
+	"github.com/cubefs/blobstore/blobnode/core"
 	"github.com/cubefs/blobstore/blobnode/core/disk"
 	bloberr "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/rpc"
 	"github.com/cubefs/blobstore/common/trace"
 )
@@ -59,74 +64,238 @@ func (s *Service) DiskProbe(c *rpc.Context) {
 	}
 	defer s.DiskLimitPerKey.Release(probePath)
 
-	// Verify that the directory path exists
-	fileExists, err := base.IsFileExists(probePath)
-	if err != nil || !fileExists {
-		span.Errorf("probePath(%s) is not exist, err:%v", probePath, err)
+	if err = s.checkEmptyDiskPath(ctx, probePath); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	// The corresponding configuration file must exist
+	diskConf, found, err := s.findDiskConf(probePath)
+	if err != nil {
+		c.RespondError(err)
+		return
+	}
+	if !found {
+		span.Errorf("can not found<%s> disk config", probePath)
+		c.RespondError(bloberr.ErrNotFound)
+		return
+	}
+
+	ds, err := s.startDisk(ctx, diskConf)
+	if err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	span.Infof("probe path<%s> diskId:%d success.", probePath, ds.ID())
+}
+
+/*
+ *  method:         POST
+ *  url:            /disk/attach
+ *  request body:   json.Marshal(DiskAttachArgs)
+ *  response body:  json.Marshal(DiskInfo)
+ */
+func (s *Service) DiskAttach(c *rpc.Context) {
+	args := new(bnapi.DiskAttachArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Debugf("disk attach args: %v", args)
+
+	if args.Path == "" || args.MaxChunks < 0 {
 		c.RespondError(bloberr.ErrInvalidParam)
 		return
 	}
 
+	attachPath, err := filepath.Abs(args.Path)
+	if err != nil {
+		span.Errorf("Failed abs(path):%s invalid: err:%v", args.Path, err)
+		c.RespondError(err)
+		return
+	}
+
+	err = s.DiskLimitPerKey.Acquire(attachPath)
+	if err != nil {
+		span.Errorf("attachPath (%v) are loading at the same time", attachPath)
+		c.RespondError(bloberr.ErrOutOfLimit)
+		return
+	}
+	defer s.DiskLimitPerKey.Release(attachPath)
+
+	if err = s.checkEmptyDiskPath(ctx, attachPath); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	diskConf, found, err := s.findDiskConf(attachPath)
+	if err != nil {
+		c.RespondError(err)
+		return
+	}
+	if !found {
+		s.lock.RLock()
+		if len(s.Conf.Disks) > 0 {
+			diskConf.BaseConfig = s.Conf.Disks[0].BaseConfig
+		}
+		s.lock.RUnlock()
+		diskConf.Path = attachPath
+	}
+	// empty path must be formatted
+	diskConf.AutoFormat = true
+	if args.MaxChunks > 0 {
+		diskConf.MaxChunks = args.MaxChunks
+	}
+
+	ds, err := s.startDisk(ctx, diskConf)
+	if err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	// record config of attached disk, config file should be updated before next restart
+	if !found {
+		s.lock.Lock()
+		s.Conf.Disks = append(s.Conf.Disks, diskConf)
+		s.lock.Unlock()
+	}
+
+	span.Infof("attach path<%s> diskId:%d success.", attachPath, ds.ID())
+
+	info := ds.DiskInfo()
+	c.RespondJSON(&info)
+}
+
+/*
+ *  method:         POST
+ *  url:            /disk/detach
+ *  request body:   json.Marshal(DiskDetachArgs)
+ */
+func (s *Service) DiskDetach(c *rpc.Context) {
+	args := new(bnapi.DiskDetachArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+
+	span.Debugf("disk detach args: %v", args)
+
+	if !bnapi.IsValidDiskID(args.DiskID) {
+		c.RespondError(bloberr.ErrInvalidDiskId)
+		return
+	}
+
+	// only disks whose chunks have been repaired elsewhere can be detached
+	info, err := s.ClusterMgrClient.DiskInfo(ctx, args.DiskID)
+	if err != nil {
+		span.Errorf("Failed get clustermgr diskinfo %v, err:%v", args.DiskID, err)
+		c.RespondError(err)
+		return
+	}
+	if info.Status != proto.DiskStatusRepaired {
+		span.Errorf("disk:%v status:%v is not repaired", args.DiskID, info.Status)
+		c.RespondError(bloberr.ErrDiskNotRepair)
+		return
+	}
+
+	s.lock.Lock()
+	ds, exist := s.Disks[args.DiskID]
+	delete(s.Disks, args.DiskID)
+
+	path := info.Path
+	if exist {
+		path = ds.GetConfig().Path
+	}
+	disks := make([]core.Config, 0, len(s.Conf.Disks))
+	for _, conf := range s.Conf.Disks {
+		if !isSamePath(conf.Path, path) {
+			disks = append(disks, conf)
+		}
+	}
+	s.Conf.Disks = disks
+	s.lock.Unlock()
+
+	// the handle will be closed when gc, just like broken disks
+	if exist {
+		ds.ResetChunks(ctx)
+	}
+
+	span.Infof("detach disk:%v path<%s> success, online:%v", args.DiskID, path, exist)
+}
+
+// checkEmptyDiskPath checks that path exists, is empty and not used by any online disk
+func (s *Service) checkEmptyDiskPath(ctx context.Context, diskPath string) error {
+	span := trace.SpanFromContextSafe(ctx)
+
+	// Verify that the directory path exists
+	fileExists, err := base.IsFileExists(diskPath)
+	if err != nil || !fileExists {
+		span.Errorf("path(%s) is not exist, err:%v", diskPath, err)
+		return bloberr.ErrInvalidParam
+	}
+
 	// Must be empty
-	empty, err := base.IsEmptyDisk(probePath)
+	empty, err := base.IsEmptyDisk(diskPath)
 	if err != nil || !empty {
-		span.Errorf("probePath(%s) is not empty. err:%v", probePath, err)
-		c.RespondError(bloberr.ErrInvalidParam)
-		return
+		span.Errorf("path(%s) is not empty. err:%v", diskPath, err)
+		return bloberr.ErrInvalidParam
 	}
 
-	var foundOnlineDisk bool
+	// must be no corresponding active handle
 	s.lock.RLock()
+	defer s.lock.RUnlock()
 	for _, d := range s.Disks {
 		path, err := filepath.Abs(d.GetConfig().Path)
 		if err != nil {
-			s.lock.RUnlock()
-			c.RespondError(err)
-			return
+			return err
 		}
-		if path == probePath {
-			foundOnlineDisk = true
-			break
+		if path == diskPath {
+			span.Errorf("path<%s> found online disk.", diskPath)
+			return bloberr.ErrInvalidParam
 		}
 	}
-	s.lock.RUnlock()
 
-	// must be no corresponding active handle
-	if foundOnlineDisk {
-		span.Errorf("path<%s> found online disk.", probePath)
-		c.RespondError(bloberr.ErrInvalidParam)
-		return
-	}
+	return nil
+}
 
-	// The corresponding configuration file must exist
-	foundIdx := -1
-	for idx, conf := range s.Conf.Disks {
+// findDiskConf returns the fixed config of disk path
+func (s *Service) findDiskConf(diskPath string) (diskConf core.Config, found bool, err error) {
+	s.lock.RLock()
+	for _, conf := range s.Conf.Disks {
 		path, err := filepath.Abs(conf.Path)
 		if err != nil {
-			c.RespondError(err)
-			return
+			s.lock.RUnlock()
+			return diskConf, false, err
 		}
-		if probePath == path {
-			foundIdx = idx
+		if diskPath == path {
+			diskConf, found = conf, true
 		}
 	}
+	s.lock.RUnlock()
 
-	if foundIdx < 0 {
-		span.Errorf("can not found<%s> disk config", probePath)
-		c.RespondError(bloberr.ErrNotFound)
-		return
-	}
-
-	// fix init config
-	diskConf := s.Conf.Disks[foundIdx]
 	s.fixDiskConf(&diskConf)
+	return diskConf, found, nil
+}
+
+// startDisk opens the disk storage, registers it to cluster mgr and serves it.
+// The disk path was empty, it is cleaned if failed, so that it can be retried.
+func (s *Service) startDisk(ctx context.Context, diskConf core.Config) (core.DiskAPI, error) {
+	span := trace.SpanFromContextSafe(ctx)
 
-	// new disk storage
-	ds, err := disk.NewDiskStorage(ctx, diskConf)
+	// new disk storage, loops of disk live with service rather than request
+	ds, err := disk.NewDiskStorage(s.ctx, diskConf)
 	if err != nil {
 		span.Errorf("Failed Open DiskStorage. conf:%v, err:%v", diskConf, err)
-		c.RespondError(err)
-		return
+		cleanDiskPath(ctx, diskConf.Path)
+		return nil, err
 	}
 
 	// add disk to cluster mgr
@@ -134,8 +303,9 @@ func (s *Service) DiskProbe(c *rpc.Context) {
 	err = s.ClusterMgrClient.AddDisk(ctx, &diskInfo)
 	if err != nil {
 		span.Errorf("Failed register disk: %v, err:%v", diskInfo, err)
-		c.RespondError(err)
-		return
+		ds.Destroy(ctx)
+		cleanDiskPath(ctx, diskConf.Path)
+		return nil, err
 	}
 
 	// add to service map
@@ -143,5 +313,33 @@ func (s *Service) DiskProbe(c *rpc.Context) {
 	s.Disks[ds.DiskID] = ds
 	s.lock.Unlock()
 
-	span.Infof("probe path<%s> diskId:%d success.", probePath, ds.DiskID)
+	return ds, nil
+}
+
+// cleanDiskPath removes all in the disk path to undo the format
+func cleanDiskPath(ctx context.Context, diskPath string) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	fis, err := ioutil.ReadDir(diskPath)
+	if err != nil {
+		span.Errorf("Failed read disk path:%s, err:%v", diskPath, err)
+		return
+	}
+	for _, fi := range fis {
+		if err = os.RemoveAll(filepath.Join(diskPath, fi.Name())); err != nil {
+			span.Errorf("Failed clean disk path:%s, err:%v", diskPath, err)
+		}
+	}
+}
+
+func isSamePath(a, b string) bool {
+	pa, err := filepath.Abs(a)
+	if err != nil {
+		return false
+	}
+	pb, err := filepath.Abs(b)
+	if err != nil {
+		return false
+	}
+	return pa == pb
 }
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
@@ -27,7 +28,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base"
 	"github.com/cubefs/blobstore/blobnode/core/disk"
+	bloberr "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/trace"
 )
 
@@ -113,3 +117,91 @@ func TestService_WsprpcDiskProbe(t *testing.T) {
 	span.Infof("=== resp:%v, err:%v ===", resp, err)
 	require.Equal(t, 200, resp.StatusCode)
 }
+
+func TestService_DiskAttachDetach(t *testing.T) {
+	service, mockcm := newTestBlobNodeService(t, "DiskAttach")
+	defer cleanTestBlobNodeService(service)
+
+	host := runTestServer(service)
+
+	post := func(uri string, args interface{}) *http.Response {
+		b, err := json.Marshal(args)
+		require.NoError(t, err)
+		resp, err := http.Post(host+uri, "application/json", bytes.NewReader(b))
+		require.NoError(t, err)
+		return resp
+	}
+
+	disk1Path := mockcm.disks[0].path
+	newPath := filepath.Join(filepath.Dir(disk1Path), "disk3")
+
+	// err: path not exist
+	resp := post("/disk/attach", &bnapi.DiskAttachArgs{Path: newPath})
+	resp.Body.Close()
+	require.Equal(t, 600, resp.StatusCode)
+
+	// err: online disk
+	resp = post("/disk/attach", &bnapi.DiskAttachArgs{Path: disk1Path})
+	resp.Body.Close()
+	require.Equal(t, 600, resp.StatusCode)
+
+	// attach empty path absent in config
+	require.NoError(t, os.MkdirAll(newPath, 0o755))
+
+	// err: register failed, the path is cleaned to retry
+	mockcm.addDiskErr = bloberr.ErrIllegalArguments
+	resp = post("/disk/attach", &bnapi.DiskAttachArgs{Path: newPath, MaxChunks: 10})
+	resp.Body.Close()
+	require.NotEqual(t, 200, resp.StatusCode)
+	empty, err := base.IsEmptyDisk(newPath)
+	require.NoError(t, err)
+	require.True(t, empty)
+	mockcm.addDiskErr = nil
+
+	resp = post("/disk/attach", &bnapi.DiskAttachArgs{Path: newPath, MaxChunks: 10})
+	require.Equal(t, 200, resp.StatusCode)
+	info := bnapi.DiskInfo{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	resp.Body.Close()
+
+	service.lock.RLock()
+	ds, exist := service.Disks[info.DiskID]
+	confs := len(service.Conf.Disks)
+	service.lock.RUnlock()
+	require.True(t, exist)
+	require.Equal(t, newPath, ds.GetConfig().Path)
+	require.Equal(t, int32(10), ds.GetConfig().MaxChunks)
+	require.Equal(t, 3, confs)
+
+	// err: attached twice
+	resp = post("/disk/attach", &bnapi.DiskAttachArgs{Path: newPath})
+	resp.Body.Close()
+	require.Equal(t, 600, resp.StatusCode)
+
+	// err: invalid disk id
+	resp = post("/disk/detach", &bnapi.DiskDetachArgs{})
+	resp.Body.Close()
+	require.Equal(t, bloberr.CodeInvalidDiskId, resp.StatusCode)
+
+	// err: disk is not repaired
+	mockcm.disks = append(mockcm.disks, mockDiskInfo{diskId: info.DiskID, path: newPath, status: proto.DiskStatusNormal})
+	resp = post("/disk/detach", &bnapi.DiskDetachArgs{DiskID: info.DiskID})
+	resp.Body.Close()
+	require.Equal(t, bloberr.CodeDiskNotRepair, resp.StatusCode)
+
+	mockcm.disks[len(mockcm.disks)-1].status = proto.DiskStatusRepaired
+	resp = post("/disk/detach", &bnapi.DiskDetachArgs{DiskID: info.DiskID})
+	resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+
+	service.lock.RLock()
+	_, exist = service.Disks[info.DiskID]
+	confs = len(service.Conf.Disks)
+	service.lock.RUnlock()
+	require.False(t, exist)
+	require.Equal(t, 2, confs)
+
+	// other disks still work
+	_, exist = service.Disks[mockcm.disks[0].diskId]
+	require.True(t, exist)
+}
@@ -77,12 +77,16 @@ func NewHandler(service *Service) *rpc.Router {
 
 	rpc.RegisterArgsParser(&bnapi.DiskStatArgs{}, "json")
 	rpc.RegisterArgsParser(&bnapi.DiskProbeArgs{}, "json")
+	rpc.RegisterArgsParser(&bnapi.DiskAttachArgs{}, "json")
+	rpc.RegisterArgsParser(&bnapi.DiskDetachArgs{}, "json")
 
 	rpc.RegisterArgsParser(&bnapi.CreateChunkArgs{}, "json")
 	rpc.RegisterArgsParser(&bnapi.ChangeChunkStatusArgs{}, "json")
 	rpc.RegisterArgsParser(&bnapi.ListChunkArgs{}, "json")
 	rpc.RegisterArgsParser(&bnapi.StatChunkArgs{}, "json")
 	rpc.RegisterArgsParser(&bnapi.CompactChunkArgs{}, "json")
+	rpc.RegisterArgsParser(&bnapi.ExportChunkArgs{}, "json")
+	rpc.RegisterArgsParser(&bnapi.ImportChunkArgs{}, "json")
 
 	rpc.RegisterArgsParser(&bnapi.GetShardArgs{}, "json")
 	rpc.RegisterArgsParser(&bnapi.ListShardsArgs{}, "json")
@@ -97,6 +101,8 @@ func NewHandler(service *Service) *rpc.Router {
 
 	r.Handle(http.MethodGet, "/disk/stat/diskid/:diskid", service.DiskStat_, rpc.OptArgsURI())
 	r.Handle(http.MethodPost, "/disk/probe", service.DiskProbe, rpc.OptArgsBody())
+	r.Handle(http.MethodPost, "/disk/attach", service.DiskAttach, rpc.OptArgsBody())
+	r.Handle(http.MethodPost, "/disk/detach", service.DiskDetach, rpc.OptArgsBody())
 
 	r.Handle(http.MethodPost, "/chunk/create/diskid/:diskid/vuid/:vuid", service.ChunkCreate_, rpc.OptArgsURI(), rpc.OptArgsQuery())
 	r.Handle(http.MethodPost, "/chunk/release/diskid/:diskid/vuid/:vuid", service.ChunkRelease_, rpc.OptArgsURI(), rpc.OptArgsQuery())
@@ -105,6 +111,8 @@ func NewHandler(service *Service) *rpc.Router {
 	r.Handle(http.MethodGet, "/chunk/list/diskid/:diskid", service.ChunkList_, rpc.OptArgsURI())
 	r.Handle(http.MethodGet, "/chunk/stat/diskid/:diskid/vuid/:vuid", service.ChunkStat_, rpc.OptArgsURI())
 	r.Handle(http.MethodPost, "/chunk/compact/diskid/:diskid/vuid/:vuid", service.ChunkCompact_, rpc.OptArgsURI())
+	r.Handle(http.MethodGet, "/chunk/export/diskid/:diskid/vuid/:vuid", service.ChunkExport_, rpc.OptArgsURI())
+	r.Handle(http.MethodPost, "/chunk/import/diskid/:diskid/vuid/:vuid", service.ChunkImport_, rpc.OptArgsURI())
 
 	r.Handle(http.MethodGet, "/shard/get/diskid/:diskid/vuid/:vuid/bid/:bid", service.ShardGet_, rpc.OptArgsURI(), rpc.OptArgsQuery())
 	r.Handle(http.MethodGet, "/shard/list/diskid/:diskid/vuid/:vuid/startbid/:startbid/status/:status/count/:count", service.ShardList_, rpc.OptArgsURI())
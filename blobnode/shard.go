@@ -25,6 +25,7 @@ import (
 	"github.com/cubefs/blobstore/blobnode/base"
 	"github.com/cubefs/blobstore/blobnode/base/limitio"
 	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/cache"
 	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/rpc"
@@ -146,12 +147,8 @@ func (s *Service) ShardGet_(c *rpc.Context) {
 		}
 	}
 
-	if rangeBytesStr != "" {
-		// [from, to)
-		written, err = cs.RangeRead(ctx, shard)
-	} else {
-		written, err = cs.Read(ctx, shard)
-	}
+	// [from, to) if ranged
+	written, err = s.readShard(ctx, cs, shard, args.Type, rangeBytesStr != "")
 	if err != nil {
 		span.Errorf("Failed read. args:%v err:%v, written:%v", args, err, written)
 		if !wroteHeader {
@@ -481,6 +478,10 @@ func (s *Service) ShardDelete_(c *rpc.Context) {
 		c.RespondError(err)
 		return
 	}
+
+	if s.ShardCache != nil {
+		s.ShardCache.Delete(ctx, cache.Key{Vuid: args.Vuid, Bid: args.Bid})
+	}
 }
 
 /*
@@ -0,0 +1,96 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package blobnode
+
+import (
+	"bytes"
+	"context"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/base"
+	"github.com/cubefs/blobstore/blobnode/base/limitio"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/cache"
+	bloberr "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// readShard serves the shard from ssd cache if hit, otherwise reads it from
+// chunk, and promotes it into cache if it's read frequently.
+func (s *Service) readShard(ctx context.Context, cs core.ChunkAPI, shard *core.Shard,
+	iotype bnapi.IOType, ranged bool) (n int64, err error) {
+	read := cs.Read
+	if ranged {
+		read = cs.RangeRead
+	}
+	if s.ShardCache == nil {
+		return read(ctx, shard)
+	}
+
+	// the error is returned by reading chunk
+	meta, err := cs.ReadShardMeta(ctx, shard.Bid)
+	if err != nil {
+		return read(ctx, shard)
+	}
+
+	key := cache.Key{Vuid: cs.Vuid(), Bid: shard.Bid}
+	if data, ok := s.ShardCache.Get(ctx, key, meta); ok {
+		return writeCachedShard(shard, meta, data, ranged)
+	}
+
+	if n, err = read(ctx, shard); err != nil {
+		return n, err
+	}
+
+	if iotype == bnapi.NormalIO && s.ShardCache.Access(key, meta.Size) {
+		span := trace.SpanFromContextSafe(ctx)
+		_, fillCtx := trace.StartSpanFromContextWithTraceID(s.ctx, "FillShardCache", span.TraceID())
+		fillCtx = bnapi.Setiotype(fillCtx, bnapi.BackgroundIO)
+		fillCtx = limitio.SetLimitTrack(fillCtx)
+
+		s.ShardCache.Fill(fillCtx, key, meta.Crc, func(ctx context.Context) ([]byte, error) {
+			buf := bytes.NewBuffer(make([]byte, 0, meta.Size))
+			if _, err := cs.Read(ctx, core.NewShardReader(key.Bid, key.Vuid, 0, 0, buf)); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		})
+	}
+
+	return n, nil
+}
+
+func writeCachedShard(shard *core.Shard, meta *core.ShardMeta, data []byte, ranged bool) (n int64, err error) {
+	shard.FillMeta(*meta)
+
+	if ranged {
+		shard.From, shard.To, err = base.FixHttpRange(shard.From, shard.To, int64(meta.Size))
+		if err != nil {
+			return 0, bloberr.ErrRequestedRangeNotSatisfiable
+		}
+	} else {
+		shard.From, shard.To = 0, int64(meta.Size)
+	}
+
+	if shard.PrepareHook != nil {
+		shard.PrepareHook(shard)
+	}
+	if shard.AfterHook != nil {
+		defer shard.AfterHook(shard)
+	}
+
+	written, err := shard.Writer.Write(data[shard.From:shard.To])
+	return int64(written), err
+}
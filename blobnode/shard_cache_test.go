@@ -0,0 +1,118 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package blobnode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core/cache"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func TestShardGetWithCache(t *testing.T) {
+	service, _ := newTestBlobNodeService(t, "ShardGetWithCache")
+	defer cleanTestBlobNodeService(service)
+
+	ctx := context.TODO()
+	cacheDir := filepath.Join(filepath.Dir(service.Conf.Disks[0].Path), "cache")
+	shardCache, err := cache.NewShardCache(ctx, cache.Config{Paths: []string{cacheDir}, PromoteHits: 2})
+	require.NoError(t, err)
+	service.ShardCache = shardCache
+
+	host := runTestServer(service)
+	client := bnapi.New(&bnapi.Config{})
+
+	diskID := proto.DiskID(101)
+	vuid := proto.Vuid(2001)
+	bid := proto.BlobID(30001)
+	shardData := []byte("testData")
+
+	require.NoError(t, client.CreateChunk(ctx, host, &bnapi.CreateChunkArgs{DiskID: diskID, Vuid: vuid}))
+	_, err = client.PutShard(ctx, host, &bnapi.PutShardArgs{
+		DiskID: diskID,
+		Vuid:   vuid,
+		Bid:    bid,
+		Size:   int64(len(shardData)),
+		Body:   bytes.NewReader(shardData),
+	})
+	require.NoError(t, err)
+
+	getShard := func() {
+		body, _, err := client.GetShard(ctx, host, &bnapi.GetShardArgs{DiskID: diskID, Vuid: vuid, Bid: bid})
+		require.NoError(t, err)
+		defer body.Close()
+		b, err := ioutil.ReadAll(body)
+		require.NoError(t, err)
+		require.Equal(t, shardData, b)
+	}
+
+	// background reads are not counted
+	for i := 0; i < 2; i++ {
+		_, _, err = client.GetShard(ctx, host, &bnapi.GetShardArgs{DiskID: diskID, Vuid: vuid, Bid: bid, Type: bnapi.BackgroundIO})
+		require.NoError(t, err)
+	}
+	shardCache.Close()
+	count, _ := shardCache.Stat()
+	require.Equal(t, 0, count)
+
+	// promoted
+	getShard()
+	getShard()
+	shardCache.Close()
+	count, used := shardCache.Stat()
+	require.Equal(t, 1, count)
+	require.Equal(t, int64(len(shardData)), used)
+
+	// served from cache
+	getShard()
+	body, _, err := client.RangeGetShard(ctx, host, &bnapi.RangeGetShardArgs{
+		GetShardArgs: bnapi.GetShardArgs{DiskID: diskID, Vuid: vuid, Bid: bid},
+		Offset:       4,
+		Size:         2,
+	})
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	body.Close()
+	require.Equal(t, []byte("Da"), b)
+
+	// broken cache is not served
+	cacheFile := filepath.Join(cacheDir, fmt.Sprintf("%016x-%016x", vuid, bid))
+	require.NoError(t, ioutil.WriteFile(cacheFile, []byte("testdata"), 0o644))
+	getShard()
+	count, _ = shardCache.Stat()
+	require.Equal(t, 0, count)
+
+	// deleted with shard
+	getShard()
+	getShard()
+	shardCache.Close()
+	count, _ = shardCache.Stat()
+	require.Equal(t, 1, count)
+	require.NoError(t, client.MarkDeleteShard(ctx, host, &bnapi.DeleteShardArgs{DiskID: diskID, Vuid: vuid, Bid: bid}))
+	require.NoError(t, client.DeleteShard(ctx, host, &bnapi.DeleteShardArgs{DiskID: diskID, Vuid: vuid, Bid: bid}))
+	count, _ = shardCache.Stat()
+	require.Equal(t, 0, count)
+	_, _, err = client.GetShard(ctx, host, &bnapi.GetShardArgs{DiskID: diskID, Vuid: vuid, Bid: bid})
+	require.Error(t, err)
+}
@@ -23,8 +23,10 @@ import (
 
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
 	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/api/mqproxy"
 	"github.com/cubefs/blobstore/blobnode/base/flow"
 	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/cache"
 	"github.com/cubefs/blobstore/blobnode/core/disk"
 	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
@@ -170,6 +172,9 @@ func (s *Service) fixDiskConf(config *core.Config) {
 	config.AllocDiskID = s.ClusterMgrClient.AllocDiskID
 	config.NotifyCompacting = s.ClusterMgrClient.SetCompactChunk
 	config.HandleIOError = s.handleDiskIOError
+	if s.MQProxyClient != nil {
+		config.NotifyRepair = s.MQProxyClient.SendShardRepairMsg
+	}
 
 	// init configs
 	config.RuntimeConfig = s.Conf.DiskConfig
@@ -217,6 +222,14 @@ func NewService(conf Config) (svr *Service, err error) {
 		closeCh: make(chan struct{}),
 	}
 
+	if conf.DiskConfig.AllowScrub {
+		svr.MQProxyClient, err = mqproxy.NewLbClient(&conf.MQProxy, clusterMgrCli, conf.ClusterID)
+		if err != nil {
+			span.Errorf("Failed new mqproxy client, err:%v", err)
+			return nil, err
+		}
+	}
+
 	svr.ctx, svr.cancel = context.WithCancel(context.Background())
 
 	wg := sync.WaitGroup{}
@@ -294,6 +307,13 @@ func NewService(conf Config) (svr *Service, err error) {
 		return nil, err
 	}
 
+	if len(conf.ShardCache.Paths) > 0 {
+		if svr.ShardCache, err = cache.NewShardCache(ctx, conf.ShardCache); err != nil {
+			span.Errorf("Failed new shard cache, err:%v", err)
+			return nil, err
+		}
+	}
+
 	// background loop goroutines
 	go svr.loopHeartbeatToClusterMgr()
 	go svr.loopReportChunkInfoToClusterMgr()
@@ -23,7 +23,9 @@ import (
 
 	bnapi "github.com/cubefs/blobstore/api/blobnode"
 	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/api/mqproxy"
 	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/cache"
 	bloberr "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/rpc"
@@ -43,6 +45,7 @@ type Service struct {
 
 	// client handler
 	ClusterMgrClient *cmapi.Client
+	MQProxyClient    mqproxy.LbMsgSender
 	groupRun         singleflight.Group
 
 	Conf *Config
@@ -56,6 +59,9 @@ type Service struct {
 	ChunkLimitPerVuid     limit.Limiter
 	DiskLimitPerKey       limit.Limiter
 
+	// read cache of shards, nil if disabled
+	ShardCache *cache.ShardCache
+
 	RequestCount int64
 
 	// ctx is used for initiated requests that
@@ -106,6 +112,10 @@ func (s *Service) Close() {
 	s.waitAllRequestsDone(ctx)
 	span.Warnf("all requests done")
 
+	if s.ShardCache != nil {
+		s.ShardCache.Close()
+	}
+
 	// sync chunks
 	chunks := s.copyChunkStorages(ctx)
 	for _, cs := range chunks {
@@ -176,8 +186,14 @@ func (s *Service) DebugStat(c *rpc.Context) {
 		})
 	}
 
+	scrubs := make(map[proto.DiskID][]core.ScrubStat)
+	for _, ds := range disks {
+		scrubs[ds.ID()] = ds.ScrubStats()
+	}
+
 	ret := make(map[string]interface{})
 	ret["chunks"] = chunks
+	ret["scrubs"] = scrubs
 	c.RespondJSON(ret)
 }
 
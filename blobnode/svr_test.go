@@ -479,8 +479,9 @@ type mockDiskInfo struct {
 var _mockDiskIdBase = int64(100)
 
 type mockClusterMgr struct {
-	reqIdx int64
-	disks  []mockDiskInfo
+	reqIdx     int64
+	disks      []mockDiskInfo
+	addDiskErr error
 }
 
 func mockClusterMgrRouter(service *mockClusterMgr) *rpc.Router {
@@ -570,6 +571,12 @@ func (mcm *mockClusterMgr) DiskInfo(c *rpc.Context) {
 	}
 	ret := &bnapi.DiskInfo{}
 	ret.DiskID = args.DiskID
+	for _, di := range mcm.disks {
+		if di.diskId == args.DiskID {
+			ret.Path = di.path
+			ret.Status = di.status
+		}
+	}
 	c.RespondJSON(ret)
 }
 
@@ -599,6 +606,9 @@ func (mcm *mockClusterMgr) DiskAdd(c *rpc.Context) {
 		c.RespondError(bloberr.ErrIllegalArguments)
 		return
 	}
+	if mcm.addDiskErr != nil {
+		c.RespondError(mcm.addDiskErr)
+	}
 }
 
 func (mcm *mockClusterMgr) DiskSet(c *rpc.Context) {
@@ -22,6 +22,7 @@ import (
 var (
 	ErrDiskNotFound = errors.New("sys: disk not found")
 	ErrPathInvalid  = errors.New("sys: path invalid")
+	ErrRingClosed   = errors.New("sys: io_uring closed")
 )
 
 var fsTypes = map[string]string{
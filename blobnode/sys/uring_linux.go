@@ -0,0 +1,359 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+//go:build linux
+// +build linux
+
+package sys
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// raw io_uring without liburing, READ/WRITE ops need linux >= 5.6
+
+const (
+	sysIoUringSetup = 425
+	sysIoUringEnter = 426
+
+	ioringOffSqRing = 0
+	ioringOffCqRing = 0x8000000
+	ioringOffSqes   = 0x10000000
+
+	ioringFeatSingleMmap = 1 << 0
+	ioringEnterGetevents = 1 << 0
+
+	ioringOpNop   = 0
+	ioringOpRead  = 22
+	ioringOpWrite = 23
+
+	closeUserData = math.MaxUint64
+)
+
+type sqringOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	flags       uint32
+	dropped     uint32
+	array       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type cqringOffsets struct {
+	head        uint32
+	tail        uint32
+	ringMask    uint32
+	ringEntries uint32
+	overflow    uint32
+	cqes        uint32
+	flags       uint32
+	resv1       uint32
+	userAddr    uint64
+}
+
+type uringParams struct {
+	sqEntries    uint32
+	cqEntries    uint32
+	flags        uint32
+	sqThreadCPU  uint32
+	sqThreadIdle uint32
+	features     uint32
+	wqFd         uint32
+	resv         [3]uint32
+	sqOff        sqringOffsets
+	cqOff        cqringOffsets
+}
+
+type uringSqe struct {
+	opcode      uint8
+	flags       uint8
+	ioprio      uint16
+	fd          int32
+	off         uint64
+	addr        uint64
+	len         uint32
+	rwFlags     uint32
+	userData    uint64
+	bufIndex    uint16
+	personality uint16
+	spliceFdIn  int32
+	pad         [2]uint64
+}
+
+type uringCqe struct {
+	userData uint64
+	res      int32
+	flags    uint32
+}
+
+// Ring is an io_uring shared by goroutines, submissions are serialized,
+// completions are reaped by a background goroutine.
+type Ring struct {
+	fd int
+
+	sqMem   []byte
+	cqMem   []byte
+	sqesMem []byte
+
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray []uint32
+	sqes    []uringSqe
+
+	cqHead *uint32
+	cqTail *uint32
+	cqMask uint32
+	cqes   []uringCqe
+
+	slots   chan struct{} // in flight limit, keeps cq from overflow
+	lock    sync.Mutex
+	seq     uint64
+	waiters map[uint64]chan int32
+	closed  bool
+	done    chan struct{}
+
+	releaseOnce sync.Once
+}
+
+func uringSetup(entries uint32, params *uringParams) (int, error) {
+	fd, _, errno := syscall.Syscall(sysIoUringSetup, uintptr(entries), uintptr(unsafe.Pointer(params)), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func uringEnter(fd int, toSubmit, minComplete, flags uint32) (int, error) {
+	n, _, errno := syscall.Syscall6(sysIoUringEnter, uintptr(fd), uintptr(toSubmit), uintptr(minComplete),
+		uintptr(flags), 0, 0)
+	if errno != 0 {
+		return int(n), errno
+	}
+	return int(n), nil
+}
+
+func mmapRing(fd int, offset int64, size int) ([]byte, error) {
+	return syscall.Mmap(fd, offset, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+}
+
+func uint32At(mem []byte, off uint32) *uint32 {
+	return (*uint32)(unsafe.Pointer(&mem[off]))
+}
+
+// NewRing returns syscall error if io_uring is not supported or not permitted
+func NewRing(entries uint32) (r *Ring, err error) {
+	params := uringParams{}
+	fd, err := uringSetup(entries, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	r = &Ring{fd: fd}
+	defer func() {
+		if err != nil {
+			r.release()
+		}
+	}()
+
+	sqSize := int(params.sqOff.array + params.sqEntries*4)
+	cqSize := int(params.cqOff.cqes + params.cqEntries*uint32(unsafe.Sizeof(uringCqe{})))
+	if params.features&ioringFeatSingleMmap != 0 && cqSize > sqSize {
+		sqSize = cqSize
+	}
+
+	if r.sqMem, err = mmapRing(fd, ioringOffSqRing, sqSize); err != nil {
+		return nil, err
+	}
+	r.cqMem = r.sqMem
+	if params.features&ioringFeatSingleMmap == 0 {
+		if r.cqMem, err = mmapRing(fd, ioringOffCqRing, cqSize); err != nil {
+			return nil, err
+		}
+	}
+	sqesSize := int(params.sqEntries * uint32(unsafe.Sizeof(uringSqe{})))
+	if r.sqesMem, err = mmapRing(fd, ioringOffSqes, sqesSize); err != nil {
+		return nil, err
+	}
+
+	r.sqTail = uint32At(r.sqMem, params.sqOff.tail)
+	r.sqMask = *uint32At(r.sqMem, params.sqOff.ringMask)
+	r.sqArray = (*[math.MaxInt32 / 4]uint32)(unsafe.Pointer(&r.sqMem[params.sqOff.array]))[:params.sqEntries:params.sqEntries]
+	r.sqes = (*[math.MaxInt32 / 64]uringSqe)(unsafe.Pointer(&r.sqesMem[0]))[:params.sqEntries:params.sqEntries]
+
+	r.cqHead = uint32At(r.cqMem, params.cqOff.head)
+	r.cqTail = uint32At(r.cqMem, params.cqOff.tail)
+	r.cqMask = *uint32At(r.cqMem, params.cqOff.ringMask)
+	r.cqes = (*[math.MaxInt32 / 16]uringCqe)(unsafe.Pointer(&r.cqMem[params.cqOff.cqes]))[:params.cqEntries:params.cqEntries]
+
+	r.slots = make(chan struct{}, params.sqEntries-1) // one for close
+	r.waiters = make(map[uint64]chan int32)
+	r.done = make(chan struct{})
+
+	go r.reap()
+
+	return r, nil
+}
+
+// ReadAt submits one read, n may be less than len(b)
+func (r *Ring) ReadAt(fd uintptr, b []byte, off int64) (n int, err error) {
+	return r.submit(ioringOpRead, fd, b, off)
+}
+
+// WriteAt submits one write, n may be less than len(b)
+func (r *Ring) WriteAt(fd uintptr, b []byte, off int64) (n int, err error) {
+	return r.submit(ioringOpWrite, fd, b, off)
+}
+
+func (r *Ring) submit(op uint8, fd uintptr, b []byte, off int64) (n int, err error) {
+	select {
+	case r.slots <- struct{}{}:
+	case <-r.done:
+		return 0, ErrRingClosed
+	}
+	defer func() { <-r.slots }()
+
+	ch := make(chan int32, 1)
+
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return 0, ErrRingClosed
+	}
+	r.seq++
+	userData := r.seq
+
+	sqe := uringSqe{opcode: op, fd: int32(fd), off: uint64(off), len: uint32(len(b)), userData: userData}
+	if len(b) > 0 {
+		sqe.addr = uint64(uintptr(unsafe.Pointer(&b[0])))
+	}
+	if err = r.push(sqe); err != nil {
+		r.lock.Unlock()
+		return 0, err
+	}
+	r.waiters[userData] = ch
+	r.lock.Unlock()
+
+	res := <-ch
+	runtime.KeepAlive(b)
+	if res < 0 {
+		return 0, syscall.Errno(-res)
+	}
+	return int(res), nil
+}
+
+// push submits the sqe to kernel, must be called with lock held
+func (r *Ring) push(sqe uringSqe) error {
+	tail := atomic.LoadUint32(r.sqTail)
+	idx := tail & r.sqMask
+	r.sqes[idx] = sqe
+	r.sqArray[idx] = idx
+	atomic.StoreUint32(r.sqTail, tail+1)
+
+	for {
+		n, err := uringEnter(r.fd, 1, 0, 0)
+		if err == syscall.EINTR || err == syscall.EAGAIN || err == syscall.EBUSY {
+			continue
+		}
+		if err != nil || n == 0 {
+			// kernel consumes sqe only in enter without sqpoll, safe to take back
+			atomic.StoreUint32(r.sqTail, tail)
+			if err == nil {
+				err = syscall.EIO
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+func (r *Ring) reap() {
+	defer close(r.done)
+
+	closing := false
+	for {
+		_, err := uringEnter(r.fd, 0, 1, ioringEnterGetevents)
+		if err != nil && err != syscall.EINTR && err != syscall.EAGAIN {
+			// no more completions can be reaped
+			r.lock.Lock()
+			r.closed = true
+			for userData, ch := range r.waiters {
+				ch <- -int32(err.(syscall.Errno))
+				delete(r.waiters, userData)
+			}
+			r.lock.Unlock()
+			return
+		}
+
+		head := atomic.LoadUint32(r.cqHead)
+		tail := atomic.LoadUint32(r.cqTail)
+		r.lock.Lock()
+		for ; head != tail; head++ {
+			cqe := r.cqes[head&r.cqMask]
+			if cqe.userData == closeUserData {
+				closing = true
+				continue
+			}
+			if ch, ok := r.waiters[cqe.userData]; ok {
+				ch <- cqe.res
+				delete(r.waiters, cqe.userData)
+			}
+		}
+		atomic.StoreUint32(r.cqHead, head)
+		finished := closing && len(r.waiters) == 0
+		r.lock.Unlock()
+
+		if finished {
+			return
+		}
+	}
+}
+
+// Close waits the submitted requests done
+func (r *Ring) Close() (err error) {
+	r.lock.Lock()
+	if !r.closed {
+		r.closed = true
+		// wake up the reaper
+		err = r.push(uringSqe{opcode: ioringOpNop, userData: closeUserData})
+	}
+	r.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	<-r.done
+	r.releaseOnce.Do(func() { err = r.release() })
+	return err
+}
+
+func (r *Ring) release() error {
+	if r.sqesMem != nil {
+		syscall.Munmap(r.sqesMem)
+	}
+	if r.cqMem != nil && (r.sqMem == nil || &r.cqMem[0] != &r.sqMem[0]) {
+		syscall.Munmap(r.cqMem)
+	}
+	if r.sqMem != nil {
+		syscall.Munmap(r.sqMem)
+	}
+	return syscall.Close(r.fd)
+}
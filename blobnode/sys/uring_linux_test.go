@@ -0,0 +1,85 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sys
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRing(t *testing.T) *Ring {
+	r, err := NewRing(8)
+	if err != nil {
+		// io_uring is disabled by kernel or seccomp
+		require.IsType(t, syscall.Errno(0), err)
+		t.Skipf("io_uring not supported: %v", err)
+	}
+	return r
+}
+
+func TestRing_ReadWrite(t *testing.T) {
+	testDir, err := ioutil.TempDir(os.TempDir(), "RingReadWrite")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	f, err := os.OpenFile(filepath.Join(testDir, "file"), os.O_RDWR|os.O_CREATE, 0o644)
+	require.NoError(t, err)
+	defer f.Close()
+
+	r := newTestRing(t)
+
+	// more concurrency than ring entries
+	concurrency := 32
+	wg := sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func(i int) {
+			defer wg.Done()
+			data := bytes.Repeat([]byte{byte(i)}, 4096)
+			n, err := r.WriteAt(f.Fd(), data, int64(i*4096))
+			require.NoError(t, err)
+			require.Equal(t, len(data), n)
+
+			buf := make([]byte, 4096)
+			n, err = r.ReadAt(f.Fd(), buf, int64(i*4096))
+			require.NoError(t, err)
+			require.Equal(t, len(buf), n)
+			require.Equal(t, data, buf)
+		}(i)
+	}
+	wg.Wait()
+
+	// eof
+	buf := make([]byte, 4096)
+	n, err := r.ReadAt(f.Fd(), buf, int64(concurrency*4096))
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	// bad fd
+	_, err = r.ReadAt(uintptr(0x12345), buf, 0)
+	require.Equal(t, syscall.EBADF, err)
+
+	require.NoError(t, r.Close())
+	require.NoError(t, r.Close())
+	_, err = r.ReadAt(f.Fd(), buf, 0)
+	require.Equal(t, ErrRingClosed, err)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/fatih/color"
 
 	"github.com/cubefs/blobstore/cli/access"
+	"github.com/cubefs/blobstore/cli/blobnode"
 	"github.com/cubefs/blobstore/cli/clustermgr"
 	"github.com/cubefs/blobstore/cli/common"
 	"github.com/cubefs/blobstore/cli/common/flags"
@@ -103,5 +104,6 @@ func init() {
 	registerUtil(App)
 
 	access.Register(App)
+	blobnode.Register(App)
 	clustermgr.Register(App)
 }
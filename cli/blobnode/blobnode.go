@@ -0,0 +1,239 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package blobnode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/desertbit/grumble"
+	"github.com/dustin/go-humanize"
+
+	bnapi "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/blobnode/core"
+	"github.com/cubefs/blobstore/blobnode/core/disk"
+	"github.com/cubefs/blobstore/cli/common"
+	"github.com/cubefs/blobstore/cli/common/args"
+)
+
+// Register register blobnode
+func Register(app *grumble.App) {
+	bnCommand := &grumble.Command{
+		Name:     "blobnode",
+		Help:     "blobnode tools",
+		LongHelp: "blobnode tools, blobnode must be stopped on the disk for offline meta tools",
+	}
+	app.AddCommand(bnCommand)
+
+	metaCommand := &grumble.Command{
+		Name:     "meta",
+		Help:     "disk meta tools",
+		LongHelp: "rebuild, export or import meta db of blobnode disk",
+	}
+	bnCommand.AddCommand(metaCommand)
+
+	metaCommand.AddCommand(&grumble.Command{
+		Name:     "rebuild",
+		Help:     "rebuild meta db of disk from data files",
+		LongHelp: "rebuild meta db of disk from data files, move the broken meta db away first",
+		Run:      cmdMetaRebuild,
+		Args: func(a *grumble.Args) {
+			a.String("path", "disk path")
+		},
+		Flags: func(f *grumble.Flags) {
+			f.Bool("", "verify", false, "verify shard data with crc")
+		},
+	})
+	metaCommand.AddCommand(&grumble.Command{
+		Name: "export",
+		Help: "export chunk meta into file",
+		Run:  cmdMetaExport,
+		Args: func(a *grumble.Args) {
+			a.String("path", "disk path")
+			args.VuidRegister(a)
+			a.String("file", "export file path")
+		},
+	})
+	metaCommand.AddCommand(&grumble.Command{
+		Name: "import",
+		Help: "import chunk meta from file",
+		Run:  cmdMetaImport,
+		Args: func(a *grumble.Args) {
+			a.String("path", "disk path")
+			args.VuidRegister(a)
+			a.String("file", "exported file path")
+		},
+	})
+
+	chunkCommand := &grumble.Command{
+		Name:     "chunk",
+		Help:     "chunk archive tools",
+		LongHelp: "export chunk into archive file or import it as new vuid, for offline data transfer",
+	}
+	bnCommand.AddCommand(chunkCommand)
+
+	chunkCommand.AddCommand(&grumble.Command{
+		Name:     "export",
+		Help:     "export readonly chunk into archive file",
+		LongHelp: "export readonly chunk into archive file, with data and metas of shards",
+		Run:      cmdChunkExport,
+		Args: func(a *grumble.Args) {
+			a.String("host", "blobnode host")
+			args.DiskIDRegister(a)
+			args.VuidRegister(a)
+			a.String("file", "archive file path")
+		},
+	})
+	chunkCommand.AddCommand(&grumble.Command{
+		Name:     "import",
+		Help:     "import archive file as new chunk",
+		LongHelp: "import archive file as new chunk of vuid, shards are verified with crc",
+		Run:      cmdChunkImport,
+		Args: func(a *grumble.Args) {
+			a.String("host", "blobnode host")
+			args.DiskIDRegister(a)
+			args.VuidRegister(a)
+			a.String("file", "archive file path")
+		},
+	})
+}
+
+func diskConfig(path string) (core.Config, error) {
+	if path == "" {
+		return core.Config{}, errors.New("disk path can't be null")
+	}
+	return core.Config{BaseConfig: core.BaseConfig{Path: path}}, nil
+}
+
+func cmdMetaRebuild(c *grumble.Context) error {
+	conf, err := diskConfig(c.Args.String("path"))
+	if err != nil {
+		return err
+	}
+	if !common.Confirm(fmt.Sprintf("rebuild meta db of disk %s?", conf.Path)) {
+		return nil
+	}
+
+	stat, err := disk.RebuildMeta(common.CmdContext(), conf, c.Flags.Bool("verify"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(common.Readable(stat))
+	return nil
+}
+
+func cmdMetaExport(c *grumble.Context) error {
+	conf, err := diskConfig(c.Args.String("path"))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(c.Args.String("file"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := disk.ExportChunkMeta(common.CmdContext(), conf, args.Vuid(c.Args), f)
+	if err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	fmt.Printf("exported %d shards\n", n)
+	return nil
+}
+
+func cmdMetaImport(c *grumble.Context) error {
+	conf, err := diskConfig(c.Args.String("path"))
+	if err != nil {
+		return err
+	}
+	vuid := args.Vuid(c.Args)
+	if !common.Confirm(fmt.Sprintf("import meta of vuid %d into disk %s?", vuid, conf.Path)) {
+		return nil
+	}
+
+	f, err := os.Open(c.Args.String("file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := disk.ImportChunkMeta(common.CmdContext(), conf, vuid, f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d shards\n", n)
+	return nil
+}
+
+func cmdChunkExport(c *grumble.Context) error {
+	cli := bnapi.New(&bnapi.Config{})
+	body, err := cli.ExportChunk(common.CmdContext(), c.Args.String("host"), &bnapi.ExportChunkArgs{
+		DiskID: args.DiskID(c.Args),
+		Vuid:   args.Vuid(c.Args),
+	})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := os.OpenFile(c.Args.String("file"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, body)
+	if err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	fmt.Printf("exported %s\n", humanize.IBytes(uint64(n)))
+	return nil
+}
+
+func cmdChunkImport(c *grumble.Context) error {
+	host := c.Args.String("host")
+	diskID := args.DiskID(c.Args)
+	vuid := args.Vuid(c.Args)
+	if !common.Confirm(fmt.Sprintf("import archive as vuid %d into disk %d of %s?", vuid, diskID, host)) {
+		return nil
+	}
+
+	f, err := os.Open(c.Args.String("file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cli := bnapi.New(&bnapi.Config{})
+	info, err := cli.ImportChunk(common.CmdContext(), host, &bnapi.ImportChunkArgs{
+		DiskID: diskID,
+		Vuid:   vuid,
+		Body:   f,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(common.Readable(info))
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/desertbit/grumble"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/cli/common"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/persistence/raftdb"
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/raftserver"
+)
+
+func addCmdBackup(cmd *grumble.Command) {
+	cmd.AddCommand(&grumble.Command{
+		Name:     "backup",
+		Help:     "backup clustermgr",
+		LongHelp: "take a consistent backup of clustermgr into local directory of leader",
+		Run:      cmdBackup,
+		Args: func(a *grumble.Args) {
+			a.String("dir", "backup directory on leader host")
+		},
+		Flags: func(f *grumble.Flags) {
+			clusterFlags(f)
+		},
+	})
+
+	cmd.AddCommand(&grumble.Command{
+		Name:     "restore",
+		Help:     "restore clustermgr from backup",
+		LongHelp: "restore backup into empty db and wal path, and bootstrap a single member cluster, clustermgr should be stopped",
+		Run:      cmdRestore,
+		Args: func(a *grumble.Args) {
+			a.String("dir", "backup directory")
+			a.String("normalDBPath", "normal db path")
+			a.String("volumeDBPath", "volume db path")
+			a.String("raftDBPath", "raft db path")
+			a.String("walDir", "raft wal directory")
+			a.Uint64("nodeID", "raft node id of the new member")
+			a.String("host", "raft host of the new member")
+		},
+	})
+}
+
+func cmdBackup(c *grumble.Context) error {
+	cli := newCMClient(c.Flags.String("secret"), specificHosts(c.Flags)...)
+	manifest, err := cli.Backup(common.CmdContext(), &clustermgr.BackupArgs{Dir: c.Args.String("dir")})
+	if err != nil {
+		return err
+	}
+	fmt.Println(common.Readable(manifest))
+	return nil
+}
+
+func cmdRestore(c *grumble.Context) error {
+	dir := c.Args.String("dir")
+	normalDBPath := c.Args.String("normalDBPath")
+	volumeDBPath := c.Args.String("volumeDBPath")
+	raftDBPath := c.Args.String("raftDBPath")
+	walDir := c.Args.String("walDir")
+	member := raftserver.Member{Id: c.Args.Uint64("nodeID"), Host: c.Args.String("host")}
+	if dir == "" || normalDBPath == "" || volumeDBPath == "" || raftDBPath == "" || walDir == "" ||
+		member.Id == 0 || member.Host == "" {
+		return errors.New("invalid command arguments")
+	}
+
+	normalDB, err := openNormalDB(normalDBPath, false)
+	if err != nil {
+		return err
+	}
+	defer normalDB.Close()
+	volumeDB, err := openVolumeDB(volumeDBPath, false)
+	if err != nil {
+		return err
+	}
+	defer volumeDB.Close()
+	raftDB, err := raftdb.OpenRaftDB(raftDBPath, false, &kvstore.RocksDBOption{})
+	if err != nil {
+		return fmt.Errorf("open db failed, err: %s", err.Error())
+	}
+	defer raftDB.Close()
+
+	dbs := map[string]base.SnapshotDB{"volume": volumeDB, "normal": normalDB}
+	manifest, err := base.RestoreBackup(common.CmdContext(), dir, dbs, raftDB, walDir, member)
+	if err != nil {
+		return err
+	}
+	fmt.Println(common.Readable(manifest))
+	fmt.Printf("restored at apply index %d, start clustermgr with node id %d and the only member %s\n",
+		manifest.ApplyIndex, member.Id, member.Host)
+	return nil
+}
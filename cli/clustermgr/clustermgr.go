@@ -87,6 +87,7 @@ func Register(app *grumble.App) {
 	addCmdVolume(cmCommand)
 	addCmdListAllDB(cmCommand)
 	addCmdDisk(cmCommand)
+	addCmdBackup(cmCommand)
 
 	cmCommand.AddCommand(&grumble.Command{
 		Name: "stat",
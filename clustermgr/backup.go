@@ -0,0 +1,62 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"os"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	apierrors "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// AdminBackup takes a consistent backup of all snapshot dbs and raft meta into local directory.
+// apply is blocked only when flushing memory data and creating db snapshot
+func (s *Service) AdminBackup(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.BackupArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Infof("accept AdminBackup request, args: %v", args)
+	if args.Dir == "" {
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+
+	s.applyLock.Lock()
+	snapshot, err := s.raftNode.CreateBackupSnapshot(ctx, s.dbs, s.RaftConfig.SnapshotPatchNum)
+	s.applyLock.Unlock()
+	if err != nil {
+		span.Errorf("create backup snapshot failed, err: %v", err)
+		c.RespondError(err)
+		return
+	}
+	defer snapshot.Close()
+
+	manifest, err := s.raftNode.WriteBackup(ctx, args.Dir, snapshot)
+	if err != nil {
+		if os.IsExist(err) {
+			err = apierrors.ErrBackupExist
+		}
+		span.Errorf("write backup into %s failed, err: %v", args.Dir, err)
+		c.RespondError(err)
+		return
+	}
+	c.RespondJSON(manifest)
+}
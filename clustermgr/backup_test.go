@@ -0,0 +1,124 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
+	"github.com/cubefs/blobstore/clustermgr/persistence/raftdb"
+	"github.com/cubefs/blobstore/clustermgr/persistence/volumedb"
+	apierrors "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/raftserver"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	srcService := initTestService(t)
+	defer clear(srcService)
+	defer srcService.Close()
+	srcClusterClient := initTestClusterClient(srcService)
+
+	_, ctx := trace.StartSpanFromContext(context.Background(), "")
+	insertDiskInfos(t, srcClusterClient, 1, 10, "z0")
+
+	backupDir := "/tmp/tmpsvrbackup-" + strconv.Itoa(rand.Intn(10000000))
+	defer os.RemoveAll(backupDir)
+
+	// backup
+	_, err := srcClusterClient.Backup(ctx, &clustermgr.BackupArgs{})
+	require.Equal(t, http.StatusBadRequest, rpc.DetectStatusCode(err))
+
+	manifest, err := srcClusterClient.Backup(ctx, &clustermgr.BackupArgs{Dir: backupDir})
+	require.NoError(t, err)
+	require.Equal(t, srcService.raftNode.GetStableApplyIndex(), manifest.ApplyIndex)
+	require.Equal(t, 1, len(manifest.Members.Mbs))
+	require.Equal(t, 1, len(manifest.Files))
+
+	_, err = srcClusterClient.Backup(ctx, &clustermgr.BackupArgs{Dir: backupDir})
+	require.Equal(t, apierrors.CodeBackupExist, rpc.DetectStatusCode(err))
+
+	// restore into empty path and start a single member cluster
+	cfg := *testServiceCfg
+	cfg.NormalDBPath = "/tmp/tmpsvrnormaldb-" + strconv.Itoa(rand.Intn(10000000))
+	cfg.VolumeMgrConfig.VolumeDBPath = "/tmp/tmpsvrvolumedb-" + strconv.Itoa(rand.Intn(10000000))
+	cfg.RaftConfig.RaftDBPath = "/tmp/tmpsvrraftdb-" + strconv.Itoa(rand.Intn(10000000))
+	cfg.RaftConfig.ServerConfig.WalDir = "/tmp/tmpsvrraftwal-" + strconv.Itoa(rand.Intn(10000000))
+	cfg.RaftConfig.ServerConfig.ListenPort = GetFreePort()
+	host := "127.0.0.1:" + strconv.Itoa(cfg.RaftConfig.ServerConfig.ListenPort)
+	cfg.RaftConfig.ServerConfig.Peers = map[uint64]string{2: host}
+	cfg.RaftConfig.ServerConfig.NodeId = 2
+	os.Mkdir(cfg.NormalDBPath, 0o755)
+	os.Mkdir(cfg.VolumeMgrConfig.VolumeDBPath, 0o755)
+	os.Mkdir(cfg.RaftConfig.RaftDBPath, 0o755)
+	{
+		normalDB, err := normaldb.OpenNormalDB(cfg.NormalDBPath, false, &kvstore.RocksDBOption{})
+		require.NoError(t, err)
+		volumeDB, err := volumedb.Open(cfg.VolumeMgrConfig.VolumeDBPath, false, &kvstore.RocksDBOption{})
+		require.NoError(t, err)
+		raftDB, err := raftdb.OpenRaftDB(cfg.RaftConfig.RaftDBPath, false, &kvstore.RocksDBOption{})
+		require.NoError(t, err)
+
+		dbs := map[string]base.SnapshotDB{"volume": volumeDB, "normal": normalDB}
+		member := raftserver.Member{Id: 2, Host: host}
+		restored, err := base.RestoreBackup(ctx, backupDir, dbs, raftDB, cfg.RaftConfig.ServerConfig.WalDir, member)
+		require.NoError(t, err)
+		require.Equal(t, manifest.ApplyIndex, restored.ApplyIndex)
+
+		// restore into path with data is not allowed
+		_, err = base.RestoreBackup(ctx, backupDir, dbs, raftDB, cfg.RaftConfig.ServerConfig.WalDir, member)
+		require.ErrorIs(t, err, base.ErrRestoreNotEmpty)
+
+		normalDB.Close()
+		volumeDB.Close()
+		raftDB.Close()
+	}
+	destService, err := New(&cfg)
+	require.NoError(t, err)
+	defer clear(destService)
+	defer destService.Close()
+	destClusterClient := initTestClusterClient(destService)
+
+	require.Equal(t, manifest.ApplyIndex, destService.raftNode.GetCurrentApplyIndex())
+	for i := 1; i <= 10; i++ {
+		_, err = destClusterClient.DiskInfo(ctx, proto.DiskID(i))
+		require.NoError(t, err)
+	}
+	// restored cluster accepts new propose after the backup index
+	insertDiskInfos(t, destClusterClient, 11, 12, "z0")
+	require.Less(t, manifest.ApplyIndex, destService.raftNode.GetCurrentApplyIndex())
+
+	// corrupted backup
+	f, err := os.OpenFile(filepath.Join(backupDir, manifest.Files[0].Name), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("corrupted"))
+	require.NoError(t, err)
+	f.Close()
+	_, err = base.ReadBackupManifest(backupDir)
+	require.ErrorIs(t, err, base.ErrBackupChecksum)
+}
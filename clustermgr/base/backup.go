@@ -0,0 +1,258 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package base
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/persistence/raftdb"
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/raftserver"
+	"github.com/cubefs/blobstore/common/raftserver/wal"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+const (
+	BackupManifestName = "MANIFEST"
+	// backup data file holds all snapshot data in the same encoding of raft snapshot
+	backupDataName = "snapshot.data"
+)
+
+var (
+	ErrBackupChecksum  = errors.New("backup file checksum mismatch")
+	ErrRestoreNotEmpty = errors.New("restore target has persistent data already")
+)
+
+// CreateBackupSnapshot flush all applied data and create snapshot of dbs at current apply index.
+// caller should make sure that there is no apply in progress until it returns,
+// then the snapshot is consistent with the apply index
+func (r *RaftNode) CreateBackupSnapshot(ctx context.Context, dbs map[string]SnapshotDB, patchNum int) (raftserver.Snapshot, error) {
+	if err := r.RecordApplyIndex(ctx, r.GetCurrentApplyIndex(), true); err != nil {
+		return nil, err
+	}
+	return r.CreateRaftSnapshot(dbs, patchNum), nil
+}
+
+// WriteBackup write all data of snapshot into dir, and a manifest with raft meta and checksums at last
+func (r *RaftNode) WriteBackup(ctx context.Context, dir string, st raftserver.Snapshot) (*clustermgr.BackupManifest, error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	if _, err := os.Stat(filepath.Join(dir, BackupManifestName)); err == nil {
+		return nil, os.ErrExist
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	manifest := &clustermgr.BackupManifest{
+		ApplyIndex: st.Index(),
+		Term:       r.Status().Term,
+		CreateTime: time.Now().Unix(),
+	}
+	rawMembers, err := r.raftDB.Get([]byte(raftserver.RaftMemberKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(rawMembers) > 0 {
+		if err = json.Unmarshal(rawMembers, &manifest.Members); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := writeBackupData(filepath.Join(dir, backupDataName), st)
+	if err != nil {
+		span.Errorf("write backup data failed, err: %v", err)
+		return nil, err
+	}
+	manifest.Files = append(manifest.Files, file)
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	tmpName := filepath.Join(dir, BackupManifestName+".tmp")
+	if err = ioutil.WriteFile(tmpName, data, 0o644); err != nil {
+		return nil, err
+	}
+	if err = os.Rename(tmpName, filepath.Join(dir, BackupManifestName)); err != nil {
+		return nil, err
+	}
+	span.Infof("write backup into %s success, manifest: %+v", dir, manifest)
+	return manifest, nil
+}
+
+// ReadBackupManifest read manifest of backup in dir and verify all files' checksum
+func ReadBackupManifest(dir string) (*clustermgr.BackupManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, BackupManifestName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &clustermgr.BackupManifest{}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	for _, file := range manifest.Files {
+		size, crc, err := checksumFile(filepath.Join(dir, file.Name))
+		if err != nil {
+			return nil, err
+		}
+		if size != file.Size || crc != file.Crc32 {
+			return nil, fmt.Errorf("%w: %s", ErrBackupChecksum, file.Name)
+		}
+	}
+	return manifest, nil
+}
+
+// RestoreBackup load backup in dir into empty dbs and raft storage, and bootstrap
+// raft storage as a single member cluster of the member at backup's apply index
+func RestoreBackup(ctx context.Context, dir string, dbs map[string]SnapshotDB, raftDB *raftdb.RaftDB,
+	walDir string, member raftserver.Member) (*clustermgr.BackupManifest, error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	manifest, err := ReadBackupManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rawApplyIndex, err := raftDB.Get(ApplyIndexKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(rawApplyIndex) > 0 {
+		return nil, ErrRestoreNotEmpty
+	}
+	w, err := wal.OpenWal(walDir, true)
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+	if w.LastIndex() > 0 {
+		return nil, ErrRestoreNotEmpty
+	}
+
+	count, err := loadBackupData(filepath.Join(dir, backupDataName), dbs)
+	if err != nil {
+		return nil, err
+	}
+	span.Infof("restore backup data count: %d", count)
+
+	member.Learner = false
+	rawMembers, err := json.Marshal(raftserver.Members{Mbs: []raftserver.Member{member}})
+	if err != nil {
+		return nil, err
+	}
+	if err = raftDB.Put([]byte(raftserver.RaftMemberKey), rawMembers); err != nil {
+		return nil, err
+	}
+	indexValue := make([]byte, 8)
+	binary.BigEndian.PutUint64(indexValue, manifest.ApplyIndex)
+	if err = raftDB.Put(ApplyIndexKey, indexValue); err != nil {
+		return nil, err
+	}
+	// raft log starts after the backup, so the node never asks for entries before apply index
+	if err = w.ApplySnapshot(wal.Snapshot{Index: manifest.ApplyIndex, Term: manifest.Term}); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeBackupData(name string, st raftserver.Snapshot) (file clustermgr.BackupFile, err error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	crc := crc32.NewIEEE()
+	writer := bufio.NewWriter(io.MultiWriter(f, crc))
+	var data []byte
+	for data, err = st.Read(); err == nil; data, err = st.Read() {
+		if _, err = writer.Write(data); err != nil {
+			return
+		}
+		file.Size += int64(len(data))
+	}
+	if err != io.EOF {
+		return
+	}
+	if err = writer.Flush(); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	file.Name = filepath.Base(name)
+	file.Crc32 = crc.Sum32()
+	return
+}
+
+func loadBackupData(name string, dbs map[string]SnapshotDB) (count uint64, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		snapData, err := decodeSnapshotData(reader)
+		if err != nil {
+			if err == io.EOF {
+				return count, nil
+			}
+			return count, err
+		}
+		db, ok := dbs[snapData.Header.DbName]
+		if !ok {
+			return count, fmt.Errorf("db %s of backup not found", snapData.Header.DbName)
+		}
+		kv := kvstore.KV{Key: snapData.Key, Value: snapData.Value}
+		if snapData.Header.CfName != "" {
+			err = db.Table(snapData.Header.CfName).Put(kv)
+		} else {
+			err = db.Put(kv)
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func checksumFile(name string) (size int64, crc uint32, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	h := crc32.NewIEEE()
+	if size, err = io.Copy(h, f); err != nil {
+		return
+	}
+	return size, h.Sum32(), nil
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/clustermgr/base"
 	"github.com/cubefs/blobstore/clustermgr/configmgr"
+	"github.com/cubefs/blobstore/common/codemode"
 	apierrors "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/rpc"
@@ -65,7 +66,7 @@ func (s *Service) ConfigSet(c *rpc.Context) {
 	}
 	span.Debugf("accept ConfigSet request :%v\n", args)
 
-	if args.Key == proto.CodeModeConfigKey {
+	if args.Key == proto.CodeModeConfigKey || args.Key == proto.CodeModeTacticsConfigKey {
 		span.Warnf("code mode key not allow to set by api")
 		c.RespondError(apierrors.ErrIllegalArguments)
 		return
@@ -88,6 +89,12 @@ func (s *Service) ConfigDelete(c *rpc.Context) {
 	}
 	span.Debugf("accept ConfigDelete request key:%v\n", args.Key)
 
+	if args.Key == proto.CodeModeTacticsConfigKey {
+		span.Warnf("registered code modes not allow to delete by api")
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+
 	data, err := json.Marshal(args)
 	if err != nil {
 		span.Errorf("ConfigDelete json marshal failed, args: %v, error: %v", args, err)
@@ -123,3 +130,41 @@ func (s *Service) ConfigList(c *rpc.Context) {
 		c.RespondError(err)
 	}
 }
+
+// CodeModeRegister register runtime code mode: /codemode/register
+func (s *Service) CodeModeRegister(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(codemode.RegisteredMode)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Infof("accept CodeModeRegister request, args: %+v", args)
+
+	if err := s.ConfigMgr.RegisterCodeMode(ctx, *args); err != nil {
+		span.Errorf("register code mode failed, args: %+v, err: %v", args, err)
+		c.RespondError(errors.Info(apierrors.ErrIllegalArguments).Detail(err))
+		return
+	}
+}
+
+// CodeModeList list runtime registered code modes: /codemode/list
+func (s *Service) CodeModeList(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	span.Debug("accept CodeModeList request")
+
+	// linear read
+	if err := s.raftNode.ReadIndex(ctx); err != nil {
+		span.Errorf("read index error: %v", err)
+		c.RespondError(apierrors.ErrRaftReadIndex)
+		return
+	}
+	modes, err := s.ConfigMgr.ListCodeModes(ctx)
+	if err != nil {
+		c.RespondError(err)
+		return
+	}
+	c.RespondJSON(clustermgr.ListCodeModeRet{CodeModes: modes})
+}
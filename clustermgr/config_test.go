@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/common/codemode"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/trace"
 
@@ -80,3 +81,52 @@ func TestConfig(t *testing.T) {
 		assert.Error(t, err)
 	}
 }
+
+func TestCodeModeRegistry(t *testing.T) {
+	testService := initTestService(t)
+	defer clear(testService)
+	defer testService.Close()
+	testClusterClient := initTestClusterClient(testService)
+
+	_, ctx := trace.StartSpanFromContext(context.Background(), "")
+
+	ret, err := testClusterClient.ListCodeModes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(ret.CodeModes))
+	assert.NoError(t, clustermgr.SyncCodeModes(ctx, testClusterClient))
+
+	ec8p4 := codemode.RegisteredMode{
+		CodeMode: 210,
+		Name:     "EC8P4",
+		Tactic:   codemode.Tactic{N: 8, M: 4, AZCount: 1, PutQuorum: 11, MinShardSize: 2048},
+	}
+	// failed case
+	{
+		bad := ec8p4
+		bad.CodeMode = codemode.EC6P6
+		assert.Error(t, testClusterClient.RegisterCodeMode(ctx, &bad))
+		bad = ec8p4
+		bad.Tactic.PutQuorum = 13
+		assert.Error(t, testClusterClient.RegisterCodeMode(ctx, &bad))
+		err = testClusterClient.SetConfig(ctx, &clustermgr.ConfigSetArgs{Key: proto.CodeModeTacticsConfigKey, Value: "[]"})
+		assert.Error(t, err)
+	}
+
+	assert.NoError(t, testClusterClient.RegisterCodeMode(ctx, &ec8p4))
+	assert.NoError(t, testClusterClient.RegisterCodeMode(ctx, &ec8p4))
+	assert.True(t, ec8p4.CodeMode.IsValid())
+	assert.Equal(t, ec8p4.Tactic, ec8p4.Name.Tactic())
+
+	conflict := ec8p4
+	conflict.CodeMode = 211
+	assert.Error(t, testClusterClient.RegisterCodeMode(ctx, &conflict))
+	conflict = ec8p4
+	conflict.Tactic.PutQuorum = 12
+	assert.Error(t, testClusterClient.RegisterCodeMode(ctx, &conflict))
+
+	ret, err = testClusterClient.ListCodeModes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []codemode.RegisteredMode{ec8p4}, ret.CodeModes)
+	assert.NoError(t, clustermgr.SyncCodeModes(ctx, testClusterClient))
+	assert.Error(t, testClusterClient.DeleteConfig(ctx, proto.CodeModeTacticsConfigKey))
+}
@@ -20,12 +20,15 @@ import (
 
 	"github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/trace"
 	"github.com/cubefs/blobstore/util/errors"
 )
 
 func (s *ConfigMgr) LoadData(ctx context.Context) error {
-	return nil
+	_, err := loadCodeModes(s.configTbl)
+	return err
 }
 
 func (v *ConfigMgr) GetModuleName() string {
@@ -52,6 +55,9 @@ func (v *ConfigMgr) Apply(ctx context.Context, operTypes []int32, datas [][]byte
 				span.Errorf("ConfigMgr.Apply OperTypeSetConfig update failed, err: %v, args: %v", err, configSetArgs)
 				return
 			}
+			if configSetArgs.Key == proto.CodeModeTacticsConfigKey {
+				v.applyCodeModes(ctx, configSetArgs.Value)
+			}
 		case OperTypeDeleteConfig:
 			configDelArgs := &clustermgr.ConfigArgs{}
 			err = json.Unmarshal(datas[i], configDelArgs)
@@ -73,6 +79,21 @@ func (v *ConfigMgr) Apply(ctx context.Context, operTypes []int32, datas [][]byte
 	return
 }
 
+// applyCodeModes registers the applied code modes into process,
+// registered mode never changed so that error only be logged
+func (v *ConfigMgr) applyCodeModes(ctx context.Context, val string) {
+	span := trace.SpanFromContextSafe(ctx)
+	modes, err := decodeCodeModes(val)
+	if err == nil {
+		err = codemode.Register(modes...)
+	}
+	if err != nil {
+		span.Errorf("ConfigMgr.Apply register code modes failed, err: %v, value: %s", err, val)
+		return
+	}
+	span.Infof("ConfigMgr.Apply registered code modes: %+v", modes)
+}
+
 // Flush will flush memory data into persistent storage
 func (v *ConfigMgr) Flush(ctx context.Context) error {
 	return nil
@@ -0,0 +1,107 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configmgr
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+// LoadCodeModes register runtime code modes stored in normal db into process,
+// clustermgr should call it before any code mode name of config been used
+func LoadCodeModes(db *normaldb.NormalDB) error {
+	configTable, err := normaldb.OpenConfigTable(db)
+	if err != nil {
+		return err
+	}
+	_, err = loadCodeModes(configTable)
+	return err
+}
+
+func loadCodeModes(configTbl *normaldb.ConfigTable) ([]codemode.RegisteredMode, error) {
+	val, err := configTbl.Get(proto.CodeModeTacticsConfigKey)
+	if err == kvstore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	modes, err := decodeCodeModes(val)
+	if err != nil {
+		return nil, err
+	}
+	return modes, codemode.Register(modes...)
+}
+
+func decodeCodeModes(val string) (modes []codemode.RegisteredMode, err error) {
+	if val == "" {
+		return
+	}
+	err = json.Unmarshal([]byte(val), &modes)
+	return
+}
+
+// ListCodeModes returns all runtime registered code modes
+func (v *ConfigMgr) ListCodeModes(ctx context.Context) ([]codemode.RegisteredMode, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	val, err := v.configTbl.Get(proto.CodeModeTacticsConfigKey)
+	if err == kvstore.ErrNotFound {
+		return []codemode.RegisteredMode{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	modes, err := decodeCodeModes(val)
+	if modes == nil {
+		modes = []codemode.RegisteredMode{}
+	}
+	return modes, err
+}
+
+// RegisterCodeMode validate the code mode and propose the new registered list,
+// the mode takes effect in process after raft applied
+func (v *ConfigMgr) RegisterCodeMode(ctx context.Context, mode codemode.RegisteredMode) error {
+	if err := mode.Validate(); err != nil {
+		return err
+	}
+
+	v.registerMu.Lock()
+	defer v.registerMu.Unlock()
+	modes, err := v.ListCodeModes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range modes {
+		if m == mode {
+			return nil
+		}
+		if m.CodeMode == mode.CodeMode || m.Name == mode.Name {
+			return errors.Newf("conflict with registered codemode:%d name:%s", m.CodeMode, m.Name)
+		}
+	}
+
+	data, err := json.Marshal(append(modes, mode))
+	if err != nil {
+		return err
+	}
+	return v.Set(ctx, proto.CodeModeTacticsConfigKey, string(data))
+}
@@ -38,6 +38,8 @@ type ConfigMgr struct {
 	defaultClusterConfig map[string]string
 	mu                   sync.RWMutex
 	raftServer           raftserver.RaftServer
+	// registerMu serializes read-modify-write of registered code modes
+	registerMu sync.Mutex
 }
 
 type ConfigMgrAPI interface {
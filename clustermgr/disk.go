@@ -110,7 +110,7 @@ func (s *Service) DiskInfo(c *rpc.Context) {
 	span.Infof("accept DiskInfo request, args: %v", args)
 
 	// linear read
-	if err := s.raftNode.ReadIndex(ctx); err != nil {
+	if err := s.readIndex(c); err != nil {
 		span.Errorf("read index error: %v", err)
 		c.RespondError(apierrors.ErrRaftReadIndex)
 		return
@@ -135,7 +135,7 @@ func (s *Service) DiskList(c *rpc.Context) {
 	}
 	span.Infof("accept DiskList request, args: %v", args)
 
-	if err := s.raftNode.ReadIndex(ctx); err != nil {
+	if err := s.readIndex(c); err != nil {
 		span.Errorf("read index error: %v", err)
 		c.RespondError(apierrors.ErrRaftReadIndex)
 		return
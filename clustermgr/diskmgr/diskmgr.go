@@ -26,6 +26,7 @@ import (
 	"github.com/cubefs/blobstore/clustermgr/base"
 	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
 	"github.com/cubefs/blobstore/clustermgr/scopemgr"
+	"github.com/cubefs/blobstore/clustermgr/watchmgr"
 	"github.com/cubefs/blobstore/common/codemode"
 	apierrors "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
@@ -364,6 +365,7 @@ func (d *DiskMgr) SetStatus(ctx context.Context, id proto.DiskID, status proto.D
 	if !diskInfo.needFilter() {
 		d.hostPathFilter.Delete(diskInfo.genFilterKey())
 	}
+	watchmgr.Record(ctx, clustermgr.WatchEvent{Type: clustermgr.WatchEventDiskStatus, DiskID: id, Status: status})
 
 	return nil
 }
@@ -753,7 +755,15 @@ func (d *DiskMgr) adminUpdateDisk(ctx context.Context, diskInfo *blobnode.DiskIn
 	diskRecord := diskInfoToDiskInfoRecord(disk.info)
 	err := d.diskTbl.UpdateDisk(diskInfo.DiskID, diskRecord)
 	disk.lock.Unlock()
-	return err
+	if err != nil {
+		return err
+	}
+	if diskInfo.Status.IsValid() {
+		watchmgr.Record(ctx, clustermgr.WatchEvent{
+			Type: clustermgr.WatchEventDiskStatus, DiskID: diskInfo.DiskID, Status: diskInfo.Status,
+		})
+	}
+	return nil
 }
 
 func diskInfoToDiskInfoRecord(info *blobnode.DiskInfo) *normaldb.DiskInfoRecord {
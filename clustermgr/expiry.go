@@ -0,0 +1,108 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"github.com/cubefs/blobstore/api/clustermgr"
+	apierrors "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+const (
+	defaultListExpiredBlobCount = 1000
+	maxListExpiredBlobCount     = 10000
+	maxBlobExpiryCount          = 10000
+)
+
+func (s *Service) BlobExpirySet(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.BlobExpiryArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept BlobExpirySet request, args: %v", args)
+
+	if !isValidBlobExpiry(args, false) {
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+	if err := s.ExpiryMgr.Set(ctx, args); err != nil {
+		span.Error("raft propose failed, err: ", err)
+		c.RespondError(apierrors.ErrRaftPropose)
+	}
+}
+
+func (s *Service) BlobExpiryDelete(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.BlobExpiryArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept BlobExpiryDelete request, args: %v", args)
+
+	if !isValidBlobExpiry(args, true) {
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+	if err := s.ExpiryMgr.Delete(ctx, args); err != nil {
+		span.Error("raft propose failed, err: ", err)
+		c.RespondError(apierrors.ErrRaftPropose)
+	}
+}
+
+func (s *Service) BlobExpiryList(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.ListExpiredBlobArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept BlobExpiryList request, args: %v", args)
+
+	if args.Count <= 0 {
+		args.Count = defaultListExpiredBlobCount
+	}
+	if args.Count > maxListExpiredBlobCount {
+		args.Count = maxListExpiredBlobCount
+	}
+	blobs, err := s.ExpiryMgr.ListExpired(ctx, args.Before, args.Count)
+	if err != nil {
+		span.Errorf("list expired blob failed, err: %v", err)
+		c.RespondError(err)
+		return
+	}
+	c.RespondJSON(&clustermgr.ListExpiredBlobRet{Blobs: blobs})
+}
+
+func isValidBlobExpiry(args *clustermgr.BlobExpiryArgs, toDelete bool) bool {
+	if len(args.Blobs) == 0 || len(args.Blobs) > maxBlobExpiryCount {
+		return false
+	}
+	for i := range args.Blobs {
+		if toDelete && !args.Blobs[i].IsValidToDelete() {
+			return false
+		}
+		if !toDelete && !args.Blobs[i].IsValid() {
+			return false
+		}
+	}
+	return true
+}
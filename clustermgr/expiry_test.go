@@ -0,0 +1,86 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+func TestBlobExpiry(t *testing.T) {
+	testService := initTestService(t)
+	defer clear(testService)
+	defer testService.Close()
+	testClusterClient := initTestClusterClient(testService)
+
+	_, ctx := trace.StartSpanFromContext(context.Background(), "")
+
+	blobs := []clustermgr.BlobExpiry{
+		{Vid: 1, MinBid: 100, Count: 10, ExpireAt: 200},
+		{Vid: 1, MinBid: 110, Count: 1, ExpireAt: 100, Packed: &proto.PackedBlob{Index: 0, Count: 2}},
+		{Vid: 2, MinBid: 120, Count: 5, ExpireAt: 300},
+	}
+
+	// invalid args
+	{
+		err := testClusterClient.SetBlobExpiry(ctx, &clustermgr.BlobExpiryArgs{})
+		require.Error(t, err)
+		err = testClusterClient.SetBlobExpiry(ctx, &clustermgr.BlobExpiryArgs{
+			Blobs: []clustermgr.BlobExpiry{{Vid: 1, MinBid: 100, Count: 1}},
+		})
+		require.Error(t, err)
+		err = testClusterClient.SetBlobExpiry(ctx, &clustermgr.BlobExpiryArgs{
+			Blobs: []clustermgr.BlobExpiry{{Vid: 1, MinBid: 100, Count: 2, ExpireAt: 1, Packed: &proto.PackedBlob{Count: 2}}},
+		})
+		require.Error(t, err)
+		err = testClusterClient.DeleteBlobExpiry(ctx, &clustermgr.BlobExpiryArgs{})
+		require.Error(t, err)
+	}
+
+	require.NoError(t, testClusterClient.SetBlobExpiry(ctx, &clustermgr.BlobExpiryArgs{Blobs: blobs}))
+
+	ret, err := testClusterClient.ListExpiredBlob(ctx, &clustermgr.ListExpiredBlobArgs{Before: 100})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ret.Blobs))
+
+	ret, err = testClusterClient.ListExpiredBlob(ctx, &clustermgr.ListExpiredBlobArgs{Before: 250})
+	require.NoError(t, err)
+	require.Equal(t, []clustermgr.BlobExpiry{blobs[1], blobs[0]}, ret.Blobs)
+
+	ret, err = testClusterClient.ListExpiredBlob(ctx, &clustermgr.ListExpiredBlobArgs{Before: 1000, Count: 1})
+	require.NoError(t, err)
+	require.Equal(t, blobs[1:2], ret.Blobs)
+
+	require.NoError(t, testClusterClient.DeleteBlobExpiry(ctx, &clustermgr.BlobExpiryArgs{Blobs: blobs[:2]}))
+	ret, err = testClusterClient.ListExpiredBlob(ctx, &clustermgr.ListExpiredBlobArgs{Before: 1000})
+	require.NoError(t, err)
+	require.Equal(t, blobs[2:], ret.Blobs)
+
+	// delete by explicit deletion without expiry
+	noExpiry := blobs[2]
+	noExpiry.ExpireAt = 0
+	require.NoError(t, testClusterClient.DeleteBlobExpiry(ctx, &clustermgr.BlobExpiryArgs{
+		Blobs: []clustermgr.BlobExpiry{noExpiry},
+	}))
+	ret, err = testClusterClient.ListExpiredBlob(ctx, &clustermgr.ListExpiredBlobArgs{Before: 1000})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ret.Blobs))
+}
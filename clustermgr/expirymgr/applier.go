@@ -0,0 +1,71 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package expirymgr
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+func (e *ExpiryMgr) LoadData(ctx context.Context) error {
+	return nil
+}
+
+func (e *ExpiryMgr) GetModuleName() string {
+	return e.module
+}
+
+func (e *ExpiryMgr) SetModuleName(module string) {
+	e.module = module
+}
+
+func (e *ExpiryMgr) Apply(ctx context.Context, operTypes []int32, datas [][]byte, contexts []base.ProposeContext) (err error) {
+	for i, t := range operTypes {
+		span, _ := trace.StartSpanFromContextWithTraceID(ctx, "", contexts[i].ReqID)
+		args := &clustermgr.BlobExpiryArgs{}
+		if err = json.Unmarshal(datas[i], args); err != nil {
+			span.Errorf("ExpiryMgr.Apply json unmarshal failed, err: %v, data: %v", err, datas[i])
+			return
+		}
+		switch t {
+		case OperTypeSetBlobExpiry:
+			if err = e.tbl.Put(toRecords(args.Blobs)); err != nil {
+				span.Errorf("ExpiryMgr.Apply OperTypeSetBlobExpiry put failed, err: %v, args: %v", err, args)
+				return
+			}
+		case OperTypeDeleteBlobExpiry:
+			if err = e.tbl.Delete(toRecords(args.Blobs)); err != nil {
+				span.Errorf("ExpiryMgr.Apply OperTypeDeleteBlobExpiry delete failed, err: %v, args: %v", err, args)
+				return
+			}
+		default:
+			err = errors.New("unsupported operation")
+			return
+		}
+	}
+	return
+}
+
+func (e *ExpiryMgr) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (e *ExpiryMgr) NotifyLeaderChange(ctx context.Context, leader uint64, host string) {
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package expirymgr
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
+	"github.com/cubefs/blobstore/common/raftserver"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+const (
+	OperTypeSetBlobExpiry = iota + 1
+	OperTypeDeleteBlobExpiry
+)
+
+// ExpiryMgr manages expiry of blobs, the expired blobs
+// are listed and deleted by the background sweeper.
+type ExpiryMgr struct {
+	module     string
+	tbl        *normaldb.BlobExpiryTable
+	raftServer raftserver.RaftServer
+}
+
+func New(db *normaldb.NormalDB) *ExpiryMgr {
+	return &ExpiryMgr{tbl: normaldb.OpenBlobExpiryTable(db)}
+}
+
+// Set propose to record expiry of blobs
+func (e *ExpiryMgr) Set(ctx context.Context, args *clustermgr.BlobExpiryArgs) error {
+	return e.propose(ctx, OperTypeSetBlobExpiry, args)
+}
+
+// Delete propose to delete expiry records of blobs
+func (e *ExpiryMgr) Delete(ctx context.Context, args *clustermgr.BlobExpiryArgs) error {
+	return e.propose(ctx, OperTypeDeleteBlobExpiry, args)
+}
+
+// ListExpired returns blobs expired before the unix timestamp
+func (e *ExpiryMgr) ListExpired(ctx context.Context, before int64, count int) ([]clustermgr.BlobExpiry, error) {
+	records, err := e.tbl.ListExpired(before, count)
+	if err != nil {
+		return nil, err
+	}
+	blobs := make([]clustermgr.BlobExpiry, len(records))
+	for i := range records {
+		blobs[i] = clustermgr.BlobExpiry(records[i])
+	}
+	return blobs, nil
+}
+
+func (e *ExpiryMgr) SetRaftServer(raftServer raftserver.RaftServer) {
+	e.raftServer = raftServer
+}
+
+func (e *ExpiryMgr) propose(ctx context.Context, operType int32, args *clustermgr.BlobExpiryArgs) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	proposeInfo := base.EncodeProposeInfo(e.GetModuleName(), operType, data, base.ProposeContext{ReqID: trace.SpanFromContextSafe(ctx).TraceID()})
+	return e.raftServer.Propose(ctx, proposeInfo)
+}
+
+func toRecords(blobs []clustermgr.BlobExpiry) []normaldb.BlobExpiryRecord {
+	records := make([]normaldb.BlobExpiryRecord, len(blobs))
+	for i := range blobs {
+		records[i] = normaldb.BlobExpiryRecord(blobs[i])
+	}
+	return records
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package expirymgr
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/testing/mocks"
+)
+
+func TestExpiryMgr(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "expiry")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	normalDB, err := normaldb.OpenNormalDB(testDir, false, nil)
+	require.NoError(t, err)
+	defer normalDB.Close()
+
+	span, ctx := trace.StartSpanFromContext(context.Background(), "")
+	mgr := New(normalDB)
+	mgr.SetModuleName("ExpiryMgr")
+	require.Equal(t, "ExpiryMgr", mgr.GetModuleName())
+	require.NoError(t, mgr.LoadData(ctx))
+	require.NoError(t, mgr.Flush(ctx))
+	mgr.NotifyLeaderChange(ctx, 0, "")
+
+	// apply the proposed data
+	mockRaftServer := mocks.NewMockRaftServer(gomock.NewController(t))
+	mockRaftServer.EXPECT().Propose(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, data []byte) error {
+			info := base.DecodeProposeInfo(data)
+			require.Equal(t, mgr.GetModuleName(), info.Module)
+			return mgr.Apply(ctx, []int32{info.OperType}, [][]byte{info.Data}, []base.ProposeContext{info.Context})
+		})
+	mgr.SetRaftServer(mockRaftServer)
+
+	blobs := []clustermgr.BlobExpiry{
+		{Vid: 1, MinBid: 100, Count: 10, ExpireAt: 200},
+		{Vid: 2, MinBid: 200, Count: 1, ExpireAt: 100, Packed: &proto.PackedBlob{Index: 1, Count: 3}},
+	}
+	require.NoError(t, mgr.Set(ctx, &clustermgr.BlobExpiryArgs{Blobs: blobs}))
+
+	expired, err := mgr.ListExpired(ctx, 150, 10)
+	require.NoError(t, err)
+	require.Equal(t, blobs[1:], expired)
+	expired, err = mgr.ListExpired(ctx, 300, 10)
+	require.NoError(t, err)
+	require.Equal(t, []clustermgr.BlobExpiry{blobs[1], blobs[0]}, expired)
+
+	require.NoError(t, mgr.Delete(ctx, &clustermgr.BlobExpiryArgs{Blobs: blobs[1:]}))
+	expired, err = mgr.ListExpired(ctx, 300, 10)
+	require.NoError(t, err)
+	require.Equal(t, blobs[:1], expired)
+
+	// invalid data and operation
+	err = mgr.Apply(ctx, []int32{OperTypeSetBlobExpiry}, [][]byte{[]byte("-1")},
+		[]base.ProposeContext{{ReqID: span.TraceID()}})
+	require.Error(t, err)
+	err = mgr.Apply(ctx, []int32{10}, [][]byte{[]byte("{}")},
+		[]base.ProposeContext{{ReqID: span.TraceID()}})
+	require.Error(t, err)
+}
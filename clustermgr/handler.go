@@ -32,6 +32,11 @@ func NewHandler(service *Service) *rpc.Router {
 
 	rpc.GET("/config/list", service.ConfigList)
 
+	//===================codemode=====================
+	rpc.POST("/codemode/register", service.CodeModeRegister, rpc.OptArgsBody())
+
+	rpc.GET("/codemode/list", service.CodeModeList)
+
 	//==================disk==========================
 	rpc.RegisterArgsParser(&clustermgr.DiskInfoArgs{}, "json")
 	rpc.RegisterArgsParser(&clustermgr.ListOptionArgs{}, "json")
@@ -106,12 +111,44 @@ func NewHandler(service *Service) *rpc.Router {
 
 	rpc.POST("/admin/update/volume", service.AdminUpdateVolume, rpc.OptArgsBody())
 
+	rpc.RegisterArgsParser(&clustermgr.BackupArgs{}, "json")
+
+	rpc.POST("/admin/backup", service.AdminBackup, rpc.OptArgsBody())
+
 	//==================chunk==========================
 
 	rpc.POST("/chunk/report", service.ChunkReport, rpc.OptArgsBody())
 
 	rpc.POST("/chunk/set/compact", service.ChunkSetCompact, rpc.OptArgsBody())
 
+	//==================blob expiry==========================
+	rpc.RegisterArgsParser(&clustermgr.ListExpiredBlobArgs{}, "json")
+
+	rpc.POST("/blob/expiry/set", service.BlobExpirySet, rpc.OptArgsBody())
+
+	rpc.POST("/blob/expiry/delete", service.BlobExpiryDelete, rpc.OptArgsBody())
+
+	rpc.GET("/blob/expiry/list", service.BlobExpiryList, rpc.OptArgsQuery())
+
+	//==================kv==========================
+	rpc.RegisterArgsParser(&clustermgr.GetKvArgs{}, "json")
+	rpc.RegisterArgsParser(&clustermgr.ListKvArgs{}, "json")
+
+	rpc.GET("/kv/get", service.KvGet, rpc.OptArgsQuery())
+
+	rpc.GET("/kv/list", service.KvList, rpc.OptArgsQuery())
+
+	rpc.POST("/kv/set", service.KvSet, rpc.OptArgsBody())
+
+	rpc.POST("/kv/delete", service.KvDelete, rpc.OptArgsBody())
+
+	rpc.POST("/kv/incr", service.KvIncr, rpc.OptArgsBody())
+
+	//==================watch==========================
+	rpc.RegisterArgsParser(&clustermgr.WatchArgs{}, "json")
+
+	rpc.GET("/watch", service.Watch, rpc.OptArgsQuery())
+
 	//==================srv==========================
 
 	rpc.POST("/bid/alloc", service.BidAlloc, rpc.OptArgsBody())
@@ -0,0 +1,137 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"github.com/cubefs/blobstore/api/clustermgr"
+	apierrors "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+const (
+	maxKvBatchCount = 1000
+	maxKvValueSize  = 1 << 20
+)
+
+func (s *Service) KvGet(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.GetKvArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept KvGet request, args: %v", args)
+
+	if args.Key == "" {
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+	value, err := s.KvMgr.Get(ctx, args.Key)
+	if err != nil {
+		c.RespondError(err)
+		return
+	}
+	c.RespondJSON(&clustermgr.GetKvRet{Value: value})
+}
+
+func (s *Service) KvList(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.ListKvArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept KvList request, args: %v", args)
+
+	ret, err := s.KvMgr.List(ctx, args)
+	if err != nil {
+		span.Errorf("list kv failed, err: %v", err)
+		c.RespondError(err)
+		return
+	}
+	c.RespondJSON(ret)
+}
+
+func (s *Service) KvSet(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.SetKvArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept KvSet request, count: %d", len(args.Kvs))
+
+	if len(args.Kvs) == 0 || len(args.Kvs) > maxKvBatchCount {
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+	for i := range args.Kvs {
+		if args.Kvs[i].Key == "" || len(args.Kvs[i].Value) > maxKvValueSize {
+			c.RespondError(apierrors.ErrIllegalArguments)
+			return
+		}
+	}
+	if err := s.KvMgr.Set(ctx, args); err != nil {
+		span.Error("raft propose failed, err: ", err)
+		c.RespondError(apierrors.ErrRaftPropose)
+	}
+}
+
+func (s *Service) KvDelete(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.DeleteKvArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept KvDelete request, args: %v", args)
+
+	if len(args.Keys) == 0 || len(args.Keys) > maxKvBatchCount {
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+	if err := s.KvMgr.Delete(ctx, args); err != nil {
+		span.Error("raft propose failed, err: ", err)
+		c.RespondError(apierrors.ErrRaftPropose)
+	}
+}
+
+func (s *Service) KvIncr(c *rpc.Context) {
+	ctx := c.Request.Context()
+	span := trace.SpanFromContextSafe(ctx)
+	args := new(clustermgr.IncrKvArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+	span.Debugf("accept KvIncr request, args: %v", args)
+
+	if args.Key == "" || args.Max < 0 {
+		c.RespondError(apierrors.ErrIllegalArguments)
+		return
+	}
+	value, err := s.KvMgr.Incr(ctx, args)
+	if err != nil {
+		span.Warnf("incr kv failed, err: %v", err)
+		c.RespondError(err)
+		return
+	}
+	c.RespondJSON(&clustermgr.IncrKvRet{Value: value})
+}
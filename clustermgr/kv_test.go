@@ -0,0 +1,75 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	apierrors "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+func TestKv(t *testing.T) {
+	testService := initTestService(t)
+	defer clear(testService)
+	defer testService.Close()
+	testClusterClient := initTestClusterClient(testService)
+
+	_, ctx := trace.StartSpanFromContext(context.Background(), "")
+
+	// invalid args
+	{
+		err := testClusterClient.SetKV(ctx, &clustermgr.SetKvArgs{})
+		require.Error(t, err)
+		err = testClusterClient.SetKV(ctx, &clustermgr.SetKvArgs{Kvs: []clustermgr.KeyValue{{Value: []byte("1")}}})
+		require.Error(t, err)
+		err = testClusterClient.DeleteKV(ctx, &clustermgr.DeleteKvArgs{})
+		require.Error(t, err)
+		_, err = testClusterClient.IncrKV(ctx, &clustermgr.IncrKvArgs{Delta: 1})
+		require.Error(t, err)
+	}
+
+	kvs := []clustermgr.KeyValue{
+		{Key: "x/a b", Value: []byte("1")},
+		{Key: "x/c&d", Value: []byte("2")},
+	}
+	require.NoError(t, testClusterClient.SetKV(ctx, &clustermgr.SetKvArgs{Kvs: kvs}))
+	ret, err := testClusterClient.GetKV(ctx, kvs[1].Key)
+	require.NoError(t, err)
+	require.Equal(t, kvs[1].Value, ret.Value)
+
+	listRet, err := testClusterClient.ListKV(ctx, &clustermgr.ListKvArgs{Prefix: "x/", Count: 1})
+	require.NoError(t, err)
+	require.Equal(t, kvs[:1], listRet.Kvs)
+	listRet, err = testClusterClient.ListKV(ctx, &clustermgr.ListKvArgs{Prefix: "x/", Marker: listRet.Marker})
+	require.NoError(t, err)
+	require.Equal(t, kvs[1:], listRet.Kvs)
+	require.Equal(t, "", listRet.Marker)
+
+	require.NoError(t, testClusterClient.DeleteKV(ctx, &clustermgr.DeleteKvArgs{Keys: []string{kvs[0].Key}}))
+	_, err = testClusterClient.GetKV(ctx, kvs[0].Key)
+	require.Equal(t, apierrors.CodeKvNotFound, rpc.DetectStatusCode(err))
+
+	incrRet, err := testClusterClient.IncrKV(ctx, &clustermgr.IncrKvArgs{Key: "y", Delta: 3, Max: 3})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), incrRet.Value)
+	_, err = testClusterClient.IncrKV(ctx, &clustermgr.IncrKvArgs{Key: "y", Delta: 1, Max: 3})
+	require.Equal(t, apierrors.CodeKvCounterExceedLimit, rpc.DetectStatusCode(err))
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kvmgr
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+)
+
+func (k *KvMgr) LoadData(ctx context.Context) error {
+	return nil
+}
+
+func (k *KvMgr) GetModuleName() string {
+	return k.module
+}
+
+func (k *KvMgr) SetModuleName(module string) {
+	k.module = module
+}
+
+func (k *KvMgr) Apply(ctx context.Context, operTypes []int32, datas [][]byte, contexts []base.ProposeContext) (err error) {
+	for i, t := range operTypes {
+		span, _ := trace.StartSpanFromContextWithTraceID(ctx, "", contexts[i].ReqID)
+		switch t {
+		case OperTypeSetKv:
+			args := &clustermgr.SetKvArgs{}
+			if err = json.Unmarshal(datas[i], args); err != nil {
+				span.Errorf("KvMgr.Apply json unmarshal failed, err: %v, data: %v", err, datas[i])
+				return
+			}
+			kvs := make([]kvstore.KV, len(args.Kvs))
+			for j := range args.Kvs {
+				kvs[j] = kvstore.KV{Key: []byte(args.Kvs[j].Key), Value: args.Kvs[j].Value}
+			}
+			if err = k.tbl.Put(kvs); err != nil {
+				span.Errorf("KvMgr.Apply OperTypeSetKv put failed, err: %v", err)
+				return
+			}
+		case OperTypeDeleteKv:
+			args := &clustermgr.DeleteKvArgs{}
+			if err = json.Unmarshal(datas[i], args); err != nil {
+				span.Errorf("KvMgr.Apply json unmarshal failed, err: %v, data: %v", err, datas[i])
+				return
+			}
+			if err = k.tbl.Delete(args.Keys); err != nil {
+				span.Errorf("KvMgr.Apply OperTypeDeleteKv delete failed, err: %v, args: %v", err, args)
+				return
+			}
+		case OperTypeIncrKv:
+			args := &incrKvArgs{}
+			if err = json.Unmarshal(datas[i], args); err != nil {
+				span.Errorf("KvMgr.Apply json unmarshal failed, err: %v, data: %v", err, datas[i])
+				return
+			}
+			value, rejected, e := k.incr(&args.IncrKvArgs)
+			if e != nil {
+				span.Errorf("KvMgr.Apply OperTypeIncrKv failed, err: %v, args: %v", e, args)
+				return e
+			}
+			if _, ok := k.pendingEntries.Load(args.PendingKey); ok {
+				k.pendingEntries.Store(args.PendingKey, &incrKvResult{value: value, err: rejected})
+			}
+		default:
+			err = errors.New("unsupported operation")
+			return
+		}
+	}
+	return
+}
+
+func (k *KvMgr) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (k *KvMgr) NotifyLeaderChange(ctx context.Context, leader uint64, host string) {
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kvmgr
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
+	"github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/raftserver"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+const (
+	OperTypeSetKv = iota + 1
+	OperTypeDeleteKv
+	OperTypeIncrKv
+)
+
+const (
+	defaultListCount = 100
+	maxListCount     = 1000
+	counterLen       = 8
+)
+
+type incrKvArgs struct {
+	clustermgr.IncrKvArgs
+	PendingKey string `json:"pending_key"`
+}
+
+type incrKvResult struct {
+	value int64
+	err   error
+}
+
+// KvMgr manages generic key values shared by services, such as
+// the states of access which must be seen by all access nodes.
+type KvMgr struct {
+	module     string
+	tbl        *normaldb.KvTable
+	raftServer raftserver.RaftServer
+
+	pendingEntries sync.Map
+}
+
+func New(db *normaldb.NormalDB) *KvMgr {
+	return &KvMgr{tbl: normaldb.OpenKvTable(db)}
+}
+
+// Get returns ErrKvNotFound if key not exists
+func (k *KvMgr) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := k.tbl.Get(key)
+	if err == kvstore.ErrNotFound {
+		return nil, errors.ErrKvNotFound
+	}
+	return value, err
+}
+
+func (k *KvMgr) List(ctx context.Context, args *clustermgr.ListKvArgs) (*clustermgr.ListKvRet, error) {
+	count := args.Count
+	if count <= 0 {
+		count = defaultListCount
+	}
+	if count > maxListCount {
+		count = maxListCount
+	}
+	kvs, marker, err := k.tbl.List(args.Prefix, args.Marker, count)
+	if err != nil {
+		return nil, err
+	}
+	ret := &clustermgr.ListKvRet{Kvs: make([]clustermgr.KeyValue, len(kvs)), Marker: marker}
+	for i := range kvs {
+		ret.Kvs[i] = clustermgr.KeyValue{Key: string(kvs[i].Key), Value: kvs[i].Value}
+	}
+	return ret, nil
+}
+
+// Set propose to put key values
+func (k *KvMgr) Set(ctx context.Context, args *clustermgr.SetKvArgs) error {
+	return k.propose(ctx, OperTypeSetKv, args)
+}
+
+// Delete propose to delete keys
+func (k *KvMgr) Delete(ctx context.Context, args *clustermgr.DeleteKvArgs) error {
+	return k.propose(ctx, OperTypeDeleteKv, args)
+}
+
+// Incr propose to add delta to the counter, and returns the new value
+func (k *KvMgr) Incr(ctx context.Context, args *clustermgr.IncrKvArgs) (int64, error) {
+	pendingKey := uuid.New().String()
+	k.pendingEntries.Store(pendingKey, nil)
+	defer k.pendingEntries.Delete(pendingKey)
+
+	if err := k.propose(ctx, OperTypeIncrKv, &incrKvArgs{IncrKvArgs: *args, PendingKey: pendingKey}); err != nil {
+		return 0, err
+	}
+	value, _ := k.pendingEntries.Load(pendingKey)
+	if value == nil {
+		return 0, errors.ErrUnexpected
+	}
+	ret := value.(*incrKvResult)
+	return ret.value, ret.err
+}
+
+func (k *KvMgr) SetRaftServer(raftServer raftserver.RaftServer) {
+	k.raftServer = raftServer
+}
+
+func (k *KvMgr) propose(ctx context.Context, operType int32, args interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	proposeInfo := base.EncodeProposeInfo(k.GetModuleName(), operType, data, base.ProposeContext{ReqID: trace.SpanFromContextSafe(ctx).TraceID()})
+	return k.raftServer.Propose(ctx, proposeInfo)
+}
+
+func incrTokenKey(key, token string) string {
+	return clustermgr.IncrKvTokenPrefix + key + "/" + token
+}
+
+// incr the counter is applied only if the new value in [0, max],
+// rejection is returned as result but not an apply error.
+// the counter and its token are updated atomically.
+func (k *KvMgr) incr(args *clustermgr.IncrKvArgs) (value int64, rejected, err error) {
+	data, err := k.tbl.Get(args.Key)
+	exist := err == nil
+	switch {
+	case err == kvstore.ErrNotFound:
+	case err != nil:
+		return 0, nil, err
+	case len(data) != counterLen:
+		return 0, errors.ErrIllegalArguments, nil
+	default:
+		value = int64(binary.BigEndian.Uint64(data))
+	}
+
+	var (
+		puts    []kvstore.KV
+		deletes []string
+	)
+	if args.Token != "" {
+		tokenKey := incrTokenKey(args.Key, args.Token)
+		_, err = k.tbl.Get(tokenKey)
+		if err != nil && err != kvstore.ErrNotFound {
+			return 0, nil, err
+		}
+		if recorded := err == nil; args.Delta == 0 || recorded == (args.Delta > 0) {
+			return value, nil, nil
+		}
+		if args.Delta > 0 {
+			puts = append(puts, kvstore.KV{Key: []byte(tokenKey), Value: []byte{1}})
+		} else {
+			deletes = append(deletes, tokenKey)
+		}
+	}
+	if !exist && args.MustExist {
+		return 0, errors.ErrKvNotFound, nil
+	}
+
+	value += args.Delta
+	if value < 0 || (args.Max > 0 && value > args.Max) {
+		return value - args.Delta, errors.ErrKvCounterExceedLimit, nil
+	}
+	if value == 0 {
+		deletes = append(deletes, args.Key)
+	} else {
+		data = make([]byte, counterLen)
+		binary.BigEndian.PutUint64(data, uint64(value))
+		puts = append(puts, kvstore.KV{Key: []byte(args.Key), Value: data})
+	}
+	return value, nil, k.tbl.Update(puts, deletes)
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kvmgr
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
+	"github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/testing/mocks"
+)
+
+func TestKvMgr(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "kv")
+	require.NoError(t, err)
+	defer os.RemoveAll(testDir)
+
+	normalDB, err := normaldb.OpenNormalDB(testDir, false, nil)
+	require.NoError(t, err)
+	defer normalDB.Close()
+
+	span, ctx := trace.StartSpanFromContext(context.Background(), "")
+	mgr := New(normalDB)
+	mgr.SetModuleName("KvMgr")
+	require.Equal(t, "KvMgr", mgr.GetModuleName())
+	require.NoError(t, mgr.LoadData(ctx))
+	require.NoError(t, mgr.Flush(ctx))
+	mgr.NotifyLeaderChange(ctx, 0, "")
+
+	// apply the proposed data
+	mockRaftServer := mocks.NewMockRaftServer(gomock.NewController(t))
+	mockRaftServer.EXPECT().Propose(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+		func(ctx context.Context, data []byte) error {
+			info := base.DecodeProposeInfo(data)
+			require.Equal(t, mgr.GetModuleName(), info.Module)
+			return mgr.Apply(ctx, []int32{info.OperType}, [][]byte{info.Data}, []base.ProposeContext{info.Context})
+		})
+	mgr.SetRaftServer(mockRaftServer)
+
+	kvs := []clustermgr.KeyValue{
+		{Key: "a/1", Value: []byte("1")},
+		{Key: "a/2", Value: []byte("2")},
+		{Key: "a/3", Value: []byte("3")},
+		{Key: "b/1", Value: []byte("4")},
+	}
+	require.NoError(t, mgr.Set(ctx, &clustermgr.SetKvArgs{Kvs: kvs}))
+	value, err := mgr.Get(ctx, "a/2")
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	_, err = mgr.Get(ctx, "c")
+	require.Equal(t, errors.ErrKvNotFound, err)
+
+	ret, err := mgr.List(ctx, &clustermgr.ListKvArgs{Prefix: "a/", Count: 2})
+	require.NoError(t, err)
+	require.Equal(t, kvs[:2], ret.Kvs)
+	require.Equal(t, "a/2", ret.Marker)
+	ret, err = mgr.List(ctx, &clustermgr.ListKvArgs{Prefix: "a/", Marker: ret.Marker, Count: 2})
+	require.NoError(t, err)
+	require.Equal(t, kvs[2:3], ret.Kvs)
+	require.Equal(t, "", ret.Marker)
+
+	require.NoError(t, mgr.Delete(ctx, &clustermgr.DeleteKvArgs{Keys: []string{"a/1", "b/1"}}))
+	ret, err = mgr.List(ctx, &clustermgr.ListKvArgs{})
+	require.NoError(t, err)
+	require.Equal(t, kvs[1:3], ret.Kvs)
+
+	// counter
+	_, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: 1, MustExist: true})
+	require.Equal(t, errors.ErrKvNotFound, err)
+	n, err := mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: 5, Max: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+	n, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: 6, Max: 10})
+	require.Equal(t, errors.ErrKvCounterExceedLimit, err)
+	require.Equal(t, int64(5), n)
+	_, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: -6})
+	require.Equal(t, errors.ErrKvCounterExceedLimit, err)
+	n, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: -5})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+	_, err = mgr.Get(ctx, "cnt")
+	require.Equal(t, errors.ErrKvNotFound, err)
+	_, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "a/2", Delta: 1})
+	require.Equal(t, errors.ErrIllegalArguments, err)
+
+	// idempotent counter with token
+	for ii := 0; ii < 2; ii++ {
+		n, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: 2, Token: "x"})
+		require.NoError(t, err)
+		require.Equal(t, int64(2), n)
+	}
+	n, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: 3, Token: "y"})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+	for ii := 0; ii < 2; ii++ {
+		n, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: -2, MustExist: true, Token: "x"})
+		require.NoError(t, err)
+		require.Equal(t, int64(3), n)
+	}
+	n, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: -3, MustExist: true, Token: "y"})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+	n, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: -3, MustExist: true, Token: "y"})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+	// rejected incr does not record the token
+	_, err = mgr.Incr(ctx, &clustermgr.IncrKvArgs{Key: "cnt", Delta: 2, Max: 1, Token: "z"})
+	require.Equal(t, errors.ErrKvCounterExceedLimit, err)
+	ret, err = mgr.List(ctx, &clustermgr.ListKvArgs{Prefix: clustermgr.IncrKvTokenPrefix})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ret.Kvs))
+	_, err = mgr.Get(ctx, "cnt")
+	require.Equal(t, errors.ErrKvNotFound, err)
+
+	// invalid data and operation
+	for _, operType := range []int32{OperTypeSetKv, OperTypeDeleteKv, OperTypeIncrKv} {
+		err = mgr.Apply(ctx, []int32{operType}, [][]byte{[]byte("-1")},
+			[]base.ProposeContext{{ReqID: span.TraceID()}})
+		require.Error(t, err)
+	}
+	err = mgr.Apply(ctx, []int32{10}, [][]byte{[]byte("{}")},
+		[]base.ProposeContext{{ReqID: span.TraceID()}})
+	require.Error(t, err)
+}
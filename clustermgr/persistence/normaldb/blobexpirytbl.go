@@ -0,0 +1,180 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package normaldb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+const (
+	blobExpiryKeyLen      = 8 + 4 + 8 + 4
+	blobExpiryIndexKeyLen = 4 + 8 + 4
+)
+
+type BlobExpiryRecord struct {
+	Vid      proto.Vid         `json:"vid"`
+	MinBid   proto.BlobID      `json:"min_bid"`
+	Count    uint32            `json:"count"`
+	ExpireAt int64             `json:"expire_at"`
+	Packed   *proto.PackedBlob `json:"packed,omitempty"`
+	Owner    string            `json:"owner,omitempty"`
+}
+
+// BlobExpiryTable records sorted by expiry, key is
+// expire_at(8) + vid(4) + min_bid(8) + packed index(4),
+// and expire_at of the blobs indexed by vid(4) + min_bid(8) + packed index(4).
+type BlobExpiryTable struct {
+	tbl      kvstore.KVTable
+	indexTbl kvstore.KVTable
+}
+
+func OpenBlobExpiryTable(db *NormalDB) *BlobExpiryTable {
+	return &BlobExpiryTable{tbl: db.Table(blobExpiryCF), indexTbl: db.Table(blobExpiryIndexCF)}
+}
+
+// Put records, the old record of the blobs is replaced
+func (b *BlobExpiryTable) Put(records []BlobExpiryRecord) error {
+	olds, err := b.indexed(records)
+	if err != nil {
+		return err
+	}
+	staleKeys := make([][]byte, 0)
+	for i := range olds {
+		if olds[i].ExpireAt != 0 && olds[i].ExpireAt != records[i].ExpireAt {
+			staleKeys = append(staleKeys, encodeBlobExpiryKey(&olds[i]))
+		}
+	}
+	if len(staleKeys) > 0 {
+		if err = b.tbl.DeleteBatch(staleKeys, false); err != nil {
+			return err
+		}
+	}
+
+	kvs := make([]kvstore.KV, 0, len(records))
+	indexKvs := make([]kvstore.KV, 0, len(records))
+	for i := range records {
+		data, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		}
+		kvs = append(kvs, kvstore.KV{Key: encodeBlobExpiryKey(&records[i]), Value: data})
+		expireAt := make([]byte, 8)
+		binary.BigEndian.PutUint64(expireAt, uint64(records[i].ExpireAt))
+		indexKvs = append(indexKvs, kvstore.KV{Key: encodeBlobExpiryIndexKey(&records[i]), Value: expireAt})
+	}
+	if err = b.tbl.WriteBatch(kvs, false); err != nil {
+		return err
+	}
+	return b.indexTbl.WriteBatch(indexKvs, false)
+}
+
+// Delete records of the blobs, ExpireAt of the record
+// may be 0 which is resolved by the index of blobs.
+func (b *BlobExpiryTable) Delete(records []BlobExpiryRecord) error {
+	olds, err := b.indexed(records)
+	if err != nil {
+		return err
+	}
+	keys := make([][]byte, 0, len(records))
+	indexKeys := make([][]byte, 0, len(records))
+	for i := range records {
+		if records[i].ExpireAt != 0 {
+			keys = append(keys, encodeBlobExpiryKey(&records[i]))
+		}
+		if olds[i].ExpireAt != 0 && olds[i].ExpireAt != records[i].ExpireAt {
+			keys = append(keys, encodeBlobExpiryKey(&olds[i]))
+		}
+		indexKeys = append(indexKeys, encodeBlobExpiryIndexKey(&records[i]))
+	}
+	if err = b.tbl.DeleteBatch(keys, false); err != nil {
+		return err
+	}
+	return b.indexTbl.DeleteBatch(indexKeys, false)
+}
+
+// indexed returns copy of the records with indexed ExpireAt, 0 if not indexed
+func (b *BlobExpiryTable) indexed(records []BlobExpiryRecord) ([]BlobExpiryRecord, error) {
+	olds := make([]BlobExpiryRecord, len(records))
+	for i := range records {
+		olds[i] = records[i]
+		olds[i].ExpireAt = 0
+		value, err := b.indexTbl.Get(encodeBlobExpiryIndexKey(&records[i]))
+		if err == kvstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(value) == 8 {
+			olds[i].ExpireAt = int64(binary.BigEndian.Uint64(value))
+		}
+	}
+	return olds, nil
+}
+
+// ListExpired returns at most count records expired before the timestamp
+func (b *BlobExpiryTable) ListExpired(before int64, count int) ([]BlobExpiryRecord, error) {
+	iter := b.tbl.NewIterator(nil)
+	defer iter.Close()
+
+	records := make([]BlobExpiryRecord, 0, 16)
+	for iter.SeekToFirst(); iter.Valid() && len(records) < count; iter.Next() {
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+		key := iter.Key().Data()
+		expired := len(key) == blobExpiryKeyLen && int64(binary.BigEndian.Uint64(key)) < before
+		if !expired {
+			iter.Key().Free()
+			iter.Value().Free()
+			break
+		}
+
+		var record BlobExpiryRecord
+		err := json.Unmarshal(iter.Value().Data(), &record)
+		iter.Key().Free()
+		iter.Value().Free()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func encodeBlobExpiryIndexKey(record *BlobExpiryRecord) []byte {
+	key := make([]byte, blobExpiryIndexKeyLen)
+	binary.BigEndian.PutUint32(key, uint32(record.Vid))
+	binary.BigEndian.PutUint64(key[4:], uint64(record.MinBid))
+	if record.Packed != nil {
+		binary.BigEndian.PutUint32(key[12:], record.Packed.Index+1)
+	}
+	return key
+}
+
+func encodeBlobExpiryKey(record *BlobExpiryRecord) []byte {
+	key := make([]byte, blobExpiryKeyLen)
+	binary.BigEndian.PutUint64(key, uint64(record.ExpireAt))
+	binary.BigEndian.PutUint32(key[8:], uint32(record.Vid))
+	binary.BigEndian.PutUint64(key[12:], uint64(record.MinBid))
+	if record.Packed != nil {
+		binary.BigEndian.PutUint32(key[20:], record.Packed.Index+1)
+	}
+	return key
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package normaldb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/common/kvstore"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func TestBlobExpiryTbl(t *testing.T) {
+	testDir, _ := ioutil.TempDir("", "blobexpiry")
+	defer os.RemoveAll(testDir)
+	db, err := OpenNormalDB(testDir, false, &kvstore.RocksDBOption{ReadOnly: false})
+	require.NoError(t, err)
+	defer db.Close()
+
+	tbl := OpenBlobExpiryTable(db)
+	records := []BlobExpiryRecord{
+		{Vid: 1, MinBid: 100, Count: 10, ExpireAt: 300},
+		{Vid: 2, MinBid: 200, Count: 1, ExpireAt: 100},
+		{Vid: 2, MinBid: 200, Count: 1, ExpireAt: 100, Packed: &proto.PackedBlob{Index: 0, Count: 2}},
+		{Vid: 2, MinBid: 200, Count: 1, ExpireAt: 100, Packed: &proto.PackedBlob{Index: 1, Count: 2}},
+		{Vid: 1, MinBid: 300, Count: 2, ExpireAt: 200},
+	}
+	require.NoError(t, tbl.Put(records))
+
+	expired, err := tbl.ListExpired(100, 10)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(expired))
+
+	expired, err = tbl.ListExpired(101, 10)
+	require.NoError(t, err)
+	require.Equal(t, records[1:4], expired)
+
+	expired, err = tbl.ListExpired(1000, 4)
+	require.NoError(t, err)
+	require.Equal(t, append(records[1:4:4], records[4]), expired)
+
+	require.NoError(t, tbl.Delete(records[1:3]))
+	expired, err = tbl.ListExpired(1000, 10)
+	require.NoError(t, err)
+	require.Equal(t, []BlobExpiryRecord{records[3], records[4], records[0]}, expired)
+
+	// delete without expiry, resolved by the index
+	noExpiry := records[3]
+	noExpiry.ExpireAt = 0
+	require.NoError(t, tbl.Delete([]BlobExpiryRecord{noExpiry}))
+	expired, err = tbl.ListExpired(1000, 10)
+	require.NoError(t, err)
+	require.Equal(t, []BlobExpiryRecord{records[4], records[0]}, expired)
+
+	// the old record of blobs is replaced
+	renewed := records[0]
+	renewed.ExpireAt = 50
+	require.NoError(t, tbl.Put([]BlobExpiryRecord{renewed}))
+	expired, err = tbl.ListExpired(1000, 10)
+	require.NoError(t, err)
+	require.Equal(t, []BlobExpiryRecord{renewed, records[4]}, expired)
+	renewed.ExpireAt = 0
+	require.NoError(t, tbl.Delete([]BlobExpiryRecord{renewed}))
+	expired, err = tbl.ListExpired(1000, 10)
+	require.NoError(t, err)
+	require.Equal(t, []BlobExpiryRecord{records[4]}, expired)
+}
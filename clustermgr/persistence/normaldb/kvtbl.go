@@ -0,0 +1,94 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package normaldb
+
+import (
+	"github.com/cubefs/blobstore/common/kvstore"
+)
+
+// KvTable generic key value records shared by services
+type KvTable struct {
+	tbl kvstore.KVTable
+}
+
+func OpenKvTable(db *NormalDB) *KvTable {
+	return &KvTable{db.Table(kvCF)}
+}
+
+// Get returns kvstore.ErrNotFound if key not exists
+func (k *KvTable) Get(key string) ([]byte, error) {
+	return k.tbl.Get([]byte(key))
+}
+
+func (k *KvTable) Put(kvs []kvstore.KV) error {
+	return k.tbl.WriteBatch(kvs, false)
+}
+
+func (k *KvTable) Delete(keys []string) error {
+	bkeys := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		bkeys = append(bkeys, []byte(key))
+	}
+	return k.tbl.DeleteBatch(bkeys, false)
+}
+
+// Update puts and deletes keys atomically
+func (k *KvTable) Update(puts []kvstore.KV, deletes []string) error {
+	batch := k.tbl.NewWriteBatch()
+	defer batch.Destroy()
+	for _, kv := range puts {
+		batch.PutCF(k.tbl.GetCf(), kv.Key, kv.Value)
+	}
+	for _, key := range deletes {
+		batch.DeleteCF(k.tbl.GetCf(), []byte(key))
+	}
+	return k.tbl.DoBatch(batch)
+}
+
+// List returns at most count records with prefix after marker,
+// and the marker of next page which is empty if no more
+func (k *KvTable) List(prefix, marker string, count int) (kvs []kvstore.KV, next string, err error) {
+	iter := k.tbl.NewIterator(nil)
+	defer iter.Close()
+
+	start := prefix
+	if marker > start {
+		start = marker
+	}
+	kvs = make([]kvstore.KV, 0, 16)
+	for iter.Seek([]byte(start)); iter.ValidForPrefix([]byte(prefix)); iter.Next() {
+		if err = iter.Err(); err != nil {
+			return nil, "", err
+		}
+		key := string(iter.Key().Data())
+		if key == marker {
+			iter.Key().Free()
+			iter.Value().Free()
+			continue
+		}
+		if len(kvs) >= count {
+			iter.Key().Free()
+			iter.Value().Free()
+			next = string(kvs[len(kvs)-1].Key)
+			break
+		}
+		value := make([]byte, iter.Value().Size())
+		copy(value, iter.Value().Data())
+		iter.Key().Free()
+		iter.Value().Free()
+		kvs = append(kvs, kvstore.KV{Key: []byte(key), Value: value})
+	}
+	return kvs, next, nil
+}
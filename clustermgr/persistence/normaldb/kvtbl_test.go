@@ -0,0 +1,66 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package normaldb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/common/kvstore"
+)
+
+func TestKvTbl(t *testing.T) {
+	testDir, _ := ioutil.TempDir("", "kv")
+	defer os.RemoveAll(testDir)
+	db, err := OpenNormalDB(testDir, false, &kvstore.RocksDBOption{ReadOnly: false})
+	require.NoError(t, err)
+	defer db.Close()
+
+	tbl := OpenKvTable(db)
+	kvs := []kvstore.KV{
+		{Key: []byte("a"), Value: []byte("0")},
+		{Key: []byte("p/1"), Value: []byte("1")},
+		{Key: []byte("p/2"), Value: []byte("2")},
+		{Key: []byte("p/3"), Value: []byte("3")},
+		{Key: []byte("q"), Value: []byte("4")},
+	}
+	require.NoError(t, tbl.Put(kvs))
+
+	value, err := tbl.Get("p/2")
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+
+	ret, next, err := tbl.List("p/", "", 2)
+	require.NoError(t, err)
+	require.Equal(t, kvs[1:3], ret)
+	require.Equal(t, "p/2", next)
+	ret, next, err = tbl.List("p/", next, 2)
+	require.NoError(t, err)
+	require.Equal(t, kvs[3:4], ret)
+	require.Equal(t, "", next)
+	ret, _, err = tbl.List("", "", 10)
+	require.NoError(t, err)
+	require.Equal(t, kvs, ret)
+
+	require.NoError(t, tbl.Delete([]string{"p/2", "q"}))
+	_, err = tbl.Get("q")
+	require.Equal(t, kvstore.ErrNotFound, err)
+	ret, _, err = tbl.List("p/", "", 10)
+	require.NoError(t, err)
+	require.Equal(t, []kvstore.KV{kvs[1], kvs[3]}, ret)
+}
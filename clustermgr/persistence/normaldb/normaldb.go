@@ -22,6 +22,9 @@ var (
 	configCF           = "config"
 	diskDropCF         = "disk_drop"
 	serviceCF          = "service"
+	blobExpiryCF       = "blob_expiry"
+	blobExpiryIndexCF  = "blob_expiry_index"
+	kvCF               = "kv"
 	diskStatusIndexCF  = "disk-status"
 	diskHostIndexCF    = "disk-host"
 	diskIDCIndexCF     = "disk-idc"
@@ -33,6 +36,9 @@ var (
 		diskDropCF,
 		configCF,
 		serviceCF,
+		blobExpiryCF,
+		blobExpiryIndexCF,
+		kvCF,
 		diskStatusIndexCF,
 		diskHostIndexCF,
 		diskIDCIndexCF,
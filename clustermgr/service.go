@@ -39,7 +39,7 @@ func (s *Service) ServiceGet(c *rpc.Context) {
 	}
 	span.Infof("accept ServiceGet request, args: %v", args)
 
-	if err := s.raftNode.ReadIndex(ctx); err != nil {
+	if err := s.readIndex(c); err != nil {
 		span.Errorf("read index error: %v", err)
 		c.RespondError(apierrors.ErrRaftReadIndex)
 		return
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/watchmgr"
 	"github.com/cubefs/blobstore/common/raftserver"
 	"github.com/cubefs/blobstore/common/trace"
 	"github.com/cubefs/blobstore/util/errors"
@@ -37,7 +38,10 @@ var applyTaskPool = taskpool.New(5, 5)
 func (s *Service) ApplyMemberChange(cc raftserver.ConfChange, index uint64) error {
 	// record apply index and flush all memory data
 	_, ctx := trace.StartSpanFromContext(context.Background(), "")
+	s.applyLock.Lock()
+	defer s.applyLock.Unlock()
 	s.raftNode.RecordApplyIndex(ctx, index, true)
+	s.WatchMgr.Append(index, nil)
 	return nil
 }
 
@@ -48,6 +52,9 @@ func (s *Service) Apply(data [][]byte, index uint64) error {
 		span, ctx = trace.StartSpanFromContext(context.Background(), "")
 	)
 
+	s.applyLock.Lock()
+	defer s.applyLock.Unlock()
+
 	start := time.Now()
 	// 1. decode all propose data and gather by module
 	moduleOperTypes := make(map[string][]int32)
@@ -67,7 +74,8 @@ func (s *Service) Apply(data [][]byte, index uint64) error {
 	decodeCost := time.Since(start)
 	start = time.Now()
 
-	// 2. call module applies's Apply method
+	// 2. call module applies's Apply method, events are recorded by successful applies
+	ctx, recorder := watchmgr.WithRecorder(ctx)
 	wg := sync.WaitGroup{}
 	wg.Add(len(moduleOperTypes))
 	errs = make([]error, len(moduleOperTypes))
@@ -100,6 +108,9 @@ func (s *Service) Apply(data [][]byte, index uint64) error {
 		span.Error(errors.Detail(err))
 		return err
 	}
+
+	// 4. record change events for watchers
+	s.WatchMgr.Append(index, recorder.Events())
 	span.Infof("state machine apply, total data: %d, decode cost: %dus, module apply cost: %dus, record apply index cost: %dus",
 		len(data), decodeCost/time.Microsecond, moduleApplyCost/time.Microsecond, time.Since(start)/time.Microsecond)
 
@@ -135,6 +146,7 @@ func (s *Service) ApplySnapshot(meta raftserver.SnapshotMeta, st raftserver.Snap
 		span.Errorf("apply raft snapshot record apply index failed, err: %v", err)
 		return err
 	}
+	s.WatchMgr.Reset(meta.Index)
 	atomic.StoreUint32(&s.status, ServiceStatusNormal)
 	return nil
 }
@@ -32,12 +32,15 @@ import (
 	"github.com/cubefs/blobstore/clustermgr/base"
 	"github.com/cubefs/blobstore/clustermgr/configmgr"
 	"github.com/cubefs/blobstore/clustermgr/diskmgr"
+	"github.com/cubefs/blobstore/clustermgr/expirymgr"
+	"github.com/cubefs/blobstore/clustermgr/kvmgr"
 	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
 	"github.com/cubefs/blobstore/clustermgr/persistence/raftdb"
 	"github.com/cubefs/blobstore/clustermgr/persistence/volumedb"
 	"github.com/cubefs/blobstore/clustermgr/scopemgr"
 	"github.com/cubefs/blobstore/clustermgr/servicemgr"
 	"github.com/cubefs/blobstore/clustermgr/volumemgr"
+	"github.com/cubefs/blobstore/clustermgr/watchmgr"
 	"github.com/cubefs/blobstore/cmd"
 	"github.com/cubefs/blobstore/common/codemode"
 	"github.com/cubefs/blobstore/common/config"
@@ -74,6 +77,8 @@ const (
 	defaultHeartbeatNotifyIntervalS = 10
 	defaultMaxHeartbeatNotifyNum    = 2000
 	defaultMetricReportIntervalM    = 2
+	defaultStaleReadMaxDelayMs      = 3000
+	defaultWatchEventsCapacity      = 100000
 )
 
 var (
@@ -100,6 +105,11 @@ type Config struct {
 	MaxHeartbeatNotifyNum    int                       `json:"max_heartbeat_notify_num"`
 	ChunkSize                uint64                    `json:"chunk_size"`
 	MetricReportIntervalM    int                       `json:"metric_report_interval_m"`
+	// StaleReadMaxDelayMs is the max delay since the latest leader read index
+	// that member allows when serving stale read
+	StaleReadMaxDelayMs int64 `json:"stale_read_max_delay_ms"`
+	// WatchEventsCapacity is the max number of change events kept in memory for watchers
+	WatchEventsCapacity int `json:"watch_events_capacity"`
 
 	cmd.Config
 }
@@ -116,21 +126,27 @@ type Service struct {
 	ConfigMgr  *configmgr.ConfigMgr
 	ScopeMgr   *scopemgr.ScopeMgr
 	ServiceMgr *servicemgr.ServiceMgr
+	ExpiryMgr  *expirymgr.ExpiryMgr
+	KvMgr      *kvmgr.KvMgr
 	// Note: DiskMgr should always list before volumeMgr
 	// cause DiskMgr applier LoadData should be call first, or VolumeMgr LoadData may return error with disk not found
 	DiskMgr   *diskmgr.DiskMgr
 	VolumeMgr *volumemgr.VolumeMgr
+	WatchMgr  *watchmgr.WatchMgr
 
 	dbs map[string]base.SnapshotDB
 	// status indicate service's current state, like normal/snapshot
 	status uint32
 	// electedLeaderReadIndex indicate that service(elected leader) should execute ReadIndex or not before accept incoming request
 	electedLeaderReadIndex uint32
-	raftNode               *base.RaftNode
-	raftStartOnce          sync.Once
-	raftStartCh            chan interface{}
-	closeCh                chan interface{}
-	consulClient           *api.Client
+	// readIndexTime is the unix nano when the latest succeeded read index started
+	readIndexTime int64
+	raftNode      *base.RaftNode
+	applyLock     sync.Mutex
+	raftStartOnce sync.Once
+	raftStartCh   chan interface{}
+	closeCh       chan interface{}
+	consulClient  *api.Client
 	*Config
 }
 
@@ -166,15 +182,19 @@ func tearDown() {
 }
 
 func New(cfg *Config) (*Service, error) {
-	if err := cfg.checkAndFix(); err != nil {
-		log.Fatalf(fmt.Sprint("clusterMgr service config check failed => ", errors.Detail(err)))
-	}
-
 	// db initial: normal/volume/raft
 	normalDB, err := normaldb.OpenNormalDB(cfg.NormalDBPath, false, &cfg.NormalDBOption)
 	if err != nil {
 		log.Fatalf("open normal database failed, err: %v", err)
 	}
+	// runtime code modes may be used by code mode policies
+	if err = configmgr.LoadCodeModes(normalDB); err != nil {
+		log.Fatalf("load registered code modes failed, err: %v", err)
+	}
+	if err = cfg.checkAndFix(); err != nil {
+		log.Fatalf(fmt.Sprint("clusterMgr service config check failed => ", errors.Detail(err)))
+	}
+
 	volumeDB, err := volumedb.Open(cfg.VolumeMgrConfig.VolumeDBPath, false, &cfg.VolumeMgrConfig.VolumeDBOption)
 	if err != nil {
 		log.Fatalf("open volume database failed, err: %v", err)
@@ -228,6 +248,8 @@ func New(cfg *Config) (*Service, error) {
 	service.DiskMgr = diskMgr
 	service.ServiceMgr = serviceMgr
 	service.ScopeMgr = scopeMgr
+	service.ExpiryMgr = expirymgr.New(normalDB)
+	service.KvMgr = kvmgr.New(normalDB)
 
 	// raft server initial
 	applyIndex := uint64(0)
@@ -245,6 +267,8 @@ func New(cfg *Config) (*Service, error) {
 	if err != nil {
 		log.Fatalf("new raft node failed, err: %v", err)
 	}
+	// changes applied before are unknown to watchers
+	service.WatchMgr = watchmgr.New(cfg.WatchEventsCapacity, applyIndex)
 	// register all mgr's apply method
 	raftNode.RegistRaftApplier(service)
 	service.raftNode = raftNode
@@ -262,6 +286,8 @@ func New(cfg *Config) (*Service, error) {
 	scopeMgr.SetRaftServer(raftServer)
 	volumeMgr.SetRaftServer(raftServer)
 	configMgr.SetRaftServer(raftServer)
+	service.ExpiryMgr.SetRaftServer(raftServer)
+	service.KvMgr.SetRaftServer(raftServer)
 
 	// wait for raft start
 	service.waitForRaftStart()
@@ -298,6 +324,41 @@ func (s *Service) Handler(w http.ResponseWriter, req *http.Request, f func(http.
 	f(w, req)
 }
 
+// readIndex makes sure that read request sees the latest committed data by raft read index.
+// When client opts into stale read, member serves the read with local data if it has applied
+// the leader's read index got within StaleReadMaxDelayMs, so the data is stale no more than that,
+// and the applied index is reported to client by response header
+func (s *Service) readIndex(c *rpc.Context) error {
+	ctx := c.Request.Context()
+	if c.Request.Header.Get(clustermgr.HeaderStaleRead) == "" {
+		return s.doReadIndex(ctx)
+	}
+
+	maxDelay := time.Duration(s.StaleReadMaxDelayMs) * time.Millisecond
+	if time.Since(time.Unix(0, atomic.LoadInt64(&s.readIndexTime))) > maxDelay {
+		if err := s.doReadIndex(ctx); err != nil {
+			return err
+		}
+	}
+	applied := s.raftNode.Status().Applied
+	c.Writer.Header().Set(clustermgr.HeaderAppliedIndex, strconv.FormatUint(applied, 10))
+	return nil
+}
+
+// doReadIndex waits for applying leader's commit index, and records when it started
+func (s *Service) doReadIndex(ctx context.Context) error {
+	start := time.Now().UnixNano()
+	if err := s.raftNode.ReadIndex(ctx); err != nil {
+		return err
+	}
+	for {
+		last := atomic.LoadInt64(&s.readIndexTime)
+		if last >= start || atomic.CompareAndSwapInt64(&s.readIndexTime, last, start) {
+			return nil
+		}
+	}
+}
+
 func (s *Service) Close() {
 	// 1. close service loop
 	close(s.closeCh)
@@ -350,6 +411,12 @@ func (c *Config) checkAndFix() (err error) {
 	if c.ChunkSize == 0 {
 		c.ChunkSize = DefaultChunkSize
 	}
+	if c.StaleReadMaxDelayMs <= 0 {
+		c.StaleReadMaxDelayMs = defaultStaleReadMaxDelayMs
+	}
+	if c.WatchEventsCapacity <= 0 {
+		c.WatchEventsCapacity = defaultWatchEventsCapacity
+	}
 	if c.ClusterCfg[proto.VolumeReserveSizeKey] == nil {
 		c.ClusterCfg[proto.VolumeReserveSizeKey] = DefaultVolumeReserveSize
 	}
@@ -22,7 +22,9 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -179,6 +181,54 @@ func TestManage(t *testing.T) {
 	}
 }
 
+func TestStaleRead(t *testing.T) {
+	testService := initTestService(t)
+	defer clear(testService)
+	defer testService.Close()
+	ph := rpc.DefaultRouter.Router.PanicHandler
+	rpc.DefaultRouter = rpc.New()
+	rpc.DefaultRouter.Router.PanicHandler = ph
+	server := httptest.NewServer(NewHandler(testService))
+	defer server.Close()
+
+	_, ctx := trace.StartSpanFromContext(context.Background(), "")
+	staleClient := clustermgr.New(&clustermgr.Config{
+		LbConfig:  rpc.LbConfig{Hosts: []string{server.URL}},
+		StaleRead: true,
+	})
+	_, err := staleClient.ListDisk(ctx, &clustermgr.ListOptionArgs{Count: 10})
+	assert.NoError(t, err)
+
+	// applied index is reported only when client opts into stale read
+	resp, err := http.Get(server.URL + "/disk/list?count=10")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "", resp.Header.Get(clustermgr.HeaderAppliedIndex))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/disk/list?count=10", nil)
+	assert.NoError(t, err)
+	req.Header.Set(clustermgr.HeaderStaleRead, "1")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	applied, err := strconv.ParseUint(resp.Header.Get(clustermgr.HeaderAppliedIndex), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, testService.raftNode.Status().Applied, applied)
+
+	// served locally within max delay since the latest read index
+	readIndexTime := atomic.LoadInt64(&testService.readIndexTime)
+	assert.NotEqual(t, int64(0), readIndexTime)
+	_, err = staleClient.ListDisk(ctx, &clustermgr.ListOptionArgs{Count: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, readIndexTime, atomic.LoadInt64(&testService.readIndexTime))
+
+	atomic.StoreInt64(&testService.readIndexTime, time.Now().Add(-time.Minute).UnixNano())
+	_, err = staleClient.ListDisk(ctx, &clustermgr.ListOptionArgs{Count: 10})
+	assert.NoError(t, err)
+	assert.Less(t, readIndexTime, atomic.LoadInt64(&testService.readIndexTime))
+}
+
 type mockWriter struct{}
 
 func (m *mockWriter) Write(data []byte) (int, error) {
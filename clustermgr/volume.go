@@ -46,7 +46,7 @@ func (s *Service) VolumeGet(c *rpc.Context) {
 	}
 	span.Infof("accept VolumeGet request, args: %v", args)
 
-	if err := s.raftNode.ReadIndex(ctx); err != nil {
+	if err := s.readIndex(c); err != nil {
 		span.Errorf("read index error: %v", err)
 		c.RespondError(apierrors.ErrRaftReadIndex)
 		return
@@ -71,7 +71,7 @@ func (s *Service) VolumeList(c *rpc.Context) {
 	}
 	span.Infof("accept VolumeList request, args: %v", args)
 
-	if err := s.raftNode.ReadIndex(ctx); err != nil {
+	if err := s.readIndex(c); err != nil {
 		span.Errorf("read index error: %v", err)
 		c.RespondError(apierrors.ErrRaftReadIndex)
 		return
@@ -240,7 +240,7 @@ func (s *Service) VolumeLock(c *rpc.Context) {
 	}
 	span.Infof("accept VolumeLock request, args: %v", args)
 
-	c.RespondError(s.VolumeMgr.LockVolume(ctx, args.Vid))
+	c.RespondError(s.VolumeMgr.LockVolume(ctx, args))
 }
 
 func (s *Service) VolumeUnlock(c *rpc.Context) {
@@ -193,7 +193,18 @@ func (a *volumeAllocator) VolumeStatusActiveCallback(ctx context.Context, vol *v
 
 // volume status change event callback, lock change should delete from volume allocator's idle head
 func (a *volumeAllocator) VolumeStatusLockCallback(ctx context.Context, vol *volume) error {
+	span := trace.SpanFromContextSafe(ctx)
 	a.idles[vol.volInfoBase.CodeMode].delete(vol.vid)
+
+	// an active volume locked by its allocator should not be allocated any more
+	if vol.token != nil {
+		host, _, err := decodeToken(vol.token.tokenID)
+		if err != nil {
+			span.Errorf("decode token error,%s", vol.token.String())
+			return err
+		}
+		a.removeAllocatedVolumes(vol.vid, host)
+	}
 	return nil
 }
 
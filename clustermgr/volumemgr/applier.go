@@ -95,6 +95,7 @@ type ChangeVolStatusCtx struct {
 	Vid      proto.Vid           `json:"vid"`
 	TaskID   string              `json:"task_id"`
 	TaskType base.VolumeTaskType `json:"type"`
+	Token    string              `json:"token,omitempty"`
 }
 
 type allocVolumeUnitCtx struct {
@@ -199,7 +200,7 @@ func (v *VolumeMgr) Apply(ctx context.Context, operTypes []int32, datas [][]byte
 				continue
 			}
 			v.applyTaskPool.Run(v.getTaskIdx(args.Vid), func() {
-				if err = v.applyVolumeTask(taskCtx, args.Vid, args.TaskID, args.TaskType); err != nil {
+				if err = v.applyVolumeTask(taskCtx, args.Vid, args.TaskID, args.TaskType, args.Token); err != nil {
 					errs[idx] = errors.Info(err, "apply change volume status failed, args: ", args).Detail(err)
 				}
 				wg.Done()
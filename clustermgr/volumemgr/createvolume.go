@@ -26,6 +26,7 @@ import (
 	"github.com/cubefs/blobstore/clustermgr/base"
 	"github.com/cubefs/blobstore/clustermgr/diskmgr"
 	"github.com/cubefs/blobstore/clustermgr/persistence/volumedb"
+	"github.com/cubefs/blobstore/clustermgr/watchmgr"
 	"github.com/cubefs/blobstore/common/codemode"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/trace"
@@ -196,7 +197,16 @@ func (v *VolumeMgr) applyInitCreateVolume(ctx context.Context, vol *volume) erro
 }
 
 func (v *VolumeMgr) applyIncreaseVolumeUnitsEpoch(ctx context.Context, units []*volumedb.VolumeUnitRecord) error {
-	return v.transitedTbl.PutVolumeUnits(units)
+	if err := v.transitedTbl.PutVolumeUnits(units); err != nil {
+		return err
+	}
+	for _, unit := range units {
+		vuid := proto.EncodeVuid(unit.VuidPrefix, unit.Epoch)
+		watchmgr.Record(ctx, clustermgr.WatchEvent{
+			Type: clustermgr.WatchEventVolumeUnit, Vid: vuid.Vid(), Vuid: vuid, DiskID: unit.DiskID,
+		})
+	}
+	return nil
 }
 
 func (v *VolumeMgr) applyCreateVolume(ctx context.Context, vol *volume) error {
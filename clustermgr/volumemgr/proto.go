@@ -119,8 +119,16 @@ func (vol *volume) canRetain(freezeThreshold uint64, retainThreshold int) bool {
 	return false
 }
 
-func (vol *volume) canLock() bool {
-	return vol.getStatus() == proto.VolumeStatusIdle
+// canLock return true if volume is idle, or active and allocated with the token
+func (vol *volume) canLock(token string) bool {
+	switch vol.getStatus() {
+	case proto.VolumeStatusIdle:
+		return true
+	case proto.VolumeStatusActive:
+		return token != "" && vol.token != nil && vol.token.tokenID == token
+	default:
+		return false
+	}
 }
 
 func (vol *volume) canUnlock() bool {
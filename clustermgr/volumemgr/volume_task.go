@@ -120,7 +120,7 @@ func (m *VolumeMgr) setVolumeStatus(task *volTask) error {
 	return nil
 }
 
-func (m *VolumeMgr) applyVolumeTask(ctx context.Context, vid proto.Vid, taskID string, t base.VolumeTaskType) error {
+func (m *VolumeMgr) applyVolumeTask(ctx context.Context, vid proto.Vid, taskID string, t base.VolumeTaskType, token string) error {
 	// get volume from cache
 	span := trace.SpanFromContextSafe(ctx)
 	vol := m.all.getVol(vid)
@@ -138,7 +138,7 @@ func (m *VolumeMgr) applyVolumeTask(ctx context.Context, vid proto.Vid, taskID s
 	switch t {
 	case base.VolumeTaskTypeLock:
 		vol.lock.Lock()
-		if !vol.canLock() {
+		if !vol.canLock(token) {
 			span.Warnf("volume can't lock, status=%d", vol.getStatus())
 			vol.lock.Unlock()
 			return nil
@@ -140,7 +140,7 @@ func TestTaskProc(t *testing.T) {
 	volMgr.all.putVol(vol)
 	_, ctx := trace.StartSpanFromContext(context.Background(), "")
 	// volume lock
-	volMgr.applyVolumeTask(ctx, 1, uuid.New().String(), base.VolumeTaskTypeLock)
+	volMgr.applyVolumeTask(ctx, 1, uuid.New().String(), base.VolumeTaskTypeLock, "")
 	require.Equal(t, proto.VolumeStatusLock, vol.volInfoBase.Status)
 	taskid, hit := volMgr.lastTaskIdMap.Load(vol.vid)
 	require.True(t, hit)
@@ -150,7 +150,7 @@ func TestTaskProc(t *testing.T) {
 	require.False(t, hit)
 
 	// volume unlock
-	volMgr.applyVolumeTask(ctx, 1, uuid.New().String(), base.VolumeTaskTypeUnlock)
+	volMgr.applyVolumeTask(ctx, 1, uuid.New().String(), base.VolumeTaskTypeUnlock, "")
 	taskid, hit = volMgr.lastTaskIdMap.Load(vol.vid)
 	require.True(t, hit)
 	time.Sleep(2 * time.Second) // wait task finish
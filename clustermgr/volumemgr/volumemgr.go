@@ -30,6 +30,7 @@ import (
 	"github.com/cubefs/blobstore/clustermgr/diskmgr"
 	"github.com/cubefs/blobstore/clustermgr/persistence/volumedb"
 	"github.com/cubefs/blobstore/clustermgr/scopemgr"
+	"github.com/cubefs/blobstore/clustermgr/watchmgr"
 	"github.com/cubefs/blobstore/common/codemode"
 	apierrors "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
@@ -108,7 +109,7 @@ type VolumeMgrAPI interface {
 
 	// ListVolumeUnitInfo head all volume unit info in the disk
 	ListVolumeUnitInfo(ctx context.Context, args *cm.ListVolumeUnitArgs) ([]*cm.VolumeUnitInfo, error)
-	LockVolume(ctx context.Context, vid proto.Vid) error
+	LockVolume(ctx context.Context, args *cm.LockVolumeArgs) error
 	UnlockVolume(ctx context.Context, vid proto.Vid) error
 
 	// Stat return volume statistic info
@@ -394,8 +395,9 @@ func (v *VolumeMgr) DiskWritableChange(ctx context.Context, diskID proto.DiskID)
 	return
 }
 
-func (v *VolumeMgr) LockVolume(ctx context.Context, vid proto.Vid) error {
+func (v *VolumeMgr) LockVolume(ctx context.Context, args *cm.LockVolumeArgs) error {
 	span := trace.SpanFromContextSafe(ctx)
+	vid := args.Vid
 	vol := v.all.getVol(vid)
 	if vol == nil {
 		span.Errorf("volume not found, vid: %d", vid)
@@ -409,7 +411,7 @@ func (v *VolumeMgr) LockVolume(ctx context.Context, vid proto.Vid) error {
 		vol.lock.RUnlock()
 		return nil
 	}
-	if !vol.canLock() {
+	if !vol.canLock(args.Token) {
 		vol.lock.RUnlock()
 		span.Warnf("can't lock volume, volume %d, current status(%d)", vid, status)
 		return apierrors.ErrLockNotAllow
@@ -420,6 +422,7 @@ func (v *VolumeMgr) LockVolume(ctx context.Context, vid proto.Vid) error {
 		Vid:      vid,
 		TaskID:   uuid.New().String(),
 		TaskType: base.VolumeTaskTypeLock,
+		Token:    args.Token,
 	}
 	data, err := json.Marshal(param)
 	if err != nil {
@@ -698,8 +701,15 @@ func (v *VolumeMgr) applyAdminUpdateVolumeUnit(ctx context.Context, unitInfo *cm
 
 	unitRecord := vol.vUnits[index].ToVolumeUnitRecord()
 	err = v.volumeTbl.PutVolumeUnit(unitInfo.Vuid.VuidPrefix(), unitRecord)
+	vuid := vol.vUnits[index].vuInfo.Vuid
 	vol.lock.Unlock()
-	return err
+	if err != nil {
+		return err
+	}
+	watchmgr.Record(ctx, cm.WatchEvent{
+		Type: cm.WatchEventVolumeUnit, Vid: vuid.Vid(), Vuid: vuid, DiskID: diskInfo.DiskID,
+	})
+	return nil
 }
 
 // only leader node can create volume and check expire volume
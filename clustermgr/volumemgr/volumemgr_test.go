@@ -35,6 +35,7 @@ import (
 	"github.com/cubefs/blobstore/clustermgr/persistence/normaldb"
 	"github.com/cubefs/blobstore/clustermgr/persistence/volumedb"
 	"github.com/cubefs/blobstore/common/codemode"
+	apierrors "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/kvstore"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/raftserver"
@@ -360,7 +361,7 @@ func Test_NewVolumeMgr(t *testing.T) {
 	vol1.lock.Unlock()
 
 	// test exec task
-	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.New().String(), base.VolumeTaskTypeLock)
+	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.New().String(), base.VolumeTaskTypeLock, "")
 	assert.NoError(t, err)
 	vol2 := mockVolumeMgr.all.getVol(2)
 	assert.Equal(t, proto.VolumeStatusLock, vol2.volInfoBase.Status)
@@ -903,11 +904,11 @@ func TestVolumeMgr_LockVolume(t *testing.T) {
 	defer closeTestVolumeMgr()
 
 	// not allow lock active volume
-	err := mockVolumeMgr.LockVolume(context.Background(), 1)
+	err := mockVolumeMgr.LockVolume(context.Background(), &clustermgr.LockVolumeArgs{Vid: 1})
 	assert.Error(t, err)
 
 	// vid not exist
-	err = mockVolumeMgr.LockVolume(context.Background(), 55)
+	err = mockVolumeMgr.LockVolume(context.Background(), &clustermgr.LockVolumeArgs{Vid: 55})
 	assert.Error(t, err)
 
 	ctr := gomock.NewController(t)
@@ -919,17 +920,35 @@ func TestVolumeMgr_LockVolume(t *testing.T) {
 	// not apply ,
 	vol2 := mockVolumeMgr.all.getVol(2)
 	assert.Equal(t, proto.VolumeStatusIdle, vol2.volInfoBase.Status)
-	err = mockVolumeMgr.LockVolume(context.Background(), 2)
+	err = mockVolumeMgr.LockVolume(context.Background(), &clustermgr.LockVolumeArgs{Vid: 2})
 	assert.Error(t, err)
 	assert.Equal(t, proto.VolumeStatusIdle, vol2.volInfoBase.Status)
 
-	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.New().String(), base.VolumeTaskTypeLock)
+	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.New().String(), base.VolumeTaskTypeLock, "")
 	assert.NoError(t, err)
 	vol2 = mockVolumeMgr.all.getVol(2)
 	assert.Equal(t, proto.VolumeStatusLock, vol2.volInfoBase.Status)
 
-	err = mockVolumeMgr.LockVolume(context.Background(), 2)
+	err = mockVolumeMgr.LockVolume(context.Background(), &clustermgr.LockVolumeArgs{Vid: 2})
 	assert.NoError(t, err)
+
+	// lock active volume with its allocated token
+	vol1 := mockVolumeMgr.all.getVol(1)
+	assert.Equal(t, proto.VolumeStatusActive, vol1.volInfoBase.Status)
+	err = mockVolumeMgr.applyVolumeTask(context.Background(), 1, uuid.New().String(), base.VolumeTaskTypeLock, "127.0.0.1:8080;2")
+	assert.NoError(t, err)
+	assert.Equal(t, proto.VolumeStatusActive, vol1.volInfoBase.Status)
+	mockRaftServer.EXPECT().Propose(gomock.Any(), gomock.Any()).Return(nil)
+	// not apply, but allowed to propose
+	err = mockVolumeMgr.LockVolume(context.Background(), &clustermgr.LockVolumeArgs{Vid: 1, Token: "127.0.0.1:8080;1"})
+	assert.ErrorIs(t, err, apierrors.ErrCMUnexpect)
+	err = mockVolumeMgr.applyVolumeTask(context.Background(), 1, uuid.New().String(), base.VolumeTaskTypeLock, "127.0.0.1:8080;1")
+	assert.NoError(t, err)
+	assert.Equal(t, proto.VolumeStatusLock, vol1.volInfoBase.Status)
+	for _, vols := range mockVolumeMgr.allocator.actives.allocatorVols {
+		_, ok := vols[1]
+		assert.False(t, ok)
+	}
 }
 
 func TestVolumeMgr_UnlockVolume(t *testing.T) {
@@ -961,7 +980,7 @@ func TestVolumeMgr_UnlockVolume(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, proto.VolumeStatusLock, ret.Status)
 
-	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.New().String(), base.VolumeTaskTypeUnlock)
+	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.New().String(), base.VolumeTaskTypeUnlock, "")
 	assert.NoError(t, err)
 
 	ret, err = mockVolumeMgr.GetVolumeInfo(context.Background(), 2)
@@ -969,7 +988,7 @@ func TestVolumeMgr_UnlockVolume(t *testing.T) {
 	assert.Equal(t, proto.VolumeStatusUnlocking, ret.Status)
 
 	// volume status id idle , cannot apply volume unlock task, direct return but error is nil
-	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.NewString(), base.VolumeTaskTypeUnlock)
+	err = mockVolumeMgr.applyVolumeTask(context.Background(), 2, uuid.NewString(), base.VolumeTaskTypeUnlock, "")
 	assert.NoError(t, err)
 }
 
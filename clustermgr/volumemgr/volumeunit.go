@@ -24,6 +24,7 @@ import (
 	cmapi "github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/clustermgr/base"
 	"github.com/cubefs/blobstore/clustermgr/diskmgr"
+	"github.com/cubefs/blobstore/clustermgr/watchmgr"
 	apierrors "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/trace"
@@ -225,6 +226,9 @@ func (v *VolumeMgr) applyUpdateVolumeUnit(ctx context.Context, newVuid proto.Vui
 	}
 
 	span.Debugf("finish apply update volume unit")
+	watchmgr.Record(ctx, cmapi.WatchEvent{
+		Type: cmapi.WatchEventVolumeUnit, Vid: newVuid.Vid(), Vuid: newVuid, DiskID: newDiskID,
+	})
 
 	return
 }
@@ -0,0 +1,91 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clustermgr
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/clustermgr/base"
+	"github.com/cubefs/blobstore/clustermgr/diskmgr"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+func TestWatch(t *testing.T) {
+	testService := initTestService(t)
+	defer clear(testService)
+	defer testService.Close()
+	testClusterClient := initTestClusterClient(testService)
+
+	_, ctx := trace.StartSpanFromContext(context.Background(), "")
+	insertDiskInfos(t, testClusterClient, 1, 2, testService.IDC[0])
+
+	ret, err := testClusterClient.Watch(ctx, &clustermgr.WatchArgs{})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ret.Events))
+	index := ret.Index
+	require.True(t, index > 0)
+
+	// no change, returns after timeout
+	start := time.Now()
+	ret, err = testClusterClient.Watch(ctx, &clustermgr.WatchArgs{Index: index, TimeoutMs: 200})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ret.Events))
+	require.True(t, time.Since(start) >= 200*time.Millisecond)
+
+	// watcher is waked up by disk status change
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, testClusterClient.SetDisk(ctx, 1, proto.DiskStatusBroken))
+	}()
+	ret, err = testClusterClient.Watch(ctx, &clustermgr.WatchArgs{Index: index, TimeoutMs: 10000})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(ret.Events))
+	require.Equal(t, clustermgr.WatchEventDiskStatus, ret.Events[0].Type)
+	require.Equal(t, proto.DiskID(1), ret.Events[0].DiskID)
+	require.Equal(t, proto.DiskStatusBroken, ret.Events[0].Status)
+	require.Equal(t, ret.Events[0].Index, ret.Index)
+	require.True(t, ret.Index > index)
+
+	// resume from the returned index
+	index = ret.Index
+	require.NoError(t, testClusterClient.SetDisk(ctx, 2, proto.DiskStatusBroken))
+	ret, err = testClusterClient.Watch(ctx, &clustermgr.WatchArgs{Index: index})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(ret.Events))
+	require.Equal(t, proto.DiskID(2), ret.Events[0].DiskID)
+
+	// no event if the status was not changed by the apply
+	data, err := json.Marshal(&clustermgr.DiskSetArgs{DiskID: 2, Status: proto.DiskStatusBroken})
+	require.NoError(t, err)
+	proposeInfo := base.EncodeProposeInfo(testService.DiskMgr.GetModuleName(), diskmgr.OperTypeSetDiskStatus, data, base.ProposeContext{ReqID: "watch"})
+	require.NoError(t, testService.Apply([][]byte{proposeInfo}, ret.Index+1))
+	ret, err = testClusterClient.Watch(ctx, &clustermgr.WatchArgs{Index: ret.Index})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ret.Events))
+
+	// all events of a new cluster are kept, and discarded by snapshot
+	_, err = testClusterClient.Watch(ctx, &clustermgr.WatchArgs{Index: 1})
+	require.NoError(t, err)
+	testService.WatchMgr.Reset(ret.Index)
+	_, err = testClusterClient.Watch(ctx, &clustermgr.WatchArgs{Index: index})
+	require.Error(t, err)
+}
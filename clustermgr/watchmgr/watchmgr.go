@@ -0,0 +1,211 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package watchmgr
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	apierrors "github.com/cubefs/blobstore/common/errors"
+)
+
+const (
+	defaultCapacity   = 100000
+	defaultWatchCount = 1000
+	maxWatchCount     = 10000
+	maxWatchTimeout   = time.Minute
+)
+
+// WatchMgr keeps the recent volume unit and disk status change events in memory,
+// events are ordered by raft apply index, watchers long poll the events after an index.
+// Events are not persisted, so the events before restart or snapshot are compacted.
+type WatchMgr struct {
+	capacity int
+	events   []clustermgr.WatchEvent
+	// compactedIndex events with index not larger than compactedIndex have been discarded
+	compactedIndex uint64
+	// lastIndex the last apply index has been recorded
+	lastIndex uint64
+	// notifyCh closed and renewed when new events appended
+	notifyCh chan struct{}
+
+	lock sync.RWMutex
+}
+
+// New returns watch manager, watch can only resume from applyIndex or later
+func New(capacity int, applyIndex uint64) *WatchMgr {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	return &WatchMgr{
+		capacity:       capacity,
+		compactedIndex: applyIndex,
+		lastIndex:      applyIndex,
+		notifyCh:       make(chan struct{}),
+	}
+}
+
+// Append records events applied at raft index, and wakes up all watchers
+func (w *WatchMgr) Append(index uint64, events []clustermgr.WatchEvent) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if index <= w.lastIndex {
+		return
+	}
+	w.lastIndex = index
+	if len(events) == 0 {
+		return
+	}
+	for i := range events {
+		events[i].Index = index
+		w.events = append(w.events, events[i])
+	}
+	if len(w.events) > w.capacity {
+		w.compact(len(w.events) - w.capacity)
+	}
+
+	close(w.notifyCh)
+	w.notifyCh = make(chan struct{})
+}
+
+// Reset discards all events, watch can only resume from index or later
+func (w *WatchMgr) Reset(index uint64) {
+	w.lock.Lock()
+	w.events = nil
+	w.compactedIndex = index
+	w.lastIndex = index
+	w.lock.Unlock()
+}
+
+// Watch returns events after index, waits for new events at most timeout if there is none
+func (w *WatchMgr) Watch(ctx context.Context, index uint64, count int, timeout time.Duration) (*clustermgr.WatchRet, error) {
+	if count <= 0 || count > maxWatchCount {
+		count = defaultWatchCount
+	}
+	if timeout > maxWatchTimeout {
+		timeout = maxWatchTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		w.lock.RLock()
+		if index == 0 {
+			ret := &clustermgr.WatchRet{Index: w.lastIndex}
+			w.lock.RUnlock()
+			return ret, nil
+		}
+		if index < w.compactedIndex {
+			w.lock.RUnlock()
+			return nil, apierrors.ErrWatchIndexCompacted
+		}
+		ret := w.collect(index, count)
+		notifyCh := w.notifyCh
+		w.lock.RUnlock()
+
+		if len(ret.Events) > 0 || timeout <= 0 {
+			return ret, nil
+		}
+		select {
+		case <-notifyCh:
+		case <-timer.C:
+			return ret, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// collect events after index, events of the same index will never be split
+func (w *WatchMgr) collect(index uint64, count int) *clustermgr.WatchRet {
+	start := sort.Search(len(w.events), func(i int) bool {
+		return w.events[i].Index > index
+	})
+	end := start
+	for end < len(w.events) && (end-start < count || w.events[end].Index == w.events[end-1].Index) {
+		end++
+	}
+
+	ret := &clustermgr.WatchRet{Index: index}
+	if end > start {
+		ret.Events = make([]clustermgr.WatchEvent, end-start)
+		copy(ret.Events, w.events[start:end])
+		ret.Index = w.events[end-1].Index
+		if end == len(w.events) && w.lastIndex > ret.Index {
+			ret.Index = w.lastIndex
+		}
+		return ret
+	}
+	if w.lastIndex > index {
+		ret.Index = w.lastIndex
+	}
+	return ret
+}
+
+// compact discards the oldest n events and the rest events of the same index
+func (w *WatchMgr) compact(n int) {
+	for n < len(w.events) && w.events[n].Index == w.events[n-1].Index {
+		n++
+	}
+	w.compactedIndex = w.events[n-1].Index
+	events := make([]clustermgr.WatchEvent, len(w.events)-n, w.capacity)
+	copy(events, w.events[n:])
+	w.events = events
+}
+
+type recorderKey struct{}
+
+// Recorder collects events of one raft apply, modules record the events
+// only if their operations were applied, rejected operations change nothing.
+type Recorder struct {
+	lock   sync.Mutex
+	events [clustermgr.WatchEventMax][]clustermgr.WatchEvent
+}
+
+// WithRecorder returns context with a new recorder for module applies
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+	r := &Recorder{}
+	return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+// Record records events to the recorder in context, nothing to do if there is no recorder
+func Record(ctx context.Context, events ...clustermgr.WatchEvent) {
+	r, ok := ctx.Value(recorderKey{}).(*Recorder)
+	if !ok {
+		return
+	}
+	r.lock.Lock()
+	for _, event := range events {
+		if event.Type > clustermgr.WatchEventMin && event.Type < clustermgr.WatchEventMax {
+			r.events[event.Type] = append(r.events[event.Type], event)
+		}
+	}
+	r.lock.Unlock()
+}
+
+// Events returns recorded events, volume unit events are always in front of disk status events
+func (r *Recorder) Events() []clustermgr.WatchEvent {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var events []clustermgr.WatchEvent
+	for _, typed := range r.events {
+		events = append(events, typed...)
+	}
+	return events
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package watchmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/api/clustermgr"
+	apierrors "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+func diskEvents(ids ...proto.DiskID) []clustermgr.WatchEvent {
+	events := make([]clustermgr.WatchEvent, len(ids))
+	for i, id := range ids {
+		events[i] = clustermgr.WatchEvent{Type: clustermgr.WatchEventDiskStatus, DiskID: id, Status: proto.DiskStatusBroken}
+	}
+	return events
+}
+
+func TestWatchMgr(t *testing.T) {
+	ctx := context.Background()
+	w := New(4, 10)
+
+	ret, err := w.Watch(ctx, 0, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), ret.Index)
+	_, err = w.Watch(ctx, 9, 0, 0)
+	require.Equal(t, apierrors.ErrWatchIndexCompacted, err)
+
+	w.Append(11, nil)
+	w.Append(12, diskEvents(1, 2))
+	w.Append(12, diskEvents(3))
+	w.Append(13, diskEvents(4))
+	w.Append(14, nil)
+
+	ret, err = w.Watch(ctx, 10, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(14), ret.Index)
+	require.Equal(t, 3, len(ret.Events))
+	require.Equal(t, uint64(12), ret.Events[0].Index)
+	require.Equal(t, uint64(13), ret.Events[2].Index)
+
+	// events of the same index are never split
+	ret, err = w.Watch(ctx, 10, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(12), ret.Index)
+	require.Equal(t, 2, len(ret.Events))
+	ret, err = w.Watch(ctx, ret.Index, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(14), ret.Index)
+	require.Equal(t, proto.DiskID(4), ret.Events[0].DiskID)
+
+	// wait for new events
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		w.Append(15, diskEvents(5))
+	}()
+	ret, err = w.Watch(ctx, 14, 0, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, uint64(15), ret.Index)
+	require.Equal(t, proto.DiskID(5), ret.Events[0].DiskID)
+
+	ret, err = w.Watch(ctx, 15, 0, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, uint64(15), ret.Index)
+	require.Equal(t, 0, len(ret.Events))
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = w.Watch(cancelCtx, 15, 0, time.Second)
+	require.Error(t, err)
+
+	// compact the oldest events over capacity
+	w.Append(16, diskEvents(6))
+	_, err = w.Watch(ctx, 11, 0, 0)
+	require.Equal(t, apierrors.ErrWatchIndexCompacted, err)
+	ret, err = w.Watch(ctx, 12, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(ret.Events))
+
+	w.Reset(20)
+	_, err = w.Watch(ctx, 16, 0, 0)
+	require.Equal(t, apierrors.ErrWatchIndexCompacted, err)
+	ret, err = w.Watch(ctx, 20, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(20), ret.Index)
+	require.Equal(t, 0, len(ret.Events))
+}
+
+func TestRecorder(t *testing.T) {
+	// nothing recorded without recorder
+	Record(context.Background(), diskEvents(1)...)
+
+	ctx, r := WithRecorder(context.Background())
+	require.Equal(t, 0, len(r.Events()))
+
+	Record(ctx, diskEvents(1, 2)...)
+	Record(ctx, clustermgr.WatchEvent{Type: clustermgr.WatchEventVolumeUnit, Vid: 1, Vuid: proto.EncodeVuid(proto.EncodeVuidPrefix(1, 0), 2)})
+	Record(ctx, clustermgr.WatchEvent{Type: clustermgr.WatchEventMax})
+	events := r.Events()
+	require.Equal(t, 3, len(events))
+	require.Equal(t, clustermgr.WatchEventVolumeUnit, events[0].Type)
+	require.Equal(t, diskEvents(1, 2), events[1:])
+}
@@ -160,15 +160,8 @@ func init() {
 		{Mode: EC6P6Align512, Size: alignSize512B},
 	} {
 		tactic := pair.Mode.Tactic()
-		if !tactic.IsValid() {
-			panic(fmt.Sprintf("Invalid codemode:%d Tactic:%+v", pair.Mode, tactic))
-		}
-
-		min := tactic.N + (tactic.N+tactic.M)/tactic.AZCount
-		max := tactic.N + tactic.M
-		if tactic.PutQuorum < min || tactic.PutQuorum > max {
-			panic(fmt.Sprintf("Invalid codemode:%d PutQuorum:%d([%d,%d])", pair.Mode,
-				tactic.PutQuorum, min, max))
+		if err := tactic.Validate(); err != nil {
+			panic(fmt.Sprintf("Invalid codemode:%d %s", pair.Mode, err.Error()))
 		}
 
 		if tactic.MinShardSize != pair.Size {
@@ -183,6 +176,9 @@ func (c CodeMode) Tactic() Tactic {
 	if tactic, ok := constCodeModeTactic[c]; ok {
 		return tactic
 	}
+	if tactic, ok := registeredTactic(c); ok {
+		return tactic
+	}
 	panic(fmt.Sprintf("Invalid codemode:%d", c))
 }
 
@@ -196,6 +192,9 @@ func (c CodeMode) Name() CodeModeName {
 	if name, ok := constCodeMode2Name[c]; ok {
 		return name
 	}
+	if name, ok := registeredName(c); ok {
+		return name
+	}
 	panic(fmt.Sprintf("codemode: %d is invalid", c))
 }
 
@@ -204,6 +203,9 @@ func (c CodeMode) String() string {
 	if name, ok := constCodeMode2Name[c]; ok {
 		return string(name)
 	}
+	if name, ok := registeredName(c); ok {
+		return string(name)
+	}
 	panic(fmt.Sprintf("codemode: %d is invalid", c))
 }
 
@@ -212,7 +214,8 @@ func (c CodeMode) IsValid() bool {
 	if _, ok := constCodeMode2Name[c]; ok {
 		return ok
 	}
-	return false
+	_, ok := registeredName(c)
+	return ok
 }
 
 // GetCodeMode get the code mode by name
@@ -220,6 +223,9 @@ func (cn CodeModeName) GetCodeMode() CodeMode {
 	if code, ok := constName2CodeMode[cn]; ok {
 		return code
 	}
+	if code, ok := registeredCodeMode(cn); ok {
+		return code
+	}
 	panic(fmt.Sprintf("codemode: %s is invalid", cn))
 }
 
@@ -228,7 +234,8 @@ func (cn CodeModeName) IsValid() bool {
 	if _, ok := constName2CodeMode[cn]; ok {
 		return ok
 	}
-	return false
+	_, ok := registeredCodeMode(cn)
+	return ok
 }
 
 // Tactic get tactic by code mode name
@@ -243,6 +250,24 @@ func (c *Tactic) IsValid() bool {
 		c.N%c.AZCount == 0 && c.M%c.AZCount == 0 && c.L%c.AZCount == 0
 }
 
+// Validate checks the tactic is valid and its PutQuorum
+// keeps ec data recoverable if one AZ was down,
+// PutQuorum of single az tactic should not less than N
+func (c *Tactic) Validate() error {
+	if !c.IsValid() {
+		return fmt.Errorf("invalid tactic:%+v", *c)
+	}
+	min := c.N
+	if c.AZCount > 1 {
+		min = c.N + (c.N+c.M)/c.AZCount
+	}
+	max := c.N + c.M
+	if c.PutQuorum < min || c.PutQuorum > max {
+		return fmt.Errorf("invalid PutQuorum:%d([%d,%d])", c.PutQuorum, min, max)
+	}
+	return nil
+}
+
 // GetECLayoutByAZ ec layout by AZ
 func (c *Tactic) GetECLayoutByAZ() (azStripes [][]int) {
 	azStripes = make([][]int, c.AZCount)
@@ -319,7 +344,7 @@ func (c *Tactic) LocalStripeInAZ(azIndex int) (localStripe []int, n, m int) {
 
 // GetAllCodeModes get all the available CodeModes
 func GetAllCodeModes() []CodeMode {
-	return []CodeMode{
+	return append([]CodeMode{
 		EC15P12,
 		EC6P6,
 		EC16P20L2,
@@ -333,5 +358,5 @@ func GetAllCodeModes() []CodeMode {
 		EC3P3,
 		EC10P4,
 		EC6P3,
-	}
+	}, registeredCodeModes()...)
 }
@@ -0,0 +1,143 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package codemode
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// RegisteredMode code mode defined at runtime, clustermgr keeps them
+// in its raft replicated config and other services sync them from clustermgr
+type RegisteredMode struct {
+	CodeMode CodeMode     `json:"code_mode"`
+	Name     CodeModeName `json:"name"`
+	Tactic   Tactic       `json:"tactic"`
+}
+
+// Validate checks the mode not conflict with pre-defined modes and its tactic
+func (m *RegisteredMode) Validate() error {
+	if m.CodeMode == 0 || m.Name == "" {
+		return fmt.Errorf("invalid codemode:%d name:%s", m.CodeMode, m.Name)
+	}
+	if _, ok := constCodeMode2Name[m.CodeMode]; ok {
+		return fmt.Errorf("codemode:%d is pre-defined", m.CodeMode)
+	}
+	if _, ok := constName2CodeMode[m.Name]; ok {
+		return fmt.Errorf("codemode name:%s is pre-defined", m.Name)
+	}
+	if err := m.Tactic.Validate(); err != nil {
+		return fmt.Errorf("codemode:%d %s", m.CodeMode, err.Error())
+	}
+	return nil
+}
+
+type registry struct {
+	modes map[CodeMode]RegisteredMode
+	names map[CodeModeName]CodeMode
+}
+
+var (
+	registryLock sync.Mutex
+	// registryValue holds *registry, copy on write and lock free on read
+	registryValue atomic.Value
+)
+
+func init() {
+	registryValue.Store(&registry{
+		modes: make(map[CodeMode]RegisteredMode),
+		names: make(map[CodeModeName]CodeMode),
+	})
+}
+
+func loadRegistry() *registry {
+	return registryValue.Load().(*registry)
+}
+
+// Register add runtime code modes, register the same mode again is ok,
+// but one code mode or name can not be redefined once registered
+func Register(modes ...RegisteredMode) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	old := loadRegistry()
+	reg := &registry{
+		modes: make(map[CodeMode]RegisteredMode, len(old.modes)+len(modes)),
+		names: make(map[CodeModeName]CodeMode, len(old.names)+len(modes)),
+	}
+	for mode, m := range old.modes {
+		reg.modes[mode] = m
+	}
+	for name, mode := range old.names {
+		reg.names[name] = mode
+	}
+
+	for _, m := range modes {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		if exist, ok := reg.modes[m.CodeMode]; ok {
+			if exist != m {
+				return fmt.Errorf("codemode:%d was registered as %+v", m.CodeMode, exist)
+			}
+			continue
+		}
+		if mode, ok := reg.names[m.Name]; ok {
+			return fmt.Errorf("codemode name:%s was registered by codemode:%d", m.Name, mode)
+		}
+		reg.modes[m.CodeMode] = m
+		reg.names[m.Name] = m.CodeMode
+	}
+
+	registryValue.Store(reg)
+	return nil
+}
+
+// Registered returns all runtime code modes sorted by code mode
+func Registered() []RegisteredMode {
+	reg := loadRegistry()
+	modes := make([]RegisteredMode, 0, len(reg.modes))
+	for _, m := range reg.modes {
+		modes = append(modes, m)
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i].CodeMode < modes[j].CodeMode })
+	return modes
+}
+
+func registeredCodeModes() []CodeMode {
+	modes := Registered()
+	codeModes := make([]CodeMode, 0, len(modes))
+	for _, m := range modes {
+		codeModes = append(codeModes, m.CodeMode)
+	}
+	return codeModes
+}
+
+func registeredTactic(c CodeMode) (Tactic, bool) {
+	m, ok := loadRegistry().modes[c]
+	return m.Tactic, ok
+}
+
+func registeredName(c CodeMode) (CodeModeName, bool) {
+	m, ok := loadRegistry().modes[c]
+	return m.Name, ok
+}
+
+func registeredCodeMode(cn CodeModeName) (CodeMode, bool) {
+	mode, ok := loadRegistry().names[cn]
+	return mode, ok
+}
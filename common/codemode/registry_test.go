@@ -0,0 +1,74 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package codemode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeModeRegister(t *testing.T) {
+	ec8p4 := RegisteredMode{
+		CodeMode: 200,
+		Name:     "EC8P4",
+		Tactic:   Tactic{N: 8, M: 4, AZCount: 1, PutQuorum: 11, MinShardSize: alignSize2KB},
+	}
+	require.False(t, ec8p4.CodeMode.IsValid())
+	require.False(t, ec8p4.Name.IsValid())
+	require.Panics(t, func() { ec8p4.CodeMode.Tactic() })
+
+	// conflict with pre-defined
+	for _, m := range []RegisteredMode{
+		{CodeMode: EC6P6, Name: "EC6P6X", Tactic: ec8p4.Tactic},
+		{CodeMode: 201, Name: "EC6P6", Tactic: ec8p4.Tactic},
+		{CodeMode: 0, Name: "EC0", Tactic: ec8p4.Tactic},
+		{CodeMode: 201, Name: "", Tactic: ec8p4.Tactic},
+	} {
+		require.Error(t, Register(m))
+	}
+	// invalid tactic
+	bad := ec8p4
+	bad.Tactic.PutQuorum = 13
+	require.Error(t, Register(bad))
+	bad.Tactic.PutQuorum = 7
+	require.Error(t, Register(bad))
+	bad.Tactic.PutQuorum = 11
+	bad.Tactic.AZCount = 3
+	require.Error(t, Register(bad))
+
+	require.NoError(t, Register(ec8p4))
+	require.NoError(t, Register(ec8p4))
+	require.True(t, ec8p4.CodeMode.IsValid())
+	require.True(t, ec8p4.Name.IsValid())
+	require.Equal(t, ec8p4.Tactic, ec8p4.CodeMode.Tactic())
+	require.Equal(t, ec8p4.Name, ec8p4.CodeMode.Name())
+	require.Equal(t, "EC8P4", ec8p4.CodeMode.String())
+	require.Equal(t, ec8p4.CodeMode, ec8p4.Name.GetCodeMode())
+	require.Equal(t, 12, ec8p4.CodeMode.GetShardNum())
+	require.Contains(t, GetAllCodeModes(), ec8p4.CodeMode)
+	require.Contains(t, GetAllCodeModes(), EC6P6)
+
+	// redefine
+	changed := ec8p4
+	changed.Tactic.PutQuorum = 12
+	require.Error(t, Register(changed))
+	require.Error(t, Register(RegisteredMode{CodeMode: 201, Name: "EC8P4", Tactic: ec8p4.Tactic}))
+	// failed batch registers nothing
+	require.Error(t, Register(RegisteredMode{CodeMode: 202, Name: "EC4P4", Tactic: Tactic{N: 4, M: 4, AZCount: 1, PutQuorum: 7}}, changed))
+	require.False(t, CodeModeName("EC4P4").IsValid())
+
+	require.Equal(t, []RegisteredMode{ec8p4}, Registered())
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ec
+
+import (
+	"io"
+
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/resourcepool"
+)
+
+// DefaultStripeUnit default unit size of stripe, same as block size of crc32block
+const DefaultStripeUnit = 64 * 1024
+
+// Stripe layout, shards are cut into units, the i-th unit of all shards is the i-th stripe.
+// Data of one stripe is continuous in the blob, the last stripe is shorter
+// if shard size is not aligned with unit size.
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+//  |  shard  |     stripe-0     |     stripe-1     |     ...     |
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+//  | data-0  |  data[0, u)      |  data[N*u, N*u+u) |    ...     |
+//  | data-1  |  data[u, 2u)     |       ...         |    ...     |
+//  | ...     |       ...        |       ...         |    ...     |
+//  | parity  |  encoded stripe  |  encoded stripe   |    ...     |
+//  - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// Normal layout is the layout with only one stripe which unit is shard size,
+// so they are the same if shard size is not larger than unit size.
+// Shards of stripe layout can be verified and reconstructed as normal layout.
+
+// Segment a continuous piece of data in one data shard
+type Segment struct {
+	Index  int // index of data shard
+	Offset int // offset in the shard
+	Size   int // size of the piece
+}
+
+// StripeSegments returns segments of data range [from, to) in data shards,
+// the segments are in order of data. unit <= 0 means normal layout.
+func StripeSegments(sizes BufferSizes, tactic codemode.Tactic, unit, from, to int) []Segment {
+	shardSize := sizes.ShardSize
+	if unit <= 0 || unit > shardSize {
+		unit = shardSize
+	}
+	if isOutOfRange(sizes.ECDataSize, from, to) {
+		return nil
+	}
+
+	segments := make([]Segment, 0, 1+(to-from)/unit)
+	stripeSize := unit * tactic.N
+	for off := from; off < to; {
+		stripe := off / stripeSize
+		unitSize := minInt(unit, shardSize-stripe*unit)
+		inStripe := off - stripe*stripeSize
+		inUnit := inStripe % unitSize
+		size := minInt(unitSize-inUnit, to-off)
+		segments = append(segments, Segment{
+			Index:  inStripe / unitSize,
+			Offset: stripe*unit + inUnit,
+			Size:   size,
+		})
+		off += size
+	}
+	return segments
+}
+
+// StripeEncoder encodes data stream stripe by stripe,
+// memory of the encoder is one stripe whatever the size of data.
+type StripeEncoder struct {
+	encoder Encoder
+	tactic  codemode.Tactic
+	unit    int
+	pool    *resourcepool.MemPool
+	buf     []byte
+}
+
+// NewStripeEncoder returns stripe encoder with unit size, alloc buffer from pool if not nil
+func NewStripeEncoder(encoder Encoder, tactic codemode.Tactic, unit int,
+	pool *resourcepool.MemPool) (*StripeEncoder, error) {
+	if encoder == nil || unit <= 0 {
+		return nil, ErrInvalidConfig
+	}
+
+	size := unit * (tactic.N + tactic.M + tactic.L)
+	if size <= 0 {
+		return nil, ErrInvalidCodeMode
+	}
+	var (
+		buf []byte
+		err error
+	)
+	if pool != nil {
+		buf, err = pool.Get(size)
+		if err == resourcepool.ErrNoSuitableSizeClass {
+			buf, err = pool.Alloc(size)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		buf = make([]byte, size)
+	}
+
+	return &StripeEncoder{
+		encoder: encoder,
+		tactic:  tactic,
+		unit:    unit,
+		pool:    pool,
+		buf:     buf[:size],
+	}, nil
+}
+
+// Encode reads dataSize bytes from r, encodes and writes the units of shard i
+// into writers[i] stripe by stripe. The unit bytes are reused after Write returns.
+// Returns ErrShortData if r has no enough data.
+func (e *StripeEncoder) Encode(r io.Reader, dataSize int, writers []io.Writer) error {
+	if len(writers) != e.tactic.N+e.tactic.M+e.tactic.L {
+		return ErrInvalidShards
+	}
+	sizes, err := GetBufferSizes(dataSize, e.tactic)
+	if err != nil {
+		return err
+	}
+
+	unit := minInt(e.unit, sizes.ShardSize)
+	shards := make([][]byte, len(writers))
+	remain := dataSize
+	for off := 0; off < sizes.ShardSize; off += unit {
+		unitSize := minInt(unit, sizes.ShardSize-off)
+		for idx := range shards {
+			shards[idx] = e.buf[idx*unitSize : (idx+1)*unitSize]
+		}
+
+		stripeData := e.buf[:unitSize*e.tactic.N]
+		n := minInt(remain, len(stripeData))
+		if _, err = io.ReadFull(r, stripeData[:n]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return ErrShortData
+			}
+			return err
+		}
+		remain -= n
+		zeroBytes(stripeData[n:])
+
+		if err = e.encoder.Encode(shards); err != nil {
+			return err
+		}
+		for idx, w := range writers {
+			if _, err = w.Write(shards[idx]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Release recycles the buffer into pool
+func (e *StripeEncoder) Release() error {
+	if e == nil || e.buf == nil {
+		return nil
+	}
+	buf := e.buf
+	e.buf = nil
+	if e.pool != nil {
+		return e.pool.Put(buf)
+	}
+	return nil
+}
+
+func zeroBytes(b []byte) {
+	for idx := range b {
+		b[idx] = 0
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
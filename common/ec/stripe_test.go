@@ -0,0 +1,143 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ec_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/ec"
+)
+
+func TestStripeSegments(t *testing.T) {
+	tactic := codemode.EC6P6.Tactic()
+	sizes, err := ec.GetBufferSizes(6*kb4+6*kb, tactic)
+	require.NoError(t, err)
+	require.Equal(t, kb4+kb, sizes.ShardSize)
+
+	// normal layout
+	require.Equal(t, []ec.Segment{{Index: 0, Offset: 100, Size: 10}},
+		ec.StripeSegments(sizes, tactic, 0, 100, 110))
+	require.Equal(t, []ec.Segment{
+		{Index: 0, Offset: kb4, Size: kb},
+		{Index: 1, Offset: 0, Size: 1},
+	}, ec.StripeSegments(sizes, tactic, 0, kb4, kb4+kb+1))
+	require.Equal(t, ec.StripeSegments(sizes, tactic, 0, 0, sizes.DataSize),
+		ec.StripeSegments(sizes, tactic, kb64, 0, sizes.DataSize))
+
+	// stripe layout with 4KB unit, the last stripe with 1KB unit
+	require.Equal(t, []ec.Segment{{Index: 1, Offset: 0, Size: 10}},
+		ec.StripeSegments(sizes, tactic, kb4, kb4, kb4+10))
+	require.Equal(t, []ec.Segment{
+		{Index: 5, Offset: kb4 - 1, Size: 1},
+		{Index: 0, Offset: kb4, Size: kb},
+		{Index: 1, Offset: kb4, Size: 1},
+	}, ec.StripeSegments(sizes, tactic, kb4, 6*kb4-1, 6*kb4+kb+1))
+
+	var total int
+	for _, seg := range ec.StripeSegments(sizes, tactic, kb4, 0, sizes.DataSize) {
+		total += seg.Size
+	}
+	require.Equal(t, sizes.DataSize, total)
+
+	require.Nil(t, ec.StripeSegments(sizes, tactic, kb4, 0, sizes.ECDataSize+1))
+	require.Equal(t, 0, len(ec.StripeSegments(sizes, tactic, kb4, 10, 10)))
+}
+
+func TestStripeEncoder(t *testing.T) {
+	for _, cm := range []codemode.CodeMode{codemode.EC6P6, codemode.EC6P10L2, codemode.EC6P6Align0} {
+		tactic := cm.Tactic()
+		encoder, err := ec.NewEncoder(&ec.Config{CodeMode: tactic, EnableVerify: true})
+		require.NoError(t, err)
+
+		for _, size := range []int{1, kb, 6*kb4 + 6*kb, 6*kb64 + 1, mb + 1} {
+			for _, unit := range []int{kb4, kb64} {
+				data := make([]byte, size)
+				rand.Read(data)
+
+				stripe, err := ec.NewStripeEncoder(encoder, tactic, unit, memPool)
+				require.NoError(t, err)
+				buffers := make([]*bytes.Buffer, cm.GetShardNum())
+				writers := make([]io.Writer, len(buffers))
+				for idx := range buffers {
+					buffers[idx] = new(bytes.Buffer)
+					writers[idx] = buffers[idx]
+				}
+				require.NoError(t, stripe.Encode(bytes.NewReader(data), size, writers))
+				require.NoError(t, stripe.Release())
+				require.NoError(t, stripe.Release())
+
+				sizes, err := ec.GetBufferSizes(size, tactic)
+				require.NoError(t, err)
+				shards := make([][]byte, len(buffers))
+				for idx := range buffers {
+					shards[idx] = buffers[idx].Bytes()
+					require.Equal(t, sizes.ShardSize, len(shards[idx]))
+				}
+				ok, err := encoder.Verify(shards)
+				require.NoError(t, err)
+				require.True(t, ok)
+
+				got := make([]byte, 0, size)
+				for _, seg := range ec.StripeSegments(sizes, tactic, unit, 0, size) {
+					got = append(got, shards[seg.Index][seg.Offset:seg.Offset+seg.Size]...)
+				}
+				require.Equal(t, data, got)
+
+				// the same as normal layout in one stripe
+				if sizes.ShardSize <= unit {
+					buffer, err := ec.NewBuffer(size, tactic, memPool)
+					require.NoError(t, err)
+					defer buffer.Release()
+					copy(buffer.DataBuf, data)
+					normal, err := encoder.Split(buffer.ECDataBuf)
+					require.NoError(t, err)
+					require.NoError(t, encoder.Encode(normal))
+					require.Equal(t, normal, shards)
+				}
+			}
+		}
+	}
+}
+
+func TestStripeEncoderError(t *testing.T) {
+	tactic := codemode.EC6P6.Tactic()
+	encoder, err := ec.NewEncoder(&ec.Config{CodeMode: tactic})
+	require.NoError(t, err)
+
+	_, err = ec.NewStripeEncoder(nil, tactic, kb4, nil)
+	require.ErrorIs(t, err, ec.ErrInvalidConfig)
+	_, err = ec.NewStripeEncoder(encoder, tactic, 0, nil)
+	require.ErrorIs(t, err, ec.ErrInvalidConfig)
+
+	stripe, err := ec.NewStripeEncoder(encoder, tactic, kb4, nil)
+	require.NoError(t, err)
+	defer stripe.Release()
+
+	writers := make([]io.Writer, tactic.N+tactic.M)
+	for idx := range writers {
+		writers[idx] = ioutil.Discard
+	}
+	require.ErrorIs(t, stripe.Encode(bytes.NewReader(nil), 1, writers[1:]), ec.ErrInvalidShards)
+	require.ErrorIs(t, stripe.Encode(bytes.NewReader(nil), 0, writers), ec.ErrShortData)
+	require.ErrorIs(t, stripe.Encode(bytes.NewReader(make([]byte, mb)), mb+1, writers), ec.ErrShortData)
+	require.NoError(t, stripe.Encode(bytes.NewReader(make([]byte, mb)), mb, writers))
+}
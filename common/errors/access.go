@@ -17,19 +17,27 @@ package errors
 // code for access
 const (
 	CodeAccessReadRequestBody  = 466 // read request body error
+	CodeAccessChecksumMismatch = 467 // checksum of request body mismatch
 	CodeAccessReadConflictBody = 499 // read conflict body error
 	CodeAccessUnexpect         = 550 // unexpect
 	CodeAccessServiceDiscovery = 551 // service discovery for access api client
 	CodeAccessLimited          = 552 // read write limited for access api client
 	CodeAccessExceedSize       = 553 // exceed max size
+	CodeAccessNoSuchUpload     = 554 // multipart upload not found or expired
+	CodeAccessPartsIncomplete  = 555 // multipart upload has parts not uploaded
+	CodeAccessQuotaExceeded    = 556 // tenant quota of stored bytes or objects exceeded
 )
 
 // errro of access
 var (
 	ErrAccessReadRequestBody  = Error(CodeAccessReadRequestBody)
+	ErrAccessChecksumMismatch = Error(CodeAccessChecksumMismatch)
 	ErrAccessReadConflictBody = Error(CodeAccessReadConflictBody)
 	ErrAccessUnexpect         = Error(CodeAccessUnexpect)
 	ErrAccessServiceDiscovery = Error(CodeAccessServiceDiscovery)
 	ErrAccessLimited          = Error(CodeAccessLimited)
 	ErrAccessExceedSize       = Error(CodeAccessExceedSize)
+	ErrAccessNoSuchUpload     = Error(CodeAccessNoSuchUpload)
+	ErrAccessPartsIncomplete  = Error(CodeAccessPartsIncomplete)
+	ErrAccessQuotaExceeded    = Error(CodeAccessQuotaExceeded)
 )
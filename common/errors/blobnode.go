@@ -26,6 +26,7 @@ const (
 	CodeDiskBroken    = 613
 	CodeInvalidDiskId = 614
 	CodeDiskNoSpace   = 615
+	CodeDiskNotRepair = 616
 
 	CodeVuidNotFound     = 621
 	CodeVUIDReadonly     = 622
@@ -59,6 +60,7 @@ var (
 	ErrDiskBroken    = Error(CodeDiskBroken)
 	ErrDiskNoSpace   = Error(CodeDiskNoSpace)
 	ErrInvalidDiskId = Error(CodeInvalidDiskId)
+	ErrDiskNotRepair = Error(CodeDiskNotRepair)
 
 	ErrNoSuchVuid       = Error(CodeVuidNotFound)
 	ErrReadonlyVUID     = Error(CodeVUIDReadonly)
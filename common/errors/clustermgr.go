@@ -47,6 +47,10 @@ const (
 	CodeRetainVolumeNotAlloc         = 929
 	CodeDroppedDiskHasVolumeUnit     = 930
 	CodeNotSupportIdle               = 931
+	CodeKvNotFound                   = 932
+	CodeKvCounterExceedLimit         = 933
+	CodeWatchIndexCompacted          = 934
+	CodeBackupExist                  = 935
 )
 
 var (
@@ -82,4 +86,8 @@ var (
 	ErrRetainVolumeNotAlloc         = Error(CodeRetainVolumeNotAlloc)
 	ErrDroppedDiskHasVolumeUnit     = Error(CodeDroppedDiskHasVolumeUnit)
 	ErrNotSupportIdle               = Error(CodeNotSupportIdle)
+	ErrKvNotFound                   = Error(CodeKvNotFound)
+	ErrKvCounterExceedLimit         = Error(CodeKvCounterExceedLimit)
+	ErrWatchIndexCompacted          = Error(CodeWatchIndexCompacted)
+	ErrBackupExist                  = Error(CodeBackupExist)
 )
@@ -49,11 +49,15 @@ func (e Error) ErrorCode() string {
 var errCodeMap = map[int]string{
 	// access
 	CodeAccessReadRequestBody:  "access read request body",
+	CodeAccessChecksumMismatch: "access checksum mismatch",
 	CodeAccessReadConflictBody: "access read conflict body",
 	CodeAccessUnexpect:         "access unexpected error",
 	CodeAccessServiceDiscovery: "access client service discovery disconnect",
 	CodeAccessLimited:          "access limited",
 	CodeAccessExceedSize:       "access exceed object size",
+	CodeAccessNoSuchUpload:     "access no such multipart upload",
+	CodeAccessPartsIncomplete:  "access multipart upload parts incomplete",
+	CodeAccessQuotaExceeded:    "access tenant quota exceeded",
 
 	// clustermgr
 	CodeCMUnexpect:                "cm: unexpected error",
@@ -87,6 +91,10 @@ var errCodeMap = map[int]string{
 	CodeRetainVolumeNotAlloc:      "retain volume is not alloc",
 	CodeDroppedDiskHasVolumeUnit:  "dropped disk still has volume unit remain, migrate them firstly",
 	CodeNotSupportIdle:            "list volume v2 not support idle status",
+	CodeKvNotFound:                "kv not found",
+	CodeKvCounterExceedLimit:      "kv counter exceeds the limit",
+	CodeWatchIndexCompacted:       "watch index has been compacted",
+	CodeBackupExist:               "backup already exists",
 
 	// background
 	CodeNotingTodo:                   "nothing to do",
@@ -113,6 +121,7 @@ var errCodeMap = map[int]string{
 	CodeDiskBroken:    "disk is broken",
 	CodeInvalidDiskId: "disk id is invalid",
 	CodeDiskNoSpace:   "disk no space",
+	CodeDiskNotRepair: "disk is not repaired",
 
 	CodeVuidNotFound:     "vuid not found",
 	CodeVUIDReadonly:     "vuid readonly",
@@ -85,4 +85,10 @@ const (
 	CodeModeConfigKey    = "code_mode"
 	VolumeReserveSizeKey = "volume_reserve_size"
 	VolumeChunkSizeKey   = "volume_chunk_size"
+	// CodeModeTacticsConfigKey json list of runtime registered code modes,
+	// only clustermgr's code mode register api can change it.
+	CodeModeTacticsConfigKey = "code_mode_tactics"
+	// StripeEnableConfigKey feature flag of striped location, "true" or "false",
+	// set to true only if all access nodes of the region can read striped location.
+	StripeEnableConfigKey = "stripe_enable"
 )
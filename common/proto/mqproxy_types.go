@@ -55,6 +55,17 @@ func (s *BlobDeleteStage) Copy() BlobDeleteStage {
 	return myCopy
 }
 
+// PackedBlob is the index-th object of Count objects packed in a shared blob,
+// the shared blob can be deleted only after all packed objects are deleted.
+type PackedBlob struct {
+	Index uint32 `json:"index"`
+	Count uint32 `json:"count"`
+}
+
+func (p *PackedBlob) IsValid() bool {
+	return p.Index < p.Count
+}
+
 type DeleteMsg struct {
 	ClusterID     ClusterID       `json:"cluster_id"`
 	Bid           BlobID          `json:"bid"`
@@ -63,6 +74,7 @@ type DeleteMsg struct {
 	Time          int64           `json:"time"`
 	ReqId         string          `json:"req_id"`
 	BlobDelStages BlobDeleteStage `json:"blob_del_stages"`
+	Packed        *PackedBlob     `json:"packed,omitempty"`
 }
 
 func (msg *DeleteMsg) IsValid() bool {
@@ -72,6 +84,9 @@ func (msg *DeleteMsg) IsValid() bool {
 	if msg.Vid == InvalidVid {
 		return false
 	}
+	if msg.Packed != nil && !msg.Packed.IsValid() {
+		return false
+	}
 	return true
 }
 
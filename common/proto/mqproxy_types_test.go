@@ -91,6 +91,22 @@ func TestDeleteMsg_IsValid(t *testing.T) {
 		Time:      0,
 	}
 	require.Equal(t, false, msg.IsValid())
+
+	msg = DeleteMsg{
+		ClusterID: 1,
+		Bid:       1,
+		Vid:       1,
+		Packed:    &PackedBlob{Index: 1, Count: 2},
+	}
+	require.Equal(t, true, msg.IsValid())
+
+	msg = DeleteMsg{
+		ClusterID: 1,
+		Bid:       1,
+		Vid:       1,
+		Packed:    &PackedBlob{Index: 2, Count: 2},
+	}
+	require.Equal(t, false, msg.IsValid())
 }
 
 func TestMsgMarshal(t *testing.T) {
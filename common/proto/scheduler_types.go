@@ -59,6 +59,7 @@ const (
 	BalanceTaskType   = "balance_task"
 	DiskDropTaskType  = "disk_drop_task"
 	ManualMigrateType = "manual_migrate"
+	TranscodeTaskType = "transcode_task"
 )
 
 //---------------------------------------------------------------------
@@ -216,6 +217,86 @@ func (t *MigrateTask) SrcMigDiskID() DiskID {
 
 //--------------------------------------------------------------------------------------------------
 
+// 转码后台任务
+type TranscodeState uint8
+
+const (
+	TranscodeStateInited TranscodeState = iota + 1
+	TranscodeStatePrepared
+	TranscodeStateWorkCompleted
+	TranscodeStateFinished
+	TranscodeStateFinishedInAdvance
+	TranscodeStateRedirected // redirected to destination, source volume units are not released
+)
+
+// TranscodeTask re-encodes all blobs of a sealed volume into a new volume of
+// another code mode, blobs keep their bids in the new volume.
+// the data shards of source blob are concatenated as data of the new blob.
+type TranscodeTask struct {
+	TaskID string         `json:"task_id" bson:"_id"` // task id
+	State  TranscodeState `json:"state" bson:"state"` // task state
+
+	SourceIdc string            `json:"source_idc" bson:"source_idc"` // idc of worker queue
+	SourceVid Vid               `json:"source_vid" bson:"source_vid"` // source volume id
+	CodeMode  codemode.CodeMode `json:"code_mode" bson:"code_mode"`   // source codemode
+	Sources   []VunitLocation   `json:"sources" bson:"sources"`       // source volume units location
+
+	DestVid      Vid               `json:"dest_vid" bson:"dest_vid"`             // destination volume id
+	DestCodeMode codemode.CodeMode `json:"dest_code_mode" bson:"dest_code_mode"` // destination codemode
+	Destinations []VunitLocation   `json:"destinations" bson:"destinations"`     // destination volume units location
+	DestToken    string            `json:"dest_token" bson:"dest_token"`         // lease token of destination volume
+
+	RedirectTime int64 `json:"redirect_time" bson:"redirect_time"` // unix seconds of redirecting to destination
+
+	Ctime string `json:"ctime" bson:"ctime"` // create time
+	MTime string `json:"mtime" bson:"mtime"` // modify time
+
+	FinishAdvanceReason string `json:"finish_advance_reason" bson:"finish_advance_reason"`
+}
+
+func (t *TranscodeTask) GetSrc() []VunitLocation {
+	return t.Sources
+}
+
+// GetDest the first unit stands for the destination volume
+func (t *TranscodeTask) GetDest() VunitLocation {
+	if len(t.Destinations) == 0 {
+		return VunitLocation{}
+	}
+	return t.Destinations[0]
+}
+
+// SetDest replaces the destination unit with the same index
+func (t *TranscodeTask) SetDest(dest VunitLocation) {
+	idx := int(dest.Vuid.Index())
+	if idx < len(t.Destinations) {
+		t.Destinations[idx] = dest
+	}
+}
+
+func (t *TranscodeTask) Running() bool {
+	return t.State == TranscodeStatePrepared || t.State == TranscodeStateWorkCompleted ||
+		t.State == TranscodeStateRedirected
+}
+
+func (t *TranscodeTask) Finished() bool {
+	return t.State == TranscodeStateFinished || t.State == TranscodeStateFinishedInAdvance
+}
+
+func (t *TranscodeTask) Copy() *TranscodeTask {
+	task := &TranscodeTask{}
+	*task = *t
+	src := make([]VunitLocation, len(t.Sources))
+	copy(src, t.Sources)
+	task.Sources = src
+	dst := make([]VunitLocation, len(t.Destinations))
+	copy(dst, t.Destinations)
+	task.Destinations = dst
+	return task
+}
+
+//--------------------------------------------------------------------------------------------------
+
 type InspectCheckPoint struct {
 	Id       string `json:"_id" bson:"_id"`
 	StartVid Vid    `json:"start_vid" bson:"start_vid"` // min vid in current batch volumes
@@ -17,6 +17,7 @@ package resourcepool
 import (
 	"errors"
 	"sort"
+	"unsafe"
 )
 
 // ErrNoSuitableSizeClass no suitable pool of size
@@ -32,12 +33,23 @@ var zero = make([]byte, zeroLen)
 
 // MemPool reused buffer pool
 type MemPool struct {
-	pool     []Pool
-	poolSize []int
+	pool      []Pool
+	poolSize  []int
+	alignment int
 }
 
 // NewMemPool new MemPool with self-defined size-class and capacity
 func NewMemPool(sizeClasses map[int]int) *MemPool {
+	return newMemPool(sizeClasses, 0)
+}
+
+// NewAlignedMemPool new MemPool whose buffers start at address aligned with alignment,
+// alignment must be power of 2, used for direct io
+func NewAlignedMemPool(sizeClasses map[int]int, alignment int) *MemPool {
+	return newMemPool(sizeClasses, alignment)
+}
+
+func newMemPool(sizeClasses map[int]int, alignment int) *MemPool {
 	pool := make([]Pool, 0, len(sizeClasses))
 	poolSize := make([]int, 0, len(sizeClasses))
 	for sizeClass := range sizeClasses {
@@ -50,14 +62,28 @@ func NewMemPool(sizeClasses map[int]int) *MemPool {
 	for _, sizeClass := range poolSize {
 		size, capacity := sizeClass, sizeClasses[sizeClass]
 		pool = append(pool, NewChanPool(func() []byte {
-			return make([]byte, size)
+			return alignedBytes(size, alignment)
 		}, capacity))
 	}
 
 	return &MemPool{
-		pool:     pool,
-		poolSize: poolSize,
+		pool:      pool,
+		poolSize:  poolSize,
+		alignment: alignment,
+	}
+}
+
+func alignedBytes(size, alignment int) []byte {
+	if alignment <= 1 {
+		return make([]byte, size)
+	}
+	buf := make([]byte, size+alignment)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & uintptr(alignment-1)); rem != 0 {
+		off = alignment - rem
 	}
+	// cap is size, so that Put finds the right size class
+	return buf[off : off+size : off+size]
 }
 
 // Get return a suitable buffer
@@ -80,7 +106,7 @@ func (p *MemPool) Get(size int) ([]byte, error) {
 func (p *MemPool) Alloc(size int) ([]byte, error) {
 	buf, err := p.Get(size)
 	if err == ErrNoSuitableSizeClass {
-		return make([]byte, size), nil
+		return alignedBytes(size, p.alignment), nil
 	}
 
 	return buf, err
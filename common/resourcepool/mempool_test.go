@@ -18,6 +18,7 @@ import (
 	"crypto/rand"
 	"fmt"
 	"testing"
+	"unsafe"
 
 	"github.com/stretchr/testify/require"
 
@@ -63,6 +64,36 @@ func TestMemPoolBase(t *testing.T) {
 	require.Equal(t, bufm, bufmx)
 }
 
+func TestMemPoolAligned(t *testing.T) {
+	const alignment = 4 * kb
+	isAligned := func(b []byte) bool {
+		return uintptr(unsafe.Pointer(&b[0]))%alignment == 0
+	}
+
+	pool := rp.NewAlignedMemPool(map[int]int{kb: 2, 64 * kb: 2}, alignment)
+	for _, size := range []int{1, kb, kb + 1, 64 * kb} {
+		buf, err := pool.Get(size)
+		require.NoError(t, err)
+		require.Equal(t, size, len(buf))
+		require.True(t, isAligned(buf))
+		require.NoError(t, pool.Put(buf))
+	}
+
+	// oversize buffer is aligned too
+	buf, err := pool.Alloc(mb)
+	require.NoError(t, err)
+	require.Equal(t, mb, cap(buf))
+	require.True(t, isAligned(buf))
+
+	bufk, err := pool.Get(kb)
+	require.NoError(t, err)
+	require.Equal(t, kb, cap(bufk))
+	require.NoError(t, pool.Put(bufk))
+	bufkx, err := pool.Get(kb)
+	require.NoError(t, err)
+	require.True(t, &bufk[0] == &bufkx[0])
+}
+
 func TestMemPoolEmpty(t *testing.T) {
 	pool := rp.NewMemPool(nil)
 	require.NotNil(t, pool)
@@ -31,6 +31,7 @@ const (
 	BalanceSwitchName     = "balance"
 	DiskDropSwitchName    = "disk_drop"
 	BlobDeleteSwitchName  = "blob_delete"
+	BlobExpirySwitchName  = "blob_expiry"
 	ShardRepairSwitchName = "shard_repair"
 	VolInspectSwitchName  = "vol_inspect"
 )
@@ -75,6 +75,7 @@ func (d *BlobDeleteMgr) SendDeleteMsg(ctx context.Context, info *mqproxy.DeleteA
 			Bid:       blobInfo.Bid,
 			Time:      time.Now().Unix(),
 			ReqId:     span.TraceID(),
+			Packed:    blobInfo.Packed,
 		}
 
 		msgByte, err := json.Marshal(msg)
@@ -16,6 +16,8 @@ package client
 
 import (
 	"context"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/cubefs/blobstore/api/blobnode"
@@ -33,6 +35,7 @@ var (
 	defaultListDiskNum    = 1000
 	defaultListDiskMarker = proto.DiskID(0)
 	diskStatusAll         = proto.DiskStatus(0)
+	defaultListKvCount    = 1000
 )
 
 // VolumeInfoSimple volume info used by scheduler
@@ -205,6 +208,10 @@ type IClusterManager interface {
 	ReleaseVolumeUnit(ctx context.Context, args *cmapi.ReleaseVolumeUnitArgs) (err error)
 	ListVolumeUnit(ctx context.Context, args *cmapi.ListVolumeUnitArgs) ([]*cmapi.VolumeUnitInfo, error)
 	ListVolume(ctx context.Context, args *cmapi.ListVolumeArgs) (ret cmapi.ListVolumes, err error)
+	AllocVolume(ctx context.Context, args *cmapi.AllocVolumeArgs) (ret cmapi.AllocatedVolumeInfos, err error)
+	RetainVolume(ctx context.Context, args *cmapi.RetainVolumeArgs) (ret cmapi.RetainVolumes, err error)
+	SetVolumeRedirect(ctx context.Context, args *cmapi.VolumeRedirect) (err error)
+	ListKV(ctx context.Context, args *cmapi.ListKvArgs) (ret cmapi.ListKvRet, err error)
 	ListDisk(ctx context.Context, args *cmapi.ListOptionArgs) (ret cmapi.ListDiskRet, err error)
 	ListDroppingDisk(ctx context.Context) (ret []*blobnode.DiskInfo, err error)
 	SetDisk(ctx context.Context, id proto.DiskID, status proto.DiskStatus) (err error)
@@ -342,6 +349,78 @@ func (c *ClusterMgrClient) AllocVolumeUnit(ctx context.Context, vuid proto.Vuid)
 	return ret, err
 }
 
+// AllocVolume alloc a new volume of code mode, the volume is leased with the returned token
+func (c *ClusterMgrClient) AllocVolume(ctx context.Context, mode codemode.CodeMode) (*VolumeInfoSimple, string, error) {
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	pSpan := trace.SpanFromContextSafe(ctx)
+	span, ctx := trace.StartSpanFromContextWithTraceID(context.Background(), "AllocVolume", pSpan.TraceID())
+
+	span.Debugf("AllocVolume args code_mode %s", mode)
+	info, err := c.cli.AllocVolume(ctx, &cmapi.AllocVolumeArgs{CodeMode: mode, Count: 1})
+	if err != nil {
+		span.Errorf("AllocVolume fail err %+v", err)
+		return nil, "", err
+	}
+	if len(info.AllocVolumeInfos) == 0 {
+		return nil, "", errors.New("no volume allocated")
+	}
+	span.Debugf("AllocVolume ret %+v", info.AllocVolumeInfos[0])
+
+	ret := &VolumeInfoSimple{}
+	ret.set(&info.AllocVolumeInfos[0].VolumeInfo)
+	return ret, info.AllocVolumeInfos[0].Token, nil
+}
+
+// RetainVolume renew the lease of allocated volumes
+func (c *ClusterMgrClient) RetainVolume(ctx context.Context, tokens []string) (err error) {
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	pSpan := trace.SpanFromContextSafe(ctx)
+	span, ctx := trace.StartSpanFromContextWithTraceID(context.Background(), "RetainVolume", pSpan.TraceID())
+
+	span.Debugf("RetainVolume args tokens %v", tokens)
+	ret, err := c.cli.RetainVolume(ctx, &cmapi.RetainVolumeArgs{Tokens: tokens})
+	if err != nil {
+		span.Errorf("RetainVolume fail err %+v", err)
+		return
+	}
+	if len(ret.RetainVolTokens) != len(tokens) {
+		span.Warnf("RetainVolume retained %d of %d tokens", len(ret.RetainVolTokens), len(tokens))
+	}
+	return
+}
+
+// SealVolume lock the volume allocated with token, no more blobs written into it
+func (c *ClusterMgrClient) SealVolume(ctx context.Context, vid proto.Vid, token string) (err error) {
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	pSpan := trace.SpanFromContextSafe(ctx)
+	span, ctx := trace.StartSpanFromContextWithTraceID(context.Background(), "SealVolume", pSpan.TraceID())
+
+	span.Debugf("SealVolume args vid %d token %s", vid, token)
+	err = c.cli.LockVolume(ctx, &cmapi.LockVolumeArgs{Vid: vid, Token: token})
+	span.Debugf("SealVolume ret err %+v", err)
+	return
+}
+
+// SetVolumeRedirect redirect reads of volume to the new volume
+func (c *ClusterMgrClient) SetVolumeRedirect(ctx context.Context, redirect *cmapi.VolumeRedirect) (err error) {
+	c.rwLock.Lock()
+	defer c.rwLock.Unlock()
+
+	pSpan := trace.SpanFromContextSafe(ctx)
+	span, ctx := trace.StartSpanFromContextWithTraceID(context.Background(), "SetVolumeRedirect", pSpan.TraceID())
+
+	span.Infof("SetVolumeRedirect args %+v", *redirect)
+	err = c.cli.SetVolumeRedirect(ctx, redirect)
+	span.Infof("SetVolumeRedirect ret err %+v", err)
+	return
+}
+
 // ReleaseVolumeUnit release volume unit
 func (c *ClusterMgrClient) ReleaseVolumeUnit(ctx context.Context, vuid proto.Vuid, diskID proto.DiskID) (err error) {
 	c.rwLock.Lock()
@@ -376,6 +455,13 @@ func (c *ClusterMgrClient) ListDiskVolumeUnits(ctx context.Context, diskID proto
 		span.Debugf("ListDiskVolumeUnits.ListVolumeUnit ret idx %d info %+v", idx, *info)
 	}
 
+	// units of redirected volumes have been released after transcoded
+	redirected, err := c.listRedirectedVolumes(ctx)
+	if err != nil {
+		span.Errorf("ListDiskVolumeUnits.listRedirectedVolumes fail err %+v", err)
+		return nil, err
+	}
+
 	span.Debugf("ListDiskVolumeUnits.DiskInfo args diskID %d", diskID)
 	diskInfo, err := c.cli.DiskInfo(ctx, diskID)
 	if err != nil {
@@ -385,6 +471,9 @@ func (c *ClusterMgrClient) ListDiskVolumeUnits(ctx context.Context, diskID proto
 	span.Debugf("ListDiskVolumeUnits.DiskInfo ret %+v", *diskInfo)
 
 	for _, info := range infos {
+		if _, ok := redirected[info.Vuid.Vid()]; ok {
+			continue
+		}
 		ele := VunitInfoSimple{}
 		ele.set(info, diskInfo.Host)
 		rets = append(rets, &ele)
@@ -404,7 +493,14 @@ func (c *ClusterMgrClient) ListVolume(ctx context.Context, marker proto.Vid, cou
 	if err != nil {
 		return
 	}
+	redirected, err := c.listRedirectedVolumes(ctx)
+	if err != nil {
+		return
+	}
 	for index := range vols.Volumes {
+		if _, ok := redirected[vols.Volumes[index].Vid]; ok {
+			continue
+		}
 		ret := &VolumeInfoSimple{}
 		ret.set(vols.Volumes[index])
 		rets = append(rets, ret)
@@ -413,6 +509,28 @@ func (c *ClusterMgrClient) ListVolume(ctx context.Context, marker proto.Vid, cou
 	return
 }
 
+func (c *ClusterMgrClient) listRedirectedVolumes(ctx context.Context) (map[proto.Vid]struct{}, error) {
+	vids := make(map[proto.Vid]struct{})
+	args := &cmapi.ListKvArgs{Prefix: cmapi.VolumeRedirectPrefix, Count: defaultListKvCount}
+	for {
+		ret, err := c.cli.ListKV(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range ret.Kvs {
+			vid, err := strconv.ParseUint(strings.TrimPrefix(kv.Key, cmapi.VolumeRedirectPrefix), 10, 32)
+			if err != nil {
+				return nil, err
+			}
+			vids[proto.Vid(vid)] = struct{}{}
+		}
+		if len(ret.Kvs) == 0 || ret.Marker == "" {
+			return vids, nil
+		}
+		args.Marker = ret.Marker
+	}
+}
+
 // ListClusterDisks list all disks
 func (c *ClusterMgrClient) ListClusterDisks(ctx context.Context) (disks []*DiskInfoSimple, err error) {
 	c.rwLock.RLock()
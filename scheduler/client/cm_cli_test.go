@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 	"sync"
 	"testing"
 
@@ -95,7 +96,50 @@ func (c *mockCM) GetVolumeInfo(ctx context.Context, args *cmapi.GetVolumeArgs) (
 	return vol, nil
 }
 
-func (c *mockCM) AllocVolume(ctx context.Context, codeMode codemode.CodeMode, baseDiskID proto.DiskID) (ret *cmapi.VolumeInfo, err error) {
+func (c *mockCM) AllocVolume(ctx context.Context, args *cmapi.AllocVolumeArgs) (ret cmapi.AllocatedVolumeInfos, err error) {
+	info, err := c.createVolume(ctx, args.CodeMode, 60)
+	if err != nil {
+		return
+	}
+	info.Status = proto.VolumeStatusActive
+	ret.AllocVolumeInfos = []cmapi.AllocVolumeInfo{{VolumeInfo: *info, Token: uuid.New().String()}}
+	return
+}
+
+func (c *mockCM) SetVolumeRedirect(ctx context.Context, args *cmapi.VolumeRedirect) (err error) {
+	c.kvRW.Lock()
+	defer c.kvRW.Unlock()
+
+	c.kv[cmapi.VolumeRedirectKey(args.Vid)] = args.NewVid.ToString()
+	return
+}
+
+func (c *mockCM) RetainVolume(ctx context.Context, args *cmapi.RetainVolumeArgs) (ret cmapi.RetainVolumes, err error) {
+	for _, token := range args.Tokens {
+		ret.RetainVolTokens = append(ret.RetainVolTokens, cmapi.RetainVolume{Token: token})
+	}
+	return
+}
+
+func (c *mockCM) ListKV(ctx context.Context, args *cmapi.ListKvArgs) (ret cmapi.ListKvRet, err error) {
+	c.kvRW.RLock()
+	defer c.kvRW.RUnlock()
+
+	if args.Marker != "" {
+		return
+	}
+	for key, val := range c.kv {
+		if strings.HasPrefix(key, args.Prefix) {
+			ret.Kvs = append(ret.Kvs, cmapi.KeyValue{Key: key, Value: []byte(val)})
+		}
+	}
+	if len(ret.Kvs) > 0 {
+		ret.Marker = ret.Kvs[len(ret.Kvs)-1].Key
+	}
+	return
+}
+
+func (c *mockCM) createVolume(ctx context.Context, codeMode codemode.CodeMode, baseDiskID proto.DiskID) (ret *cmapi.VolumeInfo, err error) {
 	c.volRW.Lock()
 	defer c.volRW.Unlock()
 
@@ -158,7 +202,7 @@ func (c *mockCM) LockVolume(ctx context.Context, args *cmapi.LockVolumeArgs) (er
 	if vol.Status == proto.VolumeStatusLock {
 		return nil
 	}
-	if vol.Status == proto.VolumeStatusActive {
+	if vol.Status == proto.VolumeStatusActive && args.Token == "" {
 		return cmerrors.ErrActiveVolume
 	}
 	vol.Status = proto.VolumeStatusLock
@@ -383,7 +427,7 @@ func TestCMClient(t *testing.T) {
 	_, err = cmCli.GetVolumeInfo(ctx, 0)
 	require.Error(t, err)
 
-	ret, err := cli.AllocVolume(ctx, codemode.EC6P10L2, 1)
+	ret, err := cli.createVolume(ctx, codemode.EC6P10L2, 1)
 	require.NoError(t, err)
 	_, err = cmCli.GetVolumeInfo(ctx, ret.Vid)
 	require.NoError(t, err)
@@ -438,7 +482,7 @@ func TestCMClient(t *testing.T) {
 	require.Equal(t, 1, len(volumes))
 	require.Equal(t, defaultVolumeListMarker, marker)
 
-	_, err = cli.AllocVolume(ctx, codemode.EC6P6, 20)
+	_, err = cli.createVolume(ctx, codemode.EC6P6, 20)
 	require.NoError(t, err)
 
 	volumes, marker, err = cmCli.ListVolume(ctx, defaultVolumeListMarker, 1)
@@ -492,6 +536,34 @@ func TestCMClient(t *testing.T) {
 	disks, err = cmCli.ListDropDisks(ctx)
 	require.NoError(t, err)
 	require.Equal(t, 96, len(disks))
+
+	newVol, token, err := cmCli.AllocVolume(ctx, codemode.EC6P6)
+	require.NoError(t, err)
+	require.Equal(t, codemode.EC6P6, newVol.CodeMode)
+	require.Equal(t, 12, len(newVol.VunitLocations))
+	err = cmCli.RetainVolume(ctx, []string{token})
+	require.NoError(t, err)
+	err = cmCli.SealVolume(ctx, newVol.Vid, token)
+	require.NoError(t, err)
+	require.Equal(t, proto.VolumeStatusLock, cli.volumeMap[newVol.Vid].Status)
+
+	err = cmCli.SetVolumeRedirect(ctx, &cmapi.VolumeRedirect{Vid: ret.Vid, NewVid: newVol.Vid})
+	require.NoError(t, err)
+	require.Equal(t, newVol.Vid.ToString(), cli.kv[cmapi.VolumeRedirectKey(ret.Vid)])
+
+	// units of redirected volume are not listed
+	vols, _, err := cmCli.ListVolume(ctx, 0, 1000)
+	require.NoError(t, err)
+	for _, vol := range vols {
+		require.NotEqual(t, ret.Vid, vol.Vid)
+	}
+	for _, unit := range ret.Units {
+		units, err := cmCli.ListDiskVolumeUnits(ctx, unit.DiskID)
+		require.NoError(t, err)
+		for _, u := range units {
+			require.NotEqual(t, ret.Vid, u.Vuid.Vid())
+		}
+	}
 }
 
 func initClusterDisks(ctx context.Context, cli *mockCM) {
@@ -41,6 +41,7 @@ type Config struct {
 	DiskDropTblName          string           `json:"disk_drop_tbl_name"`
 	ManualMigrateTblName     string           `json:"manual_migrate_tbl_name"`
 	RepairTblName            string           `json:"repair_tbl_name"`
+	TranscodeTblName         string           `json:"transcode_tbl_name"`
 	InspectCheckPointTblName string           `json:"inspect_checkpoint_tbl_name"`
 	SvrRegisterTblName       string           `json:"svr_register_tbl_name"`
 }
@@ -53,6 +54,7 @@ type Database struct {
 	DiskDropTbl          IMigrateTaskTbl
 	ManualMigrateTbl     IMigrateTaskTbl
 	RepairTaskTbl        IRepairTaskTbl
+	TranscodeTaskTbl     ITranscodeTaskTbl
 	InspectCheckPointTbl IInspectCheckPointTbl
 	SvrRegisterTbl       ISvrRegisterTbl
 }
@@ -110,6 +112,13 @@ func openTaskDataBase(conf *Config) (*Database, error) {
 		return nil, err
 	}
 
+	db.TranscodeTaskTbl, err = OpenTranscodeTaskTbl(
+		mustCreateCollection(db0, conf.TranscodeTblName),
+		proto.TranscodeTaskType)
+	if err != nil {
+		return nil, err
+	}
+
 	db.InspectCheckPointTbl, err = OpenInspectCheckPointTbl(mustCreateCollection(db0, conf.InspectCheckPointTblName))
 	if err != nil {
 		return nil, err
@@ -0,0 +1,146 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+)
+
+// ITranscodeTaskTbl define the interface of db used by volume transcode
+type ITranscodeTaskTbl interface {
+	Insert(ctx context.Context, t *proto.TranscodeTask) error
+	Update(ctx context.Context, t *proto.TranscodeTask) error
+	Find(ctx context.Context, taskID string) (task *proto.TranscodeTask, err error)
+	FindBySourceVid(ctx context.Context, vid proto.Vid) (tasks []*proto.TranscodeTask, err error)
+	FindAll(ctx context.Context) (tasks []*proto.TranscodeTask, err error)
+}
+
+// TranscodeTaskTbl volume transcode task table
+type TranscodeTaskTbl struct {
+	coll *mongo.Collection
+	name string
+}
+
+// OpenTranscodeTaskTbl open volume transcode task table
+func OpenTranscodeTaskTbl(coll *mongo.Collection, name string) (ITranscodeTaskTbl, error) {
+	tbl := &TranscodeTaskTbl{
+		coll: coll,
+		name: name,
+	}
+	err := ArchiveStoreInst().registerArchiveStore(name, tbl)
+	return tbl, err
+}
+
+// Insert insert task
+func (tbl *TranscodeTaskTbl) Insert(ctx context.Context, t *proto.TranscodeTask) error {
+	t.Ctime = time.Now().String()
+	t.MTime = time.Now().String()
+	_, err := tbl.coll.InsertOne(ctx, t)
+	return err
+}
+
+// Update update task
+func (tbl *TranscodeTaskTbl) Update(ctx context.Context, t *proto.TranscodeTask) error {
+	span := trace.SpanFromContextSafe(ctx)
+	span.Debugf("update transcode task tbl task %+v", *t)
+
+	t.MTime = time.Now().String()
+	return tbl.coll.FindOneAndReplace(ctx, bson.M{"_id": t.TaskID}, t).Err()
+}
+
+// Find find task by taskID
+func (tbl *TranscodeTaskTbl) Find(ctx context.Context, taskID string) (task *proto.TranscodeTask, err error) {
+	err = tbl.coll.FindOne(ctx, bson.M{"_id": taskID, DeleteMark: bson.M{"$ne": true}}).Decode(&task)
+	return
+}
+
+// FindBySourceVid find tasks by source vid
+func (tbl *TranscodeTaskTbl) FindBySourceVid(ctx context.Context, vid proto.Vid) (tasks []*proto.TranscodeTask, err error) {
+	cursor, err := tbl.coll.Find(ctx, bson.M{"source_vid": vid, DeleteMark: bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &tasks)
+	return tasks, err
+}
+
+// FindAll return all tasks
+func (tbl *TranscodeTaskTbl) FindAll(ctx context.Context) (tasks []*proto.TranscodeTask, err error) {
+	cursor, err := tbl.coll.Find(ctx, bson.M{DeleteMark: bson.M{"$ne": true}})
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &tasks)
+	return tasks, err
+}
+
+// QueryMarkDeleteTasks find mark delete tasks
+func (tbl *TranscodeTaskTbl) QueryMarkDeleteTasks(ctx context.Context, delayMin int) (records []*ArchiveRecord, err error) {
+	span := trace.SpanFromContextSafe(ctx)
+
+	type TranscodeTaskEx struct {
+		proto.TranscodeTask `bson:",inline"`
+		DelTime             int64 `bson:"del_time"`
+	}
+	var tasks []*TranscodeTaskEx
+	cursor, err := tbl.coll.Find(ctx, bson.M{DeleteMark: true})
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, task := range tasks {
+		content, err := json.MarshalIndent(task, "", "\t")
+		if err != nil {
+			span.Warnf("task_id %s marshal fail err:%+v", task.TaskID, err)
+			continue
+		}
+
+		if inDelayTime(task.DelTime, delayMin) {
+			span.Debugf("task_id %s is in delay time", task.TaskID)
+			continue
+		}
+
+		r := &ArchiveRecord{
+			TaskID:   task.TaskID,
+			TaskType: tbl.Name(),
+			Content:  string(content),
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// RemoveMarkDelete remove mark delete task by taskID
+func (tbl *TranscodeTaskTbl) RemoveMarkDelete(ctx context.Context, taskID string) error {
+	_, err := tbl.coll.DeleteOne(ctx, bson.M{"_id": taskID, DeleteMark: true})
+	return err
+}
+
+// Name return transcode table name
+func (tbl *TranscodeTaskTbl) Name() string {
+	return tbl.name
+}
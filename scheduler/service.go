@@ -40,11 +40,14 @@ type Service struct {
 	diskDropMgr    *DiskDropMgr
 	manualMigMgr   *ManualMigrateMgr
 	repairMgr      *RepairMgr
+	transcodeMgr   *TranscodeMgr
 	inspectMgr     *InspectMgr
 
 	svrTbl db.ISvrRegisterTbl
 
 	cmCli client.IClusterMgr
+
+	closeCh chan struct{}
 }
 
 // HTTPTaskAcquire acquire task
@@ -97,6 +100,16 @@ func (svr *Service) HTTPTaskAcquire(c *rpc.Context) {
 		return
 	}
 
+	transcodeTask, err := svr.transcodeMgr.AcquireTask(ctx, args.IDC)
+	if err == nil {
+		ret := &api.WorkerTask{
+			TaskType:  proto.TranscodeTaskType,
+			Transcode: transcodeTask,
+		}
+		c.RespondJSON(ret)
+		return
+	}
+
 	c.RespondError(comerrs.ErrNothingTodo)
 }
 
@@ -112,6 +125,12 @@ func (svr *Service) HTTPTaskReclaim(c *rpc.Context) {
 	}
 	span.Infof("reclaim task args==>%+v", args)
 
+	// destination of transcode task is the whole allocated volume
+	if args.TaskType == proto.TranscodeTaskType {
+		c.RespondError(rpc.NewError(http.StatusBadRequest, "illegal_type", comerrs.ErrIllegalTaskType))
+		return
+	}
+
 	newDst, err := base.AllocVunitSafe(ctx, svr.cmCli, args.Dest.Vuid, args.Src)
 	if err != nil {
 		c.RespondError(err)
@@ -156,6 +175,8 @@ func (svr *Service) HTTPTaskCancel(c *rpc.Context) {
 		err = svr.diskDropMgr.CancelTask(ctx, args)
 	case proto.ManualMigrateType:
 		err = svr.manualMigMgr.CancelTask(ctx, args)
+	case proto.TranscodeTaskType:
+		err = svr.transcodeMgr.CancelTask(ctx, args)
 	default:
 		c.RespondError(rpc.NewError(http.StatusBadRequest, "illegal_type", comerrs.ErrIllegalTaskType))
 		return
@@ -185,6 +206,8 @@ func (svr *Service) HTTPTaskComplete(c *rpc.Context) {
 		err = svr.diskDropMgr.CompleteTask(ctx, args)
 	case proto.ManualMigrateType:
 		err = svr.manualMigMgr.CompleteTask(ctx, args)
+	case proto.TranscodeTaskType:
+		err = svr.transcodeMgr.CompleteTask(ctx, args)
 	default:
 		c.RespondError(rpc.NewError(http.StatusBadRequest, "illegal_type", comerrs.ErrIllegalTaskType))
 		return
@@ -242,6 +265,7 @@ func (svr *Service) HTTPTaskRenewal(c *rpc.Context) {
 		Balance:       make(map[string]string),
 		DiskDrop:      make(map[string]string),
 		ManualMigrate: make(map[string]string),
+		Transcode:     make(map[string]string),
 	}
 
 	for taskID := range args.Repair {
@@ -264,6 +288,11 @@ func (svr *Service) HTTPTaskRenewal(c *rpc.Context) {
 		ret.ManualMigrate[taskID] = getErrMsg(err)
 	}
 
+	for taskID := range args.Transcode {
+		err := svr.transcodeMgr.RenewalTask(ctx, idc, taskID)
+		ret.Transcode[taskID] = getErrMsg(err)
+	}
+
 	c.RespondJSON(ret)
 }
 
@@ -308,6 +337,12 @@ func (svr *Service) HTTPTaskReport(c *rpc.Context) {
 			args.TaskStats,
 			args.IncreaseDataSizeByte,
 			args.IncreaseShardCnt)
+	case proto.TranscodeTaskType:
+		svr.transcodeMgr.ReportWorkerTaskStats(
+			args.TaskId,
+			args.TaskStats,
+			args.IncreaseDataSizeByte,
+			args.IncreaseShardCnt)
 	}
 
 	c.Respond()
@@ -466,6 +501,29 @@ func (svr *Service) HTTPManualMigrateTaskDetail(c *rpc.Context) {
 	c.RespondJSON(taskDetail)
 }
 
+// HTTPTranscodeTaskDetail returns transcode task detail stats
+func (svr *Service) HTTPTranscodeTaskDetail(c *rpc.Context) {
+	ctx := c.Request.Context()
+
+	args := new(api.TaskStatArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	taskInfo, runStats, err := svr.transcodeMgr.QueryTask(ctx, args.TaskId)
+	if err != nil {
+		c.RespondError(rpc.NewError(http.StatusNotFound, "not found", errors.New("task not found")))
+		return
+	}
+
+	taskDetail := api.TranscodeTaskDetail{
+		TaskInfo: taskInfo,
+		RunStats: runStats,
+	}
+	c.RespondJSON(taskDetail)
+}
+
 // HTTPRepairTaskDetail returns repair task detail stats
 func (svr *Service) HTTPRepairTaskDetail(c *rpc.Context) {
 	ctx := c.Request.Context()
@@ -590,6 +648,23 @@ func (svr *Service) HTTPStats(c *rpc.Context) {
 		},
 	}
 
+	// stats transcode tasks
+	finishedCnt, dataSizeByte, shardCnt = svr.transcodeMgr.GetTaskStats()
+	preparing, workerDoing, finishing = svr.transcodeMgr.StatQueueTaskCnt()
+
+	transcode := api.TranscodeTasksStat{
+		MigrateTasksStat: api.MigrateTasksStat{
+			PreparingCnt:   preparing,
+			WorkerDoingCnt: workerDoing,
+			FinishingCnt:   finishing,
+			StatsPerMin: api.PerMinStats{
+				FinishedCnt:    fmt.Sprint(finishedCnt),
+				DataAmountByte: base.DataMountFormat(dataSizeByte),
+				ShardCnt:       fmt.Sprint(shardCnt),
+			},
+		},
+	}
+
 	// stats inspect tasks
 	var finished, timeout [counter.SLOT]int
 	if svr.inspectMgr != nil {
@@ -614,6 +689,7 @@ func (svr *Service) HTTPStats(c *rpc.Context) {
 		Drop:          drop,
 		Balance:       balance,
 		ManualMigrate: manualMigrate,
+		Transcode:     transcode,
 		Inspect:       inspect,
 	}
 
@@ -638,3 +714,22 @@ func (svr *Service) HTTPManualMigrateTaskAdd(c *rpc.Context) {
 	err := svr.manualMigMgr.AddTask(ctx, args.Vuid, !args.DirectDownload)
 	c.RespondError(rpc.Error2HTTPError(err))
 }
+
+// HTTPTranscodeTaskAdd adds volume transcode task
+func (svr *Service) HTTPTranscodeTaskAdd(c *rpc.Context) {
+	ctx := c.Request.Context()
+
+	args := new(api.AddTranscodeArgs)
+	if err := c.ParseArgs(args); err != nil {
+		c.RespondError(err)
+		return
+	}
+
+	if !args.Valid() {
+		c.RespondError(comerrs.ErrIllegalArguments)
+		return
+	}
+
+	err := svr.transcodeMgr.AddTask(ctx, args.Vid, args.CodeMode)
+	c.RespondError(rpc.Error2HTTPError(err))
+}
@@ -117,6 +117,9 @@ func init() {
 	}
 	repairMgr.taskSwitch.Enable()
 
+	transcodeMgr := NewTranscodeMgr(newMockTranscodeCmClient(map[proto.Vid]*client.VolumeInfoSimple{}),
+		newMockTranscodeTbl(), clusterID)
+
 	inspectCfg := &InspectMgrCfg{
 		InspectBatch:      3,
 		ListVolIntervalMs: 100,
@@ -140,6 +143,7 @@ func init() {
 		diskDropMgr:    diskDropMgr,
 		manualMigMgr:   manualMigMgr,
 		repairMgr:      repairMgr,
+		transcodeMgr:   transcodeMgr,
 		inspectMgr:     inspectMgr,
 		svrTbl:         serviceRegisterTbl,
 		cmCli:          clusterMgrCli,
@@ -385,6 +389,12 @@ func TestTaskAPI(t *testing.T) {
 
 	_, err = schedulerCli.ManualMigrateTaskDetail(context.Background(), &scheduler.TaskStatArgs{TaskId: ""})
 	require.Error(t, err)
+	_, err = schedulerCli.TranscodeTaskDetail(context.Background(), &scheduler.TaskStatArgs{TaskId: ""})
+	require.Error(t, err)
+	err = schedulerCli.ReclaimTask(context.Background(), &scheduler.ReclaimTaskArgs{
+		TaskType: proto.TranscodeTaskType,
+	})
+	require.EqualError(t, err, errors.ErrIllegalTaskType.Error())
 
 	// stats
 	_, err = schedulerCli.Stats(context.Background())
@@ -394,6 +404,12 @@ func TestTaskAPI(t *testing.T) {
 	err = schedulerCli.AddManualMigrateTask(context.Background(), &scheduler.AddManualMigrateArgs{Vuid: 0})
 	require.Error(t, err)
 	require.EqualError(t, errors.ErrIllegalArguments, err.Error())
+
+	// add transcode task
+	err = schedulerCli.AddTranscodeTask(context.Background(), &scheduler.AddTranscodeArgs{Vid: 1})
+	require.EqualError(t, errors.ErrIllegalArguments, err.Error())
+	err = schedulerCli.AddTranscodeTask(context.Background(), &scheduler.AddTranscodeArgs{Vid: 1, CodeMode: codemode.EC6P6})
+	require.Error(t, err)
 }
 
 func newServiceRegisterTbl() db.ISvrRegisterTbl {
@@ -15,6 +15,7 @@
 package scheduler
 
 import (
+	"context"
 	"time"
 
 	"github.com/cubefs/blobstore/api/clustermgr"
@@ -152,6 +153,9 @@ func (c *Config) checkAndFixDataBaseCfg() {
 	if c.Database.RepairTblName == "" {
 		c.Database.RepairTblName = "repair_tbl"
 	}
+	if c.Database.TranscodeTblName == "" {
+		c.Database.TranscodeTblName = "transcode_tbl"
+	}
 	if c.Database.InspectCheckPointTblName == "" {
 		c.Database.InspectCheckPointTblName = "inspect_checkpoint_tbl"
 	}
@@ -263,6 +267,12 @@ func NewService(conf *Config) (svr *Service, err error) {
 		return nil, err
 	}
 
+	// tasks of runtime code modes need their tactics
+	if err = clustermgr.SyncCodeModes(context.Background(), clusterMgrCli); err != nil {
+		log.Errorf("sync code modes fail err:%+v", err)
+		return nil, err
+	}
+
 	tinkerCli := client.NewTinkerClient(conf.Tinker)
 
 	switchMgr := taskswitch.NewSwitchMgr(clusterMgrCli)
@@ -321,6 +331,12 @@ func NewService(conf *Config) (svr *Service, err error) {
 		return nil, err
 	}
 
+	// new volume transcode manager
+	transcodeMgr := NewTranscodeMgr(
+		clusterMgrCli,
+		database.TranscodeTaskTbl,
+		conf.ClusterID)
+
 	// new inspect manger
 	var inspectMgr *InspectMgr
 	mqProxy, err := client.NewMqProxyClient(conf.MqProxy, conf.ClusterMgr, conf.ClusterID)
@@ -351,10 +367,13 @@ func NewService(conf *Config) (svr *Service, err error) {
 		diskDropMgr:    diskDropMgr,
 		manualMigMgr:   manualMigMgr,
 		repairMgr:      repairMgr,
+		transcodeMgr:   transcodeMgr,
 		inspectMgr:     inspectMgr,
 		svrTbl:         database.SvrRegisterTbl,
 
 		cmCli: clusterMgrCli,
+
+		closeCh: make(chan struct{}),
 	}
 
 	err = svr.waitAndLoad()
@@ -364,6 +383,7 @@ func NewService(conf *Config) (svr *Service, err error) {
 	}
 
 	go svr.Run()
+	go clustermgr.LoopSyncCodeModes(clusterMgrCli, clustermgr.CodeModeSyncInterval, svr.closeCh)
 	return svr, nil
 }
 
@@ -396,6 +416,11 @@ func (svr *Service) load() (err error) {
 		return
 	}
 
+	err = svr.transcodeMgr.Load()
+	if err != nil {
+		return
+	}
+
 	return
 }
 
@@ -405,6 +430,7 @@ func (svr *Service) Run() {
 	svr.balanceMgr.Run()
 	svr.diskDropMgr.Run()
 	svr.manualMigMgr.Run()
+	svr.transcodeMgr.Run()
 
 	if svr.inspectMgr != nil {
 		svr.inspectMgr.Run()
@@ -414,6 +440,7 @@ func (svr *Service) Run() {
 // Close close service safe
 func (svr *Service) Close() {
 	svr.balanceMgr.Close()
+	close(svr.closeCh)
 }
 
 // NewHandler returns app server handler
@@ -423,6 +450,7 @@ func NewHandler(service *Service) *rpc.Router {
 	rpc.RegisterArgsParser(&api.CancelTaskArgs{}, "json")
 	rpc.RegisterArgsParser(&api.CompleteTaskArgs{}, "json")
 	rpc.RegisterArgsParser(&api.AddManualMigrateArgs{}, "json")
+	rpc.RegisterArgsParser(&api.AddTranscodeArgs{}, "json")
 
 	rpc.RegisterArgsParser(&api.CompleteInspectArgs{}, "json")
 
@@ -442,6 +470,7 @@ func NewHandler(service *Service) *rpc.Router {
 	rpc.POST("/task/cancel", service.HTTPTaskCancel, rpc.OptArgsBody())
 	rpc.POST("/task/complete", service.HTTPTaskComplete, rpc.OptArgsBody())
 	rpc.POST("/manual/migrate/task/add", service.HTTPManualMigrateTaskAdd, rpc.OptArgsBody())
+	rpc.POST("/transcode/task/add", service.HTTPTranscodeTaskAdd, rpc.OptArgsBody())
 
 	rpc.GET("/inspect/acquire", service.HTTPInspectAcquire, rpc.OptArgsQuery())
 	rpc.POST("/inspect/complete", service.HTTPInspectComplete, rpc.OptArgsBody())
@@ -453,6 +482,7 @@ func NewHandler(service *Service) *rpc.Router {
 	rpc.POST("/repair/task/detail", service.HTTPRepairTaskDetail, rpc.OptArgsBody())
 	rpc.POST("/drop/task/detail", service.HTTPDropTaskDetail, rpc.OptArgsBody())
 	rpc.POST("/manual/migrate/task/detail", service.HTTPManualMigrateTaskDetail, rpc.OptArgsBody())
+	rpc.POST("/transcode/task/detail", service.HTTPTranscodeTaskDetail, rpc.OptArgsBody())
 	rpc.GET("/stats", service.HTTPStats, rpc.OptArgsQuery())
 
 	rpc.GET("/service/list", service.HTTPServiceList, rpc.OptArgsQuery())
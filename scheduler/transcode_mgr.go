@@ -0,0 +1,577 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	api "github.com/cubefs/blobstore/api/scheduler"
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/counter"
+	comerrs "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/interrupt"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/taskswitch"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/scheduler/base"
+	"github.com/cubefs/blobstore/scheduler/client"
+	"github.com/cubefs/blobstore/scheduler/db"
+	"github.com/cubefs/blobstore/util/log"
+)
+
+// volume transcode
+const (
+	// renew the lease of destination volume before it expires in clustermgr
+	transcodeRetainInterval = 60 * time.Second
+	// release source volume units after access expires its cached volume
+	defaultTranscodeReleaseDelay = 2 * time.Hour
+)
+
+var errSourceReleaseDelayed = errors.New("source release is delayed")
+
+// errors of adding transcode task
+var (
+	ErrTranscodeActiveVolume = rpc.NewError(http.StatusBadRequest, "active_volume",
+		errors.New("can not transcode active volume"))
+	ErrTranscodeSameCodeMode = rpc.NewError(http.StatusBadRequest, "same_code_mode",
+		errors.New("volume is already in the code mode"))
+	ErrTranscodeTaskExist = rpc.NewError(http.StatusConflict, "task_exist",
+		errors.New("volume has been transcoded or is transcoding"))
+)
+
+// ITranscodeCmCli define the interface of clustermgr used by volume transcode
+type ITranscodeCmCli interface {
+	GetVolumeInfo(ctx context.Context, Vid proto.Vid) (ret *client.VolumeInfoSimple, err error)
+	LockVolume(ctx context.Context, Vid proto.Vid) (err error)
+	GetDiskInfo(ctx context.Context, diskID proto.DiskID) (ret *client.DiskInfoSimple, err error)
+	AllocVolume(ctx context.Context, mode codemode.CodeMode) (ret *client.VolumeInfoSimple, token string, err error)
+	RetainVolume(ctx context.Context, tokens []string) (err error)
+	SealVolume(ctx context.Context, vid proto.Vid, token string) (err error)
+	SetVolumeRedirect(ctx context.Context, redirect *cmapi.VolumeRedirect) (err error)
+	ReleaseVolumeUnit(ctx context.Context, vuid proto.Vuid, diskID proto.DiskID) (err error)
+}
+
+// TranscodeMgr volume transcode task manager,
+// the destination volume is leased until transcoded and sealed, then reads and deletes
+// of the source volume are redirected to it, and source volume units are released later.
+type TranscodeMgr struct {
+	taskTbl db.ITranscodeTaskTbl
+
+	leaseLock sync.Mutex
+	leases    map[string]string // task id -> lease token of destination volume
+
+	releaseDelay time.Duration
+
+	prepareQueue *base.TaskQueue       // store inited task
+	workQueue    *base.WorkerTaskQueue // store prepared task
+	finishQueue  *base.TaskQueue       // store completed task
+
+	cmCli ITranscodeCmCli
+
+	taskSwitch *taskswitch.TaskSwitch
+
+	// for stats
+	finishTaskCounter counter.CounterByMin
+	taskStatsMgr      *base.TaskStatsMgr
+
+	MigrateConfig
+}
+
+// NewTranscodeMgr returns volume transcode manager
+func NewTranscodeMgr(cmCli ITranscodeCmCli, taskTbl db.ITranscodeTaskTbl, clusterID proto.ClusterID) *TranscodeMgr {
+	cfg := defaultMigrateConfig(clusterID)
+	mgr := &TranscodeMgr{
+		taskTbl:      taskTbl,
+		prepareQueue: base.NewTaskQueue(time.Duration(cfg.PrepareQueueRetryDelayS) * time.Second),
+		workQueue:    base.NewWorkerTaskQueue(time.Duration(cfg.CancelPunishDurationS) * time.Second),
+		finishQueue:  base.NewTaskQueue(time.Duration(cfg.FinishQueueRetryDelayS) * time.Second),
+
+		leases:       make(map[string]string),
+		releaseDelay: defaultTranscodeReleaseDelay,
+
+		cmCli:         cmCli,
+		taskSwitch:    taskswitch.NewEnabledTaskSwitch(),
+		MigrateConfig: cfg,
+	}
+	mgr.taskStatsMgr = base.NewTaskStatsMgr(clusterID, proto.TranscodeTaskType)
+	return mgr
+}
+
+// Load load transcode task from database
+func (mgr *TranscodeMgr) Load() error {
+	log.Infof("TranscodeMgr start load...")
+	ctx := context.Background()
+
+	tasks, err := mgr.taskTbl.FindAll(ctx)
+	if err != nil {
+		log.Errorf("find all transcode tasks failed, err:%v", err)
+		return err
+	}
+	log.Infof("transcode load tasks len %d", len(tasks))
+
+	for _, t := range tasks {
+		if t.Running() {
+			err = VolTaskLockerInst().TryLock(ctx, t.SourceVid)
+			if err != nil {
+				log.Panicf("transcode task conflict,task:%+v,err:%+v", t, err.Error())
+			}
+		}
+
+		log.Infof("load task taskId %s state %d", t.TaskID, t.State)
+		switch t.State {
+		case proto.TranscodeStateInited:
+			mgr.prepareQueue.PushTask(t.TaskID, t)
+		case proto.TranscodeStatePrepared:
+			mgr.addLease(t)
+			mgr.workQueue.AddPreparedTask(t.SourceIdc, t.TaskID, t)
+		case proto.TranscodeStateWorkCompleted:
+			mgr.addLease(t)
+			mgr.finishQueue.PushTask(t.TaskID, t)
+		case proto.TranscodeStateRedirected:
+			mgr.finishQueue.PushTask(t.TaskID, t)
+		case proto.TranscodeStateFinished, proto.TranscodeStateFinishedInAdvance:
+			continue
+		default:
+			log.Panicf("unexpect transcode state,task:%+v", t)
+		}
+	}
+	return nil
+}
+
+// Run run transcode task includes prepare/finish phase
+func (mgr *TranscodeMgr) Run() {
+	go mgr.prepareTaskLoop()
+	go mgr.finishTaskLoop()
+	go mgr.retainVolumeLoop()
+}
+
+func (mgr *TranscodeMgr) addLease(t *proto.TranscodeTask) {
+	if t.DestToken == "" {
+		return
+	}
+	mgr.leaseLock.Lock()
+	mgr.leases[t.TaskID] = t.DestToken
+	mgr.leaseLock.Unlock()
+}
+
+func (mgr *TranscodeMgr) removeLease(taskID string) {
+	mgr.leaseLock.Lock()
+	delete(mgr.leases, taskID)
+	mgr.leaseLock.Unlock()
+}
+
+func (mgr *TranscodeMgr) retainVolumeLoop() {
+	ticker := time.NewTicker(transcodeRetainInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		mgr.retainVolumes()
+	}
+}
+
+// retainVolumes renew the lease of destination volumes not sealed
+func (mgr *TranscodeMgr) retainVolumes() {
+	mgr.leaseLock.Lock()
+	tokens := make([]string, 0, len(mgr.leases))
+	for _, token := range mgr.leases {
+		tokens = append(tokens, token)
+	}
+	mgr.leaseLock.Unlock()
+	if len(tokens) == 0 {
+		return
+	}
+
+	span, ctx := trace.StartSpanFromContext(context.Background(), "TranscodeMgr.retainVolumes")
+	defer span.Finish()
+	if err := mgr.cmCli.RetainVolume(ctx, tokens); err != nil {
+		span.Errorf("retain volumes failed, tokens: %v, err:%v", tokens, err)
+	}
+}
+
+// AddTask add transcode task of volume
+func (mgr *TranscodeMgr) AddTask(ctx context.Context, vid proto.Vid, mode codemode.CodeMode) error {
+	span := trace.SpanFromContextSafe(ctx)
+
+	volume, err := mgr.cmCli.GetVolumeInfo(ctx, vid)
+	if err != nil {
+		span.Errorf("get vid %d volume fail err:%+v", vid, err)
+		return err
+	}
+	if volume.IsActive() {
+		return ErrTranscodeActiveVolume
+	}
+	if volume.CodeMode == mode {
+		return ErrTranscodeSameCodeMode
+	}
+
+	tasks, err := mgr.taskTbl.FindBySourceVid(ctx, vid)
+	if err != nil {
+		span.Errorf("find transcode tasks of vid %d fail err:%+v", vid, err)
+		return err
+	}
+	for _, t := range tasks {
+		if t.State != proto.TranscodeStateFinishedInAdvance {
+			return ErrTranscodeTaskExist
+		}
+	}
+
+	diskID := volume.VunitLocations[0].DiskID
+	disk, err := mgr.cmCli.GetDiskInfo(ctx, diskID)
+	if err != nil {
+		span.Errorf("get disk info diskID %d fail err:%+v", diskID, err)
+		return err
+	}
+
+	task := &proto.TranscodeTask{
+		TaskID:       base.GenTaskID("transcode", vid),
+		State:        proto.TranscodeStateInited,
+		SourceIdc:    disk.Idc,
+		SourceVid:    vid,
+		CodeMode:     volume.CodeMode,
+		DestCodeMode: mode,
+	}
+	base.LoopExecUntilSuccess(ctx, "transcode add task insert task to tbl", func() error {
+		return mgr.taskTbl.Insert(ctx, task)
+	})
+
+	mgr.prepareQueue.PushTask(task.TaskID, task)
+	span.Infof("add transcode task success! task_info:%+v", task)
+	return nil
+}
+
+func (mgr *TranscodeMgr) prepareTaskLoop() {
+	for {
+		mgr.taskSwitch.WaitEnable()
+		todo, doing := mgr.workQueue.StatsTasks()
+		if todo+doing >= mgr.WorkQueueSize {
+			time.Sleep(time.Duration(prepareTaskPauseS) * time.Second)
+			continue
+		}
+		err := mgr.prepareTask()
+		if err == base.ErrNoTaskInQueue {
+			time.Sleep(prepareIntervalS)
+		}
+	}
+}
+
+func (mgr *TranscodeMgr) prepareTask() (err error) {
+	_, task, exist := mgr.prepareQueue.PopTask()
+	if !exist {
+		return base.ErrNoTaskInQueue
+	}
+
+	span, ctx := trace.StartSpanFromContext(context.Background(), "TranscodeMgr.prepareTask")
+	defer span.Finish()
+
+	defer func() {
+		if err != nil {
+			span.Errorf("prepare task %s fail %+v and retry task", task.(*proto.TranscodeTask).TaskID, err)
+			mgr.prepareQueue.RetryTask(task.(*proto.TranscodeTask).TaskID)
+		}
+	}()
+
+	// why:avoid to change task in queue
+	t := task.(*proto.TranscodeTask).Copy()
+	span.Infof("prepare task phase, taskId: %s, state: %v", t.TaskID, t.State)
+
+	err = VolTaskLockerInst().TryLock(ctx, t.SourceVid)
+	if err != nil {
+		span.Warnf("lock volume failed, volumeId:%v,err:%v", t.SourceVid, err)
+		return base.ErrVolNotOnlyOneTask
+	}
+	defer func() {
+		if err != nil {
+			VolTaskLockerInst().Unlock(ctx, t.SourceVid)
+		}
+	}()
+
+	volInfo, err := mgr.cmCli.GetVolumeInfo(ctx, t.SourceVid)
+	if err != nil {
+		span.Errorf("prepare task get volume info fail err:%+v", err)
+		return err
+	}
+
+	// no more blobs written into the source volume
+	err = mgr.cmCli.LockVolume(ctx, t.SourceVid)
+	if err != nil {
+		if rpc.DetectStatusCode(err) == comerrs.CodeLockNotAllow {
+			mgr.finishTaskInAdvance(ctx, t, "lock volume fail")
+			return nil
+		}
+		span.Errorf("lock volume failed, volumeId:%v,err:%v", t.SourceVid, err)
+		return err
+	}
+
+	destVol, token, err := mgr.cmCli.AllocVolume(ctx, t.DestCodeMode)
+	if err != nil {
+		span.Errorf("alloc volume failed, err:%v", err)
+		return err
+	}
+
+	t.CodeMode = volInfo.CodeMode
+	t.Sources = volInfo.VunitLocations
+	t.DestVid = destVol.Vid
+	t.Destinations = destVol.VunitLocations
+	t.DestToken = token
+	t.State = proto.TranscodeStatePrepared
+	mgr.addLease(t)
+
+	interrupt.Inject("transcode_prepare_task")
+
+	base.LoopExecUntilSuccess(ctx, "transcode prepare task update task tbl", func() error {
+		return mgr.taskTbl.Update(ctx, t)
+	})
+
+	mgr.workQueue.AddPreparedTask(t.SourceIdc, t.TaskID, t)
+	mgr.prepareQueue.RemoveTask(t.TaskID)
+
+	span.Infof("prepare task success, taskId: %s, dest vid: %d", t.TaskID, t.DestVid)
+	return nil
+}
+
+func (mgr *TranscodeMgr) finishTaskInAdvance(ctx context.Context, t *proto.TranscodeTask, reason string) {
+	span := trace.SpanFromContextSafe(ctx)
+	span.Infof("finish task in advance, taskId:%s, reason: %s", t.TaskID, reason)
+
+	t.State = proto.TranscodeStateFinishedInAdvance
+	t.FinishAdvanceReason = reason
+	base.LoopExecUntilSuccess(ctx, "transcode finish task in advance update task tbl", func() error {
+		return mgr.taskTbl.Update(ctx, t)
+	})
+
+	mgr.finishTaskCounter.Add()
+	mgr.prepareQueue.RemoveTask(t.TaskID)
+	VolTaskLockerInst().Unlock(ctx, t.SourceVid)
+}
+
+func (mgr *TranscodeMgr) finishTaskLoop() {
+	for {
+		mgr.taskSwitch.WaitEnable()
+		err := mgr.finishTask()
+		if err == base.ErrNoTaskInQueue {
+			time.Sleep(finishIntervalS)
+		}
+	}
+}
+
+func (mgr *TranscodeMgr) finishTask() (err error) {
+	_, task, exist := mgr.finishQueue.PopTask()
+	if !exist {
+		return base.ErrNoTaskInQueue
+	}
+
+	span, ctx := trace.StartSpanFromContext(context.Background(), "TranscodeMgr.finishTask")
+	defer span.Finish()
+
+	defer func() {
+		if err != nil {
+			mgr.finishQueue.RetryTask(task.(*proto.TranscodeTask).TaskID)
+		}
+	}()
+
+	t := task.(*proto.TranscodeTask).Copy()
+	span.Debugf("finish task phase, taskId: %s, state: %v", t.TaskID, t.State)
+
+	switch t.State {
+	case proto.TranscodeStateWorkCompleted:
+		base.LoopExecUntilSuccess(ctx, "transcode finish task update task state completed", func() error {
+			return mgr.taskTbl.Update(ctx, t)
+		})
+		if err = mgr.redirectTask(ctx, t); err != nil {
+			return
+		}
+		// the task in queue keeps redirected state for retrying
+		*task.(*proto.TranscodeTask) = *t.Copy()
+	case proto.TranscodeStateRedirected:
+	default:
+		span.Panicf("taskId %s finish state expect %d but actual %d", t.TaskID, proto.TranscodeStateWorkCompleted, t.State)
+	}
+
+	if time.Since(time.Unix(t.RedirectTime, 0)) < mgr.releaseDelay {
+		return errSourceReleaseDelayed
+	}
+	// blobs of source volume are reclaimed after redirected
+	volInfo, err := mgr.cmCli.GetVolumeInfo(ctx, t.SourceVid)
+	if err != nil {
+		span.Errorf("get source volume info failed, vid: %d, err:%v", t.SourceVid, err)
+		return
+	}
+	for _, src := range volInfo.VunitLocations {
+		err = mgr.cmCli.ReleaseVolumeUnit(ctx, src.Vuid, src.DiskID)
+		if rpc.DetectStatusCode(err) == comerrs.CodeVuidNotFound {
+			span.Infof("source volume unit has been released, vuid: %d", src.Vuid)
+			err = nil
+		}
+		if err != nil {
+			span.Errorf("release source volume unit failed, vuid: %d, err:%v", src.Vuid, err)
+			return
+		}
+	}
+
+	t.State = proto.TranscodeStateFinished
+	interrupt.Inject("transcode_save_finished")
+	base.LoopExecUntilSuccess(ctx, "transcode finish task update task state finished", func() error {
+		return mgr.taskTbl.Update(ctx, t)
+	})
+
+	mgr.finishTaskCounter.Add()
+	mgr.finishQueue.RemoveTask(t.TaskID)
+	VolTaskLockerInst().Unlock(ctx, t.SourceVid)
+
+	span.Infof("finish task phase success, taskId: %s, state: %v", t.TaskID, t.State)
+	return
+}
+
+// redirectTask seal the destination volume and redirect the source volume to it
+func (mgr *TranscodeMgr) redirectTask(ctx context.Context, t *proto.TranscodeTask) error {
+	span := trace.SpanFromContextSafe(ctx)
+
+	// no more blobs written into the destination volume, it's not allocated again
+	err := mgr.cmCli.SealVolume(ctx, t.DestVid, t.DestToken)
+	if err != nil {
+		span.Errorf("seal volume failed, vid: %d, err:%v", t.DestVid, err)
+		return err
+	}
+	mgr.removeLease(t.TaskID)
+
+	err = mgr.cmCli.SetVolumeRedirect(ctx, &cmapi.VolumeRedirect{
+		Vid:         t.SourceVid,
+		CodeMode:    t.CodeMode,
+		NewVid:      t.DestVid,
+		NewCodeMode: t.DestCodeMode,
+	})
+	if err != nil {
+		span.Errorf("set volume redirect failed, vid: %d, err:%v", t.SourceVid, err)
+		return err
+	}
+
+	t.State = proto.TranscodeStateRedirected
+	t.RedirectTime = time.Now().Unix()
+	base.LoopExecUntilSuccess(ctx, "transcode finish task update task state redirected", func() error {
+		return mgr.taskTbl.Update(ctx, t)
+	})
+	span.Infof("redirect task success, taskId: %s, vid: %d -> %d", t.TaskID, t.SourceVid, t.DestVid)
+	return nil
+}
+
+// AcquireTask acquire transcode task
+func (mgr *TranscodeMgr) AcquireTask(ctx context.Context, idc string) (*proto.TranscodeTask, error) {
+	if !mgr.taskSwitch.Enabled() {
+		return nil, proto.ErrTaskPaused
+	}
+
+	_, task, _ := mgr.workQueue.Acquire(idc)
+	if task != nil {
+		t := task.(*proto.TranscodeTask)
+		span := trace.SpanFromContextSafe(ctx)
+		span.Infof("acquire transcode taskId: %s", t.TaskID)
+		return t, nil
+	}
+	return nil, proto.ErrTaskEmpty
+}
+
+// CancelTask cancel transcode task
+func (mgr *TranscodeMgr) CancelTask(ctx context.Context, args *api.CancelTaskArgs) error {
+	span := trace.SpanFromContextSafe(ctx)
+	span.Infof("cancel transcode taskId %s", args.TaskId)
+
+	err := mgr.workQueue.Cancel(args.IDC, args.TaskId, args.Src, args.Dest)
+	if err != nil {
+		span.Errorf("cancel transcode taskId %s fail error:%v", args.TaskId, err)
+	}
+
+	mgr.taskStatsMgr.CancelTask()
+	return err
+}
+
+// CompleteTask complete transcode task
+func (mgr *TranscodeMgr) CompleteTask(ctx context.Context, args *api.CompleteTaskArgs) error {
+	span := trace.SpanFromContextSafe(ctx)
+	span.Infof("complete transcode taskId %s", args.TaskId)
+
+	completeTask, err := mgr.workQueue.Complete(args.IDC, args.TaskId, args.Src, args.Dest)
+	if err != nil {
+		span.Errorf("complete transcode taskId %s fail error:%v", args.TaskId, err)
+		return err
+	}
+
+	t := completeTask.(*proto.TranscodeTask)
+	t.State = proto.TranscodeStateWorkCompleted
+
+	// saving task info is delayed in finish stage
+	mgr.finishQueue.PushTask(args.TaskId, t)
+	return nil
+}
+
+// RenewalTask renewal transcode task
+func (mgr *TranscodeMgr) RenewalTask(ctx context.Context, idc, taskID string) error {
+	if !mgr.taskSwitch.Enabled() {
+		return proto.ErrTaskPaused
+	}
+
+	span := trace.SpanFromContextSafe(ctx)
+	span.Debugf("Renewal transcode taskID %s", taskID)
+	err := mgr.workQueue.Renewal(idc, taskID)
+	if err != nil {
+		span.Warnf("Renewal transcode taskID %s fail error:%v", taskID, err)
+	}
+	return err
+}
+
+// ReportWorkerTaskStats reports task stats
+func (mgr *TranscodeMgr) ReportWorkerTaskStats(
+	taskID string,
+	s proto.TaskStatistics,
+	increaseDataSize,
+	increaseShardCnt int) {
+	mgr.taskStatsMgr.ReportWorkerTaskStats(taskID, s, increaseDataSize, increaseShardCnt)
+}
+
+// QueryTask return task statistics
+func (mgr *TranscodeMgr) QueryTask(ctx context.Context, taskID string) (proto.TranscodeTask, proto.TaskStatistics, error) {
+	taskInfo, err := mgr.taskTbl.Find(ctx, taskID)
+	if err != nil {
+		return proto.TranscodeTask{}, proto.TaskStatistics{}, err
+	}
+	detailRunInfo, err := mgr.taskStatsMgr.QueryTaskDetail(taskID)
+	if err != nil {
+		return *taskInfo, proto.TaskStatistics{}, nil
+	}
+	return *taskInfo, detailRunInfo.Statistics, nil
+}
+
+// GetTaskStats returns task stats
+func (mgr *TranscodeMgr) GetTaskStats() (finish, dataSize, shardCnt [counter.SLOT]int) {
+	increaseDataSize, increaseShardCnt := mgr.taskStatsMgr.Counters()
+	return mgr.finishTaskCounter.Show(), increaseDataSize, increaseShardCnt
+}
+
+// StatQueueTaskCnt returns task queue stats
+func (mgr *TranscodeMgr) StatQueueTaskCnt() (inited, prepared, completed int) {
+	todo, doing := mgr.prepareQueue.StatsTasks()
+	inited = todo + doing
+
+	todo, doing = mgr.workQueue.StatsTasks()
+	prepared = todo + doing
+
+	todo, doing = mgr.finishQueue.StatsTasks()
+	completed = todo + doing
+	return
+}
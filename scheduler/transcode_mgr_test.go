@@ -0,0 +1,267 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	api "github.com/cubefs/blobstore/api/scheduler"
+	"github.com/cubefs/blobstore/common/codemode"
+	comerrors "github.com/cubefs/blobstore/common/errors"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/scheduler/base"
+	"github.com/cubefs/blobstore/scheduler/client"
+	"github.com/cubefs/blobstore/scheduler/db"
+)
+
+// ----------------------------------------------------------------------------mock cluster client
+type mockTranscodeCmClient struct {
+	volInfoMap map[proto.Vid]*client.VolumeInfoSimple
+	redirects  map[proto.Vid]*cmapi.VolumeRedirect
+	nextVid    proto.Vid
+	retained   []string
+	released   []proto.Vuid
+}
+
+func newMockTranscodeCmClient(volInfoMap map[proto.Vid]*client.VolumeInfoSimple) *mockTranscodeCmClient {
+	return &mockTranscodeCmClient{
+		volInfoMap: volInfoMap,
+		redirects:  make(map[proto.Vid]*cmapi.VolumeRedirect),
+		nextVid:    10000,
+	}
+}
+
+func (m *mockTranscodeCmClient) GetVolumeInfo(ctx context.Context, vid proto.Vid) (*client.VolumeInfoSimple, error) {
+	vol, ok := m.volInfoMap[vid]
+	if !ok {
+		return nil, comerrors.ErrVolumeNotExist
+	}
+	return vol, nil
+}
+
+func (m *mockTranscodeCmClient) LockVolume(ctx context.Context, vid proto.Vid) error {
+	if m.volInfoMap[vid].IsActive() {
+		return comerrors.ErrLockNotAllow
+	}
+	m.volInfoMap[vid].Status = proto.VolumeStatusLock
+	return nil
+}
+
+func (m *mockTranscodeCmClient) GetDiskInfo(ctx context.Context, diskID proto.DiskID) (*client.DiskInfoSimple, error) {
+	return &client.DiskInfoSimple{DiskID: diskID, Idc: "z0"}, nil
+}
+
+func (m *mockTranscodeCmClient) AllocVolume(ctx context.Context, mode codemode.CodeMode) (*client.VolumeInfoSimple, string, error) {
+	m.nextVid++
+	vol := MockMigrateGenVolInfo(m.nextVid, mode, proto.VolumeStatusActive)
+	m.volInfoMap[vol.Vid] = vol
+	return vol, "token-" + vol.Vid.ToString(), nil
+}
+
+func (m *mockTranscodeCmClient) RetainVolume(ctx context.Context, tokens []string) error {
+	m.retained = append(m.retained, tokens...)
+	return nil
+}
+
+func (m *mockTranscodeCmClient) SealVolume(ctx context.Context, vid proto.Vid, token string) error {
+	if m.volInfoMap[vid].IsActive() && token != "token-"+vid.ToString() {
+		return comerrors.ErrLockNotAllow
+	}
+	m.volInfoMap[vid].Status = proto.VolumeStatusLock
+	return nil
+}
+
+func (m *mockTranscodeCmClient) ReleaseVolumeUnit(ctx context.Context, vuid proto.Vuid, diskID proto.DiskID) error {
+	m.released = append(m.released, vuid)
+	return nil
+}
+
+func (m *mockTranscodeCmClient) SetVolumeRedirect(ctx context.Context, redirect *cmapi.VolumeRedirect) error {
+	m.redirects[redirect.Vid] = redirect
+	return nil
+}
+
+// ----------------------------------------------------------------------------mock transcode table
+type mockTranscodeTbl struct {
+	tasks map[string]*proto.TranscodeTask
+}
+
+func newMockTranscodeTbl() db.ITranscodeTaskTbl {
+	return &mockTranscodeTbl{tasks: make(map[string]*proto.TranscodeTask)}
+}
+
+func (tbl *mockTranscodeTbl) Insert(ctx context.Context, t *proto.TranscodeTask) error {
+	tbl.tasks[t.TaskID] = t.Copy()
+	return nil
+}
+
+func (tbl *mockTranscodeTbl) Update(ctx context.Context, t *proto.TranscodeTask) error {
+	tbl.tasks[t.TaskID] = t.Copy()
+	return nil
+}
+
+func (tbl *mockTranscodeTbl) Find(ctx context.Context, taskID string) (*proto.TranscodeTask, error) {
+	t, ok := tbl.tasks[taskID]
+	if !ok {
+		return nil, base.ErrNoDocuments
+	}
+	return t.Copy(), nil
+}
+
+func (tbl *mockTranscodeTbl) FindBySourceVid(ctx context.Context, vid proto.Vid) (tasks []*proto.TranscodeTask, err error) {
+	for _, t := range tbl.tasks {
+		if t.SourceVid == vid {
+			tasks = append(tasks, t.Copy())
+		}
+	}
+	return
+}
+
+func (tbl *mockTranscodeTbl) FindAll(ctx context.Context) (tasks []*proto.TranscodeTask, err error) {
+	for _, t := range tbl.tasks {
+		tasks = append(tasks, t.Copy())
+	}
+	return
+}
+
+func TestTranscodeMgr(t *testing.T) {
+	ctx := context.Background()
+	volInfos := map[proto.Vid]*client.VolumeInfoSimple{
+		701: MockMigrateGenVolInfo(701, codemode.EC6P6, proto.VolumeStatusIdle),
+		702: MockMigrateGenVolInfo(702, codemode.EC6P6, proto.VolumeStatusActive),
+		703: MockMigrateGenVolInfo(703, codemode.EC6P6, proto.VolumeStatusIdle),
+	}
+	cmCli := newMockTranscodeCmClient(volInfos)
+	taskTbl := newMockTranscodeTbl()
+	mgr := NewTranscodeMgr(cmCli, taskTbl, 1)
+	mgr.finishQueue = base.NewTaskQueue(0)
+
+	// illegal volumes
+	err := mgr.AddTask(ctx, 700, codemode.EC6P3L3)
+	require.Error(t, err)
+	err = mgr.AddTask(ctx, 702, codemode.EC6P3L3)
+	require.Equal(t, ErrTranscodeActiveVolume, err)
+	err = mgr.AddTask(ctx, 701, codemode.EC6P6)
+	require.Equal(t, ErrTranscodeSameCodeMode, err)
+
+	err = mgr.AddTask(ctx, 701, codemode.EC6P3L3)
+	require.NoError(t, err)
+	err = mgr.AddTask(ctx, 701, codemode.EC6P3L3)
+	require.Equal(t, ErrTranscodeTaskExist, err)
+	inited, prepared, completed := mgr.StatQueueTaskCnt()
+	require.Equal(t, 1, inited)
+	require.Equal(t, 0, prepared)
+	require.Equal(t, 0, completed)
+
+	// prepare
+	err = mgr.prepareTask()
+	require.NoError(t, err)
+	err = mgr.prepareTask()
+	require.Equal(t, base.ErrNoTaskInQueue, err)
+	inited, prepared, completed = mgr.StatQueueTaskCnt()
+	require.Equal(t, 0, inited)
+	require.Equal(t, 1, prepared)
+	require.Equal(t, 0, completed)
+	require.Equal(t, proto.VolumeStatusLock, volInfos[701].Status)
+
+	task, err := mgr.AcquireTask(ctx, "z0")
+	require.NoError(t, err)
+	require.Equal(t, proto.Vid(701), task.SourceVid)
+	require.Equal(t, codemode.EC6P6, task.CodeMode)
+	require.Equal(t, codemode.EC6P3L3, task.DestCodeMode)
+	require.Equal(t, 12, len(task.Sources))
+	require.Equal(t, 12, len(task.Destinations))
+	require.Equal(t, task.DestVid, task.GetDest().Vuid.Vid())
+	require.True(t, (&api.WorkerTask{TaskType: proto.TranscodeTaskType, Transcode: task}).IsValid())
+	mgr.retainVolumes()
+	require.Equal(t, []string{task.DestToken}, cmCli.retained)
+	_, err = mgr.AcquireTask(ctx, "z0")
+	require.Equal(t, proto.ErrTaskEmpty, err)
+
+	err = mgr.RenewalTask(ctx, "z0", task.TaskID)
+	require.NoError(t, err)
+	err = mgr.RenewalTask(ctx, "z1", task.TaskID)
+	require.Error(t, err)
+
+	// complete and finish
+	err = mgr.CompleteTask(ctx, &api.CompleteTaskArgs{
+		IDC: "z0", TaskId: task.TaskID, Src: task.Sources, Dest: task.Destinations[1],
+	})
+	require.Error(t, err)
+	err = mgr.CompleteTask(ctx, &api.CompleteTaskArgs{
+		IDC: "z0", TaskId: task.TaskID, Src: task.Sources, Dest: task.GetDest(),
+	})
+	require.NoError(t, err)
+	inited, prepared, completed = mgr.StatQueueTaskCnt()
+	require.Equal(t, 0, prepared)
+	require.Equal(t, 1, completed)
+
+	err = mgr.finishTask()
+	require.Equal(t, errSourceReleaseDelayed, err)
+	redirect := cmCli.redirects[701]
+	require.NotNil(t, redirect)
+	require.Equal(t, task.DestVid, redirect.NewVid)
+	require.Equal(t, codemode.EC6P6, redirect.CodeMode)
+	require.Equal(t, codemode.EC6P3L3, redirect.NewCodeMode)
+	require.Equal(t, proto.VolumeStatusLock, volInfos[701].Status)
+	require.Equal(t, proto.VolumeStatusLock, volInfos[task.DestVid].Status)
+	require.Equal(t, 0, len(cmCli.released))
+	taskInfo, _, err := mgr.QueryTask(ctx, task.TaskID)
+	require.NoError(t, err)
+	require.Equal(t, proto.TranscodeStateRedirected, taskInfo.State)
+	cmCli.retained = nil
+	mgr.retainVolumes()
+	require.Equal(t, 0, len(cmCli.retained))
+
+	// release source volume units after delay
+	mgr.releaseDelay = 0
+	err = mgr.finishTask()
+	require.NoError(t, err)
+	require.Equal(t, 12, len(cmCli.released))
+	require.Equal(t, volInfos[701].VunitLocations[0].Vuid, cmCli.released[0])
+
+	taskInfo, _, err = mgr.QueryTask(ctx, task.TaskID)
+	require.NoError(t, err)
+	require.Equal(t, proto.TranscodeStateFinished, taskInfo.State)
+	require.NoError(t, VolTaskLockerInst().TryLock(ctx, 701))
+	VolTaskLockerInst().Unlock(ctx, 701)
+
+	// finish in advance if the volume becomes active
+	err = mgr.AddTask(ctx, 703, codemode.EC6P3L3)
+	require.NoError(t, err)
+	volInfos[703].Status = proto.VolumeStatusActive
+	err = mgr.prepareTask()
+	require.NoError(t, err)
+	tasks, err := taskTbl.FindBySourceVid(ctx, 703)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(tasks))
+	require.Equal(t, proto.TranscodeStateFinishedInAdvance, tasks[0].State)
+	volInfos[703].Status = proto.VolumeStatusIdle
+	err = mgr.AddTask(ctx, 703, codemode.EC6P3L3)
+	require.NoError(t, err)
+
+	// load
+	mgr = NewTranscodeMgr(cmCli, taskTbl, 1)
+	err = mgr.Load()
+	require.NoError(t, err)
+	inited, prepared, completed = mgr.StatQueueTaskCnt()
+	require.Equal(t, 1, inited)
+	require.Equal(t, 0, prepared)
+	require.Equal(t, 0, completed)
+}
@@ -94,6 +94,21 @@ func (mr *MockStorageAPIMockRecorder) DiskInfo(arg0, arg1, arg2 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiskInfo", reflect.TypeOf((*MockStorageAPI)(nil).DiskInfo), arg0, arg1, arg2)
 }
 
+// ExportChunk mocks base method.
+func (m *MockStorageAPI) ExportChunk(arg0 context.Context, arg1 string, arg2 *blobnode.ExportChunkArgs) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportChunk", arg0, arg1, arg2)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExportChunk indicates an expected call of ExportChunk.
+func (mr *MockStorageAPIMockRecorder) ExportChunk(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportChunk", reflect.TypeOf((*MockStorageAPI)(nil).ExportChunk), arg0, arg1, arg2)
+}
+
 // GetShard mocks base method.
 func (m *MockStorageAPI) GetShard(arg0 context.Context, arg1 string, arg2 *blobnode.GetShardArgs) (io.ReadCloser, uint32, error) {
 	m.ctrl.T.Helper()
@@ -125,6 +140,21 @@ func (mr *MockStorageAPIMockRecorder) GetShards(arg0, arg1, arg2 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShards", reflect.TypeOf((*MockStorageAPI)(nil).GetShards), arg0, arg1, arg2)
 }
 
+// ImportChunk mocks base method.
+func (m *MockStorageAPI) ImportChunk(arg0 context.Context, arg1 string, arg2 *blobnode.ImportChunkArgs) (*blobnode.ChunkInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportChunk", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*blobnode.ChunkInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportChunk indicates an expected call of ImportChunk.
+func (mr *MockStorageAPIMockRecorder) ImportChunk(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportChunk", reflect.TypeOf((*MockStorageAPI)(nil).ImportChunk), arg0, arg1, arg2)
+}
+
 // IsOnline mocks base method.
 func (m *MockStorageAPI) IsOnline(arg0 context.Context, arg1 string) bool {
 	m.ctrl.T.Helper()
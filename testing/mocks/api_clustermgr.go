@@ -127,6 +127,21 @@ func (mr *MockClientAPIMockRecorder) GetVolumeInfo(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolumeInfo", reflect.TypeOf((*MockClientAPI)(nil).GetVolumeInfo), arg0, arg1)
 }
 
+// GetVolumeRedirect mocks base method.
+func (m *MockClientAPI) GetVolumeRedirect(arg0 context.Context, arg1 proto.Vid) (*clustermgr.VolumeRedirect, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVolumeRedirect", arg0, arg1)
+	ret0, _ := ret[0].(*clustermgr.VolumeRedirect)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVolumeRedirect indicates an expected call of GetVolumeRedirect.
+func (mr *MockClientAPIMockRecorder) GetVolumeRedirect(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolumeRedirect", reflect.TypeOf((*MockClientAPI)(nil).GetVolumeRedirect), arg0, arg1)
+}
+
 // RegisterService mocks base method.
 func (m *MockClientAPI) RegisterService(arg0 context.Context, arg1 clustermgr.ServiceNode, arg2, arg3, arg4 uint32) error {
 	m.ctrl.T.Helper()
@@ -80,6 +80,20 @@ func (mr *MockISchedulerMockRecorder) AddManualMigrateTask(arg0, arg1 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddManualMigrateTask", reflect.TypeOf((*MockIScheduler)(nil).AddManualMigrateTask), arg0, arg1)
 }
 
+// AddTranscodeTask mocks base method.
+func (m *MockIScheduler) AddTranscodeTask(arg0 context.Context, arg1 *scheduler.AddTranscodeArgs) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTranscodeTask", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddTranscodeTask indicates an expected call of AddTranscodeTask.
+func (mr *MockISchedulerMockRecorder) AddTranscodeTask(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTranscodeTask", reflect.TypeOf((*MockIScheduler)(nil).AddTranscodeTask), arg0, arg1)
+}
+
 // BalanceTaskDetail mocks base method.
 func (m *MockIScheduler) BalanceTaskDetail(arg0 context.Context, arg1 *scheduler.TaskStatArgs) (scheduler.MigrateTaskDetail, error) {
 	m.ctrl.T.Helper()
@@ -297,3 +311,18 @@ func (mr *MockISchedulerMockRecorder) Stats(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockIScheduler)(nil).Stats), arg0)
 }
+
+// TranscodeTaskDetail mocks base method.
+func (m *MockIScheduler) TranscodeTaskDetail(arg0 context.Context, arg1 *scheduler.TaskStatArgs) (scheduler.TranscodeTaskDetail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TranscodeTaskDetail", arg0, arg1)
+	ret0, _ := ret[0].(scheduler.TranscodeTaskDetail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TranscodeTaskDetail indicates an expected call of TranscodeTaskDetail.
+func (mr *MockISchedulerMockRecorder) TranscodeTaskDetail(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TranscodeTaskDetail", reflect.TypeOf((*MockIScheduler)(nil).TranscodeTaskDetail), arg0, arg1)
+}
@@ -16,11 +16,16 @@ package client
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
+	"github.com/cubefs/blobstore/api/access"
 	"github.com/cubefs/blobstore/api/clustermgr"
 	cmapi "github.com/cubefs/blobstore/api/clustermgr"
 	"github.com/cubefs/blobstore/common/codemode"
+	errcode "github.com/cubefs/blobstore/common/errors"
 	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/rpc"
 )
 
 // ClusterMgrAPI defines the interface of clustermgr use by tinker
@@ -28,9 +33,12 @@ type ClusterMgrAPI interface {
 	GetVolInfo(ctx context.Context, vid proto.Vid) (*VolInfo, error)
 	ListVolume(ctx context.Context, afterVid proto.Vid, count int) ([]*VolInfo, proto.Vid, error)
 	GetConfig(ctx context.Context, key string) (ret string, err error)
+	ListExpiredBlob(ctx context.Context, before int64, count int) ([]cmapi.BlobExpiry, error)
+	DeleteBlobExpiry(ctx context.Context, blobs []cmapi.BlobExpiry) error
+	ReleaseTenantOwner(ctx context.Context, key string) error
 }
 
-// VolInfo volume info
+// VolInfo volume info, units are of the new volume if Vid is redirected
 type VolInfo struct {
 	Vid            proto.Vid             `json:"vid"`
 	VunitLocations []proto.VunitLocation `json:"vunit_locations"`
@@ -92,6 +100,12 @@ type IClusterManager interface {
 	GetConfig(ctx context.Context, key string) (val string, err error)
 	GetVolumeInfo(ctx context.Context, args *cmapi.GetVolumeArgs) (ret *cmapi.VolumeInfo, err error)
 	ListVolume(ctx context.Context, args *cmapi.ListVolumeArgs) (ret cmapi.ListVolumes, err error)
+	GetVolumeRedirect(ctx context.Context, vid proto.Vid) (ret *cmapi.VolumeRedirect, err error)
+	ListExpiredBlob(ctx context.Context, args *cmapi.ListExpiredBlobArgs) (ret cmapi.ListExpiredBlobRet, err error)
+	DeleteBlobExpiry(ctx context.Context, args *cmapi.BlobExpiryArgs) (err error)
+	GetKV(ctx context.Context, key string) (ret cmapi.GetKvRet, err error)
+	DeleteKV(ctx context.Context, args *cmapi.DeleteKvArgs) (err error)
+	IncrKV(ctx context.Context, args *cmapi.IncrKvArgs) (ret cmapi.IncrKvRet, err error)
 }
 
 // ClusterMgrClient clustermgr client
@@ -110,10 +124,51 @@ func (c *ClusterMgrClient) GetVolInfo(ctx context.Context, vid proto.Vid) (*VolI
 	if err != nil {
 		return nil, err
 	}
-	ret := ParseVolInfo(v)
+	return c.parseVolInfo(ctx, v)
+}
+
+// parseVolInfo returns units of the new volume if the volume is redirected,
+// blobs of transcoded volume are deleted in the new volume.
+func (c *ClusterMgrClient) parseVolInfo(ctx context.Context, info *clustermgr.VolumeInfo) (*VolInfo, error) {
+	if err := c.checkCodeMode(ctx, info.CodeMode); err != nil {
+		return nil, err
+	}
+	if info.Status != proto.VolumeStatusLock {
+		return ParseVolInfo(info), nil
+	}
+	redirect, err := c.client.GetVolumeRedirect(ctx, info.Vid)
+	if err != nil {
+		if rpc.DetectStatusCode(err) == errcode.CodeKvNotFound {
+			return ParseVolInfo(info), nil
+		}
+		return nil, err
+	}
+	newInfo, err := c.client.GetVolumeInfo(ctx, &clustermgr.GetVolumeArgs{Vid: redirect.NewVid})
+	if err != nil {
+		return nil, err
+	}
+	if err = c.checkCodeMode(ctx, newInfo.CodeMode); err != nil {
+		return nil, err
+	}
+	ret := ParseVolInfo(newInfo)
+	ret.Vid = info.Vid
 	return ret, nil
 }
 
+// checkCodeMode syncs runtime code modes of cluster if the code mode is unknown
+func (c *ClusterMgrClient) checkCodeMode(ctx context.Context, mode codemode.CodeMode) error {
+	if mode.IsValid() {
+		return nil
+	}
+	if err := cmapi.SyncCodeModes(ctx, c); err != nil {
+		return err
+	}
+	if !mode.IsValid() {
+		return fmt.Errorf("unknown code mode %d", mode)
+	}
+	return nil
+}
+
 // ListVolume lists volume info
 func (c *ClusterMgrClient) ListVolume(ctx context.Context, afterVid proto.Vid, count int) ([]*VolInfo, proto.Vid, error) {
 	// todo cluster manager need change arg and return
@@ -123,7 +178,11 @@ func (c *ClusterMgrClient) ListVolume(ctx context.Context, afterVid proto.Vid, c
 	}
 	ret := make([]*VolInfo, 0, len(vols.Volumes))
 	for _, v := range vols.Volumes {
-		ret = append(ret, ParseVolInfo(v))
+		info, err := c.parseVolInfo(ctx, v)
+		if err != nil {
+			return nil, 0, err
+		}
+		ret = append(ret, info)
 	}
 	return ret, vols.Marker, nil
 }
@@ -136,3 +195,47 @@ func (c *ClusterMgrClient) GetConfig(ctx context.Context, key string) (ret strin
 	}
 	return string(config), nil
 }
+
+// ListExpiredBlob lists blobs expired before the unix timestamp
+func (c *ClusterMgrClient) ListExpiredBlob(ctx context.Context, before int64, count int) ([]cmapi.BlobExpiry, error) {
+	ret, err := c.client.ListExpiredBlob(ctx, &cmapi.ListExpiredBlobArgs{Before: before, Count: count})
+	if err != nil {
+		return nil, err
+	}
+	return ret.Blobs, nil
+}
+
+// DeleteBlobExpiry deletes expiry records of blobs
+func (c *ClusterMgrClient) DeleteBlobExpiry(ctx context.Context, blobs []cmapi.BlobExpiry) error {
+	return c.client.DeleteBlobExpiry(ctx, &cmapi.BlobExpiryArgs{Blobs: blobs})
+}
+
+// ReleaseTenantOwner releases usage of the tenant owner recorded in kv,
+// the owner is removed after released, nothing to do if it is not found.
+func (c *ClusterMgrClient) ReleaseTenantOwner(ctx context.Context, key string) error {
+	ret, err := c.client.GetKV(ctx, key)
+	if err != nil {
+		if rpc.DetectStatusCode(err) == errcode.CodeKvNotFound {
+			return nil
+		}
+		return err
+	}
+	var owner access.TenantOwner
+	if err = json.Unmarshal(ret.Value, &owner); err != nil {
+		return err
+	}
+	for _, incr := range []cmapi.IncrKvArgs{
+		{Key: access.TenantBytesPrefix + owner.Tenant, Delta: -owner.Bytes},
+		{Key: access.TenantObjectsPrefix + owner.Tenant, Delta: -1},
+	} {
+		if incr.Delta == 0 {
+			continue
+		}
+		incr.MustExist = true
+		incr.Token = owner.Token
+		if _, err = c.client.IncrKV(ctx, &incr); err != nil && rpc.DetectStatusCode(err) != errcode.CodeKvNotFound {
+			return err
+		}
+	}
+	return c.client.DeleteKV(ctx, &cmapi.DeleteKvArgs{Keys: []string{key}})
+}
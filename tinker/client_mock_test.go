@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	clustermgr "github.com/cubefs/blobstore/api/clustermgr"
 	proto "github.com/cubefs/blobstore/common/proto"
 	client "github.com/cubefs/blobstore/tinker/client"
 	gomock "github.com/golang/mock/gomock"
@@ -51,6 +52,20 @@ func (mr *MockClusterMgrAPIMockRecorder) GetConfig(arg0, arg1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConfig", reflect.TypeOf((*MockClusterMgrAPI)(nil).GetConfig), arg0, arg1)
 }
 
+// DeleteBlobExpiry mocks base method.
+func (m *MockClusterMgrAPI) DeleteBlobExpiry(arg0 context.Context, arg1 []clustermgr.BlobExpiry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBlobExpiry", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBlobExpiry indicates an expected call of DeleteBlobExpiry.
+func (mr *MockClusterMgrAPIMockRecorder) DeleteBlobExpiry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBlobExpiry", reflect.TypeOf((*MockClusterMgrAPI)(nil).DeleteBlobExpiry), arg0, arg1)
+}
+
 // GetVolInfo mocks base method.
 func (m *MockClusterMgrAPI) GetVolInfo(arg0 context.Context, arg1 proto.Vid) (*client.VolInfo, error) {
 	m.ctrl.T.Helper()
@@ -66,6 +81,21 @@ func (mr *MockClusterMgrAPIMockRecorder) GetVolInfo(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVolInfo", reflect.TypeOf((*MockClusterMgrAPI)(nil).GetVolInfo), arg0, arg1)
 }
 
+// ListExpiredBlob mocks base method.
+func (m *MockClusterMgrAPI) ListExpiredBlob(arg0 context.Context, arg1 int64, arg2 int) ([]clustermgr.BlobExpiry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListExpiredBlob", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]clustermgr.BlobExpiry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListExpiredBlob indicates an expected call of ListExpiredBlob.
+func (mr *MockClusterMgrAPIMockRecorder) ListExpiredBlob(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListExpiredBlob", reflect.TypeOf((*MockClusterMgrAPI)(nil).ListExpiredBlob), arg0, arg1, arg2)
+}
+
 // ListVolume mocks base method.
 func (m *MockClusterMgrAPI) ListVolume(arg0 context.Context, arg1 proto.Vid, arg2 int) ([]*client.VolInfo, proto.Vid, error) {
 	m.ctrl.T.Helper()
@@ -82,6 +112,20 @@ func (mr *MockClusterMgrAPIMockRecorder) ListVolume(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVolume", reflect.TypeOf((*MockClusterMgrAPI)(nil).ListVolume), arg0, arg1, arg2)
 }
 
+// ReleaseTenantOwner mocks base method.
+func (m *MockClusterMgrAPI) ReleaseTenantOwner(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseTenantOwner", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseTenantOwner indicates an expected call of ReleaseTenantOwner.
+func (mr *MockClusterMgrAPIMockRecorder) ReleaseTenantOwner(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseTenantOwner", reflect.TypeOf((*MockClusterMgrAPI)(nil).ReleaseTenantOwner), arg0, arg1)
+}
+
 // MockScheduler is a mock of IScheduler interface.
 type MockScheduler struct {
 	ctrl     *gomock.Controller
@@ -30,6 +30,7 @@ type Database struct {
 	DB                 *mongo.Database
 	OrphanedShardTable IOrphanedShardTbl
 	KafkaOffsetTable   IKafkaOffsetTbl
+	PackedBlobTable    IPackedBlobTbl
 }
 
 // Config database config
@@ -38,6 +39,7 @@ type Config struct {
 	DBName               string           `json:"db_name"`
 	OrphanedShardTblName string           `json:"orphaned_shard_tbl_name"`
 	KafkaOffsetTblName   string           `json:"kafka_offset_tbl_name"`
+	PackedBlobTblName    string           `json:"packed_blob_tbl_name"`
 }
 
 // OpenDatabase open database wit config
@@ -61,6 +63,11 @@ func OpenDatabase(cfg Config) (*Database, error) {
 		return nil, err
 	}
 
+	db.PackedBlobTable, err = openPackedBlobTbl(mustCreateCollection(db0, cfg.PackedBlobTblName))
+	if err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
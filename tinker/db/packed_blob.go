@@ -0,0 +1,66 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package db
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/cubefs/blobstore/common/proto"
+)
+
+// IPackedBlobTbl define the interface of tombstones of packed objects in shared blob
+type IPackedBlobTbl interface {
+	// Tombstone marks the packed object deleted,
+	// returns true if all packed objects in the shared blob have been deleted.
+	Tombstone(ctx context.Context, vid proto.Vid, bid proto.BlobID, packed proto.PackedBlob) (bool, error)
+}
+
+// PackedBlobTbl packed blob table
+type PackedBlobTbl struct {
+	coll *mongo.Collection
+}
+
+// PackedBlobInfo tombstones of shared blob, the index of deleted packed object
+// is added into Deleted, tombstone of same index is idempotent.
+type PackedBlobInfo struct {
+	Vid     proto.Vid    `bson:"vid"`
+	Bid     proto.BlobID `bson:"bid"`
+	Count   uint32       `bson:"count"`
+	Deleted []uint32     `bson:"deleted"`
+}
+
+func openPackedBlobTbl(coll *mongo.Collection) (IPackedBlobTbl, error) {
+	return &PackedBlobTbl{coll: coll}, nil
+}
+
+// Tombstone add tombstone of packed object
+func (t *PackedBlobTbl) Tombstone(ctx context.Context, vid proto.Vid, bid proto.BlobID, packed proto.PackedBlob) (bool, error) {
+	selector := bson.M{"vid": vid, "bid": bid}
+	update := bson.M{
+		"$set":      bson.M{"count": packed.Count},
+		"$addToSet": bson.M{"deleted": packed.Index},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	info := PackedBlobInfo{}
+	if err := t.coll.FindOneAndUpdate(ctx, selector, update, opts).Decode(&info); err != nil {
+		return false, err
+	}
+	return len(info.Deleted) >= int(info.Count), nil
+}
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/cubefs/blobstore/tinker/db (interfaces: IOrphanedShardTbl)
+// Source: github.com/cubefs/blobstore/tinker/db (interfaces: IOrphanedShardTbl,IPackedBlobTbl)
 
 // Package tinker is a generated GoMock package.
 package tinker
@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	proto "github.com/cubefs/blobstore/common/proto"
 	db "github.com/cubefs/blobstore/tinker/db"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -48,3 +49,41 @@ func (mr *MockOrphanedShardTblMockRecorder) SaveOrphanedShard(arg0, arg1 interfa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOrphanedShard", reflect.TypeOf((*MockOrphanedShardTbl)(nil).SaveOrphanedShard), arg0, arg1)
 }
+
+// MockPackedBlobTbl is a mock of IPackedBlobTbl interface.
+type MockPackedBlobTbl struct {
+	ctrl     *gomock.Controller
+	recorder *MockPackedBlobTblMockRecorder
+}
+
+// MockPackedBlobTblMockRecorder is the mock recorder for MockPackedBlobTbl.
+type MockPackedBlobTblMockRecorder struct {
+	mock *MockPackedBlobTbl
+}
+
+// NewMockPackedBlobTbl creates a new mock instance.
+func NewMockPackedBlobTbl(ctrl *gomock.Controller) *MockPackedBlobTbl {
+	mock := &MockPackedBlobTbl{ctrl: ctrl}
+	mock.recorder = &MockPackedBlobTblMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPackedBlobTbl) EXPECT() *MockPackedBlobTblMockRecorder {
+	return m.recorder
+}
+
+// Tombstone mocks base method.
+func (m *MockPackedBlobTbl) Tombstone(arg0 context.Context, arg1 proto.Vid, arg2 proto.BlobID, arg3 proto.PackedBlob) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tombstone", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Tombstone indicates an expected call of Tombstone.
+func (mr *MockPackedBlobTblMockRecorder) Tombstone(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tombstone", reflect.TypeOf((*MockPackedBlobTbl)(nil).Tombstone), arg0, arg1, arg2, arg3)
+}
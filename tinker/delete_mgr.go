@@ -34,6 +34,7 @@ import (
 	"github.com/cubefs/blobstore/common/trace"
 	"github.com/cubefs/blobstore/tinker/base"
 	"github.com/cubefs/blobstore/tinker/client"
+	"github.com/cubefs/blobstore/tinker/db"
 	"github.com/cubefs/blobstore/util/taskpool"
 )
 
@@ -188,6 +189,7 @@ func NewDeleteMgr(
 	offAccessor base.IOffsetAccessor,
 	blobNodeCli client.BlobNodeAPI,
 	switchMgr *taskswitch.SwitchMgr,
+	packedBlobTbl db.IPackedBlobTbl,
 ) (*DeleteMgr, error) {
 	var safeDelayTime time.Duration
 	if cfg.SafeDelayTimeH == 0 {
@@ -249,6 +251,7 @@ func NewDeleteMgr(
 		safeDelayTime:     safeDelayTime,
 		volCache:          volCache,
 		blobNodeCli:       blobNodeCli,
+		packedBlobTbl:     packedBlobTbl,
 		failMsgSender:     failMsgSender,
 
 		delSuccessCounter:      mgr.delSuccessCounter,
@@ -270,6 +273,7 @@ func NewDeleteMgr(
 		safeDelayTime:     safeDelayTime,
 		volCache:          volCache,
 		blobNodeCli:       blobNodeCli,
+		packedBlobTbl:     packedBlobTbl,
 		failMsgSender:     failMsgSender,
 
 		delSuccessCounter:      mgr.delSuccessCounter,
@@ -319,8 +323,9 @@ type deleteTopicConsumer struct {
 	consumeIntervalMs time.Duration
 	safeDelayTime     time.Duration
 
-	volCache    base.IVolumeCache
-	blobNodeCli client.BlobNodeAPI
+	volCache      base.IVolumeCache
+	blobNodeCli   client.BlobNodeAPI
+	packedBlobTbl db.IPackedBlobTbl
 
 	failMsgSender base.IProducer
 	dsm           deleteStageMgr
@@ -470,6 +475,28 @@ func (d *deleteTopicConsumer) handleOneMsg(ctx context.Context, delMsg *proto.De
 	pSpan.Infof("start delete msg: [%+v]", delMsg)
 
 	span, tmpCtx := trace.StartSpanFromContextWithTraceID(context.Background(), "handleDeleteMsg", delMsg.ReqId)
+	// the shared blob is deleted only after all packed objects in it are deleted
+	if delMsg.Packed != nil {
+		allDeleted, err := d.packedBlobTbl.Tombstone(tmpCtx, delMsg.Vid, delMsg.Bid, *delMsg.Packed)
+		if err != nil {
+			finishCh <- delBlobRet{
+				status: DelFailed,
+				err:    err,
+				delMsg: delMsg,
+			}
+			return
+		}
+		if !allDeleted {
+			span.Infof("packed object tombstoned and keep the blob: vid[%d], bid[%d], packed[%+v]",
+				delMsg.Vid, delMsg.Bid, *delMsg.Packed)
+			finishCh <- delBlobRet{
+				status: DelDone,
+				delMsg: delMsg,
+			}
+			return
+		}
+	}
+
 	err := d.deleteWithCheckVolConsistency(tmpCtx, delMsg.Vid, delMsg.Bid)
 	if err != nil {
 		finishCh <- delBlobRet{
@@ -694,12 +721,22 @@ func unmarshalMsgs(msgs []*sarama.ConsumerMessage) (delMsgs []*proto.DeleteMsg)
 
 // DeduplicateMsgs deduplicate delete messages
 func DeduplicateMsgs(ctx context.Context, delMsgs []*proto.DeleteMsg) (msgs []*proto.DeleteMsg) {
+	type msgKey struct {
+		bid    proto.BlobID
+		packed proto.PackedBlob
+	}
+
 	span := trace.SpanFromContextSafe(ctx)
-	bids := make(map[proto.BlobID]struct{})
+	keys := make(map[msgKey]struct{})
 	for _, m := range delMsgs {
-		if _, exist := bids[m.Bid]; !exist {
+		// packed objects in the same shared blob are different tasks
+		key := msgKey{bid: m.Bid}
+		if m.Packed != nil {
+			key.packed = *m.Packed
+		}
+		if _, exist := keys[key]; !exist {
 			msgs = append(msgs, m)
-			bids[m.Bid] = struct{}{}
+			keys[key] = struct{}{}
 			continue
 		}
 		span.Infof("msg dropped due to same task: msg[%+v]", m)
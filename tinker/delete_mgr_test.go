@@ -306,6 +306,69 @@ func TestDeleteTopicConsumer(t *testing.T) {
 	}
 }
 
+func TestDeleteTopicConsumerPacked(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockTopicConsumeDelete := newDeleteTopicConsumer(t)
+	mockTopicConsumeDelete.safeDelayTime = 0
+
+	consumer := mockTopicConsumeDelete.topicConsumers[0].(*MockConsumer)
+	consumer.EXPECT().CommitOffset(gomock.Any()).AnyTimes().Return(nil)
+
+	volCache := NewMockVolumeCache(ctr)
+	volCache.EXPECT().Get(gomock.Any()).AnyTimes().Return(&client.VolInfo{Vid: 2, VunitLocations: []proto.VunitLocation{
+		{Vuid: 1},
+	}}, nil)
+	mockTopicConsumeDelete.volCache = volCache
+
+	deleted := make(map[uint32]struct{})
+	packedBlobTbl := NewMockPackedBlobTbl(ctr)
+	packedBlobTbl.EXPECT().Tombstone(gomock.Any(), proto.Vid(2), proto.BlobID(2), gomock.Any()).AnyTimes().DoAndReturn(
+		func(_ context.Context, _ proto.Vid, _ proto.BlobID, packed proto.PackedBlob) (bool, error) {
+			deleted[packed.Index] = struct{}{}
+			return len(deleted) >= int(packed.Count), nil
+		})
+	mockTopicConsumeDelete.packedBlobTbl = packedBlobTbl
+
+	mockBlobNode := NewMockBlobNodeAPI(ctr)
+	mockTopicConsumeDelete.blobNodeCli = mockBlobNode
+
+	consumePacked := func(indexes ...uint32) {
+		consumer.EXPECT().ConsumeMessages(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, msgCnt int) (msgs []*sarama.ConsumerMessage) {
+				for _, idx := range indexes {
+					msg := proto.DeleteMsg{Bid: 2, Vid: 2, ReqId: "packed", Packed: &proto.PackedBlob{Index: idx, Count: 3}}
+					msgByte, _ := json.Marshal(msg)
+					msgs = append(msgs, &sarama.ConsumerMessage{Value: msgByte})
+				}
+				return
+			},
+		)
+		mockTopicConsumeDelete.consumeAndDelete(consumer, len(indexes))
+	}
+
+	// the shared blob is kept
+	consumePacked(0, 0)
+	consumePacked(1)
+	require.Equal(t, 2, len(deleted))
+
+	// all packed objects deleted
+	mockBlobNode.EXPECT().MarkDelete(gomock.Any(), gomock.Any(), proto.BlobID(2)).Times(1).Return(nil)
+	mockBlobNode.EXPECT().Delete(gomock.Any(), gomock.Any(), proto.BlobID(2)).Times(1).Return(nil)
+	consumePacked(2)
+	require.Equal(t, 3, len(deleted))
+}
+
+func TestDeduplicatePackedMsgs(t *testing.T) {
+	msgs := []*proto.DeleteMsg{
+		{Bid: 1, Vid: 1},
+		{Bid: 1, Vid: 1},
+		{Bid: 1, Vid: 1, Packed: &proto.PackedBlob{Index: 0, Count: 2}},
+		{Bid: 1, Vid: 1, Packed: &proto.PackedBlob{Index: 1, Count: 2}},
+		{Bid: 1, Vid: 1, Packed: &proto.PackedBlob{Index: 1, Count: 2}},
+	}
+	require.Equal(t, 3, len(DeduplicateMsgs(context.Background(), msgs)))
+}
+
 // comment temporary
 func TestNewDeleteMgr(t *testing.T) {
 	ctr := gomock.NewController(t)
@@ -356,7 +419,7 @@ func TestNewDeleteMgr(t *testing.T) {
 	)
 	switchMgr := taskswitch.NewSwitchMgr(mockCmClient)
 
-	service, err := NewDeleteMgr(blobCfg, volCache, accessor, mockBlobNode, switchMgr)
+	service, err := NewDeleteMgr(blobCfg, volCache, accessor, mockBlobNode, switchMgr, NewMockPackedBlobTbl(ctr))
 	require.NoError(t, err)
 
 	// run task
@@ -0,0 +1,213 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tinker
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/common/counter"
+	"github.com/cubefs/blobstore/common/kafka"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/taskswitch"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/tinker/base"
+	"github.com/cubefs/blobstore/tinker/client"
+)
+
+// BlobExpiryConfig is blob expiry config,
+// expired blobs are sent to the normal topic of blob delete.
+type BlobExpiryConfig struct {
+	ClusterID proto.ClusterID
+	Topic     string
+
+	IntervalS  int               `json:"interval_s"`
+	BatchCount int               `json:"batch_count"`
+	MsgSender  kafka.ProducerCfg `json:"msg_sender"`
+}
+
+// ExpiryMgr is blob expiry manager, sweeps expired blobs recorded in clustermgr
+type ExpiryMgr struct {
+	taskSwitch *taskswitch.TaskSwitch
+
+	clusterID    proto.ClusterID
+	interval     time.Duration
+	batchCount   int
+	cmCli        client.ClusterMgrAPI
+	delMsgSender base.IProducer
+
+	expirySuccessCounter      prometheus.Counter
+	expirySuccessCounterByMin *counter.CounterByMin
+	expiryFailCounter         prometheus.Counter
+	expiryFailCounterByMin    *counter.CounterByMin
+	errStatsDistribution      *base.ErrorStats
+}
+
+// NewExpiryMgr returns blob expiry manager
+func NewExpiryMgr(cfg *BlobExpiryConfig, cmCli client.ClusterMgrAPI, switchMgr *taskswitch.SwitchMgr) (*ExpiryMgr, error) {
+	delMsgSender, err := base.NewMsgSenderEx(cfg.Topic, &cfg.MsgSender)
+	if err != nil {
+		return nil, err
+	}
+
+	taskSwitch, err := switchMgr.AddSwitch(taskswitch.BlobExpirySwitchName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newExpiryMgr(cfg, cmCli, taskSwitch, delMsgSender), nil
+}
+
+func newExpiryMgr(cfg *BlobExpiryConfig, cmCli client.ClusterMgrAPI,
+	taskSwitch *taskswitch.TaskSwitch, delMsgSender base.IProducer) *ExpiryMgr {
+	return &ExpiryMgr{
+		taskSwitch: taskSwitch,
+
+		clusterID:    cfg.ClusterID,
+		interval:     time.Second * time.Duration(cfg.IntervalS),
+		batchCount:   cfg.BatchCount,
+		cmCli:        cmCli,
+		delMsgSender: delMsgSender,
+
+		expirySuccessCounter:      base.NewCounter(cfg.ClusterID, "expiry", base.KindSuccess),
+		expirySuccessCounterByMin: &counter.CounterByMin{},
+		expiryFailCounter:         base.NewCounter(cfg.ClusterID, "expiry", base.KindFailed),
+		expiryFailCounterByMin:    &counter.CounterByMin{},
+		errStatsDistribution:      base.NewErrorStats(),
+	}
+}
+
+// RunTask sweeps expired blobs
+func (mgr *ExpiryMgr) RunTask() {
+	go func() {
+		for {
+			mgr.taskSwitch.WaitEnable()
+			// sweep the next batch immediately if there are more expired blobs
+			if n := mgr.sweep(); n < mgr.batchCount {
+				time.Sleep(mgr.interval)
+			}
+		}
+	}()
+}
+
+// Enabled returns return if expiry task switch is enable, otherwise returns false
+func (mgr *ExpiryMgr) Enabled() bool {
+	return mgr.taskSwitch.Enabled()
+}
+
+// GetTaskStats returns task stats
+func (mgr *ExpiryMgr) GetTaskStats() (success [counter.SLOT]int, failed [counter.SLOT]int) {
+	return mgr.expirySuccessCounterByMin.Show(), mgr.expiryFailCounterByMin.Show()
+}
+
+// GetErrorStats returns error stats
+func (mgr *ExpiryMgr) GetErrorStats() (errStats []string, totalErrCnt uint64) {
+	statsResult, totalErrCnt := mgr.errStatsDistribution.Stats()
+	return base.FormatPrint(statsResult), totalErrCnt
+}
+
+// sweep sends delete messages of expired blobs, releases the tenant usage
+// of their owners, then deletes the expiry records, returns count of swept records.
+func (mgr *ExpiryMgr) sweep() int {
+	span, ctx := trace.StartSpanFromContext(context.Background(), "sweepExpiredBlobs")
+	defer span.Finish()
+
+	now := time.Now().Unix()
+	blobs, err := mgr.cmCli.ListExpiredBlob(ctx, now, mgr.batchCount)
+	if err != nil {
+		span.Errorf("list expired blobs failed: err[%+v]", err)
+		mgr.addFail(err)
+		return 0
+	}
+	if len(blobs) == 0 {
+		return 0
+	}
+	span.Infof("sweep expired blobs: len[%d]", len(blobs))
+
+	msgs := make([][]byte, 0, len(blobs))
+	for _, blob := range blobs {
+		for i := uint32(0); i < blob.Count; i++ {
+			msg, err := json.Marshal(mgr.toDeleteMsg(span, blob, i, now))
+			if err != nil {
+				span.Errorf("marshal delete msg failed: blob[%+v], err[%+v]", blob, err)
+				mgr.addFail(err)
+				return 0
+			}
+			msgs = append(msgs, msg)
+		}
+	}
+
+	if err = mgr.delMsgSender.SendMessages(msgs); err != nil {
+		span.Errorf("send delete msgs failed: err[%+v]", err)
+		mgr.addFail(err)
+		return 0
+	}
+	// usage of owners is released once whichever records of the location swept
+	if err = mgr.releaseOwners(ctx, blobs); err != nil {
+		span.Errorf("release owners of expired blobs failed: err[%+v]", err)
+		mgr.addFail(err)
+		return 0
+	}
+	// blobs will be deleted again if failed to delete records, it is idempotent
+	if err = mgr.cmCli.DeleteBlobExpiry(ctx, blobs); err != nil {
+		span.Errorf("delete blob expiry failed: err[%+v]", err)
+		mgr.addFail(err)
+		return 0
+	}
+
+	for range msgs {
+		mgr.expirySuccessCounter.Inc()
+		mgr.expirySuccessCounterByMin.Add()
+	}
+	return len(blobs)
+}
+
+func (mgr *ExpiryMgr) releaseOwners(ctx context.Context, blobs []cmapi.BlobExpiry) error {
+	released := make(map[string]struct{})
+	for _, blob := range blobs {
+		if blob.Owner == "" {
+			continue
+		}
+		if _, ok := released[blob.Owner]; ok {
+			continue
+		}
+		if err := mgr.cmCli.ReleaseTenantOwner(ctx, blob.Owner); err != nil {
+			return err
+		}
+		released[blob.Owner] = struct{}{}
+	}
+	return nil
+}
+
+func (mgr *ExpiryMgr) toDeleteMsg(span trace.Span, blob cmapi.BlobExpiry, idx uint32, now int64) proto.DeleteMsg {
+	return proto.DeleteMsg{
+		ClusterID: mgr.clusterID,
+		Bid:       blob.MinBid + proto.BlobID(idx),
+		Vid:       blob.Vid,
+		Time:      now,
+		ReqId:     span.TraceID(),
+		Packed:    blob.Packed,
+	}
+}
+
+func (mgr *ExpiryMgr) addFail(err error) {
+	mgr.expiryFailCounter.Inc()
+	mgr.expiryFailCounterByMin.Add()
+	mgr.errStatsDistribution.AddFail(err)
+}
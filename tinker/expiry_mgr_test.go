@@ -0,0 +1,111 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tinker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+
+	cmapi "github.com/cubefs/blobstore/api/clustermgr"
+	"github.com/cubefs/blobstore/common/counter"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/taskswitch"
+)
+
+func TestExpiryMgrSweep(t *testing.T) {
+	ctr := gomock.NewController(t)
+	cmClient := NewMockClusterMgrAPI(ctr)
+	producer := NewMockProducer(ctr)
+	mgr := newExpiryMgr(&BlobExpiryConfig{ClusterID: 1, IntervalS: 1, BatchCount: 3},
+		cmClient, taskswitch.NewEnabledTaskSwitch(), producer)
+	require.True(t, mgr.Enabled())
+
+	// nothing expired
+	cmClient.EXPECT().ListExpiredBlob(gomock.Any(), gomock.Any(), 3).Return(nil, nil)
+	require.Equal(t, 0, mgr.sweep())
+
+	// list failed
+	cmClient.EXPECT().ListExpiredBlob(gomock.Any(), gomock.Any(), 3).Return(nil, errMock)
+	require.Equal(t, 0, mgr.sweep())
+
+	blobs := []cmapi.BlobExpiry{
+		{Vid: 1, MinBid: 100, Count: 2, ExpireAt: 10, Owner: "owner"},
+		{Vid: 2, MinBid: 200, Count: 1, ExpireAt: 20, Packed: &proto.PackedBlob{Index: 1, Count: 2}, Owner: "owner"},
+	}
+	before := time.Now().Unix()
+	cmClient.EXPECT().ListExpiredBlob(gomock.Any(), gomock.Any(), 3).Times(4).DoAndReturn(
+		func(ctx context.Context, now int64, count int) ([]cmapi.BlobExpiry, error) {
+			require.LessOrEqual(t, before, now)
+			return blobs, nil
+		})
+
+	// send failed, records are kept
+	producer.EXPECT().SendMessages(gomock.Any()).Return(errMock)
+	require.Equal(t, 0, mgr.sweep())
+
+	var msgs []proto.DeleteMsg
+	producer.EXPECT().SendMessages(gomock.Any()).Times(3).DoAndReturn(
+		func(data [][]byte) error {
+			msgs = msgs[:0]
+			for _, b := range data {
+				var msg proto.DeleteMsg
+				require.NoError(t, json.Unmarshal(b, &msg))
+				msgs = append(msgs, msg)
+			}
+			return nil
+		})
+
+	// release owner failed, records are kept
+	cmClient.EXPECT().ReleaseTenantOwner(gomock.Any(), "owner").Return(errMock)
+	require.Equal(t, 0, mgr.sweep())
+
+	// owner of the location is released once in each sweep
+	cmClient.EXPECT().ReleaseTenantOwner(gomock.Any(), "owner").Times(2).Return(nil)
+
+	// delete records failed
+	cmClient.EXPECT().DeleteBlobExpiry(gomock.Any(), blobs).Return(errMock)
+	require.Equal(t, 0, mgr.sweep())
+	require.Equal(t, 3, len(msgs))
+
+	cmClient.EXPECT().DeleteBlobExpiry(gomock.Any(), blobs).Return(nil)
+	require.Equal(t, 2, mgr.sweep())
+	require.Equal(t, 3, len(msgs))
+	for idx, bid := range []proto.BlobID{100, 101, 200} {
+		require.Equal(t, proto.ClusterID(1), msgs[idx].ClusterID)
+		require.Equal(t, bid, msgs[idx].Bid)
+		require.True(t, msgs[idx].IsValid())
+	}
+	require.Nil(t, msgs[0].Packed)
+	require.Equal(t, proto.Vid(2), msgs[2].Vid)
+	require.Equal(t, blobs[1].Packed, msgs[2].Packed)
+
+	sum := func(counts [counter.SLOT]int) (n int) {
+		for _, c := range counts {
+			n += c
+		}
+		return
+	}
+	success, failed := mgr.GetTaskStats()
+	require.Equal(t, 3, sum(success))
+	require.Equal(t, 4, sum(failed))
+	errStats, totalErrCnt := mgr.GetErrorStats()
+	require.Equal(t, uint64(4), totalErrCnt)
+	require.NotEqual(t, 0, len(errStats))
+}
@@ -82,6 +82,7 @@ const (
 	defaultHandleBatchCnt           = 100
 	defaultFailMsgConsumeIntervalMs = 10000
 	defaultAuditLogChunkSize        = 29
+	defaultExpiryIntervalS          = 60
 )
 
 // ServiceRegisterConfig is service register info
@@ -98,6 +99,7 @@ type Config struct {
 	ServiceRegister ServiceRegisterConfig `json:"service_register"`
 	ShardRepair     ShardRepairConfig     `json:"shard_repair"`
 	BlobDelete      BlobDeleteConfig      `json:"blob_delete"`
+	BlobExpiry      BlobExpiryConfig      `json:"blob_expiry"`
 
 	Database db.Config `json:"database"`
 
@@ -142,12 +144,16 @@ func (cfg *Config) checkAndFix() (err error) {
 	if cfg.Database.OrphanedShardTblName == "" {
 		cfg.Database.OrphanedShardTblName = "orphaned_shard_tbl"
 	}
+	if cfg.Database.PackedBlobTblName == "" {
+		cfg.Database.PackedBlobTblName = "packed_blob_tbl"
+	}
 	if cfg.Database.Mongo.WriteConcern == nil {
 		cfg.Database.Mongo.WriteConcern = &mongoutil.WriteConcernConfig{TimeoutMs: defaultMongoTimeoutMs, Majority: true}
 	}
 
 	cfg.fixShardRepairConfig()
 	cfg.fixBlobDeleteConfig()
+	cfg.fixBlobExpiryConfig()
 
 	return
 }
@@ -199,6 +205,21 @@ func (cfg *Config) fixBlobDeleteConfig() {
 	cfg.BlobDelete.FailMsgSender.BrokerList = cfg.BlobDelete.BrokerList
 }
 
+func (cfg *Config) fixBlobExpiryConfig() {
+	cfg.BlobExpiry.ClusterID = cfg.ClusterID
+	if cfg.BlobExpiry.IntervalS <= 0 {
+		cfg.BlobExpiry.IntervalS = defaultExpiryIntervalS
+	}
+	if cfg.BlobExpiry.BatchCount <= 0 {
+		cfg.BlobExpiry.BatchCount = defaultHandleBatchCnt
+	}
+	if cfg.BlobExpiry.MsgSender.TimeoutMs <= 0 {
+		cfg.BlobExpiry.MsgSender.TimeoutMs = defaultClientTimeoutMs
+	}
+	cfg.BlobExpiry.Topic = cfg.BlobDelete.NormalTopic.Topic
+	cfg.BlobExpiry.MsgSender.BrokerList = cfg.BlobDelete.BrokerList
+}
+
 // Service rpc service
 type Service struct {
 	Config
@@ -207,6 +228,7 @@ type Service struct {
 	switchMgr      *taskswitch.SwitchMgr
 	shardRepairMgr base.IBaseMgr
 	deleteMgr      base.IBaseMgr
+	expiryMgr      base.IBaseMgr
 
 	volCache base.IVolumeCache
 	database *db.Database
@@ -225,6 +247,12 @@ func NewService(cfg Config) (*Service, error) {
 	}
 
 	cmCli := client.NewCmClient(&cfg.ClusterMgr)
+	if err = clustermgr.SyncCodeModes(context.Background(), cmCli); err != nil {
+		return nil, fmt.Errorf("sync code modes: err[%w]", err)
+	}
+	// keep syncing runtime code modes until the process exits
+	go clustermgr.LoopSyncCodeModes(cmCli, clustermgr.CodeModeSyncInterval, nil)
+
 	schedulerCli := client.NewSchedulerClient(&cfg.Scheduler)
 	blobNodeCli := client.NewBlobNodeClient(&cfg.BlobNode)
 	workerCli := client.NewWorkerCli(&cfg.Worker)
@@ -239,17 +267,23 @@ func NewService(cfg Config) (*Service, error) {
 		return nil, fmt.Errorf("new shard repair mgr: cfg[%+v], err[%w]", cfg.ShardRepair, err)
 	}
 
-	deleteMgr, err := NewDeleteMgr(&cfg.BlobDelete, vc, offAccessor, blobNodeCli, switchMgr)
+	deleteMgr, err := NewDeleteMgr(&cfg.BlobDelete, vc, offAccessor, blobNodeCli, switchMgr, database.PackedBlobTable)
 	if err != nil {
 		return nil, fmt.Errorf("new blob delete mgr: cfg[%+v], err[%w]", cfg.BlobDelete, err)
 	}
 
+	expiryMgr, err := NewExpiryMgr(&cfg.BlobExpiry, cmCli, switchMgr)
+	if err != nil {
+		return nil, fmt.Errorf("new blob expiry mgr: cfg[%+v], err[%w]", cfg.BlobExpiry, err)
+	}
+
 	service := &Service{
 		Config:           cfg,
 		clusterMgrClient: cmCli,
 		switchMgr:        switchMgr,
 		shardRepairMgr:   shardRepairMgr,
 		deleteMgr:        deleteMgr,
+		expiryMgr:        expiryMgr,
 		volCache:         vc,
 		database:         database,
 	}
@@ -341,15 +375,33 @@ func (s *Service) HTTPStats(c *rpc.Context) {
 		ErrStats:      repairErrStats,
 	}
 
+	// stats expiry tasks
+	expirySuccessCounter, expiryFailedCounter := s.expiryMgr.GetTaskStats()
+	expiryErrStats, expiryTotalErrCnt := s.expiryMgr.GetErrorStats()
+
+	if s.expiryMgr.Enabled() {
+		switchStatus = taskswitch.SwitchOpen
+	} else {
+		switchStatus = taskswitch.SwitchClose
+	}
+	expiryStat := api.Stat{
+		Switch:        switchStatus,
+		SuccessPerMin: fmt.Sprint(expirySuccessCounter),
+		FailedPerMin:  fmt.Sprint(expiryFailedCounter),
+		TotalErrCnt:   expiryTotalErrCnt,
+		ErrStats:      expiryErrStats,
+	}
+
 	taskStats := api.Stats{
 		ShardRepair: repairStat,
 		BlobDelete:  deleteStat,
+		BlobExpiry:  expiryStat,
 	}
 
 	c.RespondJSON(taskStats)
 }
 
-// RunTask run shard repair and blob delete tasks
+// RunTask run shard repair, blob delete and blob expiry tasks
 func (s *Service) RunTask() {
 	err := s.LoadVolInfo()
 	if err != nil {
@@ -357,6 +409,7 @@ func (s *Service) RunTask() {
 	}
 	s.shardRepairMgr.RunTask()
 	s.deleteMgr.RunTask()
+	s.expiryMgr.RunTask()
 }
 
 // Register registers self service to scheduler
@@ -28,6 +28,7 @@ import (
 	"github.com/cubefs/blobstore/common/counter"
 	"github.com/cubefs/blobstore/common/proto"
 	"github.com/cubefs/blobstore/common/rpc"
+	"github.com/cubefs/blobstore/common/taskswitch"
 	cli "github.com/cubefs/blobstore/tinker/client"
 )
 
@@ -100,11 +101,18 @@ func newMockService(t *testing.T) *Service {
 		},
 	)
 
+	expiryMgr := NewMockBaseMgr(ctr)
+	expiryMgr.EXPECT().GetTaskStats().AnyTimes().Return([counter.SLOT]int{}, [counter.SLOT]int{})
+	expiryMgr.EXPECT().GetErrorStats().AnyTimes().Return(nil, uint64(0))
+	expiryMgr.EXPECT().RunTask().AnyTimes().Return()
+	expiryMgr.EXPECT().Enabled().AnyTimes().Return(false)
+
 	return &Service{
 		clusterMgrClient: cmClient,
 		volCache:         volCache,
 		deleteMgr:        deleteMgr,
 		shardRepairMgr:   shardRepairMgr,
+		expiryMgr:        expiryMgr,
 	}
 }
 
@@ -131,8 +139,9 @@ func TestService(t *testing.T) {
 	}
 
 	for i := 0; i < 2; i++ {
-		_, err := tinkerCli.Stats(ctx, tinkerServer.URL)
+		stats, err := tinkerCli.Stats(ctx, tinkerServer.URL)
 		require.NoError(t, err)
+		require.Equal(t, taskswitch.SwitchClose, stats.BlobExpiry.Switch)
 	}
 }
 
@@ -157,6 +166,17 @@ func TestConfigCheckAndFix(t *testing.T) {
 	err := cfg.checkAndFix()
 	require.NoError(t, err)
 	require.Equal(t, defaultUpdateDurationS, cfg.VolCacheUpdateDurationS)
+	require.Equal(t, defaultExpiryIntervalS, cfg.BlobExpiry.IntervalS)
+	require.Equal(t, defaultHandleBatchCnt, cfg.BlobExpiry.BatchCount)
+
+	cfg = &Config{}
+	cfg.BlobDelete.BrokerList = []string{"127.0.0.1:9092"}
+	cfg.BlobDelete.NormalTopic.Topic = "blob_delete"
+	cfg.BlobExpiry.IntervalS = 10
+	require.NoError(t, cfg.checkAndFix())
+	require.Equal(t, 10, cfg.BlobExpiry.IntervalS)
+	require.Equal(t, "blob_delete", cfg.BlobExpiry.Topic)
+	require.Equal(t, cfg.BlobDelete.BrokerList, cfg.BlobExpiry.MsgSender.BrokerList)
 }
 
 func TestRegister(t *testing.T) {
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	blobnodeapi "github.com/cubefs/blobstore/api/blobnode"
+	cmapi "github.com/cubefs/blobstore/api/clustermgr"
 	schedulerapi "github.com/cubefs/blobstore/api/scheduler"
 	workerapi "github.com/cubefs/blobstore/api/worker"
 	"github.com/cubefs/blobstore/cmd"
@@ -77,7 +78,10 @@ func tearDown() {
 	service.Close()
 }
 
-var errHostEmpty = errors.New("my_hosts should not be empty")
+var (
+	errHostEmpty           = errors.New("my_hosts should not be empty")
+	errClusterMgrHostEmpty = errors.New("clustermgr hosts should not be empty")
+)
 
 // ServiceRegisterConfig service register config
 type ServiceRegisterConfig struct {
@@ -101,6 +105,8 @@ type Config struct {
 	DiskDropConcurrency int `json:"disk_drop_concurrency"`
 	// tasklet concurrency of single manual migrate task
 	ManualMigrateConcurrency int `json:"manual_migrate_concurrency"`
+	// tasklet concurrency of single transcode task
+	TranscodeConcurrency int `json:"transcode_concurrency"`
 	// shard repair concurrency
 	ShardRepairConcurrency int `json:"shard_repair_concurrency"`
 	// volume inspect concurrency
@@ -121,6 +127,8 @@ type Config struct {
 	Scheduler schedulerapi.Config `json:"scheduler"`
 	// blbonode client config
 	BlobNode blobnodeapi.Config `json:"blobnode"`
+	// clustermgr client config, sync runtime code modes from clustermgr
+	ClusterMgr cmapi.Config `json:"clustermgr"`
 
 	DroppedBidRecord *recordlog.Config `json:"dropped_bid_record"`
 }
@@ -146,6 +154,9 @@ func (cfg *Config) checkAndFix() (err error) {
 	if cfg.ServiceRegister.Host == "" {
 		return errHostEmpty
 	}
+	if len(cfg.ClusterMgr.Hosts) == 0 {
+		return errClusterMgrHostEmpty
+	}
 
 	fixConfigItemInt(&cfg.AcquireIntervalMs, 500)
 	fixConfigItemInt(&cfg.MaxTaskRunnerCnt, 1)
@@ -153,6 +164,7 @@ func (cfg *Config) checkAndFix() (err error) {
 	fixConfigItemInt(&cfg.BalanceConcurrency, 1)
 	fixConfigItemInt(&cfg.DiskDropConcurrency, 1)
 	fixConfigItemInt(&cfg.ManualMigrateConcurrency, 10)
+	fixConfigItemInt(&cfg.TranscodeConcurrency, 1)
 	fixConfigItemInt(&cfg.ShardRepairConcurrency, 1)
 	fixConfigItemInt(&cfg.InspectConcurrency, 1)
 	fixConfigItemInt(&cfg.DownloadShardConcurrency, 10)
@@ -184,6 +196,11 @@ func NewService(cfg *Config) (*Service, error) {
 		return nil, fmt.Errorf("check config: err[%w]", err)
 	}
 
+	cmCli := cmapi.New(&cfg.ClusterMgr)
+	if err := cmapi.SyncCodeModes(context.Background(), cmCli); err != nil {
+		return nil, fmt.Errorf("sync code modes: err[%w]", err)
+	}
+
 	base.BigBufPool = base.NewByteBufferPool(cfg.BigBufPool.BufSizeByte, cfg.BigBufPool.PoolSize)
 	base.SmallBufPool = base.NewByteBufferPool(cfg.SmallBufPool.BufSizeByte, cfg.SmallBufPool.PoolSize)
 
@@ -196,6 +213,7 @@ func NewService(cfg *Config) (*Service, error) {
 		cfg.BalanceConcurrency,
 		cfg.DiskDropConcurrency,
 		cfg.ManualMigrateConcurrency,
+		cfg.TranscodeConcurrency,
 		schedulerCli,
 		&TaskWorkerCreator{})
 
@@ -230,6 +248,7 @@ func NewService(cfg *Config) (*Service, error) {
 	}
 
 	go svr.Run()
+	go cmapi.LoopSyncCodeModes(cmCli, cmapi.CodeModeSyncInterval, svr.closeCh)
 
 	return svr, nil
 }
@@ -341,10 +360,10 @@ func (s *Service) tryAcquireTask() {
 }
 
 func (s *Service) hasTaskRunnerResource() bool {
-	repair, balance, drop, manualMig := s.taskRunnerMgr.RunningTaskCnt()
-	log.Infof("task count:repair %d balance %d drop %d manualMig %d max %d",
-		repair, balance, drop, manualMig, s.MaxTaskRunnerCnt)
-	return (repair + balance + drop + manualMig) < s.MaxTaskRunnerCnt
+	repair, balance, drop, manualMig, transcode := s.taskRunnerMgr.RunningTaskCnt()
+	log.Infof("task count:repair %d balance %d drop %d manualMig %d transcode %d max %d",
+		repair, balance, drop, manualMig, transcode, s.MaxTaskRunnerCnt)
+	return (repair + balance + drop + manualMig + transcode) < s.MaxTaskRunnerCnt
 }
 
 func (s *Service) hasInspectTaskResource() bool {
@@ -367,8 +386,8 @@ func (s *Service) acquireTask() {
 	}
 
 	if !t.IsValid() {
-		span.Errorf("task is illegal: task type[%s], disk drop[%+v], balance[%+v], repair[%+v], manual[%+v]",
-			t.TaskType, t.DiskDrop, t.Balance, t.Repair, t.ManualMigrate)
+		span.Errorf("task is illegal: task type[%s], disk drop[%+v], balance[%+v], repair[%+v], manual[%+v], transcode[%+v]",
+			t.TaskType, t.DiskDrop, t.Balance, t.Repair, t.ManualMigrate, t.Transcode)
 		return
 	}
 
@@ -407,6 +426,13 @@ func (s *Service) acquireTask() {
 			blobNodeCli:              s.blobNodeCli,
 			downloadShardConcurrency: s.DownloadShardConcurrency,
 		})
+	case proto.TranscodeTaskType:
+		taskID = t.Transcode.TaskID
+		err = s.taskRunnerMgr.AddTranscodeTask(ctx, TranscodeTaskEx{
+			taskInfo:                 t.Transcode,
+			blobNodeCli:              s.blobNodeCli,
+			downloadShardConcurrency: s.DownloadShardConcurrency,
+		})
 	default:
 		span.Fatalf("can not support task: type[%+v]", t.TaskType)
 	}
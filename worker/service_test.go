@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
@@ -174,14 +175,15 @@ func newMockService() *Service {
 	scheduler := schedulerCli
 	blobnode := blobnodeCli
 	wf := &mockWorkerFactory{
-		newRepairWorkerFn: NewMockRepairWorker,
-		newMigWorkerFn:    NewmockMigrateWorker,
+		newRepairWorkerFn:    NewMockRepairWorker,
+		newMigWorkerFn:       NewmockMigrateWorker,
+		newTranscodeWorkerFn: NewmockTranscodeWorker,
 	}
 	return &Service{
 		shardRepairLimit: count.New(1),
 		inspectTaskMgr:   NewInspectTaskMgr(1, blobnode, scheduler),
 		taskRenter: NewTaskRenter("z0", scheduler, NewTaskRunnerMgr(0, 2, 2,
-			2, 2, scheduler, wf)),
+			2, 2, 2, scheduler, wf)),
 		schedulerCli: scheduler,
 		blobNodeCli:  blobnode,
 		Config:       Config{AcquireIntervalMs: 1},
@@ -190,7 +192,7 @@ func newMockService() *Service {
 		closeCh:   make(chan struct{}, 1),
 
 		taskRunnerMgr: NewTaskRunnerMgr(0, 2, 2,
-			2, 2, scheduler, wf),
+			2, 2, 2, scheduler, wf),
 	}
 }
 
@@ -243,11 +245,16 @@ func TestSvr(t *testing.T) {
 }
 
 func TestNewService(t *testing.T) {
+	cmServer := httptest.NewServer(http.NotFoundHandler())
+	defer cmServer.Close()
 	cfg := &Config{
 		ServiceRegister: ServiceRegisterConfig{Host: "http://127.0.0.1:123"},
 	}
-
 	_, err := NewService(cfg)
+	require.ErrorIs(t, err, errClusterMgrHostEmpty)
+
+	cfg.ClusterMgr.Hosts = []string{cmServer.URL}
+	_, err = NewService(cfg)
 	require.NoError(t, err)
 
 	cfg = &Config{}
@@ -276,7 +283,10 @@ func TestCfgFix(t *testing.T) {
 	require.Error(t, err)
 
 	cfg.ServiceRegister.Host = "host1"
+	err = cfg.checkAndFix()
+	require.Error(t, err)
 
+	cfg.ClusterMgr.Hosts = []string{"cm1"}
 	err = cfg.checkAndFix()
 	require.NoError(t, err)
 	fixConfigItemInt(&cfg.AcquireIntervalMs, 500)
@@ -111,6 +111,13 @@ func NewmockMigrateWorker(task MigrateTaskEx) ITaskWorker {
 	}
 }
 
+func NewmockTranscodeWorker(task TranscodeTaskEx) ITaskWorker {
+	return &mockMigrateWorker{
+		tasklet:       mocktasklets,
+		taskletRetErr: nil,
+	}
+}
+
 func (w *mockMigrateWorker) GenTasklets(ctx context.Context) ([]Tasklet, *WorkError) {
 	time.Sleep(3600 * time.Second)
 	return w.tasklet, nil
@@ -146,8 +153,9 @@ func (w *mockMigrateWorker) GetBenchmarkBids() []*ShardInfoSimple {
 }
 
 type mockWorkerFactory struct {
-	newRepairWorkerFn func(task VolRepairTaskEx) ITaskWorker
-	newMigWorkerFn    func(task MigrateTaskEx) ITaskWorker
+	newRepairWorkerFn    func(task VolRepairTaskEx) ITaskWorker
+	newMigWorkerFn       func(task MigrateTaskEx) ITaskWorker
+	newTranscodeWorkerFn func(task TranscodeTaskEx) ITaskWorker
 }
 
 func (mwf *mockWorkerFactory) NewRepairWorker(task VolRepairTaskEx) ITaskWorker {
@@ -158,6 +166,10 @@ func (mwf *mockWorkerFactory) NewMigrateWorker(task MigrateTaskEx) ITaskWorker {
 	return mwf.newMigWorkerFn(task)
 }
 
+func (mwf *mockWorkerFactory) NewTranscodeWorker(task TranscodeTaskEx) ITaskWorker {
+	return mwf.newTranscodeWorkerFn(task)
+}
+
 type mockScheCli struct {
 	cancelRet   error
 	completeRet error
@@ -191,10 +203,11 @@ func initTestTaskRunnerMgr(t *testing.T, taskCnt int) *TaskRunnerMgr {
 		reclaimRet:  nil,
 	}
 	wf := mockWorkerFactory{
-		newRepairWorkerFn: NewMockRepairWorker,
-		newMigWorkerFn:    NewmockMigrateWorker,
+		newRepairWorkerFn:    NewMockRepairWorker,
+		newMigWorkerFn:       NewmockMigrateWorker,
+		newTranscodeWorkerFn: NewmockTranscodeWorker,
 	}
-	tm := NewTaskRunnerMgr(0, 2, 2, 2, 2, &cli, &wf)
+	tm := NewTaskRunnerMgr(0, 2, 2, 2, 2, 2, &cli, &wf)
 	ctx := context.Background()
 	for i := 0; i < taskCnt; i++ {
 		taskID := fmt.Sprintf("repair_%d", i+1)
@@ -222,6 +235,15 @@ func initTestTaskRunnerMgr(t *testing.T, taskCnt int) *TaskRunnerMgr {
 		err := tm.AddDiskDropTask(ctx, task)
 		require.NoError(t, err)
 	}
+
+	for i := 0; i < taskCnt; i++ {
+		taskID := fmt.Sprintf("transcode_%d", i+1)
+		task := TranscodeTaskEx{
+			taskInfo: &proto.TranscodeTask{TaskID: taskID},
+		}
+		err := tm.AddTranscodeTask(ctx, task)
+		require.NoError(t, err)
+	}
 	return tm
 }
 
@@ -231,9 +253,11 @@ func TestTaskRunnerMgr(t *testing.T) {
 	require.Equal(t, 10, len(tm.GetRepairAliveTask()))
 	require.Equal(t, 10, len(tm.GetBalanceAliveTask()))
 	require.Equal(t, 10, len(tm.GetDiskDropAliveTask()))
+	require.Equal(t, 10, len(tm.GetTranscodeAliveTask()))
 
 	tm.StopAllAliveRunner()
 	require.Equal(t, 0, len(tm.GetRepairAliveTask()))
 	require.Equal(t, 0, len(tm.GetBalanceAliveTask()))
 	require.Equal(t, 0, len(tm.GetDiskDropAliveTask()))
+	require.Equal(t, 0, len(tm.GetTranscodeAliveTask()))
 }
@@ -0,0 +1,227 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package worker
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/cubefs/blobstore/common/ec"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/common/trace"
+	"github.com/cubefs/blobstore/util/errors"
+	"github.com/cubefs/blobstore/util/retry"
+	"github.com/cubefs/blobstore/worker/base"
+)
+
+// transcode task use
+
+// ErrUnexpectedShardSize shard size of blob not match with code mode
+var ErrUnexpectedShardSize = errors.New("unexpected shard size")
+
+// TranscodeWorker used to manager transcode task
+type TranscodeWorker struct {
+	t           *proto.TranscodeTask
+	bolbNodeCli IVunitAccess
+
+	benchmarkBids            []*ShardInfoSimple
+	destBids                 []*ShardInfoSimple
+	downloadShardConcurrency int
+}
+
+// TranscodeTaskEx transcode task execution machine
+type TranscodeTaskEx struct {
+	taskInfo *proto.TranscodeTask
+
+	downloadShardConcurrency int
+	blobNodeCli              IVunitAccess
+}
+
+// NewTranscodeWorker returns transcode worker
+func NewTranscodeWorker(task TranscodeTaskEx) ITaskWorker {
+	return &TranscodeWorker{
+		t:                        task.taskInfo,
+		bolbNodeCli:              task.blobNodeCli,
+		downloadShardConcurrency: task.downloadShardConcurrency,
+	}
+}
+
+// GenTasklets generates transcode tasklets
+func (w *TranscodeWorker) GenTasklets(ctx context.Context) ([]Tasklet, *WorkError) {
+	span := trace.SpanFromContextSafe(ctx)
+	if base.BigBufPool == nil {
+		panic("BigBufPool should init before")
+	}
+
+	// source volume is locked by scheduler, make sure chunks are in read-only state
+	if err := retry.Timed(3, 1000).On(func() error {
+		if majorityLocked(ctx, w.bolbNodeCli, w.t.Sources, w.t.CodeMode) {
+			return nil
+		}
+		return ErrNotReadyForMigrate
+	}); err != nil {
+		return nil, OtherError(ErrNotReadyForMigrate)
+	}
+
+	benchmarkBids, err := GetBenchmarkBids(ctx, w.bolbNodeCli, w.t.Sources, w.t.CodeMode, []uint8{})
+	if err != nil {
+		span.Errorf("get benchmark bids failed: err[%+v]", err)
+		return nil, SrcError(err)
+	}
+
+	srcN := w.t.CodeMode.Tactic().N
+	destBids := make([]*ShardInfoSimple, 0, len(benchmarkBids))
+	transBids := make([]*ShardInfoSimple, 0, len(benchmarkBids))
+	expectSize := make(map[proto.BlobID]int64, len(benchmarkBids))
+	for _, bid := range benchmarkBids {
+		if bid.Size == 0 {
+			span.Infof("blob size is zero not need to transcode: bid[%d]", bid.Bid)
+			continue
+		}
+		sizes, err := ec.GetBufferSizes(int(bid.Size)*srcN, w.t.DestCodeMode.Tactic())
+		if err != nil {
+			return nil, OtherError(err)
+		}
+		expectSize[bid.Bid] = int64(sizes.ShardSize)
+		destBids = append(destBids, &ShardInfoSimple{Bid: bid.Bid, Size: int64(sizes.ShardSize)})
+		transBids = append(transBids, bid)
+	}
+
+	// bids which are already in all destinations with expected size need not transcode again
+	doneCnt := make(map[proto.BlobID]int, len(transBids))
+	for _, dest := range w.t.Destinations {
+		bids, err := GetSingleVunitNormalBids(ctx, w.bolbNodeCli, dest)
+		if err != nil {
+			span.Errorf("get single vunit normal bids failed: dest[%+v], err[%+v]", dest, err)
+			return nil, OtherError(err)
+		}
+		for _, bid := range bids {
+			if size, ok := expectSize[bid.Bid]; ok && size == bid.Size {
+				doneCnt[bid.Bid]++
+			}
+		}
+	}
+	var needBids []*ShardInfoSimple
+	for _, bid := range transBids {
+		if doneCnt[bid.Bid] == len(w.t.Destinations) {
+			span.Debugf("bid exist in all destinations: bid[%d]", bid.Bid)
+			continue
+		}
+		needBids = append(needBids, bid)
+	}
+
+	w.benchmarkBids = benchmarkBids
+	w.destBids = destBids
+	span.Debugf("task info: taskID[%s], benchmarkBids size[%d], need transcode bids size[%d]",
+		w.t.TaskID, len(benchmarkBids), len(needBids))
+	return BidsSplit(ctx, needBids, base.BigBufPool.GetBufSize()), nil
+}
+
+// ExecTasklet execute transcode tasklet
+func (w *TranscodeWorker) ExecTasklet(ctx context.Context, tasklet Tasklet) *WorkError {
+	span := trace.SpanFromContextSafe(ctx)
+
+	srcN := w.t.CodeMode.Tactic().N
+	destTactic := w.t.DestCodeMode.Tactic()
+	shardRecover := NewShardRecover(w.t.Sources, w.t.CodeMode, tasklet.bids, base.BigBufPool, w.bolbNodeCli, w.downloadShardConcurrency)
+	defer shardRecover.ReleaseBuf()
+
+	dataIdxs := make([]uint8, srcN)
+	for i := range dataIdxs {
+		dataIdxs[i] = uint8(i)
+	}
+	if err := shardRecover.RecoverShards(ctx, dataIdxs, true); err != nil {
+		return SrcError(err)
+	}
+
+	encoder, err := ec.NewEncoder(&ec.Config{CodeMode: destTactic})
+	if err != nil {
+		return OtherError(err)
+	}
+	for _, bid := range tasklet.bids {
+		// data of blob in destination is the joined data shards of source
+		sizes, err := ec.GetBufferSizes(int(bid.Size)*srcN, destTactic)
+		if err != nil {
+			return OtherError(err)
+		}
+		buf := make([]byte, sizes.ECSize)
+		for i := 0; i < srcN; i++ {
+			data, err := shardRecover.GetShard(uint8(i), bid.Bid)
+			if err != nil {
+				return OtherError(err)
+			}
+			if int64(len(data)) != bid.Size {
+				span.Errorf("shard size not match: bid[%d], idx[%d], size[%d], expect[%d]", bid.Bid, i, len(data), bid.Size)
+				return SrcError(ErrUnexpectedShardSize)
+			}
+			copy(buf[i*int(bid.Size):], data)
+		}
+
+		shards, err := encoder.Split(buf[:sizes.ECDataSize])
+		if err != nil {
+			return OtherError(err)
+		}
+		if err = encoder.Encode(shards); err != nil {
+			return OtherError(err)
+		}
+		if len(shards) != len(w.t.Destinations) {
+			return OtherError(ErrUnexpectedShardSize)
+		}
+
+		for i, dest := range w.t.Destinations {
+			err = tryPutShard(ctx, w.bolbNodeCli, dest, bid.Bid, int64(sizes.ShardSize), bytes.NewReader(shards[i]))
+			if err != nil {
+				span.Errorf("put shard failed: dest[%+v], bid[%d], err[%+v]", dest, bid.Bid, err)
+				return OtherError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// Check checks transcode task execute result
+func (w *TranscodeWorker) Check(ctx context.Context) *WorkError {
+	for _, dest := range w.t.Destinations {
+		if err := CheckVunit(ctx, w.destBids, dest, w.bolbNodeCli); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBenchmarkBids returns benchmark bids
+func (w *TranscodeWorker) GetBenchmarkBids() []*ShardInfoSimple {
+	return w.benchmarkBids
+}
+
+// CancelArgs returns cancel args
+func (w *TranscodeWorker) CancelArgs() (taskID, taskType string, src []proto.VunitLocation, dest proto.VunitLocation) {
+	return w.t.TaskID, proto.TranscodeTaskType, w.t.Sources, w.t.GetDest()
+}
+
+// CompleteArgs returns complete args
+func (w *TranscodeWorker) CompleteArgs() (taskID, taskType string, src []proto.VunitLocation, dest proto.VunitLocation) {
+	return w.t.TaskID, proto.TranscodeTaskType, w.t.Sources, w.t.GetDest()
+}
+
+// ReclaimArgs returns reclaim args
+func (w *TranscodeWorker) ReclaimArgs() (taskID, taskType string, src []proto.VunitLocation, dest proto.VunitLocation) {
+	return w.t.TaskID, proto.TranscodeTaskType, w.t.Sources, w.t.GetDest()
+}
+
+// TaskType returns task type
+func (w *TranscodeWorker) TaskType() (taskType string) {
+	return proto.TranscodeTaskType
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 The CubeFS Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package worker
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	api "github.com/cubefs/blobstore/api/blobnode"
+	"github.com/cubefs/blobstore/common/codemode"
+	"github.com/cubefs/blobstore/common/proto"
+	"github.com/cubefs/blobstore/util/errors"
+	"github.com/cubefs/blobstore/worker/base"
+)
+
+func TestTranscodeWorker(t *testing.T) {
+	ctx := context.Background()
+	srcMode := codemode.EC6P6
+	destMode := codemode.EC3P3
+	srcReplicas, _ := genMockVol(100, srcMode)
+	destReplicas, _ := genMockVol(200, destMode)
+	task := &proto.TranscodeTask{
+		TaskID:       "mock_transcode_task_id",
+		CodeMode:     srcMode,
+		Sources:      srcReplicas,
+		DestVid:      200,
+		DestCodeMode: destMode,
+		Destinations: destReplicas,
+	}
+	bids := []proto.BlobID{1, 2, 3, 4, 5, 6, 7}
+	sizes := []int64{1024, 2048, 0, 512, 23, 65, 12}
+
+	base.BigBufPool = base.NewByteBufferPool(2*1024, 10)
+	getter := NewMockGetterWithBids(srcReplicas, srcMode, bids, sizes)
+	for _, replica := range destReplicas {
+		getter.vunits[replica.Vuid] = newMockVunit(replica.Vuid, api.ChunkStatusNormal)
+	}
+	w := NewTranscodeWorker(TranscodeTaskEx{taskInfo: task, blobNodeCli: getter, downloadShardConcurrency: 1})
+	require.Equal(t, proto.TranscodeTaskType, w.TaskType())
+
+	getter.setFail(destReplicas[1].Vuid, errors.New("fake error"))
+	_, err := w.GenTasklets(ctx)
+	require.Equal(t, OtherErr, err.errType)
+	getter.setWell(destReplicas[1].Vuid)
+
+	tasklets, err := w.GenTasklets(ctx)
+	require.Nil(t, err)
+	transBids := 0
+	for _, tasklet := range tasklets {
+		transBids += len(tasklet.bids)
+		require.Nil(t, w.ExecTasklet(ctx, tasklet))
+	}
+	require.Equal(t, len(bids)-1, transBids)
+	require.Nil(t, w.Check(ctx))
+
+	// data of destination blob is the joined data shards of source
+	srcN := srcMode.Tactic().N
+	destN := destMode.Tactic().N
+	for i, bid := range bids {
+		if sizes[i] == 0 {
+			continue
+		}
+		var srcData, destData []byte
+		for _, replica := range srcReplicas[:srcN] {
+			srcData = append(srcData, readMockShard(t, getter, replica, bid)...)
+		}
+		for _, replica := range destReplicas[:destN] {
+			destData = append(destData, readMockShard(t, getter, replica, bid)...)
+		}
+		require.Equal(t, srcData, destData[:len(srcData)])
+	}
+
+	tasklets, err = w.GenTasklets(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(tasklets))
+
+	getter.Delete(ctx, destReplicas[0].Vuid, bids[0])
+	require.NotNil(t, w.Check(ctx))
+	tasklets, err = w.GenTasklets(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(tasklets))
+	require.Equal(t, bids[0], tasklets[0].bids[0].Bid)
+}
+
+func readMockShard(t *testing.T, getter *MockGetter, location proto.VunitLocation, bid proto.BlobID) []byte {
+	body, _, err := getter.GetShard(context.Background(), location, bid)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	return data
+}